@@ -0,0 +1,291 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// The minimum number of times a query shape must have been observed before the advisor will
+// suggest an index for it.
+var AdvisorMinimumQueries int64 = 10
+
+type AdviceKind string
+
+const (
+	AdviseCompositeIndex AdviceKind = `composite_index`
+	AdviseGlobalIndex    AdviceKind = `global_secondary_index`
+	AdviseIndexMapping   AdviceKind = `index_mapping`
+	AdviseUnusedField    AdviceKind = `unused_field`
+	AdviseUnusedTable    AdviceKind = `unused_collection`
+)
+
+// A single suggestion produced by the advisor, along with a snippet that can be applied to the
+// schema or backend directly.
+type IndexAdvice struct {
+	Collection string     `json:"collection"`
+	Kind       AdviceKind `json:"kind"`
+	Fields     []string   `json:"fields,omitempty"`
+	Queries    int64      `json:"queries,omitempty"`
+	Reason     string     `json:"reason"`
+	Snippet    string     `json:"snippet,omitempty"`
+}
+
+func (self *IndexAdvice) String() string {
+	return fmt.Sprintf("%s %s(%s): %s", self.Kind, self.Collection, strings.Join(self.Fields, `, `), self.Reason)
+}
+
+// Analyzes the recorded usage statistics and query shapes for the given collections (or all
+// collections if none are given) and suggests indexes for frequently-performed queries, as well as
+// fields and collections that are never read or queried.  Snippets are generated in the dialect
+// of the backend (or its external indexer, if one is configured).
+func Advise(tracker *UsageTrackingBackend, minQueries int64, collections ...string) ([]*IndexAdvice, error) {
+	if minQueries <= 0 {
+		minQueries = AdvisorMinimumQueries
+	}
+
+	if len(collections) == 0 {
+		if names, err := tracker.ListCollections(); err == nil {
+			collections = names
+		} else {
+			return nil, err
+		}
+	}
+
+	collections = sliceutil.UniqueStrings(collections)
+	advice := make([]*IndexAdvice, 0)
+	stats := make(map[string]*UsageStat)
+	shapes := make(map[string][]*QueryShape)
+
+	for _, stat := range tracker.Usage() {
+		stats[stat.Key()] = stat
+	}
+
+	for _, shape := range tracker.QueryShapes() {
+		shapes[shape.Collection] = append(shapes[shape.Collection], shape)
+	}
+
+	for _, name := range collections {
+		if name == UsageCollectionName || name == QueryShapeCollectionName {
+			continue
+		}
+
+		collection, err := tracker.GetCollection(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		dialect := advisorDialect(tracker, collection)
+
+		// collections that have never been touched are candidates for removal
+		if stat, ok := stats[collection.Name]; !ok || stat.LastUsed().IsZero() {
+			advice = append(advice, &IndexAdvice{
+				Collection: collection.Name,
+				Kind:       AdviseUnusedTable,
+				Reason:     `collection has not been read, written, or queried since usage tracking began`,
+			})
+
+			continue
+		}
+
+		for _, shape := range shapes[collection.Name] {
+			if shape.Count < minQueries {
+				continue
+			}
+
+			fields := shape.Fields()
+
+			// queries that only match on the identity field are already served by the primary key
+			if len(fields) == 0 || (len(fields) == 1 && fields[0] == collection.GetIdentityFieldName()) {
+				continue
+			}
+
+			if suggestion := adviseIndex(dialect, collection, shape); suggestion != nil {
+				advice = append(advice, suggestion)
+			}
+		}
+
+		for _, field := range collection.Fields {
+			if field.Identity || field.Key {
+				continue
+			}
+
+			if stat, ok := stats[collection.Name+`.`+field.Name]; !ok || (stat.Reads == 0 && stat.Queries == 0) {
+				suggestion := &IndexAdvice{
+					Collection: collection.Name,
+					Kind:       AdviseUnusedField,
+					Fields:     []string{field.Name},
+					Reason:     `field has not been read or queried since usage tracking began`,
+				}
+
+				if dialect == `sql` {
+					suggestion.Snippet = fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", collection.Name, field.Name)
+				}
+
+				advice = append(advice, suggestion)
+			}
+		}
+	}
+
+	sort.SliceStable(advice, func(i int, j int) bool {
+		if advice[i].Collection == advice[j].Collection {
+			return advice[i].Queries > advice[j].Queries
+		}
+
+		return advice[i].Collection < advice[j].Collection
+	})
+
+	return advice, nil
+}
+
+func adviseIndex(dialect string, collection *dal.Collection, shape *QueryShape) *IndexAdvice {
+	fields := shape.Fields()
+	suggestion := &IndexAdvice{
+		Collection: collection.Name,
+		Fields:     fields,
+		Queries:    shape.Count,
+		Reason: fmt.Sprintf(
+			"%d queries matched on [%s], ranged over [%s], and sorted by [%s]",
+			shape.Count,
+			strings.Join(shape.Equality, `, `),
+			strings.Join(shape.Range, `, `),
+			strings.Join(shape.Sort, `, `),
+		),
+	}
+
+	switch dialect {
+	case `sql`:
+		suggestion.Kind = AdviseCompositeIndex
+		suggestion.Snippet = fmt.Sprintf(
+			"CREATE INDEX idx_%s_%s ON %s (%s);",
+			collection.Name,
+			strings.Join(fields, `_`),
+			collection.Name,
+			strings.Join(fields, `, `),
+		)
+
+	case `dynamodb`:
+		var hashKey string
+		var rangeKey string
+
+		// DynamoDB indexes are limited to a single hash key and an optional range key; the
+		// remaining fields are projected into the index so they can be filtered on
+		if len(shape.Equality) > 0 {
+			hashKey = shape.Equality[0]
+		} else {
+			hashKey = fields[0]
+		}
+
+		for _, field := range append(append([]string{}, shape.Range...), shape.Sort...) {
+			if field != hashKey {
+				rangeKey = field
+				break
+			}
+		}
+
+		keySchema := []map[string]interface{}{
+			{`AttributeName`: hashKey, `KeyType`: `HASH`},
+		}
+
+		if rangeKey != `` {
+			keySchema = append(keySchema, map[string]interface{}{
+				`AttributeName`: rangeKey,
+				`KeyType`:       `RANGE`,
+			})
+		}
+
+		suggestion.Kind = AdviseGlobalIndex
+		suggestion.Snippet = advisorJSON(map[string]interface{}{
+			`IndexName`: fmt.Sprintf("%s_%s_index", collection.Name, strings.Join(fields, `_`)),
+			`KeySchema`: keySchema,
+			`Projection`: map[string]interface{}{
+				`ProjectionType`: `ALL`,
+			},
+		})
+
+	case `mapping`:
+		properties := make(map[string]interface{})
+
+		for _, name := range fields {
+			properties[name] = advisorMappingFor(collection, name, sliceutil.ContainsString(shape.Equality, name))
+		}
+
+		suggestion.Kind = AdviseIndexMapping
+		suggestion.Snippet = advisorJSON(map[string]interface{}{
+			`properties`: properties,
+		})
+
+	default:
+		return nil
+	}
+
+	return suggestion
+}
+
+// determine which kind of snippets to generate based on where queries for this collection are
+// actually being served from
+func advisorDialect(backend Backend, collection *dal.Collection) string {
+	scheme := backend.GetConnectionString().Backend()
+
+	if indexer := backend.WithSearch(collection); indexer != nil {
+		if cs := indexer.IndexConnectionString(); cs != nil && cs.Backend() != `` {
+			scheme = cs.Backend()
+		}
+	}
+
+	switch scheme {
+	case `mysql`, `tidb`, `postgres`, `postgresql`, `psql`, `cockroach`, `mssql`, `sqlite`:
+		return `sql`
+	case `dynamodb`:
+		return `dynamodb`
+	case `elasticsearch`, `es`, `bleve`:
+		return `mapping`
+	default:
+		return ``
+	}
+}
+
+func advisorMappingFor(collection *dal.Collection, name string, exact bool) map[string]interface{} {
+	var fieldType dal.Type
+
+	if field, ok := collection.GetField(name); ok {
+		fieldType = field.Type
+	}
+
+	switch fieldType {
+	case dal.IntType:
+		return map[string]interface{}{`type`: `long`}
+	case dal.FloatType:
+		return map[string]interface{}{`type`: `double`}
+	case dal.BooleanType:
+		return map[string]interface{}{`type`: `boolean`}
+	case dal.TimeType:
+		return map[string]interface{}{`type`: `date`}
+	default:
+		if exact {
+			return map[string]interface{}{`type`: `keyword`}
+		} else {
+			return map[string]interface{}{
+				`type`: `text`,
+				`fields`: map[string]interface{}{
+					`raw`: map[string]interface{}{
+						`type`: `keyword`,
+					},
+				},
+			}
+		}
+	}
+}
+
+func advisorJSON(value interface{}) string {
+	if data, err := json.MarshalIndent(value, ``, `  `); err == nil {
+		return string(data)
+	} else {
+		return ``
+	}
+}
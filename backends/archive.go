@@ -0,0 +1,419 @@
+package backends
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/klauspost/compress/zstd"
+)
+
+// The version of the archive format written by ExportArchive.  Archives with a newer version
+// cannot be imported.
+var ArchiveFormatVersion = 1
+
+// The name of the manifest, which is always the first entry in an archive.
+var ArchiveManifestName = `manifest.json`
+
+// The extension given to the entries holding each collection's records.
+var ArchiveDataExtension = `.ndjson.zst`
+
+// Describes the contents of an archive.  Archives are tar streams that begin with this manifest
+// (as JSON), followed by one entry per collection holding that collection's records as
+// zstd-compressed, newline-delimited JSON (the same format written by Dump).
+type ArchiveManifest struct {
+	Version     int                 `json:"version"`
+	CreatedAt   time.Time           `json:"created_at"`
+	Collections []ArchiveCollection `json:"collections"`
+}
+
+// Describes a single collection stored in an archive.
+type ArchiveCollection struct {
+	Name          string          `json:"name"`
+	Schema        *dal.Collection `json:"schema"`
+	SchemaVersion int             `json:"schema_version"` // the version of the collection's schema that the records were exported from (see UpgradingBackend)
+	File          string          `json:"file"`           // the name of the archive entry holding the records
+	Records       int             `json:"records"`        // the number of records in the entry
+	Size          int64           `json:"size"`           // the compressed size of the entry, in bytes
+	Checksum      string          `json:"checksum"`       // the SHA-256 checksum of the compressed entry (e.g.: "sha256:...")
+}
+
+// Returns the manifest entry for the named collection.
+func (self *ArchiveManifest) Collection(name string) (ArchiveCollection, bool) {
+	for _, collection := range self.Collections {
+		if collection.Name == name {
+			return collection, true
+		}
+	}
+
+	return ArchiveCollection{}, false
+}
+
+// Specifies how an archive is imported.
+type ImportArchiveOptions struct {
+	Collections       []string // if set, only import these collections
+	CreateCollections bool     // create collections that don't exist using the schema stored in the archive
+	BatchSize         int      // the number of records to write to the backend at a time (default: DumpBatchSize)
+	VerifyOnly        bool     // only verify the archive's integrity; don't write anything to the backend
+}
+
+// Writes the given collections (or all collections if none are given) to w as an archive.
+// Records are compressed as they are read from the backend, and are staged in temporary files
+// until their checksums are known and the manifest can be written.
+func ExportArchive(backend Backend, w io.Writer, collections ...string) (*ArchiveManifest, error) {
+	var manifest = &ArchiveManifest{
+		Version:   ArchiveFormatVersion,
+		CreatedAt: time.Now(),
+	}
+
+	if len(collections) == 0 {
+		if names, err := backend.ListCollections(); err == nil {
+			collections = names
+		} else {
+			return nil, err
+		}
+	}
+
+	staging, err := ioutil.TempDir(``, `pivot-export-`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.RemoveAll(staging)
+
+	for i, name := range collections {
+		if schema, err := backend.GetCollection(name); err == nil {
+			var entry = ArchiveCollection{
+				Name:          name,
+				Schema:        schema,
+				SchemaVersion: archiveSchemaVersion(backend, name),
+				File:          fmt.Sprintf("%04d-%s%s", i, filepath.Base(name), ArchiveDataExtension),
+			}
+
+			if err := exportArchiveCollection(backend, filepath.Join(staging, entry.File), &entry); err != nil {
+				return nil, fmt.Errorf("collection %q: %v", name, err)
+			}
+
+			manifest.Collections = append(manifest.Collections, entry)
+		} else {
+			return nil, fmt.Errorf("collection %q: %v", name, err)
+		}
+	}
+
+	var archive = tar.NewWriter(w)
+
+	if data, err := json.MarshalIndent(manifest, ``, `  `); err == nil {
+		if err := archive.WriteHeader(&tar.Header{
+			Name:    ArchiveManifestName,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: manifest.CreatedAt,
+		}); err != nil {
+			return nil, err
+		} else if _, err := archive.Write(data); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+
+	for _, entry := range manifest.Collections {
+		if err := archive.WriteHeader(&tar.Header{
+			Name:    entry.File,
+			Mode:    0644,
+			Size:    entry.Size,
+			ModTime: manifest.CreatedAt,
+		}); err != nil {
+			return nil, err
+		}
+
+		if file, err := os.Open(filepath.Join(staging, entry.File)); err == nil {
+			_, err := io.Copy(archive, file)
+			file.Close()
+
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	return manifest, archive.Close()
+}
+
+// compress the records of a collection into the given file, recording their number, size, and
+// checksum in the manifest entry
+func exportArchiveCollection(backend Backend, filename string, entry *ArchiveCollection) error {
+	file, err := os.Create(filename)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	var checksum = sha256.New()
+	var counter = &archiveCountingWriter{w: io.MultiWriter(file, checksum)}
+
+	if compressor, err := zstd.NewWriter(counter); err == nil {
+		if n, err := Dump(backend, entry.Name, nil, compressor, 0); err == nil {
+			entry.Records = n
+		} else {
+			compressor.Close()
+			return err
+		}
+
+		if err := compressor.Close(); err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	entry.Size = counter.n
+	entry.Checksum = archiveChecksum(checksum)
+
+	return file.Close()
+}
+
+// Reads an archive from r, verifying it against its manifest without importing anything.
+func VerifyArchive(r io.Reader) (*ArchiveManifest, error) {
+	return ImportArchive(nil, r, ImportArchiveOptions{
+		VerifyOnly: true,
+	})
+}
+
+// Reads an archive from r and writes its records to the backend.  The entire archive is verified
+// before anything is written: every collection in the manifest must be present with the expected
+// size, checksum, and number of records, and the backend must not have an older schema version
+// than the one the records were exported from.
+func ImportArchive(backend Backend, r io.Reader, options ImportArchiveOptions) (*ArchiveManifest, error) {
+	var manifest ArchiveManifest
+	var archive = tar.NewReader(r)
+
+	if header, err := archive.Next(); err == nil {
+		if header.Name != ArchiveManifestName {
+			return nil, fmt.Errorf("invalid archive: expected %s as the first entry, got %q", ArchiveManifestName, header.Name)
+		} else if err := json.NewDecoder(archive).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("invalid archive manifest: %v", err)
+		}
+	} else {
+		return nil, fmt.Errorf("invalid archive: %v", err)
+	}
+
+	if manifest.Version > ArchiveFormatVersion {
+		return nil, fmt.Errorf("archive format version %d is newer than the supported version (%d)", manifest.Version, ArchiveFormatVersion)
+	}
+
+	var wanted = func(name string) bool {
+		return len(options.Collections) == 0 || sliceutil.ContainsString(options.Collections, name)
+	}
+
+	for _, name := range options.Collections {
+		if _, ok := manifest.Collection(name); !ok {
+			return nil, fmt.Errorf("collection %q is not in the archive", name)
+		}
+	}
+
+	staging, err := ioutil.TempDir(``, `pivot-import-`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.RemoveAll(staging)
+
+	var verified = make(map[string]bool)
+
+	// verify every entry before importing any of them
+	for {
+		header, err := archive.Next()
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid archive: %v", err)
+		}
+
+		var entry *ArchiveCollection
+
+		for i := range manifest.Collections {
+			if manifest.Collections[i].File == header.Name {
+				entry = &manifest.Collections[i]
+				break
+			}
+		}
+
+		if entry == nil {
+			return nil, fmt.Errorf("invalid archive: entry %q is not in the manifest", header.Name)
+		} else if verified[entry.Name] {
+			return nil, fmt.Errorf("invalid archive: entry %q appears more than once", header.Name)
+		}
+
+		if err := verifyArchiveEntry(archive, filepath.Join(staging, filepath.Base(entry.File)), entry); err != nil {
+			return nil, fmt.Errorf("collection %q: %v", entry.Name, err)
+		}
+
+		verified[entry.Name] = true
+	}
+
+	for _, entry := range manifest.Collections {
+		if !verified[entry.Name] {
+			return nil, fmt.Errorf("invalid archive: collection %q is missing", entry.Name)
+		}
+	}
+
+	if options.VerifyOnly || backend == nil {
+		return &manifest, nil
+	}
+
+	for _, entry := range manifest.Collections {
+		if !wanted(entry.Name) {
+			continue
+		} else if err := prepareArchiveCollection(backend, entry, options); err != nil {
+			return nil, fmt.Errorf("collection %q: %v", entry.Name, err)
+		}
+	}
+
+	for _, entry := range manifest.Collections {
+		if !wanted(entry.Name) {
+			continue
+		} else if err := importArchiveEntry(backend, filepath.Join(staging, filepath.Base(entry.File)), entry, options); err != nil {
+			return nil, fmt.Errorf("collection %q: %v", entry.Name, err)
+		}
+	}
+
+	return &manifest, nil
+}
+
+// restore the records of a verified archive entry
+func importArchiveEntry(backend Backend, filename string, entry ArchiveCollection, options ImportArchiveOptions) error {
+	file, err := os.Open(filename)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	if decompressor, err := zstd.NewReader(bufio.NewReader(file)); err == nil {
+		defer decompressor.Close()
+
+		if n, err := Restore(backend, entry.Name, decompressor, options.BatchSize); err != nil {
+			return fmt.Errorf("restore failed after %d records: %v", n, err)
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// copy an archive entry into the given file, verifying its size and checksum, and that it holds
+// the expected number of valid records
+func verifyArchiveEntry(r io.Reader, filename string, entry *ArchiveCollection) error {
+	file, err := os.Create(filename)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	var checksum = sha256.New()
+
+	if n, err := io.Copy(io.MultiWriter(file, checksum), r); err != nil {
+		return err
+	} else if n != entry.Size {
+		return fmt.Errorf("size mismatch: expected %d bytes, got %d", entry.Size, n)
+	} else if actual := archiveChecksum(checksum); actual != entry.Checksum {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", entry.Checksum, actual)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if decompressor, err := zstd.NewReader(bufio.NewReader(file)); err == nil {
+		defer decompressor.Close()
+
+		var decoder = json.NewDecoder(decompressor)
+		var records int
+
+		for {
+			var record dal.Record
+
+			if err := decoder.Decode(&record); err == io.EOF {
+				break
+			} else if err != nil {
+				return fmt.Errorf("record %d: %v", records+1, err)
+			}
+
+			records += 1
+		}
+
+		if records != entry.Records {
+			return fmt.Errorf("record count mismatch: expected %d, got %d", entry.Records, records)
+		}
+	} else {
+		return err
+	}
+
+	return nil
+}
+
+// make sure the backend can accept the records of an archived collection, creating the collection
+// if necessary and allowed
+func prepareArchiveCollection(backend Backend, entry ArchiveCollection, options ImportArchiveOptions) error {
+	if current := archiveSchemaVersion(backend, entry.Name); entry.SchemaVersion > current {
+		return fmt.Errorf("records were exported from schema version %d, but the backend is only at version %d", entry.SchemaVersion, current)
+	}
+
+	if _, err := backend.GetCollection(entry.Name); err == nil {
+		return nil
+	} else if dal.IsCollectionNotFoundErr(err) && options.CreateCollections && entry.Schema != nil {
+		return backend.CreateCollection(entry.Schema)
+	} else {
+		return err
+	}
+}
+
+// returns the schema version that the backend stamps records in the given collection with, which
+// is always 1 unless the backend is (or wraps) an UpgradingBackend
+func archiveSchemaVersion(backend Backend, collection string) int {
+	for _, backend := range BackendChain(backend) {
+		if upgrader, ok := backend.(*UpgradingBackend); ok {
+			return upgrader.CurrentVersion(collection)
+		}
+	}
+
+	return 1
+}
+
+func archiveChecksum(h hash.Hash) string {
+	return `sha256:` + hex.EncodeToString(h.Sum(nil))
+}
+
+type archiveCountingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (self *archiveCountingWriter) Write(p []byte) (int, error) {
+	n, err := self.w.Write(p)
+	self.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,152 @@
+package backends_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+// rewrite an archive, replacing the contents of the named entry
+func rewriteArchive(t *testing.T, archive []byte, name string, contents []byte) []byte {
+	var out bytes.Buffer
+	var r = tar.NewReader(bytes.NewReader(archive))
+	var w = tar.NewWriter(&out)
+
+	for {
+		header, err := r.Next()
+
+		if err != nil {
+			break
+		}
+
+		data, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+
+		if header.Name == name {
+			data = contents
+			header.Size = int64(len(data))
+		}
+
+		require.NoError(t, w.WriteHeader(header))
+		_, err = w.Write(data)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.Close())
+	return out.Bytes()
+}
+
+func TestArchiveExportImport(t *testing.T) {
+	assert := require.New(t)
+
+	schemata := []*dal.Collection{
+		dal.NewCollection(`people`, dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		}),
+		dal.NewCollection(`places`, dal.Field{
+			Name: `city`,
+			Type: dal.StringType,
+		}),
+	}
+
+	source := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	for _, schema := range schemata {
+		assert.NoError(source.CreateCollection(schema))
+	}
+
+	assert.NoError(source.Insert(`people`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `Alice`),
+		dal.NewRecord(2).Set(`name`, `Bob`),
+	)))
+
+	assert.NoError(source.Insert(`places`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`city`, `Lisbon`),
+	)))
+
+	var buf bytes.Buffer
+
+	manifest, err := backends.ExportArchive(source, &buf, `people`, `places`)
+	assert.NoError(err)
+	assert.Len(manifest.Collections, 2)
+
+	people, ok := manifest.Collection(`people`)
+	assert.True(ok)
+	assert.Equal(2, people.Records)
+	assert.Equal(1, people.SchemaVersion)
+	assert.Contains(people.Checksum, `sha256:`)
+
+	var archive = buf.Bytes()
+
+	verified, err := backends.VerifyArchive(bytes.NewReader(archive))
+	assert.NoError(err)
+	assert.Len(verified.Collections, len(manifest.Collections))
+
+	// decoded schemata have their own (uncomparable) formatter and validator functions, so they're
+	// compared by their definitions rather than as a whole
+	for i, expected := range manifest.Collections {
+		actual := verified.Collections[i]
+		assert.Empty(expected.Schema.Diff(actual.Schema))
+
+		expected.Schema, actual.Schema = nil, nil
+		assert.Equal(expected, actual)
+	}
+
+	// collections are created from the archived schema
+	destination := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	_, err = backends.ImportArchive(destination, bytes.NewReader(archive), backends.ImportArchiveOptions{})
+	assert.Error(err)
+
+	_, err = backends.ImportArchive(destination, bytes.NewReader(archive), backends.ImportArchiveOptions{
+		CreateCollections: true,
+	})
+	assert.NoError(err)
+
+	record, err := destination.Retrieve(`people`, 2)
+	assert.NoError(err)
+	assert.Equal(`Bob`, record.Get(`name`))
+
+	record, err = destination.Retrieve(`places`, 1)
+	assert.NoError(err)
+	assert.Equal(`Lisbon`, record.Get(`city`))
+
+	// archives that have been tampered with are rejected before anything is written
+	tampered := rewriteArchive(t, archive, people.File, []byte(`not what was archived`))
+	empty := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	_, err = backends.ImportArchive(empty, bytes.NewReader(tampered), backends.ImportArchiveOptions{
+		CreateCollections: true,
+	})
+	assert.Error(err)
+	assert.Contains(err.Error(), `size mismatch`)
+
+	_, err = empty.GetCollection(`places`)
+	assert.True(dal.IsCollectionNotFoundErr(err))
+
+	// ...as are archives that are missing collections
+	_, err = backends.VerifyArchive(bytes.NewReader(rewriteArchive(t, archive, backends.ArchiveManifestName, []byte(`{"version": 1, "collections": [{"name": "things", "file": "things.ndjson.zst"}]}`))))
+	assert.Error(err)
+
+	// records exported from a newer schema can't be imported into an older one
+	upgraded := backends.NewUpgradingBackend(source)
+	upgraded.AddUpgrades(`people`, func(record *dal.Record) error {
+		return nil
+	})
+
+	buf.Reset()
+	_, err = backends.ExportArchive(upgraded, &buf, `people`)
+	assert.NoError(err)
+
+	_, err = backends.ImportArchive(destination, bytes.NewReader(buf.Bytes()), backends.ImportArchiveOptions{})
+	assert.Error(err)
+	assert.Contains(err.Error(), `schema version 2`)
+}
@@ -24,6 +24,7 @@ const (
 	PartialSearch BackendFeature = iota
 	CompositeKeys
 	Constraints
+	Transactions
 )
 
 type Backend interface {
@@ -59,11 +60,18 @@ var backendMap = map[string]BackendFunc{
 	`mongodb`:       NewMongoBackend,
 	`mongo`:         NewMongoBackend,
 	`mysql`:         NewSqlBackend,
+	`tidb`:          NewSqlBackend,
 	`postgres`:      NewSqlBackend,
 	`postgresql`:    NewSqlBackend,
 	`psql`:          NewSqlBackend,
+	`cockroach`:     NewSqlBackend,
+	`cockroachdb`:   NewSqlBackend,
+	`mssql`:         NewSqlBackend,
+	`sqlserver`:     NewSqlBackend,
 	`sqlite`:        NewSqlBackend,
 	`redis`:         NewRedisBackend,
+	`cassandra`:     NewCassandraBackend,
+	`scylla`:        NewCassandraBackend,
 	`elasticsearch`: NewElasticsearchBackend,
 	`es`:            NewElasticsearchBackend,
 }
@@ -112,3 +120,21 @@ func MakeBackend(connection dal.ConnectionString) (Backend, error) {
 		return nil, fmt.Errorf("Unknown backend type %q", backendName)
 	}
 }
+
+// Returns the given backend followed by each of the backends it wraps (as exposed by their
+// GetBackend methods), from the outermost to the innermost.
+func BackendChain(backend Backend) []Backend {
+	var chain = make([]Backend, 0)
+
+	for backend != nil {
+		chain = append(chain, backend)
+
+		if wrapper, ok := backend.(interface{ GetBackend() Backend }); ok && wrapper.GetBackend() != backend {
+			backend = wrapper.GetBackend()
+		} else {
+			break
+		}
+	}
+
+	return chain
+}
@@ -0,0 +1,25 @@
+package backends_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendChain(t *testing.T) {
+	assert := require.New(t)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	timeout := backends.NewTimeoutBackend(backend, time.Second, time.Second)
+	watched := backends.NewChangeWatchingBackend(timeout)
+
+	// the adapter returns itself as the backend it wraps, which ends the chain
+	assert.Equal([]backends.Backend{watched, timeout, backend}, backends.BackendChain(watched))
+	assert.Equal([]backends.Backend{backend}, backends.BackendChain(backend))
+	assert.Empty(backends.BackendChain(nil))
+}
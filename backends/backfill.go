@@ -0,0 +1,270 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// The default number of records read and updated per batch when backfilling a collection.
+var BackfillBatchSize = 1000
+
+var backfillFieldReference = regexp.MustCompile(`\{([^\{\}]+)\}`)
+
+// An expression used to compute the value of a field being backfilled.  Expressions are either
+// static values (e.g.: "active", "42", "true"), a reference to another field in the same record
+// (e.g.: "{name}"), or a string with one or more field references interpolated into it
+// (e.g.: "{first_name} {last_name}").
+type BackfillExpression string
+
+// Compute the value of the expression for the given record.
+func (self BackfillExpression) Evaluate(record *dal.Record) interface{} {
+	var expr = string(self)
+
+	// a lone field reference takes on the value (and type) of the referenced field
+	if match := backfillFieldReference.FindStringSubmatch(expr); match != nil && match[0] == expr {
+		return record.Get(match[1])
+	} else if backfillFieldReference.MatchString(expr) {
+		return backfillFieldReference.ReplaceAllStringFunc(expr, func(ref string) string {
+			return typeutil.String(record.Get(strings.TrimSuffix(strings.TrimPrefix(ref, `{`), `}`)))
+		})
+	}
+
+	return stringutil.Autotype(expr)
+}
+
+// Parse a "field=expression" assignment.
+func ParseBackfillAssignment(in string) (string, BackfillExpression, error) {
+	if parts := strings.SplitN(in, `=`, 2); len(parts) == 2 && strings.TrimSpace(parts[0]) != `` {
+		return strings.TrimSpace(parts[0]), BackfillExpression(parts[1]), nil
+	} else {
+		return ``, ``, fmt.Errorf("invalid assignment %q: expected field=expression", in)
+	}
+}
+
+// Parse a rate limit, given as a number of records per unit of time (e.g.: "100/s", "5000/m").  A
+// plain number is treated as records per second.
+func ParseBackfillRate(in string) (float64, error) {
+	var count = in
+	var per = time.Second
+
+	if in == `` {
+		return 0, nil
+	}
+
+	if parts := strings.SplitN(in, `/`, 2); len(parts) == 2 {
+		count = parts[0]
+
+		switch parts[1] {
+		case `s`, `sec`, `second`:
+			per = time.Second
+		case `m`, `min`, `minute`:
+			per = time.Minute
+		case `h`, `hr`, `hour`:
+			per = time.Hour
+		default:
+			return 0, fmt.Errorf("invalid rate %q: unit must be one of s, m, or h", in)
+		}
+	}
+
+	if n, err := stringutil.ConvertToFloat(count); err == nil && n >= 0 {
+		return n / per.Seconds(), nil
+	} else {
+		return 0, fmt.Errorf("invalid rate %q", in)
+	}
+}
+
+type BackfillOptions struct {
+	// The fields to populate, and the expressions used to compute their values.
+	Set map[string]BackfillExpression
+
+	// Only backfill records matching this filter.  Defaults to all records.
+	Filter *filter.Filter
+
+	// The number of records read and updated at a time.
+	BatchSize int
+
+	// The maximum number of records processed per second.  Zero is unlimited.
+	Rate float64
+
+	// If set, progress is saved to this file after every batch so that an interrupted backfill
+	// can pick up where it left off.  The file is removed once the backfill completes.
+	StateFile string
+
+	// Replace values that are already present.  By default, only empty fields are populated.
+	Overwrite bool
+
+	// Called after every batch.
+	Progress func(progress *BackfillProgress)
+}
+
+type BackfillProgress struct {
+	Collection string        `json:"collection"`
+	After      interface{}   `json:"after,omitempty"`
+	Scanned    int           `json:"scanned"`
+	Updated    int           `json:"updated"`
+	Elapsed    time.Duration `json:"elapsed"`
+}
+
+// Walks a collection in batches ordered by identity, populating fields in each record according to
+// the given options.  This is typically used to fill in fields that were added to a collection
+// after records were written to it.
+func Backfill(backend Backend, name string, options BackfillOptions) (*BackfillProgress, error) {
+	var progress = &BackfillProgress{
+		Collection: name,
+	}
+
+	if len(options.Set) == 0 {
+		return progress, fmt.Errorf("must specify at least one field to backfill")
+	}
+
+	if options.BatchSize <= 0 {
+		options.BatchSize = BackfillBatchSize
+	}
+
+	collection, err := backend.GetCollection(name)
+
+	if err != nil {
+		return progress, err
+	}
+
+	// pick up where a previous run left off
+	if options.StateFile != `` {
+		if data, err := ioutil.ReadFile(options.StateFile); err == nil {
+			if err := json.Unmarshal(data, progress); err != nil {
+				return progress, fmt.Errorf("invalid backfill state file %s: %v", options.StateFile, err)
+			} else if progress.Collection != name {
+				return progress, fmt.Errorf("backfill state file %s belongs to collection %q", options.StateFile, progress.Collection)
+			}
+		} else if !os.IsNotExist(err) {
+			return progress, err
+		}
+	}
+
+	var previouslyElapsed = progress.Elapsed
+	var started = time.Now()
+	var processed int
+
+	for {
+		var f filter.Filter
+
+		if options.Filter != nil {
+			f = filter.Copy(options.Filter)
+		} else {
+			f = filter.Copy(filter.All())
+		}
+
+		f.IdentityField = collection.GetIdentityFieldName()
+		f.Sort = []string{f.IdentityField}
+		f.Limit = options.BatchSize
+		f.Offset = 0
+		f.After = progress.After
+
+		search := backend.WithSearch(collection, &f)
+
+		if search == nil {
+			return progress, fmt.Errorf("collection %q is not enumerable", name)
+		}
+
+		recordset, err := search.Query(collection, &f)
+
+		if err != nil {
+			return progress, err
+		} else if len(recordset.Records) == 0 {
+			break
+		}
+
+		var updates = dal.NewRecordSet()
+		var lastID interface{}
+
+		for _, record := range recordset.Records {
+			var changed bool
+
+			for field, expr := range options.Set {
+				if options.Overwrite || typeutil.IsZero(record.Get(field)) {
+					value := expr.Evaluate(record)
+
+					if v, err := collection.ValueForField(field, value, dal.PersistOperation); err == nil {
+						value = v
+					}
+
+					record.Set(field, value)
+					changed = true
+				}
+			}
+
+			if changed {
+				updates.Push(record)
+			}
+
+			lastID = record.ID
+		}
+
+		// guard against looping forever over backends that don't support cursor pagination
+		if progress.After != nil && fmt.Sprintf("%v", lastID) == fmt.Sprintf("%v", progress.After) {
+			return progress, fmt.Errorf("collection %q does not support backfilling: results did not advance past %v", name, lastID)
+		}
+
+		if len(updates.Records) > 0 {
+			if err := backend.Update(name, updates); err != nil {
+				return progress, err
+			}
+		}
+
+		processed += len(recordset.Records)
+		progress.Scanned += len(recordset.Records)
+		progress.Updated += len(updates.Records)
+		progress.After = lastID
+		progress.Elapsed = previouslyElapsed + time.Since(started)
+
+		if err := progress.save(options.StateFile); err != nil {
+			return progress, err
+		}
+
+		if options.Progress != nil {
+			options.Progress(progress)
+		}
+
+		if len(recordset.Records) < options.BatchSize {
+			break
+		}
+
+		// throttle so that the overall throughput of this run stays under the rate limit
+		if options.Rate > 0 {
+			var due = time.Duration(float64(processed) / options.Rate * float64(time.Second))
+
+			if elapsed := time.Since(started); elapsed < due {
+				time.Sleep(due - elapsed)
+			}
+		}
+	}
+
+	if options.StateFile != `` {
+		if err := os.Remove(options.StateFile); err != nil && !os.IsNotExist(err) {
+			return progress, err
+		}
+	}
+
+	return progress, nil
+}
+
+func (self *BackfillProgress) save(filename string) error {
+	if filename == `` {
+		return nil
+	}
+
+	if data, err := json.Marshal(self); err == nil {
+		return ioutil.WriteFile(filename, data, 0644)
+	} else {
+		return err
+	}
+}
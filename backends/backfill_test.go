@@ -0,0 +1,126 @@
+package backends_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackfill(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-backfill-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`people`, dal.Field{
+		Name: `first`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `last`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `full_name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `status`,
+		Type: dal.StringType,
+	})))
+
+	assert.NoError(backend.Insert(`people`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`first`, `Ada`).Set(`last`, `Lovelace`),
+		dal.NewRecord(2).Set(`first`, `Alan`).Set(`last`, `Turing`).Set(`status`, `retired`),
+		dal.NewRecord(3).Set(`first`, `Grace`).Set(`last`, `Hopper`),
+		dal.NewRecord(4).Set(`first`, `Edsger`).Set(`last`, `Dijkstra`),
+		dal.NewRecord(5).Set(`first`, `Barbara`).Set(`last`, `Liskov`),
+	)))
+
+	_, fullName, err := backends.ParseBackfillAssignment(`full_name={first} {last}`)
+	assert.NoError(err)
+
+	_, status, err := backends.ParseBackfillAssignment(`status=active`)
+	assert.NoError(err)
+
+	var batches int
+	var stateFile = filepath.Join(dir, `state.json`)
+
+	progress, err := backends.Backfill(backend, `people`, backends.BackfillOptions{
+		Set: map[string]backends.BackfillExpression{
+			`full_name`: fullName,
+			`status`:    status,
+		},
+		Filter:    filter.MustParse(`last/not:Dijkstra`),
+		BatchSize: 2,
+		StateFile: stateFile,
+		Progress: func(p *backends.BackfillProgress) {
+			batches += 1
+		},
+	})
+
+	assert.NoError(err)
+	assert.Equal(4, progress.Scanned)
+	assert.Equal(4, progress.Updated)
+	assert.Equal(2, batches)
+
+	// the state file is removed once the backfill completes
+	_, err = os.Stat(stateFile)
+	assert.True(os.IsNotExist(err))
+
+	record, err := backend.Retrieve(`people`, 1)
+	assert.NoError(err)
+	assert.Equal(`Ada Lovelace`, record.Get(`full_name`))
+	assert.Equal(`active`, record.Get(`status`))
+
+	// existing values are left alone unless overwriting
+	record, err = backend.Retrieve(`people`, 2)
+	assert.NoError(err)
+	assert.Equal(`Alan Turing`, record.Get(`full_name`))
+	assert.Equal(`retired`, record.Get(`status`))
+
+	record, err = backend.Retrieve(`people`, 4)
+	assert.NoError(err)
+	assert.Nil(record.Get(`full_name`))
+
+	// resuming from saved state only visits records after the saved position
+	assert.NoError(ioutil.WriteFile(stateFile, []byte(`{"collection":"people","after":3,"scanned":3}`), 0644))
+
+	progress, err = backends.Backfill(backend, `people`, backends.BackfillOptions{
+		Set: map[string]backends.BackfillExpression{
+			`status`: `{last}`,
+		},
+		StateFile: stateFile,
+		Overwrite: true,
+	})
+
+	assert.NoError(err)
+	assert.Equal(5, progress.Scanned)
+	assert.Equal(2, progress.Updated)
+
+	record, err = backend.Retrieve(`people`, 3)
+	assert.NoError(err)
+	assert.Equal(`active`, record.Get(`status`))
+
+	record, err = backend.Retrieve(`people`, 5)
+	assert.NoError(err)
+	assert.Equal(`Liskov`, record.Get(`status`))
+
+	rate, err := backends.ParseBackfillRate(`120/m`)
+	assert.NoError(err)
+	assert.Equal(2.0, rate)
+
+	_, err = backends.ParseBackfillRate(`5/fortnight`)
+	assert.Error(err)
+
+	_, _, err = backends.ParseBackfillAssignment(`nope`)
+	assert.Error(err)
+}
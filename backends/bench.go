@@ -0,0 +1,381 @@
+package backends
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// The maximum number of existing records whose IDs are used as targets for benchmark reads and writes.
+var BenchSampleSize = 10000
+
+var rxBenchMix = regexp.MustCompile(`^(\d+)([rwq])$`)
+
+type BenchOperation string
+
+const (
+	BenchRead  BenchOperation = `read`
+	BenchWrite BenchOperation = `write`
+	BenchQuery BenchOperation = `query`
+)
+
+// The relative weights of each type of operation performed during a benchmark.
+type BenchMix struct {
+	Reads   int `json:"reads"`
+	Writes  int `json:"writes"`
+	Queries int `json:"queries"`
+}
+
+// Parse a mix specification of the form "70r/20w/10q".  Weights are relative, and do not need to
+// add up to 100.
+func ParseBenchMix(in string) (BenchMix, error) {
+	var mix BenchMix
+
+	for _, part := range strings.Split(in, `/`) {
+		if match := rxBenchMix.FindStringSubmatch(strings.TrimSpace(part)); match != nil {
+			weight, _ := strconv.Atoi(match[1])
+
+			switch match[2] {
+			case `r`:
+				mix.Reads = weight
+			case `w`:
+				mix.Writes = weight
+			case `q`:
+				mix.Queries = weight
+			}
+		} else {
+			return mix, fmt.Errorf("invalid mix component %q", part)
+		}
+	}
+
+	if mix.total() <= 0 {
+		return mix, fmt.Errorf("mix %q must specify at least one non-zero weight", in)
+	}
+
+	return mix, nil
+}
+
+func (self BenchMix) total() int {
+	return self.Reads + self.Writes + self.Queries
+}
+
+func (self BenchMix) pick(random *rand.Rand) BenchOperation {
+	n := random.Intn(self.total())
+
+	if n < self.Reads {
+		return BenchRead
+	} else if n < self.Reads+self.Writes {
+		return BenchWrite
+	} else {
+		return BenchQuery
+	}
+}
+
+func (self BenchMix) String() string {
+	return fmt.Sprintf("%dr/%dw/%dq", self.Reads, self.Writes, self.Queries)
+}
+
+type BenchOptions struct {
+	Collection  string
+	Mix         BenchMix
+	Concurrency int
+	Duration    time.Duration
+
+	// The query to perform for query operations.  If empty, queries will match a randomly-chosen
+	// field value from an existing record.
+	Query string
+
+	// Describes how values for written records are generated.
+	Template *SeedTemplate
+}
+
+// Latency and error statistics for a single type of operation.
+type BenchResult struct {
+	Operation  BenchOperation `json:"operation"`
+	Count      int            `json:"count"`
+	Errors     int            `json:"errors"`
+	ErrorRate  float64        `json:"error_rate"`
+	Throughput float64        `json:"ops_per_second"`
+	Min        time.Duration  `json:"min"`
+	Mean       time.Duration  `json:"mean"`
+	P50        time.Duration  `json:"p50"`
+	P90        time.Duration  `json:"p90"`
+	P99        time.Duration  `json:"p99"`
+	Max        time.Duration  `json:"max"`
+	LastError  string         `json:"last_error,omitempty"`
+	latencies  []time.Duration
+}
+
+func (self *BenchResult) String() string {
+	return fmt.Sprintf(
+		"%-6s n=%-8d err=%-6d (%5.2f%%) %8.1f/s  min=%v mean=%v p50=%v p90=%v p99=%v max=%v",
+		self.Operation,
+		self.Count,
+		self.Errors,
+		self.ErrorRate*100,
+		self.Throughput,
+		self.Min,
+		self.Mean,
+		self.P50,
+		self.P90,
+		self.P99,
+		self.Max,
+	)
+}
+
+func (self *BenchResult) finalize(elapsed time.Duration) {
+	if self.Count == 0 {
+		return
+	}
+
+	var total time.Duration
+
+	sort.Slice(self.latencies, func(i int, j int) bool {
+		return self.latencies[i] < self.latencies[j]
+	})
+
+	for _, latency := range self.latencies {
+		total += latency
+	}
+
+	self.ErrorRate = float64(self.Errors) / float64(self.Count)
+	self.Throughput = float64(self.Count) / elapsed.Seconds()
+	self.Min = self.latencies[0]
+	self.Max = self.latencies[len(self.latencies)-1]
+	self.Mean = total / time.Duration(len(self.latencies))
+	self.P50 = percentile(self.latencies, 0.50)
+	self.P90 = percentile(self.latencies, 0.90)
+	self.P99 = percentile(self.latencies, 0.99)
+}
+
+// The results of a benchmark run.
+type BenchReport struct {
+	Backend     string                          `json:"backend"`
+	Collection  string                          `json:"collection"`
+	Mix         string                          `json:"mix"`
+	Concurrency int                             `json:"concurrency"`
+	Elapsed     time.Duration                   `json:"elapsed"`
+	Results     map[BenchOperation]*BenchResult `json:"results"`
+}
+
+// Drives a mix of reads, writes, and queries against the given collection from multiple
+// concurrent workers for the configured duration, and reports on the latency and error rate of
+// each type of operation.  Reads and writes target existing records, so the collection must
+// contain data (see Seeder) before benchmarking.
+func Benchmark(backend Backend, options BenchOptions) (*BenchReport, error) {
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+
+	if options.Duration <= 0 {
+		return nil, fmt.Errorf("must specify a positive benchmark duration")
+	} else if options.Mix.total() <= 0 {
+		return nil, fmt.Errorf("must specify a read/write/query mix")
+	}
+
+	collection, err := backend.GetCollection(options.Collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Query != `` {
+		if _, err := filter.Parse(options.Query); err != nil {
+			return nil, fmt.Errorf("invalid query: %v", err)
+		}
+	}
+
+	samples, err := benchSample(backend, collection)
+
+	if err != nil {
+		return nil, err
+	} else if len(samples) == 0 && (options.Mix.Reads+options.Mix.Writes > 0 || options.Query == ``) {
+		return nil, fmt.Errorf("collection %q contains no records to benchmark against", collection.Name)
+	}
+
+	// related records are only sampled once, rather than by every worker (and during the benchmark)
+	seeder := NewSeeder(backend, collection, options.Template)
+
+	if err := seeder.loadForeignKeys(); err != nil {
+		return nil, err
+	}
+
+	report := &BenchReport{
+		Backend:     backend.String(),
+		Collection:  collection.Name,
+		Mix:         options.Mix.String(),
+		Concurrency: options.Concurrency,
+		Results: map[BenchOperation]*BenchResult{
+			BenchRead:  {Operation: BenchRead},
+			BenchWrite: {Operation: BenchWrite},
+			BenchQuery: {Operation: BenchQuery},
+		},
+	}
+
+	var resultLock sync.Mutex
+	var wg sync.WaitGroup
+
+	deadline := time.Now().Add(options.Duration)
+	started := time.Now()
+
+	for w := 0; w < options.Concurrency; w++ {
+		wg.Add(1)
+
+		go func(worker int) {
+			defer wg.Done()
+
+			var query *filter.Filter
+
+			random := rand.New(rand.NewSource(time.Now().UnixNano() + int64(worker)))
+			seeder := seeder.fork(random.Int63())
+
+			// backends may modify the filters they are given, so each worker parses its own copy
+			if options.Query != `` {
+				query, _ = filter.Parse(options.Query)
+			}
+
+			for time.Now().Before(deadline) {
+				op := options.Mix.pick(random)
+				opStart := time.Now()
+				err := benchOperation(backend, collection, seeder, random, op, samples, query)
+				latency := time.Since(opStart)
+
+				resultLock.Lock()
+				result := report.Results[op]
+				result.Count += 1
+				result.latencies = append(result.latencies, latency)
+
+				if err != nil {
+					result.Errors += 1
+					result.LastError = err.Error()
+				}
+
+				resultLock.Unlock()
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	report.Elapsed = time.Since(started)
+
+	for _, result := range report.Results {
+		result.finalize(report.Elapsed)
+	}
+
+	return report, nil
+}
+
+func benchOperation(
+	backend Backend,
+	collection *dal.Collection,
+	seeder *Seeder,
+	random *rand.Rand,
+	op BenchOperation,
+	samples []*dal.Record,
+	query *filter.Filter,
+) error {
+	switch op {
+	case BenchRead:
+		_, err := backend.Retrieve(collection.Name, samples[random.Intn(len(samples))].ID)
+		return err
+
+	case BenchWrite:
+		if record, err := seeder.Generate(random.Int()); err == nil {
+			// overwrite an existing record so that the size of the collection remains stable
+			record.ID = samples[random.Intn(len(samples))].ID
+
+			return backend.Update(collection.Name, dal.NewRecordSet(record))
+		} else {
+			return err
+		}
+
+	case BenchQuery:
+		f := query
+
+		if f == nil {
+			if generated, err := benchQueryFor(collection, samples[random.Intn(len(samples))], random); err == nil {
+				f = generated
+			} else {
+				return err
+			}
+		}
+
+		if search := backend.WithSearch(collection, f); search != nil {
+			_, err := search.Query(collection, f)
+			return err
+		} else {
+			return fmt.Errorf("backend %v does not support querying", backend)
+		}
+	}
+
+	return nil
+}
+
+// build a query that matches one of the field values of the given record
+func benchQueryFor(collection *dal.Collection, record *dal.Record, random *rand.Rand) (*filter.Filter, error) {
+	candidates := make([]filter.Criterion, 0)
+
+	for _, field := range collection.Fields {
+		if field.Identity {
+			continue
+		}
+
+		switch field.Type {
+		case dal.StringType, dal.IntType, dal.BooleanType:
+			if value := record.Get(field.Name); !typeutil.IsZero(value) {
+				candidates = append(candidates, filter.Criterion{
+					Field:  field.Name,
+					Values: []interface{}{value},
+				})
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no queryable fields found in record %v", record.ID)
+	}
+
+	return filter.New().AddCriteria(candidates[random.Intn(len(candidates))]), nil
+}
+
+func benchSample(backend Backend, collection *dal.Collection) ([]*dal.Record, error) {
+	samples := make([]*dal.Record, 0)
+
+	if search := backend.WithSearch(collection); search != nil {
+		f := filter.All()
+		f.Limit = BenchSampleSize
+
+		if err := search.QueryFunc(collection, f, func(record *dal.Record, err error, _ IndexPage) error {
+			if err == nil {
+				samples = append(samples, record)
+			}
+
+			return err
+		}); err != nil {
+			return nil, err
+		}
+
+		return samples, nil
+	} else {
+		return nil, fmt.Errorf("backend %v does not support enumerating records", backend)
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(float64(len(sorted)-1) * p)
+
+	return sorted[i]
+}
@@ -0,0 +1,134 @@
+package backends_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBenchMix(t *testing.T) {
+	assert := require.New(t)
+
+	mix, err := backends.ParseBenchMix(`70r/20w/10q`)
+	assert.NoError(err)
+	assert.Equal(backends.BenchMix{Reads: 70, Writes: 20, Queries: 10}, mix)
+
+	mix, err = backends.ParseBenchMix(`1r`)
+	assert.NoError(err)
+	assert.Equal(backends.BenchMix{Reads: 1}, mix)
+
+	_, err = backends.ParseBenchMix(`70x/30w`)
+	assert.Error(err)
+
+	_, err = backends.ParseBenchMix(`0r/0w`)
+	assert.Error(err)
+}
+
+func TestBenchmark(t *testing.T) {
+	assert := require.New(t)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	collection := dal.NewCollection(`bench`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	assert.NoError(backend.CreateCollection(collection))
+	assert.NoError(backend.Insert(`bench`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `one`),
+		dal.NewRecord(2).Set(`name`, `two`),
+		dal.NewRecord(3).Set(`name`, `three`),
+	)))
+
+	report, err := backends.Benchmark(backend, backends.BenchOptions{
+		Collection:  `bench`,
+		Mix:         backends.BenchMix{Reads: 7, Writes: 2, Queries: 1},
+		Concurrency: 4,
+		Duration:    100 * time.Millisecond,
+	})
+
+	assert.NoError(err)
+	assert.Equal(`bench`, report.Collection)
+
+	for _, op := range []backends.BenchOperation{backends.BenchRead, backends.BenchWrite, backends.BenchQuery} {
+		result := report.Results[op]
+		assert.NotZero(result.Count, "no %s operations performed", op)
+		assert.Zero(result.Errors, result.LastError)
+		assert.True(result.P50 <= result.P99)
+	}
+
+	_, err = backends.Benchmark(backend, backends.BenchOptions{
+		Collection: `bench`,
+		Mix:        backends.BenchMix{Reads: 1},
+	})
+
+	assert.Error(err)
+}
+
+func TestBenchmarkRelatedRecords(t *testing.T) {
+	assert := require.New(t)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	groups := dal.NewCollection(`groups`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	bench := dal.NewCollection(`bench`, dal.Field{
+		Name: `path`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name:      `group_id`,
+		Type:      dal.IntType,
+		BelongsTo: `groups`,
+	})
+
+	assert.NoError(backend.CreateCollection(groups))
+	assert.NoError(backend.CreateCollection(bench))
+	assert.NoError(backend.Insert(`groups`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `one`),
+		dal.NewRecord(2).Set(`name`, `two`),
+	)))
+
+	// values containing filter syntax are still queried for as-is
+	assert.NoError(backend.Insert(`bench`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`path`, `/usr/local`).Set(`group_id`, 1),
+		dal.NewRecord(2).Set(`path`, `a/b/c`).Set(`group_id`, 2),
+	)))
+
+	report, err := backends.Benchmark(backend, backends.BenchOptions{
+		Collection:  `bench`,
+		Mix:         backends.BenchMix{Queries: 1},
+		Concurrency: 4,
+		Duration:    50 * time.Millisecond,
+	})
+
+	assert.NoError(err)
+	assert.NotZero(report.Results[backends.BenchQuery].Count)
+	assert.Zero(report.Results[backends.BenchQuery].Errors, report.Results[backends.BenchQuery].LastError)
+
+	report, err = backends.Benchmark(backend, backends.BenchOptions{
+		Collection:  `bench`,
+		Mix:         backends.BenchMix{Writes: 1},
+		Concurrency: 4,
+		Duration:    50 * time.Millisecond,
+	})
+
+	assert.NoError(err)
+	assert.NotZero(report.Results[backends.BenchWrite].Count)
+	assert.Zero(report.Results[backends.BenchWrite].Errors, report.Results[backends.BenchWrite].LastError)
+
+	// written records only ever reference related records that exist
+	assert.NoError(backend.WithSearch(bench).QueryFunc(bench, filter.All(), func(record *dal.Record, err error, _ backends.IndexPage) error {
+		assert.NoError(err)
+		assert.True(backend.Exists(`groups`, record.Get(`group_id`)), "group %v does not exist", record.Get(`group_id`))
+		return nil
+	}))
+}
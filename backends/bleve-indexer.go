@@ -10,6 +10,7 @@ import (
 
 	"github.com/blevesearch/bleve"
 	"github.com/blevesearch/bleve/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/analysis/analyzer/standard"
 	"github.com/blevesearch/bleve/analysis/char/regexp"
 	"github.com/blevesearch/bleve/analysis/token/lowercase"
 	"github.com/blevesearch/bleve/analysis/tokenizer/single"
@@ -18,6 +19,7 @@ import (
 	"github.com/ghetzel/go-stockutil/log"
 	"github.com/ghetzel/go-stockutil/sliceutil"
 	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
 	"github.com/ghetzel/pivot/v3/dal"
 	"github.com/ghetzel/pivot/v3/filter"
 	"github.com/orcaman/concurrent-map"
@@ -179,7 +181,7 @@ func (self *BleveIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter
 	}
 
 	if index, err := self.getIndexForCollection(collection); err == nil {
-		if bq, err := self.filterToBleveQuery(index, f); err == nil {
+		if bq, err := self.filterToBleveQuery(collection, index, f); err == nil {
 			limit := f.Limit
 
 			if limit == 0 || limit > IndexerPageSize {
@@ -221,7 +223,10 @@ func (self *BleveIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter
 
 					// call the resultFn for each hit on this page
 					for _, hit := range results.Hits {
-						if err := resultFn(dal.NewRecord(hit.ID).SetFields(hit.Fields), nil, IndexPage{
+						var record = dal.NewRecord(hit.ID).SetFields(hit.Fields)
+						record.Score = hit.Score
+
+						if err := resultFn(record, nil, IndexPage{
 							Page:         page,
 							TotalPages:   totalPages,
 							Limit:        f.Limit,
@@ -287,7 +292,7 @@ func (self *BleveIndexer) IndexRemove(collection *dal.Collection, ids []interfac
 func (self *BleveIndexer) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
 	if index, err := self.getIndexForCollection(collection); err == nil {
 
-		if bq, err := self.filterToBleveQuery(index, f); err == nil {
+		if bq, err := self.filterToBleveQuery(collection, index, f); err == nil {
 			request := bleve.NewSearchRequestOptions(bq, 0, 0, false)
 			request.Fields = []string{}
 			idQuery := false
@@ -376,6 +381,7 @@ func (self *BleveIndexer) getIndexForCollection(collection *dal.Collection) (ble
 
 		// setup the mapping and text analysis settings for this index
 		self.useFilterMapping(mapping)
+		self.useSearchMapping(mapping, collection)
 
 		switch self.conn.Dataset() {
 		case `memory`:
@@ -402,11 +408,13 @@ func (self *BleveIndexer) getIndexForCollection(collection *dal.Collection) (ble
 	}
 }
 
-func (self *BleveIndexer) filterToBleveQuery(index bleve.Index, f *filter.Filter) (query.Query, error) {
+func (self *BleveIndexer) filterToBleveQuery(collection *dal.Collection, index bleve.Index, f *filter.Filter) (query.Query, error) {
 	defer stats.NewTiming().Send(`pivot.indexers.bleve.filter_to_native`)
 
 	if f.MatchAll {
 		return bleve.NewMatchAllQuery(), nil
+	} else if len(f.Groups) > 0 {
+		return nil, fmt.Errorf("Grouped criteria are not supported by the bleve indexer")
 	} else {
 		mapping := index.Mapping()
 		conjunction := bleve.NewConjunctionQuery()
@@ -421,6 +429,11 @@ func (self *BleveIndexer) filterToBleveQuery(index bleve.Index, f *filter.Filter
 				}
 			}
 
+			if criterion.Operator == `fulltext` {
+				conjunction.AddQuery(bleveFulltextQuery(collection, criterion))
+				continue
+			}
+
 			var skipNext bool
 			var disjunction *query.DisjunctionQuery
 
@@ -613,3 +626,61 @@ func (self *BleveIndexer) useFilterMapping(mappingImpl *mapping.IndexMappingImpl
 
 	mappingImpl.DefaultAnalyzer = `pivot_filter`
 }
+
+// adds an analyzed copy of each of the collection's searchable string fields to the mapping, which
+// full-text queries are matched against.  The fields themselves are indexed as they otherwise
+// would be, so that exact-match queries continue to work.
+func (self *BleveIndexer) useSearchMapping(mappingImpl *mapping.IndexMappingImpl, collection *dal.Collection) {
+	for _, sf := range collection.SearchFields {
+		if path := sf.GetSearchPath(collection); path != sf.Name {
+			var analyzed = bleve.NewTextFieldMapping()
+			analyzed.Name = path
+			analyzed.Analyzer = standard.Name
+
+			mappingImpl.DefaultMapping.AddFieldMappingsAt(sf.Name, bleve.NewTextFieldMapping(), analyzed)
+		}
+	}
+}
+
+// builds a query that matches any of the criterion's values against the given field, or against
+// all of the collection's search fields if the field is filter.SearchAllField.  Matches are
+// boosted according to the collection's search field declarations.
+func bleveFulltextQuery(collection *dal.Collection, criterion filter.Criterion) query.Query {
+	var fields []dal.SearchField
+	var disjunction = bleve.NewDisjunctionQuery()
+
+	if criterion.Field == filter.SearchAllField {
+		fields = collection.SearchFields
+	} else if sf, ok := collection.GetSearchField(criterion.Field); ok {
+		fields = []dal.SearchField{sf}
+	}
+
+	for _, value := range criterion.Values {
+		if len(fields) == 0 {
+			var q = bleve.NewMatchQuery(typeutil.String(value))
+
+			// searching everything uses the composite field containing all values
+			if criterion.Field != filter.SearchAllField {
+				q.SetField(criterion.Field)
+			}
+
+			disjunction.AddQuery(q)
+			continue
+		}
+
+		for _, sf := range fields {
+			var q = bleve.NewMatchQuery(typeutil.String(value))
+			var path = sf.GetSearchPath(collection)
+
+			if path != sf.Name {
+				q.Analyzer = standard.Name
+			}
+
+			q.SetField(path)
+			q.SetBoost(sf.GetBoost())
+			disjunction.AddQuery(q)
+		}
+	}
+
+	return disjunction
+}
@@ -45,6 +45,13 @@ func (self *CachingBackend) Retrieve(collection string, id interface{}, fields .
 	}
 }
 
+// Runs fn in a transaction on the underlying backend.  The cache is reset afterwards since records
+// may have been written (or rolled back) without passing through it.
+func (self *CachingBackend) Transaction(fn func(tx Backend) error) error {
+	defer self.ResetCache()
+	return Transaction(self.backend, fn)
+}
+
 // passthrough the remaining functions to fulfill the Backend interface
 // -------------------------------------------------------------------------------------------------
 func (self *CachingBackend) Exists(collection string, id interface{}) bool {
@@ -0,0 +1,337 @@
+package backends
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// describes how a filter is split between the database and Pivot: the WHERE clause contains the
+// criteria that CQL can evaluate, and everything else remains in the residual filter.
+type cassandraQueryPlan struct {
+	Where          string
+	Values         []interface{}
+	AllowFiltering bool
+	Residual       *filter.Filter
+}
+
+// Returns whether the database will evaluate every criterion in the filter.
+func (self *cassandraQueryPlan) IsComplete() bool {
+	return self.Residual == nil || (len(self.Residual.Criteria) == 0 && len(self.Residual.Groups) == 0)
+}
+
+func (self *CassandraBackend) IndexConnectionString() *dal.ConnectionString {
+	return &self.cs
+}
+
+func (self *CassandraBackend) IndexInitialize(Backend) error {
+	return nil
+}
+
+func (self *CassandraBackend) GetBackend() Backend {
+	return self
+}
+
+func (self *CassandraBackend) IndexExists(collection *dal.Collection, id interface{}) bool {
+	return self.Exists(collection.Name, id)
+}
+
+func (self *CassandraBackend) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	return self.Retrieve(collection.Name, id)
+}
+
+func (self *CassandraBackend) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return nil
+}
+
+func (self *CassandraBackend) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return nil
+}
+
+// Queries push down as many criteria as CQL allows: exact matches on the partition key, clustering
+// columns in primary key order, and (if the allowFiltering option is set) single-value comparisons
+// on other columns.  All other criteria are evaluated against each row as it is read.
+func (self *CassandraBackend) QueryFunc(collection *dal.Collection, flt *filter.Filter, resultFn IndexResultFunc) error {
+	if flt == nil {
+		flt = filter.All()
+	}
+
+	querylog.Debugf("[%v] Query using filter %q", self, flt.String())
+
+	var plan = cassandraPlanQuery(collection, flt, self.allowFiltering)
+	var stmt = fmt.Sprintf("SELECT * FROM %s", cassandraTableName(self.keyspace, collection.Name))
+
+	if plan.Where != `` {
+		stmt += ` WHERE ` + plan.Where
+	}
+
+	// the limit can only be applied by the database if it has seen every criterion
+	if plan.IsComplete() && flt.Limit > 0 {
+		stmt += fmt.Sprintf(" LIMIT %d", flt.Offset+flt.Limit)
+	}
+
+	if plan.AllowFiltering {
+		stmt += ` ALLOW FILTERING`
+	}
+
+	querylog.Debugf("[%v] %s %v", self, stmt, plan.Values)
+
+	var iter = self.session.Query(stmt, plan.Values...).PageSize(self.pageSize).Iter()
+	var processed int
+	var page = IndexPage{
+		Page:         1,
+		TotalPages:   1,
+		Limit:        flt.Limit,
+		Offset:       flt.Offset,
+		TotalResults: -1,
+	}
+
+	for {
+		var row = make(map[string]interface{})
+
+		if !iter.MapScan(row) {
+			break
+		}
+
+		record, err := cassandraRecordFromRow(collection, row)
+
+		if err == nil {
+			if !plan.IsComplete() && !plan.Residual.MatchesRecord(record) {
+				continue
+			} else if len(flt.Fields) > 0 {
+				record = record.OnlyFields(flt.Fields)
+			}
+		}
+
+		processed += 1
+
+		if processed <= flt.Offset {
+			continue
+		}
+
+		if err := resultFn(record, err, page); err != nil {
+			iter.Close()
+			return err
+		}
+
+		if flt.Limit > 0 && processed >= (flt.Offset+flt.Limit) {
+			break
+		}
+	}
+
+	return iter.Close()
+}
+
+func (self *CassandraBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if f != nil {
+		f.Options[`ForceIndexRecord`] = true
+	}
+
+	return DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+func (self *CassandraBackend) ListValues(collection *dal.Collection, fields []string, flt *filter.Filter) (map[string][]interface{}, error) {
+	var values = make(map[string][]interface{})
+
+	if err := self.QueryFunc(collection, flt, func(record *dal.Record, err error, _ IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			var value interface{}
+
+			if collection.IsIdentityField(field) {
+				value = record.ID
+			} else {
+				value = record.Get(field)
+			}
+
+			if value != nil {
+				values[field] = sliceutil.Unique(append(values[field], value))
+			}
+		}
+
+		return nil
+	}); err == nil {
+		return values, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *CassandraBackend) DeleteQuery(collection *dal.Collection, flt *filter.Filter) error {
+	var ids = make([]interface{}, 0)
+
+	if err := self.QueryFunc(collection, flt, func(record *dal.Record, err error, _ IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		ids = append(ids, record.Keys(collection))
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if len(ids) > 0 {
+		return self.Delete(collection.Name, ids...)
+	}
+
+	return nil
+}
+
+func (self *CassandraBackend) FlushIndex() error {
+	return nil
+}
+
+// splits the given filter into a CQL WHERE clause and a residual filter containing the criteria that
+// CQL can't (or, without ALLOW FILTERING, won't) evaluate.
+func cassandraPlanQuery(collection *dal.Collection, flt *filter.Filter, allowFiltering bool) *cassandraQueryPlan {
+	var plan = new(cassandraQueryPlan)
+	var residual = filter.Copy(flt)
+	var clauses = make([]string, 0)
+	var pushed = make(map[int]bool)
+
+	plan.Residual = &residual
+
+	// criteria in OR queries can't be expressed in CQL at all
+	if flt.Conjunction == filter.OrConjunction && len(flt.Criteria)+len(flt.Groups) > 1 {
+		return plan
+	}
+
+	var push = func(i int, clause string, values ...interface{}) {
+		pushed[i] = true
+		clauses = append(clauses, clause)
+		plan.Values = append(plan.Values, values...)
+	}
+
+	// returns the first pushable criterion on the given field, and the index it appears at
+	var find = func(field dal.Field, ranges bool) (int, *filter.Criterion) {
+		for i, criterion := range flt.Criteria {
+			if pushed[i] || !cassandraCanPush(criterion) {
+				continue
+			}
+
+			if criterion.Field == field.Name || (field.Identity && criterion.Field == flt.IdentityField) {
+				if criterion.IsExactMatch() || (ranges && len(criterion.Values) == 1) {
+					return i, &flt.Criteria[i]
+				}
+			}
+		}
+
+		return -1, nil
+	}
+
+	var keys = collection.KeyFields()
+	var partitioned bool
+
+	// the partition key may be matched against one or more values
+	if i, criterion := find(keys[0], false); criterion != nil {
+		var values = make([]interface{}, len(criterion.Values))
+
+		for j, value := range criterion.Values {
+			values[j] = collection.ConvertValue(keys[0].Name, value)
+		}
+
+		if len(values) == 1 {
+			push(i, cassandraQuote(keys[0].Name)+` = ?`, values...)
+		} else {
+			push(i, cassandraQuote(keys[0].Name)+` IN (`+strings.TrimSuffix(strings.Repeat(`?, `, len(values)), `, `)+`)`, values...)
+		}
+
+		partitioned = true
+	}
+
+	// clustering columns must be restricted in order, and nothing after a range can be restricted
+	if partitioned || allowFiltering {
+	ClusteringLoop:
+		for _, key := range keys[1:] {
+			if i, criterion := find(key, true); criterion != nil && len(criterion.Values) == 1 {
+				var value = collection.ConvertValue(key.Name, criterion.Values[0])
+
+				push(i, cassandraQuote(key.Name)+` `+cassandraOperator(criterion.Operator)+` ?`, value)
+
+				if !partitioned {
+					plan.AllowFiltering = true
+				}
+
+				if !criterion.IsExactMatch() {
+					break ClusteringLoop
+				}
+			} else {
+				break ClusteringLoop
+			}
+		}
+	}
+
+	// everything else can only be evaluated by the database if ALLOW FILTERING is permitted
+	if allowFiltering {
+		for _, field := range collection.Fields {
+			if field.Key || field.Identity {
+				continue
+			}
+
+			switch field.Type {
+			case dal.ObjectType, dal.ArrayType, dal.RawType:
+				continue
+			}
+
+			if i, criterion := find(field, true); criterion != nil && len(criterion.Values) == 1 {
+				push(i, cassandraQuote(field.Name)+` `+cassandraOperator(criterion.Operator)+` ?`, collection.ConvertValue(field.Name, criterion.Values[0]))
+				plan.AllowFiltering = true
+			}
+		}
+	}
+
+	residual.Criteria = nil
+
+	for i, criterion := range flt.Criteria {
+		if !pushed[i] {
+			residual.Criteria = append(residual.Criteria, criterion)
+		}
+	}
+
+	plan.Where = strings.Join(clauses, ` AND `)
+	return plan
+}
+
+// returns whether the given criterion could be expressed as a CQL relation
+func cassandraCanPush(criterion filter.Criterion) bool {
+	if len(criterion.Values) == 0 || cassandraOperator(criterion.Operator) == `` {
+		return false
+	}
+
+	// comparisons against null can't be expressed in CQL
+	for _, value := range criterion.Values {
+		switch typeutil.String(value) {
+		case ``, `null`:
+			return false
+		}
+	}
+
+	return true
+}
+
+// returns the CQL operator equivalent to the given filter operator, or an empty string if there
+// isn't one
+func cassandraOperator(operator string) string {
+	switch operator {
+	case `is`, ``:
+		return `=`
+	case `gt`:
+		return `>`
+	case `gte`:
+		return `>=`
+	case `lt`:
+		return `<`
+	case `lte`:
+		return `<=`
+	default:
+		return ``
+	}
+}
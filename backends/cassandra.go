@@ -0,0 +1,676 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/gocql/gocql"
+)
+
+var CassandraDefaultHost = `localhost`
+var CassandraDefaultConsistency = `quorum`
+var CassandraDefaultTimeout = 10 * time.Second
+
+// The number of rows retrieved per page when querying Cassandra.
+var CassandraPageSize = 1000
+
+func init() {
+	dal.AddConnectionSchemeAlias(`scylla`, `cassandra`)
+	dal.AddConnectionSchemeAlias(`scylladb`, `cassandra`)
+
+	dal.RegisterConnectionScheme(dal.ConnectionScheme{
+		Name: `cassandra`,
+		Options: []dal.ConnectionOption{
+			{
+				Name:        `consistency`,
+				Default:     CassandraDefaultConsistency,
+				Description: `the consistency level that queries are performed at (e.g.: "one", "quorum", "local_quorum")`,
+				Validate: func(value interface{}) error {
+					_, err := gocql.ParseConsistencyWrapper(typeutil.String(value))
+					return err
+				},
+			}, {
+				Name:        `timeout`,
+				Description: `how long to wait for connections and queries to complete (e.g.: "10s")`,
+			}, {
+				Name:        `allowFiltering`,
+				Type:        dal.BooleanType,
+				Description: `allow criteria on non-key columns to be evaluated by the database using ALLOW FILTERING, rather than by Pivot`,
+			}, {
+				Name:        `pageSize`,
+				Type:        dal.IntType,
+				Description: `the number of rows retrieved per page when querying`,
+			}, {
+				Name:        `autoregister`,
+				Type:        dal.BooleanType,
+				Description: `register all tables in the keyspace as collections on startup`,
+			},
+		},
+	})
+}
+
+// The CassandraBackend stores records in Cassandra (or ScyllaDB) tables, one per collection.  The
+// collection's identity field is used as the partition key, and any other key fields become
+// clustering columns (in the order they are defined).  Object and array fields are stored as
+// JSON-encoded text.
+type CassandraBackend struct {
+	Backend
+	cs                    dal.ConnectionString
+	session               *gocql.Session
+	keyspace              string
+	registeredCollections sync.Map
+	indexer               Indexer
+	allowFiltering        bool
+	pageSize              int
+}
+
+func NewCassandraBackend(connection dal.ConnectionString) Backend {
+	backend := &CassandraBackend{
+		cs:       connection,
+		pageSize: CassandraPageSize,
+	}
+
+	backend.indexer = backend
+	return backend
+}
+
+func (self *CassandraBackend) Supports(features ...BackendFeature) bool {
+	for _, feat := range features {
+		switch feat {
+		case CompositeKeys:
+			continue
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func (self *CassandraBackend) String() string {
+	return `cassandra`
+}
+
+func (self *CassandraBackend) GetConnectionString() *dal.ConnectionString {
+	return &self.cs
+}
+
+func (self *CassandraBackend) Initialize() error {
+	if self.keyspace = self.cs.Dataset(); self.keyspace == `` {
+		return fmt.Errorf("%v: must specify a keyspace (e.g.: cassandra://localhost/mykeyspace)", self)
+	}
+
+	var cluster = gocql.NewCluster(strings.Split(self.cs.Host(CassandraDefaultHost), `,`)...)
+
+	cluster.Keyspace = self.keyspace
+	cluster.Timeout = self.cs.OptDuration(`timeout`, CassandraDefaultTimeout)
+	cluster.ConnectTimeout = cluster.Timeout
+
+	if consistency, err := gocql.ParseConsistencyWrapper(self.cs.OptString(`consistency`, CassandraDefaultConsistency)); err == nil {
+		cluster.Consistency = consistency
+	} else {
+		return err
+	}
+
+	if u, p, ok := self.cs.Credentials(); ok {
+		cluster.Authenticator = gocql.PasswordAuthenticator{
+			Username: u,
+			Password: p,
+		}
+	}
+
+	self.allowFiltering = self.cs.OptBool(`allowFiltering`, false)
+	self.pageSize = int(self.cs.OptInt(`pageSize`, int64(CassandraPageSize)))
+
+	if session, err := cluster.CreateSession(); err == nil {
+		self.session = session
+	} else {
+		return err
+	}
+
+	if self.cs.OptBool(`autoregister`, DefaultAutoregister) {
+		if names, err := self.ListCollections(); err == nil {
+			for _, name := range names {
+				if _, err := self.GetCollection(name); err != nil {
+					return err
+				}
+			}
+		} else {
+			return err
+		}
+	}
+
+	if self.indexer == nil {
+		self.indexer = self
+	}
+
+	if err := self.indexer.IndexInitialize(self); err != nil {
+		return err
+	}
+
+	return self.Ping(cluster.Timeout)
+}
+
+func (self *CassandraBackend) SetIndexer(indexConnString dal.ConnectionString) error {
+	if indexer, err := MakeIndexer(indexConnString); err == nil {
+		self.indexer = indexer
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *CassandraBackend) RegisterCollection(collection *dal.Collection) {
+	if collection != nil {
+		log.Debugf("[%v] register collection %q", self, collection.Name)
+		self.registeredCollections.Store(collection.Name, collection)
+	}
+}
+
+func (self *CassandraBackend) Ping(timeout time.Duration) error {
+	if self.session == nil {
+		return fmt.Errorf("Backend not initialized")
+	}
+
+	errchan := make(chan error)
+
+	go func() {
+		if err := self.session.Query(`SELECT now() FROM system.local`).Exec(); err == nil {
+			errchan <- nil
+		} else {
+			errchan <- fmt.Errorf("Backend unavailable: %v", err)
+		}
+	}()
+
+	select {
+	case err := <-errchan:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("Backend unavailable: timed out after waiting %v", timeout)
+	}
+}
+
+func (self *CassandraBackend) Exists(name string, id interface{}) bool {
+	if record, ok := id.(*dal.Record); ok {
+		id = record.ID
+	}
+
+	if _, err := self.Retrieve(name, id); err == nil {
+		return true
+	}
+
+	return false
+}
+
+func (self *CassandraBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	if collection, err := self.GetCollection(name); err == nil {
+		if where, values, err := cassandraKeyClause(collection, sliceutil.Sliceify(id)); err == nil {
+			var row = make(map[string]interface{})
+			var stmt = fmt.Sprintf(
+				"SELECT * FROM %s WHERE %s",
+				cassandraTableName(self.keyspace, collection.Name),
+				where,
+			)
+
+			querylog.Debugf("[%v] %s %v", self, stmt, values)
+
+			if err := self.session.Query(stmt, values...).MapScan(row); err == nil {
+				if record, err := cassandraRecordFromRow(collection, row); err == nil {
+					return record.OnlyFields(fields), nil
+				} else {
+					return nil, err
+				}
+			} else if err == gocql.ErrNotFound {
+				return nil, fmt.Errorf("Record %v does not exist", id)
+			} else {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *CassandraBackend) Insert(name string, recordset *dal.RecordSet) error {
+	return self.upsert(true, name, recordset)
+}
+
+func (self *CassandraBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	return self.upsert(false, name, recordset)
+}
+
+func (self *CassandraBackend) Delete(name string, ids ...interface{}) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		var merr error
+
+		for _, id := range ids {
+			if where, values, err := cassandraKeyClause(collection, sliceutil.Sliceify(id)); err == nil {
+				var stmt = fmt.Sprintf(
+					"DELETE FROM %s WHERE %s",
+					cassandraTableName(self.keyspace, collection.Name),
+					where,
+				)
+
+				querylog.Debugf("[%v] %s %v", self, stmt, values)
+
+				if err := self.session.Query(stmt, values...).Exec(); err != nil {
+					merr = log.AppendError(merr, err)
+				}
+			} else {
+				return err
+			}
+		}
+
+		// remove the records from the external index (if any)
+		if search := self.WithSearch(collection); search != nil && search != Indexer(self) {
+			if err := search.IndexRemove(collection, ids); err != nil {
+				merr = log.AppendError(merr, err)
+			}
+		}
+
+		return merr
+	} else {
+		return err
+	}
+}
+
+func (self *CassandraBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.indexer
+}
+
+func (self *CassandraBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	return nil
+}
+
+func (self *CassandraBackend) ListCollections() ([]string, error) {
+	var names = make([]string, 0)
+	var name string
+	var iter = self.session.Query(
+		`SELECT table_name FROM system_schema.tables WHERE keyspace_name = ?`,
+		self.keyspace,
+	).Iter()
+
+	for iter.Scan(&name) {
+		names = append(names, name)
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	// include collections that were registered but don't exist yet
+	for _, registered := range maputil.StringKeys(&self.registeredCollections) {
+		if !sliceutil.ContainsString(names, registered) {
+			names = append(names, registered)
+		}
+	}
+
+	return names, nil
+}
+
+func (self *CassandraBackend) CreateCollection(definition *dal.Collection) error {
+	querylog.Debugf("[%v] Create collection %v", self, definition.Name)
+
+	if definition.View {
+		return fmt.Errorf("View-type collections are not supported on this backend.")
+	}
+
+	var stmt = cassandraCreateTableStatement(self.keyspace, definition)
+
+	querylog.Debugf("[%v] %s", self, stmt)
+
+	if err := self.session.Query(stmt).Exec(); err == nil {
+		self.RegisterCollection(definition)
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *CassandraBackend) DeleteCollection(name string) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		var stmt = fmt.Sprintf("DROP TABLE %s", cassandraTableName(self.keyspace, collection.Name))
+
+		querylog.Debugf("[%v] %s", self, stmt)
+
+		if err := self.session.Query(stmt).Exec(); err == nil {
+			self.registeredCollections.Delete(collection.Name)
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+// Returns the named collection, reading its definition from the keyspace's schema if it hasn't
+// been registered.
+func (self *CassandraBackend) GetCollection(name string) (*dal.Collection, error) {
+	if collectionI, ok := self.registeredCollections.Load(name); ok && collectionI != nil {
+		return collectionI.(*dal.Collection), nil
+	}
+
+	var collection = dal.NewCollection(name)
+	var column, kind, ctype string
+	var position int
+	var clustering = make(map[int]dal.Field)
+	var found bool
+
+	var iter = self.session.Query(
+		`SELECT column_name, kind, position, type FROM system_schema.columns WHERE keyspace_name = ? AND table_name = ?`,
+		self.keyspace,
+		name,
+	).Iter()
+
+	for iter.Scan(&column, &kind, &position, &ctype) {
+		found = true
+
+		var field = dal.Field{
+			Name: column,
+			Type: cassandraFieldType(ctype),
+		}
+
+		switch kind {
+		case `partition_key`:
+			if position == 0 {
+				collection.IdentityField = column
+				collection.IdentityFieldType = field.Type
+				continue
+			}
+
+			// additional partition key columns are treated as key fields
+			field.Key = true
+			field.Required = true
+			clustering[-len(clustering)-1] = field
+		case `clustering`:
+			field.Key = true
+			field.Required = true
+			clustering[position] = field
+		default:
+			collection.Fields = append(collection.Fields, field)
+		}
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, dal.CollectionNotFound
+	}
+
+	// key fields come first, in the order that they appear in the primary key
+	var keys = make([]dal.Field, 0, len(clustering))
+
+	for i := -len(clustering); i < len(clustering); i++ {
+		if field, ok := clustering[i]; ok {
+			keys = append(keys, field)
+		}
+	}
+
+	collection.Fields = append(keys, collection.Fields...)
+	self.RegisterCollection(collection)
+
+	return collection, nil
+}
+
+func (self *CassandraBackend) Flush() error {
+	return nil
+}
+
+func (self *CassandraBackend) upsert(create bool, collectionName string, recordset *dal.RecordSet) error {
+	if collection, err := self.GetCollection(collectionName); err == nil {
+		if err := collection.FormatRecordSet(recordset, create); err != nil {
+			return err
+		}
+
+		var merr error
+
+		for _, record := range recordset.Records {
+			if r, err := collection.StructToRecord(record); err == nil {
+				record = r
+			} else {
+				return err
+			}
+
+			// don't write already-expired records, and remove any existing copy of them
+			if collection.IsExpired(record) {
+				if !create {
+					merr = log.AppendError(merr, self.Delete(collection.Name, record.Keys(collection)))
+				}
+
+				continue
+			}
+
+			var columns = []string{cassandraQuote(collection.GetIdentityFieldName())}
+			var values = []interface{}{collection.ConvertValue(collection.GetIdentityFieldName(), record.ID)}
+
+			for _, name := range maputil.StringKeys(record.Fields) {
+				if field, ok := collection.GetField(name); ok && !field.Identity {
+					if value, err := cassandraValue(field, record.Get(name)); err == nil {
+						columns = append(columns, cassandraQuote(name))
+						values = append(values, value)
+					} else {
+						return err
+					}
+				}
+			}
+
+			var stmt = fmt.Sprintf(
+				"INSERT INTO %s (%s) VALUES (%s)",
+				cassandraTableName(self.keyspace, collection.Name),
+				strings.Join(columns, `, `),
+				strings.TrimSuffix(strings.Repeat(`?, `, len(columns)), `, `),
+			)
+
+			// inserts must not overwrite existing records, which requires a lightweight transaction
+			if create {
+				stmt += ` IF NOT EXISTS`
+			}
+
+			if ttl := collection.TTL(record); ttl > 0 {
+				stmt += fmt.Sprintf(" USING TTL %d", int(ttl.Round(time.Second).Seconds()))
+			}
+
+			querylog.Debugf("[%v] %s %v", self, stmt, values)
+
+			if create {
+				if applied, err := self.session.Query(stmt, values...).MapScanCAS(make(map[string]interface{})); err == nil && !applied {
+					return fmt.Errorf("Record %v already exists", record.ID)
+				} else if err != nil {
+					return err
+				}
+			} else if err := self.session.Query(stmt, values...).Exec(); err != nil {
+				merr = log.AppendError(merr, err)
+			}
+		}
+
+		if search := self.WithSearch(collection); search != nil && search != Indexer(self) {
+			if err := search.Index(collection, recordset); err != nil {
+				merr = log.AppendError(merr, err)
+			}
+		}
+
+		return merr
+	} else {
+		return err
+	}
+}
+
+// returns a WHERE clause (and its values) that selects a single row by its primary key
+func cassandraKeyClause(collection *dal.Collection, ids []interface{}) (string, []interface{}, error) {
+	var keys = collection.KeyFields()
+
+	if len(ids) != len(keys) {
+		return ``, nil, fmt.Errorf("%v: expected %d key values, got %d", collection.Name, len(keys), len(ids))
+	}
+
+	var clauses = make([]string, len(keys))
+	var values = make([]interface{}, len(keys))
+
+	for i, key := range keys {
+		clauses[i] = cassandraQuote(key.Name) + ` = ?`
+		values[i] = collection.ConvertValue(key.Name, ids[i])
+	}
+
+	return strings.Join(clauses, ` AND `), values, nil
+}
+
+func cassandraRecordFromRow(collection *dal.Collection, row map[string]interface{}) (*dal.Record, error) {
+	var keys = make([]interface{}, 0)
+	var record = dal.NewRecord(nil)
+
+	for _, key := range collection.KeyFields() {
+		keys = append(keys, row[key.Name])
+	}
+
+	for name, value := range row {
+		if collection.IsIdentityField(name) || typeutil.IsZero(value) {
+			continue
+		}
+
+		if field, ok := collection.GetField(name); ok {
+			switch field.Type {
+			case dal.ObjectType, dal.ArrayType:
+				if text, ok := value.(string); ok {
+					var decoded interface{}
+
+					if err := json.Unmarshal([]byte(text), &decoded); err == nil {
+						value = decoded
+					} else {
+						return nil, fmt.Errorf("field %q: %v", name, err)
+					}
+				}
+			}
+		}
+
+		record.Set(name, value)
+	}
+
+	if err := record.SetKeys(collection, dal.RetrieveOperation, keys...); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// converts a value into one that can be written to the column for the given field
+func cassandraValue(field dal.Field, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch field.Type {
+	case dal.ObjectType, dal.ArrayType:
+		if data, err := json.Marshal(value); err == nil {
+			return string(data), nil
+		} else {
+			return nil, fmt.Errorf("field %q: %v", field.Name, err)
+		}
+	default:
+		return field.ConvertValue(value)
+	}
+}
+
+// returns a CQL statement that creates a table for the given collection.  The identity field is
+// the partition key, and additional key fields are clustering columns.
+func cassandraCreateTableStatement(keyspace string, collection *dal.Collection) string {
+	var columns = []string{
+		cassandraQuote(collection.GetIdentityFieldName()) + ` ` + cassandraColumnType(collection.IdentityFieldType),
+	}
+
+	var clustering = make([]string, 0)
+
+	for _, field := range collection.Fields {
+		if field.Identity || field.Name == collection.GetIdentityFieldName() {
+			continue
+		}
+
+		columns = append(columns, cassandraQuote(field.Name)+` `+cassandraColumnType(field.Type))
+
+		if field.Key {
+			clustering = append(clustering, cassandraQuote(field.Name))
+		}
+	}
+
+	var primaryKey = `(` + cassandraQuote(collection.GetIdentityFieldName()) + `)`
+
+	if len(clustering) > 0 {
+		primaryKey += `, ` + strings.Join(clustering, `, `)
+	}
+
+	columns = append(columns, `PRIMARY KEY (`+primaryKey+`)`)
+
+	return fmt.Sprintf(
+		"CREATE TABLE %s (%s)",
+		cassandraTableName(keyspace, collection.Name),
+		strings.Join(columns, `, `),
+	)
+}
+
+// returns the CQL column type used to store fields of the given type
+func cassandraColumnType(ftype dal.Type) string {
+	switch ftype {
+	case dal.IntType:
+		return `bigint`
+	case dal.FloatType:
+		return `double`
+	case dal.BooleanType:
+		return `boolean`
+	case dal.TimeType:
+		return `timestamp`
+	case dal.RawType:
+		return `blob`
+	default:
+		return `text`
+	}
+}
+
+// returns the field type that best represents values stored in a column of the given CQL type
+func cassandraFieldType(ctype string) dal.Type {
+	ctype = strings.ToLower(ctype)
+
+	if strings.HasPrefix(ctype, `frozen<`) {
+		ctype = strings.TrimSuffix(strings.TrimPrefix(ctype, `frozen<`), `>`)
+	}
+
+	switch {
+	case strings.HasPrefix(ctype, `map<`), strings.HasPrefix(ctype, `tuple<`):
+		return dal.ObjectType
+	case strings.HasPrefix(ctype, `list<`), strings.HasPrefix(ctype, `set<`):
+		return dal.ArrayType
+	}
+
+	switch ctype {
+	case `tinyint`, `smallint`, `int`, `bigint`, `varint`, `counter`:
+		return dal.IntType
+	case `float`, `double`, `decimal`:
+		return dal.FloatType
+	case `boolean`:
+		return dal.BooleanType
+	case `timestamp`, `date`:
+		return dal.TimeType
+	case `blob`:
+		return dal.RawType
+	default:
+		return dal.StringType
+	}
+}
+
+func cassandraTableName(keyspace string, name string) string {
+	return cassandraQuote(keyspace) + `.` + cassandraQuote(name)
+}
+
+func cassandraQuote(identifier string) string {
+	return `"` + strings.Replace(identifier, `"`, `""`, -1) + `"`
+}
@@ -0,0 +1,90 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCassandraCreateTableStatement(t *testing.T) {
+	assert := require.New(t)
+
+	collection := dal.NewCollection(`events`,
+		dal.Field{
+			Name: `tenant`,
+			Type: dal.StringType,
+			Key:  true,
+		}, dal.Field{
+			Name: `count`,
+			Type: dal.IntType,
+		}, dal.Field{
+			Name: `tags`,
+			Type: dal.ArrayType,
+		},
+	)
+
+	collection.IdentityFieldType = dal.StringType
+
+	assert.Equal(
+		`CREATE TABLE "app"."events" ("id" text, "tenant" text, "count" bigint, "tags" text, PRIMARY KEY (("id"), "tenant"))`,
+		cassandraCreateTableStatement(`app`, collection),
+	)
+
+	assert.EqualValues(dal.IntType, cassandraFieldType(`bigint`))
+	assert.EqualValues(dal.ArrayType, cassandraFieldType(`frozen<list<text>>`))
+	assert.EqualValues(dal.ObjectType, cassandraFieldType(`map<text, int>`))
+	assert.Equal(dal.StringType, cassandraFieldType(`varchar`))
+}
+
+func TestCassandraPlanQuery(t *testing.T) {
+	assert := require.New(t)
+
+	collection := dal.NewCollection(`events`,
+		dal.Field{
+			Name: `tenant`,
+			Type: dal.StringType,
+			Key:  true,
+		}, dal.Field{
+			Name: `count`,
+			Type: dal.IntType,
+		},
+	)
+
+	collection.IdentityFieldType = dal.StringType
+
+	// everything can be pushed down
+	plan := cassandraPlanQuery(collection, filter.MustParse(`id/abc/tenant/xyz`), false)
+	assert.Equal(`"id" = ? AND "tenant" = ?`, plan.Where)
+	assert.Equal([]interface{}{`abc`, `xyz`}, plan.Values)
+	assert.False(plan.AllowFiltering)
+	assert.True(plan.IsComplete())
+
+	// non-key columns are evaluated by Pivot unless filtering is allowed
+	plan = cassandraPlanQuery(collection, filter.MustParse(`id/abc/count/gt:5`), false)
+	assert.Equal(`"id" = ?`, plan.Where)
+	assert.False(plan.IsComplete())
+	assert.Len(plan.Residual.Criteria, 1)
+	assert.Equal(`count`, plan.Residual.Criteria[0].Field)
+
+	plan = cassandraPlanQuery(collection, filter.MustParse(`id/abc/count/gt:5`), true)
+	assert.Equal(`"id" = ? AND "count" > ?`, plan.Where)
+	assert.True(plan.AllowFiltering)
+	assert.True(plan.IsComplete())
+
+	// clustering columns can't be restricted without the partition key
+	plan = cassandraPlanQuery(collection, filter.MustParse(`tenant/xyz`), false)
+	assert.Empty(plan.Where)
+	assert.False(plan.IsComplete())
+
+	// multiple partition key values become an IN relation
+	plan = cassandraPlanQuery(collection, filter.MustParse(`id/abc|def`), false)
+	assert.Equal(`"id" IN (?, ?)`, plan.Where)
+	assert.True(plan.IsComplete())
+
+	// unsupported operators remain in the residual filter
+	plan = cassandraPlanQuery(collection, filter.MustParse(`id/prefix:ab`), true)
+	assert.Empty(plan.Where)
+	assert.Len(plan.Residual.Criteria, 1)
+}
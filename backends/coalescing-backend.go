@@ -0,0 +1,409 @@
+package backends
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// The flush interval used for coalesced collections that do not specify one.
+var DefaultCoalesceInterval = time.Second
+
+// Controls how updates to a collection are coalesced.
+type CoalesceOptions struct {
+	// How often pending updates are written to the backend.
+	Interval time.Duration `json:"interval"`
+
+	// The longest an update may wait before being written.  If a collection has updates older
+	// than this when a new update arrives, they are flushed immediately (and synchronously).
+	MaxDelay time.Duration `json:"max_delay,omitempty"`
+
+	// Flush immediately once this many records are pending for the collection.
+	MaxPending int `json:"max_pending,omitempty"`
+
+	// If true, pending updates are discarded rather than written when the backend is closed.
+	DiscardOnClose bool `json:"discard_on_close,omitempty"`
+}
+
+type pendingWrite struct {
+	record *dal.Record
+	since  time.Time
+}
+
+// The CoalescingBackend wraps another backend and buffers updates to the same record in selected
+// collections, merging rapid successive updates into a single write to the underlying backend.
+// Fields from later updates replace those from earlier ones (i.e.: the latest value wins).
+//
+// This trades durability for write throughput: buffered updates are lost if the process exits
+// without calling Close or Flush, and errors from background flushes can only be logged.  It is
+// intended for telemetry-style workloads where losing the last few updates is acceptable.
+type CoalescingBackend struct {
+	backend Backend
+	options map[string]CoalesceOptions
+	pending map[string]map[string]*pendingWrite
+	lock    sync.Mutex
+	stop    chan bool
+	closed  bool
+}
+
+func NewCoalescingBackend(parent Backend) *CoalescingBackend {
+	return &CoalescingBackend{
+		backend: parent,
+		options: make(map[string]CoalesceOptions),
+		pending: make(map[string]map[string]*pendingWrite),
+		stop:    make(chan bool),
+	}
+}
+
+// Return the backend writes are being coalesced for.
+func (self *CoalescingBackend) GetBackend() Backend {
+	return self.backend
+}
+
+// Enable coalescing of updates to the named collection, and start periodically flushing them.
+func (self *CoalescingBackend) Coalesce(collection string, options CoalesceOptions) {
+	if options.Interval <= 0 {
+		options.Interval = DefaultCoalesceInterval
+	}
+
+	self.lock.Lock()
+	_, running := self.options[collection]
+	self.options[collection] = options
+	self.lock.Unlock()
+
+	if !running {
+		go self.startFlushing(collection)
+	}
+}
+
+func (self *CoalescingBackend) startFlushing(collection string) {
+	for {
+		self.lock.Lock()
+		interval := self.options[collection].Interval
+		self.lock.Unlock()
+
+		select {
+		case <-self.stop:
+			return
+		case <-time.After(interval):
+			if err := self.FlushCollection(collection); err != nil {
+				log.Warningf("[%v] failed to flush coalesced writes to %q: %v", self, collection, err)
+			}
+		}
+	}
+}
+
+// Returns the number of records with updates waiting to be written.
+func (self *CoalescingBackend) Pending(collection string) int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	return len(self.pending[collection])
+}
+
+// Immediately write all pending updates for the given collection.  Updates that fail to be written
+// are not retried.
+func (self *CoalescingBackend) FlushCollection(collection string) error {
+	self.lock.Lock()
+	writes := self.pending[collection]
+	delete(self.pending, collection)
+	self.lock.Unlock()
+
+	if len(writes) == 0 {
+		return nil
+	}
+
+	recordset := dal.NewRecordSet()
+
+	for _, write := range writes {
+		recordset.Push(write.record)
+	}
+
+	return self.backend.Update(collection, recordset)
+}
+
+// Write all pending updates for every collection.
+func (self *CoalescingBackend) FlushPending() error {
+	var merr error
+
+	for _, collection := range self.pendingCollections() {
+		if err := self.FlushCollection(collection); err != nil {
+			merr = log.AppendError(merr, fmt.Errorf("%s: %v", collection, err))
+		}
+	}
+
+	return merr
+}
+
+// Stop flushing in the background and write any pending updates (unless the collection was
+// configured with DiscardOnClose).
+func (self *CoalescingBackend) Close() error {
+	var merr error
+
+	self.lock.Lock()
+
+	if self.closed {
+		self.lock.Unlock()
+		return nil
+	}
+
+	self.closed = true
+	close(self.stop)
+	self.lock.Unlock()
+
+	for _, collection := range self.pendingCollections() {
+		self.lock.Lock()
+		discard := self.options[collection].DiscardOnClose
+		self.lock.Unlock()
+
+		if discard {
+			self.lock.Lock()
+			delete(self.pending, collection)
+			self.lock.Unlock()
+		} else if err := self.FlushCollection(collection); err != nil {
+			merr = log.AppendError(merr, fmt.Errorf("%s: %v", collection, err))
+		}
+	}
+
+	return merr
+}
+
+func (self *CoalescingBackend) pendingCollections() []string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	collections := make([]string, 0, len(self.pending))
+
+	for collection := range self.pending {
+		collections = append(collections, collection)
+	}
+
+	return collections
+}
+
+// buffer the given records, returning whether the collection should be flushed now
+func (self *CoalescingBackend) buffer(collection string, options CoalesceOptions, records []*dal.Record) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	now := time.Now()
+	writes, ok := self.pending[collection]
+
+	if !ok {
+		writes = make(map[string]*pendingWrite)
+		self.pending[collection] = writes
+	}
+
+	for _, record := range records {
+		key := fmt.Sprintf("%v", record.ID)
+
+		if write, ok := writes[key]; ok {
+			for k, v := range record.Fields {
+				write.record.Set(k, v)
+			}
+		} else {
+			merged := dal.NewRecord(record.ID)
+
+			for k, v := range record.Fields {
+				merged.Set(k, v)
+			}
+
+			writes[key] = &pendingWrite{
+				record: merged,
+				since:  now,
+			}
+		}
+	}
+
+	if options.MaxPending > 0 && len(writes) >= options.MaxPending {
+		return true
+	}
+
+	if options.MaxDelay > 0 {
+		for _, write := range writes {
+			if now.Sub(write.since) >= options.MaxDelay {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (self *CoalescingBackend) coalesceOptions(collection string) (CoalesceOptions, bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.closed {
+		return CoalesceOptions{}, false
+	}
+
+	options, ok := self.options[collection]
+	return options, ok
+}
+
+// Updates to coalesced collections that identify records by ID are buffered; all others (including
+// targeted updates) are written immediately, after any pending updates to the collection.
+func (self *CoalescingBackend) Update(collection string, records *dal.RecordSet, target ...string) error {
+	if options, ok := self.coalesceOptions(collection); ok && len(target) == 0 && records != nil {
+		buffered := make([]*dal.Record, 0, len(records.Records))
+		direct := dal.NewRecordSet()
+
+		for _, record := range records.Records {
+			if typeutil.IsZero(record.ID) {
+				direct.Push(record)
+			} else {
+				buffered = append(buffered, record)
+			}
+		}
+
+		if self.buffer(collection, options, buffered) {
+			if err := self.FlushCollection(collection); err != nil {
+				return err
+			}
+		}
+
+		if len(direct.Records) == 0 {
+			return nil
+		}
+
+		return self.backend.Update(collection, direct)
+	}
+
+	if err := self.FlushCollection(collection); err != nil {
+		return err
+	}
+
+	return self.backend.Update(collection, records, target...)
+}
+
+// Inserts are written immediately, after any pending updates to the collection.
+func (self *CoalescingBackend) Insert(collection string, records *dal.RecordSet) error {
+	if err := self.FlushCollection(collection); err != nil {
+		return err
+	}
+
+	return self.backend.Insert(collection, records)
+}
+
+// Pending updates to deleted records are discarded.
+func (self *CoalescingBackend) Delete(collection string, ids ...interface{}) error {
+	self.lock.Lock()
+
+	if writes, ok := self.pending[collection]; ok {
+		for _, id := range ids {
+			delete(writes, fmt.Sprintf("%v", id))
+		}
+	}
+
+	self.lock.Unlock()
+
+	return self.backend.Delete(collection, ids...)
+}
+
+// Pending updates are written before fn runs in a transaction on the underlying backend.  Writes
+// made within the transaction are never buffered, since they must commit or roll back with it.
+func (self *CoalescingBackend) Transaction(fn func(tx Backend) error) error {
+	if err := self.FlushPending(); err != nil {
+		return err
+	}
+
+	return Transaction(self.backend, fn)
+}
+
+// Retrieved records reflect any pending updates to them.
+func (self *CoalescingBackend) Retrieve(collection string, id interface{}, fields ...string) (*dal.Record, error) {
+	if record, err := self.backend.Retrieve(collection, id, fields...); err == nil {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+
+		if write, ok := self.pending[collection][fmt.Sprintf("%v", id)]; ok {
+			for k, v := range write.record.Fields {
+				if len(fields) == 0 || sliceutil.ContainsString(fields, k) {
+					record.Set(k, v)
+				}
+			}
+		}
+
+		return record, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Flush pending updates, then flush the underlying backend.
+func (self *CoalescingBackend) Flush() error {
+	if err := self.FlushPending(); err != nil {
+		return err
+	}
+
+	return self.backend.Flush()
+}
+
+func (self *CoalescingBackend) DeleteCollection(collection string) error {
+	self.lock.Lock()
+	delete(self.pending, collection)
+	self.lock.Unlock()
+
+	return self.backend.DeleteCollection(collection)
+}
+
+// passthrough the remaining functions to fulfill the Backend interface
+// -------------------------------------------------------------------------------------------------
+func (self *CoalescingBackend) Exists(collection string, id interface{}) bool {
+	return self.backend.Exists(collection, id)
+}
+
+func (self *CoalescingBackend) Initialize() error {
+	return self.backend.Initialize()
+}
+
+func (self *CoalescingBackend) SetIndexer(cs dal.ConnectionString) error {
+	return self.backend.SetIndexer(cs)
+}
+
+func (self *CoalescingBackend) RegisterCollection(c *dal.Collection) {
+	self.backend.RegisterCollection(c)
+}
+
+func (self *CoalescingBackend) GetConnectionString() *dal.ConnectionString {
+	return self.backend.GetConnectionString()
+}
+
+func (self *CoalescingBackend) CreateCollection(definition *dal.Collection) error {
+	return self.backend.CreateCollection(definition)
+}
+
+func (self *CoalescingBackend) ListCollections() ([]string, error) {
+	return self.backend.ListCollections()
+}
+
+func (self *CoalescingBackend) GetCollection(collection string) (*dal.Collection, error) {
+	return self.backend.GetCollection(collection)
+}
+
+func (self *CoalescingBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.backend.WithSearch(collection, filters...)
+}
+
+func (self *CoalescingBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	return self.backend.WithAggregator(collection)
+}
+
+func (self *CoalescingBackend) Ping(d time.Duration) error {
+	return self.backend.Ping(d)
+}
+
+func (self *CoalescingBackend) String() string {
+	return self.backend.String()
+}
+
+func (self *CoalescingBackend) Supports(feature ...BackendFeature) bool {
+	return self.backend.Supports(feature...)
+}
@@ -0,0 +1,83 @@
+package backends_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalescingBackend(t *testing.T) {
+	assert := require.New(t)
+
+	parent := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	backend := backends.NewCoalescingBackend(parent)
+	defer backend.Close()
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`sensors`, dal.Field{
+		Name: `value`,
+		Type: dal.IntType,
+	})))
+
+	assert.NoError(backend.Insert(`sensors`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`value`, 0),
+		dal.NewRecord(2).Set(`value`, 0),
+	)))
+
+	backend.Coalesce(`sensors`, backends.CoalesceOptions{
+		Interval:   time.Hour,
+		MaxPending: 2,
+	})
+
+	for i := 1; i <= 5; i++ {
+		assert.NoError(backend.Update(`sensors`, dal.NewRecordSet(
+			dal.NewRecord(1).Set(`value`, i),
+		)))
+	}
+
+	// updates are merged and held until flushed
+	assert.Equal(1, backend.Pending(`sensors`))
+
+	record, err := parent.Retrieve(`sensors`, 1)
+	assert.NoError(err)
+	assert.EqualValues(0, record.Get(`value`))
+
+	// ...but reads through the coalescing backend see the latest value
+	record, err = backend.Retrieve(`sensors`, 1)
+	assert.NoError(err)
+	assert.EqualValues(5, record.Get(`value`))
+
+	assert.NoError(backend.Flush())
+	assert.Equal(0, backend.Pending(`sensors`))
+
+	record, err = parent.Retrieve(`sensors`, 1)
+	assert.NoError(err)
+	assert.EqualValues(5, record.Get(`value`))
+
+	// reaching MaxPending flushes immediately
+	assert.NoError(backend.Update(`sensors`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`value`, 10),
+		dal.NewRecord(2).Set(`value`, 20),
+	)))
+
+	assert.Equal(0, backend.Pending(`sensors`))
+
+	record, err = parent.Retrieve(`sensors`, 2)
+	assert.NoError(err)
+	assert.EqualValues(20, record.Get(`value`))
+
+	// closing writes anything still pending
+	assert.NoError(backend.Update(`sensors`, dal.NewRecordSet(
+		dal.NewRecord(2).Set(`value`, 30),
+	)))
+
+	assert.NoError(backend.Close())
+
+	record, err = parent.Retrieve(`sensors`, 2)
+	assert.NoError(err)
+	assert.EqualValues(30, record.Get(`value`))
+}
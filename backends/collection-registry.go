@@ -0,0 +1,186 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// The name of the internal collection that collection definitions are persisted to.
+var CollectionRegistryName = `__pivot_collections`
+
+// The CollectionRegistryBackend persists the full definition of every collection created or
+// registered through it to an internal collection (see CollectionRegistryName) on the wrapped
+// backend.  When initialized, the stored definitions of all collections that still exist are
+// registered with the wrapped backend.  This is useful for backends like DynamoDB or the
+// filesystem, whose own idea of a collection's schema is incomplete, so that a new process regains
+// complete knowledge of the schema without being given the schema files again.
+//
+// Definitions are stored as JSON, so validators and formatters given as functions (as opposed to
+// being declared in the schema) are not persisted.
+type CollectionRegistryBackend struct {
+	Backend
+}
+
+func NewCollectionRegistryBackend(parent Backend) *CollectionRegistryBackend {
+	return &CollectionRegistryBackend{
+		Backend: parent,
+	}
+}
+
+// Return the backend being wrapped.
+func (self *CollectionRegistryBackend) GetBackend() Backend {
+	return self.Backend
+}
+
+func (self *CollectionRegistryBackend) Initialize() error {
+	if err := self.Backend.Initialize(); err != nil {
+		return err
+	}
+
+	if collections, err := self.LoadCollections(); err == nil {
+		for _, collection := range collections {
+			self.Backend.RegisterCollection(collection)
+		}
+
+		if len(collections) > 0 {
+			log.Debugf("[%v] registered %d stored collection definitions", self, len(collections))
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Registers the collection with the wrapped backend and stores its definition.
+func (self *CollectionRegistryBackend) RegisterCollection(definition *dal.Collection) {
+	self.Backend.RegisterCollection(definition)
+
+	if err := self.PersistCollection(definition); err != nil {
+		log.Warningf("[%v] failed to store definition of collection %q: %v", self, definition.Name, err)
+	}
+}
+
+func (self *CollectionRegistryBackend) CreateCollection(definition *dal.Collection) error {
+	if err := self.Backend.CreateCollection(definition); err == nil {
+		return self.PersistCollection(definition)
+	} else {
+		return err
+	}
+}
+
+func (self *CollectionRegistryBackend) DeleteCollection(name string) error {
+	if err := self.Backend.DeleteCollection(name); err == nil {
+		if self.Backend.Exists(CollectionRegistryName, name) {
+			return self.Backend.Delete(CollectionRegistryName, name)
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Lists the collections in the wrapped backend, excluding the registry itself.
+func (self *CollectionRegistryBackend) ListCollections() ([]string, error) {
+	if names, err := self.Backend.ListCollections(); err == nil {
+		var out = make([]string, 0, len(names))
+
+		for _, name := range names {
+			if name != CollectionRegistryName {
+				out = append(out, name)
+			}
+		}
+
+		return out, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Returns the stored definitions of all collections that exist in the wrapped backend.
+func (self *CollectionRegistryBackend) LoadCollections() ([]*dal.Collection, error) {
+	var collections = make([]*dal.Collection, 0)
+
+	names, err := self.Backend.ListCollections()
+
+	if err != nil {
+		return nil, err
+	} else if !sliceutil.ContainsString(names, CollectionRegistryName) {
+		return collections, nil
+	}
+
+	for _, name := range names {
+		if name == CollectionRegistryName || !self.Backend.Exists(CollectionRegistryName, name) {
+			continue
+		}
+
+		if record, err := self.Backend.Retrieve(CollectionRegistryName, name); err == nil {
+			var collection dal.Collection
+
+			if err := json.Unmarshal([]byte(record.GetString(`definition`)), &collection); err != nil {
+				return nil, fmt.Errorf("invalid stored definition for collection %q: %v", name, err)
+			} else if err := collection.Check(); err != nil {
+				return nil, fmt.Errorf("invalid stored definition for collection %q: %v", name, err)
+			}
+
+			collections = append(collections, &collection)
+		} else {
+			return nil, err
+		}
+	}
+
+	return collections, nil
+}
+
+// Stores the given collection definition, replacing any that was previously stored.
+func (self *CollectionRegistryBackend) PersistCollection(definition *dal.Collection) error {
+	if definition == nil || definition.Name == CollectionRegistryName {
+		return nil
+	}
+
+	data, err := json.Marshal(definition)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := self.Backend.GetCollection(CollectionRegistryName); dal.IsCollectionNotFoundErr(err) {
+		if err := self.Backend.CreateCollection(collectionRegistryCollection()); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	var record = dal.NewRecord(definition.Name).Set(`definition`, string(data)).Set(`updated_at`, time.Now())
+
+	if self.Backend.Exists(CollectionRegistryName, definition.Name) {
+		// don't rewrite definitions that haven't changed
+		if existing, err := self.Backend.Retrieve(CollectionRegistryName, definition.Name); err == nil {
+			if existing.GetString(`definition`) == string(data) {
+				return nil
+			}
+		}
+
+		return self.Backend.Update(CollectionRegistryName, dal.NewRecordSet(record))
+	} else {
+		return self.Backend.Insert(CollectionRegistryName, dal.NewRecordSet(record))
+	}
+}
+
+func collectionRegistryCollection() *dal.Collection {
+	collection := dal.NewCollection(CollectionRegistryName,
+		dal.Field{Name: `definition`, Type: dal.StringType},
+		dal.Field{Name: `updated_at`, Type: dal.TimeType},
+	)
+
+	collection.IdentityFieldType = dal.StringType
+
+	return collection
+}
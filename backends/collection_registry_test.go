@@ -0,0 +1,64 @@
+package backends_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectionRegistryBackend(t *testing.T) {
+	assert := require.New(t)
+	driver := spitest.NewMemoryDriver()
+
+	backend := backends.NewCollectionRegistryBackend(
+		spi.NewAdapter(dal.MustParseConnectionString(`memory://`), driver),
+	)
+
+	assert.NoError(backend.Initialize())
+
+	collection := dal.NewCollection(`things`, dal.Field{
+		Name:        `name`,
+		Type:        dal.StringType,
+		Description: `The name of the thing`,
+		Required:    true,
+	})
+
+	collection.IdentityFieldType = dal.StringType
+
+	assert.NoError(backend.CreateCollection(collection))
+	assert.True(backend.GetBackend().Exists(backends.CollectionRegistryName, `things`))
+
+	names, err := backend.ListCollections()
+	assert.NoError(err)
+	assert.Equal([]string{`things`}, names)
+
+	// a new process talking to the same backend regains the full definition
+	restarted := backends.NewCollectionRegistryBackend(
+		spi.NewAdapter(dal.MustParseConnectionString(`memory://`), driver),
+	)
+
+	assert.NoError(restarted.Initialize())
+
+	loaded, err := restarted.LoadCollections()
+	assert.NoError(err)
+	assert.Len(loaded, 1)
+	assert.Equal(`things`, loaded[0].Name)
+	assert.Equal(dal.StringType, loaded[0].IdentityFieldType)
+
+	field, ok := loaded[0].GetField(`name`)
+	assert.True(ok)
+	assert.Equal(`The name of the thing`, field.Description)
+	assert.True(field.Required)
+
+	// deleting the collection forgets its definition
+	assert.NoError(restarted.DeleteCollection(`things`))
+	assert.False(restarted.GetBackend().Exists(backends.CollectionRegistryName, `things`))
+
+	loaded, err = restarted.LoadCollections()
+	assert.NoError(err)
+	assert.Empty(loaded)
+}
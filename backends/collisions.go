@@ -0,0 +1,346 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// Describes how records whose IDs already exist in the destination are given new ones.
+type IdRemapStrategy string
+
+const (
+	// Add a fixed amount to every colliding (integer) ID.
+	RemapByOffset IdRemapStrategy = `offset`
+
+	// Assign a new ID: the next unused integer for integer IDs, or a UUID otherwise.
+	RemapByRegenerate IdRemapStrategy = `regenerate`
+
+	// Prepend a fixed string to every colliding ID.
+	RemapByPrefix IdRemapStrategy = `prefix`
+)
+
+func ParseIdRemapStrategy(in string) (IdRemapStrategy, error) {
+	switch strategy := IdRemapStrategy(in); strategy {
+	case RemapByOffset, RemapByRegenerate, RemapByPrefix:
+		return strategy, nil
+	default:
+		return ``, fmt.Errorf("unknown ID remapping strategy %q (must be one of: offset, regenerate, prefix)", in)
+	}
+}
+
+type IdCollisionOptions struct {
+	// If set, colliding IDs are assigned new values using this strategy.  Otherwise, collisions
+	// are only reported.
+	Strategy IdRemapStrategy `json:"strategy,omitempty"`
+
+	// The amount added to colliding IDs by the offset strategy.  If zero, the largest ID in
+	// either the source or destination is used, which guarantees the new IDs are unused.
+	Offset int64 `json:"offset,omitempty"`
+
+	// The string prepended to colliding IDs by the prefix strategy.
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// Describes the IDs in a source collection that already exist in the destination.
+type IdCollisionReport struct {
+	Collection       string        `json:"collection"`
+	SourceCount      int           `json:"source_count"`
+	DestinationCount int           `json:"destination_count"`
+	Collisions       []interface{} `json:"collisions"`
+	Remapped         int           `json:"remapped,omitempty"`
+	Error            string        `json:"error,omitempty"`
+}
+
+// An IdRemapTable records the new IDs assigned to records as they are merged into another
+// dataset, so that they can be applied when the records are copied, and so that references to
+// them can be fixed up.
+type IdRemapTable struct {
+	Collections map[string]map[string]interface{} `json:"collections"`
+}
+
+func NewIdRemapTable() *IdRemapTable {
+	return &IdRemapTable{
+		Collections: make(map[string]map[string]interface{}),
+	}
+}
+
+// Load a remap table previously written with Save.
+func LoadIdRemapTable(filename string) (*IdRemapTable, error) {
+	var table = NewIdRemapTable()
+
+	if data, err := ioutil.ReadFile(filename); err == nil {
+		if err := json.Unmarshal(data, table); err == nil {
+			if table.Collections == nil {
+				table.Collections = make(map[string]map[string]interface{})
+			}
+
+			return table, nil
+		} else {
+			return nil, fmt.Errorf("invalid remap table %v: %v", filename, err)
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// Write the remap table to the given file as JSON.
+func (self *IdRemapTable) Save(filename string) error {
+	if data, err := json.MarshalIndent(self, ``, `  `); err == nil {
+		return ioutil.WriteFile(filename, data, 0644)
+	} else {
+		return err
+	}
+}
+
+// Record that the given ID in the named collection has been replaced.
+func (self *IdRemapTable) Set(collection string, from interface{}, to interface{}) {
+	if _, ok := self.Collections[collection]; !ok {
+		self.Collections[collection] = make(map[string]interface{})
+	}
+
+	self.Collections[collection][idKey(from)] = to
+}
+
+// Return the ID that replaced the given one in the named collection, if any.
+func (self *IdRemapTable) Get(collection string, from interface{}) (interface{}, bool) {
+	if ids, ok := self.Collections[collection]; ok {
+		to, ok := ids[idKey(from)]
+		return to, ok
+	}
+
+	return nil, false
+}
+
+// Return the number of IDs replaced in the named collection.
+func (self *IdRemapTable) Len(collection string) int {
+	return len(self.Collections[collection])
+}
+
+// Replace the record's ID if it has been remapped.  Returns whether the record was changed.
+func (self *IdRemapTable) Apply(collection *dal.Collection, record *dal.Record) bool {
+	if to, ok := self.Get(collection.Name, record.ID); ok {
+		record.ID = collection.ConvertValue(collection.GetIdentityFieldName(), to)
+		return true
+	}
+
+	return false
+}
+
+// Rewrite the record's references to other records (as declared by the collection's constraints)
+// whose IDs have been remapped.  Only single-field constraints on the remote collection's identity
+// field are considered, since only identities are remapped.  Fields holding an array of IDs have
+// each of them rewritten.  Returns the number of values that were changed.
+func (self *IdRemapTable) ApplyReferences(collection *dal.Collection, record *dal.Record) int {
+	var changed int
+
+	for _, constraint := range collection.GetAllConstraints() {
+		var localFields = sliceutil.Stringify(constraint.On)
+		var remoteFields = sliceutil.Stringify(constraint.Field)
+
+		if len(localFields) != 1 || len(remoteFields) != 1 || self.Len(constraint.Collection) == 0 {
+			continue
+		}
+
+		if remoteFields[0] != dal.DefaultIdentityField {
+			// self-referential constraints may name the collection's own identity field
+			if constraint.Collection != collection.Name || !collection.IsIdentityField(remoteFields[0]) {
+				continue
+			}
+		}
+
+		var field = localFields[0]
+
+		if collection.IsIdentityField(field) {
+			continue
+		}
+
+		var value = record.Get(field)
+
+		if typeutil.IsZero(value) {
+			continue
+		}
+
+		if typeutil.IsArray(value) {
+			var values = sliceutil.Sliceify(value)
+			var rewritten bool
+
+			for i, v := range values {
+				if to, ok := self.Get(constraint.Collection, v); ok {
+					values[i] = to
+					changed += 1
+					rewritten = true
+				}
+			}
+
+			if rewritten {
+				record.Set(field, values)
+			}
+		} else if to, ok := self.Get(constraint.Collection, value); ok {
+			record.Set(field, collection.ConvertValue(field, to))
+			changed += 1
+		}
+	}
+
+	return changed
+}
+
+// Compare the IDs of a collection in the source with those in the destination, reporting those
+// that exist in both.  If a remapping strategy is given, each colliding ID is assigned a new one
+// that is unused in either, and the assignments are recorded in remaps.
+func AnalyzeIdCollisions(source Backend, destination Backend, collection *dal.Collection, options IdCollisionOptions, remaps *IdRemapTable) (*IdCollisionReport, error) {
+	var report = &IdCollisionReport{
+		Collection: collection.Name,
+		Collisions: make([]interface{}, 0),
+	}
+
+	var sourceIds []interface{}
+	var used = make(map[string]bool)
+
+	if ids, err := collectIds(source, collection); err == nil {
+		sourceIds = ids
+		report.SourceCount = len(ids)
+	} else {
+		return nil, fmt.Errorf("source: %v", err)
+	}
+
+	for _, id := range sourceIds {
+		used[idKey(id)] = true
+	}
+
+	if destCollection, err := destination.GetCollection(collection.Name); err == nil {
+		if destIds, err := collectIds(destination, destCollection); err == nil {
+			var existing = make(map[string]bool)
+
+			for _, id := range destIds {
+				existing[idKey(id)] = true
+			}
+
+			for _, id := range sourceIds {
+				if existing[idKey(id)] {
+					report.Collisions = append(report.Collisions, id)
+				}
+			}
+
+			for key := range existing {
+				used[key] = true
+			}
+
+			// new IDs are chosen from beyond those on either side
+			report.DestinationCount = len(destIds)
+			sourceIds = append(sourceIds, destIds...)
+		} else {
+			// the destination can't be enumerated, so check for each ID individually
+			for _, id := range sourceIds {
+				if destination.Exists(collection.Name, id) {
+					report.Collisions = append(report.Collisions, id)
+				}
+			}
+
+			report.DestinationCount = -1
+		}
+	} else if !dal.IsCollectionNotFoundErr(err) {
+		return nil, fmt.Errorf("destination: %v", err)
+	}
+
+	if options.Strategy == `` || len(report.Collisions) == 0 {
+		return report, nil
+	}
+
+	if remaps == nil {
+		return nil, fmt.Errorf("must provide a remap table to record new IDs in")
+	}
+
+	var next int64
+
+	switch options.Strategy {
+	case RemapByOffset, RemapByRegenerate:
+		// find the largest integer ID on either side
+		for _, id := range sourceIds {
+			if v, err := stringutil.ConvertToInteger(id); err == nil {
+				if v > next {
+					next = v
+				}
+			} else if options.Strategy == RemapByOffset {
+				return nil, fmt.Errorf("offset remapping requires integer IDs, got %v", id)
+			}
+		}
+
+		if options.Strategy == RemapByOffset && options.Offset != 0 {
+			next = options.Offset
+		}
+	case RemapByPrefix:
+		if options.Prefix == `` {
+			return nil, fmt.Errorf("must specify a prefix to remap IDs with")
+		}
+	default:
+		return nil, fmt.Errorf("unknown ID remapping strategy %q", options.Strategy)
+	}
+
+	for _, id := range report.Collisions {
+		var newId interface{}
+
+		switch options.Strategy {
+		case RemapByOffset:
+			v, _ := stringutil.ConvertToInteger(id)
+			newId = v + next
+		case RemapByRegenerate:
+			if collection.IdentityFieldType == dal.IntType {
+				for used[idKey(next)] {
+					next += 1
+				}
+
+				newId = next
+			} else {
+				newId = stringutil.UUID().String()
+			}
+		case RemapByPrefix:
+			newId = options.Prefix + idKey(id)
+		}
+
+		if used[idKey(newId)] {
+			return nil, fmt.Errorf("cannot remap ID %v: new ID %v is already in use", id, newId)
+		}
+
+		used[idKey(newId)] = true
+		remaps.Set(collection.Name, id, newId)
+		report.Remapped += 1
+	}
+
+	return report, nil
+}
+
+// returns the IDs of all records in the collection
+func collectIds(backend Backend, collection *dal.Collection) ([]interface{}, error) {
+	var ids = make([]interface{}, 0)
+
+	if search := backend.WithSearch(collection); search != nil {
+		var f = filter.All()
+		f.Fields = []string{collection.GetIdentityFieldName()}
+
+		if err := search.QueryFunc(collection, f, func(record *dal.Record, err error, _ IndexPage) error {
+			if err != nil {
+				return err
+			}
+
+			ids = append(ids, record.ID)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+
+		return ids, nil
+	} else {
+		return nil, fmt.Errorf("collection %q is not enumerable", collection.Name)
+	}
+}
+
+func idKey(id interface{}) string {
+	return fmt.Sprintf("%v", id)
+}
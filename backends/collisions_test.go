@@ -0,0 +1,169 @@
+package backends_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func collisionTestBackends(t *testing.T) (backends.Backend, backends.Backend, *dal.Collection) {
+	assert := require.New(t)
+
+	source := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	destination := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	collection := dal.NewCollection(`things`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	collection.IdentityFieldType = dal.IntType
+
+	assert.NoError(source.CreateCollection(collection))
+	assert.NoError(destination.CreateCollection(collection))
+
+	assert.NoError(source.Insert(`things`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `one`),
+		dal.NewRecord(2).Set(`name`, `two`),
+		dal.NewRecord(3).Set(`name`, `three`),
+	)))
+
+	assert.NoError(destination.Insert(`things`, dal.NewRecordSet(
+		dal.NewRecord(2).Set(`name`, `deux`),
+		dal.NewRecord(3).Set(`name`, `trois`),
+		dal.NewRecord(4).Set(`name`, `quatre`),
+	)))
+
+	return source, destination, collection
+}
+
+func TestAnalyzeIdCollisions(t *testing.T) {
+	assert := require.New(t)
+	source, destination, collection := collisionTestBackends(t)
+
+	report, err := backends.AnalyzeIdCollisions(source, destination, collection, backends.IdCollisionOptions{}, nil)
+	assert.NoError(err)
+	assert.Equal(3, report.SourceCount)
+	assert.Equal(3, report.DestinationCount)
+	assert.Len(report.Collisions, 2)
+	assert.Zero(report.Remapped)
+}
+
+func TestAnalyzeIdCollisionsRemapByOffset(t *testing.T) {
+	assert := require.New(t)
+	source, destination, collection := collisionTestBackends(t)
+	remaps := backends.NewIdRemapTable()
+
+	report, err := backends.AnalyzeIdCollisions(source, destination, collection, backends.IdCollisionOptions{
+		Strategy: backends.RemapByOffset,
+	}, remaps)
+
+	assert.NoError(err)
+	assert.Equal(2, report.Remapped)
+	assert.Equal(2, remaps.Len(`things`))
+
+	// the default offset is the largest ID on either side
+	to, ok := remaps.Get(`things`, 2)
+	assert.True(ok)
+	assert.EqualValues(6, to)
+
+	record := dal.NewRecord(3)
+	assert.True(remaps.Apply(collection, record))
+	assert.EqualValues(7, record.ID)
+
+	record = dal.NewRecord(1)
+	assert.False(remaps.Apply(collection, record))
+	assert.EqualValues(1, record.ID)
+}
+
+func TestAnalyzeIdCollisionsRemapByPrefix(t *testing.T) {
+	assert := require.New(t)
+	source, destination, collection := collisionTestBackends(t)
+	remaps := backends.NewIdRemapTable()
+
+	_, err := backends.AnalyzeIdCollisions(source, destination, collection, backends.IdCollisionOptions{
+		Strategy: backends.RemapByPrefix,
+	}, remaps)
+
+	assert.Error(err)
+
+	collection.IdentityFieldType = dal.StringType
+
+	_, err = backends.AnalyzeIdCollisions(source, destination, collection, backends.IdCollisionOptions{
+		Strategy: backends.RemapByPrefix,
+		Prefix:   `src-`,
+	}, remaps)
+
+	assert.NoError(err)
+
+	to, ok := remaps.Get(`things`, `2`)
+	assert.True(ok)
+	assert.Equal(`src-2`, to)
+
+	// remap tables survive a round trip to disk
+	root, err := ioutil.TempDir(``, `pivot-collisions-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	filename := filepath.Join(root, `remaps.json`)
+	assert.NoError(remaps.Save(filename))
+
+	loaded, err := backends.LoadIdRemapTable(filename)
+	assert.NoError(err)
+	assert.Equal(2, loaded.Len(`things`))
+
+	to, ok = loaded.Get(`things`, 3)
+	assert.True(ok)
+	assert.Equal(`src-3`, to)
+}
+
+func TestIdRemapTableApplyReferences(t *testing.T) {
+	assert := require.New(t)
+	remaps := backends.NewIdRemapTable()
+	remaps.Set(`authors`, 2, 102)
+	remaps.Set(`tags`, `red`, `red-2`)
+
+	collection := dal.NewCollection(`books`, dal.Field{
+		Name:      `author_id`,
+		Type:      dal.IntType,
+		BelongsTo: `authors`,
+	}, dal.Field{
+		Name: `tags`,
+		Type: dal.ArrayType,
+	}, dal.Field{
+		Name: `editor_id`,
+		Type: dal.IntType,
+	})
+
+	collection.Constraints = []dal.Constraint{
+		{
+			On:         `tags`,
+			Collection: `tags`,
+			Field:      `id`,
+		}, {
+			On:         `editor_id`,
+			Collection: `authors`,
+			Field:      `name`,
+		},
+	}
+
+	record := dal.NewRecord(1).Set(`author_id`, 2).Set(`tags`, []interface{}{`red`, `blue`}).Set(`editor_id`, 2)
+
+	assert.Equal(2, remaps.ApplyReferences(collection, record))
+	assert.EqualValues(102, record.Get(`author_id`))
+	assert.Equal([]interface{}{`red-2`, `blue`}, record.Get(`tags`))
+
+	// constraints on non-identity fields are left alone
+	assert.EqualValues(2, record.Get(`editor_id`))
+	assert.EqualValues(1, record.ID)
+
+	record = dal.NewRecord(2).Set(`author_id`, 3)
+	assert.Zero(remaps.ApplyReferences(collection, record))
+	assert.EqualValues(3, record.Get(`author_id`))
+}
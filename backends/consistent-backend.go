@@ -0,0 +1,297 @@
+package backends
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// How long records written through a ConsistentReadBackend are remembered for the purpose of
+// merging them into query results.  This should comfortably exceed the indexer's refresh interval.
+var ConsistencyWindow = 5 * time.Second
+
+// Implemented by indexers that can be told to make recent writes visible to queries immediately
+// (e.g.: by performing an Elasticsearch index refresh.)
+type IndexRefresher interface {
+	RefreshIndex(collection *dal.Collection) error
+}
+
+type recentWrite struct {
+	id      interface{}
+	deleted bool
+	at      time.Time
+}
+
+// The ConsistentReadBackend wraps a backend that uses an external indexer, allowing individual
+// queries to request read-your-writes consistency by setting the Consistency field of their
+// filter.  Queries that don't are passed through to the indexer as-is.
+type ConsistentReadBackend struct {
+	Backend
+	recent    map[string]map[string]*recentWrite
+	lock      sync.Mutex
+	parent    *ConsistentReadBackend // set on the views of this backend given to transactions
+	committed *[]func()
+}
+
+func NewConsistentReadBackend(parent Backend) *ConsistentReadBackend {
+	return &ConsistentReadBackend{
+		Backend: parent,
+		recent:  make(map[string]map[string]*recentWrite),
+	}
+}
+
+// Return the backend being wrapped.
+func (self *ConsistentReadBackend) GetBackend() Backend {
+	return self.Backend
+}
+
+func (self *ConsistentReadBackend) Insert(collection string, records *dal.RecordSet) error {
+	if err := self.Backend.Insert(collection, records); err == nil {
+		self.remember(collection, false, recordIds(records)...)
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *ConsistentReadBackend) Update(collection string, records *dal.RecordSet, target ...string) error {
+	if err := self.Backend.Update(collection, records, target...); err == nil {
+		self.remember(collection, false, recordIds(records)...)
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *ConsistentReadBackend) Delete(collection string, ids ...interface{}) error {
+	if err := self.Backend.Delete(collection, ids...); err == nil {
+		self.remember(collection, true, ids...)
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *ConsistentReadBackend) DeleteCollection(collection string) error {
+	self.lock.Lock()
+	delete(self.recent, collection)
+	self.lock.Unlock()
+
+	return self.Backend.DeleteCollection(collection)
+}
+
+// Returns an indexer that honors the consistency level of the first filter given (if any).
+func (self *ConsistentReadBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	var search = self.Backend.WithSearch(collection, filters...)
+
+	if search == nil {
+		return nil
+	}
+
+	for _, f := range filters {
+		if f != nil && f.Consistency != filter.EventualConsistency {
+			return &consistentIndexer{
+				Indexer: search,
+				backend: self,
+				level:   f.Consistency,
+			}
+		}
+	}
+
+	return search
+}
+
+// Runs fn in a transaction on the wrapped backend.  Records written by it are remembered once the
+// transaction commits.
+func (self *ConsistentReadBackend) Transaction(fn func(tx Backend) error) error {
+	return transactionView(self.Backend, fn, func(tx Backend, committed *[]func()) Backend {
+		return &ConsistentReadBackend{
+			Backend:   tx,
+			parent:    self,
+			committed: committed,
+		}
+	})
+}
+
+func (self *ConsistentReadBackend) remember(collection string, deleted bool, ids ...interface{}) {
+	if self.parent != nil {
+		*self.committed = append(*self.committed, func() {
+			self.parent.remember(collection, deleted, ids...)
+		})
+
+		return
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	var now = time.Now()
+	var writes, ok = self.recent[collection]
+
+	if !ok {
+		writes = make(map[string]*recentWrite)
+		self.recent[collection] = writes
+	}
+
+	for _, id := range ids {
+		if typeutil.IsZero(id) {
+			continue
+		}
+
+		writes[fmt.Sprintf("%v", id)] = &recentWrite{
+			id:      id,
+			deleted: deleted,
+			at:      now,
+		}
+	}
+}
+
+// returns the writes to the given collection made within the consistency window, discarding
+// any older ones
+func (self *ConsistentReadBackend) recentWrites(collection string) map[string]recentWrite {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	var writes = make(map[string]recentWrite)
+
+	for key, write := range self.recent[collection] {
+		if time.Since(write.at) > ConsistencyWindow {
+			delete(self.recent[collection], key)
+		} else {
+			writes[key] = *write
+		}
+	}
+
+	return writes
+}
+
+// find the first backend in the chain of wrapped backends that can answer queries itself
+func (self *ConsistentReadBackend) directIndexer() Indexer {
+	for _, backend := range BackendChain(self.Backend) {
+		if indexer, ok := backend.(Indexer); ok {
+			return indexer
+		}
+	}
+
+	return nil
+}
+
+func recordIds(records *dal.RecordSet) []interface{} {
+	var ids = make([]interface{}, 0)
+
+	if records != nil {
+		for _, record := range records.Records {
+			ids = append(ids, record.ID)
+		}
+	}
+
+	return ids
+}
+
+type consistentIndexer struct {
+	Indexer
+	backend *ConsistentReadBackend
+	level   filter.ConsistencyLevel
+}
+
+func (self *consistentIndexer) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if self.level == filter.BackendConsistency {
+		if direct := self.backend.directIndexer(); direct != nil {
+			return direct.Query(collection, f, resultFns...)
+		} else {
+			return nil, fmt.Errorf("backend %v cannot be queried directly", self.backend)
+		}
+	}
+
+	return DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+func (self *consistentIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	switch self.level {
+	case filter.RefreshConsistency:
+		if refresher, ok := self.Indexer.(IndexRefresher); ok {
+			if err := refresher.RefreshIndex(collection); err != nil {
+				return err
+			}
+		} else if err := self.Indexer.FlushIndex(); err != nil {
+			return err
+		}
+
+		return self.Indexer.QueryFunc(collection, f, resultFn)
+
+	case filter.MergeConsistency:
+		return self.mergedQueryFunc(collection, f, resultFn)
+
+	case filter.BackendConsistency:
+		if direct := self.backend.directIndexer(); direct != nil {
+			return direct.QueryFunc(collection, f, resultFn)
+		} else {
+			return fmt.Errorf("backend %v cannot be queried directly", self.backend)
+		}
+
+	default:
+		return self.Indexer.QueryFunc(collection, f, resultFn)
+	}
+}
+
+// query the indexer, replacing results that were recently written with their current contents
+// from the backend, omitting those that were recently deleted, and appending recently-written
+// records that match the filter but that the indexer doesn't know about yet.
+func (self *consistentIndexer) mergedQueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	var recent = self.backend.recentWrites(collection.Name)
+	var seen = make(map[string]bool)
+	var lastPage IndexPage
+	var emitted int
+
+	if err := self.Indexer.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		lastPage = page
+
+		if err != nil || record == nil {
+			return resultFn(record, err, page)
+		}
+
+		var key = fmt.Sprintf("%v", record.ID)
+		seen[key] = true
+
+		if write, ok := recent[key]; ok {
+			if write.deleted {
+				return nil
+			}
+
+			if current, err := self.backend.Retrieve(collection.Name, write.id); err == nil {
+				if !f.MatchesRecord(current) {
+					return nil
+				}
+
+				record = current
+			}
+		}
+
+		emitted += 1
+		return resultFn(record, nil, page)
+	}); err != nil {
+		return err
+	}
+
+	for key, write := range recent {
+		if write.deleted || seen[key] {
+			continue
+		} else if f.Limit > 0 && emitted >= f.Limit {
+			break
+		}
+
+		if current, err := self.backend.Retrieve(collection.Name, write.id); err == nil && f.MatchesRecord(current) {
+			emitted += 1
+
+			if err := resultFn(current, nil, lastPage); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
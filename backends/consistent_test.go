@@ -0,0 +1,113 @@
+package backends_test
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// an indexer that only knows about records written to it directly, standing in for an external
+// indexer that hasn't refreshed yet
+type staleIndexer struct {
+	backends.Indexer
+	parent backends.Backend
+}
+
+func (self *staleIndexer) GetBackend() backends.Backend {
+	return self.parent
+}
+
+type staleSearchBackend struct {
+	backends.Backend
+	index backends.Indexer
+}
+
+func (self *staleSearchBackend) GetBackend() backends.Backend {
+	return self.Backend
+}
+
+func (self *staleSearchBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) backends.Indexer {
+	return self.index
+}
+
+func TestConsistentReadBackend(t *testing.T) {
+	assert := require.New(t)
+
+	primary := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	index := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	for _, b := range []backends.Backend{primary, index} {
+		assert.NoError(b.CreateCollection(dal.NewCollection(`things`, dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		})))
+	}
+
+	collection, err := primary.GetCollection(`things`)
+	assert.NoError(err)
+
+	backend := backends.NewConsistentReadBackend(&staleSearchBackend{
+		Backend: primary,
+		index: &staleIndexer{
+			Indexer: index,
+			parent:  primary,
+		},
+	})
+
+	// record 3 is already indexed, 1 and 2 have not been yet
+	indexed := dal.NewRecordSet(dal.NewRecord(3).Set(`name`, `c`))
+	assert.NoError(primary.Insert(`things`, indexed))
+	assert.NoError(index.Insert(`things`, indexed))
+
+	assert.NoError(backend.Insert(`things`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `a`),
+		dal.NewRecord(2).Set(`name`, `b`),
+	)))
+
+	query := func(f *filter.Filter) []string {
+		search := backend.WithSearch(collection, f)
+		assert.NotNil(search)
+
+		recordset, err := search.Query(collection, f)
+		assert.NoError(err)
+
+		ids := make([]string, 0)
+
+		for _, record := range recordset.Records {
+			ids = append(ids, fmt.Sprintf("%v", record.ID))
+		}
+
+		sort.Strings(ids)
+		return ids
+	}
+
+	f := filter.All()
+	assert.Equal([]string{`3`}, query(f))
+
+	f.Consistency = filter.MergeConsistency
+	assert.Equal([]string{`1`, `2`, `3`}, query(f))
+
+	f = filter.MustParse(`name/b`)
+	f.Consistency = filter.MergeConsistency
+	assert.Equal([]string{`2`}, query(f))
+
+	// recently deleted records are omitted even though the indexer still has them
+	assert.NoError(backend.Delete(`things`, 3))
+
+	f = filter.All()
+	f.Consistency = filter.MergeConsistency
+	assert.Equal([]string{`1`, `2`}, query(f))
+
+	f.Consistency = filter.BackendConsistency
+	assert.Equal([]string{`1`, `2`}, query(f))
+
+	_, err = filter.ParseConsistencyLevel(`strong`)
+	assert.Error(err)
+}
@@ -0,0 +1,158 @@
+package backends
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// Implemented by backends whose reads and writes can be bound to a context, so that callers can
+// enforce deadlines and cancel operations that are no longer needed.  The methods behave exactly
+// like their counterparts in Backend (and Indexer, for QueryContext), except that they stop and
+// return the context's error once it is cancelled or its deadline passes.
+type ContextBackend interface {
+	RetrieveContext(ctx context.Context, collection string, id interface{}, fields ...string) (*dal.Record, error)
+	InsertContext(ctx context.Context, collection string, records *dal.RecordSet) error
+	UpdateContext(ctx context.Context, collection string, records *dal.RecordSet, target ...string) error
+	DeleteContext(ctx context.Context, collection string, ids ...interface{}) error
+	QueryContext(ctx context.Context, collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error)
+}
+
+// Retrieve a record from the given backend, giving up once the context is done.  If the backend
+// is not a ContextBackend, the operation is abandoned rather than cancelled; its eventual result
+// is discarded.
+func RetrieveContext(ctx context.Context, backend Backend, collection string, id interface{}, fields ...string) (*dal.Record, error) {
+	if cb, ok := backend.(ContextBackend); ok {
+		return cb.RetrieveContext(ctx, collection, id, fields...)
+	}
+
+	var record *dal.Record
+
+	err := runContext(ctx, func() error {
+		var err error
+		record, err = backend.Retrieve(collection, id, fields...)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// Insert records into the given backend, giving up once the context is done.  If the backend is
+// not a ContextBackend, the operation is abandoned rather than cancelled, so the records may still
+// be written after this returns.
+func InsertContext(ctx context.Context, backend Backend, collection string, records *dal.RecordSet) error {
+	if cb, ok := backend.(ContextBackend); ok {
+		return cb.InsertContext(ctx, collection, records)
+	}
+
+	return runContext(ctx, func() error {
+		return backend.Insert(collection, records)
+	})
+}
+
+// Update records in the given backend, giving up once the context is done.  If the backend is not
+// a ContextBackend, the operation is abandoned rather than cancelled, so the records may still be
+// updated after this returns.
+func UpdateContext(ctx context.Context, backend Backend, collection string, records *dal.RecordSet, target ...string) error {
+	if cb, ok := backend.(ContextBackend); ok {
+		return cb.UpdateContext(ctx, collection, records, target...)
+	}
+
+	return runContext(ctx, func() error {
+		return backend.Update(collection, records, target...)
+	})
+}
+
+// Delete records from the given backend, giving up once the context is done.  If the backend is
+// not a ContextBackend, the operation is abandoned rather than cancelled, so the records may still
+// be deleted after this returns.
+func DeleteContext(ctx context.Context, backend Backend, collection string, ids ...interface{}) error {
+	if cb, ok := backend.(ContextBackend); ok {
+		return cb.DeleteContext(ctx, collection, ids...)
+	}
+
+	return runContext(ctx, func() error {
+		return backend.Delete(collection, ids...)
+	})
+}
+
+// Query the given backend's indexer, giving up once the context is done.  If the backend is not a
+// ContextBackend, the query is abandoned rather than cancelled; resultFns are not called after
+// this returns.
+func QueryContext(ctx context.Context, backend Backend, collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if cb, ok := backend.(ContextBackend); ok {
+		return cb.QueryContext(ctx, collection, f, resultFns...)
+	}
+
+	if search := backend.WithSearch(collection, f); search != nil {
+		return queryContext(ctx, search, collection, f, resultFns...)
+	} else {
+		return nil, NotImplementedError
+	}
+}
+
+// runs the given query in the background, passing results to resultFns until the context is done
+func queryContext(ctx context.Context, search Indexer, collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	var recordset *dal.RecordSet
+	var lock sync.Mutex
+	var expired bool
+	var guarded = make([]IndexResultFunc, len(resultFns))
+
+	for i, resultFn := range resultFns {
+		var fn = resultFn
+
+		guarded[i] = func(record *dal.Record, err error, page IndexPage) error {
+			lock.Lock()
+			defer lock.Unlock()
+
+			if expired {
+				return ctx.Err()
+			}
+
+			return fn(record, err, page)
+		}
+	}
+
+	err := runContext(ctx, func() error {
+		var err error
+		recordset, err = search.Query(collection, f, guarded...)
+		return err
+	})
+
+	if err != nil {
+		lock.Lock()
+		expired = true
+		lock.Unlock()
+
+		return nil, err
+	}
+
+	return recordset, nil
+}
+
+// runs the given function in the background, returning the context's error if it is done before
+// the function returns
+func runContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var result = make(chan error, 1)
+
+	go func() {
+		result <- fn()
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,115 @@
+package backends_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// a backend that records the context its reads are given
+type contextBackend struct {
+	*spi.Adapter
+	ctx context.Context
+}
+
+func (self *contextBackend) RetrieveContext(ctx context.Context, collection string, id interface{}, fields ...string) (*dal.Record, error) {
+	self.ctx = ctx
+	return self.Adapter.Retrieve(collection, id, fields...)
+}
+
+func (self *contextBackend) InsertContext(ctx context.Context, collection string, records *dal.RecordSet) error {
+	return self.Adapter.Insert(collection, records)
+}
+
+func (self *contextBackend) UpdateContext(ctx context.Context, collection string, records *dal.RecordSet, target ...string) error {
+	return self.Adapter.Update(collection, records, target...)
+}
+
+func (self *contextBackend) DeleteContext(ctx context.Context, collection string, ids ...interface{}) error {
+	return self.Adapter.Delete(collection, ids...)
+}
+
+func (self *contextBackend) QueryContext(ctx context.Context, collection *dal.Collection, f *filter.Filter, resultFns ...backends.IndexResultFunc) (*dal.RecordSet, error) {
+	return self.Adapter.Query(collection, f, resultFns...)
+}
+
+func TestContextOperations(t *testing.T) {
+	assert := require.New(t)
+
+	slow := &slowBackend{
+		Adapter: spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver()),
+	}
+
+	assert.NoError(slow.CreateCollection(dal.NewCollection(`things`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	collection, err := slow.GetCollection(`things`)
+	assert.NoError(err)
+
+	// operations that finish before the deadline are unaffected
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.NoError(backends.InsertContext(ctx, slow, `things`, dal.NewRecordSet(
+		dal.NewRecord(`a`).Set(`name`, `first`),
+	)))
+
+	record, err := backends.RetrieveContext(ctx, slow, `things`, `a`)
+	assert.NoError(err)
+	assert.Equal(`first`, record.Get(`name`))
+
+	results, err := backends.QueryContext(ctx, slow, collection, filter.All())
+	assert.NoError(err)
+	assert.Len(results.Records, 1)
+
+	// operations still running when the deadline passes return the context's error
+	slow.delay = 200 * time.Millisecond
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(context.DeadlineExceeded, backends.InsertContext(ctx, slow, `things`, dal.NewRecordSet(
+		dal.NewRecord(`b`).Set(`name`, `second`),
+	)))
+
+	// operations given a context that is already cancelled are never started
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(context.Canceled, backends.DeleteContext(ctx, slow, `things`, `a`))
+	assert.True(slow.Exists(`things`, `a`))
+}
+
+func TestContextBackendPreferred(t *testing.T) {
+	assert := require.New(t)
+
+	backend := &contextBackend{
+		Adapter: spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver()),
+	}
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`things`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	assert.NoError(backend.Insert(`things`, dal.NewRecordSet(
+		dal.NewRecord(`a`).Set(`name`, `first`),
+	)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	record, err := backends.RetrieveContext(ctx, backend, `things`, `a`)
+	assert.NoError(err)
+	assert.Equal(`first`, record.Get(`name`))
+	assert.Equal(ctx, backend.ctx)
+}
@@ -0,0 +1,309 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// The default number of records read from the source and written to the destination at a time.
+var CopyBatchSize = 1000
+
+// The default number of collections copied at the same time.
+var CopyConcurrency = 4
+
+type CopyOptions struct {
+	// Fields used to match existing destination records; matching records are updated instead of
+	// duplicated.
+	KeyFields []string
+
+	// If set, records are given the new IDs recorded in this table as they are copied, and
+	// references to remapped records are rewritten to match.
+	Remaps *IdRemapTable
+
+	// The number of records read and written at a time.
+	BatchSize int
+
+	// The number of collections copied at the same time.
+	Concurrency int
+
+	// Copy collections whose schemas differ between the source and destination.
+	SkipSchemaCheck bool
+
+	// If set, progress is saved to this file after every batch so that an interrupted copy can
+	// pick up where it left off.  The file is removed once every collection has been copied.
+	CheckpointFile string
+
+	// Called after every batch.  Calls may come from multiple goroutines at once.
+	Progress func(report *CopyReport)
+}
+
+// The outcome of copying a single collection from one backend to another.
+type CopyReport struct {
+	Collection string            `json:"collection"`
+	Copied     int               `json:"copied"`
+	Failed     int               `json:"failed"`
+	Remapped   int               `json:"remapped,omitempty"`
+	References int               `json:"references,omitempty"`
+	Downgrades []SchemaDowngrade `json:"downgrades,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	After      interface{}       `json:"after,omitempty"`
+	Complete   bool              `json:"complete,omitempty"`
+}
+
+// the contents of a copy checkpoint file
+type copyCheckpoint struct {
+	Collections map[string]CopyReport `json:"collections"`
+}
+
+// Copy the collections described by the given reports from the source to the destination backend,
+// several at a time.  Each report is updated with the outcome of copying its collection.  If a
+// checkpoint file from a previous (interrupted) copy exists, collections are resumed from where it
+// left off, and those it records as complete are skipped.
+func Copy(source Backend, destination Backend, reports []*CopyReport, options CopyOptions) error {
+	var checkpoint = copyCheckpoint{
+		Collections: make(map[string]CopyReport),
+	}
+
+	var checkpointLock sync.Mutex
+	var checkpointErr error
+
+	if options.Concurrency <= 0 {
+		options.Concurrency = CopyConcurrency
+	}
+
+	// pick up where a previous run left off
+	if options.CheckpointFile != `` {
+		if data, err := ioutil.ReadFile(options.CheckpointFile); err == nil {
+			if err := json.Unmarshal(data, &checkpoint); err != nil {
+				return fmt.Errorf("invalid copy checkpoint file %s: %v", options.CheckpointFile, err)
+			} else if checkpoint.Collections == nil {
+				checkpoint.Collections = make(map[string]CopyReport)
+			}
+
+			for _, report := range reports {
+				if saved, ok := checkpoint.Collections[report.Collection]; ok {
+					report.Copied = saved.Copied
+					report.Failed = saved.Failed
+					report.Remapped = saved.Remapped
+					report.References = saved.References
+					report.After = saved.After
+					report.Complete = saved.Complete
+				}
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	var progress = options.Progress
+
+	// record each collection's progress in the checkpoint file as batches are written
+	options.Progress = func(report *CopyReport) {
+		checkpointLock.Lock()
+		defer checkpointLock.Unlock()
+
+		checkpoint.Collections[report.Collection] = *report
+
+		if options.CheckpointFile != `` {
+			if data, err := json.Marshal(&checkpoint); err == nil {
+				if err := ioutil.WriteFile(options.CheckpointFile, data, 0644); err != nil {
+					checkpointErr = err
+				}
+			} else {
+				checkpointErr = err
+			}
+		}
+
+		if progress != nil {
+			progress(report)
+		}
+	}
+
+	var queue = make(chan *CopyReport)
+	var wg sync.WaitGroup
+
+	for i := 0; i < options.Concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for report := range queue {
+				if err := CopyCollection(source, destination, report, options); err != nil {
+					report.Error = err.Error()
+					log.Errorf("Failed to copy collection %q: %v", report.Collection, err)
+				} else {
+					log.Noticef("Successfully copied %d records from collection %q", report.Copied, report.Collection)
+				}
+			}
+		}()
+	}
+
+	for _, report := range reports {
+		if report.Complete {
+			log.Infof("Skipping collection %q: already copied", report.Collection)
+			continue
+		}
+
+		queue <- report
+	}
+
+	close(queue)
+	wg.Wait()
+
+	if checkpointErr != nil {
+		return fmt.Errorf("failed to write copy checkpoint: %v", checkpointErr)
+	}
+
+	// only forget our progress once there's nothing left to resume
+	if options.CheckpointFile != `` {
+		for _, report := range reports {
+			if !report.Complete {
+				return nil
+			}
+		}
+
+		if err := os.Remove(options.CheckpointFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Copy a single collection from the source to the destination backend in batches ordered by
+// identity, creating the destination collection if it does not exist.  Copying starts after the
+// record identified by report.After (if set), and the report is updated after every batch.
+func CopyCollection(source Backend, destination Backend, report *CopyReport, options CopyOptions) error {
+	var name = report.Collection
+
+	if options.BatchSize <= 0 {
+		options.BatchSize = CopyBatchSize
+	}
+
+	collection, err := source.GetCollection(name)
+
+	if err != nil {
+		return err
+	}
+
+	var destCollection *dal.Collection
+
+	if dc, err := destination.GetCollection(name); err == nil {
+		destCollection = dc
+	} else if dal.IsCollectionNotFoundErr(err) {
+		if err := destination.CreateCollection(collection); err == nil {
+			destCollection = collection
+		} else {
+			return fmt.Errorf("cannot create destination collection: %v", err)
+		}
+	} else {
+		return fmt.Errorf("cannot import to destination collection: %v", err)
+	}
+
+	if diffs := destCollection.Diff(collection); len(diffs) > 0 && !options.SkipSchemaCheck {
+		for _, diff := range diffs {
+			log.Errorf("  %v", diff)
+		}
+
+		return fmt.Errorf("collections differ")
+	}
+
+	for {
+		var f = filter.Copy(filter.All())
+
+		f.IdentityField = collection.GetIdentityFieldName()
+		f.Sort = []string{f.IdentityField}
+		f.Limit = options.BatchSize
+		f.Offset = 0
+		f.After = report.After
+
+		search := source.WithSearch(collection, &f)
+
+		if search == nil {
+			return fmt.Errorf("collection is not enumerable")
+		}
+
+		recordset, err := search.Query(collection, &f)
+
+		if err != nil {
+			return err
+		} else if len(recordset.Records) == 0 {
+			break
+		}
+
+		var batch = dal.NewRecordSet()
+		var lastID interface{}
+
+		for _, record := range recordset.Records {
+			// the cursor refers to records by their ID in the source
+			lastID = record.ID
+
+			if options.Remaps != nil {
+				if options.Remaps.Apply(collection, record) {
+					report.Remapped += 1
+				}
+
+				report.References += options.Remaps.ApplyReferences(collection, record)
+			}
+
+			batch.Push(record)
+		}
+
+		// guard against looping forever over backends that don't support cursor pagination
+		if report.After != nil && fmt.Sprintf("%v", lastID) == fmt.Sprintf("%v", report.After) {
+			return fmt.Errorf("results did not advance past %v", lastID)
+		}
+
+		if err := copyBatch(destination, name, batch, options); err == nil {
+			report.Copied += len(batch.Records)
+		} else {
+			// find out which records can't be written by writing them one at a time
+			log.Warningf("failed to write batch of %d records to %q, retrying individually: %v", len(batch.Records), name, err)
+
+			for _, record := range batch.Records {
+				if err := copyBatch(destination, name, dal.NewRecordSet(record), options); err == nil {
+					report.Copied += 1
+				} else {
+					report.Failed += 1
+					log.Warningf("failed to write record %v to destination: %v", record.ID, err)
+				}
+			}
+		}
+
+		report.After = lastID
+
+		if options.Progress != nil {
+			options.Progress(report)
+		}
+
+		if len(recordset.Records) < options.BatchSize {
+			break
+		}
+	}
+
+	report.Complete = true
+
+	if options.Progress != nil {
+		options.Progress(report)
+	}
+
+	return nil
+}
+
+// writes the given records to the destination, updating existing records if key fields were given
+func copyBatch(destination Backend, name string, batch *dal.RecordSet, options CopyOptions) error {
+	if len(options.KeyFields) > 0 {
+		_, err := UpsertByKey(destination, name, options.KeyFields, batch)
+		return err
+	} else {
+		return destination.Insert(name, batch)
+	}
+}
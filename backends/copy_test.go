@@ -0,0 +1,130 @@
+package backends_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func copyTestSource(t *testing.T) backends.Backend {
+	assert := require.New(t)
+	source := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	for _, name := range []string{`cats`, `dogs`} {
+		collection := dal.NewCollection(name, dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		})
+
+		collection.IdentityFieldType = dal.IntType
+
+		assert.NoError(source.CreateCollection(collection))
+
+		records := dal.NewRecordSet()
+
+		for i := 1; i <= 5; i++ {
+			records.Push(dal.NewRecord(i).Set(`name`, name))
+		}
+
+		assert.NoError(source.Insert(name, records))
+	}
+
+	return source
+}
+
+func TestCopy(t *testing.T) {
+	assert := require.New(t)
+	source := copyTestSource(t)
+	destination := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	reports := []*backends.CopyReport{
+		{Collection: `cats`},
+		{Collection: `dogs`},
+	}
+
+	var batches int
+
+	assert.NoError(backends.Copy(source, destination, reports, backends.CopyOptions{
+		BatchSize:   2,
+		Concurrency: 1,
+		Progress: func(report *backends.CopyReport) {
+			batches += 1
+		},
+	}))
+
+	for _, report := range reports {
+		assert.Empty(report.Error)
+		assert.Equal(5, report.Copied)
+		assert.Zero(report.Failed)
+		assert.True(report.Complete)
+
+		for i := 1; i <= 5; i++ {
+			assert.True(destination.Exists(report.Collection, i))
+		}
+	}
+
+	// three batches per collection, plus one marking it complete
+	assert.Equal(8, batches)
+}
+
+func TestCopyResumesFromCheckpoint(t *testing.T) {
+	assert := require.New(t)
+	source := copyTestSource(t)
+	destination := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	dir, err := ioutil.TempDir(``, `pivot-copy-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// an earlier run finished copying cats, and got partway through dogs
+	checkpoint := filepath.Join(dir, `copy.json`)
+	data, err := json.Marshal(map[string]interface{}{
+		`collections`: map[string]interface{}{
+			`cats`: map[string]interface{}{
+				`collection`: `cats`,
+				`copied`:     5,
+				`complete`:   true,
+			},
+			`dogs`: map[string]interface{}{
+				`collection`: `dogs`,
+				`copied`:     3,
+				`after`:      3,
+			},
+		},
+	})
+
+	assert.NoError(err)
+	assert.NoError(ioutil.WriteFile(checkpoint, data, 0644))
+
+	reports := []*backends.CopyReport{
+		{Collection: `cats`},
+		{Collection: `dogs`},
+	}
+
+	assert.NoError(backends.Copy(source, destination, reports, backends.CopyOptions{
+		BatchSize:      2,
+		CheckpointFile: checkpoint,
+	}))
+
+	assert.True(reports[0].Complete)
+	assert.Equal(5, reports[0].Copied)
+	assert.False(destination.Exists(`cats`, 1))
+
+	assert.True(reports[1].Complete)
+	assert.Equal(5, reports[1].Copied)
+	assert.False(destination.Exists(`dogs`, 3))
+	assert.True(destination.Exists(`dogs`, 4))
+	assert.True(destination.Exists(`dogs`, 5))
+
+	// the checkpoint is removed once everything has been copied
+	_, err = os.Stat(checkpoint)
+	assert.True(os.IsNotExist(err))
+}
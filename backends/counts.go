@@ -0,0 +1,127 @@
+package backends
+
+import (
+	"fmt"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// The key used in count maps for records that have no value for a field.
+var CountByNullKey = `null`
+
+// The number of records sharing a distinct combination of values for a set of fields.
+type GroupCount struct {
+	Values []interface{} `json:"values"`
+	Count  uint64        `json:"count"`
+}
+
+// Implemented by aggregators that can count records grouped by several fields in a single query
+// (e.g.: SQL GROUP BY, Elasticsearch composite aggregations).
+type GroupCounter interface {
+	CountBy(collection *dal.Collection, fields []string, f ...*filter.Filter) ([]GroupCount, error)
+}
+
+// Counts the records in a collection for each distinct combination of values of the given fields,
+// returning a map nested one level per field (e.g.: {"CA": {"LA": 12, "SF": 3}}).  Aggregators that
+// implement GroupCounter perform this in a single query; otherwise the matching records are
+// counted in a single pass over the collection's search index.
+func CountBy(backend Backend, collection *dal.Collection, fields []string, f *filter.Filter) (map[string]interface{}, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("must specify at least one field to count by")
+	}
+
+	for _, name := range fields {
+		if _, ok := collection.GetField(name); !ok {
+			return nil, fmt.Errorf("field %q: %v", name, dal.FieldNotFound)
+		}
+	}
+
+	if f == nil {
+		f = filter.All()
+	}
+
+	var groups []GroupCount
+
+	if counter, ok := backend.WithAggregator(collection).(GroupCounter); ok {
+		if g, err := counter.CountBy(collection, fields, f); err == nil {
+			groups = g
+		} else {
+			return nil, err
+		}
+	} else if search := backend.WithSearch(collection, f); search != nil {
+		if g, err := countByScanning(search, collection, fields, f); err == nil {
+			groups = g
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("Backend %T does not support aggregations.", backend)
+	}
+
+	return nestGroupCounts(groups), nil
+}
+
+func countByScanning(search Indexer, collection *dal.Collection, fields []string, f *filter.Filter) ([]GroupCount, error) {
+	groups := make([]GroupCount, 0)
+	index := make(map[string]int)
+
+	if err := search.QueryFunc(collection, f, func(record *dal.Record, err error, _ IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		values := make([]interface{}, len(fields))
+
+		for i, name := range fields {
+			values[i] = record.Get(name)
+		}
+
+		key := fmt.Sprintf("%v", values)
+
+		if i, ok := index[key]; ok {
+			groups[i].Count += 1
+		} else {
+			index[key] = len(groups)
+			groups = append(groups, GroupCount{
+				Values: values,
+				Count:  1,
+			})
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+func nestGroupCounts(groups []GroupCount) map[string]interface{} {
+	counts := make(map[string]interface{})
+
+	for _, group := range groups {
+		current := counts
+
+		for i, value := range group.Values {
+			key := CountByNullKey
+
+			if value != nil {
+				key = typeutil.String(value)
+			}
+
+			if i == len(group.Values)-1 {
+				current[key] = typeutil.Int(current[key]) + int64(group.Count)
+			} else if next, ok := current[key].(map[string]interface{}); ok {
+				current = next
+			} else {
+				next = make(map[string]interface{})
+				current[key] = next
+				current = next
+			}
+		}
+	}
+
+	return counts
+}
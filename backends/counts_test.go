@@ -0,0 +1,56 @@
+package backends_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountBy(t *testing.T) {
+	assert := require.New(t)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	collection := dal.NewCollection(`people`, dal.Field{
+		Name: `state`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `city`,
+		Type: dal.StringType,
+	})
+
+	assert.NoError(backend.CreateCollection(collection))
+	assert.NoError(backend.Insert(`people`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`state`, `CA`).Set(`city`, `Los Angeles`),
+		dal.NewRecord(2).Set(`state`, `CA`).Set(`city`, `Los Angeles`),
+		dal.NewRecord(3).Set(`state`, `CA`).Set(`city`, `San Francisco`),
+		dal.NewRecord(4).Set(`state`, `NY`).Set(`city`, `New York`),
+		dal.NewRecord(5).Set(`state`, `NY`),
+	)))
+
+	counts, err := backends.CountBy(backend, collection, []string{`state`, `city`}, nil)
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{
+		`CA`: map[string]interface{}{
+			`Los Angeles`:   int64(2),
+			`San Francisco`: int64(1),
+		},
+		`NY`: map[string]interface{}{
+			`New York`:              int64(1),
+			backends.CountByNullKey: int64(1),
+		},
+	}, counts)
+
+	counts, err = backends.CountBy(backend, collection, []string{`state`}, filter.MustParse(`city/Los Angeles`))
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{
+		`CA`: int64(2),
+	}, counts)
+
+	_, err = backends.CountBy(backend, collection, []string{`zip`}, nil)
+	assert.Error(err)
+}
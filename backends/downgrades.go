@@ -0,0 +1,175 @@
+package backends
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// Describes an aspect of a field's definition that cannot be faithfully represented by a backend.
+type SchemaDowngrade struct {
+	Collection  string `json:"collection"`
+	Field       string `json:"field"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Reason      string `json:"reason"`
+}
+
+func (self SchemaDowngrade) String() string {
+	return fmt.Sprintf("%s.%s: %s -> %s (%s)", self.Collection, self.Field, self.Source, self.Destination, self.Reason)
+}
+
+// Implemented by backends that can report on the ways in which a collection's schema would be
+// degraded when stored in them.
+type SchemaDowngrader interface {
+	SchemaDowngrades(collection *dal.Collection) []SchemaDowngrade
+}
+
+// Returns all of the ways the given collection's schema would be degraded if it were copied to the
+// destination backend.  Wrapping backends are unwrapped until one that implements SchemaDowngrader
+// is found.  If none do, no downgrades are reported.
+func AnalyzeSchemaDowngrades(destination Backend, collection *dal.Collection) []SchemaDowngrade {
+	for _, backend := range BackendChain(destination) {
+		if downgrader, ok := backend.(SchemaDowngrader); ok {
+			return downgrader.SchemaDowngrades(collection)
+		}
+	}
+
+	return nil
+}
+
+func (self *SqlBackend) SchemaDowngrades(collection *dal.Collection) []SchemaDowngrade {
+	var downgrades = make([]SchemaDowngrade, 0)
+	var gen = self.makeQueryGen(nil)
+	var mapping = self.queryGenTypeMapping
+
+	for _, field := range collection.Fields {
+		if field.Identity || field.Name == collection.GetIdentityFieldName() {
+			continue
+		}
+
+		var native string
+
+		if nt, err := gen.ToNativeType(field.Type, []dal.Type{field.Subtype}, field.Length); err == nil {
+			native = nt
+		} else {
+			downgrades = append(downgrades, SchemaDowngrade{
+				Collection:  collection.Name,
+				Field:       field.Name,
+				Source:      fieldTypeDescription(&field),
+				Destination: `?`,
+				Reason:      err.Error(),
+			})
+
+			continue
+		}
+
+		var downgrade = SchemaDowngrade{
+			Collection:  collection.Name,
+			Field:       field.Name,
+			Source:      fieldTypeDescription(&field),
+			Destination: native,
+		}
+
+		switch field.Type {
+		case dal.StringType:
+			if field.Length > 0 {
+				if self.ignoresTypeLengths {
+					downgrade.Reason = fmt.Sprintf("length %d is not enforced", field.Length)
+				} else if !strings.Contains(native, fmt.Sprintf("(%d)", field.Length)) {
+					downgrade.Reason = fmt.Sprintf("length %d is not preserved", field.Length)
+				}
+			}
+
+		case dal.FloatType:
+			if field.Precision > 0 && field.Precision != mapping.FloatTypePrecision {
+				downgrade.Reason = fmt.Sprintf("precision %d is not preserved", field.Precision)
+			}
+
+		case dal.TimeType:
+			if strings.Contains(mapping.DateTimeType, `INT`) {
+				downgrade.Reason = `stored as an integer rather than a native date/time type`
+			}
+		}
+
+		if downgrade.Reason != `` {
+			downgrades = append(downgrades, downgrade)
+		}
+	}
+
+	return downgrades
+}
+
+func (self *DynamoBackend) SchemaDowngrades(collection *dal.Collection) []SchemaDowngrade {
+	var downgrades = make([]SchemaDowngrade, 0)
+
+	for _, field := range collection.Fields {
+		var downgrade = SchemaDowngrade{
+			Collection: collection.Name,
+			Field:      field.Name,
+			Source:     fieldTypeDescription(&field),
+		}
+
+		if field.Identity || field.Key || field.Name == collection.GetIdentityFieldName() {
+			// key attributes are typed, but DynamoDB only has strings, numbers, and binary
+			switch field.Type {
+			case dal.IntType:
+				downgrade.Destination = `N`
+				downgrade.Reason = `key is stored as a number and read back as a float`
+			case dal.TimeType, dal.ObjectType, dal.ArrayType:
+				downgrade.Destination = `S`
+				downgrade.Reason = `key is stored as a string`
+			}
+		} else {
+			var lost = make([]string, 0)
+
+			if field.Length > 0 {
+				lost = append(lost, `length`)
+			}
+
+			if field.Precision > 0 {
+				lost = append(lost, `precision`)
+			}
+
+			if field.Required {
+				lost = append(lost, `required`)
+			}
+
+			if field.Unique || field.UniqueGroup != `` {
+				lost = append(lost, `unique`)
+			}
+
+			downgrade.Destination = `attribute`
+
+			if len(lost) > 0 {
+				downgrade.Reason = fmt.Sprintf("schema is not stored for non-key attributes; %s not enforced", strings.Join(lost, `, `))
+			} else {
+				downgrade.Reason = `schema is not stored for non-key attributes`
+			}
+		}
+
+		if downgrade.Reason != `` {
+			downgrades = append(downgrades, downgrade)
+		}
+	}
+
+	return downgrades
+}
+
+// describe a field's type and constraints, e.g.: "string(255)", "float(10,2)"
+func fieldTypeDescription(field *dal.Field) string {
+	var desc = field.Type.String()
+
+	if field.Length > 0 {
+		if field.Precision > 0 {
+			desc += fmt.Sprintf("(%d,%d)", field.Length, field.Precision)
+		} else {
+			desc += fmt.Sprintf("(%d)", field.Length)
+		}
+	} else if field.Precision > 0 {
+		desc += fmt.Sprintf("(*,%d)", field.Precision)
+	}
+
+	return desc
+}
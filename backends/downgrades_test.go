@@ -0,0 +1,57 @@
+package backends_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeSchemaDowngrades(t *testing.T) {
+	assert := require.New(t)
+
+	collection := dal.NewCollection(`things`, dal.Field{
+		Name:   `name`,
+		Type:   dal.StringType,
+		Length: 32,
+	}, dal.Field{
+		Name:      `price`,
+		Type:      dal.FloatType,
+		Length:    12,
+		Precision: 2,
+	}, dal.Field{
+		Name: `created_at`,
+		Type: dal.TimeType,
+	}, dal.Field{
+		Name: `enabled`,
+		Type: dal.BooleanType,
+	})
+
+	sqlite := backends.NewSqlBackend(dal.MustParseConnectionString(`sqlite://temporary`))
+	downgrades := backends.AnalyzeSchemaDowngrades(sqlite, collection)
+	assert.Len(downgrades, 3)
+
+	assert.Equal(`name`, downgrades[0].Field)
+	assert.Equal(`str(32)`, downgrades[0].Source)
+	assert.Equal(`TEXT(32)`, downgrades[0].Destination)
+	assert.Equal(`length 32 is not enforced`, downgrades[0].Reason)
+
+	assert.Equal(`price`, downgrades[1].Field)
+	assert.Equal(`float(12,2)`, downgrades[1].Source)
+	assert.Equal(`precision 2 is not preserved`, downgrades[1].Reason)
+
+	assert.Equal(`created_at`, downgrades[2].Field)
+	assert.Equal(`INTEGER`, downgrades[2].Destination)
+
+	mysql := backends.NewSqlBackend(dal.MustParseConnectionString(`mysql://localhost/test`))
+	downgrades = backends.AnalyzeSchemaDowngrades(mysql, collection)
+	assert.Len(downgrades, 1)
+	assert.Equal(`price`, downgrades[0].Field)
+
+	// backends that don't know how to analyze schemata report nothing
+	memory := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	assert.Empty(backends.AnalyzeSchemaDowngrades(memory, collection))
+}
@@ -0,0 +1,128 @@
+package backends
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// The default number of records written per batch when dumping or restoring a collection.
+var DumpBatchSize = 1000
+
+// Streams the records in a collection matching the given filter (or all records if nil) to w as
+// newline-delimited JSON, flushing the output every batchSize records.  Returns the number of
+// records written.
+func Dump(backend Backend, name string, f *filter.Filter, w io.Writer, batchSize int) (int, error) {
+	var n int
+
+	if batchSize <= 0 {
+		batchSize = DumpBatchSize
+	}
+
+	if f == nil {
+		f = filter.All()
+	}
+
+	collection, err := backend.GetCollection(name)
+
+	if err != nil {
+		return 0, err
+	}
+
+	search := backend.WithSearch(collection, f)
+
+	if search == nil {
+		return 0, fmt.Errorf("collection %q is not enumerable", name)
+	}
+
+	output := bufio.NewWriter(w)
+	encoder := json.NewEncoder(output)
+
+	if err := search.QueryFunc(collection, f, func(record *dal.Record, err error, _ IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+
+		n += 1
+
+		if n%batchSize == 0 {
+			return output.Flush()
+		}
+
+		return nil
+	}); err != nil {
+		return n, err
+	}
+
+	return n, output.Flush()
+}
+
+// Reads newline-delimited JSON records from r (as written by Dump) and writes them to a collection
+// in batches of batchSize records.  If keyFields are given, records are upserted by those fields
+// (see UpsertByKey) instead of inserted.  Returns the number of records written.
+func Restore(backend Backend, name string, r io.Reader, batchSize int, keyFields ...string) (int, error) {
+	var n int
+	var line int
+
+	if batchSize <= 0 {
+		batchSize = DumpBatchSize
+	}
+
+	if _, err := backend.GetCollection(name); err != nil {
+		return 0, err
+	}
+
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	batch := dal.NewRecordSet()
+
+	flush := func() error {
+		if len(batch.Records) == 0 {
+			return nil
+		}
+
+		var err error
+
+		if len(keyFields) > 0 {
+			_, err = UpsertByKey(backend, name, keyFields, batch)
+		} else {
+			err = backend.Insert(name, batch)
+		}
+
+		if err == nil {
+			n += len(batch.Records)
+			batch = dal.NewRecordSet()
+		}
+
+		return err
+	}
+
+	for {
+		var record dal.Record
+
+		line += 1
+
+		if err := decoder.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return n, fmt.Errorf("record %d: %v", line, err)
+		}
+
+		batch.Push(&record)
+
+		if len(batch.Records) >= batchSize {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, flush()
+}
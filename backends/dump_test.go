@@ -0,0 +1,61 @@
+package backends_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpRestore(t *testing.T) {
+	assert := require.New(t)
+
+	people := func() *dal.Collection {
+		return dal.NewCollection(`people`, dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		})
+	}
+
+	source := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	assert.NoError(source.CreateCollection(people()))
+	assert.NoError(source.Insert(`people`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `Alice`),
+		dal.NewRecord(2).Set(`name`, `Bob`),
+		dal.NewRecord(3).Set(`name`, `Carol`),
+	)))
+
+	var buf bytes.Buffer
+
+	n, err := backends.Dump(source, `people`, nil, &buf, 2)
+	assert.NoError(err)
+	assert.Equal(3, n)
+	assert.Len(strings.Split(strings.TrimSpace(buf.String()), "\n"), 3)
+
+	destination := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	assert.NoError(destination.CreateCollection(people()))
+
+	n, err = backends.Restore(destination, `people`, &buf, 2)
+	assert.NoError(err)
+	assert.Equal(3, n)
+
+	record, err := destination.Retrieve(`people`, 2)
+	assert.NoError(err)
+	assert.Equal(`Bob`, record.Get(`name`))
+
+	// filtered dumps only include matching records
+	buf.Reset()
+
+	n, err = backends.Dump(source, `people`, filter.MustParse(`name/Carol`), &buf, 0)
+	assert.NoError(err)
+	assert.Equal(1, n)
+
+	_, err = backends.Restore(destination, `people`, strings.NewReader(`{"id": 4, "fields": {`), 0)
+	assert.Error(err)
+}
@@ -1,6 +1,7 @@
 package backends
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"strings"
@@ -41,11 +42,14 @@ func (self *DynamoBackend) Index(collection *dal.Collection, records *dal.Record
 }
 
 func (self *DynamoBackend) QueryFunc(collection *dal.Collection, flt *filter.Filter, resultFn IndexResultFunc) error {
+	return self.queryFunc(aws.BackgroundContext(), collection, flt, resultFn)
+}
+
+func (self *DynamoBackend) queryFunc(ctx context.Context, collection *dal.Collection, flt *filter.Filter, resultFn IndexResultFunc) error {
 	if err := self.validateFilter(collection, flt); err != nil {
 		return fmt.Errorf("Cannot validate filter: %v", err)
 	}
 
-	ctx := aws.BackgroundContext()
 	pageNumber := 0
 	var processed int
 
@@ -160,6 +164,34 @@ func (self *DynamoBackend) Query(collection *dal.Collection, f *filter.Filter, r
 	return DefaultQueryImplementation(self, collection, f, resultFns...)
 }
 
+// Query the collection, cancelling the Query or Scan requests once the context is done.  If the
+// backend has been given a separate indexer, the query is run there instead, and is abandoned
+// (rather than cancelled) once the context is done.
+func (self *DynamoBackend) QueryContext(ctx context.Context, collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if search := self.WithSearch(collection, f); search != nil && search != Indexer(self) {
+		return queryContext(ctx, search, collection, f, resultFns...)
+	}
+
+	if f != nil {
+		f.Options[`ForceIndexRecord`] = true
+	}
+
+	return DefaultQueryImplementation(&dynamoContextIndexer{
+		DynamoBackend: self,
+		ctx:           ctx,
+	}, collection, f, resultFns...)
+}
+
+// a view of a DynamoBackend whose queries are bound to a context
+type dynamoContextIndexer struct {
+	*DynamoBackend
+	ctx context.Context
+}
+
+func (self *dynamoContextIndexer) QueryFunc(collection *dal.Collection, flt *filter.Filter, resultFn IndexResultFunc) error {
+	return self.DynamoBackend.queryFunc(self.ctx, collection, flt, resultFn)
+}
+
 func (self *DynamoBackend) ListValues(collection *dal.Collection, fields []string, flt *filter.Filter) (map[string][]interface{}, error) {
 	if flt == nil {
 		flt = filter.All()
@@ -210,6 +242,10 @@ func (self *DynamoBackend) FlushIndex() error {
 
 func (self *DynamoBackend) validateFilter(collection *dal.Collection, flt *filter.Filter) error {
 	if flt != nil {
+		if len(flt.Groups) > 0 {
+			return fmt.Errorf("Grouped criteria are not supported")
+		}
+
 		for _, field := range flt.CriteriaFields() {
 			if collection.IsIdentityField(field) {
 				continue
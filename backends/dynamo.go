@@ -1,6 +1,7 @@
 package backends
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -181,11 +182,15 @@ func (self *DynamoBackend) Exists(name string, id interface{}) bool {
 }
 
 func (self *DynamoBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	return self.RetrieveContext(aws.BackgroundContext(), name, id, fields...)
+}
+
+func (self *DynamoBackend) RetrieveContext(ctx context.Context, name string, id interface{}, fields ...string) (*dal.Record, error) {
 	if collection, err := self.GetCollection(name); err == nil {
 		// get the key attributes that target this specific record
 		if _, keys, err := self.getKeyAttributes(name, id); err == nil {
 			// execute the GetItem request
-			if out, err := self.db.GetItem(&dynamodb.GetItemInput{
+			if out, err := self.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
 				TableName:      aws.String(name),
 				ConsistentRead: aws.Bool(self.cs.OptBool(`readsConsistent`, true)),
 				Key:            keys,
@@ -213,29 +218,41 @@ func (self *DynamoBackend) Retrieve(name string, id interface{}, fields ...strin
 }
 
 func (self *DynamoBackend) Insert(name string, records *dal.RecordSet) error {
+	return self.InsertContext(aws.BackgroundContext(), name, records)
+}
+
+func (self *DynamoBackend) InsertContext(ctx context.Context, name string, records *dal.RecordSet) error {
 	if collection, err := self.GetCollection(name); err == nil {
-		return self.upsertRecords(collection, records, true)
+		return self.upsertRecords(ctx, collection, records, true)
 	} else {
 		return err
 	}
 }
 
 func (self *DynamoBackend) Update(name string, records *dal.RecordSet, target ...string) error {
+	return self.UpdateContext(aws.BackgroundContext(), name, records, target...)
+}
+
+func (self *DynamoBackend) UpdateContext(ctx context.Context, name string, records *dal.RecordSet, target ...string) error {
 	if collection, err := self.GetCollection(name); err == nil {
-		return self.upsertRecords(collection, records, false)
+		return self.upsertRecords(ctx, collection, records, false)
 	} else {
 		return err
 	}
 }
 
 func (self *DynamoBackend) Delete(name string, ids ...interface{}) error {
+	return self.DeleteContext(aws.BackgroundContext(), name, ids...)
+}
+
+func (self *DynamoBackend) DeleteContext(ctx context.Context, name string, ids ...interface{}) error {
 	if _, err := self.GetCollection(name); err == nil {
 		// for each id we're deleting...
 		for _, id := range ids {
 			// get the key attributes that target this specific record
 			if _, keys, err := self.getKeyAttributes(name, id); err == nil {
 				// execute the DeleteItem request
-				if _, err := self.db.DeleteItem(&dynamodb.DeleteItemInput{
+				if _, err := self.db.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
 					TableName: aws.String(name),
 					Key:       keys,
 				}); err != nil {
@@ -271,6 +288,107 @@ func (self *DynamoBackend) CreateCollection(definition *dal.Collection) error {
 
 }
 
+// Creates a global secondary index for each secondary index declared on the given collection that
+// doesn't already exist on the table.  DynamoDB indexes consist of a hash key and an optional range
+// key, so indexes may cover at most two fields.
+func (self *DynamoBackend) EnsureSecondaryIndexes(collection *dal.Collection) error {
+	var indexes = collection.GetAllIndexes()
+
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	if out, err := self.db.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: aws.String(collection.Name),
+	}); err == nil {
+		var existing = make(map[string]bool)
+
+		for _, gsi := range out.Table.GlobalSecondaryIndexes {
+			existing[aws.StringValue(gsi.IndexName)] = true
+		}
+
+		for _, index := range indexes {
+			var name = index.GetName(collection.Name)
+
+			if existing[name] {
+				continue
+			} else if err := index.Validate(collection); err != nil {
+				return err
+			} else if len(index.Fields) > 2 {
+				return fmt.Errorf("index %q: DynamoDB indexes cannot cover more than two fields", name)
+			}
+
+			var attrs = make([]*dynamodb.AttributeDefinition, 0)
+			var keySchema = make([]*dynamodb.KeySchemaElement, 0)
+
+			for i, fieldName := range index.Fields {
+				var keyType = dynamodb.KeyTypeHash
+				var attrType = dynamodb.ScalarAttributeTypeS
+
+				if i > 0 {
+					keyType = dynamodb.KeyTypeRange
+				}
+
+				if field, ok := collection.GetField(fieldName); ok {
+					switch field.Type {
+					case dal.IntType, dal.FloatType:
+						attrType = dynamodb.ScalarAttributeTypeN
+					case dal.RawType:
+						attrType = dynamodb.ScalarAttributeTypeB
+					}
+				}
+
+				attrs = append(attrs, &dynamodb.AttributeDefinition{
+					AttributeName: aws.String(fieldName),
+					AttributeType: aws.String(attrType),
+				})
+
+				keySchema = append(keySchema, &dynamodb.KeySchemaElement{
+					AttributeName: aws.String(fieldName),
+					KeyType:       aws.String(keyType),
+				})
+			}
+
+			var create = &dynamodb.CreateGlobalSecondaryIndexAction{
+				IndexName: aws.String(name),
+				KeySchema: keySchema,
+				Projection: &dynamodb.Projection{
+					ProjectionType: aws.String(dynamodb.ProjectionTypeAll),
+				},
+			}
+
+			// tables with provisioned capacity require the same of their indexes
+			if bms := out.Table.BillingModeSummary; bms == nil || aws.StringValue(bms.BillingMode) != dynamodb.BillingModePayPerRequest {
+				if pt := out.Table.ProvisionedThroughput; pt != nil {
+					create.ProvisionedThroughput = &dynamodb.ProvisionedThroughput{
+						ReadCapacityUnits:  pt.ReadCapacityUnits,
+						WriteCapacityUnits: pt.WriteCapacityUnits,
+					}
+				}
+			}
+
+			log.Infof("[%v] creating global secondary index %q on %v", self, name, index.Fields)
+
+			if _, err := self.db.UpdateTable(&dynamodb.UpdateTableInput{
+				TableName:            aws.String(collection.Name),
+				AttributeDefinitions: attrs,
+				GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
+					{
+						Create: create,
+					},
+				},
+			}); err != nil {
+				return fmt.Errorf("index %q: %v", name, err)
+			}
+		}
+
+		self.tableCache.Delete(collection.Name)
+		return nil
+	} else {
+		return err
+	}
+}
+
 func (self *DynamoBackend) DeleteCollection(name string) error {
 	if _, err := self.GetCollection(name); err == nil {
 		if _, err := self.db.DeleteTable(&dynamodb.DeleteTableInput{
@@ -307,7 +425,7 @@ func (self *DynamoBackend) WithSearch(collection *dal.Collection, filters ...*fi
 
 func (self *DynamoBackend) WithAggregator(collection *dal.Collection) Aggregator {
 	if self.indexer != nil {
-		if agg, ok := self.indexer.(Aggregator); ok {
+		if agg, ok := underlyingIndexer(self.indexer).(Aggregator); ok {
 			return agg
 		}
 	}
@@ -560,8 +678,16 @@ func (self *DynamoBackend) getKeyAttributes(name string, id interface{}) (*filte
 	}
 }
 
-func (self *DynamoBackend) upsertRecords(collection *dal.Collection, records *dal.RecordSet, isCreate bool) error {
+func (self *DynamoBackend) upsertRecords(ctx context.Context, collection *dal.Collection, records *dal.RecordSet, isCreate bool) error {
+	if err := collection.FormatRecordSet(records, isCreate); err != nil {
+		return err
+	}
+
 	for _, record := range records.Records {
+		if err := collection.CheckUnknownFields(record); err != nil {
+			return err
+		}
+
 		if item, err := dynamoRecordToItem(collection, record); err == nil {
 			op := &dynamodb.PutItemInput{
 				TableName: aws.String(collection.Name),
@@ -587,7 +713,7 @@ func (self *DynamoBackend) upsertRecords(collection *dal.Collection, records *da
 			}
 
 			// perform the call
-			if _, err := self.db.PutItem(op); err != nil {
+			if _, err := self.db.PutItemWithContext(ctx, op); err != nil {
 				if aerr, ok := err.(awserr.Error); ok {
 					switch aerr.Code() {
 					case dynamodb.ErrCodeConditionalCheckFailedException:
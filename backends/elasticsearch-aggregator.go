@@ -8,10 +8,10 @@ import (
 
 	"github.com/ghetzel/go-stockutil/httputil"
 	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/go-stockutil/sliceutil"
 	"github.com/ghetzel/go-stockutil/typeutil"
 	"github.com/ghetzel/pivot/v3/dal"
 	"github.com/ghetzel/pivot/v3/filter"
-	"github.com/ghetzel/pivot/v3/filter/generators"
 )
 
 type esAggregationQuery struct {
@@ -36,7 +36,7 @@ func (self *ElasticsearchIndexer) Count(collection *dal.Collection, flt ...*filt
 	}
 
 	if query, err := filter.Render(
-		generators.NewElasticsearchGenerator(),
+		esGenerator(collection),
 		collection.GetAggregatorName(),
 		f,
 	); err == nil {
@@ -87,6 +87,107 @@ func (self *ElasticsearchIndexer) GroupBy(collection *dal.Collection, groupBy []
 	}
 }
 
+// The number of buckets requested per page of a composite aggregation.
+var ElasticsearchCompositePageSize = 1000
+
+// Counts records grouped by the distinct values of the given fields using a composite aggregation,
+// paging through the buckets until all groups have been retrieved.
+func (self *ElasticsearchIndexer) CountBy(collection *dal.Collection, fields []string, flt ...*filter.Filter) ([]GroupCount, error) {
+	var f *filter.Filter
+	var after map[string]interface{}
+
+	if len(flt) > 0 {
+		f = flt[0]
+	}
+
+	sources := make([]map[string]interface{}, len(fields))
+
+	for i, field := range fields {
+		sources[i] = map[string]interface{}{
+			field: map[string]interface{}{
+				`terms`: map[string]interface{}{
+					`field`:          field,
+					`missing_bucket`: true,
+				},
+			},
+		}
+	}
+
+	query, err := filter.Render(esGenerator(collection), collection.GetAggregatorName(), f)
+
+	if err != nil {
+		return nil, fmt.Errorf("filter error: %v", err)
+	}
+
+	var esFilter map[string]interface{}
+
+	if err := json.Unmarshal(query, &esFilter); err != nil {
+		return nil, fmt.Errorf("filter encode error: %v", err)
+	}
+
+	groups := make([]GroupCount, 0)
+
+	for {
+		composite := map[string]interface{}{
+			`size`:    ElasticsearchCompositePageSize,
+			`sources`: sources,
+		}
+
+		if after != nil {
+			composite[`after`] = after
+		}
+
+		aggs := esAggregationQuery{
+			Aggregations: map[string]esAggregation{
+				`counts`: {
+					`composite`: composite,
+				},
+			},
+			Query: maputil.M(esFilter).Get(`query`).MapNative(),
+		}
+
+		if response, err := self.client.GetWithBody(
+			fmt.Sprintf("/%s/_search", collection.GetAggregatorName()),
+			&aggs,
+			nil,
+			nil,
+		); err == nil {
+			var output = make(map[string]interface{})
+
+			if err := self.client.Decode(response.Body, &output); err != nil {
+				return nil, fmt.Errorf("response decode error: %v", err)
+			}
+
+			result := maputil.M(output)
+			buckets := sliceutil.Sliceify(result.Get(`aggregations.counts.buckets`).Value)
+
+			for _, bucket := range buckets {
+				key := maputil.M(bucket).Get(`key`).MapNative()
+				values := make([]interface{}, len(fields))
+
+				for i, field := range fields {
+					values[i] = key[field]
+				}
+
+				groups = append(groups, GroupCount{
+					Values: values,
+					Count:  uint64(typeutil.Int(maputil.M(bucket).Get(`doc_count`).Value)),
+				})
+			}
+
+			if afterKey := result.Get(`aggregations.counts.after_key`).MapNative(); len(buckets) > 0 && len(afterKey) > 0 {
+				after = afterKey
+			} else {
+				break
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	return groups, nil
+}
+
 func (self *ElasticsearchIndexer) aggregateFloat(collection *dal.Collection, aggregation filter.Aggregation, field string, flt []*filter.Filter) (float64, error) {
 	if result, err := self.aggregate(collection, nil, []filter.Aggregate{
 		{
@@ -125,7 +226,7 @@ func (self *ElasticsearchIndexer) aggregate(collection *dal.Collection, groupBy
 	}
 
 	if query, err := filter.Render(
-		generators.NewElasticsearchGenerator(),
+		esGenerator(collection),
 		collection.GetAggregatorName(),
 		f,
 	); err == nil {
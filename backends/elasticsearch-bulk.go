@@ -0,0 +1,208 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/httputil"
+	"github.com/ghetzel/go-stockutil/log"
+)
+
+// The largest payload (in bytes) sent in a single bulk indexing request.  Larger batches are split
+// across several requests.  This can be changed for a specific connection with the "bulk_max_bytes"
+// option.
+var ElasticsearchBulkMaxBytes = 5 * 1024 * 1024
+
+// The smallest payload budget that bulk requests are reduced to when Elasticsearch is struggling.
+var ElasticsearchBulkMinBytes = 64 * 1024
+
+// Bulk requests that take longer than this shrink the payload budget of subsequent requests;
+// those that finish in under half this time let it grow back towards ElasticsearchBulkMaxBytes.
+var ElasticsearchBulkTargetLatency = time.Second
+
+// How many times operations rejected because Elasticsearch is too busy (HTTP 429) are retried.
+var ElasticsearchBulkRetries = 3
+
+// How long to wait before first retrying rejected operations.  Each subsequent retry waits longer.
+var ElasticsearchBulkRetryDelay = 500 * time.Millisecond
+
+type elasticsearchBulkResponse struct {
+	Took   int                                  `json:"took"`
+	Errors bool                                 `json:"errors"`
+	Items  []map[string]elasticsearchBulkResult `json:"items"`
+}
+
+type elasticsearchBulkResult struct {
+	Status int `json:"status"`
+}
+
+// Adapts the payload budget of bulk requests based on how Elasticsearch responds to them:
+// requests that are rejected as too large or too numerous, or that are slow to complete, shrink
+// the budget, and those that complete quickly grow it back.
+type esBulkSizer struct {
+	budget int
+	max    int
+	lock   sync.Mutex
+}
+
+func newBulkSizer(max int) *esBulkSizer {
+	if max < ElasticsearchBulkMinBytes {
+		max = ElasticsearchBulkMinBytes
+	}
+
+	return &esBulkSizer{
+		budget: max,
+		max:    max,
+	}
+}
+
+// Return the number of bytes the next bulk request should contain.
+func (self *esBulkSizer) Budget() int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	return self.budget
+}
+
+// adjust the budget given the size of a request, how long it took, and the HTTP status it failed
+// with (if any)
+func (self *esBulkSizer) observe(size int, took time.Duration, status int) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	switch status {
+	case http.StatusRequestEntityTooLarge:
+		if size < self.budget {
+			self.budget = size
+		}
+
+		self.budget /= 2
+	case http.StatusTooManyRequests:
+		self.budget /= 2
+	case 0:
+		if took > ElasticsearchBulkTargetLatency {
+			self.budget = self.budget * 3 / 4
+		} else if took < ElasticsearchBulkTargetLatency/2 && size >= self.budget*3/4 {
+			self.budget = self.budget * 5 / 4
+		}
+	}
+
+	if self.budget < ElasticsearchBulkMinBytes {
+		self.budget = ElasticsearchBulkMinBytes
+	} else if self.budget > self.max {
+		self.budget = self.max
+	}
+}
+
+// send the given operations (each the NDJSON for a single bulk operation) in as many requests as
+// it takes to stay within the payload budget
+func (self *ElasticsearchIndexer) sendBulk(ops []string) error {
+	var merr error
+	var chunk []string
+	var size int
+
+	for _, op := range ops {
+		if len(chunk) > 0 && size+len(op) > self.bulkSizer.Budget() {
+			merr = log.AppendError(merr, self.postBulk(chunk, 0))
+			chunk = nil
+			size = 0
+		}
+
+		chunk = append(chunk, op)
+		size += len(op)
+	}
+
+	if len(chunk) > 0 {
+		merr = log.AppendError(merr, self.postBulk(chunk, 0))
+	}
+
+	return merr
+}
+
+// post a single bulk request, splitting it in half if it is too large and retrying the operations
+// Elasticsearch was too busy to perform
+func (self *ElasticsearchIndexer) postBulk(ops []string, attempt int) error {
+	var body = strings.Join(ops, ``)
+	var started = time.Now()
+
+	response, err := self.client.Post(
+		`/_bulk`,
+		httputil.Literal(body),
+		map[string]interface{}{
+			`refresh`: self.refresh,
+		},
+		nil,
+	)
+
+	var took = time.Since(started)
+
+	if err != nil {
+		var status int
+
+		if eserr, ok := err.(*elasticsearchError); ok {
+			status = eserr.StatusCode
+		}
+
+		self.bulkSizer.observe(len(body), took, status)
+
+		switch status {
+		case http.StatusRequestEntityTooLarge:
+			if len(ops) > 1 {
+				querylog.Debugf("[%T] bulk request of %d bytes was too large, splitting it", self, len(body))
+
+				return log.AppendError(
+					self.postBulk(ops[:len(ops)/2], attempt),
+					self.postBulk(ops[len(ops)/2:], attempt),
+				)
+			}
+		case http.StatusTooManyRequests:
+			if attempt < ElasticsearchBulkRetries {
+				time.Sleep(ElasticsearchBulkRetryDelay * time.Duration(attempt+1))
+				return self.postBulk(ops, attempt+1)
+			}
+		}
+
+		return err
+	}
+
+	defer response.Body.Close()
+
+	var result elasticsearchBulkResponse
+
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		self.bulkSizer.observe(len(body), took, 0)
+		return nil
+	}
+
+	// individual operations may be rejected when the cluster's write queues are full, in which
+	// case only those operations are retried
+	var rejected []string
+
+	if result.Errors {
+		for i, item := range result.Items {
+			for _, res := range item {
+				if res.Status == http.StatusTooManyRequests && i < len(ops) {
+					rejected = append(rejected, ops[i])
+				}
+			}
+		}
+	}
+
+	if len(rejected) > 0 {
+		self.bulkSizer.observe(len(body), took, http.StatusTooManyRequests)
+
+		if attempt < ElasticsearchBulkRetries {
+			time.Sleep(ElasticsearchBulkRetryDelay * time.Duration(attempt+1))
+			return self.postBulk(rejected, attempt+1)
+		}
+
+		return fmt.Errorf("%d operation(s) rejected after %d retries", len(rejected), attempt)
+	}
+
+	self.bulkSizer.observe(len(body), took, 0)
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -57,6 +58,7 @@ func (self *elasticsearchDocument) record(collection *dal.Collection, sep string
 			return nil, err
 		}
 
+		record.Score = self.Score
 		return record, nil
 	} else {
 		return nil, fmt.Errorf("%v: expected %d key values, got %d", self, collection.KeyCount(), len(ids))
@@ -167,8 +169,9 @@ func (self *esDeferredBatch) Add(op bulkOperation) {
 	self.batch = append(self.batch, op)
 }
 
-func (self *esDeferredBatch) Flush() ([]map[string]interface{}, error) {
-	var rv []map[string]interface{}
+// Empty the batch, returning the NDJSON body of each of its operations.
+func (self *esDeferredBatch) Flush() ([]string, error) {
+	var rv []string
 
 	self.batchLock.Lock()
 
@@ -180,7 +183,17 @@ func (self *esDeferredBatch) Flush() ([]map[string]interface{}, error) {
 
 	for _, op := range self.batch {
 		if body, err := op.GetBody(); err == nil {
-			rv = append(rv, body...)
+			var lines string
+
+			for _, line := range body {
+				if b, err := json.Marshal(line); err == nil {
+					lines += string(b) + "\n"
+				} else {
+					return nil, err
+				}
+			}
+
+			rv = append(rv, lines)
 		} else {
 			return nil, err
 		}
@@ -195,6 +208,7 @@ type ElasticsearchIndexer struct {
 	parent             Backend
 	indexCache         map[string]*elasticsearchIndex
 	indexDeferredBatch *esDeferredBatch
+	bulkSizer          *esBulkSizer
 	client             *httputil.Client
 	refresh            string
 	pkSeparator        string
@@ -205,6 +219,7 @@ func NewElasticsearchIndexer(connection dal.ConnectionString) *ElasticsearchInde
 		conn:               &connection,
 		indexCache:         make(map[string]*elasticsearchIndex),
 		indexDeferredBatch: new(esDeferredBatch),
+		bulkSizer:          newBulkSizer(int(connection.OptInt(`bulk_max_bytes`, int64(ElasticsearchBulkMaxBytes)))),
 		refresh:            ElasticsearchDefaultRefresh,
 		pkSeparator:        ElasticsearchDefaultCompositeJoiner,
 	}
@@ -325,27 +340,10 @@ func (self *ElasticsearchIndexer) checkAndFlushBatches(forceFlush bool) {
 		if shouldFlush {
 			defer stats.NewTiming().Send(`pivot.indexers.elasticsearch.deferred_batch_flush`)
 
-			if bulkBody, err := self.indexDeferredBatch.Flush(); err == nil {
-				querylog.Debugf("[%T] Indexing %d records to %s", self, l)
-
-				var lines []string
-
-				for _, bb := range bulkBody {
-					if b, err := json.Marshal(bb); err == nil {
-						lines = append(lines, string(b))
-					}
-				}
+			if ops, err := self.indexDeferredBatch.Flush(); err == nil {
+				querylog.Debugf("[%T] Indexing %d records", self, l)
 
-				if _, err := self.client.Post(
-					`/_bulk`,
-					httputil.Literal(
-						strings.Join(lines, "\n")+"\n",
-					),
-					map[string]interface{}{
-						`refresh`: self.refresh,
-					},
-					nil,
-				); err != nil {
+				if err := self.sendBulk(ops); err != nil {
 					log.Errorf("[%T] error indexing %d records: %v", self, l, err)
 				}
 			} else {
@@ -382,12 +380,19 @@ func (self *ElasticsearchIndexer) QueryFunc(collection *dal.Collection, f *filte
 		var processed int
 		var originalLimit = f.Limit
 		var originalOffset = f.Offset
+		var originalAfter = f.After
 		var isFirstScrollRequest = true
 		var page = 1
 
 		// shortpath for queries that specify both components of a composite key and nothing else
 		if ckeys := self.compositeKeyId(collection, f, self.pkSeparator); ckeys != `` {
 			if record, err := self.IndexRetrieve(collection, ckeys); err == nil {
+				if record, err = HydrateIndexRecord(self, collection, f, record); err != nil {
+					return err
+				} else if record == nil {
+					return nil
+				}
+
 				return resultFn(record, nil, IndexPage{
 					Page:         1,
 					TotalPages:   1,
@@ -400,9 +405,16 @@ func (self *ElasticsearchIndexer) QueryFunc(collection *dal.Collection, f *filte
 			}
 		}
 
+		// cursor-paginated requests page through results using search_after, and so are never
+		// subject to the 10k result window.
+		//
 		// unbounded requests, or bounded ones exceeding 10k results, need to use the Scroll API
 		// see: https://www.elastic.co/guide/en/elasticsearch/reference/current/search-request-scroll.html
-		if f.Limit == 0 || f.Limit > 10000 {
+		if f.After != nil {
+			if f.Limit == 0 || f.Limit > IndexerPageSize {
+				f.Limit = IndexerPageSize
+			}
+		} else if f.Limit == 0 || f.Limit > 10000 {
 			f.Limit = IndexerPageSize
 			useScrollApi = true
 		} else if f.Limit > IndexerPageSize {
@@ -412,12 +424,13 @@ func (self *ElasticsearchIndexer) QueryFunc(collection *dal.Collection, f *filte
 		defer func() {
 			f.Offset = originalOffset
 			f.Limit = originalLimit
+			f.After = originalAfter
 		}()
 
 		// perform requests until we have enough results or the index is out of them
 		for {
 			if query, err := filter.Render(
-				generators.NewElasticsearchGenerator(),
+				esGenerator(collection),
 				index.Name,
 				f,
 			); err == nil {
@@ -466,6 +479,13 @@ func (self *ElasticsearchIndexer) QueryFunc(collection *dal.Collection, f *filte
 						// call the resultFn for each hit on this page
 						for _, hit := range results.Hits {
 							if record, err := hit.record(collection, self.pkSeparator); err == nil {
+								if record, err = HydrateIndexRecord(self, collection, f, record); err != nil {
+									return err
+								} else if record == nil {
+									// stale index document; the record is gone from the backend
+									continue
+								}
+
 								if err := resultFn(record, nil, IndexPage{
 									Page:         page,
 									TotalPages:   totalPages,
@@ -492,6 +512,11 @@ func (self *ElasticsearchIndexer) QueryFunc(collection *dal.Collection, f *filte
 						page += 1
 						f.Offset += len(results.Hits)
 
+						// resume the next page after the last document we've seen
+						if f.After != nil {
+							f.After = results.Hits[len(results.Hits)-1].ID
+						}
+
 						// if the offset is now beyond the total results count
 						if int64(processed) >= results.Total {
 							querylog.Debugf("[%T] %d at or beyond total %d, returning results", self, processed, results.Total)
@@ -512,6 +537,14 @@ func (self *ElasticsearchIndexer) QueryFunc(collection *dal.Collection, f *filte
 	}
 }
 
+// Returns whether the given filter can be expressed as an Elasticsearch query.
+func (self *ElasticsearchIndexer) CanQuery(collection *dal.Collection, f *filter.Filter) bool {
+	var flt = filter.Copy(f)
+
+	_, err := filter.Render(esGenerator(collection), collection.GetIndexName(), &flt)
+	return err == nil
+}
+
 func (self *ElasticsearchIndexer) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
 	var recordset = dal.NewRecordSet()
 
@@ -575,7 +608,7 @@ func (self *ElasticsearchIndexer) ListValues(collection *dal.Collection, fields
 		}
 
 		if query, err := filter.Render(
-			generators.NewElasticsearchGenerator(),
+			esGenerator(collection),
 			index.Name,
 			f,
 		); err == nil {
@@ -637,6 +670,19 @@ func (self *ElasticsearchIndexer) FlushIndex() error {
 	return nil
 }
 
+// Flush pending writes and refresh the collection's index so that they are immediately visible
+// to searches.
+func (self *ElasticsearchIndexer) RefreshIndex(collection *dal.Collection) error {
+	self.checkAndFlushBatches(true)
+
+	if index, err := self.getIndexForCollection(collection); err == nil {
+		_, err := self.client.Post(fmt.Sprintf("/%s/_refresh", index.Name), nil, nil, nil)
+		return err
+	} else {
+		return err
+	}
+}
+
 func (self *ElasticsearchIndexer) getIndexForCollection(collection *dal.Collection) (*elasticsearchIndex, error) {
 	defer stats.NewTiming().Send(`pivot.indexers.elasticsearch.retrieve_index`)
 	var name = collection.GetIndexName()
@@ -670,7 +716,7 @@ func (self *ElasticsearchIndexer) getIndexForCollection(collection *dal.Collecti
 }
 
 func (self *ElasticsearchIndexer) compositeKeyId(collection *dal.Collection, flt *filter.Filter, sep string) string {
-	if flt != nil && len(flt.Criteria) == collection.KeyCount() {
+	if flt != nil && len(flt.Groups) == 0 && len(flt.Criteria) == collection.KeyCount() {
 		var parts []string
 
 		for _, crit := range flt.Criteria {
@@ -688,3 +734,22 @@ func (self *ElasticsearchIndexer) compositeKeyId(collection *dal.Collection, flt
 
 	return ``
 }
+
+// returns a query generator that matches full-text criteria against the collection's search fields
+func esGenerator(collection *dal.Collection) *generators.Elasticsearch {
+	var gen = generators.NewElasticsearchGenerator()
+	var fields = make([]string, 0, len(collection.SearchFields))
+
+	for _, sf := range collection.SearchFields {
+		var name = sf.GetSearchPath(collection)
+
+		if boost := sf.GetBoost(); boost != 1 {
+			name += `^` + strconv.FormatFloat(boost, 'f', -1, 64)
+		}
+
+		fields = append(fields, name)
+	}
+
+	gen.SetSearchFields(fields...)
+	return gen
+}
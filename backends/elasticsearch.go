@@ -343,7 +343,18 @@ func (self *ElasticsearchBackend) CreateCollection(definition *dal.Collection) e
 	}
 
 	for _, field := range definition.Fields {
-		index.Mappings.Properties[field.Name] = fieldTypeToEsMapping(field.Type)
+		var mapping = fieldTypeToEsMapping(field.Type)
+
+		// string fields that are searchable get an analyzed copy for full-text queries to run against
+		if sf, ok := definition.GetSearchField(field.Name); ok && sf.GetSearchPath(definition) != field.Name {
+			mapping[`fields`] = map[string]interface{}{
+				strings.TrimPrefix(dal.SearchFieldSuffix, `.`): map[string]interface{}{
+					`type`: `text`,
+				},
+			}
+		}
+
+		index.Mappings.Properties[field.Name] = mapping
 	}
 
 	index.Mappings.Properties[definition.GetIdentityFieldName()] = fieldTypeToEsMapping(definition.IdentityFieldType)
@@ -393,7 +404,7 @@ func (self *ElasticsearchBackend) WithSearch(collection *dal.Collection, filters
 
 func (self *ElasticsearchBackend) WithAggregator(collection *dal.Collection) Aggregator {
 	if self.indexer != nil {
-		if agg, ok := self.indexer.(Aggregator); ok {
+		if agg, ok := underlyingIndexer(self.indexer).(Aggregator); ok {
 			return agg
 		}
 	}
@@ -453,6 +464,10 @@ func (self *ElasticsearchBackend) cacheIndex(name string) (*dal.Collection, erro
 }
 
 func (self *ElasticsearchBackend) upsertRecords(collection *dal.Collection, records *dal.RecordSet, isCreate bool) error {
+	if err := collection.FormatRecordSet(records, isCreate); err != nil {
+		return err
+	}
+
 	for _, record := range records.Records {
 		if r, err := collection.StructToRecord(record); err == nil {
 			record = r
@@ -508,6 +523,12 @@ func esErrorDecoder(res *http.Response) error {
 				if eserr.StatusCode > 0 {
 					return &eserr
 				}
+			} else if res.StatusCode >= 400 {
+				// errors from proxies in front of Elasticsearch (e.g.: 413 Request Entity Too Large)
+				// aren't JSON, but their status is still worth reporting
+				return &elasticsearchError{
+					StatusCode: res.StatusCode,
+				}
 			} else {
 				return fmt.Errorf("elastic error decode: %v", err)
 			}
@@ -544,6 +565,10 @@ func fieldTypeToEsMapping(ftype dal.Type) map[string]interface{} {
 		return map[string]interface{}{
 			`type`: `nested`,
 		}
+	case dal.GeopointType:
+		return map[string]interface{}{
+			`type`: `geo_point`,
+		}
 	default:
 		return map[string]interface{}{
 			`type`:       `keyword`,
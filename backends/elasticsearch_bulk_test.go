@@ -0,0 +1,120 @@
+package backends
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElasticsearchBulkSizer(t *testing.T) {
+	assert := require.New(t)
+
+	defer func(min int) {
+		ElasticsearchBulkMinBytes = min
+	}(ElasticsearchBulkMinBytes)
+
+	ElasticsearchBulkMinBytes = 100
+
+	sizer := newBulkSizer(1000)
+	assert.Equal(1000, sizer.Budget())
+
+	// too-large requests shrink the budget below the size that was rejected
+	sizer.observe(800, time.Millisecond, http.StatusRequestEntityTooLarge)
+	assert.Equal(400, sizer.Budget())
+
+	// fast, full requests let it grow back, but never beyond the maximum
+	sizer.observe(400, time.Millisecond, 0)
+	assert.Equal(500, sizer.Budget())
+
+	for i := 0; i < 10; i++ {
+		sizer.observe(sizer.Budget(), time.Millisecond, 0)
+	}
+
+	assert.Equal(1000, sizer.Budget())
+
+	// slow requests and rejections shrink it, but never below the minimum
+	sizer.observe(1000, 2*ElasticsearchBulkTargetLatency, 0)
+	assert.Equal(750, sizer.Budget())
+
+	for i := 0; i < 10; i++ {
+		sizer.observe(sizer.Budget(), time.Millisecond, http.StatusTooManyRequests)
+	}
+
+	assert.Equal(100, sizer.Budget())
+}
+
+func TestElasticsearchBulkSplitting(t *testing.T) {
+	assert := require.New(t)
+
+	defer func(min int) {
+		ElasticsearchBulkMinBytes = min
+	}(ElasticsearchBulkMinBytes)
+
+	ElasticsearchBulkMinBytes = 10
+
+	var lock sync.Mutex
+	var received []string
+	var requests int
+
+	// a server that rejects any request over 250 bytes
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		requests += 1
+
+		if len(body) > 250 {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte(`<html>Request Entity Too Large</html>`))
+			return
+		}
+
+		var items []string
+		var scanner = bufio.NewScanner(strings.NewReader(string(body)))
+
+		for scanner.Scan() {
+			received = append(received, scanner.Text())
+			items = append(items, `{"index":{"status":201}}`)
+		}
+
+		fmt.Fprintf(w, `{"took":1,"errors":false,"items":[%s]}`, strings.Join(items, `,`))
+	}))
+
+	defer server.Close()
+
+	indexer := NewElasticsearchIndexer(dal.MustParseConnectionString(
+		`elasticsearch://` + strings.TrimPrefix(server.URL, `http://`) + `/?bulk_max_bytes=1000`,
+	))
+
+	assert.NoError(indexer.IndexInitialize(nil))
+
+	var ops []string
+
+	for i := 0; i < 20; i++ {
+		ops = append(ops, fmt.Sprintf("{\"delete\":{\"_id\":%d,\"_index\":\"things\",\"_type\":\"_doc\"}}\n", i))
+	}
+
+	// every operation arrives, despite the initial budget being too large for the server
+	assert.NoError(indexer.sendBulk(ops))
+	assert.Len(received, 20)
+	assert.True(indexer.bulkSizer.Budget() < 1000)
+
+	// ...and subsequent batches are split up from the start
+	received = nil
+	requests = 0
+
+	assert.NoError(indexer.sendBulk(ops))
+	assert.Len(received, 20)
+	assert.True(requests >= 4)
+}
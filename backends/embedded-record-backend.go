@@ -1,24 +1,49 @@
 package backends
 
 import (
+	"encoding/json"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
 	"github.com/ghetzel/pivot/v3/dal"
 	"github.com/ghetzel/pivot/v3/filter"
 )
 
+// The maximum depth that EmbeddedRecordBackends expand related records to by default.
+var DefaultEmbedMaxDepth = 4
+
+// The number of records that EmbeddedRecordBackend.QueryFunc buffers by default so that the
+// related records of all of them can be retrieved together.
+var DefaultEmbedBatchSize = 100
+
+// The most related records (and bytes of them) that EmbeddedRecordBackends embed at each key of a
+// record by default, for relationships that don't specify their own limits.  Zero is unlimited.
+var DefaultEmbedMaxRecords = 0
+var DefaultEmbedMaxBytes = 0
+
 type EmbeddedRecordBackend struct {
-	SkipKeys []string
-	backend  Backend
-	indexer  Indexer
-	cache    sync.Map
+	SkipKeys           []string
+	Expand             []string // if set, only the relationships named by these paths are expanded (unless a query's filter specifies its own)
+	MaxDepth           int      // how many levels deep related records are expanded (0 is unlimited)
+	BatchSize          int      // how many query results are expanded at a time
+	MaxEmbeddedRecords int      // the most related records embedded at each key, unless the relationship sets its own limit (0 is unlimited)
+	MaxEmbeddedBytes   int      // the most bytes of related records embedded at each key, unless the relationship sets its own limit (0 is unlimited)
+	backend            Backend
+	indexer            Indexer
+	cache              sync.Map
 }
 
 func NewEmbeddedRecordBackend(parent Backend, skipKeys ...string) *EmbeddedRecordBackend {
 	backend := &EmbeddedRecordBackend{
-		SkipKeys: skipKeys,
-		backend:  parent,
+		SkipKeys:           skipKeys,
+		MaxDepth:           DefaultEmbedMaxDepth,
+		BatchSize:          DefaultEmbedBatchSize,
+		MaxEmbeddedRecords: DefaultEmbedMaxRecords,
+		MaxEmbeddedBytes:   DefaultEmbedMaxBytes,
+		backend:            parent,
 	}
 
 	if indexer := parent.WithSearch(nil); indexer != nil {
@@ -40,6 +65,210 @@ func (self *EmbeddedRecordBackend) EmbedRelationships(collection *dal.Collection
 	}
 }
 
+// Embeds the related records of the given records, all of which belong to the given collection.
+// The records related from each collection are retrieved together, and are themselves expanded
+// (up to MaxDepth levels deep).  If paths are given, only the relationships they name are
+// expanded (e.g.: "items.item_id" expands "items", then "item_id" in each of the embedded items);
+// otherwise, the backend's Expand paths are used, and if there are none, all relationships not
+// in SkipKeys are expanded.  Records from a collection are never embedded within records from
+// the same collection, so cyclic relationships terminate.
+//
+// Keys holding many IDs embed at most MaxEmbeddedRecords related records, and at most
+// MaxEmbeddedBytes of them (unless their relationships specify other limits); the records left
+// out are described in each record's dal.EmbedTruncationField so clients can retrieve them
+// separately.
+func (self *EmbeddedRecordBackend) ExpandRecords(collection *dal.Collection, paths []string, records []*dal.Record, fields ...string) error {
+	var expand []string
+
+	if len(paths) > 0 {
+		expand = paths
+	} else if len(self.Expand) > 0 {
+		expand = self.Expand
+	}
+
+	return self.expandRecords(collection, records, expand, nil, 1, fields)
+}
+
+// records related by a given key, collected so that they can be expanded together
+type embeddedChildren struct {
+	collection *dal.Collection
+	expand     []string
+	expandAll  bool
+	records    []*dal.Record
+	seen       map[string]bool
+}
+
+func (self *EmbeddedRecordBackend) expandRecords(collection *dal.Collection, records []*dal.Record, expand []string, ancestors []string, depth int, fields []string) error {
+	if collection == nil || len(records) == 0 {
+		return nil
+	} else if self.MaxDepth > 0 && depth > self.MaxDepth {
+		return nil
+	}
+
+	var embeddedKeys = make(map[string]*dal.Collection)
+	var keyOrder = make([]string, 0)
+	var byteLimits = make(map[string]int)
+
+	var options = embedOptions{
+		SkipKeys:   self.SkipKeys,
+		Expand:     expand,
+		Exclude:    append(append([]string{}, ancestors...), collection.Name),
+		MaxRecords: self.MaxEmbeddedRecords,
+		OnEmbed: func(key string, related *dal.Collection, relationship dal.Relationship) {
+			if _, ok := embeddedKeys[key]; !ok {
+				embeddedKeys[key] = related
+				keyOrder = append(keyOrder, key)
+
+				if limit := embedLimit(relationship.MaxBytes, self.MaxEmbeddedBytes); limit > 0 {
+					byteLimits[key] = limit
+				}
+			}
+		},
+	}
+
+	for _, record := range records {
+		if err := populateRelationships(self.backend, collection, record, nil, options, fields...); err != nil {
+			return err
+		}
+	}
+
+	if len(embeddedKeys) == 0 {
+		return nil
+	}
+
+	// retrieve all of the related records of each collection at once
+	if err := ResolveDeferredRecords(nil, records...); err != nil {
+		return err
+	}
+
+	// gather up the records that were just embedded, grouped by collection, so that each
+	// collection's records can be expanded together
+	var children = make(map[string]*embeddedChildren)
+	var childOrder = make([]string, 0)
+
+	for _, key := range keyOrder {
+		var related = embeddedKeys[key]
+		var group, ok = children[related.Name]
+
+		if !ok {
+			group = &embeddedChildren{
+				collection: related,
+				seen:       make(map[string]bool),
+			}
+
+			children[related.Name] = group
+			childOrder = append(childOrder, related.Name)
+		}
+
+		if expand == nil {
+			group.expandAll = true
+		} else {
+			group.expand = append(group.expand, expandSubpaths(expand, key)...)
+		}
+
+		for _, record := range records {
+			var values []interface{}
+
+			if v := record.GetNested(key); typeutil.IsArray(v) {
+				values = sliceutil.Sliceify(v)
+			} else if v != nil {
+				values = []interface{}{v}
+			}
+
+			for _, value := range values {
+				if data, ok := value.(map[string]interface{}); ok {
+					if missing, _ := data[`_missing`].(bool); missing {
+						continue
+					}
+
+					var id = data[related.GetIdentityFieldName()]
+					var idKey = fmt.Sprintf("%v", id)
+
+					if !group.seen[idKey] {
+						group.seen[idKey] = true
+						group.records = append(group.records, dal.NewRecord(id, data))
+					}
+				}
+			}
+		}
+	}
+
+	// embedded records share their field data with the records they're embedded in, so expanding
+	// them in turn fills in the nested relationships in place
+	for _, name := range childOrder {
+		var group = children[name]
+		var subexpand []string
+
+		if !group.expandAll {
+			if len(group.expand) == 0 {
+				continue
+			}
+
+			subexpand = group.expand
+		}
+
+		if err := self.expandRecords(group.collection, group.records, subexpand, options.Exclude, depth+1, nil); err != nil {
+			return err
+		}
+	}
+
+	// now that the embedded records are complete, leave out those past the size limit
+	for key, limit := range byteLimits {
+		for _, record := range records {
+			if err := truncateEmbeddedBytes(record, key, embeddedKeys[key], limit); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Leaves out the related records embedded at the given key once their total size (encoded as JSON)
+// would exceed the limit.
+func truncateEmbeddedBytes(record *dal.Record, key string, related *dal.Collection, limit int) error {
+	var value = record.GetNested(key)
+
+	if !typeutil.IsArray(value) {
+		return nil
+	}
+
+	var embedded = sliceutil.Sliceify(value)
+	var size int
+
+	for i, item := range embedded {
+		if data, err := json.Marshal(item); err == nil {
+			size += len(data)
+		} else {
+			return err
+		}
+
+		if size > limit {
+			var remaining = make([]interface{}, 0, len(embedded)-i)
+
+			for _, left := range embedded[i:] {
+				if data, ok := left.(map[string]interface{}); ok {
+					remaining = append(remaining, data[related.GetIdentityFieldName()])
+				} else {
+					remaining = append(remaining, left)
+				}
+			}
+
+			setEmbedTruncation(record.Fields, key, &dal.EmbedTruncation{
+				Collection: related.Name,
+				Total:      len(embedded),
+				Included:   i,
+				Remaining:  remaining,
+			})
+
+			record.SetNested(key, embedded[:i])
+			break
+		}
+	}
+
+	return nil
+}
+
 func (self *EmbeddedRecordBackend) String() string {
 	return self.backend.String()
 }
@@ -47,8 +276,10 @@ func (self *EmbeddedRecordBackend) String() string {
 func (self *EmbeddedRecordBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
 	if collection, err := self.GetCollection(name); err == nil {
 		if record, err := self.backend.Retrieve(name, id, fields...); err == nil {
-			record, err = self.EmbedRelationships(collection, record, fields...)
-			ResolveDeferredRecords(nil, record)
+			if err := self.ExpandRecords(collection, nil, []*dal.Record{record}, fields...); err != nil {
+				return nil, err
+			}
+
 			return record, nil
 		} else {
 			return nil, err
@@ -170,35 +401,77 @@ func (self *EmbeddedRecordBackend) Index(collection *dal.Collection, records *da
 	return self.indexer.Index(collection, records)
 }
 
-func (self *EmbeddedRecordBackend) QueryFunc(collection *dal.Collection, filter *filter.Filter, resultFn IndexResultFunc) error {
-	deferredCache := make(map[string]interface{})
+// Query results are buffered in batches of BatchSize records so that the related records of each
+// batch can be retrieved together.
+func (self *EmbeddedRecordBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	var batch = make([]*dal.Record, 0)
+	var pages = make([]IndexPage, 0)
+	var fields []string
+	var expand []string
 
-	return self.indexer.QueryFunc(collection, filter, func(record *dal.Record, err error, page IndexPage) error {
-		if err := ResolveDeferredRecords(deferredCache, record); err == nil {
-			return resultFn(record, err, page)
-		} else {
+	if f != nil {
+		fields = f.Fields
+		expand = f.Expand
+	}
+
+	var flush = func() error {
+		defer func() {
+			batch = batch[:0]
+			pages = pages[:0]
+		}()
+
+		if err := self.ExpandRecords(collection, expand, batch, fields...); err != nil {
 			return err
 		}
-	})
-}
 
-func (self *EmbeddedRecordBackend) Query(collection *dal.Collection, filter *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
-	if recordset, err := self.indexer.Query(collection, filter, resultFns...); err == nil {
-		for i, record := range recordset.Records {
-			if record, err := self.EmbedRelationships(collection, record, filter.Fields...); err == nil {
-				recordset.Records[i] = record
-			} else {
-				return nil, err
+		for i, record := range batch {
+			if err := resultFn(record, nil, pages[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := self.indexer.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err != nil || record == nil {
+			if ferr := flush(); ferr != nil {
+				return ferr
 			}
+
+			return resultFn(record, err, page)
 		}
 
-		deferredCache := make(map[string]interface{})
+		batch = append(batch, record)
+		pages = append(pages, page)
+
+		if len(batch) >= self.BatchSize {
+			return flush()
+		}
+
+		return nil
+	}); err == nil {
+		return flush()
+	} else {
+		return err
+	}
+}
+
+func (self *EmbeddedRecordBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if recordset, err := self.indexer.Query(collection, f, resultFns...); err == nil {
+		var fields []string
+		var expand []string
+
+		if f != nil {
+			fields = f.Fields
+			expand = f.Expand
+		}
 
-		if err := ResolveDeferredRecords(deferredCache, recordset.Records...); err != nil {
+		if err := self.ExpandRecords(collection, expand, recordset.Records, fields...); err != nil {
 			return nil, err
 		}
 
-		return recordset, err
+		return recordset, nil
 	} else {
 		return nil, err
 	}
@@ -0,0 +1,164 @@
+package backends_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// counts the queries made against each collection
+type queryCountingIndexer struct {
+	backends.Indexer
+	queries map[string]int
+}
+
+func (self *queryCountingIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn backends.IndexResultFunc) error {
+	self.queries[collection.Name] += 1
+	return self.Indexer.QueryFunc(collection, f, resultFn)
+}
+
+func (self *queryCountingIndexer) Query(collection *dal.Collection, f *filter.Filter, resultFns ...backends.IndexResultFunc) (*dal.RecordSet, error) {
+	return backends.DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+type queryCountingBackend struct {
+	backends.Backend
+	indexer *queryCountingIndexer
+}
+
+func (self *queryCountingBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) backends.Indexer {
+	return self.indexer
+}
+
+func TestEmbeddedRecordBackendExpand(t *testing.T) {
+	assert := require.New(t)
+
+	adapter := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	// contacts live in countries, whose capitals are contacts (a cycle), and orders are shipped
+	// to contacts
+	for _, collection := range []*dal.Collection{
+		dal.NewCollection(`contacts`, dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name:      `country_id`,
+			Type:      dal.IntType,
+			BelongsTo: `countries`,
+		}),
+		dal.NewCollection(`countries`, dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name:      `capital_id`,
+			Type:      dal.IntType,
+			BelongsTo: `contacts`,
+		}),
+		dal.NewCollection(`orders`, dal.Field{
+			Name: `status`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name:      `shipping_address`,
+			Type:      dal.IntType,
+			BelongsTo: `contacts`,
+		}),
+	} {
+		assert.NoError(adapter.CreateCollection(collection))
+	}
+
+	assert.NoError(adapter.Insert(`countries`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `Portugal`).Set(`capital_id`, 3),
+	)))
+
+	assert.NoError(adapter.Insert(`contacts`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `Alice`).Set(`country_id`, 1),
+		dal.NewRecord(2).Set(`name`, `Bob`).Set(`country_id`, 1),
+		dal.NewRecord(3).Set(`name`, `Carol`).Set(`country_id`, 1),
+	)))
+
+	var orders = dal.NewRecordSet()
+
+	for i := 1; i <= 10; i++ {
+		orders.Push(dal.NewRecord(i).Set(`status`, `pending`).Set(`shipping_address`, 1+(i%2)))
+	}
+
+	assert.NoError(adapter.Insert(`orders`, orders))
+
+	indexer := &queryCountingIndexer{
+		Indexer: adapter.WithSearch(nil),
+		queries: make(map[string]int),
+	}
+
+	backend := backends.NewEmbeddedRecordBackend(&queryCountingBackend{
+		Backend: adapter,
+		indexer: indexer,
+	})
+
+	collection, err := backend.GetCollection(`orders`)
+	assert.NoError(err)
+
+	// everything is expanded, with each collection's related records retrieved in one query
+	recordset, err := backend.Query(collection, filter.All())
+	assert.NoError(err)
+	assert.Len(recordset.Records, 10)
+
+	for _, record := range recordset.Records {
+		assert.Contains([]interface{}{`Alice`, `Bob`}, record.GetNested(`shipping_address.name`))
+		assert.Equal(`Portugal`, record.GetNested(`shipping_address.country_id.name`))
+
+		// contacts are not expanded within contacts
+		assert.EqualValues(3, record.GetNested(`shipping_address.country_id.capital_id`))
+	}
+
+	assert.Equal(map[string]int{
+		`orders`:    1,
+		`contacts`:  1,
+		`countries`: 1,
+	}, indexer.queries)
+
+	// ...or only the relationships that are asked for
+	f := filter.All()
+	f.Expand = []string{`shipping_address`}
+
+	recordset, err = backend.Query(collection, f)
+	assert.NoError(err)
+	assert.Equal(`Bob`, recordset.Records[0].GetNested(`shipping_address.name`))
+	assert.EqualValues(1, recordset.Records[0].GetNested(`shipping_address.country_id`))
+
+	// expansion stops at the maximum depth
+	backend.MaxDepth = 1
+
+	recordset, err = backend.Query(collection, filter.All())
+	assert.NoError(err)
+	assert.Equal(`Bob`, recordset.Records[0].GetNested(`shipping_address.name`))
+	assert.EqualValues(1, recordset.Records[0].GetNested(`shipping_address.country_id`))
+
+	backend.MaxDepth = backends.DefaultEmbedMaxDepth
+
+	// streamed results are expanded in batches
+	backend.BatchSize = 4
+	indexer.queries = make(map[string]int)
+
+	var streamed int
+
+	assert.NoError(backend.QueryFunc(collection, f, func(record *dal.Record, err error, _ backends.IndexPage) error {
+		assert.NoError(err)
+		assert.NotNil(record.GetNested(`shipping_address.name`))
+		streamed += 1
+		return nil
+	}))
+
+	assert.Equal(10, streamed)
+	assert.Equal(3, indexer.queries[`contacts`])
+
+	// retrieved records are expanded the same way
+	record, err := backend.Retrieve(`orders`, 2)
+	assert.NoError(err)
+	assert.Equal(`Alice`, record.GetNested(`shipping_address.name`))
+	assert.Equal(`Portugal`, record.GetNested(`shipping_address.country_id.name`))
+}
@@ -2,8 +2,65 @@ package backends
 
 import (
 	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
 )
 
 func TestInflateEmbeddedRecords(t *testing.T) {
 
 }
+
+func TestEmbeddedRecordTruncation(t *testing.T) {
+	assert := require.New(t)
+
+	items := dal.NewCollection(`items`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	// only the first IDs are embedded when there are too many
+	record := dal.NewRecord(1).Set(`item_ids`, []interface{}{1, 2, 3, 4, 5})
+
+	ids := truncateEmbeddedIds(record, `item_ids`, items, []interface{}{1, 2, 3, 4, 5}, 0)
+	assert.Len(ids, 5)
+	assert.Nil(record.Get(dal.EmbedTruncationField))
+
+	ids = truncateEmbeddedIds(record, `item_ids`, items, []interface{}{1, 2, 3, 4, 5}, 3)
+	assert.Equal([]interface{}{1, 2, 3}, ids)
+	assert.Equal([]interface{}{1, 2, 3}, record.Get(`item_ids`))
+
+	truncation := record.Get(dal.EmbedTruncationField).(map[string]interface{})[`item_ids`].(*dal.EmbedTruncation)
+	assert.Equal(`items`, truncation.Collection)
+	assert.Equal(5, truncation.Total)
+	assert.Equal(3, truncation.Included)
+	assert.Equal([]interface{}{4, 5}, truncation.Remaining)
+
+	// embedded records past the size limit are left out too, adding to those already left out
+	record.Set(`item_ids`, []interface{}{
+		map[string]interface{}{`id`: 1, `name`: `first`},
+		map[string]interface{}{`id`: 2, `name`: `second`},
+		map[string]interface{}{`id`: 3, `name`: `third`},
+	})
+
+	assert.NoError(truncateEmbeddedBytes(record, `item_ids`, items, 50))
+	assert.Len(record.Get(`item_ids`), 2)
+
+	truncation = record.Get(dal.EmbedTruncationField).(map[string]interface{})[`item_ids`].(*dal.EmbedTruncation)
+	assert.Equal(5, truncation.Total)
+	assert.Equal(2, truncation.Included)
+	assert.Equal([]interface{}{3, 4, 5}, truncation.Remaining)
+
+	// records within the limit are left alone
+	other := dal.NewRecord(2).Set(`item_ids`, []interface{}{
+		map[string]interface{}{`id`: 1, `name`: `first`},
+	})
+
+	assert.NoError(truncateEmbeddedBytes(other, `item_ids`, items, 50))
+	assert.Len(other.Get(`item_ids`), 1)
+	assert.Nil(other.Get(dal.EmbedTruncationField))
+
+	// relationships can set their own limits
+	assert.Equal(10, embedLimit(10, 100))
+	assert.Equal(100, embedLimit(0, 100))
+}
@@ -6,9 +6,11 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/ghetzel/go-stockutil/maputil"
 	"github.com/ghetzel/go-stockutil/pathutil"
 	"github.com/ghetzel/go-stockutil/sliceutil"
 	"github.com/ghetzel/go-stockutil/typeutil"
@@ -179,7 +181,7 @@ func (self *FilesystemBackend) Insert(collectionName string, recordset *dal.Reco
 		}
 	}
 
-	return self.Update(collectionName, recordset)
+	return self.upsert(true, collectionName, recordset)
 }
 
 func (self *FilesystemBackend) Exists(name string, id interface{}) bool {
@@ -226,7 +228,15 @@ func (self *FilesystemBackend) Retrieve(name string, id interface{}, fields ...s
 }
 
 func (self *FilesystemBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	return self.upsert(false, name, recordset)
+}
+
+func (self *FilesystemBackend) upsert(create bool, name string, recordset *dal.RecordSet) error {
 	if collection, err := self.GetCollection(name); err == nil {
+		if err := collection.FormatRecordSet(recordset, create); err != nil {
+			return err
+		}
+
 		for _, record := range recordset.Records {
 			var idkey string
 
@@ -295,18 +305,20 @@ func (self *FilesystemBackend) WithAggregator(collection *dal.Collection) Aggreg
 
 func (self *FilesystemBackend) ListCollections() ([]string, error) {
 	if entries, err := ioutil.ReadDir(self.root); err == nil {
-		var schemata []string
+		var schemata = maputil.StringKeys(self.registeredCollections)
 
 		for _, entry := range entries {
 			if entry.IsDir() {
-
 				if collection, err := self.readSchemaFromDisk(entry.Name()); err == nil {
-					schemata = append(schemata, collection.Name)
-
+					if !sliceutil.ContainsString(schemata, collection.Name) {
+						schemata = append(schemata, collection.Name)
+					}
 				}
 			}
 		}
 
+		sort.Strings(schemata)
+
 		return schemata, nil
 	} else {
 		return nil, err
@@ -0,0 +1,255 @@
+package backends
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/pivot/v3/util"
+)
+
+// How long each health check waits for the backend (or indexer) to respond before considering it down.
+var DefaultHealthCheckTimeout = 5 * time.Second
+
+// The number of consecutive failed health checks after which the monitor tries to reconnect.
+var DefaultHealthCheckReconnectAfter = 2
+
+type HealthCheckOptions struct {
+	// How often the backend and indexer are pinged when monitored with HealthMonitor.Start.
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// How long each ping may take before the check fails.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// The number of consecutive failed checks after which a reconnect is attempted.
+	ReconnectAfter int `json:"reconnect_after,omitempty"`
+}
+
+// Implemented by backends and indexers that can discard their existing connections and establish
+// new ones, such as after the server they're connected to restarts.
+type Reconnector interface {
+	Reconnect() error
+}
+
+type pinger interface {
+	Ping(time.Duration) error
+}
+
+// The HealthMonitor periodically pings a backend and its external indexer (if it has one), keeping
+// track of whether each is reachable and how long they take to respond.  When a component fails
+// several checks in a row and it (or a backend it wraps) is a Reconnector, its connections are
+// re-established so that requests can succeed again once the server is back.
+type HealthMonitor struct {
+	backend   Backend
+	options   HealthCheckOptions
+	status    util.HealthStatus
+	lock      sync.Mutex
+	checkLock sync.Mutex
+	stop      chan bool
+}
+
+func NewHealthMonitor(backend Backend, options HealthCheckOptions) *HealthMonitor {
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultHealthCheckTimeout
+	}
+
+	if options.ReconnectAfter <= 0 {
+		options.ReconnectAfter = DefaultHealthCheckReconnectAfter
+	}
+
+	return &HealthMonitor{
+		backend: backend,
+		options: options,
+		status: util.HealthStatus{
+			OK: true,
+			Backend: util.ComponentHealth{
+				OK: true,
+			},
+		},
+	}
+}
+
+// Ping the backend and indexer once, reconnecting if they've failed too many times, and return
+// the resulting status.
+func (self *HealthMonitor) Check() util.HealthStatus {
+	self.checkLock.Lock()
+	defer self.checkLock.Unlock()
+
+	var previous = self.Status()
+	var status = util.HealthStatus{
+		Backend: self.check(self.backend, previous.Backend),
+	}
+
+	status.OK = status.Backend.OK
+
+	if indexer := externalIndexer(self.backend); indexer != nil {
+		if p, ok := indexer.(pinger); ok {
+			var health util.ComponentHealth
+
+			if previous.Indexer != nil {
+				health = *previous.Indexer
+			}
+
+			health = self.check(p, health)
+			status.Indexer = &health
+			status.OK = status.OK && health.OK
+		}
+	}
+
+	self.lock.Lock()
+	self.status = status
+	self.lock.Unlock()
+
+	return status
+}
+
+// Return the results of the most recent health check.
+func (self *HealthMonitor) Status() util.HealthStatus {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	var status = self.status
+
+	if status.Indexer != nil {
+		var indexer = *status.Indexer
+		status.Indexer = &indexer
+	}
+
+	return status
+}
+
+// Start checking the health of the backend in the background at the configured interval.
+func (self *HealthMonitor) Start() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.options.Interval <= 0 {
+		return fmt.Errorf("must specify an interval to schedule health checks")
+	} else if self.stop != nil {
+		return nil
+	}
+
+	self.stop = make(chan bool)
+
+	go func(stop chan bool) {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(self.options.Interval):
+				self.Check()
+			}
+		}
+	}(self.stop)
+
+	return nil
+}
+
+// Stop checking the health of the backend in the background.
+func (self *HealthMonitor) Stop() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.stop != nil {
+		close(self.stop)
+		self.stop = nil
+	}
+}
+
+// ping the given component and return its updated health, reconnecting if it has failed
+// ReconnectAfter times in a row
+func (self *HealthMonitor) check(component pinger, health util.ComponentHealth) util.ComponentHealth {
+	var started = time.Now()
+	var err = component.Ping(self.options.Timeout)
+
+	health.CheckedAt = started
+	health.Latency = float64(time.Since(started)) / float64(time.Millisecond)
+
+	if err == nil {
+		if !health.OK {
+			log.Infof("[%v] health check succeeded after %d failure(s)", component, health.ConsecutiveFailures)
+		}
+
+		health.OK = true
+		health.ConsecutiveFailures = 0
+		return health
+	}
+
+	health.OK = false
+	health.ConsecutiveFailures += 1
+	health.LastError = err.Error()
+	health.LastErrorAt = &started
+
+	log.Warningf("[%v] health check failed: %v", component, err)
+
+	if health.ConsecutiveFailures >= self.options.ReconnectAfter {
+		if reconnector := findReconnector(component); reconnector != nil {
+			log.Infof("[%v] reconnecting after %d failed health check(s)", component, health.ConsecutiveFailures)
+
+			if err := reconnector.Reconnect(); err == nil {
+				health.Reconnects += 1
+
+				started = time.Now()
+
+				if err := component.Ping(self.options.Timeout); err == nil {
+					log.Infof("[%v] reconnected", component)
+
+					health.OK = true
+					health.ConsecutiveFailures = 0
+					health.CheckedAt = started
+					health.Latency = float64(time.Since(started)) / float64(time.Millisecond)
+				} else {
+					health.LastError = err.Error()
+				}
+			} else {
+				log.Warningf("[%v] reconnect failed: %v", component, err)
+				health.LastError = err.Error()
+			}
+		}
+	}
+
+	return health
+}
+
+// returns the given component, or the first backend it wraps, that can reconnect
+func findReconnector(component interface{}) Reconnector {
+	if reconnector, ok := component.(Reconnector); ok {
+		return reconnector
+	} else if wrapper, ok := component.(interface{ GetBackend() Backend }); ok {
+		for _, backend := range BackendChain(wrapper.GetBackend()) {
+			if reconnector, ok := backend.(Reconnector); ok {
+				return reconnector
+			}
+		}
+	}
+
+	return nil
+}
+
+// returns the indexer that the given backend queries, unless it is the backend itself.  Wrapped
+// backends and indexers are unwrapped, since the wrappers don't expose whether the indexer can be
+// pinged.
+func externalIndexer(backend Backend) Indexer {
+	if chain := BackendChain(backend); len(chain) > 0 {
+		backend = chain[len(chain)-1]
+	} else {
+		return nil
+	}
+
+	var indexer = backend.WithSearch(nil, nil)
+
+	for indexer != nil {
+		if wrapper, ok := indexer.(interface{ GetIndexer() Indexer }); ok && wrapper.GetIndexer() != nil && wrapper.GetIndexer() != indexer {
+			indexer = wrapper.GetIndexer()
+		} else {
+			break
+		}
+	}
+
+	if indexer == nil || isSelfIndexed(backend, indexer) {
+		return nil
+	}
+
+	return indexer
+}
@@ -0,0 +1,74 @@
+package backends_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+// a backend whose connection can be broken, and which only recovers once it reconnects
+type flakyBackend struct {
+	*spi.Adapter
+	down       bool
+	reconnects int
+}
+
+func (self *flakyBackend) Ping(timeout time.Duration) error {
+	if self.down {
+		return fmt.Errorf("connection refused")
+	}
+
+	return self.Adapter.Ping(timeout)
+}
+
+func (self *flakyBackend) Reconnect() error {
+	self.reconnects += 1
+	self.down = false
+	return nil
+}
+
+func TestHealthMonitor(t *testing.T) {
+	assert := require.New(t)
+
+	flaky := &flakyBackend{
+		Adapter: spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver()),
+	}
+
+	// wrapped backends are unwrapped to find one that can reconnect
+	monitor := backends.NewHealthMonitor(backends.NewTimeoutBackend(flaky, time.Second, time.Second), backends.HealthCheckOptions{
+		ReconnectAfter: 2,
+	})
+
+	status := monitor.Check()
+	assert.True(status.OK)
+	assert.True(status.Backend.OK)
+	assert.False(status.Backend.CheckedAt.IsZero())
+	assert.Nil(status.Backend.LastErrorAt)
+	assert.Nil(status.Indexer)
+
+	// the first failure is reported, but doesn't trigger a reconnect
+	flaky.down = true
+
+	status = monitor.Check()
+	assert.False(status.OK)
+	assert.Equal(1, status.Backend.ConsecutiveFailures)
+	assert.Equal(`connection refused`, status.Backend.LastError)
+	assert.NotNil(status.Backend.LastErrorAt)
+	assert.Equal(0, flaky.reconnects)
+
+	// the second does, after which the backend is healthy again
+	status = monitor.Check()
+	assert.True(status.OK)
+	assert.Equal(0, status.Backend.ConsecutiveFailures)
+	assert.Equal(1, status.Backend.Reconnects)
+	assert.Equal(`connection refused`, status.Backend.LastError)
+	assert.Equal(1, flaky.reconnects)
+
+	assert.Equal(status, monitor.Status())
+}
@@ -0,0 +1,43 @@
+package backends
+
+import (
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// For collections with HydrateFromBackend set, this replaces a record returned by an external index
+// with the authoritative copy of that record retrieved (by ID) from the indexer's backend.  The
+// index record's relevance score is preserved.  If the record no longer exists in the backend, the
+// index document is stale and a nil record (and nil error) is returned so that callers can skip it.
+//
+// Indexers that build on DefaultQueryImplementation already retrieve results from their backend;
+// this is for indexers that otherwise return their own documents as results.
+func HydrateIndexRecord(indexer Indexer, collection *dal.Collection, f *filter.Filter, indexRecord *dal.Record) (*dal.Record, error) {
+	var fields []string
+
+	if collection == nil || !collection.HydrateFromBackend || indexRecord == nil {
+		return indexRecord, nil
+	}
+
+	if f != nil {
+		if f.IdOnly() {
+			return indexRecord, nil
+		}
+
+		fields = f.Fields
+	}
+
+	if parent := indexer.GetBackend(); parent != nil {
+		if record, err := parent.Retrieve(collection.Name, indexRecord.ID, fields...); err == nil {
+			record.Score = indexRecord.Score
+			return record, nil
+		} else if dal.IsNotExistError(err) {
+			querylog.Debugf("[%T] skipping stale index record %v/%v", indexer, collection.Name, indexRecord.ID)
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	return indexRecord, nil
+}
@@ -0,0 +1,64 @@
+package backends_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHydrateIndexRecord(t *testing.T) {
+	assert := require.New(t)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`things`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `size`,
+		Type: dal.IntType,
+	})))
+
+	assert.NoError(backend.Insert(`things`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `current`).Set(`size`, 42),
+	)))
+
+	collection, err := backend.GetCollection(`things`)
+	assert.NoError(err)
+
+	indexer := backend.WithSearch(collection)
+	stale := dal.NewRecord(1).Set(`name`, `stale`)
+	stale.Score = 2.5
+
+	// index records are returned as-is unless the collection asks for them to be hydrated
+	record, err := backends.HydrateIndexRecord(indexer, collection, filter.All(), stale)
+	assert.NoError(err)
+	assert.Equal(`stale`, record.Get(`name`))
+
+	collection.HydrateFromBackend = true
+
+	record, err = backends.HydrateIndexRecord(indexer, collection, filter.All(), stale)
+	assert.NoError(err)
+	assert.Equal(`current`, record.Get(`name`))
+	assert.EqualValues(42, record.Get(`size`))
+	assert.Equal(2.5, record.Score)
+
+	// ID-only queries have nothing to hydrate
+	idOnly := filter.All()
+	idOnly.IdentityField = `id`
+	idOnly.Fields = []string{`id`}
+
+	record, err = backends.HydrateIndexRecord(indexer, collection, idOnly, stale)
+	assert.NoError(err)
+	assert.Equal(`stale`, record.Get(`name`))
+
+	// index documents for records that no longer exist are dropped
+	record, err = backends.HydrateIndexRecord(indexer, collection, filter.All(), dal.NewRecord(2))
+	assert.NoError(err)
+	assert.Nil(record)
+}
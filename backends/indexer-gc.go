@@ -0,0 +1,225 @@
+package backends
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// The default number of index entries examined per collection each time garbage is collected.
+var IndexGCSampleSize = 1000
+
+type IndexGCOptions struct {
+	// How often garbage collection runs when scheduled with IndexGarbageCollector.Start.
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// The maximum number of index entries examined per collection on each run.  Successive runs
+	// pick up where the previous one left off, eventually covering the entire index.
+	SampleSize int `json:"sample_size,omitempty"`
+
+	// The maximum number of index entries checked against the backend per second.  Zero is unlimited.
+	Rate float64 `json:"rate,omitempty"`
+
+	// Report orphaned index entries without removing them.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// Describes the results of collecting garbage from the index of a single collection.
+type IndexGCReport struct {
+	Collection string        `json:"collection"`
+	Scanned    int           `json:"scanned"`
+	Orphans    []interface{} `json:"orphans"`
+	Removed    bool          `json:"removed"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// The IndexGarbageCollector removes entries from an external index (e.g.: Elasticsearch, Bleve)
+// whose records no longer exist in the backend.  This happens when records are deleted without
+// going through Pivot, such as by manual SQL statements or TTL sweeps.  Index entries are sampled
+// a batch at a time, and each is checked for existence in the backend.
+type IndexGarbageCollector struct {
+	backend Backend
+	options IndexGCOptions
+	offsets map[string]int
+	lock    sync.Mutex
+	stop    chan bool
+}
+
+func NewIndexGarbageCollector(backend Backend, options IndexGCOptions) *IndexGarbageCollector {
+	if options.SampleSize <= 0 {
+		options.SampleSize = IndexGCSampleSize
+	}
+
+	return &IndexGarbageCollector{
+		backend: backend,
+		options: options,
+		offsets: make(map[string]int),
+	}
+}
+
+// Collect garbage from the indexes of the given collections (or all collections if none are given).
+// Collections that are not served by an external index are skipped.
+func (self *IndexGarbageCollector) Run(collections ...string) ([]*IndexGCReport, error) {
+	var reports = make([]*IndexGCReport, 0)
+
+	if len(collections) == 0 {
+		if names, err := self.backend.ListCollections(); err == nil {
+			collections = names
+		} else {
+			return nil, err
+		}
+	}
+
+	for _, name := range collections {
+		if collection, err := self.backend.GetCollection(name); err == nil {
+			if report := self.collect(collection); report != nil {
+				reports = append(reports, report)
+			}
+		} else {
+			reports = append(reports, &IndexGCReport{
+				Collection: name,
+				Error:      err.Error(),
+			})
+		}
+	}
+
+	return reports, nil
+}
+
+// Start collecting garbage from all collections in the background at the configured interval.
+func (self *IndexGarbageCollector) Start() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.options.Interval <= 0 {
+		return fmt.Errorf("must specify an interval to schedule index garbage collection")
+	} else if self.stop != nil {
+		return nil
+	}
+
+	self.stop = make(chan bool)
+
+	go func(stop chan bool) {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(self.options.Interval):
+				if reports, err := self.Run(); err == nil {
+					for _, report := range reports {
+						if report.Error != `` {
+							log.Warningf("[%v] index garbage collection failed for %q: %v", self.backend, report.Collection, report.Error)
+						} else if len(report.Orphans) > 0 {
+							log.Infof("[%v] removed %d orphaned index entries from %q: %v", self.backend, len(report.Orphans), report.Collection, report.Orphans)
+						}
+					}
+				} else {
+					log.Warningf("[%v] index garbage collection failed: %v", self.backend, err)
+				}
+			}
+		}
+	}(self.stop)
+
+	return nil
+}
+
+// Stop collecting garbage in the background.
+func (self *IndexGarbageCollector) Stop() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.stop != nil {
+		close(self.stop)
+		self.stop = nil
+	}
+}
+
+func (self *IndexGarbageCollector) collect(collection *dal.Collection) *IndexGCReport {
+	var search = self.backend.WithSearch(collection)
+
+	if search == nil || isSelfIndexed(self.backend, search) {
+		return nil
+	}
+
+	var report = &IndexGCReport{
+		Collection: collection.Name,
+		Orphans:    make([]interface{}, 0),
+	}
+
+	self.lock.Lock()
+	var offset = self.offsets[collection.Name]
+	self.lock.Unlock()
+
+	var f = filter.Copy(filter.All())
+	f.IdentityField = collection.GetIdentityFieldName()
+	f.Sort = []string{f.IdentityField}
+	f.Fields = []string{f.IdentityField} // only return IDs, rather than retrieving each record from the backend
+	f.Limit = self.options.SampleSize
+	f.Offset = offset
+
+	var started = time.Now()
+
+	recordset, err := search.Query(collection, &f)
+
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	for _, record := range recordset.Records {
+		report.Scanned += 1
+
+		if !self.backend.Exists(collection.Name, record.ID) {
+			report.Orphans = append(report.Orphans, record.ID)
+		}
+
+		// throttle so that we don't overwhelm the backend with existence checks
+		if self.options.Rate > 0 {
+			var due = time.Duration(float64(report.Scanned) / self.options.Rate * float64(time.Second))
+
+			if elapsed := time.Since(started); elapsed < due {
+				time.Sleep(due - elapsed)
+			}
+		}
+	}
+
+	if len(report.Orphans) > 0 && !self.options.DryRun {
+		if err := search.IndexRemove(collection, report.Orphans); err == nil {
+			report.Removed = true
+		} else {
+			report.Error = err.Error()
+		}
+	}
+
+	// pick up after this sample next time, accounting for the entries we just removed; once we've
+	// reached the end of the index, start over from the beginning
+	if len(recordset.Records) < f.Limit {
+		offset = 0
+	} else if report.Removed {
+		offset += len(recordset.Records) - len(report.Orphans)
+	} else {
+		offset += len(recordset.Records)
+	}
+
+	self.lock.Lock()
+	self.offsets[collection.Name] = offset
+	self.lock.Unlock()
+
+	return report
+}
+
+// returns whether the given indexer is the backend itself (or one of the backends it wraps), in
+// which case the index can't contain entries for records that don't exist
+func isSelfIndexed(backend Backend, indexer Indexer) bool {
+	for _, backend := range BackendChain(backend) {
+		if idx, ok := backend.(Indexer); ok && idx == indexer {
+			return true
+		}
+	}
+
+	return false
+}
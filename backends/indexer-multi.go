@@ -3,11 +3,20 @@ package backends
 import (
 	"fmt"
 	"math/rand"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/ghetzel/pivot/v3/dal"
 	"github.com/ghetzel/pivot/v3/filter"
 )
 
+// How heavily each new measurement is weighed when updating an indexer's average latency (0-1).
+var MultiIndexLatencyDecay = 0.2
+
+// How long an indexer that failed a request is avoided before requests are routed to it again.
+var MultiIndexUnhealthyCooldown = 30 * time.Second
+
 type IndexSelectionStrategy int
 
 const (
@@ -16,6 +25,7 @@ const (
 	First
 	AllExceptFirst
 	Random
+	Fastest // the healthy indexer with the lowest average latency, failing over to the others in order of latency
 )
 
 func (self IndexSelectionStrategy) IsCompoundable() bool {
@@ -36,12 +46,33 @@ const (
 	InspectionOperation
 )
 
+// Indexers that can only serve some filters (e.g.: those whose query syntax doesn't support every
+// operator) can implement this interface so that a MultiIndex only routes queries to them that
+// they are capable of serving.
+type FilterCapableIndexer interface {
+	CanQuery(collection *dal.Collection, f *filter.Filter) bool
+}
+
+// Describes the health and average latency of one of a MultiIndex's indexers.
+type IndexerHealth struct {
+	Index     int           `json:"index"`
+	Indexer   string        `json:"indexer"`
+	Healthy   bool          `json:"healthy"`
+	Latency   time.Duration `json:"latency"` // an exponentially-weighted moving average of successful requests
+	Requests  int64         `json:"requests"`
+	Failures  int64         `json:"failures"`
+	LastError string        `json:"last_error,omitempty"`
+	RetryAt   time.Time     `json:"retry_at,omitempty"` // when an unhealthy indexer will next be tried first
+}
+
 type MultiIndex struct {
 	RetrievalStrategy  IndexSelectionStrategy
 	PersistStrategy    IndexSelectionStrategy
 	DeleteStrategy     IndexSelectionStrategy
 	InspectionStrategy IndexSelectionStrategy
 	indexers           []Indexer
+	health             []IndexerHealth
+	healthLock         sync.Mutex
 	connectionStrings  []string
 	backend            Backend
 }
@@ -56,7 +87,7 @@ var IndexerResultsStop = fmt.Errorf(`stop indexer results`)
 
 func NewMultiIndex(connectionStrings ...string) *MultiIndex {
 	return &MultiIndex{
-		RetrievalStrategy:  Sequential,
+		RetrievalStrategy:  Fastest,
 		PersistStrategy:    All,
 		DeleteStrategy:     All,
 		InspectionStrategy: All,
@@ -73,10 +104,22 @@ func (self *MultiIndex) AddIndexer(indexer Indexer) error {
 		}
 	}
 
-	self.indexers = append(self.indexers, indexer)
+	self.addIndexer(indexer)
 	return nil
 }
 
+func (self *MultiIndex) addIndexer(indexer Indexer) {
+	self.healthLock.Lock()
+	defer self.healthLock.Unlock()
+
+	self.health = append(self.health, IndexerHealth{
+		Index:   len(self.indexers),
+		Indexer: fmt.Sprintf("%T", indexer),
+	})
+
+	self.indexers = append(self.indexers, indexer)
+}
+
 func (self *MultiIndex) AddIndexerByConnectionString(cs string) error {
 	if ics, err := dal.ParseConnectionString(cs); err == nil {
 		if indexer, err := MakeIndexer(ics); err == nil {
@@ -87,7 +130,7 @@ func (self *MultiIndex) AddIndexerByConnectionString(cs string) error {
 				}
 			}
 
-			self.indexers = append(self.indexers, indexer)
+			self.addIndexer(indexer)
 		} else {
 			return err
 		}
@@ -131,6 +174,15 @@ func (self *MultiIndex) GetBackend() Backend {
 func (self *MultiIndex) IndexExists(collection *dal.Collection, id interface{}) bool {
 	exists := false
 
+	if self.InspectionStrategy == Fastest {
+		self.withFastestIndex(collection, nil, func(indexer Indexer) error {
+			exists = indexer.IndexExists(collection, id)
+			return nil
+		})
+
+		return exists
+	}
+
 	if err := self.EachSelectedIndex(collection, InspectionOperation, func(indexer Indexer, _ int, _ int) error {
 		if !indexer.IndexExists(collection, id) {
 			exists = false
@@ -150,6 +202,22 @@ func (self *MultiIndex) IndexExists(collection *dal.Collection, id interface{})
 func (self *MultiIndex) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
 	var record *dal.Record
 
+	if self.RetrievalStrategy == Fastest {
+		err := self.withFastestIndex(collection, nil, func(indexer Indexer) error {
+			if r, err := indexer.IndexRetrieve(collection, id); err == nil {
+				record = r
+				return nil
+			} else if dal.IsNotExistError(err) {
+				// a healthy indexer not having the record isn't a reason to ask the others
+				return noFailoverError{err}
+			} else {
+				return err
+			}
+		})
+
+		return record, err
+	}
+
 	if err := self.EachSelectedIndex(collection, RetrieveOperation, func(indexer Indexer, _ int, _ int) error {
 		if r, err := indexer.IndexRetrieve(collection, id); err == nil {
 			record = r
@@ -205,6 +273,24 @@ func (self *MultiIndex) Index(collection *dal.Collection, records *dal.RecordSet
 func (self *MultiIndex) QueryFunc(collection *dal.Collection, filter *filter.Filter, resultFn IndexResultFunc) error {
 	var indexErr error
 
+	if self.RetrievalStrategy == Fastest {
+		return self.withFastestIndex(collection, filter, func(indexer Indexer) error {
+			var delivered bool
+
+			if err := indexer.QueryFunc(collection, filter, func(record *dal.Record, err error, page IndexPage) error {
+				delivered = true
+				return resultFn(record, err, page)
+			}); err == nil {
+				return nil
+			} else if delivered {
+				// failing over would pass the results already delivered to the caller again
+				return noFailoverError{err}
+			} else {
+				return err
+			}
+		})
+	}
+
 	if err := self.EachSelectedIndex(collection, RetrieveOperation, func(indexer Indexer, _ int, _ int) error {
 		if err := indexer.QueryFunc(collection, filter, resultFn); err == nil {
 			querylog.Debugf("MultiIndex: Indexer query to %v/%v: %v", indexer, collection, filter)
@@ -229,6 +315,19 @@ func (self *MultiIndex) Query(collection *dal.Collection, filter *filter.Filter,
 	recordset := dal.NewRecordSet()
 	var indexErr error
 
+	if self.RetrievalStrategy == Fastest {
+		err := self.withFastestIndex(collection, filter, func(indexer Indexer) error {
+			if rs, err := indexer.Query(collection, filter, resultFns...); err == nil {
+				recordset = rs
+				return nil
+			} else {
+				return err
+			}
+		})
+
+		return recordset, err
+	}
+
 	if err := self.EachSelectedIndex(collection, RetrieveOperation, func(indexer Indexer, _ int, _ int) error {
 		if rs, err := indexer.Query(collection, filter, resultFns...); err == nil {
 			if !rs.IsEmpty() {
@@ -256,6 +355,19 @@ func (self *MultiIndex) ListValues(collection *dal.Collection, fields []string,
 	values := make(map[string][]interface{})
 	var indexErr error
 
+	if self.RetrievalStrategy == Fastest {
+		err := self.withFastestIndex(collection, filter, func(indexer Indexer) error {
+			if kv, err := indexer.ListValues(collection, fields, filter); err == nil {
+				values = kv
+				return nil
+			} else {
+				return err
+			}
+		})
+
+		return values, err
+	}
+
 	if err := self.EachSelectedIndex(collection, RetrieveOperation, func(indexer Indexer, _ int, _ int) error {
 		if kv, err := indexer.ListValues(collection, fields, filter); err == nil {
 			if len(kv) > 0 {
@@ -431,7 +543,159 @@ func (self *MultiIndex) SelectIndex(collection *dal.Collection, operation IndexO
 			},
 		}, nil
 
+	case Fastest:
+		return self.rankIndexers(collection, nil), nil
+
 	default:
 		return nil, fmt.Errorf("Unrecognized selection strategy '%v'", strategy)
 	}
 }
+
+// Return the health and average latency of each indexer, in the order they were added.
+func (self *MultiIndex) Health() []IndexerHealth {
+	self.healthLock.Lock()
+	defer self.healthLock.Unlock()
+
+	var now = time.Now()
+	var health = make([]IndexerHealth, len(self.health))
+
+	for i, h := range self.health {
+		h.Healthy = !now.Before(h.RetryAt)
+		health[i] = h
+	}
+
+	return health
+}
+
+// errors that are returned to the caller as-is, without failing over to another indexer or counting
+// against the health of the indexer that returned them
+type noFailoverError struct {
+	error
+}
+
+// run fn against the fastest healthy indexer that is capable of serving the given filter, failing
+// over to the next fastest (and eventually to unhealthy indexers) if it returns an error
+func (self *MultiIndex) withFastestIndex(collection *dal.Collection, f *filter.Filter, fn func(indexer Indexer) error) error {
+	var candidates = self.rankIndexers(collection, f)
+	var lastErr error
+
+	if len(self.indexers) == 0 {
+		return fmt.Errorf("No indexers registered")
+	} else if len(candidates) == 0 {
+		return fmt.Errorf("No indexer is capable of querying %v with filter: %v", collection.Name, f)
+	}
+
+	for _, candidate := range candidates {
+		var started = time.Now()
+		var err = fn(candidate.Indexer)
+
+		if nf, ok := err.(noFailoverError); ok {
+			self.observe(candidate.Index, time.Since(started), nil)
+			return nf.error
+		}
+
+		self.observe(candidate.Index, time.Since(started), err)
+
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+		querylog.Debugf("MultiIndex: indexer %d (%T) failed on %v, failing over: %v", candidate.Index, candidate.Indexer, collection.Name, err)
+	}
+
+	return lastErr
+}
+
+// return the indexers capable of serving the given filter: healthy ones ordered by their average
+// latency (those that haven't been used yet first), followed by unhealthy ones ordered by how soon
+// they are due to be retried
+func (self *MultiIndex) rankIndexers(collection *dal.Collection, f *filter.Filter) []IndexerResult {
+	var healthy = make([]IndexerResult, 0)
+	var unhealthy = make([]IndexerResult, 0)
+	var capable = make([]IndexerResult, 0)
+
+	for i, indexer := range self.indexers {
+		if fc, ok := indexer.(FilterCapableIndexer); ok && f != nil && collection != nil && !fc.CanQuery(collection, f) {
+			querylog.Debugf("MultiIndex: indexer %d (%T) cannot serve filter: %v", i, indexer, f)
+			continue
+		}
+
+		capable = append(capable, IndexerResult{
+			Index:   i,
+			Indexer: indexer,
+		})
+	}
+
+	self.healthLock.Lock()
+	defer self.healthLock.Unlock()
+
+	var now = time.Now()
+
+	for _, result := range capable {
+		if now.Before(self.health[result.Index].RetryAt) {
+			unhealthy = append(unhealthy, result)
+		} else {
+			healthy = append(healthy, result)
+		}
+	}
+
+	sort.SliceStable(healthy, func(i, j int) bool {
+		return self.health[healthy[i].Index].Latency < self.health[healthy[j].Index].Latency
+	})
+
+	sort.SliceStable(unhealthy, func(i, j int) bool {
+		return self.health[unhealthy[i].Index].RetryAt.Before(self.health[unhealthy[j].Index].RetryAt)
+	})
+
+	if len(healthy) > 0 {
+		var first = self.health[healthy[0].Index]
+
+		querylog.Debugf(
+			"MultiIndex: routing to indexer %d (%s, avg latency %v); %d healthy and %d unhealthy fallback(s)",
+			first.Index,
+			first.Indexer,
+			first.Latency,
+			len(healthy)-1,
+			len(unhealthy),
+		)
+	} else if len(unhealthy) > 0 {
+		querylog.Debugf("MultiIndex: no healthy indexers, retrying indexer %d (%s)", unhealthy[0].Index, self.health[unhealthy[0].Index].Indexer)
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+// record the outcome of a request made to the given indexer
+func (self *MultiIndex) observe(i int, took time.Duration, err error) {
+	self.healthLock.Lock()
+	defer self.healthLock.Unlock()
+
+	if i < 0 || i >= len(self.health) {
+		return
+	}
+
+	var health = &self.health[i]
+
+	health.Requests += 1
+
+	if err == nil {
+		if health.Latency == 0 {
+			health.Latency = took
+		} else {
+			health.Latency = time.Duration(MultiIndexLatencyDecay*float64(took) + (1-MultiIndexLatencyDecay)*float64(health.Latency))
+		}
+
+		if !health.RetryAt.IsZero() {
+			querylog.Debugf("MultiIndex: indexer %d (%s) recovered", i, health.Indexer)
+			health.RetryAt = time.Time{}
+			health.LastError = ``
+		}
+	} else {
+		health.Failures += 1
+		health.LastError = err.Error()
+		health.RetryAt = time.Now().Add(MultiIndexUnhealthyCooldown)
+
+		querylog.Debugf("MultiIndex: indexer %d (%s) marked unhealthy until %v: %v", i, health.Indexer, health.RetryAt, err)
+	}
+}
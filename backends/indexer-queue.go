@@ -0,0 +1,370 @@
+package backends
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/util"
+)
+
+// Determines what happens to index writes that arrive while the index write queue is full.
+type IndexOverflowBehavior string
+
+const (
+	// Wait until there is room in the queue.  This applies backpressure to the caller.
+	IndexOverflowBlock IndexOverflowBehavior = `block`
+
+	// Discard the write and increment the pivot.indexers.queue.dropped metric.
+	IndexOverflowDrop = `drop`
+
+	// Append the write to a file on disk, which is replayed once the queue has drained.
+	IndexOverflowSpill = `spill`
+)
+
+// The number of index writes that may be waiting in a queue whose size is not specified.
+var DefaultIndexQueueSize = 1000
+
+// How often a queue that has spilled writes to disk checks whether it can replay them.
+var IndexQueueSpillInterval = time.Second
+
+type IndexQueueOptions struct {
+	Size      int                   `json:"size"`
+	Overflow  IndexOverflowBehavior `json:"overflow"`
+	SpillPath string                `json:"spill_path,omitempty"`
+}
+
+// Read index queue options from the given connection string, removing them so that they aren't
+// passed along to the indexer itself.  Returns false if no queue was requested.
+//
+//	elasticsearch://localhost:9200/?queueSize=5000&queueOverflow=spill&queueSpillPath=/var/spool/pivot.ndjson
+func IndexQueueOptionsFromConnectionString(connection *dal.ConnectionString) (IndexQueueOptions, bool) {
+	var options = IndexQueueOptions{
+		Size:      int(connection.ClearOpt(`queueSize`).Int()),
+		Overflow:  IndexOverflowBehavior(connection.ClearOpt(`queueOverflow`).String()),
+		SpillPath: connection.ClearOpt(`queueSpillPath`).String(),
+	}
+
+	if options.Size == 0 && options.Overflow == `` {
+		return options, false
+	}
+
+	return options, true
+}
+
+type indexWrite struct {
+	Collection string         `json:"collection"`
+	Records    *dal.RecordSet `json:"records,omitempty"`
+	IDs        []interface{}  `json:"ids,omitempty"`
+	collection *dal.Collection
+}
+
+// A QueuedIndexer wraps another Indexer, performing index writes (Index and IndexRemove) in the
+// background from a bounded queue.  All other operations are passed through as-is.  This keeps
+// slow or overloaded indexers from stalling writes to the backend, at the cost of queries not
+// immediately reflecting recent writes.
+type QueuedIndexer struct {
+	Indexer
+	options     IndexQueueOptions
+	queue       chan *indexWrite
+	collections sync.Map
+	spillLock   sync.Mutex
+	spilled     int64
+	dropped     int64
+	pending     int64
+	idle        *sync.Cond
+	stop        chan bool
+	closed      int32
+}
+
+func NewQueuedIndexer(indexer Indexer, options IndexQueueOptions) (*QueuedIndexer, error) {
+	if options.Size <= 0 {
+		options.Size = DefaultIndexQueueSize
+	}
+
+	switch options.Overflow {
+	case ``:
+		options.Overflow = IndexOverflowBlock
+	case IndexOverflowBlock, IndexOverflowDrop:
+		break
+	case IndexOverflowSpill:
+		if options.SpillPath == `` {
+			return nil, fmt.Errorf("index queue: a spill path must be specified to spill writes to disk")
+		}
+	default:
+		return nil, fmt.Errorf("index queue: unknown overflow behavior %q", options.Overflow)
+	}
+
+	var queued = &QueuedIndexer{
+		Indexer: indexer,
+		options: options,
+		queue:   make(chan *indexWrite, options.Size),
+		idle:    sync.NewCond(&sync.Mutex{}),
+		stop:    make(chan bool),
+	}
+
+	// writes spilled by a previous process are replayed once we're running
+	if stat, err := os.Stat(options.SpillPath); err == nil && stat.Size() > 0 {
+		queued.spilled = 1
+	}
+
+	go queued.run()
+
+	return queued, nil
+}
+
+// Return the indexer writes are being queued for.
+func (self *QueuedIndexer) GetIndexer() Indexer {
+	return self.Indexer
+}
+
+// Return the current state of the queue.
+func (self *QueuedIndexer) QueueStatus() util.IndexQueueStatus {
+	return util.IndexQueueStatus{
+		Depth:    len(self.queue),
+		Capacity: cap(self.queue),
+		Overflow: string(self.options.Overflow),
+		Spilled:  atomic.LoadInt64(&self.spilled) > 0,
+		Dropped:  atomic.LoadInt64(&self.dropped),
+	}
+}
+
+func (self *QueuedIndexer) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return self.enqueue(&indexWrite{
+		Collection: collection.Name,
+		Records:    records,
+		collection: collection,
+	})
+}
+
+func (self *QueuedIndexer) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return self.enqueue(&indexWrite{
+		Collection: collection.Name,
+		IDs:        ids,
+		collection: collection,
+	})
+}
+
+// Wait for all queued writes to be performed, then flush the underlying indexer.
+func (self *QueuedIndexer) FlushIndex() error {
+	self.waitForIdle()
+	return self.Indexer.FlushIndex()
+}
+
+// Wait for all queued writes to be performed, then refresh the underlying indexer (if supported).
+func (self *QueuedIndexer) RefreshIndex(collection *dal.Collection) error {
+	self.waitForIdle()
+
+	if refresher, ok := self.Indexer.(IndexRefresher); ok {
+		return refresher.RefreshIndex(collection)
+	}
+
+	return self.Indexer.FlushIndex()
+}
+
+func (self *QueuedIndexer) waitForIdle() {
+	self.idle.L.Lock()
+	defer self.idle.L.Unlock()
+
+	for !self.isClosed() && (atomic.LoadInt64(&self.pending) > 0 || atomic.LoadInt64(&self.spilled) > 0) {
+		self.idle.Wait()
+	}
+}
+
+// Stop processing the queue.  Writes that have not yet been performed are discarded (or, if
+// spilling, remain on disk).
+func (self *QueuedIndexer) Close() error {
+	if atomic.CompareAndSwapInt32(&self.closed, 0, 1) {
+		close(self.stop)
+
+		self.idle.L.Lock()
+		self.idle.Broadcast()
+		self.idle.L.Unlock()
+	}
+
+	return nil
+}
+
+func (self *QueuedIndexer) isClosed() bool {
+	return atomic.LoadInt32(&self.closed) == 1
+}
+
+func (self *QueuedIndexer) enqueue(write *indexWrite) error {
+	self.collections.Store(write.Collection, write.collection)
+
+	// once anything has been spilled, subsequent writes must follow it to disk so that they are
+	// applied in order
+	if atomic.LoadInt64(&self.spilled) > 0 {
+		return self.spill(write)
+	}
+
+	atomic.AddInt64(&self.pending, 1)
+
+	switch self.options.Overflow {
+	case IndexOverflowDrop, IndexOverflowSpill:
+		select {
+		case self.queue <- write:
+		default:
+			atomic.AddInt64(&self.pending, -1)
+
+			if self.options.Overflow == IndexOverflowSpill {
+				return self.spill(write)
+			}
+
+			atomic.AddInt64(&self.dropped, 1)
+			stats.Increment(`pivot.indexers.queue.dropped`)
+			log.Warningf("[%T] index queue full, dropped write to %q", self, write.Collection)
+		}
+	default:
+		self.queue <- write
+	}
+
+	stats.Gauge(`pivot.indexers.queue.depth`, len(self.queue))
+	return nil
+}
+
+func (self *QueuedIndexer) spill(write *indexWrite) error {
+	self.spillLock.Lock()
+	defer self.spillLock.Unlock()
+
+	if data, err := json.Marshal(write); err == nil {
+		if file, err := os.OpenFile(self.options.SpillPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); err == nil {
+			defer file.Close()
+
+			if _, err := file.Write(append(data, '\n')); err != nil {
+				return err
+			}
+
+			atomic.StoreInt64(&self.spilled, 1)
+			stats.Increment(`pivot.indexers.queue.spilled`)
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *QueuedIndexer) run() {
+	var ticker = time.NewTicker(IndexQueueSpillInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case write := <-self.queue:
+			self.apply(write)
+			atomic.AddInt64(&self.pending, -1)
+			stats.Gauge(`pivot.indexers.queue.depth`, len(self.queue))
+			self.signalIfIdle()
+
+		case <-ticker.C:
+			if len(self.queue) == 0 && atomic.LoadInt64(&self.spilled) > 0 {
+				if err := self.replaySpilled(); err != nil {
+					log.Errorf("[%T] failed to replay spilled index writes: %v", self, err)
+				}
+
+				self.signalIfIdle()
+			}
+
+		case <-self.stop:
+			return
+		}
+	}
+}
+
+func (self *QueuedIndexer) signalIfIdle() {
+	if atomic.LoadInt64(&self.pending) == 0 && atomic.LoadInt64(&self.spilled) == 0 {
+		self.idle.L.Lock()
+		self.idle.Broadcast()
+		self.idle.L.Unlock()
+	}
+}
+
+func (self *QueuedIndexer) apply(write *indexWrite) {
+	var err error
+
+	if write.Records != nil {
+		err = self.Indexer.Index(write.collection, write.Records)
+	} else {
+		err = self.Indexer.IndexRemove(write.collection, write.IDs)
+	}
+
+	if err != nil {
+		log.Errorf("[%T] queued index write to %q failed: %v", self, write.Collection, err)
+	}
+}
+
+// replay everything written to the spill file so far.  Writes that arrive while we're doing
+// this are spilled after the ones being replayed, and are picked up by the next replay.
+func (self *QueuedIndexer) replaySpilled() error {
+	self.spillLock.Lock()
+
+	var data, err = ioutil.ReadFile(self.options.SpillPath)
+
+	if err == nil {
+		err = os.Truncate(self.options.SpillPath, 0)
+	}
+
+	if err != nil {
+		self.spillLock.Unlock()
+		return err
+	}
+
+	self.spillLock.Unlock()
+
+	var scanner = bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 65536), len(data)+1)
+
+	for scanner.Scan() {
+		var write indexWrite
+
+		if err := json.Unmarshal(scanner.Bytes(), &write); err != nil {
+			log.Warningf("[%T] skipping unreadable spilled index write: %v", self, err)
+			continue
+		}
+
+		if collection, ok := self.collections.Load(write.Collection); ok {
+			write.collection = collection.(*dal.Collection)
+		} else if backend := self.GetBackend(); backend != nil {
+			if collection, err := backend.GetCollection(write.Collection); err == nil {
+				write.collection = collection
+			} else {
+				log.Warningf("[%T] skipping spilled index write to %q: %v", self, write.Collection, err)
+				continue
+			}
+		} else {
+			log.Warningf("[%T] skipping spilled index write to unknown collection %q", self, write.Collection)
+			continue
+		}
+
+		self.apply(&write)
+	}
+
+	// only resume queueing in memory if nothing else was spilled in the meantime
+	self.spillLock.Lock()
+	defer self.spillLock.Unlock()
+
+	if stat, err := os.Stat(self.options.SpillPath); err == nil && stat.Size() == 0 {
+		atomic.StoreInt64(&self.spilled, 0)
+	}
+
+	return scanner.Err()
+}
+
+// unwrap queued indexers to get at the capabilities (e.g.: aggregation) of the real one.
+func underlyingIndexer(indexer Indexer) Indexer {
+	if queued, ok := indexer.(*QueuedIndexer); ok {
+		return queued.GetIndexer()
+	}
+
+	return indexer
+}
@@ -0,0 +1,96 @@
+package backends_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// a backend whose queries are served by a separate index
+type externallyIndexed struct {
+	backends.Backend
+	index backends.Indexer
+}
+
+func (self *externallyIndexed) WithSearch(collection *dal.Collection, filters ...*filter.Filter) backends.Indexer {
+	return self.index
+}
+
+// an index that actually removes entries when asked to
+type removingIndex struct {
+	*spi.Adapter
+}
+
+func (self removingIndex) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return self.Delete(collection.Name, ids...)
+}
+
+func TestIndexGarbageCollector(t *testing.T) {
+	assert := require.New(t)
+
+	data := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	index := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	backend := &externallyIndexed{
+		Backend: data,
+		index:   removingIndex{index},
+	}
+
+	collection := dal.NewCollection(`things`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	assert.NoError(data.CreateCollection(collection))
+	assert.NoError(index.CreateCollection(collection))
+
+	for i := 1; i <= 5; i++ {
+		record := dal.NewRecord(i).Set(`name`, `thing`)
+
+		assert.NoError(data.Insert(`things`, dal.NewRecordSet(record)))
+		assert.NoError(index.Insert(`things`, dal.NewRecordSet(record)))
+	}
+
+	// delete some records without telling the index
+	assert.NoError(data.Delete(`things`, 2, 4))
+
+	// dry runs only report orphans
+	reports, err := backends.NewIndexGarbageCollector(backend, backends.IndexGCOptions{
+		DryRun: true,
+	}).Run()
+
+	assert.NoError(err)
+	assert.Len(reports, 1)
+	assert.Equal(5, reports[0].Scanned)
+	assert.Equal([]interface{}{2, 4}, reports[0].Orphans)
+	assert.False(reports[0].Removed)
+	assert.True(index.Exists(`things`, 2))
+
+	// sampling picks up where the last run left off
+	gc := backends.NewIndexGarbageCollector(backend, backends.IndexGCOptions{
+		SampleSize: 3,
+	})
+
+	reports, err = gc.Run(`things`)
+	assert.NoError(err)
+	assert.Equal(3, reports[0].Scanned)
+	assert.Equal([]interface{}{2}, reports[0].Orphans)
+	assert.True(reports[0].Removed)
+	assert.False(index.Exists(`things`, 2))
+
+	reports, err = gc.Run(`things`)
+	assert.NoError(err)
+	assert.Equal(2, reports[0].Scanned)
+	assert.Equal([]interface{}{4}, reports[0].Orphans)
+	assert.False(index.Exists(`things`, 4))
+	assert.True(index.Exists(`things`, 5))
+
+	// collections that aren't externally indexed are skipped
+	reports, err = backends.NewIndexGarbageCollector(data, backends.IndexGCOptions{}).Run()
+	assert.NoError(err)
+	assert.Empty(reports)
+}
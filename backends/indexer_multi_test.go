@@ -0,0 +1,149 @@
+package backends_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// an indexer that takes a fixed amount of time to answer queries, and can be made to fail them or
+// to refuse filters on a given field
+type latencyIndexer struct {
+	backends.Indexer
+	delay     time.Duration
+	lock      sync.Mutex
+	failing   bool
+	queries   int
+	cannotUse string
+}
+
+func (self *latencyIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn backends.IndexResultFunc) error {
+	self.lock.Lock()
+	self.queries += 1
+	var failing = self.failing
+	self.lock.Unlock()
+
+	time.Sleep(self.delay)
+
+	if failing {
+		return fmt.Errorf("indexer unavailable")
+	}
+
+	return self.Indexer.QueryFunc(collection, f, resultFn)
+}
+
+func (self *latencyIndexer) Query(collection *dal.Collection, f *filter.Filter, resultFns ...backends.IndexResultFunc) (*dal.RecordSet, error) {
+	return backends.DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+func (self *latencyIndexer) CanQuery(collection *dal.Collection, f *filter.Filter) bool {
+	for _, field := range f.CriteriaFields() {
+		if field == self.cannotUse {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (self *latencyIndexer) setFailing(failing bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.failing = failing
+}
+
+func (self *latencyIndexer) queryCount() int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.queries
+}
+
+func TestMultiIndexFastest(t *testing.T) {
+	assert := require.New(t)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`things`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	assert.NoError(backend.Insert(`things`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `a`),
+		dal.NewRecord(2).Set(`name`, `b`),
+	)))
+
+	collection, err := backend.GetCollection(`things`)
+	assert.NoError(err)
+
+	slow := &latencyIndexer{
+		Indexer: backend.WithSearch(collection),
+		delay:   20 * time.Millisecond,
+	}
+
+	fast := &latencyIndexer{
+		Indexer:   backend.WithSearch(collection),
+		cannotUse: `slow_only`,
+	}
+
+	multi := backends.NewMultiIndex()
+	assert.NoError(multi.AddIndexer(slow))
+	assert.NoError(multi.AddIndexer(fast))
+
+	// each indexer is tried once to learn its latency, after which the fastest one is preferred
+	for i := 0; i < 5; i++ {
+		recordset, err := multi.Query(collection, filter.All())
+		assert.NoError(err)
+		assert.Len(recordset.Records, 2)
+	}
+
+	assert.Equal(1, slow.queryCount())
+	assert.Equal(4, fast.queryCount())
+
+	health := multi.Health()
+	assert.Len(health, 2)
+	assert.True(health[0].Healthy)
+	assert.True(health[1].Healthy)
+	assert.True(health[1].Latency < health[0].Latency)
+	assert.EqualValues(4, health[1].Requests)
+
+	// queries the fastest indexer can't serve are routed to the others
+	_, err = multi.Query(collection, filter.MustParse(`slow_only/x`))
+	assert.NoError(err)
+	assert.Equal(2, slow.queryCount())
+	assert.Equal(4, fast.queryCount())
+
+	// failed queries fail over to the next fastest indexer, which is then preferred until the
+	// failed one is due to be retried
+	fast.setFailing(true)
+
+	recordset, err := multi.Query(collection, filter.MustParse(`name/b`))
+	assert.NoError(err)
+	assert.Len(recordset.Records, 1)
+	assert.Equal(3, slow.queryCount())
+	assert.Equal(5, fast.queryCount())
+
+	health = multi.Health()
+	assert.False(health[1].Healthy)
+	assert.EqualValues(1, health[1].Failures)
+	assert.Equal(`indexer unavailable`, health[1].LastError)
+
+	_, err = multi.Query(collection, filter.All())
+	assert.NoError(err)
+	assert.Equal(4, slow.queryCount())
+	assert.Equal(5, fast.queryCount())
+
+	// the error is returned once every indexer has failed
+	slow.setFailing(true)
+
+	_, err = multi.Query(collection, filter.All())
+	assert.Error(err)
+}
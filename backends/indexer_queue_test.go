@@ -0,0 +1,145 @@
+package backends_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+// records index writes, optionally waiting for the gate to open before each one
+type recordingIndexer struct {
+	backends.Indexer
+	gate    chan bool
+	lock    sync.Mutex
+	indexed []interface{}
+	removed []interface{}
+}
+
+func (self *recordingIndexer) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	if self.gate != nil {
+		<-self.gate
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	for _, record := range records.Records {
+		self.indexed = append(self.indexed, record.ID)
+	}
+
+	return nil
+}
+
+func (self *recordingIndexer) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.removed = append(self.removed, ids...)
+	return nil
+}
+
+func (self *recordingIndexer) FlushIndex() error {
+	return nil
+}
+
+func (self *recordingIndexer) GetBackend() backends.Backend {
+	return nil
+}
+
+func TestQueuedIndexerDrop(t *testing.T) {
+	assert := require.New(t)
+
+	inner := &recordingIndexer{
+		gate: make(chan bool),
+	}
+
+	indexer, err := backends.NewQueuedIndexer(inner, backends.IndexQueueOptions{
+		Size:     1,
+		Overflow: backends.IndexOverflowDrop,
+	})
+
+	assert.NoError(err)
+	defer indexer.Close()
+
+	collection := dal.NewCollection(`things`)
+
+	// the first write is picked up by the worker (which waits on the gate), the second fills the
+	// queue, and the rest are dropped
+	assert.NoError(indexer.Index(collection, dal.NewRecordSet(dal.NewRecord(1))))
+
+	for indexer.QueueStatus().Depth > 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.NoError(indexer.Index(collection, dal.NewRecordSet(dal.NewRecord(2))))
+	assert.NoError(indexer.Index(collection, dal.NewRecordSet(dal.NewRecord(3))))
+	assert.NoError(indexer.Index(collection, dal.NewRecordSet(dal.NewRecord(4))))
+
+	status := indexer.QueueStatus()
+	assert.Equal(1, status.Depth)
+	assert.Equal(1, status.Capacity)
+	assert.EqualValues(2, status.Dropped)
+
+	close(inner.gate)
+	assert.NoError(indexer.FlushIndex())
+	assert.Equal([]interface{}{1, 2}, inner.indexed)
+}
+
+func TestQueuedIndexerSpill(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-index-queue-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	backends.IndexQueueSpillInterval = 10 * time.Millisecond
+
+	inner := &recordingIndexer{
+		gate: make(chan bool),
+	}
+
+	indexer, err := backends.NewQueuedIndexer(inner, backends.IndexQueueOptions{
+		Size:      1,
+		Overflow:  backends.IndexOverflowSpill,
+		SpillPath: filepath.Join(dir, `spill.ndjson`),
+	})
+
+	assert.NoError(err)
+	defer indexer.Close()
+
+	collection := dal.NewCollection(`things`)
+
+	assert.NoError(indexer.Index(collection, dal.NewRecordSet(dal.NewRecord(1))))
+
+	for indexer.QueueStatus().Depth > 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.NoError(indexer.Index(collection, dal.NewRecordSet(dal.NewRecord(2))))
+	assert.NoError(indexer.Index(collection, dal.NewRecordSet(dal.NewRecord(3))))
+	assert.NoError(indexer.IndexRemove(collection, []interface{}{1}))
+	assert.True(indexer.QueueStatus().Spilled)
+
+	close(inner.gate)
+	assert.NoError(indexer.FlushIndex())
+
+	// spilled writes are replayed in order, after the ones that were queued in memory
+	assert.Len(inner.indexed, 3)
+	assert.Equal([]interface{}{1, 2}, inner.indexed[0:2])
+	assert.EqualValues(3, inner.indexed[2])
+	assert.Len(inner.removed, 1)
+	assert.False(indexer.QueueStatus().Spilled)
+
+	_, err = backends.NewQueuedIndexer(inner, backends.IndexQueueOptions{
+		Overflow: backends.IndexOverflowSpill,
+	})
+
+	assert.Error(err)
+}
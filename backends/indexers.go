@@ -43,14 +43,27 @@ type Indexer interface {
 func MakeIndexer(connection dal.ConnectionString) (Indexer, error) {
 	log.Infof("Creating indexer: %v", connection.String())
 
+	var indexer Indexer
+	var queueOptions, queued = IndexQueueOptionsFromConnectionString(&connection)
+
 	switch connection.Backend() {
 	case `bleve`:
-		return NewBleveIndexer(connection), nil
+		indexer = NewBleveIndexer(connection)
 	case `elasticsearch`:
-		return NewElasticsearchIndexer(connection), nil
+		indexer = NewElasticsearchIndexer(connection)
 	default:
 		return nil, fmt.Errorf("Unknown indexer type %q", connection.Backend())
 	}
+
+	if queued {
+		if queuedIndexer, err := NewQueuedIndexer(indexer, queueOptions); err == nil {
+			return queuedIndexer, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	return indexer, nil
 }
 
 func PopulateRecordSetPageDetails(recordset *dal.RecordSet, f *filter.Filter, page IndexPage) {
@@ -152,6 +165,7 @@ func DefaultQueryImplementation(indexer Indexer, collection *dal.Collection, f *
 				return resultFn(emptyRecord, err, page)
 			} else if parent != nil && !forceIndexRecord {
 				if record, err := parent.Retrieve(collection.Name, indexRecord.ID, f.Fields...); err == nil {
+					record.Score = indexRecord.Score
 					return resultFn(record, err, page)
 				} else {
 					return resultFn(emptyRecord, err, page)
@@ -165,6 +179,7 @@ func DefaultQueryImplementation(indexer Indexer, collection *dal.Collection, f *
 
 			} else if parent != nil && !forceIndexRecord {
 				if record, err := parent.Retrieve(collection.Name, indexRecord.ID, f.Fields...); err == nil {
+					record.Score = indexRecord.Score
 					recordset.Records = append(recordset.Records, record)
 
 				} else {
@@ -0,0 +1,273 @@
+package backends
+
+import (
+	"fmt"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// Specifies what (if anything) should be done with records whose relationships point at
+// records that do not exist.
+type IntegrityRepairMode string
+
+const (
+	IntegrityReportOnly IntegrityRepairMode = ``
+	IntegrityNullify    IntegrityRepairMode = `nullify`
+	IntegrityDelete     IntegrityRepairMode = `delete`
+)
+
+func ParseIntegrityRepairMode(in string) (IntegrityRepairMode, error) {
+	switch mode := IntegrityRepairMode(in); mode {
+	case IntegrityReportOnly, IntegrityNullify, IntegrityDelete:
+		return mode, nil
+	case `report`, `none`:
+		return IntegrityReportOnly, nil
+	default:
+		return IntegrityReportOnly, fmt.Errorf("Unsupported repair mode %q", in)
+	}
+}
+
+// Represents a single record that references a related record which does not exist.
+type Orphan struct {
+	Collection        string      `json:"collection"`
+	ID                interface{} `json:"id"`
+	Field             string      `json:"field"`
+	RelatedCollection string      `json:"related_collection"`
+	RelatedField      string      `json:"related_field,omitempty"`
+	Value             interface{} `json:"value"`
+	Repaired          bool        `json:"repaired,omitempty"`
+}
+
+func (self *Orphan) String() string {
+	return fmt.Sprintf(
+		"%s[%v].%s -> %s(%v)",
+		self.Collection,
+		self.ID,
+		self.Field,
+		self.RelatedCollection,
+		self.Value,
+	)
+}
+
+// Contains the results of scanning one or more collections for broken references.
+type IntegrityReport struct {
+	Mode           IntegrityRepairMode `json:"mode,omitempty"`
+	Collections    []string            `json:"collections"`
+	RecordsScanned int64               `json:"records_scanned"`
+	Orphans        []*Orphan           `json:"orphans"`
+	Errors         []string            `json:"errors,omitempty"`
+}
+
+// Returns whether any broken references were found.
+func (self *IntegrityReport) OK() bool {
+	return len(self.Orphans) == 0
+}
+
+func (self *IntegrityReport) addError(err error) {
+	if err != nil {
+		self.Errors = append(self.Errors, err.Error())
+	}
+}
+
+// Scans the given collections (or all collections if none are given) for records whose constraints
+// (including those declared with BelongsTo) reference records that do not exist.  This is
+// primarily useful for backends that do not natively enforce constraints (e.g.: filesystem,
+// DynamoDB, MongoDB).  If a repair mode other than IntegrityReportOnly is given, orphaned records
+// will either have the offending field set to null (or the offending values removed, for fields
+// holding multiple references), or be deleted outright.
+func CheckIntegrity(backend Backend, mode IntegrityRepairMode, collections ...string) (*IntegrityReport, error) {
+	report := &IntegrityReport{
+		Mode:    mode,
+		Orphans: make([]*Orphan, 0),
+	}
+
+	if len(collections) == 0 {
+		if names, err := backend.ListCollections(); err == nil {
+			collections = names
+		} else {
+			return nil, err
+		}
+	}
+
+	report.Collections = collections
+
+	for _, name := range collections {
+		if collection, err := backend.GetCollection(name); err == nil {
+			if err := checkCollectionIntegrity(backend, collection, mode, report); err != nil {
+				report.addError(fmt.Errorf("%s: %v", name, err))
+			}
+		} else {
+			report.addError(fmt.Errorf("%s: %v", name, err))
+		}
+	}
+
+	return report, nil
+}
+
+func checkCollectionIntegrity(backend Backend, collection *dal.Collection, mode IntegrityRepairMode, report *IntegrityReport) error {
+	constraints := collection.GetAllConstraints()
+
+	if len(constraints) == 0 {
+		return nil
+	}
+
+	search := backend.WithSearch(collection)
+
+	if search == nil {
+		return fmt.Errorf("backend %v does not support enumerating records", backend)
+	}
+
+	orphaned := make([]*dal.Record, 0)
+	cache := make(map[string]bool)
+
+	if err := search.QueryFunc(collection, filter.All(), func(record *dal.Record, err error, _ IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		var isOrphan bool
+
+		report.RecordsScanned += 1
+
+		for _, constraint := range constraints {
+			localField := typeutil.String(constraint.On)
+			remoteField := typeutil.String(constraint.Field)
+			value := record.Get(localField)
+
+			if typeutil.IsZero(value) {
+				continue
+			}
+
+			var dangling []interface{}
+
+			for _, v := range sliceutil.Sliceify(value) {
+				key := fmt.Sprintf("%s:%s:%v", constraint.Collection, remoteField, v)
+				exists, ok := cache[key]
+
+				if !ok {
+					if e, err := relatedRecordExists(backend, &constraint, v); err == nil {
+						exists = e
+						cache[key] = exists
+					} else {
+						return err
+					}
+				}
+
+				if !exists {
+					report.Orphans = append(report.Orphans, &Orphan{
+						Collection:        collection.Name,
+						ID:                record.ID,
+						Field:             localField,
+						RelatedCollection: constraint.Collection,
+						RelatedField:      remoteField,
+						Value:             v,
+					})
+
+					dangling = append(dangling, v)
+					isOrphan = true
+				}
+			}
+
+			if mode == IntegrityNullify && len(dangling) > 0 {
+				if typeutil.IsArray(value) {
+					// only the broken references are removed from multi-valued fields
+					kept := make([]interface{}, 0)
+
+					for _, v := range sliceutil.Sliceify(value) {
+						if !sliceutil.Contains(dangling, v) {
+							kept = append(kept, v)
+						}
+					}
+
+					record.SetNested(localField, kept)
+				} else {
+					// an explicit null, since an unset field would be given its zero value on update
+					record.SetNested(localField, dal.Null)
+				}
+			}
+		}
+
+		if isOrphan {
+			orphaned = append(orphaned, record)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if len(orphaned) == 0 {
+		return nil
+	}
+
+	var err error
+
+	switch mode {
+	case IntegrityNullify:
+		err = backend.Update(collection.Name, dal.NewRecordSet(orphaned...))
+	case IntegrityDelete:
+		ids := make([]interface{}, len(orphaned))
+
+		for i, record := range orphaned {
+			ids[i] = record.ID
+		}
+
+		err = backend.Delete(collection.Name, ids...)
+	default:
+		return nil
+	}
+
+	if err == nil {
+		for _, orphan := range report.Orphans {
+			if orphan.Collection == collection.Name {
+				orphan.Repaired = true
+			}
+		}
+
+		log.Noticef("[%v] Repaired %d orphaned records in %q (mode: %v)", backend, len(orphaned), collection.Name, mode)
+	}
+
+	return err
+}
+
+func relatedRecordExists(backend Backend, constraint *dal.Constraint, value interface{}) (bool, error) {
+	related, err := backend.GetCollection(constraint.Collection)
+
+	if err != nil {
+		if dal.IsCollectionNotFoundErr(err) {
+			return false, nil
+		} else {
+			return false, err
+		}
+	}
+
+	remoteField := typeutil.String(constraint.Field)
+
+	// lookups by primary key are the cheapest thing we can do
+	if remoteField == `` || related.IsIdentityField(remoteField) {
+		return backend.Exists(related.Name, value), nil
+	}
+
+	if search := backend.WithSearch(related); search != nil {
+		if f, err := filter.FromMap(map[string]interface{}{
+			remoteField: value,
+		}); err == nil {
+			f.Limit = 1
+			f.IdentityField = related.GetIdentityFieldName()
+
+			if recordset, err := search.Query(related, f); err == nil {
+				return (len(recordset.Records) > 0), nil
+			} else {
+				return false, err
+			}
+		} else {
+			return false, err
+		}
+	} else {
+		return false, fmt.Errorf("backend %v cannot search related collection %q", backend, related.Name)
+	}
+}
@@ -0,0 +1,149 @@
+package backends_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func newIntegrityTestBackend(t *testing.T) backends.Backend {
+	assert := require.New(t)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`authors`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`books`, dal.Field{
+		Name:      `author_id`,
+		Type:      dal.IntType,
+		BelongsTo: `authors`,
+	}, dal.Field{
+		Name:      `editor_ids`,
+		Type:      dal.ArrayType,
+		BelongsTo: `authors`,
+	})))
+
+	assert.NoError(backend.Insert(`authors`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `Alice`),
+		dal.NewRecord(2).Set(`name`, `Bob`),
+	)))
+
+	assert.NoError(backend.Insert(`books`, dal.NewRecordSet(
+		dal.NewRecord(10).Set(`author_id`, 1).Set(`editor_ids`, []interface{}{1, 3, 2}),
+		dal.NewRecord(11).Set(`author_id`, 4).Set(`editor_ids`, []interface{}{2}),
+		dal.NewRecord(12).Set(`author_id`, 2),
+	)))
+
+	return backend
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	assert := require.New(t)
+	backend := newIntegrityTestBackend(t)
+
+	report, err := backends.CheckIntegrity(backend, backends.IntegrityReportOnly)
+	assert.NoError(err)
+	assert.Empty(report.Errors)
+	assert.False(report.OK())
+	assert.ElementsMatch([]string{`authors`, `books`}, report.Collections)
+	assert.EqualValues(3, report.RecordsScanned)
+	assert.Len(report.Orphans, 2)
+
+	var found = make(map[string]*backends.Orphan)
+
+	for _, orphan := range report.Orphans {
+		assert.Equal(`books`, orphan.Collection)
+		assert.Equal(`authors`, orphan.RelatedCollection)
+		assert.False(orphan.Repaired)
+		found[orphan.Field] = orphan
+	}
+
+	assert.EqualValues(11, found[`author_id`].ID)
+	assert.EqualValues(4, found[`author_id`].Value)
+	assert.EqualValues(10, found[`editor_ids`].ID)
+	assert.EqualValues(3, found[`editor_ids`].Value)
+
+	// nothing is changed when only reporting
+	record, err := backend.Retrieve(`books`, 11)
+	assert.NoError(err)
+	assert.EqualValues(4, record.Get(`author_id`))
+}
+
+func TestCheckIntegrityNullify(t *testing.T) {
+	assert := require.New(t)
+	backend := newIntegrityTestBackend(t)
+
+	report, err := backends.CheckIntegrity(backend, backends.IntegrityNullify, `books`)
+	assert.NoError(err)
+	assert.Empty(report.Errors)
+	assert.Len(report.Orphans, 2)
+
+	for _, orphan := range report.Orphans {
+		assert.True(orphan.Repaired)
+	}
+
+	// multi-valued fields only lose the references that are broken
+	record, err := backend.Retrieve(`books`, 10)
+	assert.NoError(err)
+	assert.EqualValues(1, record.Get(`author_id`))
+	assert.Equal([]string{`1`, `2`}, sliceutil.Stringify(record.Get(`editor_ids`)))
+
+	record, err = backend.Retrieve(`books`, 11)
+	assert.NoError(err)
+	authorId := record.Get(`author_id`)
+	assert.True(authorId == nil || dal.IsNull(authorId))
+	assert.Equal([]string{`2`}, sliceutil.Stringify(record.Get(`editor_ids`)))
+
+	// ...and everything checks out afterwards
+	report, err = backends.CheckIntegrity(backend, backends.IntegrityReportOnly, `books`)
+	assert.NoError(err)
+	assert.True(report.OK())
+	assert.True(backend.Exists(`books`, 12))
+}
+
+func TestCheckIntegrityDelete(t *testing.T) {
+	assert := require.New(t)
+	backend := newIntegrityTestBackend(t)
+
+	report, err := backends.CheckIntegrity(backend, backends.IntegrityDelete, `books`)
+	assert.NoError(err)
+	assert.Empty(report.Errors)
+	assert.Len(report.Orphans, 2)
+
+	assert.False(backend.Exists(`books`, 10))
+	assert.False(backend.Exists(`books`, 11))
+	assert.True(backend.Exists(`books`, 12))
+	assert.True(backend.Exists(`authors`, 1))
+
+	report, err = backends.CheckIntegrity(backend, backends.IntegrityReportOnly, `books`)
+	assert.NoError(err)
+	assert.True(report.OK())
+	assert.EqualValues(1, report.RecordsScanned)
+}
+
+func TestParseIntegrityRepairMode(t *testing.T) {
+	assert := require.New(t)
+
+	for in, mode := range map[string]backends.IntegrityRepairMode{
+		``:        backends.IntegrityReportOnly,
+		`report`:  backends.IntegrityReportOnly,
+		`none`:    backends.IntegrityReportOnly,
+		`nullify`: backends.IntegrityNullify,
+		`delete`:  backends.IntegrityDelete,
+	} {
+		actual, err := backends.ParseIntegrityRepairMode(in)
+		assert.NoError(err)
+		assert.Equal(mode, actual)
+	}
+
+	_, err := backends.ParseIntegrityRepairMode(`explode`)
+	assert.Error(err)
+}
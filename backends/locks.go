@@ -0,0 +1,79 @@
+package backends
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// How long AcquireLock waits for a lock that is held elsewhere before giving up.
+var AdvisoryLockTimeout = 30 * time.Second
+
+// Whether creating a collection that already exists succeeds (leaving the existing collection
+// as-is) instead of returning a dal.CollectionExistsError.  This can be changed for a specific
+// connection with the "ignore_existing" option.
+var DefaultIgnoreExistingCollections = false
+
+// Implemented by backends that can take a named lock that is shared by every process connected
+// to the same database (e.g.: PostgreSQL's pg_advisory_lock or MySQL's GET_LOCK).  The returned
+// function releases the lock.  Backends return NotImplementedError if the database they're
+// connected to doesn't support advisory locks.
+type AdvisoryLocker interface {
+	AdvisoryLock(name string, timeout time.Duration) (func() error, error)
+}
+
+var localLocks sync.Map
+
+// Acquire the named lock, waiting up to timeout for it to become available, and return a function
+// that releases it.  If the backend (or any backend it wraps) is an AdvisoryLocker, the lock is
+// shared with every process connected to the same database; otherwise it is only shared within
+// this process.
+func AcquireLock(backend Backend, name string, timeout time.Duration) (func() error, error) {
+	for _, backend := range BackendChain(backend) {
+		if locker, ok := backend.(AdvisoryLocker); ok {
+			if unlock, err := locker.AdvisoryLock(name, timeout); err == nil {
+				return unlock, nil
+			} else if err != NotImplementedError {
+				return nil, err
+			}
+
+			break
+		}
+	}
+
+	return acquireLocalLock(name, timeout)
+}
+
+func acquireLocalLock(name string, timeout time.Duration) (func() error, error) {
+	lockI, _ := localLocks.LoadOrStore(name, make(chan struct{}, 1))
+	lock := lockI.(chan struct{})
+
+	select {
+	case lock <- struct{}{}:
+		var once sync.Once
+
+		return func() error {
+			once.Do(func() {
+				<-lock
+			})
+
+			return nil
+		}, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("timed out after %v waiting for lock %q", timeout, name)
+	}
+}
+
+// returns the error that creating an existing collection should result in, given the options of
+// the connection it was attempted on
+func collectionExistsError(conn *dal.ConnectionString, name string) error {
+	if conn != nil && conn.OptBool(`ignore_existing`, DefaultIgnoreExistingCollections) {
+		return nil
+	}
+
+	return dal.CollectionExistsError{
+		Collection: name,
+	}
+}
@@ -0,0 +1,65 @@
+package backends_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireLock(t *testing.T) {
+	assert := require.New(t)
+	backend := backends.NewTimeoutBackend(
+		spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver()),
+		0,
+		0,
+	)
+
+	unlock, err := backends.AcquireLock(backend, `test-lock`, time.Second)
+	assert.NoError(err)
+
+	_, err = backends.AcquireLock(backend, `test-lock`, 10*time.Millisecond)
+	assert.Error(err)
+
+	// other locks are unaffected
+	other, err := backends.AcquireLock(backend, `other-lock`, 10*time.Millisecond)
+	assert.NoError(err)
+	assert.NoError(other())
+
+	var acquired = make(chan error)
+
+	go func() {
+		if unlock, err := backends.AcquireLock(backend, `test-lock`, time.Second); err == nil {
+			acquired <- unlock()
+		} else {
+			acquired <- err
+		}
+	}()
+
+	assert.NoError(unlock())
+	assert.NoError(<-acquired)
+
+	// releasing more than once is harmless
+	assert.NoError(unlock())
+}
+
+func TestCreateExistingCollection(t *testing.T) {
+	assert := require.New(t)
+	collection := dal.NewCollection(`existing`)
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	assert.NoError(backend.CreateCollection(collection))
+
+	err := backend.CreateCollection(collection)
+	assert.True(dal.IsCollectionExistsErr(err))
+	assert.True(dal.IsExistError(err))
+
+	lenient := spi.NewAdapter(dal.MustParseConnectionString(`memory://?ignore_existing=true`), spitest.NewMemoryDriver())
+
+	assert.NoError(lenient.CreateCollection(collection))
+	assert.NoError(lenient.CreateCollection(collection))
+}
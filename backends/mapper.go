@@ -31,4 +31,7 @@ type Mapper interface {
 	Maximum(field string, flt interface{}) (float64, error)
 	Average(field string, flt interface{}) (float64, error)
 	GroupBy(fields []string, aggregates []filter.Aggregate, flt interface{}) (*dal.RecordSet, error)
+	Related(instance interface{}, fieldName string, into interface{}) error
+	Relate(instance interface{}, fieldName string, relatedInstances ...interface{}) error
+	Unrelate(instance interface{}, fieldName string, relatedInstances ...interface{}) error
 }
@@ -2,15 +2,16 @@ package backends
 
 import (
 	"fmt"
-	"sync"
 
-	"github.com/deckarep/golang-set"
 	"github.com/ghetzel/go-stockutil/log"
 	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/ghetzel/pivot/v3/dal"
 	"github.com/ghetzel/pivot/v3/filter"
 )
 
+// The maximum number of distinct join values sent to the right-hand side of a join in a single query.
+var MetaIndexJoinBatchSize = 1000
+
 type MetaIndex struct {
 	leftIndexer     Indexer
 	leftCollection  *dal.Collection
@@ -55,9 +56,21 @@ func (self *MetaIndex) Index(collection *dal.Collection, records *dal.RecordSet)
 	return fmt.Errorf("MetaIndex only supports querying")
 }
 
+// Performs a joined query.  Pagination (limit, offset, and page metadata) is applied to the
+// left-hand (driving) side of the join only; right-hand records are retrieved in batches for each
+// page of left-hand records, and joined results are emitted in left-hand order.  Because each
+// left-hand record may join to zero or more right-hand records, the number of results on a page
+// may differ from the page size, but paging through the results will visit every left-hand
+// record exactly once.
 func (self *MetaIndex) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
-	leftResults := dal.NewRecordSet()
-	uniqueLeftHandValues := mapset.NewSet()
+	_, err := self.query(f, resultFn)
+	return err
+}
+
+// performs the joined query, returning the page details of the last page of left-hand records
+func (self *MetaIndex) query(f *filter.Filter, resultFn IndexResultFunc) (IndexPage, error) {
+	var leftPage IndexPage
+	var pending []*dal.Record
 
 	if f == nil {
 		f = filter.All()
@@ -67,108 +80,138 @@ func (self *MetaIndex) QueryFunc(collection *dal.Collection, f *filter.Filter, r
 	f.Fields = nil
 	f.Options[`ForceIndexRecord`] = true
 
-	// perform query on left side, collect all results
+	// perform query on left side, joining left-hand records to their right-hand counterparts
+	// each time a full batch has been collected
 	if err := self.leftIndexer.QueryFunc(self.leftCollection, f, func(record *dal.Record, err error, page IndexPage) error {
-		if err == nil {
-			leftResults.Push(record)
-			uniqueLeftHandValues.Add(record.Get(self.leftField))
+		if err != nil {
+			return err
+		}
+
+		leftPage = page
+		pending = append(pending, record)
+
+		if len(pending) >= MetaIndexJoinBatchSize {
+			batch := pending
+			pending = nil
+
+			return self.joinBatch(batch, finalFields, leftPage, resultFn)
 		}
 
-		return err
+		return nil
 	}); err != nil {
-		return fmt.Errorf("left-hand index error: %v", err)
+		return leftPage, fmt.Errorf("left-hand index error: %v", err)
 	}
 
-	if rightFilter, err := filter.FromMap(map[string]interface{}{
-		self.rightField: uniqueLeftHandValues.ToSlice(),
-	}); err == nil {
-		var leftRecordIndex sync.Map
-
-		rightFilter.Limit = 2147483647
-
-		if err := self.rightIndexer.QueryFunc(self.rightCollection, rightFilter, func(rightRecord *dal.Record, err error, page IndexPage) error {
-			if err == nil {
-				sharedId := rightRecord.Get(self.rightField)
-				var leftRecordIds []int
-
-				// load left-hand records into a map, indexing their positions by ID
-				// to allow for O(1) accesses later on
-				if ids, ok := leftRecordIndex.Load(sharedId); ok {
-					leftRecordIds = ids.([]int)
-				} else {
-					for i, leftRecord := range leftResults.Records {
-						if leftRecord.Get(self.leftField) == sharedId {
-							leftRecordIds = append(leftRecordIds, i)
-						}
-					}
-
-					leftRecordIndex.Store(sharedId, leftRecordIds)
+	if len(pending) > 0 {
+		return leftPage, self.joinBatch(pending, finalFields, leftPage, resultFn)
+	}
+
+	return leftPage, nil
+}
+
+// the left and right sides may live in different backends, so the right-hand records for each
+// batch of left-hand records are retrieved with a single query on the distinct join values
+func (self *MetaIndex) joinBatch(leftRecords []*dal.Record, finalFields []string, page IndexPage, resultFn IndexResultFunc) error {
+	values := make([]interface{}, 0)
+	rightRecords := make(map[string][]*dal.Record)
+
+	for _, leftRecord := range leftRecords {
+		if value := joinValue(self.leftCollection, self.leftField, leftRecord); value != nil {
+			key := joinKey(value)
+
+			if _, ok := rightRecords[key]; !ok {
+				rightRecords[key] = make([]*dal.Record, 0)
+				values = append(values, value)
+			}
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	rightFilter := filter.MakeFilter()
+	rightFilter.Limit = 2147483647
+	rightFilter.AddCriteria(filter.Criterion{
+		Field:    self.rightField,
+		Operator: `is`,
+		Values:   values,
+	})
+
+	if err := self.rightIndexer.QueryFunc(self.rightCollection, &rightFilter, func(rightRecord *dal.Record, err error, _ IndexPage) error {
+		if err == nil {
+			if sharedId := joinValue(self.rightCollection, self.rightField, rightRecord); sharedId != nil {
+				key := joinKey(sharedId)
+
+				if matches, ok := rightRecords[key]; ok {
+					rightRecords[key] = append(matches, rightRecord)
 				}
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("right-hand index error: %v", err)
+	}
 
-				// for each left-record...
-				for _, lrid := range leftRecordIds {
-					if leftRecord, ok := leftResults.GetRecord(lrid); ok {
-						syntheticRecord := dal.NewRecord([]interface{}{
-							leftRecord.ID,
-							rightRecord.ID,
-						})
-
-						var leftFields = make(map[string]interface{})
-						var rightFields = make(map[string]interface{})
-
-						if len(finalFields) > 0 {
-							for _, pair := range finalFields {
-								cname, field := stringutil.SplitPairTrailing(pair, `.`)
-
-								switch cname {
-								case self.leftCollection.Name:
-									leftFields[field] = leftRecord.Get(field)
-
-								case self.rightCollection.Name:
-									rightFields[field] = rightRecord.Get(field)
-
-								default:
-									leftFields[field] = leftRecord.Get(field)
-									rightFields[field] = rightRecord.Get(field)
-								}
-							}
-						} else {
-							leftFields = leftRecord.Fields
-							rightFields = rightRecord.Fields
-						}
-
-						syntheticRecord.Set(self.leftCollection.Name, leftFields)
-
-						var rightName string
-
-						if self.leftCollection.Name == self.rightCollection.Name {
-							rightName = fmt.Sprintf("%s_right", self.rightCollection.Name)
-						} else {
-							rightName = self.rightCollection.Name
-						}
-
-						syntheticRecord.Set(rightName, rightFields)
-
-						if err := resultFn(syntheticRecord, nil, IndexPage{}); err != nil {
-							log.Error(err)
-							return err
-						}
-					} else {
-						log.Error(fmt.Errorf("Non-existent left-side record index %v", lrid))
-						return fmt.Errorf("Non-existent left-side record index %v", lrid)
-					}
+	// emit results in the order of the driving side so that pagination is stable
+	for _, leftRecord := range leftRecords {
+		if value := joinValue(self.leftCollection, self.leftField, leftRecord); value != nil {
+			for _, rightRecord := range rightRecords[joinKey(value)] {
+				if err := resultFn(self.mergeRecords(leftRecord, rightRecord, finalFields), nil, page); err != nil {
+					log.Error(err)
+					return err
 				}
 			}
+		}
+	}
 
-			return nil
-		}); err != nil {
-			return fmt.Errorf("right-hand index error: %v", err)
+	return nil
+}
+
+func (self *MetaIndex) mergeRecords(leftRecord *dal.Record, rightRecord *dal.Record, finalFields []string) *dal.Record {
+	syntheticRecord := dal.NewRecord([]interface{}{
+		leftRecord.ID,
+		rightRecord.ID,
+	})
+
+	var leftFields = make(map[string]interface{})
+	var rightFields = make(map[string]interface{})
+
+	if len(finalFields) > 0 {
+		for _, pair := range finalFields {
+			cname, field := stringutil.SplitPairTrailing(pair, `.`)
+
+			switch cname {
+			case self.leftCollection.Name:
+				leftFields[field] = leftRecord.Get(field)
+
+			case self.rightCollection.Name:
+				rightFields[field] = rightRecord.Get(field)
+
+			default:
+				leftFields[field] = leftRecord.Get(field)
+				rightFields[field] = rightRecord.Get(field)
+			}
 		}
 	} else {
-		return fmt.Errorf("right-hand filter error: %v", err)
+		leftFields = leftRecord.Fields
+		rightFields = rightRecord.Fields
 	}
 
-	return nil
+	syntheticRecord.Set(self.leftCollection.Name, leftFields)
+
+	var rightName string
+
+	if self.leftCollection.Name == self.rightCollection.Name {
+		rightName = fmt.Sprintf("%s_right", self.rightCollection.Name)
+	} else {
+		rightName = self.rightCollection.Name
+	}
+
+	syntheticRecord.Set(rightName, rightFields)
+
+	return syntheticRecord
 }
 
 func (self *MetaIndex) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
@@ -176,7 +219,31 @@ func (self *MetaIndex) Query(collection *dal.Collection, f *filter.Filter, resul
 		f.IdentityField = ElasticsearchIdentityField
 	}
 
-	return DefaultQueryImplementation(self, collection, f, resultFns...)
+	recordset := dal.NewRecordSet()
+
+	if page, err := self.query(f, func(record *dal.Record, err error, page IndexPage) error {
+		if len(resultFns) > 0 {
+			return resultFns[0](record, err, page)
+		}
+
+		recordset.Push(record)
+		return nil
+	}); err == nil {
+		// page metadata describes the driving (left-hand) side of the join, which is populated
+		// even if none of the left-hand records on this page joined to anything
+		PopulateRecordSetPageDetails(recordset, f, page)
+
+		recordset.Options[`paginated_on`] = self.leftCollection.Name
+		recordset.Options[`joined_results`] = len(recordset.Records)
+
+		if recordset.RecordsPerPage == 0 {
+			recordset.RecordsPerPage = f.Limit
+		}
+
+		return recordset, nil
+	} else {
+		return nil, err
+	}
 }
 
 func (self *MetaIndex) ListValues(collection *dal.Collection, fields []string, filter *filter.Filter) (map[string][]interface{}, error) {
@@ -196,4 +263,18 @@ func (self *MetaIndex) GetBackend() Backend {
 	return self.leftIndexer.GetBackend()
 }
 
+// join values are compared by their string representation, since the same value may be
+// represented by different types depending on which backend it was read from
+func joinKey(value interface{}) string {
+	return fmt.Sprintf("%v", value)
+}
+
+func joinValue(collection *dal.Collection, field string, record *dal.Record) interface{} {
+	if field == `` || field == collection.GetIdentityFieldName() {
+		return record.ID
+	}
+
+	return record.Get(field)
+}
+
 // /api/collections/users.id+teams.user_id/where/
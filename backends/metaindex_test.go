@@ -0,0 +1,142 @@
+package backends_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetaIndexHeterogeneousJoin(t *testing.T) {
+	assert := require.New(t)
+
+	left := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	right := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	users := dal.NewCollection(`users`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	teams := dal.NewCollection(`teams`, dal.Field{
+		Name: `user_id`,
+		Type: dal.IntType,
+	}, dal.Field{
+		Name: `team`,
+		Type: dal.StringType,
+	})
+
+	assert.NoError(left.CreateCollection(users))
+	assert.NoError(right.CreateCollection(teams))
+
+	assert.NoError(left.Insert(`users`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `alice`),
+		dal.NewRecord(2).Set(`name`, `bob`),
+		dal.NewRecord(3).Set(`name`, `carol`),
+	)))
+
+	assert.NoError(right.Insert(`teams`, dal.NewRecordSet(
+		dal.NewRecord(10).Set(`user_id`, 1).Set(`team`, `red`),
+		dal.NewRecord(11).Set(`user_id`, 1).Set(`team`, `blue`),
+		dal.NewRecord(12).Set(`user_id`, 3).Set(`team`, `red`),
+	)))
+
+	batchSize := backends.MetaIndexJoinBatchSize
+	backends.MetaIndexJoinBatchSize = 1
+	defer func() {
+		backends.MetaIndexJoinBatchSize = batchSize
+	}()
+
+	meta := backends.NewMetaIndex(
+		left.WithSearch(users),
+		users,
+		`id`,
+		right.WithSearch(teams),
+		teams,
+		`user_id`,
+	)
+
+	joined := make(map[string][]string)
+
+	assert.NoError(meta.QueryFunc(users, filter.All(), func(record *dal.Record, err error, _ backends.IndexPage) error {
+		assert.NoError(err)
+
+		name := record.Get(`users`).(map[string]interface{})[`name`].(string)
+		team := record.Get(`teams`).(map[string]interface{})[`team`].(string)
+		joined[name] = append(joined[name], team)
+
+		return nil
+	}))
+
+	assert.Len(joined, 2)
+	assert.ElementsMatch([]string{`red`, `blue`}, joined[`alice`])
+	assert.Equal([]string{`red`}, joined[`carol`])
+}
+
+func TestMetaIndexJoinPagination(t *testing.T) {
+	assert := require.New(t)
+
+	left := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	right := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	users := dal.NewCollection(`users`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	teams := dal.NewCollection(`teams`, dal.Field{
+		Name: `user_id`,
+		Type: dal.IntType,
+	})
+
+	assert.NoError(left.CreateCollection(users))
+	assert.NoError(right.CreateCollection(teams))
+
+	assert.NoError(left.Insert(`users`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `alice`),
+		dal.NewRecord(2).Set(`name`, `bob`),
+		dal.NewRecord(3).Set(`name`, `carol`),
+	)))
+
+	// alice has many right-hand records, which must not push bob or carol off of their pages
+	assert.NoError(right.Insert(`teams`, dal.NewRecordSet(
+		dal.NewRecord(10).Set(`user_id`, 1),
+		dal.NewRecord(11).Set(`user_id`, 1),
+		dal.NewRecord(12).Set(`user_id`, 1),
+		dal.NewRecord(13).Set(`user_id`, 2),
+		dal.NewRecord(14).Set(`user_id`, 3),
+	)))
+
+	meta := backends.NewMetaIndex(left.WithSearch(users), users, `id`, right.WithSearch(teams), teams, `user_id`)
+	names := make([]string, 0)
+
+	for offset := 0; offset < 3; offset++ {
+		f := filter.All()
+		f.Limit = 1
+		f.Offset = offset
+
+		recordset, err := meta.Query(users, f)
+		assert.NoError(err)
+		assert.Equal(`users`, recordset.Options[`paginated_on`])
+		assert.Equal(1, recordset.RecordsPerPage)
+		assert.Equal(offset+1, recordset.Page)
+
+		seen := make(map[string]bool)
+
+		for _, record := range recordset.Records {
+			seen[record.Get(`users`).(map[string]interface{})[`name`].(string)] = true
+		}
+
+		assert.Len(seen, 1)
+
+		for name := range seen {
+			names = append(names, name)
+		}
+	}
+
+	assert.Equal([]string{`alice`, `bob`, `carol`}, names)
+}
@@ -0,0 +1,437 @@
+package backends
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/ghodss/yaml"
+)
+
+// The name of the internal collection that records which migration files have been applied.
+var MigrationHistoryName = `__pivot_migrations`
+
+// The key of per-dialect statements that are run on backends with no statements of their own.
+const AnyDialect = `*`
+
+// A single change made by a migration file.  Exactly one of the operations must be given.
+type MigrationOperation struct {
+	// Create the given collection.
+	Create *dal.Collection `json:"create,omitempty"`
+
+	// Delete the named collection.
+	Drop string `json:"drop,omitempty"`
+
+	// Bring the existing collection in line with the given definition, making the same changes
+	// PlanMigration would.
+	Alter *dal.Collection `json:"alter,omitempty"`
+
+	// Statements to run as-is, keyed by the dialect they're written in (e.g.: "postgresql",
+	// "mysql", or "*" for any).
+	Statements map[string][]string `json:"sql,omitempty"`
+}
+
+// Verifies that the operation specifies exactly one change.
+func (self *MigrationOperation) Validate() error {
+	var given int
+
+	if self.Create != nil {
+		given += 1
+
+		if err := self.Create.Check(); err != nil {
+			return fmt.Errorf("create: %v", err)
+		}
+	}
+
+	if self.Drop != `` {
+		given += 1
+	}
+
+	if self.Alter != nil {
+		given += 1
+
+		if err := self.Alter.Check(); err != nil {
+			return fmt.Errorf("alter: %v", err)
+		}
+	}
+
+	if len(self.Statements) > 0 {
+		given += 1
+	}
+
+	switch given {
+	case 0:
+		return fmt.Errorf("operation does not specify a change")
+	case 1:
+		return nil
+	default:
+		return fmt.Errorf("operation must specify exactly one change, got %d", given)
+	}
+}
+
+func (self *MigrationOperation) String() string {
+	if self.Create != nil {
+		return fmt.Sprintf("create %s", self.Create.Name)
+	} else if self.Drop != `` {
+		return fmt.Sprintf("drop %s", self.Drop)
+	} else if self.Alter != nil {
+		return fmt.Sprintf("alter %s", self.Alter.Name)
+	} else {
+		return `sql`
+	}
+}
+
+// Performs the operation against the given backend.
+func (self *MigrationOperation) Apply(backend Backend) error {
+	if err := self.Validate(); err != nil {
+		return err
+	}
+
+	if self.Create != nil {
+		return backend.CreateCollection(self.Create)
+	} else if self.Drop != `` {
+		return backend.DeleteCollection(self.Drop)
+	} else if self.Alter != nil {
+		if plan, err := PlanMigration(backend, self.Alter); err == nil {
+			return ApplyMigration(backend, plan)
+		} else {
+			return err
+		}
+	} else if executor := findStatementExecutor(backend); executor != nil {
+		var dialect = executor.String()
+
+		if statements, ok := self.Statements[dialect]; ok {
+			return executor.ExecStatements(statements...)
+		} else if statements, ok := self.Statements[AnyDialect]; ok {
+			return executor.ExecStatements(statements...)
+		} else {
+			return fmt.Errorf("no statements given for dialect %q", dialect)
+		}
+	} else {
+		return fmt.Errorf("backend %T cannot run statements", backend)
+	}
+}
+
+// A migration file, which makes explicit changes to the schema when applied (up) and reverses them
+// when rolled back (down).  Migration files are named "<version>_<name>.(json|yml|yaml)", and are
+// applied in order of their version.
+type Migration struct {
+	Version  string               `json:"-"`
+	Name     string               `json:"-"`
+	Filename string               `json:"-"`
+	Up       []MigrationOperation `json:"up"`
+	Down     []MigrationOperation `json:"down,omitempty"`
+}
+
+func (self *Migration) String() string {
+	if self.Name != `` {
+		return self.Version + `_` + self.Name
+	} else {
+		return self.Version
+	}
+}
+
+// Verifies that the migration has a version and that all of its operations are valid.
+func (self *Migration) Validate() error {
+	if self.Version == `` {
+		return fmt.Errorf("migration must have a version")
+	} else if len(self.Up) == 0 {
+		return fmt.Errorf("migration %v: no operations given", self)
+	}
+
+	for i, op := range self.Up {
+		if err := op.Validate(); err != nil {
+			return fmt.Errorf("migration %v: up[%d]: %v", self, i, err)
+		}
+	}
+
+	for i, op := range self.Down {
+		if err := op.Validate(); err != nil {
+			return fmt.Errorf("migration %v: down[%d]: %v", self, i, err)
+		}
+	}
+
+	return nil
+}
+
+// Loads a single migration file, whose version and name are taken from its filename.
+func LoadMigrationFile(filename string) (*Migration, error) {
+	var migration Migration
+	var base = filepath.Base(filename)
+	var ext = filepath.Ext(base)
+
+	switch ext {
+	case `.json`, `.yml`, `.yaml`:
+		if data, err := ioutil.ReadFile(filename); err == nil {
+			// JSON is a subset of YAML, so both are decoded the same way
+			if err := yaml.Unmarshal(data, &migration); err != nil {
+				return nil, fmt.Errorf("%v: decode error: %v", filename, err)
+			}
+		} else {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%v: unsupported migration file type %q", filename, ext)
+	}
+
+	var parts = strings.SplitN(strings.TrimSuffix(base, ext), `_`, 2)
+
+	migration.Version = parts[0]
+	migration.Filename = filename
+
+	if len(parts) > 1 {
+		migration.Name = parts[1]
+	}
+
+	if err := migration.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &migration, nil
+}
+
+// Loads all of the migration files in the given directory, ordered by version.
+func LoadMigrations(dir string) ([]*Migration, error) {
+	var migrations = make([]*Migration, 0)
+	var versions = make(map[string]string)
+
+	entries, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		switch filepath.Ext(entry.Name()) {
+		case `.json`, `.yml`, `.yaml`:
+			if migration, err := LoadMigrationFile(filepath.Join(dir, entry.Name())); err == nil {
+				if other, ok := versions[migration.Version]; ok {
+					return nil, fmt.Errorf("migrations %v and %v have the same version", other, entry.Name())
+				}
+
+				versions[migration.Version] = entry.Name()
+				migrations = append(migrations, migration)
+			} else {
+				return nil, err
+			}
+		}
+	}
+
+	SortMigrations(migrations)
+
+	return migrations, nil
+}
+
+// Sorts migrations by version.  Versions that are both numbers are compared numerically, so that
+// they need not be zero-padded.
+func SortMigrations(migrations []*Migration) {
+	sort.SliceStable(migrations, func(i int, j int) bool {
+		return migrationVersionLess(migrations[i].Version, migrations[j].Version)
+	})
+}
+
+// The state of a migration file in a backend.
+type MigrationStatus struct {
+	Migration *Migration `json:"-"`
+	Version   string     `json:"version"`
+	Name      string     `json:"name,omitempty"`
+	Applied   bool       `json:"applied"`
+	AppliedAt time.Time  `json:"applied_at,omitempty"`
+}
+
+// Returns the versions of the migrations that have been applied to the backend, and when.
+func AppliedMigrations(backend Backend) (map[string]time.Time, error) {
+	var applied = make(map[string]time.Time)
+	var f = filter.All()
+
+	collection, err := backend.GetCollection(MigrationHistoryName)
+
+	if dal.IsCollectionNotFoundErr(err) {
+		return applied, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if search := backend.WithSearch(collection, f); search != nil {
+		if err := search.QueryFunc(collection, f, func(record *dal.Record, err error, _ IndexPage) error {
+			if err != nil {
+				return err
+			}
+
+			applied[typeutil.String(record.ID)] = typeutil.V(record.Get(`applied_at`)).Time()
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("collection %q is not enumerable", MigrationHistoryName)
+	}
+
+	return applied, nil
+}
+
+// Returns whether each of the given migrations has been applied to the backend.
+func GetMigrationStatus(backend Backend, migrations []*Migration) ([]*MigrationStatus, error) {
+	var statuses = make([]*MigrationStatus, 0, len(migrations))
+
+	if applied, err := AppliedMigrations(backend); err == nil {
+		for _, migration := range migrations {
+			var status = &MigrationStatus{
+				Migration: migration,
+				Version:   migration.Version,
+				Name:      migration.Name,
+			}
+
+			if at, ok := applied[migration.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = at
+			}
+
+			statuses = append(statuses, status)
+		}
+
+		return statuses, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Applies, in order, all of the given migrations that haven't already been applied to the backend,
+// up to and including the given version (or all of them if the version is empty).  Each migration
+// is recorded as applied once all of its operations have succeeded; operations are not run in a
+// transaction, so if one fails the migration's earlier changes remain and must be reconciled by
+// hand.  The migrations that were applied are returned.
+func MigrateUp(backend Backend, migrations []*Migration, to string) ([]*Migration, error) {
+	var done = make([]*Migration, 0)
+
+	statuses, err := GetMigrationStatus(backend, migrations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range statuses {
+		if to != `` && migrationVersionLess(to, status.Version) {
+			break
+		} else if status.Applied {
+			continue
+		}
+
+		for i, op := range status.Migration.Up {
+			if err := op.Apply(backend); err != nil {
+				return done, fmt.Errorf("migration %v: up[%d] (%v): %v", status.Migration, i, &op, err)
+			}
+		}
+
+		if err := recordMigration(backend, status.Migration); err != nil {
+			return done, fmt.Errorf("migration %v: %v", status.Migration, err)
+		}
+
+		log.Noticef("[%v] applied migration %v", backend, status.Migration)
+		done = append(done, status.Migration)
+	}
+
+	return done, nil
+}
+
+// Rolls back the given number of the most recently applied migrations (by version) by performing
+// their down operations in order.  Migrations that don't specify any down operations can't be
+// rolled back.  The migrations that were rolled back are returned.
+func MigrateDown(backend Backend, migrations []*Migration, count int) ([]*Migration, error) {
+	var done = make([]*Migration, 0)
+
+	statuses, err := GetMigrationStatus(backend, migrations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(statuses) - 1; i >= 0 && len(done) < count; i-- {
+		var migration = statuses[i].Migration
+
+		if !statuses[i].Applied {
+			continue
+		} else if len(migration.Down) == 0 {
+			return done, fmt.Errorf("migration %v cannot be rolled back", migration)
+		}
+
+		for j, op := range migration.Down {
+			if err := op.Apply(backend); err != nil {
+				return done, fmt.Errorf("migration %v: down[%d] (%v): %v", migration, j, &op, err)
+			}
+		}
+
+		if err := backend.Delete(MigrationHistoryName, migration.Version); err != nil {
+			return done, fmt.Errorf("migration %v: %v", migration, err)
+		}
+
+		log.Noticef("[%v] rolled back migration %v", backend, migration)
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Implemented by backends that can run statements written in their native query language, which is
+// named by the backend's String() method.
+type StatementExecutor interface {
+	String() string
+	ExecStatements(statements ...string) error
+}
+
+func findStatementExecutor(backend Backend) StatementExecutor {
+	for _, backend := range BackendChain(backend) {
+		if executor, ok := backend.(StatementExecutor); ok {
+			return executor
+		}
+	}
+
+	return nil
+}
+
+func recordMigration(backend Backend, migration *Migration) error {
+	if _, err := backend.GetCollection(MigrationHistoryName); dal.IsCollectionNotFoundErr(err) {
+		if err := backend.CreateCollection(migrationHistoryCollection()); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	return backend.Insert(MigrationHistoryName, dal.NewRecordSet(
+		dal.NewRecord(migration.Version).Set(`name`, migration.Name).Set(`applied_at`, time.Now()),
+	))
+}
+
+func migrationHistoryCollection() *dal.Collection {
+	collection := dal.NewCollection(MigrationHistoryName,
+		dal.Field{Name: `name`, Type: dal.StringType},
+		dal.Field{Name: `applied_at`, Type: dal.TimeType},
+	)
+
+	collection.IdentityFieldType = dal.StringType
+
+	return collection
+}
+
+func migrationVersionLess(a string, b string) bool {
+	if an, err := strconv.ParseUint(a, 10, 64); err == nil {
+		if bn, err := strconv.ParseUint(b, 10, 64); err == nil {
+			return an < bn
+		}
+	}
+
+	return a < b
+}
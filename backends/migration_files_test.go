@@ -0,0 +1,100 @@
+package backends_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationFiles(t *testing.T) {
+	assert := require.New(t)
+
+	dir, err := ioutil.TempDir(``, `pivot-migrations-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for filename, data := range map[string]string{
+		`1_create_users.yml`: "up:\n" +
+			"- create:\n" +
+			"    name: users\n" +
+			"    fields:\n" +
+			"    - name: email\n" +
+			"      type: str\n" +
+			"down:\n" +
+			"- drop: users\n",
+		`2_create_groups.json`: `{"up": [{"create": {"name": "groups"}}], "down": [{"drop": "groups"}]}`,
+		`10_backfill.json`:     `{"up": [{"sql": {"postgresql": ["UPDATE users SET email = lower(email)"]}}]}`,
+		`README.md`:            `not a migration`,
+	} {
+		assert.NoError(ioutil.WriteFile(filepath.Join(dir, filename), []byte(data), 0644))
+	}
+
+	migrations, err := backends.LoadMigrations(dir)
+	assert.NoError(err)
+	assert.Len(migrations, 3)
+
+	// versions are ordered numerically
+	assert.Equal(`1`, migrations[0].Version)
+	assert.Equal(`create_users`, migrations[0].Name)
+	assert.Equal(`2`, migrations[1].Version)
+	assert.Equal(`10`, migrations[2].Version)
+	assert.Equal(`backfill`, migrations[2].Name)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	// migrate up to (and including) a specific version
+	applied, err := backends.MigrateUp(backend, migrations, `2`)
+	assert.NoError(err)
+	assert.Len(applied, 2)
+
+	users, err := backend.GetCollection(`users`)
+	assert.NoError(err)
+
+	_, ok := users.GetField(`email`)
+	assert.True(ok)
+
+	_, err = backend.GetCollection(`groups`)
+	assert.NoError(err)
+
+	statuses, err := backends.GetMigrationStatus(backend, migrations)
+	assert.NoError(err)
+	assert.True(statuses[0].Applied)
+	assert.False(statuses[0].AppliedAt.IsZero())
+	assert.True(statuses[1].Applied)
+	assert.False(statuses[2].Applied)
+
+	// applied migrations are not applied again, and raw SQL needs a backend that can run it
+	applied, err = backends.MigrateUp(backend, migrations, ``)
+	assert.Error(err)
+	assert.Empty(applied)
+
+	// roll back the most recent migration
+	rolledBack, err := backends.MigrateDown(backend, migrations, 1)
+	assert.NoError(err)
+	assert.Len(rolledBack, 1)
+	assert.Equal(`2`, rolledBack[0].Version)
+
+	_, err = backend.GetCollection(`groups`)
+	assert.True(dal.IsCollectionNotFoundErr(err))
+
+	_, err = backend.GetCollection(`users`)
+	assert.NoError(err)
+
+	applied, err = backends.MigrateUp(backend, migrations, `2`)
+	assert.NoError(err)
+	assert.Len(applied, 1)
+
+	// operations must specify exactly one change
+	assert.Error((&backends.MigrationOperation{}).Validate())
+	assert.Error((&backends.MigrationOperation{
+		Drop:       `users`,
+		Statements: map[string][]string{`*`: {`DROP TABLE users`}},
+	}).Validate())
+}
@@ -0,0 +1,113 @@
+package backends
+
+import (
+	"fmt"
+
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// A single change needed to bring a collection as it exists in a backend in line with its
+// definition.
+type MigrationStep struct {
+	// The difference between the definition and the backend that this step resolves.
+	Delta *dal.SchemaDelta `json:"delta"`
+
+	// The statement that makes the change (e.g.: SQL DDL), and any values it takes.
+	Statement string        `json:"statement,omitempty"`
+	Values    []interface{} `json:"values,omitempty"`
+
+	// If set, the backend cannot make this change, and this describes why.
+	Error string `json:"error,omitempty"`
+}
+
+// Returns whether the backend can make this change.
+func (self MigrationStep) IsSupported() bool {
+	return self.Error == ``
+}
+
+func (self MigrationStep) String() string {
+	if self.IsSupported() {
+		return fmt.Sprintf("%v: %s", self.Delta, self.Statement)
+	} else {
+		return fmt.Sprintf("%v: unsupported: %s", self.Delta, self.Error)
+	}
+}
+
+// The changes needed to migrate a single collection.
+type MigrationPlan struct {
+	Collection string          `json:"collection"`
+	Steps      []MigrationStep `json:"steps"`
+}
+
+// Returns whether the collection is already up-to-date.
+func (self *MigrationPlan) IsEmpty() bool {
+	return len(self.Steps) == 0
+}
+
+// Returns the steps the backend cannot perform.
+func (self *MigrationPlan) Unsupported() []MigrationStep {
+	var steps = make([]MigrationStep, 0)
+
+	for _, step := range self.Steps {
+		if !step.IsSupported() {
+			steps = append(steps, step)
+		}
+	}
+
+	return steps
+}
+
+// Returns a copy of the plan containing only the steps the backend can perform.
+func (self *MigrationPlan) Supported() *MigrationPlan {
+	var plan = &MigrationPlan{
+		Collection: self.Collection,
+		Steps:      make([]MigrationStep, 0),
+	}
+
+	for _, step := range self.Steps {
+		if step.IsSupported() {
+			plan.Steps = append(plan.Steps, step)
+		}
+	}
+
+	return plan
+}
+
+// Implemented by backends that can change the schema of existing collections.
+type SchemaMigrator interface {
+	// Compare the definition of a collection with the collection as it exists in the backend,
+	// returning the changes needed to bring the latter in line with the former.
+	PlanMigration(definition *dal.Collection) (*MigrationPlan, error)
+
+	// Perform all of the steps in the given plan.  Plans containing unsupported steps are rejected.
+	ApplyMigration(plan *MigrationPlan) error
+}
+
+// Returns the changes needed to bring the given collection in the backend in line with its
+// definition.  Wrapping backends are unwrapped until one that implements SchemaMigrator is found.
+func PlanMigration(backend Backend, definition *dal.Collection) (*MigrationPlan, error) {
+	if migrator := findSchemaMigrator(backend); migrator != nil {
+		return migrator.PlanMigration(definition)
+	} else {
+		return nil, fmt.Errorf("backend %T does not support schema migrations", backend)
+	}
+}
+
+// Perform all of the steps in the given plan against the backend.
+func ApplyMigration(backend Backend, plan *MigrationPlan) error {
+	if migrator := findSchemaMigrator(backend); migrator != nil {
+		return migrator.ApplyMigration(plan)
+	} else {
+		return fmt.Errorf("backend %T does not support schema migrations", backend)
+	}
+}
+
+func findSchemaMigrator(backend Backend) SchemaMigrator {
+	for _, backend := range BackendChain(backend) {
+		if migrator, ok := backend.(SchemaMigrator); ok {
+			return migrator
+		}
+	}
+
+	return nil
+}
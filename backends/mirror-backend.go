@@ -0,0 +1,432 @@
+package backends
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// The default number of mirrored reads that may be in flight at once.  Reads sampled while this
+// many are already running are dropped rather than queued.
+var DefaultMirrorConcurrency = 8
+
+// The default number of mirrored writes that may be waiting to be applied to the mirror.  Writes
+// made while the queue is full are dropped.
+var DefaultMirrorWriteQueueSize = 1024
+
+// The default amount of time a mirrored operation may take before it is abandoned.
+var DefaultMirrorTimeout = 30 * time.Second
+
+// Specifies how requests are mirrored to a secondary backend.
+type MirrorOptions struct {
+	Percent     float64       `json:"percent"`               // the percentage (0-100) of reads that are also performed against the mirror
+	Writes      bool          `json:"writes,omitempty"`      // whether all inserts, updates, and deletes are also applied to the mirror
+	Concurrency int           `json:"concurrency,omitempty"` // the number of mirrored reads that may be in flight at once (default: DefaultMirrorConcurrency)
+	Timeout     time.Duration `json:"timeout,omitempty"`     // how long to wait for a mirrored operation before abandoning it (default: DefaultMirrorTimeout)
+}
+
+// Counts the operations that were mirrored and how their results compared.
+type MirrorStats struct {
+	Mirrored int64 `json:"mirrored"` // operations performed against the mirror
+	Matched  int64 `json:"matched"`  // reads whose results were the same on both backends
+	Diverged int64 `json:"diverged"` // operations whose results differed between backends
+	Errors   int64 `json:"errors"`   // mirrored operations that failed or timed out
+	Dropped  int64 `json:"dropped"`  // operations that were sampled but not mirrored because too many were in flight
+}
+
+// The MirroringBackend wraps another backend and asynchronously repeats a sample of its reads
+// (and optionally all of its writes) against a secondary backend.  The results of mirrored reads
+// are compared by digest, and any divergence is logged.  This is useful for validating a new
+// backend or index configuration against real traffic before switching over to it.  Results
+// returned to callers always come from the wrapped backend.
+type MirroringBackend struct {
+	Backend
+	mirror    Backend
+	options   MirrorOptions
+	reads     chan bool
+	writes    chan func()
+	stats     MirrorStats
+	sample    func() bool
+	stopOnce  sync.Once
+	parent    *MirroringBackend // set on the views of this backend given to transactions
+	committed *[]func()
+}
+
+func NewMirroringBackend(parent Backend, mirror Backend, options MirrorOptions) *MirroringBackend {
+	if options.Concurrency <= 0 {
+		options.Concurrency = DefaultMirrorConcurrency
+	}
+
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultMirrorTimeout
+	}
+
+	var backend = &MirroringBackend{
+		Backend: parent,
+		mirror:  mirror,
+		options: options,
+		reads:   make(chan bool, options.Concurrency),
+	}
+
+	backend.sample = func() bool {
+		return backend.options.Percent >= 100 || rand.Float64()*100 < backend.options.Percent
+	}
+
+	// writes are applied to the mirror one at a time, in the order they were made
+	if options.Writes {
+		backend.writes = make(chan func(), DefaultMirrorWriteQueueSize)
+
+		go func() {
+			for write := range backend.writes {
+				write()
+			}
+		}()
+	}
+
+	return backend
+}
+
+func (self *MirroringBackend) GetBackend() Backend {
+	return self.Backend
+}
+
+// Return the backend that requests are mirrored to.
+func (self *MirroringBackend) GetMirror() Backend {
+	return self.mirror
+}
+
+// Return a snapshot of how many operations have been mirrored and how their results compared.
+func (self *MirroringBackend) Stats() MirrorStats {
+	return MirrorStats{
+		Mirrored: atomic.LoadInt64(&self.stats.Mirrored),
+		Matched:  atomic.LoadInt64(&self.stats.Matched),
+		Diverged: atomic.LoadInt64(&self.stats.Diverged),
+		Errors:   atomic.LoadInt64(&self.stats.Errors),
+		Dropped:  atomic.LoadInt64(&self.stats.Dropped),
+	}
+}
+
+// Stop mirroring writes.  Writes that are already queued are still applied to the mirror.
+func (self *MirroringBackend) Stop() {
+	self.stopOnce.Do(func() {
+		if self.writes != nil {
+			close(self.writes)
+		}
+	})
+}
+
+func (self *MirroringBackend) Retrieve(collection string, id interface{}, fields ...string) (*dal.Record, error) {
+	record, err := self.Backend.Retrieve(collection, id, fields...)
+
+	if err == nil || dal.IsNotExistError(err) {
+		var expected = recordDigest(record)
+
+		self.mirrorRead(`retrieve`, collection, expected, func() (string, error) {
+			if mirrored, err := self.mirror.Retrieve(collection, id, fields...); err == nil || dal.IsNotExistError(err) {
+				return recordDigest(mirrored), nil
+			} else {
+				return ``, err
+			}
+		})
+	}
+
+	return record, err
+}
+
+func (self *MirroringBackend) Exists(collection string, id interface{}) bool {
+	var exists = self.Backend.Exists(collection, id)
+
+	self.mirrorRead(`exists`, collection, fmt.Sprintf("%v", exists), func() (string, error) {
+		return fmt.Sprintf("%v", self.mirror.Exists(collection, id)), nil
+	})
+
+	return exists
+}
+
+func (self *MirroringBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	if search := self.Backend.WithSearch(collection, filters...); search != nil {
+		return &mirroringIndexer{
+			Indexer: search,
+			backend: self,
+		}
+	} else {
+		return nil
+	}
+}
+
+func (self *MirroringBackend) Insert(collection string, records *dal.RecordSet) error {
+	if err := self.Backend.Insert(collection, records); err == nil {
+		var copied = copyRecordSet(records)
+
+		self.mirrorWrite(`insert`, collection, func() error {
+			return self.mirror.Insert(collection, copied)
+		})
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *MirroringBackend) Update(collection string, records *dal.RecordSet, target ...string) error {
+	if err := self.Backend.Update(collection, records, target...); err == nil {
+		var copied = copyRecordSet(records)
+
+		self.mirrorWrite(`update`, collection, func() error {
+			return self.mirror.Update(collection, copied, target...)
+		})
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *MirroringBackend) Delete(collection string, ids ...interface{}) error {
+	if err := self.Backend.Delete(collection, ids...); err == nil {
+		var copied = append([]interface{}{}, ids...)
+
+		self.mirrorWrite(`delete`, collection, func() error {
+			return self.mirror.Delete(collection, copied...)
+		})
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// perform a sample of reads against the mirror in the background, comparing the digest of its
+// result to that of the wrapped backend
+func (self *MirroringBackend) mirrorRead(op string, collection string, expected string, read func() (string, error)) {
+	if !self.sample() {
+		return
+	}
+
+	select {
+	case self.reads <- true:
+	default:
+		atomic.AddInt64(&self.stats.Dropped, 1)
+		return
+	}
+
+	go func() {
+		defer func() {
+			<-self.reads
+		}()
+
+		atomic.AddInt64(&self.stats.Mirrored, 1)
+
+		if actual, err := self.withTimeout(read); err != nil {
+			atomic.AddInt64(&self.stats.Errors, 1)
+			log.Warningf("[mirror] %s %s: mirror failed: %v", op, collection, err)
+		} else if actual != expected {
+			atomic.AddInt64(&self.stats.Diverged, 1)
+			log.Warningf("[mirror] %s %s: results diverged (primary=%s mirror=%s)", op, collection, shortDigest(expected), shortDigest(actual))
+		} else {
+			atomic.AddInt64(&self.stats.Matched, 1)
+		}
+	}()
+}
+
+// queue a write that succeeded against the wrapped backend to be applied to the mirror
+// Runs fn in a transaction on the wrapped backend.  Writes made by it are mirrored once the
+// transaction commits.  Reads made within a transaction may see uncommitted writes, and so are not
+// mirrored.
+func (self *MirroringBackend) Transaction(fn func(tx Backend) error) error {
+	return transactionView(self.Backend, fn, func(tx Backend, committed *[]func()) Backend {
+		return &MirroringBackend{
+			Backend:   tx,
+			mirror:    self.mirror,
+			options:   self.options,
+			parent:    self,
+			committed: committed,
+			sample: func() bool {
+				return false
+			},
+		}
+	})
+}
+
+func (self *MirroringBackend) mirrorWrite(op string, collection string, write func() error) {
+	if self.parent != nil {
+		*self.committed = append(*self.committed, func() {
+			self.parent.mirrorWrite(op, collection, write)
+		})
+
+		return
+	} else if self.writes == nil {
+		return
+	}
+
+	var apply = func() {
+		atomic.AddInt64(&self.stats.Mirrored, 1)
+
+		if _, err := self.withTimeout(func() (string, error) {
+			return ``, write()
+		}); err != nil {
+			atomic.AddInt64(&self.stats.Diverged, 1)
+			log.Warningf("[mirror] %s %s: succeeded on primary, but failed on mirror: %v", op, collection, err)
+		}
+	}
+
+	defer func() {
+		// the write queue is closed once the backend is stopped
+		if recover() != nil {
+			atomic.AddInt64(&self.stats.Dropped, 1)
+		}
+	}()
+
+	select {
+	case self.writes <- apply:
+	default:
+		atomic.AddInt64(&self.stats.Dropped, 1)
+		log.Warningf("[mirror] %s %s: write queue is full, mirror will be missing this write", op, collection)
+	}
+}
+
+func (self *MirroringBackend) withTimeout(fn func() (string, error)) (string, error) {
+	type result struct {
+		value string
+		err   error
+	}
+
+	var done = make(chan result, 1)
+
+	go func() {
+		value, err := fn()
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-time.After(self.options.Timeout):
+		return ``, fmt.Errorf("timed out after %v", self.options.Timeout)
+	}
+}
+
+type mirroringIndexer struct {
+	Indexer
+	backend *MirroringBackend
+}
+
+func (self *mirroringIndexer) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	return DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+// run the query against the wrapped indexer, digesting the results as they are returned, then
+// (if sampled) run the same query against the mirror and compare the two
+func (self *mirroringIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	var records []*dal.Record
+
+	err := self.Indexer.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err == nil && record != nil {
+			records = append(records, record)
+		}
+
+		return resultFn(record, err, page)
+	})
+
+	if err == nil && collection != nil {
+		var expected = recordsDigest(records)
+		var mirrored = filter.Copy(f)
+
+		self.backend.mirrorRead(`query`, collection.Name, expected, func() (string, error) {
+			if mcollection, err := self.backend.mirror.GetCollection(collection.Name); err == nil {
+				if search := self.backend.mirror.WithSearch(mcollection, &mirrored); search != nil {
+					var results []*dal.Record
+
+					if err := search.QueryFunc(mcollection, &mirrored, func(record *dal.Record, err error, _ IndexPage) error {
+						if err == nil && record != nil {
+							results = append(results, record)
+						}
+
+						return err
+					}); err == nil {
+						return recordsDigest(results), nil
+					} else {
+						return ``, err
+					}
+				} else {
+					return ``, fmt.Errorf("mirror does not support queries")
+				}
+			} else {
+				return ``, err
+			}
+		})
+	}
+
+	return err
+}
+
+// returns a digest of a record's ID and fields
+func recordDigest(record *dal.Record) string {
+	if record == nil {
+		return ``
+	}
+
+	// maps are encoded with their keys sorted, so equal records always produce the same digest
+	data, _ := json.Marshal(map[string]interface{}{
+		`id`:     fmt.Sprintf("%v", record.ID),
+		`fields`: record.Fields,
+	})
+
+	var sum = sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// returns a digest of a set of records that doesn't depend on the order they were returned in,
+// since backends without an explicit sort order may return the same results in a different order
+func recordsDigest(records []*dal.Record) string {
+	var digests = make([]string, len(records))
+
+	for i, record := range records {
+		digests[i] = recordDigest(record)
+	}
+
+	sort.Strings(digests)
+
+	var sum = sha256.Sum256([]byte(strings.Join(digests, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func shortDigest(digest string) string {
+	if len(digest) > 12 {
+		return digest[:12]
+	} else if digest == `` {
+		return `none`
+	}
+
+	return digest
+}
+
+// copy a recordset so that mirrored writes aren't affected by changes the caller makes to it later
+func copyRecordSet(records *dal.RecordSet) *dal.RecordSet {
+	var copied = dal.NewRecordSet()
+
+	if records != nil {
+		for _, record := range records.Records {
+			var fields = make(map[string]interface{}, len(record.Fields))
+
+			for k, v := range record.Fields {
+				fields[k] = v
+			}
+
+			copied.Push(dal.NewRecord(record.ID, fields))
+		}
+	}
+
+	return copied
+}
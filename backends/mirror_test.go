@@ -0,0 +1,102 @@
+package backends_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// wait for the given number of operations to have been mirrored
+func waitForMirror(backend *backends.MirroringBackend, ops int64) backends.MirrorStats {
+	var deadline = time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		var stats = backend.Stats()
+
+		if stats.Matched+stats.Diverged+stats.Errors >= ops {
+			return stats
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	return backend.Stats()
+}
+
+func TestMirroringBackend(t *testing.T) {
+	assert := require.New(t)
+
+	primary := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	mirror := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	for _, b := range []backends.Backend{primary, mirror} {
+		assert.NoError(b.CreateCollection(dal.NewCollection(`things`, dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		})))
+	}
+
+	collection, err := primary.GetCollection(`things`)
+	assert.NoError(err)
+
+	backend := backends.NewMirroringBackend(primary, mirror, backends.MirrorOptions{
+		Percent: 100,
+		Writes:  true,
+	})
+
+	defer backend.Stop()
+
+	// writes are applied to both backends
+	assert.NoError(backend.Insert(`things`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `a`),
+		dal.NewRecord(2).Set(`name`, `b`),
+	)))
+
+	for i := 0; i < 100 && !mirror.Exists(`things`, 2); i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	assert.True(mirror.Exists(`things`, 1))
+	assert.True(mirror.Exists(`things`, 2))
+
+	// identical results match
+	record, err := backend.Retrieve(`things`, 1)
+	assert.NoError(err)
+	assert.Equal(`a`, record.Get(`name`))
+
+	recordset, err := backend.WithSearch(collection).Query(collection, filter.All())
+	assert.NoError(err)
+	assert.Len(recordset.Records, 2)
+
+	stats := waitForMirror(backend, 2)
+	assert.EqualValues(2, stats.Matched)
+	assert.EqualValues(0, stats.Diverged)
+
+	// records that differ on the mirror diverge, but callers still see the primary's results
+	assert.NoError(mirror.Update(`things`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `z`))))
+
+	record, err = backend.Retrieve(`things`, 1)
+	assert.NoError(err)
+	assert.Equal(`a`, record.Get(`name`))
+
+	recordset, err = backend.WithSearch(collection).Query(collection, filter.MustParse(`name/a`))
+	assert.NoError(err)
+	assert.Len(recordset.Records, 1)
+
+	stats = waitForMirror(backend, 4)
+	assert.EqualValues(2, stats.Matched)
+	assert.EqualValues(2, stats.Diverged)
+
+	// nothing is mirrored when no reads are sampled
+	unsampled := backends.NewMirroringBackend(primary, mirror, backends.MirrorOptions{})
+
+	_, err = unsampled.Retrieve(`things`, 1)
+	assert.NoError(err)
+	assert.Zero(unsampled.Stats().Mirrored)
+}
@@ -148,6 +148,14 @@ func (self *MongoBackend) FlushIndex() error {
 	return nil
 }
 
+// Returns whether the given filter can be expressed as a MongoDB query.
+func (self *MongoBackend) CanQuery(collection *dal.Collection, f *filter.Filter) bool {
+	var flt = filter.Copy(f)
+
+	_, err := self.filterToNative(collection, &flt)
+	return err == nil
+}
+
 func (self *MongoBackend) filterToNative(collection *dal.Collection, flt *filter.Filter) (bson.M, error) {
 	if data, err := filter.Render(
 		generators.NewMongoDBGenerator(),
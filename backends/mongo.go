@@ -26,6 +26,7 @@ type MongoBackend struct {
 	session               *mgo.Session
 	db                    *mgo.Database
 	indexer               Indexer
+	ignoreExisting        bool
 }
 
 func NewMongoBackend(connection dal.ConnectionString) Backend {
@@ -55,6 +56,9 @@ func (self *MongoBackend) String() string {
 func (self *MongoBackend) Initialize() error {
 	var autoregister = self.conn.ClearOpt(`autoregister`).Bool()
 
+	self.ignoreExisting = self.conn.OptBool(`ignore_existing`, DefaultIgnoreExistingCollections)
+	self.conn.ClearOpt(`ignore_existing`)
+
 	if session, err := mgo.DialWithTimeout(self.conn.String(), DefaultConnectTimeout); err == nil {
 		self.session = session
 
@@ -142,6 +146,16 @@ func (self *MongoBackend) Ping(timeout time.Duration) error {
 	}
 }
 
+// Discard the session's connections to the server so that subsequent operations establish new ones.
+func (self *MongoBackend) Reconnect() error {
+	if self.session == nil {
+		return fmt.Errorf("Backend not initialized")
+	}
+
+	self.session.Refresh()
+	return nil
+}
+
 func (self *MongoBackend) Exists(name string, id interface{}) bool {
 	if collection, err := self.GetCollection(name); err == nil {
 		if record, ok := id.(*dal.Record); ok {
@@ -177,9 +191,13 @@ func (self *MongoBackend) Retrieve(name string, id interface{}, fields ...string
 
 func (self *MongoBackend) Insert(name string, records *dal.RecordSet) error {
 	if collection, err := self.GetCollection(name); err == nil {
+		if err := collection.FormatRecordSet(records, true); err != nil {
+			return err
+		}
+
 		for _, record := range records.Records {
-			if _, err := collection.StructToRecord(record); err == nil {
-				data := self.prepareValuesForWrite(record.Fields)
+			if r, err := collection.StructToRecord(record); err == nil {
+				data := self.prepareValuesForWrite(r.Fields)
 
 				if record.ID == nil {
 					record.ID = bson.NewObjectId().Hex()
@@ -204,9 +222,13 @@ func (self *MongoBackend) Insert(name string, records *dal.RecordSet) error {
 
 func (self *MongoBackend) Update(name string, records *dal.RecordSet, target ...string) error {
 	if collection, err := self.GetCollection(name); err == nil {
+		if err := collection.FormatRecordSet(records, false); err != nil {
+			return err
+		}
+
 		for _, record := range records.Records {
-			if _, err := collection.StructToRecord(record); err == nil {
-				data := self.prepareValuesForWrite(record.Fields)
+			if r, err := collection.StructToRecord(record); err == nil {
+				data := self.prepareValuesForWrite(r.Fields)
 
 				if record.ID == nil {
 					return fmt.Errorf("Cannot update record without an ID")
@@ -246,11 +268,18 @@ func (self *MongoBackend) CreateCollection(definition *dal.Collection) error {
 	}
 
 	if _, err := self.GetCollection(definition.Name); err == nil {
-		return fmt.Errorf("Collection %v already exists", definition.Name)
+		if self.ignoreExisting {
+			self.registeredCollections.Store(definition.Name, definition)
+			return self.EnsureSecondaryIndexes(definition)
+		}
+
+		return dal.CollectionExistsError{
+			Collection: definition.Name,
+		}
 	} else if dal.IsCollectionNotFoundErr(err) {
 		if err := self.db.C(definition.Name).Create(&mgo.CollectionInfo{}); err == nil {
 			self.registeredCollections.Store(definition.Name, definition)
-			return nil
+			return self.EnsureSecondaryIndexes(definition)
 		} else {
 			return err
 		}
@@ -259,6 +288,51 @@ func (self *MongoBackend) CreateCollection(definition *dal.Collection) error {
 	}
 }
 
+// Creates any secondary indexes declared on the given collection, as well as a 2dsphere index on
+// each geopoint field.  MongoDB does nothing for indexes that already exist.
+func (self *MongoBackend) EnsureSecondaryIndexes(collection *dal.Collection) error {
+	for _, index := range collection.GetAllIndexes() {
+		if err := index.Validate(collection); err != nil {
+			return err
+		}
+
+		var keys = make([]string, len(index.Fields))
+
+		for i, field := range index.Fields {
+			if field == collection.GetIdentityFieldName() {
+				keys[i] = MongoIdentityField
+			} else {
+				keys[i] = field
+			}
+		}
+
+		if err := self.db.C(collection.Name).EnsureIndex(mgo.Index{
+			Key:        keys,
+			Name:       index.GetName(collection.Name),
+			Background: true,
+		}); err != nil {
+			return err
+		}
+	}
+
+	// geopoints are stored as GeoJSON, which is queried most efficiently using a 2dsphere index
+	for _, field := range collection.Fields {
+		if field.Type != dal.GeopointType {
+			continue
+		}
+
+		if err := self.db.C(collection.Name).EnsureIndex(mgo.Index{
+			Key:        []string{`$2dsphere:` + field.Name},
+			Name:       fmt.Sprintf("%s_%s_2dsphere", collection.Name, field.Name),
+			Background: true,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (self *MongoBackend) DeleteCollection(name string) error {
 	if collection, err := self.GetCollection(name); err == nil {
 		if err := self.db.C(collection.Name).DropCollection(); err == nil {
@@ -341,7 +415,14 @@ func (self *MongoBackend) recordFromResult(collection *dal.Collection, data map[
 		for k, v := range data {
 			v = self.fromId(v)
 
-			if _, ok := collection.GetField(k); ok || len(collection.Fields) == 0 {
+			if field, ok := collection.GetField(k); ok || len(collection.Fields) == 0 {
+				// geopoints are stored as GeoJSON, but are returned the same way as from other backends
+				if ok && field.Type == dal.GeopointType && v != nil {
+					if point, err := dal.ParseGeopoint(v); err == nil {
+						v = point
+					}
+				}
+
 				record.Set(k, v)
 			}
 		}
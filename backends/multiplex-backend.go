@@ -0,0 +1,248 @@
+package backends
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// Specifies that collections whose names match a glob pattern (e.g.: "events_*") are served from
+// a different backend than the rest (see MultiplexBackend).
+type CollectionRoute struct {
+	Collections string `json:"collections"` // a glob pattern matched against collection names
+	Backend     string `json:"backend"`     // the connection string of the backend serving matching collections
+}
+
+// Parse a route given as a PATTERN=CONNECTION_STRING pair.
+func ParseCollectionRoute(spec string) (CollectionRoute, error) {
+	pattern, connectionString := stringutil.SplitPair(spec, `=`)
+	pattern = strings.TrimSpace(pattern)
+	connectionString = strings.TrimSpace(connectionString)
+
+	if pattern == `` || connectionString == `` {
+		return CollectionRoute{}, fmt.Errorf("invalid route %q: must be in the form PATTERN=CONNECTION_STRING", spec)
+	} else if _, err := path.Match(pattern, ``); err != nil {
+		return CollectionRoute{}, fmt.Errorf("invalid route %q: %v", spec, err)
+	}
+
+	return CollectionRoute{
+		Collections: pattern,
+		Backend:     connectionString,
+	}, nil
+}
+
+type multiplexRoute struct {
+	pattern string
+	backend Backend
+}
+
+// The MultiplexBackend serves each collection from one of several backends, chosen by matching
+// the collection's name against a list of glob patterns.  Routes are checked in the order they
+// were added, and collections that don't match any of them are served from the default backend.
+// This allows a single database to keep (for example) some collections in PostgreSQL, others in
+// DynamoDB, and others on the filesystem.
+//
+// Operations that span collections (e.g.: ListCollections) are answered by combining the results
+// of every backend.  Transactions are not supported, since they cannot span backends.
+type MultiplexBackend struct {
+	fallback Backend
+	routes   []multiplexRoute
+	lock     sync.RWMutex
+}
+
+// Create a new backend that serves collections not matching any route from the given backend.
+func NewMultiplexBackend(fallback Backend) *MultiplexBackend {
+	return &MultiplexBackend{
+		fallback: fallback,
+	}
+}
+
+// Serve collections whose names match the given glob pattern from the given backend.
+func (self *MultiplexBackend) Route(pattern string, backend Backend) error {
+	if backend == nil {
+		return fmt.Errorf("cannot route collections to a nil backend")
+	} else if _, err := path.Match(pattern, ``); err != nil {
+		return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.routes = append(self.routes, multiplexRoute{
+		pattern: pattern,
+		backend: backend,
+	})
+
+	return nil
+}
+
+// Return the backend that serves the named collection.
+func (self *MultiplexBackend) BackendFor(collection string) Backend {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	for _, route := range self.routes {
+		if ok, _ := path.Match(route.pattern, collection); ok {
+			return route.backend
+		}
+	}
+
+	return self.fallback
+}
+
+// Return the default backend, followed by every distinct routed backend.
+func (self *MultiplexBackend) Backends() []Backend {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	var backends = []Backend{self.fallback}
+
+RouteLoop:
+	for _, route := range self.routes {
+		for _, backend := range backends {
+			if backend == route.backend {
+				continue RouteLoop
+			}
+		}
+
+		backends = append(backends, route.backend)
+	}
+
+	return backends
+}
+
+// calls fn for every backend, stopping at the first error
+func (self *MultiplexBackend) each(fn func(backend Backend) error) error {
+	for _, backend := range self.Backends() {
+		if err := fn(backend); err != nil {
+			return fmt.Errorf("%v: %v", backend, err)
+		}
+	}
+
+	return nil
+}
+
+func (self *MultiplexBackend) Initialize() error {
+	return self.each(func(backend Backend) error {
+		return backend.Initialize()
+	})
+}
+
+// Set the indexer of the default backend.  Routed backends keep their own indexers.
+func (self *MultiplexBackend) SetIndexer(cs dal.ConnectionString) error {
+	return self.fallback.SetIndexer(cs)
+}
+
+func (self *MultiplexBackend) RegisterCollection(collection *dal.Collection) {
+	self.BackendFor(collection.Name).RegisterCollection(collection)
+}
+
+func (self *MultiplexBackend) GetConnectionString() *dal.ConnectionString {
+	return self.fallback.GetConnectionString()
+}
+
+func (self *MultiplexBackend) Exists(collection string, id interface{}) bool {
+	return self.BackendFor(collection).Exists(collection, id)
+}
+
+func (self *MultiplexBackend) Retrieve(collection string, id interface{}, fields ...string) (*dal.Record, error) {
+	return self.BackendFor(collection).Retrieve(collection, id, fields...)
+}
+
+func (self *MultiplexBackend) Insert(collection string, records *dal.RecordSet) error {
+	return self.BackendFor(collection).Insert(collection, records)
+}
+
+func (self *MultiplexBackend) Update(collection string, records *dal.RecordSet, target ...string) error {
+	return self.BackendFor(collection).Update(collection, records, target...)
+}
+
+func (self *MultiplexBackend) Delete(collection string, ids ...interface{}) error {
+	return self.BackendFor(collection).Delete(collection, ids...)
+}
+
+func (self *MultiplexBackend) CreateCollection(definition *dal.Collection) error {
+	return self.BackendFor(definition.Name).CreateCollection(definition)
+}
+
+func (self *MultiplexBackend) DeleteCollection(collection string) error {
+	return self.BackendFor(collection).DeleteCollection(collection)
+}
+
+// List the collections of every backend, omitting any that a backend has but doesn't serve
+// (e.g.: a collection left in the default backend after being routed elsewhere).
+func (self *MultiplexBackend) ListCollections() ([]string, error) {
+	var names = make([]string, 0)
+
+	if err := self.each(func(backend Backend) error {
+		if list, err := backend.ListCollections(); err == nil {
+			for _, name := range list {
+				if self.BackendFor(name) == backend {
+					names = append(names, name)
+				}
+			}
+
+			return nil
+		} else {
+			return err
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (self *MultiplexBackend) GetCollection(collection string) (*dal.Collection, error) {
+	return self.BackendFor(collection).GetCollection(collection)
+}
+
+func (self *MultiplexBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.BackendFor(collection.Name).WithSearch(collection, filters...)
+}
+
+func (self *MultiplexBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	return self.BackendFor(collection.Name).WithAggregator(collection)
+}
+
+func (self *MultiplexBackend) Flush() error {
+	return self.each(func(backend Backend) error {
+		return backend.Flush()
+	})
+}
+
+func (self *MultiplexBackend) Ping(timeout time.Duration) error {
+	return self.each(func(backend Backend) error {
+		return backend.Ping(timeout)
+	})
+}
+
+func (self *MultiplexBackend) String() string {
+	return `multiplex`
+}
+
+// Returns whether every backend supports the given features.  Transactions are never supported.
+func (self *MultiplexBackend) Supports(features ...BackendFeature) bool {
+	for _, feature := range features {
+		if feature == Transactions {
+			return false
+		}
+	}
+
+	for _, backend := range self.Backends() {
+		if !backend.Supports(features...) {
+			return false
+		}
+	}
+
+	return true
+}
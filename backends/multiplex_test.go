@@ -0,0 +1,63 @@
+package backends_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiplexBackend(t *testing.T) {
+	assert := require.New(t)
+
+	var primary = newShard()
+	var events = newShard()
+	var mux = backends.NewMultiplexBackend(primary)
+
+	assert.NoError(mux.Route(`events_*`, events))
+	assert.Error(mux.Route(`[`, events))
+	assert.Len(mux.Backends(), 2)
+
+	assert.NoError(mux.CreateCollection(dal.NewCollection(`users`)))
+	assert.NoError(mux.CreateCollection(dal.NewCollection(`events_2026`)))
+
+	assert.NoError(mux.Insert(`users`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `one`))))
+	assert.NoError(mux.Insert(`events_2026`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `launch`))))
+
+	// each collection lives only in the backend it was routed to
+	assert.True(primary.Exists(`users`, 1))
+	assert.False(events.Exists(`users`, 1))
+	assert.True(events.Exists(`events_2026`, 1))
+
+	record, err := mux.Retrieve(`events_2026`, 1)
+	assert.NoError(err)
+	assert.Equal(`launch`, record.Get(`name`))
+
+	names, err := mux.ListCollections()
+	assert.NoError(err)
+	assert.Equal([]string{`events_2026`, `users`}, names)
+
+	collection, err := mux.GetCollection(`events_2026`)
+	assert.NoError(err)
+	assert.Equal(events.WithSearch(collection), mux.WithSearch(collection))
+
+	assert.False(mux.Supports(backends.Transactions))
+}
+
+func TestParseCollectionRoute(t *testing.T) {
+	assert := require.New(t)
+
+	route, err := backends.ParseCollectionRoute(`events_*=dynamodb://us-east-1?ttl=1`)
+	assert.NoError(err)
+	assert.Equal(backends.CollectionRoute{
+		Collections: `events_*`,
+		Backend:     `dynamodb://us-east-1?ttl=1`,
+	}, route)
+
+	_, err = backends.ParseCollectionRoute(`events_*`)
+	assert.Error(err)
+
+	_, err = backends.ParseCollectionRoute(`[=fs:///tmp`)
+	assert.Error(err)
+}
@@ -0,0 +1,112 @@
+package backends
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// Describes the outcome of upserting a set of records by natural key.
+type UpsertResult struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+}
+
+// Writes the given records to a collection, using the values of one or more non-identity fields
+// (a "natural key") to determine whether each record already exists.  Records that match an existing
+// record on all key fields will update that record (taking on its ID); all others are inserted.
+// This allows repeated imports of the same data from a system whose IDs differ from those in the
+// destination to avoid creating duplicate records.
+func UpsertByKey(backend Backend, name string, keyFields []string, recordset *dal.RecordSet) (*UpsertResult, error) {
+	result := new(UpsertResult)
+
+	if len(keyFields) == 0 {
+		return nil, fmt.Errorf("must specify at least one key field")
+	}
+
+	collection, err := backend.GetCollection(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	search := backend.WithSearch(collection)
+
+	if search == nil {
+		return nil, fmt.Errorf("backend %v does not support querying", backend)
+	}
+
+	inserts := dal.NewRecordSet()
+	updates := dal.NewRecordSet()
+	seen := make(map[string]*dal.Record)
+
+	for _, record := range recordset.Records {
+		f := filter.MakeFilter()
+		f.Limit = 2
+
+		keyParts := make([]string, len(keyFields))
+
+		for i, field := range keyFields {
+			value := record.Get(field)
+
+			if value == nil {
+				return nil, fmt.Errorf("record %v: missing value for key field %q", record.ID, field)
+			}
+
+			keyParts[i] = fmt.Sprintf("%v", value)
+
+			f.AddCriteria(filter.Criterion{
+				Field:    field,
+				Operator: `is`,
+				Values:   []interface{}{value},
+			})
+		}
+
+		key := strings.Join(keyParts, "\x00")
+
+		// multiple records in the same set with the same key collapse into the first one
+		if previous, ok := seen[key]; ok {
+			for k, v := range record.Fields {
+				previous.Set(k, v)
+			}
+
+			continue
+		}
+
+		seen[key] = record
+
+		if matches, err := search.Query(collection, &f); err == nil {
+			switch len(matches.Records) {
+			case 0:
+				inserts.Push(record)
+			case 1:
+				record.ID = matches.Records[0].ID
+				updates.Push(record)
+			default:
+				return nil, fmt.Errorf("key %v matches more than one existing record in %q", keyParts, name)
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	if len(inserts.Records) > 0 {
+		if err := backend.Insert(name, inserts); err == nil {
+			result.Inserted = len(inserts.Records)
+		} else {
+			return result, err
+		}
+	}
+
+	if len(updates.Records) > 0 {
+		if err := backend.Update(name, updates); err == nil {
+			result.Updated = len(updates.Records)
+		} else {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
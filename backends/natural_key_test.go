@@ -0,0 +1,55 @@
+package backends_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpsertByKey(t *testing.T) {
+	assert := require.New(t)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	collection := dal.NewCollection(`people`, dal.Field{
+		Name: `email`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	assert.NoError(backend.CreateCollection(collection))
+	assert.NoError(backend.Insert(`people`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`email`, `alice@example.com`).Set(`name`, `Alice`),
+	)))
+
+	result, err := backends.UpsertByKey(backend, `people`, []string{`email`}, dal.NewRecordSet(
+		dal.NewRecord(100).Set(`email`, `alice@example.com`).Set(`name`, `Alice Smith`),
+		dal.NewRecord(101).Set(`email`, `bob@example.com`).Set(`name`, `Bob`),
+		dal.NewRecord(102).Set(`email`, `bob@example.com`).Set(`name`, `Robert`),
+	))
+
+	assert.NoError(err)
+	assert.Equal(1, result.Inserted)
+	assert.Equal(1, result.Updated)
+
+	alice, err := backend.Retrieve(`people`, 1)
+	assert.NoError(err)
+	assert.Equal(`Alice Smith`, alice.Get(`name`))
+	assert.False(backend.Exists(`people`, 100))
+
+	bob, err := backend.Retrieve(`people`, 101)
+	assert.NoError(err)
+	assert.Equal(`Robert`, bob.Get(`name`))
+	assert.False(backend.Exists(`people`, 102))
+
+	_, err = backends.UpsertByKey(backend, `people`, []string{`email`}, dal.NewRecordSet(
+		dal.NewRecord(103).Set(`name`, `Nobody`),
+	))
+
+	assert.Error(err)
+}
@@ -1,8 +1,22 @@
 package backends
 
+import (
+	"time"
+)
+
 type ConnectOptions struct {
-	Indexer               string   `json:"indexer"`
-	AdditionalIndexers    []string `json:"additional_indexers"`
-	SkipInitialize        bool     `json:"skip_initialize"`
-	AutocreateCollections bool     `json:"autocreate_collections"`
+	Indexer               string                         `json:"indexer"`
+	AdditionalIndexers    []string                       `json:"additional_indexers"`
+	SkipInitialize        bool                           `json:"skip_initialize"`
+	AutocreateCollections bool                           `json:"autocreate_collections"`
+	TrackUsage            bool                           `json:"track_usage"`
+	PersistCollections    bool                           `json:"persist_collections"`   // store collection definitions in the backend itself (see CollectionRegistryBackend)
+	Coalesce              map[string]CoalesceOptions     `json:"coalesce"`              // collections whose updates should be coalesced (see CoalescingBackend)
+	IndexGC               IndexGCOptions                 `json:"index_gc"`              // periodically remove orphaned entries from the indexer (see IndexGarbageCollector)
+	DefaultQueryTimeout   time.Duration                  `json:"default_query_timeout"` // deadline for reads, queries, and aggregations (see TimeoutBackend)
+	DefaultWriteTimeout   time.Duration                  `json:"default_write_timeout"` // deadline for inserts, updates, and deletes (see TimeoutBackend)
+	HealthCheck           HealthCheckOptions             `json:"health_check"`          // periodically ping the backend and indexer, reconnecting on failure (see HealthMonitor)
+	Stats                 StatsOptions                   `json:"stats"`                 // periodically count records, alerting when counts cross thresholds (see StatsRefresher)
+	Routes                []CollectionRoute              `json:"routes"`                // collections served from backends other than the default one (see MultiplexBackend)
+	Upgrades              map[string][]RecordUpgradeFunc `json:"-"`                     // functions that lazily upgrade each collection's records to newer versions (see UpgradingBackend)
 }
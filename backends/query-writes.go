@@ -0,0 +1,125 @@
+package backends
+
+import (
+	"fmt"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// Implemented by backends that can delete or update every record matching a filter with a single
+// statement (e.g.: SQL DELETE/UPDATE ... WHERE), keeping any separate indexer up to date themselves.
+type QueryWriter interface {
+	DeleteWhere(collection *dal.Collection, f *filter.Filter) error
+	UpdateWhere(collection *dal.Collection, f *filter.Filter, record *dal.Record) (int64, error)
+}
+
+// Implemented by indexers that can update every record matching a filter in a single operation.
+type QueryUpdater interface {
+	UpdateQuery(collection *dal.Collection, f *filter.Filter, record *dal.Record) (int64, error)
+}
+
+// Deletes every record in a collection matching a filter.  Backends that implement QueryWriter do
+// this themselves (provided they can express the filter); otherwise it is left to the collection's
+// indexer.
+func DeleteQuery(backend Backend, collection *dal.Collection, f *filter.Filter) error {
+	if f == nil {
+		f = filter.All()
+	}
+
+	if writer, ok := backend.(QueryWriter); ok && canPushdown(backend, collection, f) {
+		return writer.DeleteWhere(collection, f)
+	} else if search := backend.WithSearch(collection, f); search != nil {
+		return search.DeleteQuery(collection, f)
+	} else {
+		return fmt.Errorf("Backend %T does not support querying.", backend)
+	}
+}
+
+// Sets the fields of the given (partial) record on every record in a collection matching a filter,
+// returning the number of records updated.  Backends that implement QueryWriter and indexers that
+// implement QueryUpdater do this in a single operation; otherwise the matching records are
+// retrieved and written back in pages.
+func UpdateQuery(backend Backend, collection *dal.Collection, f *filter.Filter, record *dal.Record) (int64, error) {
+	if record == nil || len(record.Fields) == 0 {
+		return 0, fmt.Errorf("must specify at least one field to update")
+	}
+
+	if f == nil {
+		f = filter.All()
+	}
+
+	if writer, ok := backend.(QueryWriter); ok && canPushdown(backend, collection, f) {
+		return writer.UpdateWhere(collection, f, record)
+	}
+
+	search := backend.WithSearch(collection, f)
+
+	if search == nil {
+		return 0, fmt.Errorf("Backend %T does not support querying.", backend)
+	} else if updater, ok := search.(QueryUpdater); ok {
+		return updater.UpdateQuery(collection, f, record)
+	}
+
+	// gather the IDs first so that the records being updated don't shift the results out from
+	// under the query
+	var ids []interface{}
+
+	if err := search.QueryFunc(collection, f, func(match *dal.Record, err error, _ IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		ids = append(ids, match.ID)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	var updated int64
+	var recordset = dal.NewRecordSet()
+
+	flush := func() error {
+		if len(recordset.Records) > 0 {
+			if err := backend.Update(collection.Name, recordset); err != nil {
+				return err
+			}
+
+			updated += int64(len(recordset.Records))
+			recordset = dal.NewRecordSet()
+		}
+
+		return nil
+	}
+
+	for _, id := range ids {
+		if existing, err := backend.Retrieve(collection.Name, id); err == nil {
+			for k, v := range record.Fields {
+				existing.Set(k, v)
+			}
+
+			recordset.Push(existing)
+		} else if !dal.IsNotExistError(err) {
+			return updated, err
+		}
+
+		if len(recordset.Records) >= IndexerPageSize {
+			if err := flush(); err != nil {
+				return updated, err
+			}
+		}
+	}
+
+	err := flush()
+
+	return updated, err
+}
+
+// returns whether a backend can apply the given filter itself, rather than relying on its indexer
+func canPushdown(backend Backend, collection *dal.Collection, f *filter.Filter) bool {
+	if fc, ok := backend.(FilterCapableIndexer); ok {
+		return fc.CanQuery(collection, f)
+	}
+
+	return true
+}
@@ -0,0 +1,45 @@
+package backends_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateQuery(t *testing.T) {
+	assert := require.New(t)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	collection := dal.NewCollection(`people`, dal.Field{
+		Name: `state`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `active`,
+		Type: dal.BooleanType,
+	})
+
+	assert.NoError(backend.CreateCollection(collection))
+	assert.NoError(backend.Insert(`people`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`state`, `CA`).Set(`active`, true),
+		dal.NewRecord(2).Set(`state`, `CA`).Set(`active`, true),
+		dal.NewRecord(3).Set(`state`, `NY`).Set(`active`, true),
+	)))
+
+	updated, err := backends.UpdateQuery(backend, collection, filter.MustParse(`state/CA`), dal.NewRecord(nil).Set(`active`, false))
+	assert.NoError(err)
+	assert.EqualValues(2, updated)
+
+	for id, active := range map[int]bool{1: false, 2: false, 3: true} {
+		record, err := backend.Retrieve(`people`, id)
+		assert.NoError(err)
+		assert.Equal(active, record.Get(`active`))
+	}
+
+	_, err = backends.UpdateQuery(backend, collection, filter.MustParse(`state/CA`), dal.NewRecord(nil))
+	assert.Error(err)
+}
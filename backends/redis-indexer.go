@@ -2,13 +2,15 @@ package backends
 
 import (
 	"fmt"
-	"math"
+	"strings"
 
+	"github.com/ghetzel/go-stockutil/sliceutil"
 	"github.com/ghetzel/pivot/v3/dal"
 	"github.com/ghetzel/pivot/v3/filter"
-	"github.com/gomodule/redigo/redis"
 )
 
+var redisPatternEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
 func (self *RedisBackend) IndexConnectionString() *dal.ConnectionString {
 	return &self.cs
 }
@@ -37,71 +39,54 @@ func (self *RedisBackend) Index(collection *dal.Collection, records *dal.RecordS
 	return nil
 }
 
+// Queries are performed by scanning the keys of the collection's records.  Exact-match criteria on
+// key fields narrow down which keys are scanned; all other criteria are evaluated against each
+// record as it is retrieved.
 func (self *RedisBackend) QueryFunc(collection *dal.Collection, flt *filter.Filter, resultFn IndexResultFunc) error {
-	if err := self.validateFilter(collection, flt); err != nil {
-		return err
+	if flt == nil {
+		flt = filter.All()
 	}
 
-	var keyPattern string
-
-	// full keyscan
-	if flt == nil || flt.IsMatchAll() {
-		keyPattern = self.key(collection.Name, `*`)
-	} else {
-		placeholders := make([]interface{}, 0)
+	querylog.Debugf("[%v] Query using filter %q", self, flt.String())
 
-		for i := 0; i < collection.KeyCount(); i++ {
-			placeholders = append(placeholders, `*`)
+	if keys, err := self.scanKeys(self.keyPattern(collection, flt)); err == nil {
+		var processed int
+		var page = IndexPage{
+			Page:         1,
+			TotalPages:   1,
+			Limit:        flt.Limit,
+			Offset:       flt.Offset,
+			TotalResults: -1,
 		}
 
-		for i, criterion := range flt.Criteria {
-			if !criterion.IsExactMatch() || len(criterion.Values) != 1 {
-				return fmt.Errorf(
-					"%v: filters can only contain exact match criteria (%q is invalid on %d values)",
-					self,
-					criterion.Operator,
-					len(criterion.Values),
-				)
-			} else if i < len(placeholders) {
-				placeholders[i] = fmt.Sprintf("%v", criterion.Values[0])
-			} else {
-				return fmt.Errorf("%v: too many criteria", self)
-			}
-		}
+		for _, key := range keys {
+			if ids, ok := self.keyIds(collection, key); ok {
+				record, err := self.Retrieve(collection.Name, ids)
 
-		keyPattern = self.key(collection.Name, placeholders...)
-	}
-
-	if keys, err := redis.Strings(self.run(`KEYS`, keyPattern)); err == nil {
-		limit := len(keys)
-		total := int64(len(keys))
-
-		if flt.Limit <= 0 {
-			flt.Limit = IndexerPageSize
-		}
+				// the record may have expired or been deleted since the keys were scanned
+				if dal.IsNotExistError(err) {
+					continue
+				} else if err == nil {
+					if !flt.MatchesRecord(record) {
+						continue
+					} else if len(flt.Fields) > 0 {
+						record = record.OnlyFields(flt.Fields)
+					}
+				}
 
-		if flt.Limit < limit {
-			limit = flt.Limit
-		}
+				processed += 1
 
-		for i := flt.Offset; i < limit; i++ {
-			if _, values := redisSplitKey(keys[i]); len(values) > 0 {
-				if values[0] == `__schema__` {
+				if processed <= flt.Offset {
 					continue
 				}
 
-				record, err := self.Retrieve(collection.Name, values, flt.Fields...)
-
-				// fire off the result handler
-				if err := resultFn(record, err, IndexPage{
-					Page:         1,
-					TotalPages:   int(math.Ceil(float64(total) / float64(flt.Limit))),
-					Limit:        flt.Limit,
-					Offset:       (1 - 1) * flt.Limit,
-					TotalResults: total,
-				}); err != nil {
+				if err := resultFn(record, err, page); err != nil {
 					return err
 				}
+
+				if flt.Limit > 0 && processed >= (flt.Offset+flt.Limit) {
+					break
+				}
 			}
 		}
 
@@ -120,59 +105,102 @@ func (self *RedisBackend) Query(collection *dal.Collection, f *filter.Filter, re
 }
 
 func (self *RedisBackend) ListValues(collection *dal.Collection, fields []string, flt *filter.Filter) (map[string][]interface{}, error) {
-	return nil, fmt.Errorf("%T.ListValues: Not Implemented", self)
+	var values = make(map[string][]interface{})
+
+	if err := self.QueryFunc(collection, flt, func(record *dal.Record, err error, _ IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			var value interface{}
+
+			if collection.IsIdentityField(field) {
+				value = record.ID
+			} else {
+				value = record.Get(field)
+			}
+
+			if value != nil {
+				values[field] = sliceutil.Unique(append(values[field], value))
+			}
+		}
+
+		return nil
+	}); err == nil {
+		return values, nil
+	} else {
+		return nil, err
+	}
 }
 
 func (self *RedisBackend) DeleteQuery(collection *dal.Collection, flt *filter.Filter) error {
-	return fmt.Errorf("%T.DeleteQuery: Not Implemented", self)
+	var ids = make([]interface{}, 0)
+
+	if err := self.QueryFunc(collection, flt, func(record *dal.Record, err error, _ IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		ids = append(ids, record.Keys(collection))
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if len(ids) > 0 {
+		return self.Delete(collection.Name, ids...)
+	}
+
+	return nil
 }
 
 func (self *RedisBackend) FlushIndex() error {
 	return nil
 }
 
-func (self *RedisBackend) validateFilter(collection *dal.Collection, flt *filter.Filter) error {
-	if flt != nil {
-		for _, field := range flt.CriteriaFields() {
-			if collection.IsIdentityField(field) {
-				continue
-			}
+// returns the pattern matching the keys of all records that could satisfy the given filter.  Key
+// fields that are matched exactly are filled in, and the rest are left as wildcards.
+func (self *RedisBackend) keyPattern(collection *dal.Collection, flt *filter.Filter) string {
+	var keyFields = collection.KeyFields()
+	var placeholders = make([]interface{}, len(keyFields))
+
+	for i := range placeholders {
+		placeholders[i] = `*`
+	}
 
-			if collection.IsKeyField(field) {
+	// criteria in OR queries can't be used to rule out any keys
+	if flt.Conjunction != filter.OrConjunction {
+		for _, criterion := range flt.Criteria {
+			if !criterion.IsExactMatch() || len(criterion.Values) != 1 {
 				continue
 			}
 
-			return fmt.Errorf("Filter field '%v' cannot be used: not a key field", field)
+			for i, field := range keyFields {
+				if criterion.Field == field.Name || (field.Identity && criterion.Field == flt.IdentityField) {
+					placeholders[i] = redisPatternEscaper.Replace(fmt.Sprintf("%v", criterion.Values[0]))
+				}
+			}
 		}
 	}
 
-	return nil
+	return self.key(collection.Name, placeholders...)
 }
 
-// func (self *RedisBackend) iterResult(collection *dal.Collection, flt *filter.Filter, items []map[string]*dynamodb.AttributeValue, processed int, totalResults int64, pageNumber int, lastPage bool, resultFn IndexResultFunc) bool {
-// 	if len(items) > 0 {
-// 		for _, item := range items {
-// 			record, err := dynamoRecordFromItem(collection, item)
-
-// 			// fire off the result handler
-// 			if err := resultFn(record, err, IndexPage{
-// 				Page:         pageNumber,
-// 				TotalPages:   int(math.Ceil(float64(totalResults) / float64(25))),
-// 				Limit:        flt.Limit,
-// 				Offset:       (pageNumber - 1) * 25,
-// 				TotalResults: totalResults,
-// 			}); err != nil {
-// 				return false
-// 			}
-
-// 			// perform bounds checking
-// 			if processed += 1; flt.Limit > 0 && processed >= flt.Limit {
-// 				return false
-// 			}
-// 		}
-
-// 		return !lastPage
-// 	} else {
-// 		return false
-// 	}
-// }
+// returns the key values of the record stored at the given key, or false if the key does not
+// refer to a record in the collection (e.g.: it's the collection's schema)
+func (self *RedisBackend) keyIds(collection *dal.Collection, key string) ([]interface{}, bool) {
+	var prefix = self.key(collection.Name) + `:`
+
+	if !strings.HasPrefix(key, prefix) {
+		return nil, false
+	}
+
+	var parts = strings.Split(strings.TrimPrefix(key, prefix), `:`)
+
+	if len(parts) != collection.KeyCount() || parts[0] == `__schema__` {
+		return nil, false
+	}
+
+	return sliceutil.Sliceify(parts), true
+}
@@ -3,6 +3,7 @@ package backends
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -22,6 +23,9 @@ var redisDefaultKeyPrefix = `pivot.`
 var redisDefaultPingTimeout = 5 * time.Second
 var redisDefaultCommandTimeout = 20 * time.Second
 
+// The number of keys requested per SCAN call when iterating over the records in a collection.
+var RedisScanCount = 1000
+
 type RedisBackend struct {
 	Backend
 	cs                    dal.ConnectionString
@@ -151,6 +155,10 @@ func (self *RedisBackend) Retrieve(name string, id interface{}, fields ...string
 		// expand id, make sure it matches the number of parts we need for this collection
 		if ids := sliceutil.Sliceify(id); len(ids) == collection.KeyCount() {
 			if dbfields, err := redis.Strings(self.run(`HGETALL`, self.key(collection.Name, ids...))); err == nil {
+				if len(dbfields) == 0 {
+					return nil, fmt.Errorf("Record %v does not exist", id)
+				}
+
 				record := dal.NewRecord(nil)
 
 				for _, pair := range sliceutil.Chunks(dbfields, 2) {
@@ -207,6 +215,13 @@ func (self *RedisBackend) Delete(name string, ids ...interface{}) error {
 			}
 		}
 
+		// remove the records from the external index (if any)
+		if search := self.WithSearch(collection); search != nil && search != Indexer(self) {
+			if err := search.IndexRemove(collection, ids); err != nil {
+				merr = utils.AppendError(merr, err)
+			}
+		}
+
 		return merr
 	} else {
 		return err
@@ -244,7 +259,9 @@ func (self *RedisBackend) CreateCollection(definition *dal.Collection) error {
 		)); err != nil {
 			return err
 		} else if out != `OK` {
-			return fmt.Errorf("Collection %q already exists", definition.Name)
+			if err := collectionExistsError(&self.cs, definition.Name); err != nil {
+				return err
+			}
 		}
 
 		self.RegisterCollection(definition)
@@ -256,7 +273,7 @@ func (self *RedisBackend) CreateCollection(definition *dal.Collection) error {
 
 func (self *RedisBackend) DeleteCollection(name string) error {
 	if collection, err := self.GetCollection(name); err == nil {
-		if keys, err := redis.Strings(self.run(`KEYS`, self.key(collection.Name, `*`))); err == nil {
+		if keys, err := self.scanKeys(self.key(collection.Name, `*`)); err == nil {
 			var merr error
 
 			for _, key := range keys {
@@ -269,6 +286,7 @@ func (self *RedisBackend) DeleteCollection(name string) error {
 				merr = utils.AppendError(merr, err)
 			}
 
+			self.registeredCollections.Delete(collection.Name)
 			return merr
 		} else {
 			return err
@@ -310,6 +328,10 @@ func (self *RedisBackend) key(name string, id ...interface{}) string {
 
 func (self *RedisBackend) upsert(create bool, collectionName string, recordset *dal.RecordSet) error {
 	if collection, err := self.GetCollection(collectionName); err == nil {
+		if err := collection.FormatRecordSet(recordset, create); err != nil {
+			return err
+		}
+
 		var merr error
 		var ttlSeconds int
 
@@ -322,13 +344,6 @@ func (self *RedisBackend) upsert(create bool, collectionName string, recordset *
 				return err
 			}
 
-			// don't even attempt to write already-expired records
-			if collection.IsExpired(record) {
-				return nil
-			} else {
-				ttlSeconds = int(collection.TTL(record).Round(time.Second).Seconds())
-			}
-
 			ids := record.Keys(collection)
 
 			if keyLen > 0 && len(ids) != keyLen {
@@ -336,6 +351,17 @@ func (self *RedisBackend) upsert(create bool, collectionName string, recordset *
 			}
 
 			var key string = self.key(collection.Name, ids...)
+
+			// don't write already-expired records, and remove any existing copy of them
+			if collection.IsExpired(record) {
+				if _, err := self.run(`DEL`, key); err != nil {
+					merr = utils.AppendError(merr, err)
+				}
+
+				continue
+			} else {
+				ttlSeconds = int(collection.TTL(record).Round(time.Second).Seconds())
+			}
 			var args []interface{}
 
 			for key, value := range record.Fields {
@@ -346,17 +372,23 @@ func (self *RedisBackend) upsert(create bool, collectionName string, recordset *
 			}
 
 			if len(args) > 0 {
-				if create && self.Exists(collectionName, record.ID) {
+				if create && self.Exists(collectionName, ids) {
 					return fmt.Errorf("Record %q already exists", record.ID)
 				}
 
 				args = append([]interface{}{key}, args...)
 
 				if out, err := redis.String(self.run(`HMSET`, args...)); err == nil && out == `OK` {
+					// records expire at the time given in the collection's TimeToLiveField; records
+					// that no longer have an expiry time have any previous one removed
 					if ttlSeconds > 0 {
 						if _, err := self.run(`EXPIRE`, key, ttlSeconds); err != nil {
 							merr = utils.AppendError(merr, err)
 						}
+					} else if collection.TimeToLiveField != `` {
+						if _, err := self.run(`PERSIST`, key); err != nil {
+							merr = utils.AppendError(merr, err)
+						}
 					}
 				} else if err == nil {
 					merr = utils.AppendError(merr, fmt.Errorf("%v: persist failed: %v", self, out))
@@ -366,7 +398,7 @@ func (self *RedisBackend) upsert(create bool, collectionName string, recordset *
 			}
 		}
 
-		if search := self.WithSearch(collection); search != nil {
+		if search := self.WithSearch(collection); search != nil && search != Indexer(self) {
 			if err := search.Index(collection, recordset); err != nil {
 				merr = utils.AppendError(merr, err)
 			}
@@ -424,7 +456,7 @@ func (self *RedisBackend) decode(collection *dal.Collection, key string, value [
 }
 
 func (self *RedisBackend) refreshCollections() error {
-	if schemata, err := redis.Strings(self.run(`KEYS`, self.key(`*`, `__schema__`))); err == nil {
+	if schemata, err := self.scanKeys(self.key(`*`, `__schema__`)); err == nil {
 		var merr error
 
 		for _, key := range schemata {
@@ -464,6 +496,49 @@ func (self *RedisBackend) refreshCollection(schemaKey string) error {
 	}
 }
 
+// returns all keys matching the given pattern in sorted order.  Keys are retrieved using SCAN,
+// which (unlike KEYS) does not block the server while large keyspaces are searched.
+func (self *RedisBackend) scanKeys(pattern string) ([]string, error) {
+	var cursor = `0`
+	var seen = make(map[string]bool)
+	var keys = make([]string, 0)
+
+	for {
+		if reply, err := redis.Values(self.run(`SCAN`, cursor, `MATCH`, pattern, `COUNT`, RedisScanCount)); err == nil {
+			if len(reply) != 2 {
+				return nil, fmt.Errorf("%v: invalid SCAN reply", self)
+			}
+
+			if next, err := redis.String(reply[0], nil); err == nil {
+				cursor = next
+			} else {
+				return nil, err
+			}
+
+			if batch, err := redis.Strings(reply[1], nil); err == nil {
+				// SCAN may return the same key more than once
+				for _, key := range batch {
+					if !seen[key] {
+						seen[key] = true
+						keys = append(keys, key)
+					}
+				}
+			} else {
+				return nil, err
+			}
+
+			if cursor == `0` {
+				break
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
 func redisSplitKey(key string) (string, []string) {
 	if parts := strings.Split(key, `.`); len(parts) > 0 {
 		final := strings.Split(parts[len(parts)-1], `:`)
@@ -3,6 +3,8 @@ package backends
 import (
 	"testing"
 
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
 	"github.com/stretchr/testify/require"
 )
 
@@ -29,3 +31,32 @@ func TestRedisSplitKey(t *testing.T) {
 	assert.Equal(`testing`, collection)
 	assert.Equal([]string{`123`, `456`}, keys)
 }
+
+func TestRedisKeyPattern(t *testing.T) {
+	assert := require.New(t)
+
+	backend := NewRedisBackend(dal.MustParseConnectionString(`redis://localhost/testing`)).(*RedisBackend)
+	collection := dal.NewCollection(`things`, dal.Field{
+		Name: `group`,
+		Type: dal.StringType,
+		Key:  true,
+	}, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	assert.Equal(`pivot.testing.things:*:*`, backend.keyPattern(collection, filter.All()))
+	assert.Equal(`pivot.testing.things:*:a`, backend.keyPattern(collection, filter.MustParse(`group/a/name/b`)))
+	assert.Equal(`pivot.testing.things:1:a\*`, backend.keyPattern(collection, filter.MustParse(`id/1/group/a*`)))
+	assert.Equal(`pivot.testing.things:*:*`, backend.keyPattern(collection, filter.MustParse(`group/prefix:a`)))
+
+	ids, ok := backend.keyIds(collection, `pivot.testing.things:1:a`)
+	assert.True(ok)
+	assert.Equal([]interface{}{`1`, `a`}, ids)
+
+	_, ok = backend.keyIds(collection, `pivot.testing.things:__schema__`)
+	assert.False(ok)
+
+	_, ok = backend.keyIds(collection, `pivot.testing.others:1:a`)
+	assert.False(ok)
+}
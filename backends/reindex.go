@@ -0,0 +1,162 @@
+package backends
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// The default number of records read from the backend and written to the index per batch when
+// reindexing a collection.
+var ReindexBatchSize = 500
+
+type ReindexOptions struct {
+	// Only reindex records matching this filter.  Defaults to all records.
+	Filter *filter.Filter
+
+	// The number of records read and indexed at a time.
+	BatchSize int
+
+	// The maximum number of records indexed per second.  Zero is unlimited.
+	Rate float64
+
+	// Called after every batch.
+	Progress func(progress *ReindexProgress)
+}
+
+type ReindexProgress struct {
+	Collection string        `json:"collection"`
+	After      interface{}   `json:"after,omitempty"`
+	Indexed    int           `json:"indexed"`
+	Elapsed    time.Duration `json:"elapsed"`
+}
+
+// Reads every record in a collection from the backend in batches ordered by identity, and writes
+// each batch to the collection's external index (e.g.: Bleve, Elasticsearch).  This is used to
+// populate a new index, or to rebuild an existing one after its mappings have changed.  Records
+// are always read from the database itself, never from the index being rebuilt.
+func Reindex(backend Backend, name string, options ReindexOptions) (*ReindexProgress, error) {
+	var progress = &ReindexProgress{
+		Collection: name,
+	}
+
+	if options.BatchSize <= 0 {
+		options.BatchSize = ReindexBatchSize
+	}
+
+	collection, index, source, err := reindexTargets(backend, name)
+
+	if err != nil {
+		return progress, err
+	}
+
+	var started = time.Now()
+
+	for {
+		var f filter.Filter
+
+		if options.Filter != nil {
+			f = filter.Copy(options.Filter)
+		} else {
+			f = filter.Copy(filter.All())
+		}
+
+		f.IdentityField = collection.GetIdentityFieldName()
+		f.Sort = []string{f.IdentityField}
+		f.Limit = options.BatchSize
+		f.Offset = 0
+		f.After = progress.After
+
+		recordset, err := source.Query(collection, &f)
+
+		if err != nil {
+			return progress, err
+		} else if len(recordset.Records) == 0 {
+			break
+		}
+
+		var lastID = recordset.Records[len(recordset.Records)-1].ID
+
+		// guard against looping forever over backends that don't support cursor pagination
+		if progress.After != nil && fmt.Sprintf("%v", lastID) == fmt.Sprintf("%v", progress.After) {
+			return progress, fmt.Errorf("collection %q does not support reindexing: results did not advance past %v", name, lastID)
+		}
+
+		if err := index.Index(collection, recordset); err != nil {
+			return progress, err
+		}
+
+		progress.Indexed += len(recordset.Records)
+		progress.After = lastID
+		progress.Elapsed = time.Since(started)
+
+		if options.Progress != nil {
+			options.Progress(progress)
+		}
+
+		if len(recordset.Records) < options.BatchSize {
+			break
+		}
+
+		// throttle so that the overall throughput of this run stays under the rate limit
+		if options.Rate > 0 {
+			var due = time.Duration(float64(progress.Indexed) / options.Rate * float64(time.Second))
+
+			if elapsed := time.Since(started); elapsed < due {
+				time.Sleep(due - elapsed)
+			}
+		}
+	}
+
+	if err := index.FlushIndex(); err != nil {
+		return progress, err
+	}
+
+	progress.Elapsed = time.Since(started)
+
+	return progress, nil
+}
+
+// Returns an error describing why the named collection can't be reindexed, if it can't be; e.g.:
+// because it doesn't have an external index.
+func CanReindex(backend Backend, name string) error {
+	_, _, _, err := reindexTargets(backend, name)
+	return err
+}
+
+// returns the collection, the index being rebuilt, and the indexer that reads records from the database
+func reindexTargets(backend Backend, name string) (*dal.Collection, Indexer, Indexer, error) {
+	collection, err := backend.GetCollection(name)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var index = backend.WithSearch(collection)
+
+	if index == nil || isSelfIndexed(backend, index) {
+		return nil, nil, nil, fmt.Errorf("collection %q does not have an external index", name)
+	}
+
+	if source, err := reindexSource(backend); err == nil {
+		return collection, index, source, nil
+	} else {
+		return nil, nil, nil, err
+	}
+}
+
+// returns the database underlying the given backend (unwrapping any backends that wrap it), which
+// must be able to query its own records
+func reindexSource(backend Backend) (Indexer, error) {
+	if chain := BackendChain(backend); len(chain) > 0 {
+		backend = chain[len(chain)-1]
+	}
+
+	if source, ok := backend.(Indexer); ok {
+		return source, nil
+	} else {
+		return nil, fmt.Errorf("backend %v cannot enumerate its own records", backend)
+	}
+}
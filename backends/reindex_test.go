@@ -0,0 +1,80 @@
+package backends_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// a backend whose queries are served by a separate index
+type externallyIndexedBackend struct {
+	*spi.Adapter
+	index backends.Indexer
+}
+
+func (self *externallyIndexedBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) backends.Indexer {
+	return self.index
+}
+
+func TestReindex(t *testing.T) {
+	assert := require.New(t)
+
+	db := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	assert.NoError(db.CreateCollection(dal.NewCollection(`things`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	assert.NoError(db.Insert(`things`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `a`),
+		dal.NewRecord(2).Set(`name`, `b`),
+		dal.NewRecord(3).Set(`name`, `c`),
+		dal.NewRecord(4).Set(`name`, `d`),
+		dal.NewRecord(5).Set(`name`, `e`),
+	)))
+
+	// backends that are their own index have nothing to rebuild
+	_, err := backends.Reindex(db, `things`, backends.ReindexOptions{})
+	assert.Error(err)
+
+	index := &recordingIndexer{
+		Indexer: db,
+	}
+
+	backend := &externallyIndexedBackend{
+		Adapter: db,
+		index:   index,
+	}
+
+	var batches int
+
+	progress, err := backends.Reindex(backend, `things`, backends.ReindexOptions{
+		BatchSize: 2,
+		Progress: func(p *backends.ReindexProgress) {
+			batches += 1
+		},
+	})
+
+	assert.NoError(err)
+	assert.Equal(5, progress.Indexed)
+	assert.EqualValues(5, progress.After)
+	assert.Equal(3, batches)
+	assert.Len(index.indexed, 5)
+
+	// only matching records are reindexed when a filter is given
+	index.indexed = nil
+
+	progress, err = backends.Reindex(backend, `things`, backends.ReindexOptions{
+		Filter: filter.MustParse(`name/b|d`),
+	})
+
+	assert.NoError(err)
+	assert.Equal(2, progress.Indexed)
+	assert.Len(index.indexed, 2)
+}
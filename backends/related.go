@@ -0,0 +1,122 @@
+package backends
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// Returned by QueryRelated when a collection refers to another via more than one field, and which
+// one to use was not specified.
+type AmbiguousRelationshipError struct {
+	Collection string
+	Related    string
+	Fields     []string
+}
+
+func (self AmbiguousRelationshipError) Error() string {
+	return fmt.Sprintf(
+		"collection %q refers to %q by more than one field (%s); specify which to use",
+		self.Collection,
+		self.Related,
+		strings.Join(self.Fields, `, `),
+	)
+}
+
+// Returns the constraints (including those declared with BelongsTo) by which records in the child
+// collection refer to records in the parent collection, e.g.: orders.customer_id belonging to
+// customers.  Only constraints between single fields are returned.
+func InverseConstraints(parent *dal.Collection, child *dal.Collection) []dal.Constraint {
+	var constraints = make([]dal.Constraint, 0)
+
+	for _, constraint := range child.GetAllConstraints() {
+		if constraint.Collection != parent.Name {
+			continue
+		} else if len(sliceutil.Stringify(constraint.On)) != 1 || len(sliceutil.Stringify(constraint.Field)) > 1 {
+			continue
+		}
+
+		constraints = append(constraints, constraint)
+	}
+
+	return constraints
+}
+
+// Queries the records in the child collection that refer to the given record in the parent
+// collection, adding the criterion that matches them to the given filter.  If the child collection
+// refers to the parent by more than one field, via names the one to use.
+func QueryRelated(backend Backend, parent string, id interface{}, child string, via string, f *filter.Filter) (*dal.RecordSet, error) {
+	var parentCollection, childCollection *dal.Collection
+	var err error
+
+	if parentCollection, err = backend.GetCollection(parent); err != nil {
+		return nil, err
+	}
+
+	if childCollection, err = backend.GetCollection(child); err != nil {
+		return nil, err
+	}
+
+	var constraints = InverseConstraints(parentCollection, childCollection)
+	var constraint *dal.Constraint
+	var fields = make([]string, 0)
+
+	for i, c := range constraints {
+		var field = sliceutil.Stringify(c.On)[0]
+
+		if via == `` || via == field {
+			constraint = &constraints[i]
+			fields = append(fields, field)
+		}
+	}
+
+	if len(fields) == 0 {
+		if via != `` {
+			return nil, fmt.Errorf("relationship from %s.%s to %q does not exist", child, via, parent)
+		} else {
+			return nil, fmt.Errorf("relationship from %q to %q does not exist", child, parent)
+		}
+	} else if len(fields) > 1 {
+		return nil, AmbiguousRelationshipError{
+			Collection: child,
+			Related:    parent,
+			Fields:     fields,
+		}
+	}
+
+	// the parent has to exist, and is where the value being referred to comes from
+	record, err := backend.Retrieve(parent, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var value = record.ID
+
+	if remote := sliceutil.Stringify(constraint.Field); len(remote) == 1 && !parentCollection.IsIdentityField(remote[0]) {
+		value = record.Get(remote[0])
+	}
+
+	if f == nil {
+		f = filter.All()
+	}
+
+	if f.Spec == filter.AllValue {
+		f.Spec = ``
+	}
+
+	f.AddCriteria(filter.Criterion{
+		Field:    sliceutil.Stringify(constraint.On)[0],
+		Operator: `is`,
+		Values:   []interface{}{value},
+	})
+
+	if search := backend.WithSearch(childCollection, f); search != nil {
+		return search.Query(childCollection, f)
+	} else {
+		return nil, fmt.Errorf("backend %v cannot search collection %q", backend, child)
+	}
+}
@@ -0,0 +1,98 @@
+package backends_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryRelated(t *testing.T) {
+	assert := require.New(t)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`customers`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`orders`, dal.Field{
+		Name:      `customer_id`,
+		Type:      dal.IntType,
+		BelongsTo: `customers`,
+	}, dal.Field{
+		Name: `total`,
+		Type: dal.FloatType,
+	})))
+
+	assert.NoError(backend.Insert(`customers`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `Alice`),
+		dal.NewRecord(2).Set(`name`, `Bob`),
+	)))
+
+	assert.NoError(backend.Insert(`orders`, dal.NewRecordSet(
+		dal.NewRecord(10).Set(`customer_id`, 1).Set(`total`, 5.0),
+		dal.NewRecord(11).Set(`customer_id`, 2).Set(`total`, 7.5),
+		dal.NewRecord(12).Set(`customer_id`, 1).Set(`total`, 20.0),
+	)))
+
+	recordset, err := backends.QueryRelated(backend, `customers`, 1, `orders`, ``, nil)
+	assert.NoError(err)
+	assert.Len(recordset.Records, 2)
+
+	for _, record := range recordset.Records {
+		assert.EqualValues(1, typeutil.Int(record.Get(`customer_id`)))
+	}
+
+	// the given filter narrows down the related records further
+	recordset, err = backends.QueryRelated(backend, `customers`, 1, `orders`, ``, filter.MustParse(`total/gt:10`))
+	assert.NoError(err)
+	assert.Len(recordset.Records, 1)
+	assert.EqualValues(12, typeutil.Int(recordset.Records[0].ID))
+
+	recordset, err = backends.QueryRelated(backend, `customers`, 1, `orders`, `customer_id`, filter.All())
+	assert.NoError(err)
+	assert.Len(recordset.Records, 2)
+
+	// the parent record and the relationship have to exist
+	_, err = backends.QueryRelated(backend, `customers`, 3, `orders`, ``, nil)
+	assert.Error(err)
+
+	_, err = backends.QueryRelated(backend, `orders`, 10, `customers`, ``, nil)
+	assert.True(dal.IsNotExistError(err))
+
+	_, err = backends.QueryRelated(backend, `customers`, 1, `orders`, `total`, nil)
+	assert.True(dal.IsNotExistError(err))
+
+	// collections that refer to the parent more than once must say which relationship to use
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`transfers`, dal.Field{
+		Name:      `from_id`,
+		Type:      dal.IntType,
+		BelongsTo: `customers`,
+	}, dal.Field{
+		Name:      `to_id`,
+		Type:      dal.IntType,
+		BelongsTo: `customers`,
+	})))
+
+	assert.NoError(backend.Insert(`transfers`, dal.NewRecordSet(
+		dal.NewRecord(100).Set(`from_id`, 1).Set(`to_id`, 2),
+	)))
+
+	_, err = backends.QueryRelated(backend, `customers`, 1, `transfers`, ``, nil)
+	assert.IsType(backends.AmbiguousRelationshipError{}, err)
+
+	recordset, err = backends.QueryRelated(backend, `customers`, 2, `transfers`, `to_id`, nil)
+	assert.NoError(err)
+	assert.Len(recordset.Records, 1)
+
+	recordset, err = backends.QueryRelated(backend, `customers`, 2, `transfers`, `from_id`, nil)
+	assert.NoError(err)
+	assert.Len(recordset.Records, 0)
+}
@@ -47,13 +47,34 @@ type recordFieldValue struct {
 	Deferred *DeferredRecord
 }
 
+// Controls which of a record's relationships are embedded by populateRelationships.
+type embedOptions struct {
+	SkipKeys []string // relationship keys that are never embedded
+	Expand   []string // if not nil, only the relationship keys named by these paths are embedded
+	Exclude  []string // collections that aren't embedded (unless forced), used to break cycles
+
+	// the most related records embedded at a key holding many IDs, for relationships that don't
+	// specify their own limit (0 is unlimited)
+	MaxRecords int
+
+	// if set, called with the key (and related collection) of each relationship that is embedded
+	OnEmbed func(key string, related *dal.Collection, relationship dal.Relationship)
+}
+
 func PopulateRelationships(backend Backend, parent *dal.Collection, record *dal.Record, prepId func(interface{}) interface{}, requestedFields ...string) error { // for each relationship
-	skipKeys := make([]string, 0)
+	var options embedOptions
 
 	if embed, ok := backend.(*EmbeddedRecordBackend); ok {
-		skipKeys = embed.SkipKeys
+		options.SkipKeys = embed.SkipKeys
+		options.MaxRecords = embed.MaxEmbeddedRecords
 	}
 
+	return populateRelationships(backend, parent, record, prepId, options, requestedFields...)
+}
+
+// Returns the relationships of the given collection, including those implied by constraints on
+// collections that don't already have an explicitly-defined embed.
+func embeddedRelationships(parent *dal.Collection) ([]dal.Relationship, error) {
 	embeds := parent.EmbeddedCollections
 
 	// loop through all the constraints, finding constraints on collections that we
@@ -80,11 +101,55 @@ ConstraintsLoop:
 				CollectionName: constraint.Collection,
 			})
 		} else {
-			return fmt.Errorf("Cannot embed collection: %v", err)
+			return nil, fmt.Errorf("Cannot embed collection: %v", err)
+		}
+	}
+
+	return embeds, nil
+}
+
+// Returns whether any of the given expansion paths (e.g.: "items.item_id") refers to the
+// relationship with the given key.
+func expandsKey(paths []string, key string) bool {
+	for _, path := range paths {
+		if path == key || strings.HasPrefix(path, key+`.`) {
+			return true
 		}
 	}
 
+	return false
+}
+
+// Returns the remainder of the given expansion paths that apply to the records embedded at the
+// given key (e.g.: "items.item_id" becomes "item_id" for the "items" key).
+func expandSubpaths(paths []string, key string) []string {
+	var subpaths = make([]string, 0)
+
+	for _, path := range paths {
+		if strings.HasPrefix(path, key+`.`) {
+			subpaths = append(subpaths, strings.TrimPrefix(path, key+`.`))
+		}
+	}
+
+	return subpaths
+}
+
+func populateRelationships(backend Backend, parent *dal.Collection, record *dal.Record, prepId func(interface{}) interface{}, options embedOptions, requestedFields ...string) error {
+	var skipKeys = options.SkipKeys
+	var embeds []dal.Relationship
+
+	if e, err := embeddedRelationships(parent); err == nil {
+		embeds = e
+	} else {
+		return err
+	}
+
 	for _, relationship := range embeds {
+		// many-to-many relationships are loaded explicitly via their join collection
+		if relationship.IsManyToMany() {
+			continue
+		}
+
 		keys := sliceutil.CompactString(sliceutil.Stringify(sliceutil.Sliceify(relationship.Keys)))
 
 		// if we're supposed to skip certain keys, and this is one of them
@@ -106,6 +171,9 @@ ConstraintsLoop:
 		if related.Name == parent.Name && !relationship.Force {
 			log.Warningf("not embedding records from %q to avoid loop", related.Name)
 			continue
+		} else if sliceutil.ContainsString(options.Exclude, related.Name) && !relationship.Force {
+			log.Debugf("not embedding records from %q in %q: already expanded", related.Name, parent.Name)
+			continue
 		}
 
 		var nestedFields []string
@@ -149,11 +217,23 @@ ConstraintsLoop:
 		for _, key := range keys {
 			keyBefore, _ := stringutil.SplitPair(key, `.*`)
 
+			if options.Expand != nil && !expandsKey(options.Expand, keyBefore) {
+				continue
+			}
+
 			if nestedId := record.Get(key); nestedId != nil {
+				if options.OnEmbed != nil {
+					options.OnEmbed(keyBefore, related, relationship)
+				}
+
 				if typeutil.IsArray(nestedId) {
 					results := make([]interface{}, 0)
+					ids := sliceutil.Sliceify(nestedId)
 
-					for _, id := range sliceutil.Sliceify(nestedId) {
+					// only embed up to the limit, and say which records were left out
+					ids = truncateEmbeddedIds(record, keyBefore, related, ids, embedLimit(relationship.MaxRecords, options.MaxRecords))
+
+					for _, id := range ids {
 						if prepId != nil {
 							id = prepId(id)
 						}
@@ -220,6 +300,56 @@ ConstraintsLoop:
 	return nil
 }
 
+// returns the relationship's own limit if it has one, otherwise the default
+func embedLimit(relationshipLimit int, defaultLimit int) int {
+	if relationshipLimit > 0 {
+		return relationshipLimit
+	}
+
+	return defaultLimit
+}
+
+// Returns at most the given number of the IDs of records related to the given record at the given
+// key.  If any are left out, the record's value at the key is shortened to match and the truncation
+// is described in its EmbedTruncationField.
+func truncateEmbeddedIds(record *dal.Record, key string, related *dal.Collection, ids []interface{}, limit int) []interface{} {
+	if limit <= 0 || len(ids) <= limit {
+		return ids
+	}
+
+	setEmbedTruncation(record.Fields, key, &dal.EmbedTruncation{
+		Collection: related.Name,
+		Total:      len(ids),
+		Included:   limit,
+		Remaining:  ids[limit:],
+	})
+
+	record.SetNested(key, append([]interface{}{}, ids[:limit]...))
+
+	return ids[:limit]
+}
+
+// Records that some of the related records at the given key were left out of a record's fields.  If
+// records at that key were already left out (e.g.: by count, then by size), the truncations are
+// combined.
+func setEmbedTruncation(fields map[string]interface{}, key string, truncation *dal.EmbedTruncation) {
+	var truncated map[string]interface{}
+
+	if existing, ok := fields[dal.EmbedTruncationField].(map[string]interface{}); ok {
+		truncated = existing
+	} else {
+		truncated = make(map[string]interface{})
+		fields[dal.EmbedTruncationField] = truncated
+	}
+
+	if previous, ok := truncated[key].(*dal.EmbedTruncation); ok {
+		truncation.Total = previous.Total
+		truncation.Remaining = append(truncation.Remaining, previous.Remaining...)
+	}
+
+	truncated[key] = truncation
+}
+
 func ResolveDeferredRecords(cache map[string]interface{}, records ...*dal.Record) error {
 	deferredRecords := make(map[string]*DeferredRecord)
 	resolvedValues := make([]*recordFieldValue, 0)
@@ -232,14 +362,21 @@ func ResolveDeferredRecords(cache map[string]interface{}, records ...*dal.Record
 	//             and map them to each record
 	for _, record := range records {
 		if err := maputil.WalkStruct(record.Fields, func(value interface{}, key []string, isLeaf bool) error {
-			if deferred, ok := value.(DeferredRecord); ok {
-				dptr := &deferred
-				deferredRecords[deferred.String()] = dptr
+			var dptr *DeferredRecord
+
+			if deferred, ok := value.(*DeferredRecord); ok {
+				dptr = deferred
+			} else if deferred, ok := value.(DeferredRecord); ok {
+				dptr = &deferred
+			}
+
+			if dptr != nil {
+				deferredRecords[dptr.String()] = dptr
 
 				resolvedValues = append(resolvedValues, &recordFieldValue{
 					Record:   record,
 					Key:      key,
-					Value:    deferred.ID,
+					Value:    dptr.ID,
 					Deferred: dptr,
 				})
 
@@ -347,6 +484,10 @@ func ResolveDeferredRecords(cache map[string]interface{}, records ...*dal.Record
 
 				// replace each deferred record field with the now-populated related data item
 				for _, field := range resolvedValues {
+					if field.Deferred.CollectionName != relatedCollectionName {
+						continue
+					}
+
 					key := keyFn(field.Value)
 
 					if data, ok := cache[key].(map[string]interface{}); ok {
@@ -0,0 +1,28 @@
+package backends
+
+import (
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// Implemented by backends that can create the secondary indexes declared on a collection (see
+// dal.Collection.Indexes).  Implementations should only create indexes that do not already exist.
+type SecondaryIndexManager interface {
+	EnsureSecondaryIndexes(collection *dal.Collection) error
+}
+
+// Creates any secondary indexes declared on the given collection that don't already exist.
+// Wrapping backends are unwrapped until one that implements SecondaryIndexManager is found.  If
+// none do, or if the collection doesn't declare any indexes, this does nothing.
+func EnsureSecondaryIndexes(backend Backend, collection *dal.Collection) error {
+	if len(collection.GetAllIndexes()) == 0 {
+		return nil
+	}
+
+	for _, backend := range BackendChain(backend) {
+		if manager, ok := backend.(SecondaryIndexManager); ok {
+			return manager.EnsureSecondaryIndexes(collection)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,387 @@
+package backends
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/ghodss/yaml"
+)
+
+// The maximum number of existing related records that will be sampled when generating values for
+// fields that reference another collection.
+var SeedForeignKeySampleSize = 1000
+
+// The number of generated records that are inserted in a single batch.
+var SeedBatchSize = 100
+
+// The number of times a value will be regenerated if it does not pass the field's validator.
+var SeedValidationAttempts = 10
+
+// Describes how values for a specific field should be generated.
+type SeedFieldTemplate struct {
+	// Choose values at random from this list.
+	Values []interface{} `json:"values,omitempty"`
+
+	// A fmt-style format string that is given the (1-based) sequence number of the record being
+	// generated, e.g.: "user-%04d".
+	Format string `json:"format,omitempty"`
+
+	// The lower and upper bound of numeric values, or the number of seconds relative to now that
+	// generated times will fall between.
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+
+	// The length of generated strings.
+	Length int `json:"length,omitempty"`
+
+	// The probability (0.0-1.0) that the field will be left empty.
+	NullRate float64 `json:"null_rate,omitempty"`
+
+	// Do not generate a value for this field at all.
+	Skip bool `json:"skip,omitempty"`
+}
+
+// Describes how synthetic records for a collection should be generated.  Fields that are not
+// present in the template will have values generated based on their type, validators, and
+// relationships.
+type SeedTemplate struct {
+	Fields map[string]SeedFieldTemplate `json:"fields,omitempty"`
+	Seed   int64                        `json:"seed,omitempty"`
+}
+
+// Load a seed template from the given YAML or JSON file.
+func LoadSeedTemplate(filename string) (*SeedTemplate, error) {
+	var template SeedTemplate
+
+	if data, err := ioutil.ReadFile(filename); err == nil {
+		if err := yaml.Unmarshal(data, &template); err == nil {
+			return &template, nil
+		} else {
+			return nil, fmt.Errorf("invalid seed template %s: %v", filename, err)
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// Generates synthetic records for a collection.
+type Seeder struct {
+	backend     Backend
+	collection  *dal.Collection
+	template    *SeedTemplate
+	random      *rand.Rand
+	foreignKeys map[string][]interface{}
+	loaded      bool
+}
+
+func NewSeeder(backend Backend, collection *dal.Collection, template *SeedTemplate) *Seeder {
+	if template == nil {
+		template = new(SeedTemplate)
+	}
+
+	seed := template.Seed
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &Seeder{
+		backend:     backend,
+		collection:  collection,
+		template:    template,
+		random:      rand.New(rand.NewSource(seed)),
+		foreignKeys: make(map[string][]interface{}),
+	}
+}
+
+// Generate and insert the given number of records, returning the number that were actually written.
+func (self *Seeder) Seed(count int) (int, error) {
+	var inserted int
+
+	for inserted < count {
+		batch := dal.NewRecordSet()
+
+		for i := 0; i < SeedBatchSize && inserted+i < count; i++ {
+			if record, err := self.Generate(inserted + i + 1); err == nil {
+				batch.Push(record)
+			} else {
+				return inserted, err
+			}
+		}
+
+		if err := self.backend.Insert(self.collection.Name, batch); err != nil {
+			return inserted, err
+		}
+
+		inserted += len(batch.Records)
+	}
+
+	return inserted, nil
+}
+
+// Generate a single record.  The sequence number is made available to format strings in the template.
+func (self *Seeder) Generate(sequence int) (*dal.Record, error) {
+	if err := self.loadForeignKeys(); err != nil {
+		return nil, err
+	}
+
+	record := dal.NewRecord(nil)
+
+	if fieldTemplate, ok := self.template.Fields[self.collection.GetIdentityFieldName()]; ok {
+		record.ID = self.generateValue(&dal.Field{
+			Name: self.collection.GetIdentityFieldName(),
+			Type: self.collection.IdentityFieldType,
+		}, fieldTemplate, sequence)
+	} else if self.collection.AutoIdentity == `` && self.collection.IdentityFieldType == dal.StringType {
+		record.ID = self.randomHex(16)
+	}
+
+	for _, field := range self.collection.Fields {
+		if field.Identity {
+			continue
+		}
+
+		fieldTemplate := self.template.Fields[field.Name]
+
+		if fieldTemplate.Skip {
+			continue
+		} else if fieldTemplate.NullRate > 0 && self.random.Float64() < fieldTemplate.NullRate && !field.Required {
+			continue
+		}
+
+		var value interface{}
+		var err error
+
+		// random values may not satisfy the field's validators, so make a few attempts before giving up
+		for attempt := 0; attempt < SeedValidationAttempts; attempt++ {
+			value = self.generateValue(&field, fieldTemplate, sequence)
+
+			if err = field.Validate(value); err == nil {
+				break
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("cannot generate a valid value for field %q (specify one in the template): %v", field.Name, err)
+		} else if value != nil {
+			record.Set(field.Name, value)
+		}
+	}
+
+	return record, nil
+}
+
+func (self *Seeder) generateValue(field *dal.Field, tpl SeedFieldTemplate, sequence int) interface{} {
+	var explicit interface{}
+
+	// explicit choices in the template take precedence over everything else
+	if len(tpl.Values) > 0 {
+		explicit = tpl.Values[self.random.Intn(len(tpl.Values))]
+	} else if tpl.Format != `` {
+		explicit = fmt.Sprintf(tpl.Format, sequence)
+	}
+
+	if explicit != nil {
+		if value, err := field.ConvertValue(explicit); err == nil {
+			return value
+		} else {
+			return explicit
+		}
+	}
+
+	// relationships are populated by sampling records that already exist
+	if keys, ok := self.foreignKeys[field.Name]; ok {
+		if len(keys) > 0 {
+			return keys[self.random.Intn(len(keys))]
+		} else {
+			return nil
+		}
+	}
+
+	// honor enumerated values declared via the one-of validator
+	if choices := enumValues(field); len(choices) > 0 {
+		return choices[self.random.Intn(len(choices))]
+	}
+
+	switch field.Type {
+	case dal.BooleanType:
+		return self.random.Intn(2) == 1
+
+	case dal.IntType:
+		min, max := tpl.bounds(0, 1000)
+
+		if isPositiveOnly(field) && min < 1 {
+			min = 1
+
+			if max < min {
+				max = min
+			}
+		}
+
+		return int64(min) + self.random.Int63n(int64(max-min)+1)
+
+	case dal.FloatType:
+		min, max := tpl.bounds(0, 1000)
+		return min + self.random.Float64()*(max-min)
+
+	case dal.TimeType:
+		min, max := tpl.bounds(-365*86400, 0)
+		offset := min + self.random.Float64()*(max-min)
+
+		return time.Now().Add(time.Duration(offset) * time.Second).Truncate(time.Second)
+
+	case dal.ArrayType:
+		values := make([]interface{}, 1+self.random.Intn(3))
+
+		for i := range values {
+			values[i] = self.generateValue(&dal.Field{
+				Name: field.Name,
+				Type: dal.Type(sliceutil.OrString(string(field.Subtype), string(dal.StringType))),
+			}, tpl, sequence)
+		}
+
+		return values
+
+	case dal.ObjectType:
+		return map[string]interface{}{
+			`seq`: sequence,
+		}
+
+	case dal.GeopointType:
+		return dal.Geopoint{
+			Latitude:  self.random.Float64()*180 - 90,
+			Longitude: self.random.Float64()*360 - 180,
+		}
+
+	default:
+		length := tpl.Length
+
+		if length <= 0 {
+			if field.Length > 0 && field.Length < 16 {
+				length = field.Length
+			} else {
+				length = 16
+			}
+		}
+
+		if _, isUrl := field.ValidatorConfig[`url`]; isUrl {
+			return fmt.Sprintf("https://example.com/%s", self.randomHex(length))
+		}
+
+		return self.randomHex(length)
+	}
+}
+
+func (self *Seeder) randomHex(length int) string {
+	data := make([]byte, (length+1)/2)
+	self.random.Read(data)
+
+	return hex.EncodeToString(data)[0:length]
+}
+
+// returns a copy of this seeder with its own source of randomness (so that each copy can be used
+// from a different goroutine) that shares the foreign keys this one has already loaded
+func (self *Seeder) fork(seed int64) *Seeder {
+	return &Seeder{
+		backend:     self.backend,
+		collection:  self.collection,
+		template:    self.template,
+		random:      rand.New(rand.NewSource(seed)),
+		foreignKeys: self.foreignKeys,
+		loaded:      self.loaded,
+	}
+}
+
+func (self *Seeder) loadForeignKeys() error {
+	if self.loaded {
+		return nil
+	}
+
+	for _, constraint := range self.collection.GetAllConstraints() {
+		localField := typeutil.String(constraint.On)
+		remoteField := typeutil.String(constraint.Field)
+		keys := make([]interface{}, 0)
+
+		if related, err := self.backend.GetCollection(constraint.Collection); err == nil {
+			if search := self.backend.WithSearch(related); search != nil {
+				f := filter.All()
+				f.Limit = SeedForeignKeySampleSize
+
+				if err := search.QueryFunc(related, f, func(record *dal.Record, err error, _ IndexPage) error {
+					if err != nil {
+						return err
+					}
+
+					if remoteField == `` || remoteField == related.GetIdentityFieldName() {
+						keys = append(keys, record.ID)
+					} else if v := record.Get(remoteField); v != nil {
+						keys = append(keys, v)
+					}
+
+					return nil
+				}); err != nil {
+					return err
+				}
+			} else {
+				return fmt.Errorf("backend %v does not support enumerating records", self.backend)
+			}
+		} else {
+			return fmt.Errorf("related collection %q: %v", constraint.Collection, err)
+		}
+
+		self.foreignKeys[localField] = keys
+	}
+
+	self.loaded = true
+
+	return nil
+}
+
+func (self SeedFieldTemplate) bounds(min float64, max float64) (float64, float64) {
+	if self.Min != nil {
+		min = *self.Min
+	}
+
+	if self.Max != nil {
+		max = *self.Max
+	}
+
+	if max < min {
+		max = min
+	}
+
+	return min, max
+}
+
+func enumValues(field *dal.Field) []interface{} {
+	if args, ok := field.ValidatorConfig[`one-of`]; ok {
+		if typeutil.IsArray(args) {
+			values := sliceutil.Sliceify(args)
+
+			for i, v := range values {
+				if vAtKey := maputil.M(v).Get(`value`); !vAtKey.IsNil() {
+					values[i] = vAtKey.Value
+				}
+			}
+
+			return values
+		} else if typeutil.IsMap(args) {
+			return maputil.MapValues(args)
+		}
+	}
+
+	return nil
+}
+
+func isPositiveOnly(field *dal.Field) bool {
+	_, positive := field.ValidatorConfig[`positive-integer`]
+	return positive
+}
@@ -0,0 +1,117 @@
+package backends_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeeder(t *testing.T) {
+	assert := require.New(t)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	groups := dal.NewCollection(`groups`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	users := dal.NewCollection(`users`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name:            `role`,
+		Type:            dal.StringType,
+		Validator:       dal.ValidateIsOneOf(`admin`, `user`),
+		ValidatorConfig: map[string]interface{}{`one-of`: []interface{}{`admin`, `user`}},
+	}, dal.Field{
+		Name: `age`,
+		Type: dal.IntType,
+	}, dal.Field{
+		Name:      `group_id`,
+		Type:      dal.IntType,
+		BelongsTo: `groups`,
+	})
+
+	assert.NoError(backend.CreateCollection(groups))
+	assert.NoError(backend.CreateCollection(users))
+	assert.NoError(backend.Insert(`groups`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `one`),
+		dal.NewRecord(2).Set(`name`, `two`),
+	)))
+
+	min := float64(18)
+	max := float64(65)
+
+	seeder := backends.NewSeeder(backend, users, &backends.SeedTemplate{
+		Seed: 42,
+		Fields: map[string]backends.SeedFieldTemplate{
+			`id`:   {Format: `%d`},
+			`name`: {Format: `user-%03d`},
+			`age`:  {Min: &min, Max: &max},
+		},
+	})
+
+	n, err := seeder.Seed(25)
+	assert.NoError(err)
+	assert.Equal(25, n)
+
+	var seen int
+
+	assert.NoError(backend.WithSearch(users).QueryFunc(users, filter.All(), func(record *dal.Record, err error, _ backends.IndexPage) error {
+		assert.NoError(err)
+		assert.Regexp(`^user-\d{3}$`, record.GetString(`name`))
+		assert.Contains([]interface{}{`admin`, `user`}, record.Get(`role`))
+		assert.True(backend.Exists(`groups`, record.Get(`group_id`)))
+
+		age := typeutil.Int(record.Get(`age`))
+		assert.True(age >= 18 && age <= 65)
+
+		seen += 1
+		return nil
+	}))
+
+	assert.Equal(25, seen)
+}
+
+func TestSeederPositiveOnlyBounds(t *testing.T) {
+	assert := require.New(t)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	things := dal.NewCollection(`things`, dal.Field{
+		Name:            `count`,
+		Type:            dal.IntType,
+		Validator:       dal.ValidatePositiveInteger,
+		ValidatorConfig: map[string]interface{}{`positive-integer`: true},
+	})
+
+	assert.NoError(backend.CreateCollection(things))
+
+	// a maximum below what the field allows is raised along with the minimum
+	max := float64(0)
+
+	seeder := backends.NewSeeder(backend, things, &backends.SeedTemplate{
+		Seed: 42,
+		Fields: map[string]backends.SeedFieldTemplate{
+			`id`:    {Format: `%d`},
+			`count`: {Max: &max},
+		},
+	})
+
+	n, err := seeder.Seed(5)
+	assert.NoError(err)
+	assert.Equal(5, n)
+
+	assert.NoError(backend.WithSearch(things).QueryFunc(things, filter.All(), func(record *dal.Record, err error, _ backends.IndexPage) error {
+		assert.NoError(err)
+		assert.EqualValues(1, typeutil.Int(record.Get(`count`)))
+		return nil
+	}))
+}
@@ -0,0 +1,873 @@
+package backends
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// The number of points each shard occupies on the hash ring.  More points spread records more
+// evenly across shards at the cost of a (slightly) slower lookup.  This must not be changed once
+// records have been written, as doing so moves records between shards.
+var ShardVirtualNodes = 128
+
+// The number of records read at a time from each shard while rebalancing.
+var ShardRebalanceBatchSize = 1000
+
+// Returns the value that determines which shard a record is stored on, or nil if the record
+// doesn't contain enough information to tell.
+type ShardKeyFunc func(collection string, record *dal.Record) interface{}
+
+// Shards records by their identity.  This is the default, and is the only strategy that allows
+// records to be retrieved, updated, and deleted by ID without consulting every shard.
+func ShardByIdentity(collection string, record *dal.Record) interface{} {
+	if record != nil {
+		return record.ID
+	}
+
+	return nil
+}
+
+// Shards records by the value of the given field, keeping records that share a value together on
+// the same shard.  Records given without the field (e.g.: when retrieving by ID) are looked for on
+// every shard.  The field's value must not change once a record has been written.
+func ShardByField(field string) ShardKeyFunc {
+	return func(collection string, record *dal.Record) interface{} {
+		if record != nil {
+			if v := record.Get(field); !typeutil.IsZero(v) {
+				return v
+			}
+		}
+
+		return nil
+	}
+}
+
+type shardPoint struct {
+	hash  uint32
+	shard int
+}
+
+// The ShardedBackend spreads records across several backends using consistent hashing of each
+// record's shard key (by default, its identity).  Writes go to the single shard that owns the
+// record, and queries are sent to every shard with the results merged, sorted, and paginated as if
+// they had come from one backend.  All shards are expected to have the same collections.
+//
+// Shards are identified by their position, so they must always be given in the same order.  To
+// add capacity:
+//
+//  1. Create the collections on the new backend (or leave AutocreateCollections on).
+//  2. Call AddShard() with the new backend.  From this point on, newly-written records that hash
+//     to the new shard are written there.
+//  3. Call Rebalance() for every collection.  This moves the records that now belong to the new
+//     shard off of the shards they were written to.  Until it completes, records that are due to
+//     move are still found by queries, but not by Retrieve/Exists/Update/Delete when sharding by
+//     identity.  Rebalancing is safe to interrupt and re-run.
+//
+// Only a fraction (roughly 1/N for N shards) of the existing records are moved.
+type ShardedBackend struct {
+	shards   []Backend
+	shardKey ShardKeyFunc
+	ring     []shardPoint
+	lock     sync.RWMutex
+}
+
+// Create a new backend that distributes records across the given backends.  If shardKeyFn is nil,
+// records are sharded by identity.
+func NewShardedBackend(shards []Backend, shardKeyFn ShardKeyFunc) *ShardedBackend {
+	if shardKeyFn == nil {
+		shardKeyFn = ShardByIdentity
+	}
+
+	var backend = &ShardedBackend{
+		shardKey: shardKeyFn,
+	}
+
+	for _, shard := range shards {
+		backend.addToRing(shard)
+	}
+
+	return backend
+}
+
+// Add a new shard to the end of the list of shards.  Existing records that now belong on the new
+// shard stay where they are until Rebalance() is called.
+func (self *ShardedBackend) AddShard(shard Backend) error {
+	if shard == nil {
+		return fmt.Errorf("cannot add a nil shard")
+	}
+
+	self.addToRing(shard)
+	return nil
+}
+
+// Return the backends records are sharded across.
+func (self *ShardedBackend) Shards() []Backend {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	return append([]Backend{}, self.shards...)
+}
+
+// Return the index of the shard that owns the given record, or -1 if the record has no shard key.
+func (self *ShardedBackend) ShardFor(collection string, record *dal.Record) int {
+	if key := self.shardKey(collection, record); key != nil {
+		return self.ownerOf(key)
+	}
+
+	return -1
+}
+
+func (self *ShardedBackend) addToRing(shard Backend) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	var index = len(self.shards)
+
+	self.shards = append(self.shards, shard)
+
+	for v := 0; v < ShardVirtualNodes; v++ {
+		self.ring = append(self.ring, shardPoint{
+			hash:  shardHash(fmt.Sprintf("shard-%d-%d", index, v)),
+			shard: index,
+		})
+	}
+
+	sort.Slice(self.ring, func(i int, j int) bool {
+		return self.ring[i].hash < self.ring[j].hash
+	})
+}
+
+func (self *ShardedBackend) ownerOf(key interface{}) int {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	if len(self.ring) == 0 {
+		return -1
+	}
+
+	var hash = shardHash(typeutil.String(key))
+	var i = sort.Search(len(self.ring), func(i int) bool {
+		return self.ring[i].hash >= hash
+	})
+
+	// wrap around to the start of the ring
+	if i == len(self.ring) {
+		i = 0
+	}
+
+	return self.ring[i].shard
+}
+
+func (self *ShardedBackend) shard(i int) Backend {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	return self.shards[i]
+}
+
+// calls fn for every shard, stopping at the first error
+func (self *ShardedBackend) each(fn func(i int, shard Backend) error) error {
+	for i, shard := range self.Shards() {
+		if err := fn(i, shard); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// find the shard a record with the given ID lives on, checking every shard if the ID alone isn't
+// enough to tell
+func (self *ShardedBackend) locate(collection string, record *dal.Record) (int, bool) {
+	if i := self.ShardFor(collection, record); i >= 0 {
+		return i, true
+	}
+
+	for i, shard := range self.Shards() {
+		if shard.Exists(collection, record.ID) {
+			return i, true
+		}
+	}
+
+	return -1, false
+}
+
+// group records by the shard that owns them
+func (self *ShardedBackend) partition(collection string, records *dal.RecordSet, locate bool) (map[int]*dal.RecordSet, error) {
+	var parts = make(map[int]*dal.RecordSet)
+
+	for _, record := range records.Records {
+		var i = self.ShardFor(collection, record)
+
+		if i < 0 && locate {
+			i, _ = self.locate(collection, record)
+		}
+
+		if i < 0 {
+			return nil, fmt.Errorf("cannot determine which shard record %v belongs to", record.ID)
+		}
+
+		if _, ok := parts[i]; !ok {
+			parts[i] = dal.NewRecordSet()
+		}
+
+		parts[i].Push(record)
+	}
+
+	return parts, nil
+}
+
+func (self *ShardedBackend) Initialize() error {
+	return self.each(func(_ int, shard Backend) error {
+		return shard.Initialize()
+	})
+}
+
+func (self *ShardedBackend) SetIndexer(cs dal.ConnectionString) error {
+	return self.each(func(_ int, shard Backend) error {
+		return shard.SetIndexer(cs)
+	})
+}
+
+func (self *ShardedBackend) RegisterCollection(collection *dal.Collection) {
+	self.each(func(_ int, shard Backend) error {
+		shard.RegisterCollection(collection)
+		return nil
+	})
+}
+
+func (self *ShardedBackend) GetConnectionString() *dal.ConnectionString {
+	if cs, err := dal.MakeConnectionString(`sharded`, ``, ``, nil); err == nil {
+		return &cs
+	} else {
+		return &dal.ConnectionString{}
+	}
+}
+
+func (self *ShardedBackend) Exists(collection string, id interface{}) bool {
+	if i := self.ShardFor(collection, dal.NewRecord(id)); i >= 0 {
+		return self.shard(i).Exists(collection, id)
+	}
+
+	for _, shard := range self.Shards() {
+		if shard.Exists(collection, id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (self *ShardedBackend) Retrieve(collection string, id interface{}, fields ...string) (*dal.Record, error) {
+	if i, ok := self.locate(collection, dal.NewRecord(id)); ok {
+		return self.shard(i).Retrieve(collection, id, fields...)
+	} else {
+		return nil, fmt.Errorf("Record %v does not exist", id)
+	}
+}
+
+func (self *ShardedBackend) Insert(collection string, records *dal.RecordSet) error {
+	if parts, err := self.partition(collection, records, false); err == nil {
+		for i, part := range parts {
+			if err := self.shard(i).Insert(collection, part); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *ShardedBackend) Update(collection string, records *dal.RecordSet, target ...string) error {
+	if parts, err := self.partition(collection, records, true); err == nil {
+		for i, part := range parts {
+			if err := self.shard(i).Update(collection, part, target...); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *ShardedBackend) Delete(collection string, ids ...interface{}) error {
+	var byShard = make(map[int][]interface{})
+
+	for _, id := range ids {
+		if i, ok := self.locate(collection, dal.NewRecord(id)); ok {
+			byShard[i] = append(byShard[i], id)
+		}
+	}
+
+	for i, shardIds := range byShard {
+		if err := self.shard(i).Delete(collection, shardIds...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *ShardedBackend) CreateCollection(definition *dal.Collection) error {
+	return self.each(func(_ int, shard Backend) error {
+		return shard.CreateCollection(definition)
+	})
+}
+
+func (self *ShardedBackend) DeleteCollection(collection string) error {
+	return self.each(func(_ int, shard Backend) error {
+		return shard.DeleteCollection(collection)
+	})
+}
+
+func (self *ShardedBackend) ListCollections() ([]string, error) {
+	if shards := self.Shards(); len(shards) > 0 {
+		return shards[0].ListCollections()
+	} else {
+		return nil, fmt.Errorf("no shards configured")
+	}
+}
+
+func (self *ShardedBackend) GetCollection(collection string) (*dal.Collection, error) {
+	if shards := self.Shards(); len(shards) > 0 {
+		return shards[0].GetCollection(collection)
+	} else {
+		return nil, fmt.Errorf("no shards configured")
+	}
+}
+
+func (self *ShardedBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	var indexers = make([]Indexer, 0)
+
+	for _, shard := range self.Shards() {
+		if search := shard.WithSearch(collection, filters...); search != nil {
+			indexers = append(indexers, search)
+		} else {
+			return nil
+		}
+	}
+
+	return &shardedIndexer{
+		backend:  self,
+		indexers: indexers,
+	}
+}
+
+func (self *ShardedBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	var aggregators = make([]Aggregator, 0)
+
+	for _, shard := range self.Shards() {
+		if aggregator := shard.WithAggregator(collection); aggregator != nil {
+			aggregators = append(aggregators, aggregator)
+		} else {
+			return nil
+		}
+	}
+
+	return &shardedAggregator{
+		backend:     self,
+		aggregators: aggregators,
+	}
+}
+
+func (self *ShardedBackend) Flush() error {
+	return self.each(func(_ int, shard Backend) error {
+		return shard.Flush()
+	})
+}
+
+func (self *ShardedBackend) Ping(timeout time.Duration) error {
+	return self.each(func(i int, shard Backend) error {
+		if err := shard.Ping(timeout); err != nil {
+			return fmt.Errorf("shard %d: %v", i, err)
+		}
+
+		return nil
+	})
+}
+
+func (self *ShardedBackend) String() string {
+	return `sharded`
+}
+
+func (self *ShardedBackend) Supports(features ...BackendFeature) bool {
+	for _, shard := range self.Shards() {
+		if !shard.Supports(features...) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Move every record in the given collection that isn't on the shard that owns it to that shard.
+// This is run after adding shards, and returns the number of records that were moved.
+func (self *ShardedBackend) Rebalance(name string) (int, error) {
+	var moved int
+
+	for i, shard := range self.Shards() {
+		collection, err := shard.GetCollection(name)
+
+		if err != nil {
+			return moved, fmt.Errorf("shard %d: %v", i, err)
+		}
+
+		var after interface{}
+
+		for {
+			var f = filter.Copy(filter.All())
+
+			f.IdentityField = collection.GetIdentityFieldName()
+			f.Sort = []string{f.IdentityField}
+			f.Limit = ShardRebalanceBatchSize
+			f.After = after
+
+			search := shard.WithSearch(collection, &f)
+
+			if search == nil {
+				return moved, fmt.Errorf("shard %d: collection %q is not enumerable", i, name)
+			}
+
+			recordset, err := search.Query(collection, &f)
+
+			if err != nil {
+				return moved, fmt.Errorf("shard %d: %v", i, err)
+			} else if len(recordset.Records) == 0 {
+				break
+			}
+
+			var lastID = recordset.Records[len(recordset.Records)-1].ID
+
+			for _, record := range recordset.Records {
+				if owner := self.ShardFor(name, record); owner >= 0 && owner != i {
+					var dest = self.shard(owner)
+					var rs = dal.NewRecordSet(record)
+
+					// a previous, interrupted rebalance may have already copied the record
+					if dest.Exists(name, record.ID) {
+						err = dest.Update(name, rs)
+					} else {
+						err = dest.Insert(name, rs)
+					}
+
+					if err != nil {
+						return moved, fmt.Errorf("shard %d: moving record %v: %v", owner, record.ID, err)
+					} else if err := shard.Delete(name, record.ID); err != nil {
+						return moved, fmt.Errorf("shard %d: removing moved record %v: %v", i, record.ID, err)
+					}
+
+					moved += 1
+				}
+			}
+
+			if typeutil.String(lastID) == typeutil.String(after) || len(recordset.Records) < f.Limit {
+				break
+			}
+
+			after = lastID
+		}
+
+		log.Debugf("%v: rebalanced shard %d", name, i)
+	}
+
+	return moved, nil
+}
+
+// hashes a key onto the ring.  FNV alone barely changes the high bits of keys that only differ in
+// their last few characters (e.g.: "item001", "item002"), which would put them all on the same
+// shard, so the hash is run through MurmurHash3's finalizer to spread them out.
+func shardHash(key string) uint32 {
+	var h = fnv.New32a()
+	h.Write([]byte(key))
+
+	var x = h.Sum32()
+
+	x ^= x >> 16
+	x *= 0x85ebca6b
+	x ^= x >> 13
+	x *= 0xc2b2ae35
+	x ^= x >> 16
+
+	return x
+}
+
+// returns whether a sorts before b, comparing numerically if both are numbers
+func shardValueLess(a interface{}, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := b.(time.Time); ok {
+			return at.Before(bt)
+		}
+	}
+
+	var aS = typeutil.String(a)
+	var bS = typeutil.String(b)
+
+	if aF, err := strconv.ParseFloat(aS, 64); err == nil {
+		if bF, err := strconv.ParseFloat(bS, 64); err == nil {
+			return aF < bF
+		}
+	}
+
+	return aS < bS
+}
+
+// queries every shard and combines the results
+type shardedIndexer struct {
+	backend  *ShardedBackend
+	indexers []Indexer
+}
+
+func (self *shardedIndexer) IndexConnectionString() *dal.ConnectionString {
+	return self.backend.GetConnectionString()
+}
+
+func (self *shardedIndexer) IndexInitialize(Backend) error {
+	return nil
+}
+
+func (self *shardedIndexer) IndexExists(collection *dal.Collection, id interface{}) bool {
+	for _, indexer := range self.indexers {
+		if indexer.IndexExists(collection, id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (self *shardedIndexer) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	var lastErr error
+
+	for _, indexer := range self.indexers {
+		if record, err := indexer.IndexRetrieve(collection, id); err == nil {
+			return record, nil
+		} else {
+			lastErr = err
+		}
+	}
+
+	return nil, lastErr
+}
+
+func (self *shardedIndexer) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	for _, indexer := range self.indexers {
+		if err := indexer.IndexRemove(collection, ids); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *shardedIndexer) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	if parts, err := self.backend.partition(collection.Name, records, false); err == nil {
+		for i, part := range parts {
+			if i < len(self.indexers) {
+				if err := self.indexers[i].Index(collection, part); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *shardedIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	if recordset, err := self.Query(collection, f); err == nil {
+		var page = IndexPage{
+			Page:         recordset.Page,
+			TotalPages:   recordset.TotalPages,
+			Limit:        f.Limit,
+			Offset:       f.Offset,
+			TotalResults: recordset.ResultCount,
+		}
+
+		for _, record := range recordset.Records {
+			if err := resultFn(record, nil, page); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Every shard is asked for enough results to fill the requested page by itself, then the combined
+// results are sorted and the page is cut from them.
+func (self *shardedIndexer) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	var perShard = filter.Copy(f)
+	var results = make([]*dal.RecordSet, len(self.indexers))
+	var errs = make([]error, len(self.indexers))
+	var wg sync.WaitGroup
+	var sortBy = f.GetSort()
+	var identity = collection.GetIdentityFieldName()
+
+	// results need a stable order to be paginated consistently, and each shard has to return its
+	// records in that same order so that the ones it leaves out are the ones that wouldn't make the page
+	if len(sortBy) == 0 {
+		sortBy = []filter.SortBy{{
+			Field: identity,
+		}}
+
+		perShard.Sort = []string{identity}
+	}
+
+	perShard.Offset = 0
+
+	if f.Limit > 0 {
+		perShard.Limit = f.Offset + f.Limit
+	}
+
+	for i, indexer := range self.indexers {
+		wg.Add(1)
+
+		go func(i int, indexer Indexer) {
+			defer wg.Done()
+
+			var shardFilter = perShard
+			results[i], errs[i] = indexer.Query(collection, &shardFilter)
+		}(i, indexer)
+	}
+
+	wg.Wait()
+
+	var records = make([]*dal.Record, 0)
+	var total int64
+	var knownSize = true
+
+	for i, rs := range results {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("shard %d: %v", i, errs[i])
+		} else if rs != nil {
+			records = append(records, rs.Records...)
+			total += rs.ResultCount
+			knownSize = knownSize && rs.KnownSize
+		}
+	}
+
+	sort.SliceStable(records, func(i int, j int) bool {
+		for _, s := range sortBy {
+			var a, b interface{}
+
+			if s.Field == identity || s.Field == `id` {
+				a, b = records[i].ID, records[j].ID
+			} else {
+				a, b = records[i].Get(s.Field), records[j].Get(s.Field)
+			}
+
+			if shardValueLess(a, b) {
+				return !s.Descending
+			} else if shardValueLess(b, a) {
+				return s.Descending
+			}
+		}
+
+		return false
+	})
+
+	if f.Offset > 0 {
+		if f.Offset < len(records) {
+			records = records[f.Offset:]
+		} else {
+			records = nil
+		}
+	}
+
+	if f.Limit > 0 && len(records) > f.Limit {
+		records = records[:f.Limit]
+	}
+
+	var recordset = dal.NewRecordSet(records...)
+	var page = IndexPage{
+		Limit:        f.Limit,
+		Offset:       f.Offset,
+		TotalResults: total,
+	}
+
+	if !knownSize {
+		page.TotalResults = -1
+	}
+
+	PopulateRecordSetPageDetails(recordset, f, page)
+
+	for _, record := range recordset.Records {
+		for _, resultFn := range resultFns {
+			if err := resultFn(record, nil, page); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return recordset, nil
+}
+
+func (self *shardedIndexer) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	var values = make(map[string][]interface{})
+	var seen = make(map[string]map[string]bool)
+
+	for i, indexer := range self.indexers {
+		if kv, err := indexer.ListValues(collection, fields, f); err == nil {
+			for field, vs := range kv {
+				if _, ok := seen[field]; !ok {
+					seen[field] = make(map[string]bool)
+				}
+
+				for _, v := range vs {
+					if key := fmt.Sprintf("%v", v); !seen[field][key] {
+						seen[field][key] = true
+						values[field] = append(values[field], v)
+					}
+				}
+			}
+		} else {
+			return nil, fmt.Errorf("shard %d: %v", i, err)
+		}
+	}
+
+	return values, nil
+}
+
+func (self *shardedIndexer) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	for i, indexer := range self.indexers {
+		if err := indexer.DeleteQuery(collection, f); err != nil {
+			return fmt.Errorf("shard %d: %v", i, err)
+		}
+	}
+
+	return nil
+}
+
+func (self *shardedIndexer) FlushIndex() error {
+	for _, indexer := range self.indexers {
+		if err := indexer.FlushIndex(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *shardedIndexer) GetBackend() Backend {
+	return self.backend
+}
+
+// combines aggregates from every shard
+type shardedAggregator struct {
+	backend     *ShardedBackend
+	aggregators []Aggregator
+}
+
+func (self *shardedAggregator) AggregatorConnectionString() *dal.ConnectionString {
+	return self.backend.GetConnectionString()
+}
+
+func (self *shardedAggregator) AggregatorInitialize(Backend) error {
+	return nil
+}
+
+func (self *shardedAggregator) Sum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	var sum float64
+
+	for _, aggregator := range self.aggregators {
+		if v, err := aggregator.Sum(collection, field, f...); err == nil {
+			sum += v
+		} else {
+			return 0, err
+		}
+	}
+
+	return sum, nil
+}
+
+func (self *shardedAggregator) Count(collection *dal.Collection, f ...*filter.Filter) (uint64, error) {
+	var count uint64
+
+	for _, aggregator := range self.aggregators {
+		if v, err := aggregator.Count(collection, f...); err == nil {
+			count += v
+		} else {
+			return 0, err
+		}
+	}
+
+	return count, nil
+}
+
+func (self *shardedAggregator) Minimum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.extreme(func(aggregator Aggregator) (float64, error) {
+		return aggregator.Minimum(collection, field, f...)
+	}, func(a float64, b float64) bool {
+		return a < b
+	})
+}
+
+func (self *shardedAggregator) Maximum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.extreme(func(aggregator Aggregator) (float64, error) {
+		return aggregator.Maximum(collection, field, f...)
+	}, func(a float64, b float64) bool {
+		return a > b
+	})
+}
+
+// The average is weighted by the number of records matching the filter on each shard.
+func (self *shardedAggregator) Average(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	if count, err := self.Count(collection, f...); err == nil {
+		if count == 0 {
+			return 0, nil
+		}
+
+		if sum, err := self.Sum(collection, field, f...); err == nil {
+			return sum / float64(count), nil
+		} else {
+			return 0, err
+		}
+	} else {
+		return 0, err
+	}
+}
+
+func (self *shardedAggregator) GroupBy(collection *dal.Collection, fields []string, aggregates []filter.Aggregate, f ...*filter.Filter) (*dal.RecordSet, error) {
+	return nil, NotImplementedError
+}
+
+func (self *shardedAggregator) extreme(fn func(Aggregator) (float64, error), better func(float64, float64) bool) (float64, error) {
+	var value float64
+
+	for i, aggregator := range self.aggregators {
+		if v, err := fn(aggregator); err == nil {
+			if i == 0 || better(v, value) {
+				value = v
+			}
+		} else {
+			return 0, err
+		}
+	}
+
+	return value, nil
+}
@@ -0,0 +1,155 @@
+package backends_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func newShard() backends.Backend {
+	return spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+}
+
+func countShardRecords(assert *require.Assertions, shard backends.Backend, name string) int {
+	collection, err := shard.GetCollection(name)
+	assert.NoError(err)
+
+	rs, err := shard.WithSearch(collection).Query(collection, filter.All())
+	assert.NoError(err)
+
+	return len(rs.Records)
+}
+
+func TestShardedBackend(t *testing.T) {
+	assert := require.New(t)
+
+	var shards = []backends.Backend{newShard(), newShard()}
+	var sharded = backends.NewShardedBackend(shards, nil)
+
+	assert.NoError(sharded.CreateCollection(dal.NewCollection(`items`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	var records = dal.NewRecordSet()
+
+	for i := 0; i < 100; i++ {
+		records.Push(dal.NewRecord(fmt.Sprintf("item%03d", i)).Set(`name`, fmt.Sprintf("Item %d", i)))
+	}
+
+	assert.NoError(sharded.Insert(`items`, records))
+
+	// records are spread across both shards, and each lives on exactly one of them
+	var a = countShardRecords(assert, shards[0], `items`)
+	var b = countShardRecords(assert, shards[1], `items`)
+
+	assert.Equal(100, a+b)
+	assert.True(a > 0 && b > 0)
+
+	// records are routed to their shard by ID
+	for _, id := range []string{`item000`, `item042`, `item099`} {
+		record, err := sharded.Retrieve(`items`, id)
+		assert.NoError(err)
+		assert.Equal(id, record.ID)
+		assert.True(sharded.Exists(`items`, id))
+		assert.True(shards[sharded.ShardFor(`items`, dal.NewRecord(id))].Exists(`items`, id))
+	}
+
+	assert.False(sharded.Exists(`items`, `nope`))
+
+	// queries are merged, sorted, and paginated across shards
+	collection, err := sharded.GetCollection(`items`)
+	assert.NoError(err)
+
+	f := filter.All()
+	f.Sort = []string{`id`}
+	f.Offset = 10
+	f.Limit = 5
+
+	rs, err := sharded.WithSearch(collection, f).Query(collection, f)
+	assert.NoError(err)
+	assert.Len(rs.Records, 5)
+
+	for i, record := range rs.Records {
+		assert.Equal(fmt.Sprintf("item%03d", 10+i), record.ID)
+	}
+
+	// (the memory driver doesn't sort by itself, so don't limit results from each shard)
+	f = filter.All()
+	f.Sort = []string{`-id`}
+
+	rs, err = sharded.WithSearch(collection, f).Query(collection, f)
+	assert.NoError(err)
+	assert.Len(rs.Records, 100)
+	assert.Equal([]interface{}{`item099`, `item098`, `item097`}, rs.IDs()[:3])
+
+	// aggregations are only available when every shard supports them, which the memory driver doesn't
+	assert.Nil(sharded.WithAggregator(collection))
+
+	// adding a shard moves only the records that now belong to it
+	var third = newShard()
+	assert.NoError(third.CreateCollection(dal.NewCollection(`items`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+	assert.NoError(sharded.AddShard(third))
+
+	moved, err := sharded.Rebalance(`items`)
+	assert.NoError(err)
+	assert.True(moved > 0 && moved < 100)
+	assert.Equal(moved, countShardRecords(assert, third, `items`))
+
+	for i := 0; i < 100; i++ {
+		id := fmt.Sprintf("item%03d", i)
+		assert.True(sharded.Exists(`items`, id), id)
+	}
+
+	// running it again has nothing left to do
+	moved, err = sharded.Rebalance(`items`)
+	assert.NoError(err)
+	assert.Zero(moved)
+
+	assert.NoError(sharded.Delete(`items`, `item042`))
+	assert.False(sharded.Exists(`items`, `item042`))
+}
+
+func TestShardedBackendByField(t *testing.T) {
+	assert := require.New(t)
+
+	var shards = []backends.Backend{newShard(), newShard(), newShard()}
+	var sharded = backends.NewShardedBackend(shards, backends.ShardByField(`tenant`))
+
+	assert.NoError(sharded.CreateCollection(dal.NewCollection(`orders`, dal.Field{
+		Name: `tenant`,
+		Type: dal.StringType,
+	})))
+
+	var records = dal.NewRecordSet()
+
+	for i := 0; i < 30; i++ {
+		records.Push(dal.NewRecord(i+1).Set(`tenant`, fmt.Sprintf("tenant%d", i%3)))
+	}
+
+	assert.NoError(sharded.Insert(`orders`, records))
+
+	// every record for a tenant lives on the same shard
+	for _, record := range records.Records {
+		var owner = sharded.ShardFor(`orders`, record)
+
+		assert.True(shards[owner].Exists(`orders`, record.ID))
+	}
+
+	// records can still be found by ID alone, which checks every shard
+	record, err := sharded.Retrieve(`orders`, 7)
+	assert.NoError(err)
+	assert.Equal(`tenant0`, record.Get(`tenant`))
+
+	// records without a shard key can't be placed
+	assert.Error(sharded.Insert(`orders`, dal.NewRecordSet(dal.NewRecord(99))))
+}
@@ -0,0 +1,385 @@
+package spi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// An Adapter wraps a Driver and implements the complete backends.Backend and backends.Indexer
+// interfaces on top of it.
+type Adapter struct {
+	driver                Driver
+	cs                    dal.ConnectionString
+	indexer               backends.Indexer
+	registeredCollections sync.Map
+}
+
+func NewAdapter(connection dal.ConnectionString, driver Driver) *Adapter {
+	return &Adapter{
+		driver: driver,
+		cs:     connection,
+	}
+}
+
+// Return the Driver this Adapter wraps.
+func (self *Adapter) Driver() Driver {
+	return self.driver
+}
+
+func (self *Adapter) String() string {
+	return self.cs.Backend()
+}
+
+func (self *Adapter) GetConnectionString() *dal.ConnectionString {
+	return &self.cs
+}
+
+func (self *Adapter) Supports(features ...backends.BackendFeature) bool {
+	if reporter, ok := self.driver.(FeatureReporter); ok {
+		return reporter.Supports(features...)
+	}
+
+	return false
+}
+
+func (self *Adapter) Initialize() error {
+	if versioned, ok := self.driver.(Versioned); ok && versioned.SpiVersion() != Version {
+		return fmt.Errorf(
+			"%v: driver implements version %d of the driver interface, expected version %d",
+			self,
+			versioned.SpiVersion(),
+			Version,
+		)
+	}
+
+	if err := self.driver.Initialize(self.cs); err != nil {
+		return err
+	}
+
+	if self.indexer != nil {
+		if err := self.indexer.IndexInitialize(self); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *Adapter) SetIndexer(connection dal.ConnectionString) error {
+	if indexer, err := backends.MakeIndexer(connection); err == nil {
+		self.indexer = indexer
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *Adapter) RegisterCollection(collection *dal.Collection) {
+	if collection != nil {
+		self.registeredCollections.Store(collection.Name, collection)
+	}
+}
+
+func (self *Adapter) Ping(timeout time.Duration) error {
+	return self.driver.Ping(timeout)
+}
+
+func (self *Adapter) Exists(name string, id interface{}) bool {
+	if collection, err := self.GetCollection(name); err == nil {
+		if _, err := self.driver.Get(collection, id); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (self *Adapter) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	if collection, err := self.GetCollection(name); err == nil {
+		if record, err := self.driver.Get(collection, id); err == nil {
+			if len(fields) > 0 {
+				record = record.OnlyFields(fields)
+			}
+
+			return record, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *Adapter) Insert(name string, recordset *dal.RecordSet) error {
+	return self.upsert(true, name, recordset)
+}
+
+func (self *Adapter) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	if len(target) > 0 {
+		return fmt.Errorf("%v: targeted updates are not supported", self)
+	}
+
+	return self.upsert(false, name, recordset)
+}
+
+func (self *Adapter) upsert(create bool, name string, recordset *dal.RecordSet) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		if err := collection.FormatRecordSet(recordset, create); err != nil {
+			return err
+		}
+
+		for i, record := range recordset.Records {
+			if r, err := collection.StructToRecord(record); err == nil {
+				recordset.Records[i] = r
+			} else {
+				return err
+			}
+
+			if err := self.driver.Put(collection, recordset.Records[i], create); err != nil {
+				return err
+			}
+		}
+
+		if self.indexer != nil && !collection.SkipIndexPersistence {
+			return self.indexer.Index(collection, recordset)
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *Adapter) Delete(name string, ids ...interface{}) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		for _, id := range ids {
+			if err := self.driver.Remove(collection, id); err != nil {
+				return err
+			}
+		}
+
+		if self.indexer != nil {
+			return self.indexer.IndexRemove(collection, ids)
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *Adapter) CreateCollection(definition *dal.Collection) error {
+	if err := self.driver.CreateCollection(definition); err == nil {
+		self.RegisterCollection(definition)
+		return nil
+	} else if dal.IsCollectionExistsErr(err) && self.cs.OptBool(`ignore_existing`, backends.DefaultIgnoreExistingCollections) {
+		self.RegisterCollection(definition)
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *Adapter) DeleteCollection(name string) error {
+	if err := self.driver.DeleteCollection(name); err == nil {
+		self.registeredCollections.Delete(name)
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *Adapter) ListCollections() ([]string, error) {
+	return self.driver.ListCollections()
+}
+
+func (self *Adapter) GetCollection(name string) (*dal.Collection, error) {
+	if collection, err := self.driver.GetCollection(name); err == nil {
+		// overlay any locally-registered definition onto what the driver gave us, since drivers
+		// are not required to persist things like formatters and validators
+		if v, ok := self.registeredCollections.Load(name); ok {
+			if err := collection.ApplyDefinition(v.(*dal.Collection)); err != nil {
+				log.Warningf("[%v] %s: failed to apply registered definition: %v", self, name, err)
+			}
+		}
+
+		collection.SetBackend(self)
+
+		return collection, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *Adapter) WithSearch(collection *dal.Collection, filters ...*filter.Filter) backends.Indexer {
+	if self.indexer != nil {
+		return self.indexer
+	}
+
+	return self
+}
+
+func (self *Adapter) WithAggregator(collection *dal.Collection) backends.Aggregator {
+	if self.indexer != nil {
+		if agg, ok := self.indexer.(backends.Aggregator); ok {
+			return agg
+		}
+	}
+
+	return nil
+}
+
+func (self *Adapter) Flush() error {
+	if flusher, ok := self.driver.(Flusher); ok {
+		if err := flusher.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if self.indexer != nil {
+		return self.indexer.FlushIndex()
+	}
+
+	return nil
+}
+
+// Indexer implementation (used when no external indexer is configured)
+// -------------------------------------------------------------------------------------------------
+
+func (self *Adapter) IndexConnectionString() *dal.ConnectionString {
+	return &self.cs
+}
+
+func (self *Adapter) IndexInitialize(_ backends.Backend) error {
+	return nil
+}
+
+func (self *Adapter) GetBackend() backends.Backend {
+	return self
+}
+
+func (self *Adapter) IndexExists(collection *dal.Collection, id interface{}) bool {
+	return self.Exists(collection.Name, id)
+}
+
+func (self *Adapter) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	return self.Retrieve(collection.Name, id)
+}
+
+func (self *Adapter) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return nil
+}
+
+func (self *Adapter) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return nil
+}
+
+func (self *Adapter) FlushIndex() error {
+	return nil
+}
+
+func (self *Adapter) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn backends.IndexResultFunc) error {
+	var page = backends.IndexPage{
+		Page:         1,
+		TotalPages:   1,
+		Limit:        f.Limit,
+		Offset:       f.Offset,
+		TotalResults: -1,
+	}
+
+	// drivers that can search natively are trusted to apply limits and offsets themselves
+	if searcher, ok := self.driver.(Searcher); ok {
+		return searcher.Search(collection, f, func(record *dal.Record) error {
+			return resultFn(record, nil, page)
+		})
+	}
+
+	var processed int
+
+	if err := self.driver.Scan(collection, func(record *dal.Record) error {
+		if !f.MatchesRecord(record) {
+			return nil
+		}
+
+		processed += 1
+
+		if processed <= f.Offset {
+			return nil
+		}
+
+		if err := resultFn(record, nil, page); err != nil {
+			return err
+		}
+
+		if f.Limit > 0 && processed >= (f.Offset+f.Limit) {
+			return StopIterating
+		}
+
+		return nil
+	}); err != nil && err != StopIterating {
+		return err
+	}
+
+	return nil
+}
+
+func (self *Adapter) Query(collection *dal.Collection, f *filter.Filter, resultFns ...backends.IndexResultFunc) (*dal.RecordSet, error) {
+	return backends.DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+func (self *Adapter) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	values := make(map[string][]interface{})
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, _ backends.IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			var value interface{}
+
+			if collection.IsIdentityField(field) {
+				value = record.ID
+			} else {
+				value = record.Get(field)
+			}
+
+			if value != nil {
+				values[field] = sliceutil.Unique(append(values[field], value))
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (self *Adapter) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	ids := make([]interface{}, 0)
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, _ backends.IndexPage) error {
+		if err == nil {
+			ids = append(ids, record.ID)
+		}
+
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if len(ids) > 0 {
+		return self.Delete(collection.Name, ids...)
+	}
+
+	return nil
+}
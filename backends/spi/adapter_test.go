@@ -0,0 +1,35 @@
+package spi_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryDriverConformance(t *testing.T) {
+	spitest.RunDriverTests(t, `memory://`, spitest.NewMemoryDriver)
+}
+
+type futureDriver struct {
+	spi.Driver
+}
+
+func (self *futureDriver) SpiVersion() int {
+	return spi.Version + 1
+}
+
+func TestAdapterDriverVersion(t *testing.T) {
+	assert := require.New(t)
+
+	adapter := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	assert.NoError(adapter.Initialize())
+
+	adapter = spi.NewAdapter(dal.MustParseConnectionString(`memory://`), &futureDriver{
+		Driver: spitest.NewMemoryDriver(),
+	})
+
+	assert.Error(adapter.Initialize())
+}
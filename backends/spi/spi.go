@@ -0,0 +1,95 @@
+// The spi package defines a small, stable interface for implementing storage backends outside of
+// Pivot.  The full backends.Backend interface mixes application-facing conveniences with
+// implementation details and changes as features are added; Driver implementations only need to
+// provide a handful of primitive operations and are adapted into a complete backends.Backend by
+// this package.
+package spi
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// The version of the Driver interface.  This number is only incremented when a change is made that
+// requires existing Driver implementations to be modified.
+const Version = 1
+
+// Returned from a ScanFunc to stop iterating without producing an error.
+var StopIterating = fmt.Errorf("stop iterating")
+
+type ScanFunc func(record *dal.Record) error
+
+// A Driver is the minimal set of operations a storage engine must support in order to be used as
+// a Pivot backend.
+type Driver interface {
+	// Connect to the underlying storage using the given connection string.
+	Initialize(connection dal.ConnectionString) error
+
+	// Verify that the underlying storage is reachable within the given timeout.
+	Ping(timeout time.Duration) error
+
+	// Return the names of all collections present in the underlying storage.
+	ListCollections() ([]string, error)
+
+	// Return the schema for the named collection, or dal.CollectionNotFound if it does not exist.
+	GetCollection(name string) (*dal.Collection, error)
+
+	// Create a new collection from the given definition, returning a dal.CollectionExistsError if
+	// it already exists.
+	CreateCollection(definition *dal.Collection) error
+
+	// Permanently remove the named collection and all of its records.
+	DeleteCollection(name string) error
+
+	// Retrieve a single record by its ID.
+	Get(collection *dal.Collection, id interface{}) (*dal.Record, error)
+
+	// Write a single record.  If create is true, an error should be returned if the record
+	// already exists.
+	Put(collection *dal.Collection, record *dal.Record, create bool) error
+
+	// Remove a single record by its ID.  Removing a record that does not exist is not an error.
+	Remove(collection *dal.Collection, id interface{}) error
+
+	// Call the given function once for every record in the collection.
+	Scan(collection *dal.Collection, fn ScanFunc) error
+}
+
+// Drivers that can evaluate filters natively (rather than relying on a full Scan) may implement
+// this interface.  The given function must be called with every matching record, honoring the
+// filter's Limit and Offset.
+type Searcher interface {
+	Search(collection *dal.Collection, f *filter.Filter, fn ScanFunc) error
+}
+
+// Drivers that buffer writes may implement this interface to be notified when data should be
+// committed to the underlying storage.
+type Flusher interface {
+	Flush() error
+}
+
+// Drivers may implement this interface to declare support for optional backend features.
+type FeatureReporter interface {
+	Supports(feature ...backends.BackendFeature) bool
+}
+
+// Drivers may implement this interface to declare which version of the Driver interface they were
+// written against.  Adapters refuse to initialize drivers written against any other version, and
+// drivers that don't implement it are assumed to have been written against the current one.
+type Versioned interface {
+	SpiVersion() int
+}
+
+type DriverFactory func() Driver
+
+// Register a Driver so that connection strings with the given scheme will be handled by it.  A
+// new Driver is instantiated (via factory) for every backend that is created.
+func Register(scheme string, factory DriverFactory) {
+	backends.RegisterBackend(scheme, func(connection dal.ConnectionString) backends.Backend {
+		return NewAdapter(connection, factory())
+	})
+}
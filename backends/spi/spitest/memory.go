@@ -0,0 +1,162 @@
+package spitest
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// A MemoryDriver is a minimal, non-persistent reference implementation of spi.Driver.  It exists
+// primarily to exercise the Adapter and to serve as an example for backend authors.
+type MemoryDriver struct {
+	collections map[string]*dal.Collection
+	records     map[string]map[string]*dal.Record
+	lock        sync.RWMutex
+}
+
+func NewMemoryDriver() spi.Driver {
+	return &MemoryDriver{
+		collections: make(map[string]*dal.Collection),
+		records:     make(map[string]map[string]*dal.Record),
+	}
+}
+
+func (self *MemoryDriver) SpiVersion() int {
+	return spi.Version
+}
+
+func (self *MemoryDriver) Initialize(_ dal.ConnectionString) error {
+	return nil
+}
+
+func (self *MemoryDriver) Ping(_ time.Duration) error {
+	return nil
+}
+
+func (self *MemoryDriver) ListCollections() ([]string, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	return maputil.StringKeys(self.collections), nil
+}
+
+func (self *MemoryDriver) GetCollection(name string) (*dal.Collection, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	if collection, ok := self.collections[name]; ok {
+		c := *collection
+		return &c, nil
+	} else {
+		return nil, dal.CollectionNotFound
+	}
+}
+
+func (self *MemoryDriver) CreateCollection(definition *dal.Collection) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if _, ok := self.collections[definition.Name]; ok {
+		return dal.CollectionExistsError{
+			Collection: definition.Name,
+		}
+	}
+
+	self.collections[definition.Name] = definition
+	self.records[definition.Name] = make(map[string]*dal.Record)
+
+	return nil
+}
+
+func (self *MemoryDriver) DeleteCollection(name string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if _, ok := self.collections[name]; ok {
+		delete(self.collections, name)
+		delete(self.records, name)
+		return nil
+	} else {
+		return dal.CollectionNotFound
+	}
+}
+
+func (self *MemoryDriver) Get(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	if record, ok := self.records[collection.Name][fmt.Sprintf("%v", id)]; ok {
+		return copyRecord(record), nil
+	} else {
+		return nil, fmt.Errorf("Record %v does not exist", id)
+	}
+}
+
+func (self *MemoryDriver) Put(collection *dal.Collection, record *dal.Record, create bool) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if records, ok := self.records[collection.Name]; ok {
+		key := fmt.Sprintf("%v", record.ID)
+
+		if _, exists := records[key]; exists && create {
+			return fmt.Errorf("Record %v already exists", record.ID)
+		}
+
+		records[key] = copyRecord(record)
+		return nil
+	} else {
+		return dal.CollectionNotFound
+	}
+}
+
+func (self *MemoryDriver) Remove(collection *dal.Collection, id interface{}) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if records, ok := self.records[collection.Name]; ok {
+		delete(records, fmt.Sprintf("%v", id))
+		return nil
+	} else {
+		return dal.CollectionNotFound
+	}
+}
+
+func (self *MemoryDriver) Scan(collection *dal.Collection, fn spi.ScanFunc) error {
+	self.lock.RLock()
+	records := make([]*dal.Record, 0, len(self.records[collection.Name]))
+
+	for _, record := range self.records[collection.Name] {
+		records = append(records, copyRecord(record))
+	}
+
+	self.lock.RUnlock()
+
+	// iterate in a stable order so that limits and offsets are meaningful
+	sort.Slice(records, func(i int, j int) bool {
+		return fmt.Sprintf("%v", records[i].ID) < fmt.Sprintf("%v", records[j].ID)
+	})
+
+	for _, record := range records {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyRecord(record *dal.Record) *dal.Record {
+	fields := make(map[string]interface{})
+
+	for k, v := range record.Fields {
+		fields[k] = v
+	}
+
+	return dal.NewRecord(record.ID, fields)
+}
@@ -0,0 +1,110 @@
+// The spitest package provides a conformance kit that backend authors can run against their own
+// spi.Driver implementations to verify they behave the way Pivot expects.
+package spitest
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+const ConformanceCollection = `spitest_conformance`
+
+// Run the driver conformance tests against a Driver created by the given factory.  The
+// connection string is passed to the Driver's Initialize function as-is.
+func RunDriverTests(t *testing.T, connection string, factory spi.DriverFactory) {
+	assert := require.New(t)
+
+	cs, err := dal.ParseConnectionString(connection)
+	assert.NoError(err)
+
+	backend := spi.NewAdapter(cs, factory())
+	assert.NoError(backend.Initialize())
+
+	collection := dal.NewCollection(ConformanceCollection, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `count`,
+		Type: dal.IntType,
+	})
+
+	collection.IdentityFieldType = dal.StringType
+
+	assert.NoError(backend.CreateCollection(collection))
+
+	defer func() {
+		assert.NoError(backend.DeleteCollection(ConformanceCollection))
+		assert.False(backend.Exists(ConformanceCollection, `one`))
+	}()
+
+	names, err := backend.ListCollections()
+	assert.NoError(err)
+	assert.Contains(names, ConformanceCollection)
+
+	_, err = backend.GetCollection(ConformanceCollection)
+	assert.NoError(err)
+
+	// create
+	assert.NoError(backend.Insert(ConformanceCollection, dal.NewRecordSet(
+		dal.NewRecord(`one`).Set(`name`, `first`).Set(`count`, 1),
+		dal.NewRecord(`two`).Set(`name`, `second`).Set(`count`, 2),
+		dal.NewRecord(`three`).Set(`name`, `third`).Set(`count`, 3),
+	)))
+
+	// creating a duplicate must fail
+	assert.Error(backend.Insert(ConformanceCollection, dal.NewRecordSet(
+		dal.NewRecord(`one`).Set(`name`, `again`),
+	)))
+
+	// retrieve
+	assert.True(backend.Exists(ConformanceCollection, `one`))
+	assert.False(backend.Exists(ConformanceCollection, `four`))
+
+	record, err := backend.Retrieve(ConformanceCollection, `two`)
+	assert.NoError(err)
+	assert.Equal(`two`, record.ID)
+	assert.Equal(`second`, record.Get(`name`))
+	assert.EqualValues(2, record.Get(`count`))
+
+	_, err = backend.Retrieve(ConformanceCollection, `four`)
+	assert.Error(err)
+
+	// update
+	assert.NoError(backend.Update(ConformanceCollection, dal.NewRecordSet(
+		dal.NewRecord(`two`).Set(`name`, `SECOND`).Set(`count`, 22),
+	)))
+
+	record, err = backend.Retrieve(ConformanceCollection, `two`)
+	assert.NoError(err)
+	assert.Equal(`SECOND`, record.Get(`name`))
+	assert.EqualValues(22, record.Get(`count`))
+
+	// query
+	search := backend.WithSearch(collection)
+	assert.NotNil(search)
+
+	recordset, err := search.Query(collection, filter.MustParse(`name/third`))
+	assert.NoError(err)
+	assert.Len(recordset.Records, 1)
+	assert.Equal(`three`, recordset.Records[0].ID)
+
+	recordset, err = search.Query(collection, filter.All())
+	assert.NoError(err)
+	assert.Len(recordset.Records, 3)
+
+	values, err := search.ListValues(collection, []string{`name`}, filter.All())
+	assert.NoError(err)
+	assert.ElementsMatch([]interface{}{`first`, `SECOND`, `third`}, values[`name`])
+
+	// delete
+	assert.NoError(backend.Delete(ConformanceCollection, `one`))
+	assert.False(backend.Exists(ConformanceCollection, `one`))
+
+	assert.NoError(search.DeleteQuery(collection, filter.MustParse(`name/third`)))
+	assert.False(backend.Exists(ConformanceCollection, `three`))
+	assert.True(backend.Exists(ConformanceCollection, `two`))
+}
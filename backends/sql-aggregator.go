@@ -3,8 +3,8 @@ package backends
 // this file satifies the Aggregator interface for SqlBackend
 
 import (
+	"context"
 	"database/sql"
-	"reflect"
 
 	"github.com/ghetzel/go-stockutil/typeutil"
 	"github.com/ghetzel/pivot/v3/dal"
@@ -49,6 +49,40 @@ func (self *SqlBackend) GroupBy(collection *dal.Collection, groupBy []string, ag
 	}
 }
 
+// Counts records grouped by the distinct values of the given fields using a single GROUP BY query.
+func (self *SqlBackend) CountBy(collection *dal.Collection, fields []string, f ...*filter.Filter) ([]GroupCount, error) {
+	var flt filter.Filter
+
+	if len(f) > 0 && f[0] != nil {
+		flt = filter.Copy(f[0])
+	} else {
+		flt = filter.MakeFilter(filter.AllValue)
+	}
+
+	// only the grouped fields and the count should be selected, and every group is wanted
+	flt.Fields = nil
+	flt.Sort = nil
+	flt.Limit = 0
+	flt.Offset = 0
+
+	whatToCount := collection.IdentityField
+
+	if typeutil.IsZero(whatToCount) {
+		whatToCount = `1`
+	}
+
+	if result, err := self.aggregate(collection, fields, []filter.Aggregate{
+		{
+			Aggregation: filter.Count,
+			Field:       whatToCount,
+		},
+	}, []*filter.Filter{&flt}, self.extractGroupCounts); err == nil {
+		return result.([]GroupCount), nil
+	} else {
+		return nil, err
+	}
+}
+
 func (self *SqlBackend) aggregateFloat(collection *dal.Collection, aggregation filter.Aggregation, field string, f []*filter.Filter) (float64, error) {
 	if result, err := self.aggregate(collection, nil, []filter.Aggregate{
 		{
@@ -64,6 +98,8 @@ func (self *SqlBackend) aggregateFloat(collection *dal.Collection, aggregation f
 
 func (self *SqlBackend) aggregate(collection *dal.Collection, groupBy []string, aggregates []filter.Aggregate, f []*filter.Filter, resultFn sqlAggResultFunc) (interface{}, error) {
 	queryGen := self.makeQueryGen(collection)
+	queryGen.AsOfSystemTime = self.asOfSystemTime
+
 	var flt *filter.Filter
 
 	if len(f) == 0 {
@@ -85,7 +121,7 @@ func (self *SqlBackend) aggregate(collection *dal.Collection, groupBy []string,
 			querylog.Debugf("[%T] %s %v", self, string(stmt[:]), queryGen.GetValues())
 
 			// perform query
-			if rows, err := self.db.Query(string(stmt[:]), queryGen.GetValues()...); err == nil {
+			if rows, err := self.readQuery(context.Background(), string(stmt[:]), queryGen.GetValues()...); err == nil {
 				defer rows.Close()
 				return resultFn(rows, queryGen, collection, flt)
 			} else {
@@ -125,8 +161,11 @@ func (self *SqlBackend) extractRecordSet(rows *sql.Rows, queryGen *generators.Sq
 	recordset := dal.NewRecordSet()
 
 	if columns, err := rows.Columns(); err == nil {
+		plan := self.newScanPlan(queryGen, collection, columns, flt.Fields)
+		defer plan.Release()
+
 		for rows.Next() {
-			if record, err := self.scanFnValueToRecord(queryGen, collection, columns, reflect.ValueOf(rows.Scan), flt.Fields); err == nil {
+			if record, err := self.scanFnValueToRecord(plan, rows.Scan); err == nil {
 				recordset.Push(record)
 			} else {
 				return nil, err
@@ -138,3 +177,38 @@ func (self *SqlBackend) extractRecordSet(rows *sql.Rows, queryGen *generators.Sq
 
 	return recordset, nil
 }
+
+func (self *SqlBackend) extractGroupCounts(rows *sql.Rows, _ *generators.Sql, _ *dal.Collection, _ *filter.Filter) (interface{}, error) {
+	groups := make([]GroupCount, 0)
+
+	if columns, err := rows.Columns(); err == nil {
+		for rows.Next() {
+			output := make([]interface{}, len(columns))
+			pointers := make([]interface{}, len(columns))
+
+			for i := range output {
+				pointers[i] = &output[i]
+			}
+
+			if err := rows.Scan(pointers...); err != nil {
+				return nil, err
+			}
+
+			for i, value := range output {
+				if asBytes, ok := value.([]byte); ok {
+					output[i] = string(asBytes)
+				}
+			}
+
+			// grouped fields come first, followed by the count
+			groups = append(groups, GroupCount{
+				Values: output[:len(output)-1],
+				Count:  uint64(typeutil.Int(output[len(output)-1])),
+			})
+		}
+	} else {
+		return nil, err
+	}
+
+	return groups, rows.Err()
+}
@@ -0,0 +1,52 @@
+package backends
+
+import (
+	"github.com/ghetzel/pivot/v3/filter/generators"
+	"github.com/lib/pq"
+)
+
+// CockroachDB speaks the PostgreSQL wire protocol, so it is accessed using the same driver and
+// shares most of the PostgreSQL schema handling.
+func preinitializeCockroach(self *SqlBackend) {
+	preinitializePostgres(self)
+
+	self.queryGenTypeMapping = generators.CockroachTypeMapping
+	self.listAllTablesQuery = `SELECT table_name from information_schema.TABLES WHERE table_catalog = current_database() AND table_schema = 'public'`
+	self.createPrimaryKeyIntFormat = `%s INT8 DEFAULT unique_rowid()`
+	self.createPrimaryKeyStrFormat = `%s STRING`
+
+	// pg_class statistics aren't maintained by CockroachDB
+	self.countEstimateQuery = ``
+
+	// generated IDs are not sequential, so ask for them back rather than guessing
+	self.insertReturningIdentity = true
+
+	// CockroachDB's advisory lock functions don't provide mutual exclusion, so only lock within
+	// this process
+	self.advisoryLockFunc = nil
+	self.advisoryUnlockFunc = nil
+
+	// transactions that conflict with others are aborted, and must be retried by the client
+	self.isRetryableErrorFunc = cockroachIsRetryableError
+
+	// queries (but not retrievals, which should see the latest writes) can be served by the
+	// nearest replica by reading slightly historical data
+	if self.conn.OptBool(`follower_reads`, false) {
+		self.asOfSystemTime = `follower_read_timestamp()`
+	}
+}
+
+// serialization_failure (e.g.: "restart transaction: TransactionRetryWithProtoRefreshError")
+func cockroachIsRetryableError(err error) bool {
+	if pqErr, ok := err.(*pq.Error); ok {
+		return pqErr.Code == `40001`
+	}
+
+	return false
+}
+
+func initializeCockroach(self *SqlBackend) (string, string, error) {
+	self.refreshCollectionFunc = postgresRefreshCollectionFunc(self, `current_database()`)
+
+	return `postgres`, postgresDSN(self, 26257), nil
+}
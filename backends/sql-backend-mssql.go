@@ -0,0 +1,311 @@
+package backends
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/ghetzel/pivot/v3/filter/generators"
+)
+
+func preinitializeMssql(self *SqlBackend) {
+	// tell the backend cool details about generating compatible SQL
+	self.queryGenTypeMapping = generators.MssqlTypeMapping
+	self.queryGenNormalizerFormat = "LOWER(REPLACE(REPLACE(REPLACE(REPLACE(%v, ':', ' '), '[', ' '), ']', ' '), '*', ' '))"
+	self.listAllTablesQuery = `SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_TYPE = 'BASE TABLE' AND TABLE_CATALOG = DB_NAME()`
+	self.createPrimaryKeyIntFormat = `%s BIGINT IDENTITY(1,1) NOT NULL`
+	self.createPrimaryKeyStrFormat = `%s NVARCHAR(255) NOT NULL`
+	self.foreignKeyConstraintFormat = `FOREIGN KEY(%s) REFERENCES %s (%s) %s`
+	self.defaultCurrentTimeString = `CURRENT_TIMESTAMP`
+	self.listIndexesQuery = `SELECT name FROM sys.indexes WHERE object_id = OBJECT_ID('%s') AND name IS NOT NULL`
+
+	// the driver doesn't support LastInsertId, so have the database tell us the IDs it generated
+	self.insertReturningIdentity = true
+
+	self.advisoryLockFunc = mssqlAdvisoryLock
+	self.advisoryUnlockFunc = mssqlAdvisoryUnlock
+	self.isExistsErrorFunc = mssqlIsExistsError
+
+	// the default collations compare identifiers without regard to case
+	self.identifierCase = LowerIdentifierCase
+}
+
+// application locks owned by the session are held until they are released or the connection
+// closes; resource names are limited to 255 characters
+func mssqlAdvisoryLock(ctx context.Context, conn *sql.Conn, name string, timeout time.Duration) error {
+	var result int
+
+	if err := conn.QueryRowContext(
+		ctx,
+		`DECLARE @result INT; `+
+			`EXEC @result = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = @p2; `+
+			`SELECT @result`,
+		sqlAdvisoryLockName(name, 255),
+		timeout.Milliseconds(),
+	).Scan(&result); err != nil {
+		return err
+	} else if result < 0 {
+		return fmt.Errorf("failed to acquire lock %q (status %d)", name, result)
+	}
+
+	return nil
+}
+
+func mssqlAdvisoryUnlock(conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(
+		context.Background(),
+		`EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'`,
+		sqlAdvisoryLockName(name, 255),
+	)
+
+	return err
+}
+
+// "There is already an object named ... in the database."
+func mssqlIsExistsError(err error) bool {
+	if msErr, ok := err.(mssql.Error); ok {
+		return msErr.Number == 2714
+	}
+
+	return false
+}
+
+func initializeMssql(self *SqlBackend) (string, string, error) {
+	// the bespoke method for determining table information for SQL Server
+	self.refreshCollectionFunc = func(datasetName string, collectionName string) (*dal.Collection, error) {
+		keyStmt := `SELECT ` +
+			`kc.COLUMN_NAME, tc.CONSTRAINT_TYPE ` +
+			`FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS tc, INFORMATION_SCHEMA.KEY_COLUMN_USAGE kc ` +
+			`WHERE kc.TABLE_NAME = tc.TABLE_NAME ` +
+			`AND kc.TABLE_SCHEMA = tc.TABLE_SCHEMA ` +
+			`AND kc.CONSTRAINT_NAME = tc.CONSTRAINT_NAME ` +
+			`AND tc.CONSTRAINT_CATALOG = DB_NAME() ` +
+			`AND tc.TABLE_NAME = @p1 ` +
+			`ORDER BY kc.COLUMN_NAME, tc.CONSTRAINT_TYPE`
+
+		primaryKeys := make(map[string]bool)
+		uniqueKeys := make(map[string]bool)
+		foreignKeys := make(map[string]bool)
+
+		if keyRows, err := self.db.Query(keyStmt, collectionName); err == nil {
+			defer keyRows.Close()
+
+			// for each key on this table...
+			for keyRows.Next() {
+				var columnName, constraintType string
+
+				if err := keyRows.Scan(&columnName, &constraintType); err == nil {
+					switch constraintType {
+					case `PRIMARY KEY`:
+						primaryKeys[columnName] = true
+					case `FOREIGN KEY`:
+						foreignKeys[columnName] = true
+					case `UNIQUE`:
+						uniqueKeys[columnName] = true
+					}
+				} else {
+					return nil, err
+				}
+			}
+
+			keyRows.Close()
+		} else {
+			return nil, err
+		}
+
+		if f, err := filter.FromMap(map[string]interface{}{
+			`TABLE_CATALOG`: datasetName,
+			`TABLE_NAME`:    collectionName,
+		}); err == nil {
+			f.Fields = []string{
+				`ORDINAL_POSITION`,
+				`COLUMN_NAME`,
+				`DATA_TYPE`,
+				`CHARACTER_MAXIMUM_LENGTH`,
+				`IS_NULLABLE`,
+				`COLUMN_DEFAULT`,
+			}
+
+			queryGen := self.makeQueryGen(nil)
+
+			// make this instance of the query generator use the table name as given because
+			// we need to reference another schema (INFORMATION_SCHEMA)
+			queryGen.TypeMapping.TableNameFormat = "%s"
+
+			if stmt, err := filter.Render(queryGen, `INFORMATION_SCHEMA.COLUMNS`, f); err == nil {
+				querylog.Debugf("[%T] %s %v", self, string(stmt[:]), queryGen.GetValues())
+
+				if rows, err := self.db.Query(string(stmt[:]), queryGen.GetValues()...); err == nil {
+					defer rows.Close()
+
+					collection := dal.NewCollection(collectionName)
+					var found int
+
+					// for each field in the schema description for this table...
+					for rows.Next() {
+						found += 1
+
+						var i int
+						var charMaxLength sql.NullInt64
+						var column, columnType, nullable string
+						var defaultValue sql.NullString
+
+						// populate variables from column values
+						if err := rows.Scan(&i, &column, &columnType, &charMaxLength, &nullable, &defaultValue); err == nil {
+							// start building the dal.Field
+							field := dal.Field{
+								Name:       column,
+								NativeType: columnType,
+								Required:   (nullable != `YES`),
+							}
+
+							// set default value if it's not NULL; SQL Server wraps these in parentheses
+							// e.g.: ('hello'), ((42)), (getdate())
+							if defaultValue.Valid {
+								dv := defaultValue.String
+
+								for stringutil.IsSurroundedBy(dv, `(`, `)`) {
+									dv = stringutil.Unwrap(dv, `(`, `)`)
+								}
+
+								dv = stringutil.Unwrap(dv, `'`, `'`)
+
+								switch strings.ToLower(dv) {
+								case `getdate()`, `sysdatetime()`, `current_timestamp`:
+									dv = `now`
+								}
+
+								field.DefaultValue = stringutil.Autotype(dv)
+							}
+
+							columnType = strings.ToUpper(columnType)
+							field.Length = int(charMaxLength.Int64)
+
+							// map native types to DAL types
+							if strings.HasSuffix(columnType, `CHAR`) || strings.HasSuffix(columnType, `TEXT`) {
+								// objects and arrays are stored in NVARCHAR(MAX) and VARCHAR(MAX) columns,
+								// respectively (which are reported as having a length of -1)
+								if field.Length < 0 {
+									switch columnType {
+									case generators.MssqlTypeMapping.ObjectType:
+										field.Type = dal.ObjectType
+									case generators.MssqlTypeMapping.ArrayType:
+										field.Type = dal.ArrayType
+									default:
+										field.Type = dal.StringType
+									}
+
+									field.Length = 0
+								} else {
+									field.Type = dal.StringType
+								}
+
+							} else if columnType == `BIT` {
+								field.Type = dal.BooleanType
+
+							} else if strings.HasSuffix(columnType, `INT`) {
+								field.Type = dal.IntType
+
+							} else if columnType == `DECIMAL` || columnType == `NUMERIC` || columnType == `FLOAT` || columnType == `REAL` || strings.HasSuffix(columnType, `MONEY`) {
+								field.Type = dal.FloatType
+
+							} else if strings.HasPrefix(columnType, `DATE`) || strings.Contains(columnType, `TIME`) {
+								field.Type = dal.TimeType
+
+							} else {
+								field.Type = dal.RawType
+							}
+
+							// figure out keying
+							if v, ok := primaryKeys[column]; ok && v {
+								field.Identity = true
+								collection.IdentityField = column
+								collection.IdentityFieldType = field.Type
+							} else if v, ok := foreignKeys[column]; ok && v {
+								field.Key = true
+							}
+
+							if v, ok := uniqueKeys[column]; ok && v {
+								field.Unique = true
+							}
+
+							// add field to the collection we're building
+							if !field.Identity {
+								collection.Fields = append(collection.Fields, field)
+							}
+						} else {
+							return nil, err
+						}
+					}
+
+					if found > 0 {
+						return collection, rows.Err()
+					} else {
+						return nil, dal.CollectionNotFound
+					}
+				} else {
+					return nil, err
+				}
+			} else {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	return `sqlserver`, mssqlDSN(self), nil
+}
+
+// build a go-mssqldb connection string from the backend's connection string
+func mssqlDSN(self *SqlBackend) string {
+	var dsn = &url.URL{
+		Scheme: `sqlserver`,
+	}
+
+	// append port to host if not present
+	if strings.Contains(self.conn.Host(), `:`) {
+		dsn.Host = self.conn.Host()
+	} else {
+		dsn.Host = fmt.Sprintf("%s:1433", self.conn.Host())
+	}
+
+	if u, p, ok := self.conn.Credentials(); ok {
+		dsn.User = url.UserPassword(u, p)
+	}
+
+	opts := self.conn.URI.Query()
+
+	// pull out pivot-specific options first
+	for k, vv := range opts {
+		switch k {
+		case `autoregister`:
+			self.conn.Options[k] = strings.Join(vv, `,`)
+			opts.Del(k)
+		case `autocount`:
+			self.conn.Options[k] = typeutil.V(vv).Bool()
+			opts.Del(k)
+		case `batchsize`:
+			self.conn.Options[k] = typeutil.V(vv).Int()
+			opts.Del(k)
+		case `replica`, `replica_policy`, `identifier_case`:
+			opts.Del(k)
+		}
+	}
+
+	if dataset := self.conn.Dataset(); dataset != `` {
+		opts.Set(`database`, dataset)
+	}
+
+	dsn.RawQuery = opts.Encode()
+
+	return dsn.String()
+}
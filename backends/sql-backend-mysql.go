@@ -1,15 +1,18 @@
 package backends
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"math"
 	"strings"
+	"time"
 
 	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/ghetzel/pivot/v3/dal"
 	"github.com/ghetzel/pivot/v3/filter"
 	"github.com/ghetzel/pivot/v3/filter/generators"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 func preinitializeMysql(self *SqlBackend) {
@@ -21,6 +24,75 @@ func preinitializeMysql(self *SqlBackend) {
 	self.createPrimaryKeyStrFormat = `%s VARCHAR(255) NOT NULL`
 	self.foreignKeyConstraintFormat = `FOREIGN KEY(%s) REFERENCES %s (%s) %s`
 	self.defaultCurrentTimeString = `CURRENT_TIMESTAMP`
+	self.listIndexesQuery = `SELECT DISTINCT INDEX_NAME FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = '%s'`
+	self.advisoryLockFunc = mysqlAdvisoryLock
+	self.advisoryUnlockFunc = mysqlAdvisoryUnlock
+	self.isExistsErrorFunc = mysqlIsExistsError
+	self.searchIndexFunc = mysqlSearchIndexes
+
+	// table names are lowercased on case-insensitive filesystems (lower_case_table_names)
+	self.identifierCase = LowerIdentifierCase
+}
+
+// lock names are limited to 64 characters
+func mysqlAdvisoryLock(ctx context.Context, conn *sql.Conn, name string, timeout time.Duration) error {
+	var acquired sql.NullInt64
+
+	if err := conn.QueryRowContext(
+		ctx,
+		`SELECT GET_LOCK(?, ?)`,
+		sqlAdvisoryLockName(name, 64),
+		int(math.Ceil(timeout.Seconds())),
+	).Scan(&acquired); err != nil {
+		return err
+	} else if acquired.Int64 != 1 {
+		return fmt.Errorf("timed out after %v waiting for lock %q", timeout, name)
+	}
+
+	return nil
+}
+
+func mysqlAdvisoryUnlock(conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, sqlAdvisoryLockName(name, 64))
+	return err
+}
+
+// MATCH() must name exactly the columns of a FULLTEXT index, so there is one index covering all of
+// the search fields (for searching all of them at once) and one for each individual field
+func mysqlSearchIndexes(collection *dal.Collection, gen *generators.Sql) []string {
+	var stmts = make([]string, 0)
+	var all = make([]string, 0)
+
+	for _, sf := range collection.SearchFields {
+		all = append(all, gen.ToFieldName(sf.Name))
+
+		stmts = append(stmts, fmt.Sprintf(
+			"CREATE FULLTEXT INDEX %s ON %s (%s)",
+			gen.ToTableName(collection.Name+`_search_`+sf.Name),
+			gen.ToTableName(collection.Name),
+			gen.ToFieldName(sf.Name),
+		))
+	}
+
+	if len(all) > 1 {
+		stmts = append(stmts, fmt.Sprintf(
+			"CREATE FULLTEXT INDEX %s ON %s (%s)",
+			gen.ToTableName(collection.Name+`_search`),
+			gen.ToTableName(collection.Name),
+			strings.Join(all, `, `),
+		))
+	}
+
+	return stmts
+}
+
+// ER_TABLE_EXISTS_ERROR
+func mysqlIsExistsError(err error) bool {
+	if myErr, ok := err.(*mysql.MySQLError); ok {
+		return myErr.Number == 1050
+	}
+
+	return false
 }
 
 func initializeMysql(self *SqlBackend) (string, string, error) {
@@ -139,6 +211,12 @@ func initializeMysql(self *SqlBackend) (string, string, error) {
 		}
 	}
 
+	return `mysql`, mysqlDSN(self, 3306), nil
+}
+
+// builds the go-sql-driver/mysql DSN for connecting to MySQL (and compatible databases), using the
+// given port if the connection string doesn't specify one
+func mysqlDSN(self *SqlBackend, defaultPort int) string {
 	var dsn, protocol, host string
 
 	// set or autodetect protocol
@@ -154,7 +232,7 @@ func initializeMysql(self *SqlBackend) (string, string, error) {
 	if strings.Contains(self.conn.Host(), `:`) {
 		host = self.conn.Host()
 	} else {
-		host = fmt.Sprintf("%s:3306", self.conn.Host())
+		host = fmt.Sprintf("%s:%d", self.conn.Host(), defaultPort)
 	}
 
 	if u, p, ok := self.conn.Credentials(); ok {
@@ -168,5 +246,5 @@ func initializeMysql(self *SqlBackend) (string, string, error) {
 		self.conn.Dataset(),
 	)
 
-	return `mysql`, dsn, nil
+	return dsn
 }
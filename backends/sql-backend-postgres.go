@@ -1,9 +1,11 @@
 package backends
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/ghetzel/go-stockutil/sliceutil"
 	"github.com/ghetzel/go-stockutil/stringutil"
@@ -11,12 +13,18 @@ import (
 	"github.com/ghetzel/pivot/v3/dal"
 	"github.com/ghetzel/pivot/v3/filter"
 	"github.com/ghetzel/pivot/v3/filter/generators"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 func preinitializePostgres(self *SqlBackend) {
 	// tell the backend cool details about generating compatible SQL
 	self.queryGenTypeMapping = generators.PostgresTypeMapping
+
+	// store objects and arrays as JSONB, making their nested fields queryable
+	if self.conn.OptBool(`jsonb`, false) {
+		self.queryGenTypeMapping = generators.PostgresJsonTypeMapping
+	}
+
 	self.queryGenNormalizerFormat = "regexp_replace(lower(%v), '[\\:\\[\\]\\*]+', ' ')"
 	self.listAllTablesQuery = `SELECT table_name from information_schema.TABLES WHERE table_catalog = CURRENT_CATALOG AND table_schema = 'public'`
 	self.createPrimaryKeyIntFormat = `%s BIGSERIAL`
@@ -26,18 +34,52 @@ func preinitializePostgres(self *SqlBackend) {
 	self.foreignKeyConstraintFormat = `FOREIGN KEY(%s) REFERENCES %s (%s) %s`
 	// self.defaultCurrentTimeString = `now() AT TIME ZONE 'utc'`
 	self.defaultCurrentTimeString = `current_timestamp`
+	self.listIndexesQuery = `SELECT indexname FROM pg_indexes WHERE tablename = '%s'`
+	self.advisoryLockFunc = postgresAdvisoryLock
+	self.advisoryUnlockFunc = postgresAdvisoryUnlock
+	self.isExistsErrorFunc = postgresIsExistsError
+
+	// unquoted identifiers are folded to lowercase
+	self.identifierCase = LowerIdentifierCase
+}
+
+// session-level advisory locks are held until they are explicitly released or the connection closes
+func postgresAdvisoryLock(ctx context.Context, conn *sql.Conn, name string, _ time.Duration) error {
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, sqlAdvisoryLockKey(name))
+	return err
+}
+
+func postgresAdvisoryUnlock(conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, sqlAdvisoryLockKey(name))
+	return err
+}
+
+// duplicate_table
+func postgresIsExistsError(err error) bool {
+	if pqErr, ok := err.(*pq.Error); ok {
+		return pqErr.Code == `42P07`
+	}
+
+	return false
 }
 
 func initializePostgres(self *SqlBackend) (string, string, error) {
-	// the bespoke method for determining table information for sqlite3
-	self.refreshCollectionFunc = func(datasetName string, collectionName string) (*dal.Collection, error) {
+	self.refreshCollectionFunc = postgresRefreshCollectionFunc(self, `CURRENT_CATALOG`)
+
+	return `postgres`, postgresDSN(self, 5432), nil
+}
+
+// the bespoke method for determining table information for PostgreSQL (and compatible databases),
+// given an expression that evaluates to the name of the current database
+func postgresRefreshCollectionFunc(self *SqlBackend, currentCatalog string) sqlTableDetailsFunc {
+	return func(datasetName string, collectionName string) (*dal.Collection, error) {
 		keyStmt := `SELECT ` +
 			`kc.column_name, tc.constraint_type ` +
 			`FROM information_schema.table_constraints tc, information_schema.key_column_usage kc ` +
 			`WHERE kc.table_name = tc.table_name ` +
 			`AND kc.table_schema = tc.table_schema ` +
 			`AND kc.constraint_name = tc.constraint_name ` +
-			`AND tc.constraint_catalog = CURRENT_CATALOG ` +
+			`AND tc.constraint_catalog = ` + currentCatalog + ` ` +
 			`AND tc.table_name = $1 ` +
 			`ORDER BY kc.column_name, tc.constraint_type`
 
@@ -83,6 +125,7 @@ func initializePostgres(self *SqlBackend) (string, string, error) {
 				`ordinal_position`,
 				`column_name`,
 				`data_type`,
+				`udt_name`,
 				`character_maximum_length`,
 				`is_nullable`,
 				`column_default`,
@@ -109,11 +152,11 @@ func initializePostgres(self *SqlBackend) (string, string, error) {
 
 						var i int
 						var charMaxLength sql.NullInt64
-						var column, columnType, nullable string
+						var column, columnType, udtName, nullable string
 						var defaultValue sql.NullString
 
 						// populate variables from column values
-						if err := rows.Scan(&i, &column, &columnType, &charMaxLength, &nullable, &defaultValue); err == nil {
+						if err := rows.Scan(&i, &column, &columnType, &udtName, &charMaxLength, &nullable, &defaultValue); err == nil {
 							// start building the dal.Field
 							field := dal.Field{
 								Name:       column,
@@ -142,7 +185,11 @@ func initializePostgres(self *SqlBackend) (string, string, error) {
 							// field.Precision =
 
 							// map native types to DAL types
-							if strings.Contains(columnType, `CHAR`) || strings.HasSuffix(columnType, `TEXT`) {
+							if columnType == `JSONB` || columnType == `JSON` {
+								// arrays are stored the same way, and are decoded as such when read
+								field.Type = dal.ObjectType
+
+							} else if strings.Contains(columnType, `CHAR`) || strings.HasSuffix(columnType, `TEXT`) {
 								switch field.Length {
 								case SqlObjectFieldHintLength:
 									field.Type = dal.ObjectType
@@ -167,6 +214,10 @@ func initializePostgres(self *SqlBackend) (string, string, error) {
 							} else if strings.HasPrefix(columnType, `DATE`) || strings.Contains(columnType, `TIME`) {
 								field.Type = dal.TimeType
 
+							} else if udtName == `geography` {
+								// PostGIS types are reported as "USER-DEFINED"
+								field.Type = dal.GeopointType
+
 							} else {
 								field.Type = dal.RawType
 							}
@@ -209,7 +260,10 @@ func initializePostgres(self *SqlBackend) (string, string, error) {
 			return nil, err
 		}
 	}
+}
 
+// build a lib/pq connection string from the backend's connection string
+func postgresDSN(self *SqlBackend, defaultPort int) string {
 	var dsn, host string
 
 	dsn = `postgres://`
@@ -218,7 +272,7 @@ func initializePostgres(self *SqlBackend) (string, string, error) {
 	if strings.Contains(self.conn.Host(), `:`) {
 		host = self.conn.Host()
 	} else {
-		host = fmt.Sprintf("%s:5432", self.conn.Host())
+		host = fmt.Sprintf("%s:%d", self.conn.Host(), defaultPort)
 	}
 
 	if u, p, ok := self.conn.Credentials(); ok {
@@ -236,9 +290,14 @@ func initializePostgres(self *SqlBackend) (string, string, error) {
 		case `autoregister`:
 			self.conn.Options[k] = strings.Join(vv, `,`)
 			opts.Del(k)
-		case `autocount`:
+		case `autocount`, `jsonb`:
 			self.conn.Options[k] = typeutil.V(vv).Bool()
 			opts.Del(k)
+		case `batchsize`:
+			self.conn.Options[k] = typeutil.V(vv).Int()
+			opts.Del(k)
+		case `replica`, `replica_policy`, `identifier_case`:
+			opts.Del(k)
 		}
 	}
 
@@ -248,5 +307,5 @@ func initializePostgres(self *SqlBackend) (string, string, error) {
 		dsn += `?` + v
 	}
 
-	return `postgres`, dsn, nil
+	return dsn
 }
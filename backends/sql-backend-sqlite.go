@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path"
+	"strings"
 
 	"github.com/ghetzel/go-stockutil/log"
 	"github.com/ghetzel/go-stockutil/maputil"
@@ -24,6 +25,55 @@ func preinitializeSqlite(self *SqlBackend) {
 	self.createPrimaryKeyStrFormat = `%s TEXT NOT NULL`
 	self.foreignKeyConstraintFormat = `FOREIGN KEY(%s) REFERENCES %s(%s) %s`
 	self.defaultCurrentTimeString = `CURRENT_TIMESTAMP`
+	self.listIndexesQuery = `SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = '%s'`
+
+	// column lengths are accepted but not enforced
+	self.ignoresTypeLengths = true
+
+	// table names are case-insensitive
+	self.identifierCase = LowerIdentifierCase
+
+	// SQLite doesn't distinguish this error by code
+	self.isExistsErrorFunc = func(err error) bool {
+		return log.ErrContains(err, `already exists`)
+	}
+
+	self.searchIndexFunc = sqliteSearchIndex
+}
+
+// full-text queries are run against an FTS5 table that indexes the search fields of the table it
+// is named after, and which triggers keep in sync with that table
+func sqliteSearchIndex(collection *dal.Collection, gen *generators.Sql) []string {
+	var table = gen.ToTableName(collection.Name)
+	var fts = gen.ToTableName(collection.Name + generators.SqlFulltextTableSuffix)
+	var fields = make([]string, 0)
+	var newValues = make([]string, 0)
+	var oldValues = make([]string, 0)
+
+	for _, sf := range collection.SearchFields {
+		var field = gen.ToFieldName(sf.Name)
+
+		fields = append(fields, field)
+		newValues = append(newValues, `new.`+field)
+		oldValues = append(oldValues, `old.`+field)
+	}
+
+	var columns = strings.Join(fields, `, `)
+	var insertNew = fmt.Sprintf("INSERT INTO %s (rowid, %s) VALUES (new.rowid, %s);", fts, columns, strings.Join(newValues, `, `))
+	var deleteOld = fmt.Sprintf("INSERT INTO %s (%s, rowid, %s) VALUES ('delete', old.rowid, %s);", fts, fts, columns, strings.Join(oldValues, `, `))
+
+	return []string{
+		fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(%s, content=%s)", fts, columns, sqliteQuote(collection.Name)),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s AFTER INSERT ON %s BEGIN %s END", gen.ToTableName(collection.Name+`_fts_insert`), table, insertNew),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s AFTER DELETE ON %s BEGIN %s END", gen.ToTableName(collection.Name+`_fts_delete`), table, deleteOld),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s AFTER UPDATE ON %s BEGIN %s %s END", gen.ToTableName(collection.Name+`_fts_update`), table, deleteOld, insertNew),
+		fmt.Sprintf("INSERT INTO %s (%s) VALUES ('rebuild')", fts, fts),
+	}
+}
+
+// quote a string literal
+func sqliteQuote(in string) string {
+	return `'` + strings.ReplaceAll(in, `'`, `''`) + `'`
 }
 
 func initializeSqlite(self *SqlBackend) (string, string, error) {
@@ -0,0 +1,87 @@
+package backends
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter/generators"
+	"github.com/go-sql-driver/mysql"
+)
+
+// TiDB speaks the MySQL wire protocol, so it is accessed using the same driver and shares the
+// MySQL schema handling, save for the handful of features it does not support.
+func preinitializeTidb(self *SqlBackend) {
+	preinitializeMysql(self)
+
+	self.queryGenTypeMapping = generators.TidbTypeMapping
+
+	// FULLTEXT indexes aren't supported
+	self.searchIndexFunc = nil
+
+	// GET_LOCK() is only honored by the TiDB server it was called on, so only lock within this
+	// process
+	self.advisoryLockFunc = nil
+	self.advisoryUnlockFunc = nil
+
+	// transactions are optimistic by default, and those that conflict with others fail when they
+	// are committed, and must be retried by the client
+	self.isRetryableErrorFunc = tidbIsRetryableError
+
+	// tables can be partitioned and placed according to the collection's placement options
+	self.tableOptionsFunc = tidbTableOptions
+}
+
+// ErrWriteConflict, ErrTxnRetryable, ErrInfoSchemaChanged, and ErrWriteConflictInTiDB
+func tidbIsRetryableError(err error) bool {
+	if myErr, ok := err.(*mysql.MySQLError); ok {
+		switch myErr.Number {
+		case 9007, 8022, 8028, 8005:
+			return true
+		}
+	}
+
+	return false
+}
+
+// renders the placement policy and partitioning options that follow the column definitions in a
+// CREATE TABLE statement
+func tidbTableOptions(collection *dal.Collection, gen *generators.Sql) (string, error) {
+	var placement = collection.Placement
+	var options = make([]string, 0)
+
+	if placement == nil {
+		return ``, nil
+	} else if err := placement.Validate(collection); err != nil {
+		return ``, err
+	}
+
+	if placement.Policy != `` {
+		options = append(options, fmt.Sprintf("PLACEMENT POLICY=%s", gen.ToTableName(placement.Policy)))
+	}
+
+	if placement.Partitioning != `` {
+		var fields = make([]string, 0)
+
+		for _, field := range placement.GetPartitionFields(collection) {
+			fields = append(fields, gen.ToFieldName(field))
+		}
+
+		options = append(options, fmt.Sprintf(
+			"PARTITION BY %s(%s) PARTITIONS %d",
+			strings.ToUpper(string(placement.Partitioning)),
+			strings.Join(fields, `, `),
+			placement.Partitions,
+		))
+	}
+
+	return strings.Join(options, ` `), nil
+}
+
+func initializeTidb(self *SqlBackend) (string, string, error) {
+	if _, _, err := initializeMysql(self); err != nil {
+		return ``, ``, err
+	}
+
+	return `mysql`, mysqlDSN(self, 4000), nil
+}
@@ -3,16 +3,45 @@ package backends
 // this file satifies the Indexer interface for SqlBackend
 
 import (
+	"context"
+	"fmt"
 	"math"
-	"reflect"
 
 	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
 	"github.com/ghetzel/pivot/v3/dal"
 	"github.com/ghetzel/pivot/v3/filter"
-	"github.com/ghetzel/pivot/v3/filter/generators"
 )
 
 func (self *SqlBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	return self.queryFunc(context.Background(), collection, f, resultFn)
+}
+
+func (self *SqlBackend) queryFunc(ctx context.Context, collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	// filters with more values than the database accepts in one statement are queried in batches
+	if batches, err := self.splitFilter(f); err != nil {
+		return err
+	} else if len(batches) > 0 {
+		var before int64
+
+		for _, batch := range batches {
+			var results int64
+
+			if err := self.queryFunc(ctx, collection, batch, func(record *dal.Record, err error, page IndexPage) error {
+				results = page.TotalResults
+				page.TotalResults += before
+
+				return resultFn(record, err, page)
+			}); err != nil {
+				return err
+			}
+
+			before += results
+		}
+
+		return nil
+	}
+
 	defer stats.NewTiming().Send(`pivot.backends.sql.query_time`)
 
 	f.IdentityField = collection.IdentityField
@@ -26,6 +55,8 @@ func (self *SqlBackend) QueryFunc(collection *dal.Collection, f *filter.Filter,
 
 	for {
 		queryGen := self.makeQueryGen(collection)
+		queryGen.ScoreField = sqlScoreColumn
+		queryGen.AsOfSystemTime = self.asOfSystemTime
 
 		if err := f.ApplyOptions(&queryGen); err != nil {
 			return nil
@@ -37,11 +68,21 @@ func (self *SqlBackend) QueryFunc(collection *dal.Collection, f *filter.Filter,
 			var totalPages int
 			var totalResults int64
 
-			// if we are paginating, then we need to do a preliminary query to get the
-			// total number of records that match this query
-			if f.Paginate && !f.IdOnly() {
+			if f.Totals == filter.WindowTotals && !f.IdOnly() && !queryGen.Distinct {
+				// have each row carry the total number of records that match this query
+				queryGen.CountOverField = sqlTotalsColumn
+			} else if (f.Paginate || f.Totals != filter.DefaultTotals) && !f.IdOnly() {
+				// if we are paginating, then we need to do a preliminary query to get the
+				// total number of records that match this query
 				prequeryGen := self.makeQueryGen(collection)
 				prequeryGen.Count = true
+				prequeryGen.AsOfSystemTime = self.asOfSystemTime
+
+				// counts that were explicitly asked for stop at the exact count threshold,
+				// beyond which the total is reported as unknown
+				if f.Totals != filter.DefaultTotals {
+					prequeryGen.CountLimit = sqlMaxExactCountRows + 1
+				}
 
 				if err := prequeryGen.Initialize(collection.Name); err == nil {
 					// render the count query
@@ -50,7 +91,7 @@ func (self *SqlBackend) QueryFunc(collection *dal.Collection, f *filter.Filter,
 						querylog.Debugf("[%v] %s %v", self, string(stmt[:]), values)
 
 						// perform the count query
-						if rows, err := self.db.Query(string(stmt[:]), values...); err == nil {
+						if rows, err := self.readQuery(ctx, string(stmt[:]), values...); err == nil {
 							defer rows.Close()
 
 							if rows.Next() {
@@ -74,8 +115,12 @@ func (self *SqlBackend) QueryFunc(collection *dal.Collection, f *filter.Filter,
 					return err
 				}
 
-				// totalPages = ceil(result count / page size)
-				totalPages = int(math.Ceil(float64(totalResults) / float64(f.Limit)))
+				if f.Totals != filter.DefaultTotals && totalResults > int64(sqlMaxExactCountRows) {
+					totalResults = -1
+				} else if f.Limit > 0 {
+					// totalPages = ceil(result count / page size)
+					totalPages = int(math.Ceil(float64(totalResults) / float64(f.Limit)))
+				}
 			}
 
 			if stmt, err := filter.Render(queryGen, collection.Name, f); err == nil {
@@ -83,16 +128,68 @@ func (self *SqlBackend) QueryFunc(collection *dal.Collection, f *filter.Filter,
 				querylog.Debugf("[%v] %s %v", self, string(stmt[:]), values)
 
 				// perform query
-				if rows, err := self.db.Query(string(stmt[:]), values...); err == nil {
+				if rows, err := self.readQuery(ctx, string(stmt[:]), values...); err == nil {
 					defer rows.Close()
 
 					if columns, err := rows.Columns(); err == nil {
 						processedThisQuery := 0
+						var scanFn sqlScanFunc = rows.Scan
+						totalsColumn := -1
+						scoreColumn := -1
+
+						var score float64
+
+						for i, column := range columns {
+							if queryGen.CountOverField != `` && column == queryGen.CountOverField {
+								totalsColumn = i
+							} else if column == queryGen.ScoreField {
+								scoreColumn = i
+							}
+						}
+
+						// read the total and the relevance out of each row as it is scanned
+						if totalsColumn >= 0 || scoreColumn >= 0 {
+							scanFn = func(dest ...interface{}) error {
+								if err := rows.Scan(dest...); err == nil {
+									if totalsColumn >= 0 {
+										if v, ok := dest[totalsColumn].(*interface{}); ok {
+											if b, ok := (*v).([]byte); ok {
+												*v = string(b)
+											}
+
+											totalResults = typeutil.Int(*v)
+
+											if f.Limit > 0 {
+												totalPages = int(math.Ceil(float64(totalResults) / float64(f.Limit)))
+											}
+										}
+									}
+
+									if scoreColumn >= 0 {
+										if v, ok := dest[scoreColumn].(*interface{}); ok {
+											if b, ok := (*v).([]byte); ok {
+												*v = string(b)
+											}
+
+											score = typeutil.Float(*v)
+										}
+									}
+
+									return nil
+								} else {
+									return err
+								}
+							}
+						}
+
+						plan := self.newScanPlan(queryGen, collection, columns, f.Fields)
+						defer plan.Release()
 
 						for rows.Next() {
 							// log.Debugf("  row: %d", processed)
 
-							if record, err := self.scanFnValueToRecord(queryGen, collection, columns, reflect.ValueOf(rows.Scan), f.Fields); err == nil {
+							if record, err := self.scanFnValueToRecord(plan, scanFn); err == nil {
+								record.Score = score
 								processed += 1
 								processedThisQuery += 1
 
@@ -151,7 +248,84 @@ func (self *SqlBackend) QueryFunc(collection *dal.Collection, f *filter.Filter,
 	}
 }
 
+// splits a filter with more values than the database accepts in a single statement into several
+// filters, each matching a batch of the values of its longest criterion.  Returns nothing if the
+// filter can be used as-is.
+func (self *SqlBackend) splitFilter(f *filter.Filter) ([]*filter.Filter, error) {
+	var max = self.queryGenTypeMapping.MaxPlaceholders
+	var total int
+	var longest int
+
+	if max <= 0 || f == nil {
+		return nil, nil
+	}
+
+	for i, criterion := range f.Criteria {
+		total += len(criterion.Values)
+
+		if len(criterion.Values) > len(f.Criteria[longest].Values) {
+			longest = i
+		}
+	}
+
+	if total <= max {
+		return nil, nil
+	}
+
+	var criterion = f.Criteria[longest]
+	var room = max - (total - len(criterion.Values))
+
+	// the results of each batch are concatenated, which is only the same as running the query all
+	// at once if no record can match more than one batch, and the results aren't sorted or paged
+	if room < 1 || !criterion.IsExactMatch() || f.Conjunction == filter.OrConjunction || len(f.Groups) > 0 || len(f.Sort) > 0 || f.Limit > 0 || f.Offset > 0 {
+		return nil, fmt.Errorf("query has %d values, but %v accepts at most %d in a single statement", total, self, max)
+	}
+
+	var batches []*filter.Filter
+	var values = criterion.Values
+
+	for len(values) > 0 {
+		var n = room
+
+		if len(values) < n {
+			n = len(values)
+		}
+
+		var batch = filter.Copy(f)
+
+		batch.Criteria = append([]filter.Criterion{}, f.Criteria...)
+		batch.Criteria[longest].Values = values[:n]
+		batches = append(batches, &batch)
+		values = values[n:]
+	}
+
+	return batches, nil
+}
+
 func (self *SqlBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	return self.query(context.Background(), collection, f, resultFns...)
+}
+
+// Returns whether the given filter can be expressed in this backend's dialect of SQL.
+func (self *SqlBackend) CanQuery(collection *dal.Collection, f *filter.Filter) bool {
+	var flt = filter.Copy(f)
+
+	_, err := filter.Render(self.makeQueryGen(collection), collection.Name, &flt)
+	return err == nil
+}
+
+// Query the collection, cancelling the query once the context is done.  If the backend has been
+// given a separate indexer, the query is run there instead, and is abandoned (rather than
+// cancelled) once the context is done.
+func (self *SqlBackend) QueryContext(ctx context.Context, collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if search := self.WithSearch(collection, f); search != nil && search != Indexer(self) {
+		return queryContext(ctx, search, collection, f, resultFns...)
+	}
+
+	return self.query(ctx, collection, f, resultFns...)
+}
+
+func (self *SqlBackend) query(ctx context.Context, collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
 	if f != nil {
 		if f.IdentityField == `` {
 			f.IdentityField = MongoIdentityField
@@ -161,7 +335,20 @@ func (self *SqlBackend) Query(collection *dal.Collection, f *filter.Filter, resu
 		f.Options[`ForceIndexRecord`] = true
 	}
 
-	return DefaultQueryImplementation(self, collection, f, resultFns...)
+	return DefaultQueryImplementation(&sqlContextIndexer{
+		SqlBackend: self,
+		ctx:        ctx,
+	}, collection, f, resultFns...)
+}
+
+// a view of a SqlBackend whose queries are bound to a context
+type sqlContextIndexer struct {
+	*SqlBackend
+	ctx context.Context
+}
+
+func (self *sqlContextIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	return self.SqlBackend.queryFunc(self.ctx, collection, f, resultFn)
 }
 
 func (self *SqlBackend) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
@@ -238,32 +425,12 @@ func (self *SqlBackend) IndexRemove(collection *dal.Collection, ids []interface{
 
 // DeleteQuery removes records using a filter
 func (self *SqlBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
-	if tx, err := self.db.Begin(); err == nil {
-		queryGen := self.makeQueryGen(collection)
-		queryGen.Type = generators.SqlDeleteStatement
-
-		// generate SQL
-		if stmt, err := filter.Render(queryGen, collection.Name, f); err == nil {
-			querylog.Debugf("[%v] %s %v", self, string(stmt[:]), queryGen.GetValues())
+	return self.DeleteWhere(collection, f)
+}
 
-			// execute SQL
-			if _, err := tx.Exec(string(stmt[:]), queryGen.GetValues()...); err == nil {
-				if err := tx.Commit(); err == nil {
-					return nil
-				} else {
-					return err
-				}
-			} else {
-				defer tx.Rollback()
-				return err
-			}
-		} else {
-			defer tx.Rollback()
-			return err
-		}
-	} else {
-		return err
-	}
+// UpdateQuery sets the given record's fields on all records matching a filter
+func (self *SqlBackend) UpdateQuery(collection *dal.Collection, f *filter.Filter, record *dal.Record) (int64, error) {
+	return self.UpdateWhere(collection, f, record)
 }
 
 func (self *SqlBackend) FlushIndex() error {
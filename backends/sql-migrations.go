@@ -0,0 +1,135 @@
+package backends
+
+import (
+	"fmt"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// field properties that only affect how Pivot treats values, and have no counterpart in the
+// database's schema
+var sqlMigrationIgnoredParameters = []string{
+	`BelongsTo`,
+	`Index`,
+	`Indexed`,
+	`KeyType`,
+	`NotUserEditable`,
+	`Schema`,
+	`UniqueGroup`,
+	`ValidateOnPopulate`,
+}
+
+// Compares the collection definition with the table as it currently exists in the database, and
+// generates the DDL statements needed to bring the table in line with the definition.  Changes the
+// database can't make (e.g.: changing column types in SQLite) are included in the plan with an
+// error describing why.
+func (self *SqlBackend) PlanMigration(definition *dal.Collection) (*MigrationPlan, error) {
+	var plan = &MigrationPlan{
+		Collection: definition.Name,
+		Steps:      make([]MigrationStep, 0),
+	}
+
+	// read the table's schema directly, since cached collections have their definitions applied
+	actual, err := self.refreshCollectionFunc(self.conn.Dataset(), definition.Name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, delta := range definition.Diff(actual) {
+		if !sqlMigrationAffectsSchema(delta) {
+			continue
+		}
+
+		var step = MigrationStep{
+			Delta: delta,
+		}
+
+		if stmt, values, err := self.generateAlterStatement(actual, delta); err == nil {
+			step.Statement = stmt
+			step.Values = values
+		} else {
+			step.Error = err.Error()
+		}
+
+		plan.Steps = append(plan.Steps, step)
+	}
+
+	return plan, nil
+}
+
+// Executes all of the statements in the plan in a single transaction.  Note that some databases
+// (e.g.: MySQL) implicitly commit DDL statements, in which case a failure part way through leaves
+// the steps before it applied.
+func (self *SqlBackend) ApplyMigration(plan *MigrationPlan) error {
+	if unsupported := plan.Unsupported(); len(unsupported) > 0 {
+		return fmt.Errorf("cannot migrate collection %q: %d change(s) are not supported: %v", plan.Collection, len(unsupported), unsupported[0].Error)
+	} else if plan.IsEmpty() {
+		return nil
+	}
+
+	if tx, err := self.db.Begin(); err == nil {
+		for _, step := range plan.Steps {
+			querylog.Debugf("[%v] %s", self, step.Statement)
+
+			if _, err := tx.Exec(step.Statement, step.Values...); err != nil {
+				defer tx.Rollback()
+				return fmt.Errorf("%v: %v", step.Delta, err)
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	// pick up the new schema
+	if definition, err := self.getCollectionFromCache(plan.Collection); err == nil {
+		return self.refreshCollectionFromDatabase(plan.Collection, definition)
+	} else {
+		return self.refreshCollectionFromDatabase(plan.Collection, nil)
+	}
+}
+
+// Executes the given statements as-is in a single transaction (with the same caveat about DDL as
+// ApplyMigration), then reloads the schema of every table, since the statements may have changed
+// any of them.
+func (self *SqlBackend) ExecStatements(statements ...string) error {
+	if tx, err := self.db.Begin(); err == nil {
+		for _, stmt := range statements {
+			querylog.Debugf("[%v] %s", self, stmt)
+
+			if _, err := tx.Exec(stmt); err != nil {
+				defer tx.Rollback()
+				return fmt.Errorf("%s: %v", stmt, err)
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	// statements prepared against the old schema may no longer be valid
+	self.prepared.Range(func(key interface{}, value interface{}) bool {
+		self.prepared.Delete(key)
+		value.(*sqlPreparedStatement).stmt.Close()
+		return true
+	})
+
+	return self.refreshAllCollections()
+}
+
+// returns whether resolving the given difference requires changing the database's schema
+func sqlMigrationAffectsSchema(delta *dal.SchemaDelta) bool {
+	if delta.Issue == dal.FieldPropertyIssue && sliceutil.ContainsString(sqlMigrationIgnoredParameters, delta.Parameter) {
+		return false
+	}
+
+	return true
+}
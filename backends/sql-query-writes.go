@@ -0,0 +1,306 @@
+package backends
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/ghetzel/pivot/v3/filter/generators"
+)
+
+// How many records DeleteWhere and UpdateWhere find and write at a time when the backend has been
+// given a separate indexer, which needs to be told which records were written.
+var SqlQueryWriteBatchSize = 500
+
+// Deletes all records matching a filter with a single DELETE statement.  If the backend has been
+// given a separate indexer, the records are instead deleted in batches, each of which is removed
+// from the indexer once it has been committed.
+func (self *SqlBackend) DeleteWhere(collection *dal.Collection, f *filter.Filter) error {
+	var ctx = context.Background()
+
+	if search := self.separateIndexer(); search != nil {
+		return self.eachMatchingBatch(ctx, collection, f, func(tx sqlTx, ids []interface{}) (func() error, error) {
+			queryGen := self.makeQueryGen(collection)
+			queryGen.Type = generators.SqlDeleteStatement
+
+			if _, err := self.execByIDs(ctx, tx, queryGen, collection, ids); err == nil {
+				return func() error {
+					return search.IndexRemove(collection, ids)
+				}, nil
+			} else {
+				return nil, err
+			}
+		})
+	}
+
+	queryGen := self.makeQueryGen(collection)
+	queryGen.Type = generators.SqlDeleteStatement
+
+	_, err := self.execFilterStatement(ctx, queryGen, collection, f)
+	return err
+}
+
+// Sets the given record's fields on all records matching a filter with a single UPDATE statement,
+// returning the number of records updated.  If the backend has been given a separate indexer, the
+// records are instead updated in batches, each of which is reindexed once it has been committed.
+func (self *SqlBackend) UpdateWhere(collection *dal.Collection, f *filter.Filter, record *dal.Record) (int64, error) {
+	var ctx = context.Background()
+	var input = make(map[string]interface{})
+	var updated int64
+
+	if r, err := collection.StructToRecord(record); err == nil {
+		record = r
+	} else {
+		return 0, err
+	}
+
+	for k, v := range record.Fields {
+		if k != collection.IdentityField {
+			if field, ok := collection.GetField(k); ok {
+				if converted, err := field.ConvertValue(v); err == nil {
+					input[k] = converted
+				} else {
+					return 0, fmt.Errorf("field %v: %v", field.Name, err)
+				}
+			}
+		}
+	}
+
+	if search := self.separateIndexer(); search != nil {
+		err := self.eachMatchingBatch(ctx, collection, f, func(tx sqlTx, ids []interface{}) (func() error, error) {
+			queryGen := self.makeQueryGen(collection)
+			queryGen.Type = generators.SqlUpdateStatement
+			queryGen.InputData = input
+
+			if affected, err := self.execByIDs(ctx, tx, queryGen, collection, ids); err == nil {
+				// read the records back as they are in this transaction
+				if recordset, err := self.queryTx(ctx, tx, collection, self.idsFilter(collection, ids), false); err == nil {
+					return func() error {
+						updated += affected
+						return search.Index(collection, recordset)
+					}, nil
+				} else {
+					return nil, err
+				}
+			} else {
+				return nil, err
+			}
+		})
+
+		return updated, err
+	}
+
+	queryGen := self.makeQueryGen(collection)
+	queryGen.Type = generators.SqlUpdateStatement
+	queryGen.InputData = input
+
+	return self.execFilterStatement(ctx, queryGen, collection, f)
+}
+
+// returns the indexer that writes must be mirrored to, or nil if the database is its own index
+func (self *SqlBackend) separateIndexer() Indexer {
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		return self.indexer
+	}
+
+	return nil
+}
+
+// Finds the records matching the given filter a batch at a time (in order of their IDs), calling fn
+// with the IDs of each batch from within the same transaction that found them.  Where the database
+// supports it, the records stay locked until the transaction ends, so they can't stop matching the
+// filter before fn writes them.  The function returned by fn is called once the transaction commits.
+func (self *SqlBackend) eachMatchingBatch(ctx context.Context, collection *dal.Collection, f *filter.Filter, fn func(tx sqlTx, ids []interface{}) (func() error, error)) error {
+	var after interface{}
+
+	for {
+		var ids []interface{}
+		var committed func() error
+
+		if err := self.retry(ctx, func() error {
+			ids = nil
+			committed = nil
+
+			if tx, err := self.begin(ctx, nil); err == nil {
+				if matched, err := self.matchingIDs(ctx, tx, collection, f, after); err != nil || len(matched) == 0 {
+					tx.Rollback()
+					return err
+				} else {
+					ids = matched
+				}
+
+				if c, err := fn(tx, ids); err == nil {
+					committed = c
+					return tx.Commit()
+				} else {
+					tx.Rollback()
+					return err
+				}
+			} else {
+				return err
+			}
+		}); err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if committed != nil {
+			if err := committed(); err != nil {
+				return err
+			}
+		}
+
+		if len(ids) < SqlQueryWriteBatchSize {
+			return nil
+		}
+
+		after = ids[len(ids)-1]
+	}
+}
+
+// returns the IDs of the next batch of records matching the given filter whose IDs come after the
+// given one (or the first batch, if it is nil), locking them where the database supports it
+func (self *SqlBackend) matchingIDs(ctx context.Context, tx sqlTx, collection *dal.Collection, f *filter.Filter, after interface{}) ([]interface{}, error) {
+	var flt = filter.Copy(f)
+
+	flt.Fields = []string{collection.IdentityField}
+	flt.Sort = []string{collection.IdentityField}
+	flt.Limit = SqlQueryWriteBatchSize
+	flt.Offset = 0
+	flt.Paginate = false
+
+	if after != nil {
+		flt.Conjunction = filter.AndConjunction
+		flt.Criteria = []filter.Criterion{{
+			Field:    collection.IdentityField,
+			Operator: `gt`,
+			Values:   []interface{}{after},
+		}}
+
+		flt.Groups = []filter.Group{{
+			Conjunction: f.Conjunction,
+			Criteria:    f.Criteria,
+			Groups:      f.Groups,
+		}}
+	}
+
+	if recordset, err := self.queryTx(ctx, tx, collection, &flt, true); err == nil {
+		return recordIds(recordset), nil
+	} else {
+		return nil, err
+	}
+}
+
+// returns a filter matching the records with the given IDs
+func (self *SqlBackend) idsFilter(collection *dal.Collection, ids []interface{}) *filter.Filter {
+	var f = filter.New()
+
+	f.IdentityField = collection.IdentityField
+	f.AddCriteria(filter.Criterion{
+		Field:  collection.IdentityField,
+		Values: ids,
+	})
+
+	return f
+}
+
+// reads the records matching the given filter from within a transaction, optionally locking them
+// until it ends
+func (self *SqlBackend) queryTx(ctx context.Context, tx sqlTx, collection *dal.Collection, f *filter.Filter, lock bool) (*dal.RecordSet, error) {
+	var recordset = dal.NewRecordSet()
+
+	queryGen := self.makeQueryGen(collection)
+	queryGen.LockRows = lock
+
+	if err := queryGen.Initialize(collection.Name); err != nil {
+		return nil, err
+	}
+
+	stmt, err := filter.Render(queryGen, collection.Name, f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	querylog.Debugf("[%v] %s %v", self, string(stmt[:]), queryGen.GetValues())
+
+	if rows, err := tx.QueryContext(ctx, string(stmt[:]), queryGen.GetValues()...); err == nil {
+		defer rows.Close()
+
+		if columns, err := rows.Columns(); err == nil {
+			plan := self.newScanPlan(queryGen, collection, columns, f.Fields)
+			defer plan.Release()
+
+			for rows.Next() {
+				if record, err := self.scanFnValueToRecord(plan, rows.Scan); err == nil {
+					recordset.Push(record)
+				} else {
+					return nil, err
+				}
+			}
+
+			return recordset, rows.Err()
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// renders a statement targeting the records with the given IDs and executes it in the given
+// transaction, returning the number of rows affected
+func (self *SqlBackend) execByIDs(ctx context.Context, tx sqlTx, queryGen *generators.Sql, collection *dal.Collection, ids []interface{}) (int64, error) {
+	stmt, err := filter.Render(queryGen, collection.Name, self.idsFilter(collection, ids))
+
+	if err != nil {
+		return 0, err
+	}
+
+	querylog.Debugf("[%v] %s %v", self, string(stmt[:]), queryGen.GetValues())
+
+	if result, err := tx.ExecContext(ctx, string(stmt[:]), queryGen.GetValues()...); err == nil {
+		affected, _ := result.RowsAffected()
+		return affected, nil
+	} else {
+		return 0, err
+	}
+}
+
+// renders a statement from the given filter and executes it in its own transaction, returning the
+// number of rows affected
+func (self *SqlBackend) execFilterStatement(ctx context.Context, queryGen *generators.Sql, collection *dal.Collection, f *filter.Filter) (int64, error) {
+	var affected int64
+
+	stmt, err := filter.Render(queryGen, collection.Name, f)
+
+	if err != nil {
+		return 0, err
+	}
+
+	querylog.Debugf("[%v] %s %v", self, string(stmt[:]), queryGen.GetValues())
+
+	err = self.retry(ctx, func() error {
+		if tx, err := self.begin(ctx, nil); err == nil {
+			if result, err := tx.ExecContext(ctx, string(stmt[:]), queryGen.GetValues()...); err == nil {
+				if err := tx.Commit(); err == nil {
+					affected, _ = result.RowsAffected()
+					return nil
+				} else {
+					return err
+				}
+			} else {
+				tx.Rollback()
+				return err
+			}
+		} else {
+			return err
+		}
+	})
+
+	return affected, err
+}
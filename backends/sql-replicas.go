@@ -0,0 +1,245 @@
+package backends
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// How long a read replica that failed is skipped before reads are routed to it again.
+var SqlReplicaRetryInterval = 30 * time.Second
+
+// How much weight the latest observation is given when averaging a replica's latency.
+var SqlReplicaLatencyWeight = 0.2
+
+// Specifies how reads are spread across a SQL backend's read replicas.
+type SqlReplicaPolicy string
+
+const (
+	RoundRobinReplicas SqlReplicaPolicy = `roundrobin` // take turns reading from each replica
+	LatencyReplicas                     = `latency`    // read from the replica that has been responding the fastest
+)
+
+// A read-only copy of the primary database that reads can be routed to.
+type sqlReplica struct {
+	conn     dal.ConnectionString
+	db       *sql.DB
+	latency  time.Duration
+	failedAt time.Time
+	lock     sync.Mutex
+}
+
+func (self *sqlReplica) String() string {
+	return self.conn.Host()
+}
+
+// whether the replica can be read from (i.e.: it hasn't failed recently)
+func (self *sqlReplica) available() bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	return self.failedAt.IsZero() || time.Since(self.failedAt) >= SqlReplicaRetryInterval
+}
+
+func (self *sqlReplica) averageLatency() time.Duration {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	return self.latency
+}
+
+func (self *sqlReplica) succeeded(took time.Duration) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.failedAt = time.Time{}
+
+	if self.latency == 0 {
+		self.latency = took
+	} else {
+		self.latency = time.Duration(SqlReplicaLatencyWeight*float64(took) + (1-SqlReplicaLatencyWeight)*float64(self.latency))
+	}
+}
+
+func (self *sqlReplica) failed() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.failedAt = time.Now()
+}
+
+// Returns the connection strings of the read replicas given in the "replica" option of the
+// primary's connection string.  The option can be given several times, or as a comma-separated
+// list.  Replicas given as just a host (e.g.: "replica=db2:5432") use the primary's scheme and
+// dataset, and replicas that don't specify credentials use the primary's.
+func SqlReplicaConnectionStrings(primary *dal.ConnectionString) ([]dal.ConnectionString, error) {
+	var replicas = make([]dal.ConnectionString, 0)
+
+	if primary == nil || primary.URI == nil {
+		return replicas, nil
+	}
+
+	for _, values := range primary.URI.Query()[`replica`] {
+		for _, value := range strings.Split(values, `,`) {
+			if value = strings.TrimSpace(value); value == `` {
+				continue
+			}
+
+			if !strings.Contains(value, `://`) {
+				value = primary.URI.Scheme + `://` + value + `/` + primary.Dataset()
+			}
+
+			if cs, err := dal.ParseConnectionString(value); err == nil {
+				if cs.Backend() != primary.Backend() {
+					return nil, fmt.Errorf("replica %v: expected a %v connection string", cs.Host(), primary.Backend())
+				}
+
+				if _, _, ok := cs.Credentials(); !ok {
+					if u, p, ok := primary.Credentials(); ok {
+						cs.SetCredentials(u, p)
+					}
+				}
+
+				replicas = append(replicas, cs)
+			} else {
+				return nil, fmt.Errorf("replica: %v", err)
+			}
+		}
+	}
+
+	return replicas, nil
+}
+
+// connect to each of the replicas given in the connection string.  Replicas that can't be reached
+// are skipped until SqlReplicaRetryInterval has passed, like any other failed replica.
+func (self *SqlBackend) openReplicas() error {
+	replicas, err := SqlReplicaConnectionStrings(self.conn)
+
+	if err != nil {
+		return err
+	}
+
+	switch policy := SqlReplicaPolicy(self.conn.OptString(`replica_policy`, string(RoundRobinReplicas))); policy {
+	case RoundRobinReplicas, LatencyReplicas:
+		self.replicaPolicy = policy
+	default:
+		return fmt.Errorf("unsupported replica policy %q", policy)
+	}
+
+	for _, cs := range replicas {
+		var replica = &sqlReplica{
+			conn: cs,
+		}
+
+		// use the same driver-specific setup as the primary to arrive at the replica's DSN
+		var parent = NewSqlBackend(cs).(*SqlBackend)
+
+		if name, dsn, err := sqlInitFuncs[cs.Backend()](parent); err == nil {
+			if name == `` {
+				name = cs.Backend()
+			}
+
+			if db, err := sql.Open(name, dsn); err == nil {
+				replica.db = db
+			} else {
+				return fmt.Errorf("replica %v: %v", replica, err)
+			}
+		} else {
+			return fmt.Errorf("replica %v: %v", replica, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), InitialPingTimeout)
+
+		if err := replica.db.PingContext(ctx); err != nil {
+			log.Warningf("[%v] replica %v unavailable: %v", self, replica, err)
+			replica.failed()
+		}
+
+		cancel()
+
+		self.replicas = append(self.replicas, replica)
+	}
+
+	if len(self.replicas) > 0 {
+		log.Debugf("[%v] routing reads to %d replica(s) (%v)", self, len(self.replicas), self.replicaPolicy)
+	}
+
+	return nil
+}
+
+// returns the replica the next read should be routed to, or nil if it should go to the primary
+func (self *SqlBackend) pickReplica() *sqlReplica {
+	var available = make([]*sqlReplica, 0, len(self.replicas))
+
+	for _, replica := range self.replicas {
+		if replica.available() {
+			available = append(available, replica)
+		}
+	}
+
+	if len(available) == 0 {
+		return nil
+	}
+
+	switch self.replicaPolicy {
+	case LatencyReplicas:
+		var fastest *sqlReplica
+
+		for _, replica := range available {
+			// replicas that haven't been measured yet are tried first
+			if replica.averageLatency() == 0 {
+				return replica
+			} else if fastest == nil || replica.averageLatency() < fastest.averageLatency() {
+				fastest = replica
+			}
+		}
+
+		return fastest
+	default:
+		return available[int(atomic.AddUint32(&self.replicaCounter, 1)-1)%len(available)]
+	}
+}
+
+// runs a read-only query against one of the read replicas, or against the primary if there are
+// none available.  If the replica fails, the query is retried against the primary.
+func (self *SqlBackend) readQuery(ctx context.Context, stmt string, values ...interface{}) (*sql.Rows, error) {
+	if replica := self.pickReplica(); replica != nil {
+		var started = time.Now()
+
+		if rows, err := replica.db.QueryContext(ctx, stmt, values...); err == nil {
+			replica.succeeded(time.Since(started))
+			return rows, nil
+		} else if ctx.Err() != nil {
+			return nil, err
+		} else if rows, perr := self.db.QueryContext(ctx, stmt, values...); perr == nil {
+			// the primary could answer, so the problem lies with the replica
+			log.Warningf("[%v] replica %v failed, reading from primary: %v", self, replica, err)
+			replica.failed()
+
+			return rows, nil
+		} else {
+			return nil, perr
+		}
+	}
+
+	// statements prepared during warm-up are only valid on the primary
+	if prepared := self.preparedStatement(stmt); prepared != nil {
+		if rows, err := prepared.QueryContext(ctx, values...); err == nil || ctx.Err() != nil {
+			return rows, err
+		} else {
+			// the statement may have been invalidated by a schema change, so stop using it
+			log.Warningf("[%v] prepared statement failed, retrying unprepared: %v", self, err)
+			self.prepared.Delete(stmt)
+			prepared.Close()
+		}
+	}
+
+	return self.db.QueryContext(ctx, stmt, values...)
+}
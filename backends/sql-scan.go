@@ -0,0 +1,285 @@
+package backends
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter/generators"
+)
+
+// String values no longer than this are interned while reading rows, so that repeated values share
+// a single allocation.
+var SqlInternMaxLength = 64
+
+// The most distinct string values interned while reading the rows of a single query.
+var SqlInternMaxValues = 4096
+
+// Scans the current row into dest (e.g.: sql.Rows.Scan).
+type sqlScanFunc func(dest ...interface{}) error
+
+// The buffers a row is scanned into.  These are pooled so that reading many rows (or many
+// single-row queries) doesn't allocate a new set for each one.
+type sqlScanBuffer struct {
+	values []interface{}
+	dest   []interface{}
+}
+
+var sqlScanBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(sqlScanBuffer)
+	},
+}
+
+// Everything about converting rows to records that depends only on the columns being read, worked
+// out once per query instead of once per row.
+type sqlScanPlan struct {
+	queryGen   *generators.Sql
+	collection *dal.Collection
+	columns    []string
+	paths      [][]string
+	fields     []*dal.Field
+	skip       []bool
+	hints      []interface{}
+	interned   map[string]string
+	buffer     *sqlScanBuffer
+}
+
+func (self *SqlBackend) newScanPlan(queryGen *generators.Sql, collection *dal.Collection, columns []string, wantedFields []string) *sqlScanPlan {
+	plan := &sqlScanPlan{
+		queryGen:   queryGen,
+		collection: collection,
+		columns:    columns,
+		paths:      make([][]string, len(columns)),
+		fields:     make([]*dal.Field, len(columns)),
+		skip:       make([]bool, len(columns)),
+		hints:      make([]interface{}, len(columns)),
+		interned:   make(map[string]string),
+		buffer:     sqlScanBufferPool.Get().(*sqlScanBuffer),
+	}
+
+	if cap(plan.buffer.values) < len(columns) {
+		plan.buffer.values = make([]interface{}, len(columns))
+		plan.buffer.dest = make([]interface{}, len(columns))
+	} else {
+		plan.buffer.values = plan.buffer.values[:len(columns)]
+		plan.buffer.dest = plan.buffer.dest[:len(columns)]
+	}
+
+	// each argument in the call to scan will be the address of the corresponding value
+	for i := range plan.buffer.values {
+		plan.buffer.dest[i] = &plan.buffer.values[i]
+	}
+
+	for i, column := range columns {
+		plan.paths[i] = strings.Split(column, queryGen.TypeMapping.NestedFieldSeparator)
+		baseColumn := plan.paths[i][0]
+
+		field, ok := collection.GetField(baseColumn)
+
+		if !ok {
+			continue
+		}
+
+		plan.fields[i] = &field
+
+		// put a zero-value instance of each column's type in the result array, which will
+		// serve as a hint to the sql.Scan function as to how to convert the data
+		if field.DefaultValue != nil && !dal.IsDefaultExpression(field.DefaultValue) {
+			plan.hints[i] = field.GetDefaultValue()
+		} else if field.Required {
+			plan.hints[i] = field.GetTypeInstance()
+		} else {
+			switch field.Type {
+			case dal.StringType, dal.TimeType, dal.ObjectType, dal.GeopointType:
+				plan.hints[i] = sql.NullString{}
+
+			case dal.BooleanType:
+				plan.hints[i] = sql.NullBool{}
+
+			case dal.IntType:
+				plan.hints[i] = sql.NullInt64{}
+
+			case dal.FloatType:
+				plan.hints[i] = sql.NullFloat64{}
+
+			default:
+				plan.hints[i] = make([]byte, 0)
+			}
+		}
+
+		if len(wantedFields) > 0 && column != collection.IdentityField {
+			plan.skip[i] = true
+
+			for _, wantedField := range wantedFields {
+				parts := strings.Split(wantedField, queryGen.TypeMapping.NestedFieldSeparator)
+
+				if parts[0] == baseColumn {
+					plan.skip[i] = false
+					break
+				}
+			}
+		}
+	}
+
+	return plan
+}
+
+// Return the plan's buffers to the pool.  The plan cannot be used afterwards.
+func (self *sqlScanPlan) Release() {
+	if buffer := self.buffer; buffer != nil {
+		self.buffer = nil
+
+		// don't hold on to the last row's values while pooled
+		for i := range buffer.values {
+			buffer.values[i] = nil
+		}
+
+		sqlScanBufferPool.Put(buffer)
+	}
+}
+
+// returns the given string with all non-graphic characters removed, sharing storage with
+// previously-seen values where possible
+func (self *sqlScanPlan) intern(value []byte) string {
+	if len(value) > SqlInternMaxLength {
+		return strings.Map(graphicRune, string(value))
+	} else if s, ok := self.interned[string(value)]; ok {
+		return s
+	}
+
+	s := strings.Map(graphicRune, string(value))
+
+	if len(self.interned) < SqlInternMaxValues {
+		self.interned[string(value)] = s
+	}
+
+	return s
+}
+
+func graphicRune(r rune) rune {
+	if unicode.IsGraphic(r) {
+		return r
+	}
+
+	return -1
+}
+
+func (self *SqlBackend) scanFnValueToRecord(plan *sqlScanPlan, scanFn sqlScanFunc) (*dal.Record, error) {
+	output := plan.buffer.values
+	copy(output, plan.hints)
+
+	// this is the actual error returned from calling Scan()
+	if err := scanFn(plan.buffer.dest...); err != nil {
+		return nil, err
+	}
+
+	var id interface{}
+	fields := make(map[string]interface{})
+	collection := plan.collection
+	queryGen := plan.queryGen
+
+	// for each column in the resultset
+	for i, column := range plan.columns {
+		field := plan.fields[i]
+
+		if field == nil || plan.skip[i] {
+			continue
+		}
+
+		var value interface{}
+
+		// convert value types as needed
+		switch v := output[i].(type) {
+		// raw byte arrays will either be strings, blobs, or binary-encoded objects
+		// we need to figure out which
+		case []uint8:
+			value = []byte(v)
+
+		case sql.NullString:
+			if v.Valid {
+				value = []byte(v.String)
+			}
+
+		case sql.NullBool:
+			if v.Valid {
+				value = v.Bool
+			}
+
+		case sql.NullInt64:
+			if v.Valid {
+				value = v.Int64
+			}
+
+		case sql.NullFloat64:
+			if v.Valid {
+				value = v.Float64
+			}
+
+		case string:
+			value = []byte(v)
+
+		default:
+			value = v
+		}
+
+		// strings and raw bytes need a little love to determine exactly what they actually are
+		if asBytes, ok := value.([]byte); ok {
+			var dest map[string]interface{}
+
+			switch field.Type {
+			case dal.ObjectType, dal.RawType:
+				var destA []interface{}
+
+				if err := queryGen.ObjectTypeDecode(asBytes, &dest); err == nil {
+					value = dest
+				} else if field.Type == dal.ObjectType && queryGen.ArrayTypeDecode(asBytes, &destA) == nil {
+					// native JSON columns can hold arrays too
+					value = destA
+				} else {
+					value = asBytes
+				}
+
+			case dal.ArrayType:
+				var destA []interface{}
+
+				if err := queryGen.ArrayTypeDecode(asBytes, &destA); err == nil {
+					value = destA
+				} else {
+					value = asBytes
+				}
+
+			default:
+				value = nil
+
+				if len(asBytes) > 0 {
+					if nS := plan.intern(asBytes); nS != `` {
+						value = nS
+					}
+				}
+			}
+		}
+
+		// set the appropriate field for the dal.Record
+		if v, err := field.ConvertValue(value); err == nil {
+			if column == collection.IdentityField {
+				id = v
+			} else if newFields, ok := maputil.DeepSet(fields, plan.paths[i], v).(map[string]interface{}); ok {
+				fields = newFields
+			}
+		}
+	}
+
+	record := dal.NewRecord(id).SetFields(fields)
+
+	// do this AFTER populating the record's fields from the database
+	if err := record.Populate(record, collection); err != nil {
+		return nil, fmt.Errorf("error populating record: %v", err)
+	}
+
+	return record, nil
+}
@@ -0,0 +1,175 @@
+package backends
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// How many times a transaction that failed because it conflicted with another is retried, for
+// databases that report which failures can be retried.
+var SqlTransactionRetries = 5
+
+// How long to wait before first retrying a failed transaction; this doubles with each attempt.
+var SqlTransactionRetryBackoff = 25 * time.Millisecond
+
+// the subset of *sql.Tx that writes use
+type sqlTx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	StmtContext(ctx context.Context, stmt *sql.Stmt) *sql.Stmt
+	Commit() error
+	Rollback() error
+}
+
+// statements executed as part of an enclosing transaction leave committing and rolling back to
+// whoever started it
+type sqlEnclosedTx struct {
+	*sql.Tx
+}
+
+func (self sqlEnclosedTx) Commit() error {
+	return nil
+}
+
+func (self sqlEnclosedTx) Rollback() error {
+	return nil
+}
+
+// start a new transaction, or join the given one if it is not nil
+func (self *SqlBackend) begin(ctx context.Context, outer *sqlTransaction) (sqlTx, error) {
+	if outer != nil {
+		return sqlEnclosedTx{outer.tx}, nil
+	}
+
+	return self.db.BeginTx(ctx, nil)
+}
+
+// runs fn (e.g.: updating the search index) now, or once the given transaction commits if it is
+// not nil, so that changes which are rolled back are never seen outside of the database
+func (self *SqlBackend) afterCommit(outer *sqlTransaction, fn func() error) error {
+	if outer != nil {
+		outer.committed = append(outer.committed, fn)
+		return nil
+	}
+
+	return fn()
+}
+
+// Runs fn with a backend whose Insert, Update, and Delete calls all execute in a single database
+// transaction.  Reads made through the transaction backend see only committed data.  For databases
+// that ask for conflicting transactions to be retried (e.g.: CockroachDB), fn may be called again
+// with a new transaction, and so should not have side effects outside of it.
+func (self *SqlBackend) Transaction(fn func(tx Backend) error) error {
+	if self.db == nil {
+		return fmt.Errorf("Backend not initialized")
+	}
+
+	return self.retry(context.Background(), func() error {
+		if tx, err := self.db.Begin(); err == nil {
+			defer func() {
+				if r := recover(); r != nil {
+					tx.Rollback()
+					panic(r)
+				}
+			}()
+
+			var txn = &sqlTransaction{
+				SqlBackend: self,
+				tx:         tx,
+			}
+
+			if err := fn(txn); err == nil {
+				if err := tx.Commit(); err != nil {
+					return err
+				}
+
+				// anything queued by an attempt that was rolled back is discarded along with it
+				var merr error
+
+				for _, committed := range txn.committed {
+					merr = log.AppendError(merr, committed())
+				}
+
+				return merr
+			} else {
+				tx.Rollback()
+				return err
+			}
+		} else {
+			return err
+		}
+	})
+}
+
+// runs fn, running it again for as long as it fails with an error the database says can be
+// retried (up to SqlTransactionRetries times), waiting a little longer before each attempt
+func (self *SqlBackend) retry(ctx context.Context, fn func() error) error {
+	var backoff = SqlTransactionRetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		if err := fn(); err == nil {
+			return nil
+		} else if self.isRetryableErrorFunc == nil || !self.isRetryableErrorFunc(err) || attempt > SqlTransactionRetries {
+			return err
+		} else {
+			querylog.Debugf("[%v] retrying transaction (attempt %d): %v", self, attempt+1, err)
+
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(backoff):
+				backoff *= 2
+			}
+		}
+	}
+}
+
+// A view of a SqlBackend that routes all writes through an open transaction.
+type sqlTransaction struct {
+	*SqlBackend
+	tx        *sql.Tx
+	committed []func() error
+}
+
+func (self *sqlTransaction) Insert(name string, recordset *dal.RecordSet) error {
+	return self.InsertContext(context.Background(), name, recordset)
+}
+
+func (self *sqlTransaction) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	return self.UpdateContext(context.Background(), name, recordset, target...)
+}
+
+func (self *sqlTransaction) Delete(name string, ids ...interface{}) error {
+	return self.DeleteContext(context.Background(), name, ids...)
+}
+
+func (self *sqlTransaction) InsertContext(ctx context.Context, name string, recordset *dal.RecordSet) error {
+	if err := self.formatRecordSet(name, recordset, true); err != nil {
+		return err
+	}
+
+	return self.SqlBackend.insert(ctx, self, name, recordset)
+}
+
+func (self *sqlTransaction) UpdateContext(ctx context.Context, name string, recordset *dal.RecordSet, target ...string) error {
+	if err := self.formatRecordSet(name, recordset, false); err != nil {
+		return err
+	}
+
+	return self.SqlBackend.update(ctx, self, name, recordset, target...)
+}
+
+func (self *sqlTransaction) DeleteContext(ctx context.Context, name string, ids ...interface{}) error {
+	return self.SqlBackend.delete(ctx, self, name, ids...)
+}
+
+// Transactions started from within a transaction join the one that is already open.
+func (self *sqlTransaction) Transaction(fn func(tx Backend) error) error {
+	return fn(self)
+}
@@ -0,0 +1,212 @@
+package backends
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/ghetzel/pivot/v3/filter/generators"
+)
+
+// a statement prepared during warm-up, and the collection it operates on
+type sqlPreparedStatement struct {
+	collection string
+	stmt       *sql.Stmt
+}
+
+// returns whether statements should be prepared ahead of time, as set by the "warmup" connection
+// string option (e.g.: "postgresql://localhost/db?warmup=true")
+func (self *SqlBackend) warmupEnabled() bool {
+	return self.db != nil && self.conn.OptBool(`warmup`, false)
+}
+
+// Renders and prepares the statements most commonly executed against the given collection
+// (retrieving a record by its key, checking whether one exists, and inserting one) so that the
+// first requests made after startup don't pay for doing so.
+func (self *SqlBackend) warmupCollection(name string) error {
+	if !self.warmupEnabled() {
+		return nil
+	}
+
+	if collection, err := self.getCollectionFromCache(name); err == nil {
+		if queries, err := self.warmupQueries(collection); err == nil {
+			for _, query := range queries {
+				if self.preparedStatement(query) != nil {
+					continue
+				}
+
+				if stmt, err := self.db.Prepare(query); err == nil {
+					if _, loaded := self.prepared.LoadOrStore(query, &sqlPreparedStatement{
+						collection: collection.Name,
+						stmt:       stmt,
+					}); loaded {
+						stmt.Close()
+					}
+
+					querylog.Debugf("[%v] prepared %s", self, query)
+				} else {
+					return err
+				}
+			}
+
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+// warm up every registered collection, logging (rather than failing on) any errors
+func (self *SqlBackend) warmupAll() {
+	if !self.warmupEnabled() {
+		return
+	}
+
+	var names = make([]string, 0)
+
+	self.registeredCollections.Range(func(_, value interface{}) bool {
+		names = append(names, value.(*dal.Collection).Name)
+		return true
+	})
+
+	for _, name := range names {
+		if err := self.warmupCollection(name); err != nil {
+			log.Warningf("[%v] failed to warm up collection %v: %v", self, name, err)
+		}
+	}
+}
+
+// render the statements that warmupCollection prepares, using placeholder values that produce
+// the same SQL as real ones will
+func (self *SqlBackend) warmupQueries(collection *dal.Collection) ([]string, error) {
+	var queries = make([]string, 0)
+	var ids = make([]interface{}, 0)
+	var identity = collection.GetIdentityFieldName()
+
+	for _, keyField := range collection.KeyFields() {
+		ids = append(ids, keyField.GetTypeInstance())
+	}
+
+	// retrieve (all fields) and exists (identity only)
+	for _, fields := range [][]string{nil, {identity}} {
+		if f, err := self.keyQuery(collection, ids); err == nil {
+			f.Fields = fields
+			queryGen := self.makeQueryGen(collection)
+
+			if err := queryGen.Initialize(collection.Name); err != nil {
+				return nil, err
+			}
+
+			if stmt, err := filter.Render(queryGen, collection.Name, f); err == nil {
+				queries = append(queries, string(stmt[:]))
+			} else {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	// insert a single record setting every field, both with and without an explicit identity
+	var row = make(map[string]interface{})
+
+	for _, field := range collection.Fields {
+		if !field.ReadOnly {
+			row[field.Name] = field.GetTypeInstance()
+		}
+	}
+
+	if len(row) > 0 {
+		var returning string
+
+		if self.insertReturningIdentity {
+			returning = identity
+		}
+
+		var withIdentity = make(map[string]interface{})
+
+		for k, v := range row {
+			withIdentity[k] = v
+		}
+
+		if field, ok := collection.GetField(identity); ok {
+			withIdentity[identity] = field.GetTypeInstance()
+		}
+
+		for _, input := range []struct {
+			row       map[string]interface{}
+			returning string
+		}{
+			{withIdentity, ``},
+			{row, returning},
+		} {
+			queryGen := self.makeQueryGen(collection)
+			queryGen.Type = generators.SqlInsertStatement
+			queryGen.InputData = input.row
+			queryGen.ReturningField = input.returning
+
+			if stmt, err := filter.Render(queryGen, collection.Name, filter.Null()); err == nil {
+				queries = append(queries, string(stmt[:]))
+			} else {
+				return nil, err
+			}
+		}
+	}
+
+	return queries, nil
+}
+
+// returns the prepared form of the given statement, if it was prepared during warm-up
+func (self *SqlBackend) preparedStatement(query string) *sql.Stmt {
+	if prepared, ok := self.prepared.Load(query); ok {
+		return prepared.(*sqlPreparedStatement).stmt
+	}
+
+	return nil
+}
+
+// returns whether any statements have been prepared for the given collection
+func (self *SqlBackend) isWarm(name string) bool {
+	var warm bool
+
+	self.prepared.Range(func(_ interface{}, value interface{}) bool {
+		warm = (value.(*sqlPreparedStatement).collection == name)
+		return !warm
+	})
+
+	return warm
+}
+
+// close and forget the statements prepared for the given collection
+func (self *SqlBackend) discardPrepared(name string) {
+	self.prepared.Range(func(key interface{}, value interface{}) bool {
+		if prepared := value.(*sqlPreparedStatement); prepared.collection == name {
+			self.prepared.Delete(key)
+			prepared.stmt.Close()
+		}
+
+		return true
+	})
+}
+
+// executes the given statement in a transaction, using its prepared form if it has one
+func (self *SqlBackend) txExec(ctx context.Context, tx sqlTx, query string, values ...interface{}) (sql.Result, error) {
+	if stmt := self.preparedStatement(query); stmt != nil {
+		return tx.StmtContext(ctx, stmt).ExecContext(ctx, values...)
+	}
+
+	return tx.ExecContext(ctx, query, values...)
+}
+
+// queries a single row in a transaction, using the statement's prepared form if it has one
+func (self *SqlBackend) txQueryRow(ctx context.Context, tx sqlTx, query string, values ...interface{}) *sql.Row {
+	if stmt := self.preparedStatement(query); stmt != nil {
+		return tx.StmtContext(ctx, stmt).QueryRowContext(ctx, values...)
+	}
+
+	return tx.QueryRowContext(ctx, query, values...)
+}
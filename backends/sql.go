@@ -4,11 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"reflect"
+	"hash/fnv"
+	"sort"
 	"strings"
 	"sync"
 	"time"
-	"unicode"
 
 	"github.com/ghetzel/go-stockutil/log"
 	"github.com/ghetzel/go-stockutil/maputil"
@@ -23,8 +23,28 @@ import (
 var SqlObjectFieldHintLength = 131071
 var SqlArrayFieldHintLength = 131069
 
+// The maximum number of records written by a single INSERT statement.  This can be changed for a
+// specific connection with the "batchsize" option; a batch size of 1 inserts records one at a time.
+var SqlInsertBatchSize = 100
+
+// The maximum number of values sent with a single INSERT statement.  Batches are made smaller than
+// SqlInsertBatchSize if necessary to stay under this (SQLite's default limit is the lowest.)
+var SqlInsertMaxParameters = 999
+
 var InitialPingTimeout = time.Duration(10) * time.Second
 var sqlMaxExactCountRows = 10000
+var sqlTotalsColumn = `_pivot_total`
+var sqlScoreColumn = `_pivot_score`
+var sqlMaxIdleConns = 2 // the database/sql default
+
+// Specifies how a SQL backend matches collection names against the names of the tables in the
+// database, which may not have the case they were created with.
+type SqlIdentifierCase string
+
+const (
+	PreserveIdentifierCase SqlIdentifierCase = `preserve` // names must match exactly
+	LowerIdentifierCase    SqlIdentifierCase = `lower`    // names are matched without regard to case (e.g.: because the database folds them to lowercase)
+)
 
 type SqlPreInitFunc func(*SqlBackend)
 type SqlInitFunc func(*SqlBackend) (string, string, error)
@@ -48,16 +68,25 @@ func init() {
 	// setup scheme aliases (e.g.: psql:// -> postgresql://)
 	dal.AddConnectionSchemeAlias(`psql`, `postgresql`)
 	dal.AddConnectionSchemeAlias(`postgres`, `postgresql`)
+	dal.AddConnectionSchemeAlias(`cockroachdb`, `cockroach`)
+	dal.AddConnectionSchemeAlias(`crdb`, `cockroach`)
+	dal.AddConnectionSchemeAlias(`sqlserver`, `mssql`)
 
 	// setup (optional) pre-initializers
 	RegisterSqlPreInitFunc(`mysql`, preinitializeMysql)
+	RegisterSqlPreInitFunc(`tidb`, preinitializeTidb)
 	RegisterSqlPreInitFunc(`sqlite`, preinitializeSqlite)
 	RegisterSqlPreInitFunc(`postgresql`, preinitializePostgres)
+	RegisterSqlPreInitFunc(`cockroach`, preinitializeCockroach)
+	RegisterSqlPreInitFunc(`mssql`, preinitializeMssql)
 
 	// setup *required* initializers
 	RegisterSqlInitFunc(`mysql`, initializeMysql)
+	RegisterSqlInitFunc(`tidb`, initializeTidb)
 	RegisterSqlInitFunc(`sqlite`, initializeSqlite)
 	RegisterSqlInitFunc(`postgresql`, initializePostgres)
+	RegisterSqlInitFunc(`cockroach`, initializeCockroach)
+	RegisterSqlInitFunc(`mssql`, initializeMssql)
 
 	util.DisableFeature(`sql-migrate`)
 }
@@ -82,6 +111,10 @@ type additionalIndexer struct {
 }
 
 type sqlTableDetailsFunc func(datasetName string, collectionName string) (*dal.Collection, error)
+type sqlAdvisoryLockFunc func(ctx context.Context, conn *sql.Conn, name string, timeout time.Duration) error
+type sqlAdvisoryUnlockFunc func(conn *sql.Conn, name string) error
+type sqlSearchIndexFunc func(collection *dal.Collection, gen *generators.Sql) []string
+type sqlTableOptionsFunc func(collection *dal.Collection, gen *generators.Sql) (string, error)
 
 type SqlBackend struct {
 	Backend
@@ -89,6 +122,9 @@ type SqlBackend struct {
 	Aggregator
 	conn                       *dal.ConnectionString
 	db                         *sql.DB
+	replicas                   []*sqlReplica
+	replicaPolicy              SqlReplicaPolicy
+	replicaCounter             uint32
 	indexer                    Indexer
 	aggregator                 map[string]Aggregator
 	queryGenTypeMapping        generators.SqlTypeMapping
@@ -100,12 +136,25 @@ type SqlBackend struct {
 	foreignKeyConstraintFormat string
 	defaultCurrentTimeString   string
 	refreshCollectionFunc      sqlTableDetailsFunc
+	advisoryLockFunc           sqlAdvisoryLockFunc
+	advisoryUnlockFunc         sqlAdvisoryUnlockFunc
+	searchIndexFunc            sqlSearchIndexFunc
+	tableOptionsFunc           sqlTableOptionsFunc
+	isExistsErrorFunc          func(err error) bool
+	isRetryableErrorFunc       func(err error) bool
+	asOfSystemTime             string
 	countEstimateQuery         string
 	countExactQuery            string
 	dropTableQuery             string
+	insertReturningIdentity    bool
+	identifierCase             SqlIdentifierCase
+	ignoresTypeLengths         bool
+	listIndexesQuery           string
 	registeredCollections      sync.Map
+	prepared                   sync.Map
 	knownCollections           map[string]bool
 	detectedCollections        map[string]*dal.Collection
+	tableNames                 map[string]string
 	initialized                bool
 }
 
@@ -117,12 +166,16 @@ func NewSqlBackend(connection dal.ConnectionString) Backend {
 		aggregator:          make(map[string]Aggregator),
 		knownCollections:    make(map[string]bool),
 		detectedCollections: make(map[string]*dal.Collection),
+		tableNames:          make(map[string]string),
+		identifierCase:      PreserveIdentifierCase,
 	}
 
 	if fn, ok := sqlPreInitFuncs[connection.Backend()]; ok && fn != nil {
 		fn(backend)
 	}
 
+	backend.identifierCase = SqlIdentifierCase(connection.OptString(`identifier_case`, string(backend.identifierCase)))
+
 	backend.indexer = backend
 	return backend
 }
@@ -130,7 +183,7 @@ func NewSqlBackend(connection dal.ConnectionString) Backend {
 func (self *SqlBackend) Supports(features ...BackendFeature) bool {
 	for _, feat := range features {
 		switch feat {
-		case Constraints:
+		case Constraints, Transactions:
 			return true
 		default:
 			return false
@@ -150,9 +203,16 @@ func (self *SqlBackend) GetConnectionString() *dal.ConnectionString {
 
 func (self *SqlBackend) RegisterCollection(collection *dal.Collection) {
 	if collection != nil {
-		self.registeredCollections.Store(collection.Name, collection)
+		self.registeredCollections.Store(self.collectionKey(collection.Name), collection)
 		log.Debugf("[%v] register collection %v", self, collection.Name)
 		go self.updateEstimatedCountForTable(collection)
+
+		// collections registered after startup are warmed up as they arrive
+		if self.initialized && self.warmupEnabled() && !self.isWarm(collection.Name) {
+			if err := self.warmupCollection(collection.Name); err != nil {
+				log.Warningf("[%v] failed to warm up collection %v: %v", self, collection.Name, err)
+			}
+		}
 	}
 }
 
@@ -226,6 +286,13 @@ func (self *SqlBackend) Initialize() error {
 	var dsn string
 	var err error
 
+	switch self.identifierCase {
+	case PreserveIdentifierCase, LowerIdentifierCase:
+		break
+	default:
+		return fmt.Errorf("unsupported identifier case %q", self.identifierCase)
+	}
+
 	// setup driver-specific settings
 	if fn, ok := sqlInitFuncs[self.conn.Backend()]; ok && fn != nil {
 		name, dsn, err = fn(self)
@@ -251,6 +318,11 @@ func (self *SqlBackend) Initialize() error {
 		return err
 	}
 
+	// connect to any read replicas
+	if err := self.openReplicas(); err != nil {
+		return err
+	}
+
 	// refresh schema cache
 	if err := self.refreshAllCollections(); err != nil {
 		return err
@@ -262,6 +334,11 @@ func (self *SqlBackend) Initialize() error {
 
 	// setup aggregators (currently this is just the SQL implementation)
 	self.aggregator[``] = self
+
+	// prepare common statements ahead of the first requests (if enabled)
+	self.warmupAll()
+
+	self.initialized = true
 	return nil
 }
 
@@ -280,65 +357,138 @@ func (self *SqlBackend) Ping(timeout time.Duration) error {
 	}
 }
 
+// Discard the pooled connections to the database and its replicas, which will not survive the
+// server restarting, so that subsequent operations establish new ones.
+func (self *SqlBackend) Reconnect() error {
+	if self.db == nil {
+		return fmt.Errorf("Backend not initialized")
+	}
+
+	self.db.SetMaxIdleConns(0)
+	self.db.SetMaxIdleConns(sqlMaxIdleConns)
+
+	for _, replica := range self.replicas {
+		replica.db.SetMaxIdleConns(0)
+		replica.db.SetMaxIdleConns(sqlMaxIdleConns)
+	}
+
+	return nil
+}
+
 func (self *SqlBackend) Insert(name string, recordset *dal.RecordSet) error {
+	return self.InsertContext(context.Background(), name, recordset)
+}
+
+func (self *SqlBackend) InsertContext(ctx context.Context, name string, recordset *dal.RecordSet) error {
+	if err := self.formatRecordSet(name, recordset, true); err != nil {
+		return err
+	}
+
+	return self.retry(ctx, func() error {
+		return self.insert(ctx, nil, name, recordset)
+	})
+}
+
+// insert records that have already been formatted (see formatRecordSet)
+func (self *SqlBackend) insert(ctx context.Context, outer *sqlTransaction, name string, recordset *dal.RecordSet) error {
 	if collection, err := self.getCollectionFromCache(name); err == nil {
-		if tx, err := self.db.Begin(); err == nil {
+		if tx, err := self.begin(ctx, outer); err == nil {
 			switch self.String() {
-			case `mysql`:
+			case `mysql`, `tidb`:
 				// disable zero-means-use-autoincrement for inserts in MySQL
-				if _, err := tx.Exec(`SET sql_mode='NO_AUTO_VALUE_ON_ZERO'`); err != nil {
+				if _, err := tx.ExecContext(ctx, `SET sql_mode='NO_AUTO_VALUE_ON_ZERO'`); err != nil {
 					defer tx.Rollback()
 					return err
 				}
 			}
 
+			var batch = make([]map[string]interface{}, 0)
+			var batchSize = int(self.conn.OptInt(`batchsize`, int64(SqlInsertBatchSize)))
+
+			if batchSize < 1 {
+				batchSize = 1
+			}
+
+			// write all pending rows in a single multi-row INSERT
+			var flush = func() error {
+				if len(batch) == 0 {
+					return nil
+				}
+
+				_, err := self.execInsert(ctx, tx, collection, batch, ``)
+				batch = make([]map[string]interface{}, 0)
+
+				return err
+			}
+
 			// for each record being inserted...
-			for _, record := range recordset.Records {
+			for i, record := range recordset.Records {
 				if r, err := collection.StructToRecord(record); err == nil {
 					record = r
 				} else {
 					return err
 				}
 
-				// setup query generator
-				queryGen := self.makeQueryGen(collection)
-				queryGen.Type = generators.SqlInsertStatement
+				var row = make(map[string]interface{})
 
 				// add record data to query input
 				for k, v := range record.Fields {
 					// convert incoming values to their destination field types
-					queryGen.InputData[k] = collection.ConvertValue(k, v)
+					row[k] = collection.ConvertValue(k, v)
 				}
 
 				// set the primary key
 				if !typeutil.IsZero(record.ID) && fmt.Sprintf("%v", record.ID) != `0` {
 					// convert incoming ID to it's destination field type
-					queryGen.InputData[collection.IdentityField] = collection.ConvertValue(collection.IdentityField, record.ID)
-				}
-
-				// render the query into the final SQL
-				if stmt, err := filter.Render(queryGen, collection.Name, filter.Null()); err == nil {
-					querylog.Debugf("[%v] %s", self, string(stmt[:]))
+					row[collection.IdentityField] = collection.ConvertValue(collection.IdentityField, record.ID)
+				} else if self.insertReturningIdentity {
+					// have the database tell us the identity it generated for this record, which
+					// requires inserting it by itself
+					if err := flush(); err != nil {
+						defer tx.Rollback()
+						return err
+					}
 
-					// execute the SQL
-					if _, err := tx.Exec(string(stmt[:]), queryGen.GetValues()...); err != nil {
+					if id, err := self.execInsert(ctx, tx, collection, []map[string]interface{}{row}, collection.IdentityField); err == nil {
+						record.ID = collection.ConvertValue(collection.IdentityField, id)
+						recordset.Records[i].ID = record.ID
+					} else {
 						defer tx.Rollback()
 						return err
 					}
-				} else {
-					defer tx.Rollback()
-					return err
+
+					continue
 				}
+
+				// rows can only share a statement if they set the same fields, and only so many
+				// values can be sent with a single statement
+				if len(batch) > 0 {
+					if len(batch) >= batchSize || !sqlSameFields(batch[0], row) || (len(batch)+1)*len(row) > SqlInsertMaxParameters {
+						if err := flush(); err != nil {
+							defer tx.Rollback()
+							return err
+						}
+					}
+				}
+
+				batch = append(batch, row)
+			}
+
+			if err := flush(); err != nil {
+				defer tx.Rollback()
+				return err
 			}
 
 			// commit transaction
 			if err := tx.Commit(); err == nil {
 				if search := self.WithSearch(collection); search != nil {
-					if err := search.Index(collection, recordset); err != nil {
-						querylog.Debugf("[%v] index error %v", self, err)
-					} else {
-						return err
-					}
+					return self.afterCommit(outer, func() error {
+						if err := search.Index(collection, recordset); err != nil {
+							querylog.Debugf("[%v] index error %v", self, err)
+						}
+
+						return nil
+					})
 				}
 
 				return nil
@@ -353,34 +503,80 @@ func (self *SqlBackend) Insert(name string, recordset *dal.RecordSet) error {
 	}
 }
 
+// executes a single INSERT statement writing the given rows.  If returning is given, the value of
+// that field in the inserted row is returned.
+func (self *SqlBackend) execInsert(ctx context.Context, tx sqlTx, collection *dal.Collection, rows []map[string]interface{}, returning string) (interface{}, error) {
+	// setup query generator
+	queryGen := self.makeQueryGen(collection)
+	queryGen.Type = generators.SqlInsertStatement
+	queryGen.InputData = rows[0]
+	queryGen.InputRows = rows[1:]
+	queryGen.ReturningField = returning
+
+	// render the query into the final SQL
+	if stmt, err := filter.Render(queryGen, collection.Name, filter.Null()); err == nil {
+		querylog.Debugf("[%v] %s", self, string(stmt[:]))
+
+		// execute the SQL
+		if returning != `` {
+			var id interface{}
+
+			if err := self.txQueryRow(ctx, tx, string(stmt[:]), queryGen.GetValues()...).Scan(&id); err == nil {
+				if v, ok := id.([]byte); ok {
+					id = string(v)
+				}
+
+				return id, nil
+			} else {
+				return nil, err
+			}
+		} else if _, err := self.txExec(ctx, tx, string(stmt[:]), queryGen.GetValues()...); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	} else {
+		return nil, err
+	}
+}
+
+// returns whether both rows set exactly the same fields
+func sqlSameFields(a map[string]interface{}, b map[string]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (self *SqlBackend) Exists(name string, id interface{}) bool {
 	if collection, err := self.getCollectionFromCache(name); err == nil {
 		if f, err := self.keyQuery(collection, id); err == nil {
-			if tx, err := self.db.Begin(); err == nil {
-				defer tx.Commit()
-
-				f.Fields = []string{collection.IdentityField}
-				queryGen := self.makeQueryGen(collection)
+			f.Fields = []string{collection.IdentityField}
+			queryGen := self.makeQueryGen(collection)
 
-				if err := queryGen.Initialize(collection.Name); err == nil {
-					if stmt, err := filter.Render(queryGen, collection.Name, f); err == nil {
-						querylog.Debugf("[%v] %s", self, string(stmt[:]))
+			if err := queryGen.Initialize(collection.Name); err == nil {
+				if stmt, err := filter.Render(queryGen, collection.Name, f); err == nil {
+					querylog.Debugf("[%v] %s", self, string(stmt[:]))
 
-						// perform query
-						if rows, err := tx.Query(string(stmt[:]), queryGen.GetValues()...); err == nil {
-							defer rows.Close()
-							return rows.Next()
-						} else {
-							querylog.Debugf("[%v] query error %v", self, err)
-						}
+					// perform query
+					if rows, err := self.readQuery(context.Background(), string(stmt[:]), queryGen.GetValues()...); err == nil {
+						defer rows.Close()
+						return rows.Next()
 					} else {
-						querylog.Debugf("[%v] query generator error %v", self, err)
+						querylog.Debugf("[%v] query error %v", self, err)
 					}
 				} else {
 					querylog.Debugf("[%v] query generator error %v", self, err)
 				}
 			} else {
-				querylog.Debugf("[%v] transaction error %v", self, err)
+				querylog.Debugf("[%v] query generator error %v", self, err)
 			}
 		} else {
 			querylog.Debugf("[%v] error generating key query: %v", self, err)
@@ -393,6 +589,10 @@ func (self *SqlBackend) Exists(name string, id interface{}) bool {
 }
 
 func (self *SqlBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	return self.RetrieveContext(context.Background(), name, id, fields...)
+}
+
+func (self *SqlBackend) RetrieveContext(ctx context.Context, name string, id interface{}, fields ...string) (*dal.Record, error) {
 	if collection, err := self.getCollectionFromCache(name); err == nil {
 		if f, err := self.keyQuery(collection, id); err == nil {
 			f.Fields = fields
@@ -403,12 +603,15 @@ func (self *SqlBackend) Retrieve(name string, id interface{}, fields ...string)
 					querylog.Debugf("[%v] %s", self, string(stmt[:]))
 
 					// perform query
-					if rows, err := self.db.Query(string(stmt[:]), queryGen.GetValues()...); err == nil {
+					if rows, err := self.readQuery(ctx, string(stmt[:]), queryGen.GetValues()...); err == nil {
 						defer rows.Close()
 
 						if columns, err := rows.Columns(); err == nil {
 							if rows.Next() {
-								return self.scanFnValueToRecord(queryGen, collection, columns, reflect.ValueOf(rows.Scan), fields)
+								plan := self.newScanPlan(queryGen, collection, columns, fields)
+								defer plan.Release()
+
+								return self.scanFnValueToRecord(plan, rows.Scan)
 							} else {
 								// if it doesn't exist, make sure it's not indexed
 								if search := self.WithSearch(collection); search != nil {
@@ -438,6 +641,21 @@ func (self *SqlBackend) Retrieve(name string, id interface{}, fields ...string)
 }
 
 func (self *SqlBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	return self.UpdateContext(context.Background(), name, recordset, target...)
+}
+
+func (self *SqlBackend) UpdateContext(ctx context.Context, name string, recordset *dal.RecordSet, target ...string) error {
+	if err := self.formatRecordSet(name, recordset, false); err != nil {
+		return err
+	}
+
+	return self.retry(ctx, func() error {
+		return self.update(ctx, nil, name, recordset, target...)
+	})
+}
+
+// update records that have already been formatted (see formatRecordSet)
+func (self *SqlBackend) update(ctx context.Context, outer *sqlTransaction, name string, recordset *dal.RecordSet, target ...string) error {
 	var targetFilter *filter.Filter
 
 	if len(target) > 0 {
@@ -449,7 +667,7 @@ func (self *SqlBackend) Update(name string, recordset *dal.RecordSet, target ...
 	}
 
 	if collection, err := self.getCollectionFromCache(name); err == nil {
-		if tx, err := self.db.Begin(); err == nil {
+		if tx, err := self.begin(ctx, outer); err == nil {
 			// for each record being updated...
 			for _, record := range recordset.Records {
 				if r, err := collection.StructToRecord(record); err == nil {
@@ -501,7 +719,7 @@ func (self *SqlBackend) Update(name string, recordset *dal.RecordSet, target ...
 					querylog.Debugf("[%v] %s", self, string(stmt[:]))
 
 					// execute SQL
-					if _, err := tx.Exec(string(stmt[:]), queryGen.GetValues()...); err != nil {
+					if _, err := tx.ExecContext(ctx, string(stmt[:]), queryGen.GetValues()...); err != nil {
 						defer tx.Rollback()
 						return err
 					}
@@ -513,9 +731,9 @@ func (self *SqlBackend) Update(name string, recordset *dal.RecordSet, target ...
 
 			if err := tx.Commit(); err == nil {
 				if search := self.WithSearch(collection); search != nil {
-					if err := search.Index(collection, recordset); err != nil {
-						return err
-					}
+					return self.afterCommit(outer, func() error {
+						return search.Index(collection, recordset)
+					})
 				}
 
 				return nil
@@ -530,44 +748,69 @@ func (self *SqlBackend) Update(name string, recordset *dal.RecordSet, target ...
 	}
 }
 
-func (self *SqlBackend) Delete(name string, ids ...interface{}) error {
+// run the collection's pre-save formatters on the records being written.  This happens once per
+// Insert or Update, before (and not during) any retries of the write itself.
+func (self *SqlBackend) formatRecordSet(name string, recordset *dal.RecordSet, isCreate bool) error {
 	if collection, err := self.getCollectionFromCache(name); err == nil {
-		// remove documents from index
-		if search := self.WithSearch(collection); search != nil {
-			defer search.IndexRemove(collection, ids)
-		}
+		return collection.FormatRecordSet(recordset, isCreate)
+	} else {
+		return err
+	}
+}
+
+func (self *SqlBackend) Delete(name string, ids ...interface{}) error {
+	return self.DeleteContext(context.Background(), name, ids...)
+}
+
+func (self *SqlBackend) DeleteContext(ctx context.Context, name string, ids ...interface{}) error {
+	return self.retry(ctx, func() error {
+		return self.delete(ctx, nil, name, ids...)
+	})
+}
 
+func (self *SqlBackend) delete(ctx context.Context, outer *sqlTransaction, name string, ids ...interface{}) error {
+	if collection, err := self.getCollectionFromCache(name); err == nil {
 		// TODO: need to work out how to handle DELETEs on tables with composite keys
 		// f, err := self.keyQuery(collection, record)
 
-		f := filter.New()
+		if tx, err := self.begin(ctx, outer); err == nil {
+			// large ID lists are deleted in batches the database can accept
+			for _, chunk := range self.chunkIDs(ids) {
+				f := filter.New()
 
-		f.AddCriteria(filter.Criterion{
-			Field:  collection.IdentityField,
-			Values: ids,
-		})
+				f.AddCriteria(filter.Criterion{
+					Field:  collection.IdentityField,
+					Values: chunk,
+				})
 
-		if tx, err := self.db.Begin(); err == nil {
-			queryGen := self.makeQueryGen(collection)
-			queryGen.Type = generators.SqlDeleteStatement
+				queryGen := self.makeQueryGen(collection)
+				queryGen.Type = generators.SqlDeleteStatement
 
-			// generate SQL
-			if stmt, err := filter.Render(queryGen, collection.Name, f); err == nil {
-				querylog.Debugf("[%v] %s", self, string(stmt[:]))
+				// generate SQL
+				if stmt, err := filter.Render(queryGen, collection.Name, f); err == nil {
+					querylog.Debugf("[%v] %s", self, string(stmt[:]))
 
-				// execute SQL
-				if _, err := tx.Exec(string(stmt[:]), queryGen.GetValues()...); err == nil {
-					if err := tx.Commit(); err == nil {
-						return nil
-					} else {
+					// execute SQL
+					if _, err := tx.ExecContext(ctx, string(stmt[:]), queryGen.GetValues()...); err != nil {
+						defer tx.Rollback()
 						return err
 					}
 				} else {
 					defer tx.Rollback()
 					return err
 				}
+			}
+
+			if err := tx.Commit(); err == nil {
+				// remove documents from index once they're gone from the database
+				if search := self.WithSearch(collection); search != nil {
+					return self.afterCommit(outer, func() error {
+						return search.IndexRemove(collection, ids)
+					})
+				}
+
+				return nil
 			} else {
-				defer tx.Rollback()
 				return err
 			}
 		} else {
@@ -578,6 +821,30 @@ func (self *SqlBackend) Delete(name string, ids ...interface{}) error {
 	}
 }
 
+// splits a list of IDs into groups no larger than the number of placeholders the
+// database accepts in a single statement.
+func (self *SqlBackend) chunkIDs(ids []interface{}) [][]interface{} {
+	var max = self.queryGenTypeMapping.MaxPlaceholders
+	var chunks [][]interface{}
+
+	if max <= 0 || len(ids) <= max {
+		return [][]interface{}{ids}
+	}
+
+	for len(ids) > 0 {
+		n := max
+
+		if len(ids) < n {
+			n = len(ids)
+		}
+
+		chunks = append(chunks, ids[:n])
+		ids = ids[n:]
+	}
+
+	return chunks
+}
+
 func (self *SqlBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
 	return self.indexer
 }
@@ -593,12 +860,57 @@ func (self *SqlBackend) WithAggregator(collection *dal.Collection) Aggregator {
 }
 
 func (self *SqlBackend) ListCollections() ([]string, error) {
-	return maputil.StringKeys(&self.registeredCollections), nil
+	var names = make([]string, 0)
+
+	self.registeredCollections.Range(func(_, value interface{}) bool {
+		names = append(names, value.(*dal.Collection).Name)
+		return true
+	})
+
+	sort.Strings(names)
+
+	return names, nil
 }
 
 func (self *SqlBackend) schemaColumnClause(field *dal.Field, gen *generators.Sql) (string, error) {
 	var def string
 
+	if nativeType, err := self.schemaColumnType(field, gen); err == nil {
+		def = fmt.Sprintf("%s %s", gen.ToFieldName(field.Name), nativeType)
+	} else {
+		return ``, err
+	}
+
+	if field.Required {
+		def += ` NOT NULL`
+	}
+
+	if field.Unique {
+		def += ` UNIQUE`
+	}
+
+	// if the default value is neither nil nor a function
+	if v := field.DefaultValue; v != nil && !typeutil.IsFunction(field.DefaultValue) {
+		switch vS := typeutil.String(v); vS {
+		case `now`:
+			def += fmt.Sprintf(" DEFAULT %v", self.defaultCurrentTimeString)
+		default:
+			// other default value expressions are evaluated when records are written
+			if !dal.IsDefaultExpression(v) {
+				if literal, ok := v.(string); ok {
+					v = strings.TrimPrefix(literal, dal.DefaultLiteralPrefix)
+				}
+
+				def += fmt.Sprintf(" DEFAULT %v", gen.ToNativeValue(field.Type, []dal.Type{field.Subtype}, v))
+			}
+		}
+	}
+
+	return def, nil
+}
+
+// returns the native type used to store the given field
+func (self *SqlBackend) schemaColumnType(field *dal.Field, gen *generators.Sql) (string, error) {
 	// This is weird...
 	//
 	// So Array, Object, and Raw fields are stored using the same datatype (BLOB), which
@@ -616,34 +928,124 @@ func (self *SqlBackend) schemaColumnClause(field *dal.Field, gen *generators.Sql
 		field.Length = SqlArrayFieldHintLength
 	}
 
-	if nativeType, err := gen.ToNativeType(field.Type, []dal.Type{field.Subtype}, field.Length); err == nil {
-		def = fmt.Sprintf("%s %s", gen.ToFieldName(field.Name), nativeType)
+	return gen.ToNativeType(field.Type, []dal.Type{field.Subtype}, field.Length)
+}
+
+// Takes a lock shared with every other process connected to the same database, if the database
+// supports it.  The lock is held by a dedicated connection until the returned function is called.
+func (self *SqlBackend) AdvisoryLock(name string, timeout time.Duration) (func() error, error) {
+	if self.advisoryLockFunc == nil || self.advisoryUnlockFunc == nil {
+		return nil, NotImplementedError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if conn, err := self.db.Conn(ctx); err == nil {
+		querylog.Debugf("[%v] acquiring advisory lock %q", self, name)
+
+		if err := self.advisoryLockFunc(ctx, conn, name, timeout); err != nil {
+			conn.Close()
+
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("timed out after %v waiting for lock %q", timeout, name)
+			}
+
+			return nil, err
+		}
+
+		return func() error {
+			defer conn.Close()
+
+			querylog.Debugf("[%v] releasing advisory lock %q", self, name)
+			return self.advisoryUnlockFunc(conn, name)
+		}, nil
 	} else {
-		return ``, err
+		return nil, err
 	}
+}
 
-	if field.Required {
-		def += ` NOT NULL`
+// derives a numeric lock key from a lock name, for databases whose advisory locks are identified
+// by number
+func sqlAdvisoryLockKey(name string) int64 {
+	var hash = fnv.New64a()
+
+	hash.Write([]byte(name))
+
+	return int64(hash.Sum64())
+}
+
+// shortens lock names that are longer than a database allows, keeping them unique
+func sqlAdvisoryLockName(name string, maxLength int) string {
+	if len(name) <= maxLength {
+		return name
 	}
 
-	if field.Unique {
-		def += ` UNIQUE`
+	var suffix = fmt.Sprintf("%016x", uint64(sqlAdvisoryLockKey(name)))
+
+	return name[:maxLength-len(suffix)-1] + `:` + suffix
+}
+
+// Creates the collection, unless it already exists.  Creation is serialized (across processes,
+// where the database supports advisory locks) so that several instances starting up at once
+// don't race to create the same table.  If the collection exists, a dal.CollectionExistsError is
+// returned, unless the connection's "ignore_existing" option is set.
+func (self *SqlBackend) CreateCollection(definition *dal.Collection) error {
+	if unlock, err := AcquireLock(self, `pivot:create-collection:`+definition.Name, AdvisoryLockTimeout); err == nil {
+		defer unlock()
+	} else {
+		return err
 	}
 
-	// if the default value is neither nil nor a function
-	if v := field.DefaultValue; v != nil && !typeutil.IsFunction(field.DefaultValue) {
-		switch vS := typeutil.String(v); vS {
-		case `now`:
-			def += fmt.Sprintf(" DEFAULT %v", self.defaultCurrentTimeString)
-		default:
-			def += fmt.Sprintf(" DEFAULT %v", gen.ToNativeValue(field.Type, []dal.Type{field.Subtype}, v))
+	// views are left to the database, since their definition may replace an existing one
+	if !definition.View {
+		if exists, err := self.tableExists(definition.Name); err != nil {
+			return err
+		} else if exists {
+			return self.existingCollection(definition)
 		}
 	}
 
-	return def, nil
+	if err := self.createCollection(definition); err == nil {
+		return nil
+	} else if self.isExistsErrorFunc != nil && self.isExistsErrorFunc(err) {
+		// something other than pivot created the table in the meantime
+		return self.existingCollection(definition)
+	} else {
+		return err
+	}
 }
 
-func (self *SqlBackend) CreateCollection(definition *dal.Collection) error {
+func (self *SqlBackend) existingCollection(definition *dal.Collection) error {
+	if err := collectionExistsError(self.conn, definition.Name); err != nil {
+		return err
+	}
+
+	return self.refreshCollectionFromDatabase(definition.Name, definition)
+}
+
+// checks whether a table (or view) with the given name exists in the database
+func (self *SqlBackend) tableExists(name string) (bool, error) {
+	if rows, err := self.db.Query(self.listAllTablesQuery); err == nil {
+		defer rows.Close()
+
+		for rows.Next() {
+			var tableName string
+
+			if err := rows.Scan(&tableName); err != nil {
+				return false, err
+			} else if strings.EqualFold(tableName, name) {
+				return true, nil
+			}
+		}
+
+		return false, rows.Err()
+	} else {
+		return false, err
+	}
+}
+
+func (self *SqlBackend) createCollection(definition *dal.Collection) error {
 	// -- sqlite3
 	// CREATE TABLE foo (
 	//     "id"         INTEGER PRIMARY KEY ASC,
@@ -678,6 +1080,7 @@ func (self *SqlBackend) CreateCollection(definition *dal.Collection) error {
 	gen := self.makeQueryGen(definition)
 	stmt := ``
 	values := make([]interface{}, 0)
+	indexStmts := make([]string, 0)
 
 	if definition.View {
 		vq := ``
@@ -769,14 +1172,39 @@ func (self *SqlBackend) CreateCollection(definition *dal.Collection) error {
 		stmt += strings.Join(fields, `, `)
 		stmt += `)`
 
+		// append any database-specific table options (e.g.: partitioning)
+		if self.tableOptionsFunc != nil {
+			if options, err := self.tableOptionsFunc(definition, gen); err == nil && options != `` {
+				stmt += ` ` + options
+			} else if err != nil {
+				return err
+			}
+		}
+
+		// secondary indexes are created alongside the table
+		if stmts, err := self.createIndexStatements(definition, gen, nil); err == nil {
+			indexStmts = stmts
+		} else {
+			return err
+		}
 	}
 
 	if tx, err := self.db.Begin(); err == nil {
 		querylog.Debugf("[%v] %s", self, string(stmt[:]))
 
 		if _, err := tx.Exec(stmt, values...); err == nil {
+			for _, indexStmt := range indexStmts {
+				querylog.Debugf("[%v] %s", self, indexStmt)
+
+				if _, err := tx.Exec(indexStmt); err != nil {
+					defer tx.Rollback()
+					return err
+				}
+			}
+
 			defer func() {
 				self.RegisterCollection(definition)
+				self.ensureSearchIndex(definition, gen)
 
 				if err := self.refreshCollectionFromDatabase(definition.Name, definition); err != nil {
 					querylog.Debugf("[%v] failed to refresh collection: %v", self, err)
@@ -823,6 +1251,7 @@ func (self *SqlBackend) DeleteCollection(collectionName string) error {
 			querylog.Debugf("[%v] %s", self, string(stmt[:]))
 
 			if _, err := tx.Exec(stmt); err == nil {
+				self.discardPrepared(collection.Name)
 				return tx.Commit()
 			} else {
 				defer tx.Rollback()
@@ -838,7 +1267,7 @@ func (self *SqlBackend) DeleteCollection(collectionName string) error {
 
 func (self *SqlBackend) GetCollection(name string) (*dal.Collection, error) {
 	if err := self.refreshCollectionFromDatabase(name, nil); err == nil {
-		if _, ok := self.knownCollections[name]; !ok {
+		if _, ok := self.knownCollections[self.collectionKey(name)]; !ok {
 			return nil, dal.CollectionNotFound
 		}
 
@@ -880,280 +1309,123 @@ func (self *SqlBackend) makeQueryGen(collection *dal.Collection) *generators.Sql
 		if v := self.queryGenNormalizerFormat; v != `` {
 			queryGen.NormalizerFormat = v
 		}
+
+		for _, sf := range collection.SearchFields {
+			queryGen.SearchFields = append(queryGen.SearchFields, sf.Name)
+		}
 	}
 
 	return queryGen
 }
 
-func (self *SqlBackend) scanFnValueToRecord(queryGen *generators.Sql, collection *dal.Collection, columns []string, scanFn reflect.Value, wantedFields []string) (*dal.Record, error) {
-	if scanFn.Kind() != reflect.Func {
-		return nil, fmt.Errorf("Can only accept a function value")
+// generates the statement that resolves the given difference between a collection's definition
+// and the collection as it exists in the database
+func (self *SqlBackend) generateAlterStatement(collection *dal.Collection, delta *dal.SchemaDelta) (string, []interface{}, error) {
+	if collection == nil {
+		return ``, nil, fmt.Errorf("Collection %q not detected on server", delta.Collection)
 	}
 
-	// sql.Row.Scan is strict about how we call it (e.g.: won't return results as a map),
-	// so we hack...
-	//
-	output := make([]interface{}, len(columns))
-
-	// put a zero-value instance of each column's type in the result array, which will
-	// serve as a hint to the sql.Scan function as to how to convert the data
-	for i, column := range columns {
-		baseColumn := strings.Split(column, queryGen.TypeMapping.NestedFieldSeparator)[0]
-
-		if field, ok := collection.GetField(baseColumn); ok {
-			if field.DefaultValue != nil {
-				output[i] = field.GetDefaultValue()
-			} else if field.Required {
-				output[i] = field.GetTypeInstance()
-			} else {
-				switch field.Type {
-				case dal.StringType, dal.TimeType, dal.ObjectType:
-					output[i] = sql.NullString{}
-
-				case dal.BooleanType:
-					output[i] = sql.NullBool{}
+	gen := self.makeQueryGen(collection)
+	stmt := fmt.Sprintf("ALTER TABLE %s ", gen.ToTableName(collection.Name))
 
-				case dal.IntType:
-					output[i] = sql.NullInt64{}
+	switch delta.Issue {
+	case dal.CollectionKeyNameIssue, dal.CollectionKeyTypeIssue:
+		return ``, nil, fmt.Errorf("Cannot alter key name or type for %T", self)
 
-				case dal.FloatType:
-					output[i] = sql.NullFloat64{}
-
-				default:
-					output[i] = make([]byte, 0)
-				}
-			}
+	case dal.FieldMissingIssue:
+		if delta.ReferenceField == nil {
+			return ``, nil, fmt.Errorf("field %v: new field specification not available", delta.Name)
 		}
-	}
-
-	rRowArgs := make([]reflect.Value, len(output))
-
-	// each argument in the call to scan will be the address of the corresponding
-	// item in the output array
-	for i, _ := range output {
-		rRowArgs[i] = reflect.ValueOf(output).Index(i).Addr()
-	}
-
-	// perform the call to the Scan() function with the correct number of "arguments"
-	rRowResult := scanFn.Call(rRowArgs)
-
-	var err error
 
-	// the function should only return one value of type error
-	if len(rRowResult) == 1 {
-		v := rRowResult[0].Interface()
-
-		if e, ok := v.(error); ok || v == nil {
-			err = e
+		if clause, err := self.schemaColumnClause(delta.ReferenceField, gen); err == nil {
+			stmt += `ADD ` + clause
 		} else {
-			return nil, fmt.Errorf("row scan call returned invalid type (%T)", v)
+			return ``, nil, fmt.Errorf("field %v: %v", delta.Name, err)
 		}
-	} else {
-		return nil, fmt.Errorf("invalid response from row scan call")
-	}
-
-	// this is the actual error returned from calling Scan()
-	if err == nil {
-		var id interface{}
-		fields := make(map[string]interface{})
-
-		// for each column in the resultset
-
-	ColumnLoop:
-		for i, column := range columns {
-			nestedPath := strings.Split(column, queryGen.TypeMapping.NestedFieldSeparator)
-			baseColumn := nestedPath[0]
-
-			if field, ok := collection.GetField(baseColumn); ok {
-				var value interface{}
 
-				// convert value types as needed
-				switch output[i].(type) {
-				// raw byte arrays will either be strings, blobs, or binary-encoded objects
-				// we need to figure out which
-				case []uint8:
-					value = []byte(output[i].([]uint8))
+	case dal.FieldNameIssue:
+		from := typeutil.String(delta.Actual)
+		to := typeutil.String(delta.Desired)
 
-				case sql.NullString:
-					v := output[i].(sql.NullString)
-
-					if v.Valid {
-						value = []byte(v.String)
-					} else {
-						value = nil
-					}
-
-				case sql.NullBool:
-					v := output[i].(sql.NullBool)
-
-					if v.Valid {
-						value = v.Bool
-					} else {
-						value = nil
-					}
+		switch self.String() {
+		case `mssql`:
+			return fmt.Sprintf("EXEC sp_rename '%s.%s', '%s', 'COLUMN'", collection.Name, from, to), nil, nil
+		default:
+			stmt += fmt.Sprintf("RENAME COLUMN %s TO %s", gen.ToFieldName(from), gen.ToFieldName(to))
+		}
 
-				case sql.NullInt64:
-					v := output[i].(sql.NullInt64)
+	case dal.FieldTypeIssue, dal.FieldLengthIssue, dal.FieldPropertyIssue:
+		field, ok := collection.GetField(delta.Name)
 
-					if v.Valid {
-						value = v.Int64
-					} else {
-						value = nil
-					}
+		if !ok {
+			return ``, nil, fmt.Errorf("Cannot modify field %q: not in collection %q", delta.Name, delta.Collection)
+		}
 
-				case sql.NullFloat64:
-					v := output[i].(sql.NullFloat64)
+		desired := delta.DesiredField(field)
+		name := gen.ToFieldName(desired.Name)
 
-					if v.Valid {
-						value = v.Float64
-					} else {
-						value = nil
-					}
-				default:
-					if vStr, ok := output[i].(string); ok {
-						value = []byte(vStr)
-					} else {
-						value = output[i]
-					}
+		switch self.String() {
+		case `mysql`, `tidb`:
+			// TiDB can't change the type of columns that rows are keyed or partitioned by
+			if self.String() == `tidb` && (field.Identity || field.Key) {
+				switch delta.Parameter {
+				case `Type`, `Subtype`, `Length`, `Precision`:
+					return ``, nil, fmt.Errorf("Cannot change %s of key field %q for %v", delta.Parameter, delta.Name, self)
 				}
+			}
 
-				// strings and raw bytes need a little love to determine exactly what they actually are
-				if asBytes, ok := value.([]byte); ok {
-					var dest map[string]interface{}
-
-					switch field.Type {
-					case dal.ObjectType, dal.RawType:
-						if err := queryGen.ObjectTypeDecode(asBytes, &dest); err == nil {
-							value = dest
-						} else {
-							value = asBytes
-						}
-
-					case dal.ArrayType:
-						var destA []interface{}
-
-						if err := queryGen.ArrayTypeDecode(asBytes, &destA); err == nil {
-							value = destA
-						} else {
-							value = asBytes
-						}
-
-					default:
-						value = nil
-
-						if vStr := string(asBytes); len(vStr) > 0 {
-							var normalized []rune
-
-							for _, r := range vStr {
-								if unicode.IsGraphic(r) {
-									normalized = append(normalized, r)
-								}
-							}
+			// MySQL redefines the whole column at once
+			if clause, err := self.schemaColumnClause(desired, gen); err == nil {
+				stmt += `MODIFY ` + clause
+			} else {
+				return ``, nil, fmt.Errorf("field %v: %v", field.Name, err)
+			}
 
-							if nS := string(normalized); nS != `` {
-								value = nS
-							}
-						}
-					}
+		case `postgresql`, `cockroach`:
+			switch delta.Parameter {
+			case `Type`, `Subtype`, `Length`, `Precision`:
+				if nativeType, err := self.schemaColumnType(desired, gen); err == nil {
+					stmt += fmt.Sprintf("ALTER COLUMN %s TYPE %s USING %s::%s", name, nativeType, name, nativeType)
+				} else {
+					return ``, nil, fmt.Errorf("field %v: %v", field.Name, err)
 				}
-
-				// set the appropriate field for the dal.Record
-				if v, err := field.ConvertValue(value); err == nil {
-					if column == collection.IdentityField {
-						id = v
-					} else {
-						if len(wantedFields) > 0 {
-							shouldSkip := true
-
-							for _, wantedField := range wantedFields {
-								parts := strings.Split(wantedField, queryGen.TypeMapping.NestedFieldSeparator)
-
-								if parts[0] == baseColumn {
-									shouldSkip = false
-									break
-								}
-							}
-
-							if shouldSkip {
-								continue ColumnLoop
-							}
-
-						}
-
-						if newFields, ok := maputil.DeepSet(fields, nestedPath, v).(map[string]interface{}); ok {
-							fields = newFields
-						}
-					}
+			case `Required`:
+				if desired.Required {
+					stmt += fmt.Sprintf("ALTER COLUMN %s SET NOT NULL", name)
+				} else {
+					stmt += fmt.Sprintf("ALTER COLUMN %s DROP NOT NULL", name)
 				}
-			}
-		}
-
-		record := dal.NewRecord(id).SetFields(fields)
-
-		// do this AFTER populating the record's fields from the database
-		if err := record.Populate(record, collection); err != nil {
-			return nil, fmt.Errorf("error populating record: %v", err)
-		}
-
-		return record, nil
-	} else {
-		return nil, err
-	}
-}
-
-func (self *SqlBackend) generateAlterStatement(delta *dal.SchemaDelta) (string, []interface{}, error) {
-	var collection *dal.Collection
-
-	if detected, ok := self.detectedCollections[delta.Collection]; ok {
-		collection = detected
-	} else if c, err := self.GetCollection(delta.Collection); err == nil {
-		collection = c
-	}
-
-	if collection != nil {
-		gen := self.makeQueryGen(collection)
-		stmt := fmt.Sprintf("ALTER TABLE %s ", gen.ToTableName(collection.Name))
-
-		switch delta.Issue {
-		case dal.CollectionKeyNameIssue, dal.CollectionKeyTypeIssue:
-			return ``, nil, fmt.Errorf("Cannot alter key name or type for %T", self)
-
-		case dal.FieldMissingIssue:
-			if delta.ReferenceField == nil {
-				return ``, nil, fmt.Errorf("field %v: new field specification not available", delta.Name)
+			default:
+				return ``, nil, fmt.Errorf("Cannot change %s of field %q for %v", delta.Parameter, delta.Name, self)
 			}
 
-			if clause, err := self.schemaColumnClause(delta.ReferenceField, gen); err == nil {
-				stmt += `ADD ` + clause
-			} else {
-				return ``, nil, fmt.Errorf("field %v: %v", delta.Name, err)
-			}
+		case `mssql`:
+			switch delta.Parameter {
+			case `Type`, `Subtype`, `Length`, `Precision`, `Required`:
+				if nativeType, err := self.schemaColumnType(desired, gen); err == nil {
+					stmt += fmt.Sprintf("ALTER COLUMN %s %s", name, nativeType)
 
-		case dal.FieldTypeIssue, dal.FieldPropertyIssue:
-			if field, ok := collection.GetField(delta.Name); ok {
-				if clause, err := self.schemaColumnClause(delta.DesiredField(field), gen); err == nil {
-					stmt += `MODIFY ` + clause
+					if desired.Required {
+						stmt += ` NOT NULL`
+					} else {
+						stmt += ` NULL`
+					}
 				} else {
 					return ``, nil, fmt.Errorf("field %v: %v", field.Name, err)
 				}
-			} else {
-				return ``, nil, fmt.Errorf("Cannot modify field %q: not in collection %q", delta.Name, delta.Collection)
+			default:
+				return ``, nil, fmt.Errorf("Cannot change %s of field %q for %v", delta.Parameter, delta.Name, self)
 			}
 
 		default:
-			return ``, nil, fmt.Errorf("NI: %+v", delta)
-			// case dal.FieldNameIssue:
-			// 	// ALTER TABLE ADD COLUMN ...
-			// case dal.FieldLengthIssue:
-			// 	// ALTER TABLE  ...
-			// case dal.FieldPropertyIssue:
-			// 	// ...
+			return ``, nil, fmt.Errorf("Cannot change the definition of existing field %q for %v", delta.Name, self)
 		}
 
-		return stmt, gen.GetValues(), nil
-	} else {
-		return ``, nil, fmt.Errorf("Collection %q not detected on server", delta.Collection)
+	default:
+		return ``, nil, fmt.Errorf("NI: %+v", delta)
 	}
+
+	return stmt, gen.GetValues(), nil
 }
 
 func (self *SqlBackend) Migrate() error {
@@ -1168,7 +1440,11 @@ func (self *SqlBackend) Migrate() error {
 		registered := value.(*dal.Collection)
 
 		if detected, ok := self.detectedCollections[name]; ok {
-			diff = append(diff, registered.Diff(detected)...)
+			for _, delta := range registered.Diff(detected) {
+				if sqlMigrationAffectsSchema(delta) {
+					diff = append(diff, delta)
+				}
+			}
 		}
 
 		return true
@@ -1179,7 +1455,7 @@ func (self *SqlBackend) Migrate() error {
 		if tx, err := self.db.Begin(); err == nil {
 			// populate statements
 			for _, delta := range diff {
-				if stmt, values, err := self.generateAlterStatement(delta); err == nil {
+				if stmt, values, err := self.generateAlterStatement(self.detectedCollections[self.collectionKey(delta.Collection)], delta); err == nil {
 					querylog.Debugf("[%v] %s", self, string(stmt[:]))
 
 					if _, err := tx.Exec(stmt, values...); err != nil {
@@ -1192,13 +1468,129 @@ func (self *SqlBackend) Migrate() error {
 			}
 
 			// commit transaction
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+		} else {
+			return err
+		}
+	}
+
+	var merr error
+
+	// create any secondary indexes that have been declared since the tables were created
+	self.registeredCollections.Range(func(key, value interface{}) bool {
+		if _, ok := self.detectedCollections[typeutil.String(key)]; ok {
+			merr = log.AppendError(merr, self.EnsureSecondaryIndexes(value.(*dal.Collection)))
+		}
+
+		return true
+	})
+
+	return merr
+}
+
+// Creates any secondary indexes declared on the given collection that don't already exist.
+func (self *SqlBackend) EnsureSecondaryIndexes(collection *dal.Collection) error {
+	var existing = make(map[string]bool)
+
+	if len(collection.GetAllIndexes()) == 0 {
+		return nil
+	} else if self.listIndexesQuery == `` {
+		return fmt.Errorf("%T cannot list existing indexes for this database", self)
+	}
+
+	if rows, err := self.db.Query(fmt.Sprintf(self.listIndexesQuery, collection.Name)); err == nil {
+		defer rows.Close()
+
+		for rows.Next() {
+			var name string
+
+			if err := rows.Scan(&name); err == nil {
+				existing[name] = true
+			} else {
+				return err
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		rows.Close()
+	} else {
+		return err
+	}
+
+	if stmts, err := self.createIndexStatements(collection, self.makeQueryGen(collection), existing); err == nil && len(stmts) > 0 {
+		if tx, err := self.db.Begin(); err == nil {
+			for _, stmt := range stmts {
+				querylog.Debugf("[%v] %s", self, stmt)
+
+				if _, err := tx.Exec(stmt); err != nil {
+					defer tx.Rollback()
+					return err
+				}
+			}
+
 			return tx.Commit()
 		} else {
 			return err
 		}
 	} else {
-		return nil
+		return err
+	}
+}
+
+// Creates whatever the database needs in order to run full-text queries against the collection's
+// search fields.  Full-text search is optional, so failures (e.g.: because SQLite was built without
+// FTS5) are logged rather than returned.
+func (self *SqlBackend) ensureSearchIndex(collection *dal.Collection, gen *generators.Sql) {
+	if self.searchIndexFunc == nil || len(collection.SearchFields) == 0 || collection.View {
+		return
+	}
+
+	for _, stmt := range self.searchIndexFunc(collection, gen) {
+		querylog.Debugf("[%v] %s", self, stmt)
+
+		if _, err := self.db.Exec(stmt); err != nil {
+			log.Warningf("[%v] full-text search is unavailable for collection %q: %v", self, collection.Name, err)
+			return
+		}
+	}
+}
+
+// generate CREATE INDEX statements for all of the collection's secondary indexes that aren't
+// in the given set of existing index names
+func (self *SqlBackend) createIndexStatements(collection *dal.Collection, gen *generators.Sql, existing map[string]bool) ([]string, error) {
+	var stmts = make([]string, 0)
+
+	for _, index := range collection.GetAllIndexes() {
+		if err := index.Validate(collection); err != nil {
+			return nil, err
+		}
+
+		var name = index.GetName(collection.Name)
+
+		if existing[name] {
+			continue
+		}
+
+		var fields = make([]string, len(index.Fields))
+
+		for i, field := range index.Fields {
+			fields[i] = gen.ToFieldName(field)
+		}
+
+		stmts = append(stmts, fmt.Sprintf(
+			"CREATE INDEX %s ON %s (%s)",
+			gen.ToTableName(name),
+			gen.ToTableName(collection.Name),
+			strings.Join(fields, `, `),
+		))
 	}
+
+	return stmts, nil
 }
 
 func (self *SqlBackend) refreshAllCollections() error {
@@ -1215,9 +1607,10 @@ func (self *SqlBackend) refreshAllCollections() error {
 			var tableName string
 
 			if err := rows.Scan(&tableName); err == nil {
-				knownTables = append(knownTables, tableName)
+				knownTables = append(knownTables, self.collectionKey(tableName))
+				self.tableNames[self.collectionKey(tableName)] = tableName
 
-				if definitionI, ok := self.registeredCollections.Load(tableName); ok {
+				if definitionI, ok := self.registeredCollections.Load(self.collectionKey(tableName)); ok {
 					definition := definitionI.(*dal.Collection)
 
 					if err := self.refreshCollectionFromDatabase(definition.Name, definition); err != nil {
@@ -1250,9 +1643,9 @@ func (self *SqlBackend) refreshAllCollections() error {
 func (self *SqlBackend) refreshCollectionFromDatabase(name string, definition *dal.Collection) error {
 	if collection, err := self.refreshCollectionFunc(
 		self.conn.Dataset(),
-		name,
+		self.tableName(name),
 	); err == nil {
-		self.detectedCollections[collection.Name] = collection
+		self.detectedCollections[self.collectionKey(collection.Name)] = collection
 
 		if definition != nil {
 			// we've read the collection back from the database, but in the process we've lost
@@ -1265,7 +1658,7 @@ func (self *SqlBackend) refreshCollectionFromDatabase(name string, definition *d
 			self.RegisterCollection(collection)
 		}
 
-		self.knownCollections[name] = true
+		self.knownCollections[self.collectionKey(name)] = true
 
 		return nil
 	} else {
@@ -1274,13 +1667,33 @@ func (self *SqlBackend) refreshCollectionFromDatabase(name string, definition *d
 }
 
 func (self *SqlBackend) getCollectionFromCache(name string) (*dal.Collection, error) {
-	if registered, ok := self.registeredCollections.Load(name); ok {
+	if registered, ok := self.registeredCollections.Load(self.collectionKey(name)); ok {
 		return registered.(*dal.Collection), nil
 	} else {
 		return nil, dal.CollectionNotFound
 	}
 }
 
+// returns the name the given collection is cached under, which follows the backend's identifier
+// case policy so that names read back from the database match the names they were created with
+func (self *SqlBackend) collectionKey(name string) string {
+	switch self.identifierCase {
+	case LowerIdentifierCase:
+		return strings.ToLower(name)
+	default:
+		return name
+	}
+}
+
+// returns the name of the table the given collection is stored in, as reported by the database
+func (self *SqlBackend) tableName(name string) string {
+	if table, ok := self.tableNames[self.collectionKey(name)]; ok {
+		return table
+	}
+
+	return name
+}
+
 func (self *SqlBackend) keyQuery(collection *dal.Collection, id interface{}) (*filter.Filter, error) {
 	var ids []interface{}
 
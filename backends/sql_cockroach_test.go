@@ -0,0 +1,77 @@
+package backends
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqlCockroachOptions(t *testing.T) {
+	assert := require.New(t)
+
+	backend := NewSqlBackend(dal.MustParseConnectionString(`cockroach://localhost/test`)).(*SqlBackend)
+	assert.Empty(backend.asOfSystemTime)
+	assert.True(backend.insertReturningIdentity)
+
+	backend = NewSqlBackend(dal.MustParseConnectionString(`crdb://localhost/test?follower_reads=true`)).(*SqlBackend)
+	assert.Equal(`follower_read_timestamp()`, backend.asOfSystemTime)
+}
+
+func TestSqlCockroachRetry(t *testing.T) {
+	assert := require.New(t)
+
+	backoff := SqlTransactionRetryBackoff
+	SqlTransactionRetryBackoff = 0
+	defer func() {
+		SqlTransactionRetryBackoff = backoff
+	}()
+
+	backend := NewSqlBackend(dal.MustParseConnectionString(`cockroach://localhost/test`)).(*SqlBackend)
+	attempts := 0
+
+	// conflicts are retried until they succeed...
+	assert.NoError(backend.retry(context.Background(), func() error {
+		if attempts += 1; attempts < 3 {
+			return &pq.Error{Code: `40001`}
+		}
+
+		return nil
+	}))
+
+	assert.Equal(3, attempts)
+
+	// ...or until they've been retried too many times
+	attempts = 0
+
+	assert.Error(backend.retry(context.Background(), func() error {
+		attempts += 1
+		return &pq.Error{Code: `40001`}
+	}))
+
+	assert.Equal(SqlTransactionRetries+1, attempts)
+
+	// other errors are returned immediately
+	attempts = 0
+
+	assert.Error(backend.retry(context.Background(), func() error {
+		attempts += 1
+		return fmt.Errorf("nope")
+	}))
+
+	assert.Equal(1, attempts)
+
+	// as are all errors from databases that don't say what can be retried
+	backend = NewSqlBackend(dal.MustParseConnectionString(`postgresql://localhost/test`)).(*SqlBackend)
+	attempts = 0
+
+	assert.Error(backend.retry(context.Background(), func() error {
+		attempts += 1
+		return &pq.Error{Code: `40001`}
+	}))
+
+	assert.Equal(1, attempts)
+}
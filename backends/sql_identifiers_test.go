@@ -0,0 +1,39 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqlIdentifierCase(t *testing.T) {
+	assert := require.New(t)
+
+	backend := NewSqlBackend(dal.MustParseConnectionString(`mysql://localhost/things`)).(*SqlBackend)
+	assert.Equal(LowerIdentifierCase, backend.identifierCase)
+
+	backend.RegisterCollection(dal.NewCollection(`UserAccounts`))
+
+	// the database may report the table in a different case than it was registered with
+	backend.tableNames[backend.collectionKey(`useraccounts`)] = `useraccounts`
+
+	collection, err := backend.getCollectionFromCache(`useraccounts`)
+	assert.NoError(err)
+	assert.Equal(`UserAccounts`, collection.Name)
+	assert.Equal(`useraccounts`, backend.tableName(`UserAccounts`))
+
+	names, err := backend.ListCollections()
+	assert.NoError(err)
+	assert.Equal([]string{`UserAccounts`}, names)
+
+	// names must match exactly when the case is preserved
+	backend = NewSqlBackend(dal.MustParseConnectionString(`mysql://localhost/things?identifier_case=preserve`)).(*SqlBackend)
+	backend.RegisterCollection(dal.NewCollection(`UserAccounts`))
+
+	_, err = backend.getCollectionFromCache(`useraccounts`)
+	assert.Equal(dal.CollectionNotFound, err)
+
+	_, err = backend.getCollectionFromCache(`UserAccounts`)
+	assert.NoError(err)
+}
@@ -0,0 +1,84 @@
+//go:build cgo
+// +build cgo
+
+package backends
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqlQueryManyIDs(t *testing.T) {
+	assert := require.New(t)
+
+	backend := NewSqlBackend(dal.MustParseConnectionString(`sqlite://temporary`)).(*SqlBackend)
+	assert.NoError(backend.Initialize())
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`things`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	collection, err := backend.GetCollection(`things`)
+	assert.NoError(err)
+
+	// more IDs than SQLite accepts placeholders for in a single statement
+	var count = 2500
+	var recordset = dal.NewRecordSet()
+	var ids = make([]interface{}, 0, count)
+
+	for i := 1; i <= count; i++ {
+		recordset.Push(dal.NewRecord(i).Set(`name`, fmt.Sprintf("thing%d", i%2)))
+		ids = append(ids, i)
+	}
+
+	assert.NoError(backend.Insert(`things`, recordset))
+
+	f := filter.New()
+	f.AddCriteria(filter.Criterion{
+		Field:  `id`,
+		Values: ids,
+	})
+
+	results, err := backend.Query(collection, f)
+	assert.NoError(err)
+	assert.Len(results.Records, count)
+	assert.EqualValues(count, results.ResultCount)
+
+	// other criteria apply to every batch
+	f = filter.New()
+	f.AddCriteria(filter.Criterion{
+		Field:  `id`,
+		Values: ids,
+	}, filter.Criterion{
+		Field:  `name`,
+		Values: []interface{}{`thing1`},
+	})
+
+	results, err = backend.Query(collection, f)
+	assert.NoError(err)
+	assert.Len(results.Records, count/2)
+	assert.EqualValues(count/2, results.ResultCount)
+
+	// sorted queries can't be split up without changing their results
+	f = filter.New()
+	f.Sort = []string{`name`}
+	f.AddCriteria(filter.Criterion{
+		Field:  `id`,
+		Values: ids,
+	})
+
+	_, err = backend.Query(collection, f)
+	assert.Error(err)
+
+	// deletes are batched too
+	assert.NoError(backend.Delete(`things`, ids...))
+
+	results, err = backend.Query(collection, filter.All())
+	assert.NoError(err)
+	assert.Empty(results.Records)
+}
@@ -0,0 +1,85 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqlPlanMigrationStatements(t *testing.T) {
+	assert := require.New(t)
+
+	actual := dal.NewCollection(`people`, dal.Field{
+		Name: `fullname`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `age`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `email`,
+		Type: dal.StringType,
+	})
+
+	desired := dal.NewCollection(`people`, dal.Field{
+		Name:        `name`,
+		Type:        dal.StringType,
+		RenamedFrom: []string{`fullname`},
+	}, dal.Field{
+		Name: `age`,
+		Type: dal.IntType,
+	}, dal.Field{
+		Name:     `email`,
+		Type:     dal.StringType,
+		Required: true,
+	}, dal.Field{
+		Name:            `nickname`,
+		Type:            dal.StringType,
+		NotUserEditable: true,
+	})
+
+	var deltas = make(map[string]*dal.SchemaDelta)
+
+	for _, delta := range desired.Diff(actual) {
+		if sqlMigrationAffectsSchema(delta) {
+			deltas[delta.Name] = delta
+		}
+	}
+
+	assert.Len(deltas, 4)
+	assert.Equal(dal.FieldNameIssue, deltas[`name`].Issue)
+
+	postgres := NewSqlBackend(dal.MustParseConnectionString(`postgresql://localhost/test`)).(*SqlBackend)
+
+	stmt, _, err := postgres.generateAlterStatement(actual, deltas[`name`])
+	assert.NoError(err)
+	assert.Equal(`ALTER TABLE "people" RENAME COLUMN "fullname" TO "name"`, stmt)
+
+	stmt, _, err = postgres.generateAlterStatement(actual, deltas[`age`])
+	assert.NoError(err)
+	assert.Equal(`ALTER TABLE "people" ALTER COLUMN "age" TYPE BIGINT USING "age"::BIGINT`, stmt)
+
+	stmt, _, err = postgres.generateAlterStatement(actual, deltas[`email`])
+	assert.NoError(err)
+	assert.Equal(`ALTER TABLE "people" ALTER COLUMN "email" SET NOT NULL`, stmt)
+
+	stmt, _, err = postgres.generateAlterStatement(actual, deltas[`nickname`])
+	assert.NoError(err)
+	assert.Equal(`ALTER TABLE "people" ADD "nickname" TEXT`, stmt)
+
+	mssql := NewSqlBackend(dal.MustParseConnectionString(`mssql://localhost/test`)).(*SqlBackend)
+
+	stmt, _, err = mssql.generateAlterStatement(actual, deltas[`name`])
+	assert.NoError(err)
+	assert.Equal(`EXEC sp_rename 'people.fullname', 'name', 'COLUMN'`, stmt)
+
+	// SQLite can add and rename columns, but not change them
+	sqlite := NewSqlBackend(dal.MustParseConnectionString(`sqlite:///tmp/test.db`)).(*SqlBackend)
+
+	_, _, err = sqlite.generateAlterStatement(actual, deltas[`age`])
+	assert.Error(err)
+
+	stmt, _, err = sqlite.generateAlterStatement(actual, deltas[`name`])
+	assert.NoError(err)
+	assert.Equal(`ALTER TABLE "people" RENAME COLUMN "fullname" TO "name"`, stmt)
+}
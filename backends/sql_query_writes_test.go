@@ -0,0 +1,66 @@
+//go:build cgo
+// +build cgo
+
+package backends
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqlQueryWritesWithIndexer(t *testing.T) {
+	assert := require.New(t)
+
+	defer func(size int) {
+		SqlQueryWriteBatchSize = size
+	}(SqlQueryWriteBatchSize)
+
+	SqlQueryWriteBatchSize = 2
+
+	backend := NewSqlBackend(dal.MustParseConnectionString(`sqlite://temporary`)).(*SqlBackend)
+	assert.NoError(backend.Initialize())
+
+	indexer := &sqlRecordingIndexer{}
+	backend.indexer = indexer
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`people`, dal.Field{
+		Name: `state`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `active`,
+		Type: dal.BooleanType,
+	})))
+
+	collection, err := backend.GetCollection(`people`)
+	assert.NoError(err)
+
+	assert.NoError(backend.Insert(`people`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`state`, `CA`).Set(`active`, true),
+		dal.NewRecord(2).Set(`state`, `CA`).Set(`active`, true),
+		dal.NewRecord(3).Set(`state`, `CA`).Set(`active`, true),
+		dal.NewRecord(4).Set(`state`, `NY`).Set(`active`, true),
+		dal.NewRecord(5).Set(`state`, `CA`).Set(`active`, true),
+	)))
+
+	indexer.indexed = nil
+
+	// matching records are updated (and reindexed) a batch at a time
+	updated, err := backend.UpdateWhere(collection, filter.MustParse(`state/CA`), dal.NewRecord(nil).Set(`active`, false))
+	assert.NoError(err)
+	assert.EqualValues(4, updated)
+	assert.Equal(`[1 2 3 5]`, fmt.Sprintf("%v", indexer.indexed))
+
+	record, err := backend.Retrieve(`people`, 5)
+	assert.NoError(err)
+	assert.Equal(false, record.Get(`active`))
+
+	// ...as are deletes
+	assert.NoError(backend.DeleteWhere(collection, filter.MustParse(`state/CA`)))
+	assert.Equal(`[1 2 3 5]`, fmt.Sprintf("%v", indexer.removed))
+	assert.False(backend.Exists(`people`, 1))
+	assert.True(backend.Exists(`people`, 4))
+}
@@ -0,0 +1,76 @@
+package backends
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter/generators"
+	"github.com/stretchr/testify/require"
+)
+
+// returns a backend and a collection with the given number of string columns (plus an ID), along
+// with a scan function that produces a row for them the way a driver would
+func wideScanFixture(width int) (*SqlBackend, *dal.Collection, []string, sqlScanFunc) {
+	backend := NewSqlBackend(dal.MustParseConnectionString(`sqlite://temporary`)).(*SqlBackend)
+	backend.queryGenTypeMapping = generators.SqliteTypeMapping
+
+	collection := dal.NewCollection(`wide`)
+	columns := []string{`id`}
+	row := []interface{}{int64(42)}
+
+	for i := 0; i < width; i++ {
+		name := fmt.Sprintf("field%d", i)
+
+		collection.AddFields(dal.Field{
+			Name: name,
+			Type: dal.StringType,
+		})
+
+		columns = append(columns, name)
+		row = append(row, []byte(fmt.Sprintf("value\x00%d", i%4)))
+	}
+
+	return backend, collection, columns, func(dest ...interface{}) error {
+		for i, value := range row {
+			*(dest[i].(*interface{})) = value
+		}
+
+		return nil
+	}
+}
+
+func TestSqlScanFnValueToRecord(t *testing.T) {
+	assert := require.New(t)
+	backend, collection, columns, scanFn := wideScanFixture(8)
+
+	plan := backend.newScanPlan(backend.makeQueryGen(collection), collection, columns, []string{`field1`, `field6`})
+	defer plan.Release()
+
+	for i := 0; i < 2; i++ {
+		record, err := backend.scanFnValueToRecord(plan, scanFn)
+		assert.NoError(err)
+		assert.EqualValues(42, record.ID)
+		assert.Equal(`value1`, record.Get(`field1`))
+		assert.Equal(`value2`, record.Get(`field6`))
+		assert.Nil(record.Get(`field0`))
+	}
+
+	// repeated values are only converted once
+	assert.Len(plan.interned, 2)
+}
+
+func BenchmarkSqlScanWideRows(b *testing.B) {
+	backend, collection, columns, scanFn := wideScanFixture(64)
+	plan := backend.newScanPlan(backend.makeQueryGen(collection), collection, columns, nil)
+	defer plan.Release()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := backend.scanFnValueToRecord(plan, scanFn); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
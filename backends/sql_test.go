@@ -47,7 +47,7 @@ package backends
 // 	assert.NoError(b.Migrate())
 
 // 	for _, delta := range want.Diff(have) {
-// 		stmt, _, err := b.generateAlterStatement(delta)
+// 		stmt, _, err := b.generateAlterStatement(have, delta)
 // 		assert.NoError(err)
 
 // 		// TODO: this is the wrong order, need to work out whats going on
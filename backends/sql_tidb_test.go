@@ -0,0 +1,104 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqlTidbDialect(t *testing.T) {
+	assert := require.New(t)
+
+	tidb := NewSqlBackend(dal.MustParseConnectionString(`tidb://root@db1/test`)).(*SqlBackend)
+	assert.Equal(`tidb`, tidb.queryGenTypeMapping.Name)
+	assert.Nil(tidb.searchIndexFunc)
+	assert.Nil(tidb.advisoryLockFunc)
+
+	name, dsn, err := initializeTidb(tidb)
+	assert.NoError(err)
+	assert.Equal(`mysql`, name)
+	assert.Equal(`root:@tcp(db1:4000)/test`, dsn)
+
+	// optimistic transaction conflicts are retried
+	assert.True(tidb.isRetryableErrorFunc(&mysql.MySQLError{Number: 9007}))
+	assert.False(tidb.isRetryableErrorFunc(&mysql.MySQLError{Number: 1050}))
+
+	// columns can be redefined, except for those that rows are keyed by
+	people := dal.NewCollection(`people`, dal.Field{
+		Name: `region`,
+		Type: dal.StringType,
+		Key:  true,
+	}, dal.Field{
+		Name: `age`,
+		Type: dal.StringType,
+	})
+
+	stmt, _, err := tidb.generateAlterStatement(people, &dal.SchemaDelta{
+		Type:       dal.FieldDelta,
+		Issue:      dal.FieldTypeIssue,
+		Collection: `people`,
+		Name:       `age`,
+		Parameter:  `Type`,
+		Desired:    dal.IntType,
+	})
+
+	assert.NoError(err)
+	assert.Equal("ALTER TABLE `people` MODIFY `age` BIGINT", stmt)
+
+	_, _, err = tidb.generateAlterStatement(people, &dal.SchemaDelta{
+		Type:       dal.FieldDelta,
+		Issue:      dal.FieldTypeIssue,
+		Collection: `people`,
+		Name:       `region`,
+		Parameter:  `Type`,
+		Desired:    dal.IntType,
+	})
+
+	assert.Error(err)
+}
+
+func TestSqlTidbTableOptions(t *testing.T) {
+	assert := require.New(t)
+
+	tidb := NewSqlBackend(dal.MustParseConnectionString(`tidb://localhost/test`)).(*SqlBackend)
+
+	people := dal.NewCollection(`people`, dal.Field{
+		Name: `region`,
+		Type: dal.StringType,
+		Key:  true,
+	})
+
+	options, err := tidb.tableOptionsFunc(people, tidb.makeQueryGen(people))
+	assert.NoError(err)
+	assert.Empty(options)
+
+	people.Placement = &dal.Placement{
+		Policy:       `eu`,
+		Partitioning: dal.HashPartitioning,
+		Partitions:   8,
+	}
+
+	options, err = tidb.tableOptionsFunc(people, tidb.makeQueryGen(people))
+	assert.NoError(err)
+	assert.Equal("PLACEMENT POLICY=`eu` PARTITION BY HASH(`id`) PARTITIONS 8", options)
+
+	people.Placement = &dal.Placement{
+		Partitioning:    dal.KeyPartitioning,
+		PartitionFields: []string{`id`, `region`},
+		Partitions:      4,
+	}
+
+	options, err = tidb.tableOptionsFunc(people, tidb.makeQueryGen(people))
+	assert.NoError(err)
+	assert.Equal("PARTITION BY KEY(`id`, `region`) PARTITIONS 4", options)
+	assert.Equal([]string{`id`, `region`}, people.Placement.PartitionFields)
+
+	// hash partitioning only works on integers
+	people.Placement.Partitioning = dal.HashPartitioning
+	people.Placement.PartitionFields = []string{`region`}
+
+	_, err = tidb.tableOptionsFunc(people, tidb.makeQueryGen(people))
+	assert.Error(err)
+}
@@ -0,0 +1,128 @@
+//go:build cgo
+// +build cgo
+
+package backends
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+// records which records were sent to the search index
+type sqlRecordingIndexer struct {
+	Indexer
+	indexed []interface{}
+	removed []interface{}
+}
+
+func (self *sqlRecordingIndexer) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	for _, record := range records.Records {
+		self.indexed = append(self.indexed, record.ID)
+	}
+
+	return nil
+}
+
+func (self *sqlRecordingIndexer) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	self.removed = append(self.removed, ids...)
+	return nil
+}
+
+func TestSqlTransactionIndexesAfterCommit(t *testing.T) {
+	assert := require.New(t)
+
+	backend := NewSqlBackend(dal.MustParseConnectionString(`sqlite://temporary`)).(*SqlBackend)
+	assert.NoError(backend.Initialize())
+
+	indexer := &sqlRecordingIndexer{}
+	backend.indexer = indexer
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`things`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	// writes that are rolled back never reach the index
+	assert.Error(backend.Transaction(func(tx Backend) error {
+		if err := tx.Insert(`things`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `one`))); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("nope")
+	}))
+
+	assert.False(backend.Exists(`things`, 1))
+	assert.Empty(indexer.indexed)
+
+	// ...and ones that are committed are indexed once the commit succeeds
+	assert.NoError(backend.Transaction(func(tx Backend) error {
+		if err := tx.Insert(`things`, dal.NewRecordSet(dal.NewRecord(2).Set(`name`, `two`))); err != nil {
+			return err
+		}
+
+		assert.Empty(indexer.indexed)
+		return nil
+	}))
+
+	assert.True(backend.Exists(`things`, 2))
+	assert.EqualValues([]interface{}{2}, indexer.indexed)
+
+	// the same goes for deletes
+	assert.Error(backend.Transaction(func(tx Backend) error {
+		if err := tx.Delete(`things`, 2); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("nope")
+	}))
+
+	assert.True(backend.Exists(`things`, 2))
+	assert.Empty(indexer.removed)
+
+	assert.NoError(backend.Delete(`things`, 2))
+	assert.False(backend.Exists(`things`, 2))
+	assert.EqualValues([]interface{}{2}, indexer.removed)
+}
+
+func TestSqlFormatRecordSetOncePerWrite(t *testing.T) {
+	assert := require.New(t)
+
+	backoff := SqlTransactionRetryBackoff
+	SqlTransactionRetryBackoff = 0
+	defer func() {
+		SqlTransactionRetryBackoff = backoff
+	}()
+
+	backend := NewSqlBackend(dal.MustParseConnectionString(`sqlite://temporary`)).(*SqlBackend)
+	assert.NoError(backend.Initialize())
+
+	var formatted int
+	var attempts int
+
+	collection := dal.NewCollection(`things`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	collection.PreSaveRecordSetFormatter = func(recordset *dal.RecordSet, isCreate bool) error {
+		formatted += 1
+		return nil
+	}
+
+	assert.NoError(backend.CreateCollection(collection))
+	assert.NoError(backend.Insert(`things`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `one`))))
+	assert.Equal(1, formatted)
+
+	// inserting a duplicate fails every time it's retried, but is only formatted once
+	backend.isRetryableErrorFunc = func(err error) bool {
+		attempts += 1
+		return true
+	}
+
+	assert.Error(backend.Insert(`things`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `one`))))
+	assert.Equal(SqlTransactionRetries+1, attempts)
+	assert.Equal(2, formatted)
+}
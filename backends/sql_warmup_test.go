@@ -0,0 +1,78 @@
+//go:build cgo
+// +build cgo
+
+package backends
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqlWarmupReusesPreparedStatements(t *testing.T) {
+	assert := require.New(t)
+
+	backend := NewSqlBackend(dal.MustParseConnectionString(`sqlite://temporary?warmup=true`)).(*SqlBackend)
+	assert.NoError(backend.Initialize())
+
+	for _, name := range []string{`things`, `shadow`} {
+		assert.NoError(backend.CreateCollection(dal.NewCollection(name, dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		})))
+	}
+
+	// collections created after startup are warmed up as they're registered
+	assert.True(backend.isWarm(`things`))
+	assert.NoError(backend.warmupCollection(`things`))
+
+	collection, err := backend.getCollectionFromCache(`things`)
+	assert.NoError(err)
+
+	queries, err := backend.warmupQueries(collection)
+	assert.NoError(err)
+	assert.NotEmpty(queries)
+
+	for _, query := range queries {
+		assert.NotNil(backend.preparedStatement(query), query)
+	}
+
+	// swap in statements that operate on another table, which will only be noticed if they're used
+	for _, query := range queries {
+		stmt, err := backend.db.Prepare(strings.Replace(query, `things`, `shadow`, -1))
+		assert.NoError(err)
+
+		backend.preparedStatement(query).Close()
+		backend.prepared.Store(query, &sqlPreparedStatement{
+			collection: `things`,
+			stmt:       stmt,
+		})
+	}
+
+	assert.NoError(backend.Insert(`things`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `one`))))
+
+	// the insert went through the prepared statement...
+	results, err := backend.Query(collection, filter.All())
+	assert.NoError(err)
+	assert.Empty(results.Records)
+
+	record, err := backend.Retrieve(`shadow`, 1)
+	assert.NoError(err)
+	assert.Equal(`one`, record.Get(`name`))
+
+	// ...and so does retrieving it
+	record, err = backend.Retrieve(`things`, 1)
+	assert.NoError(err)
+	assert.Equal(`one`, record.Get(`name`))
+
+	// statements are discarded along with their collection
+	assert.NoError(backend.DeleteCollection(`things`))
+	assert.False(backend.isWarm(`things`))
+
+	for _, query := range queries {
+		assert.Nil(backend.preparedStatement(query), query)
+	}
+}
@@ -0,0 +1,406 @@
+package backends
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// How long alert webhooks have to respond before the request is abandoned.
+var CountAlertWebhookTimeout = 10 * time.Second
+
+type StatsOptions struct {
+	// How often record counts are refreshed when scheduled with StatsRefresher.Start.
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// Conditions on record counts that are checked each time they're refreshed.
+	Alerts []CountAlert `json:"alerts,omitempty"`
+}
+
+// An alert that fires when a collection's record count, or how much it has changed over a window
+// of time, crosses a threshold.  Alerts are logged, and POSTed as a CountAlertEvent to the webhook
+// if one is given.  Each condition fires once when it is crossed, and again (as resolved) once it
+// no longer holds.
+type CountAlert struct {
+	// The collection to watch, or "*" (or empty) for all of them.
+	Collection string `json:"collection,omitempty"`
+
+	// Fire when the record count is greater than this.
+	Above *int64 `json:"above,omitempty"`
+
+	// Fire when the record count is less than this (e.g.: 1 to catch a collection being emptied).
+	Below *int64 `json:"below,omitempty"`
+
+	// Fire when the record count has grown by more than this over the window.
+	GrowthAbove *int64 `json:"growth_above,omitempty"`
+
+	// Fire when the record count has changed by less than this over the window (e.g.: -1000 to
+	// catch more than a thousand records being deleted).
+	GrowthBelow *int64 `json:"growth_below,omitempty"`
+
+	// The period that growth is measured over.  Defaults to the time since the previous refresh.
+	Window time.Duration `json:"window,omitempty"`
+
+	// A URL that events are POSTed to as JSON.
+	Webhook string `json:"webhook,omitempty"`
+}
+
+func (self CountAlert) appliesTo(collection string) bool {
+	switch self.Collection {
+	case ``, `*`:
+		return !strings.HasPrefix(collection, `__pivot_`)
+	default:
+		return (self.Collection == collection)
+	}
+}
+
+// Describes a CountAlert firing (or resolving) for a collection.
+type CountAlertEvent struct {
+	Collection string    `json:"collection"`
+	Condition  string    `json:"condition"`
+	Threshold  int64     `json:"threshold"`
+	Count      int64     `json:"count"`
+	Growth     int64     `json:"growth"`
+	Window     string    `json:"window,omitempty"`
+	Resolved   bool      `json:"resolved,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+func (self *CountAlertEvent) String() string {
+	var state = `fired`
+
+	if self.Resolved {
+		state = `resolved`
+	}
+
+	return fmt.Sprintf(
+		"%s: %s %d %s (count=%d growth=%d)",
+		self.Collection,
+		self.Condition,
+		self.Threshold,
+		state,
+		self.Count,
+		self.Growth,
+	)
+}
+
+// The most recently refreshed record count of a collection.
+type CollectionStats struct {
+	Collection string    `json:"collection"`
+	Count      int64     `json:"count"`
+	Change     int64     `json:"change"` // since the previous refresh
+	UpdatedAt  time.Time `json:"updated_at"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// an event to log, and the webhook (if any) to send it to
+type alertNotice struct {
+	event   *CountAlertEvent
+	webhook string
+}
+
+type countSample struct {
+	at    time.Time
+	count int64
+}
+
+// The StatsRefresher periodically counts the records in each collection, keeping the most recent
+// counts available without querying the backend, and checking them against the configured alerts
+// to catch runaway writers and unexpectedly emptied collections early.
+type StatsRefresher struct {
+	backend Backend
+	options StatsOptions
+	stats   map[string]*CollectionStats
+	samples map[string][]countSample
+	firing  map[string]bool
+	client  *http.Client
+	lock    sync.Mutex
+	stop    chan bool
+}
+
+func NewStatsRefresher(backend Backend, options StatsOptions) *StatsRefresher {
+	return &StatsRefresher{
+		backend: backend,
+		options: options,
+		stats:   make(map[string]*CollectionStats),
+		samples: make(map[string][]countSample),
+		firing:  make(map[string]bool),
+		client: &http.Client{
+			Timeout: CountAlertWebhookTimeout,
+		},
+	}
+}
+
+// Count the records in the given collections (or all collections if none are given), check the
+// counts against the configured alerts, and return the updated stats.
+func (self *StatsRefresher) Refresh(collections ...string) ([]CollectionStats, error) {
+	if len(collections) == 0 {
+		if names, err := self.backend.ListCollections(); err == nil {
+			collections = names
+		} else {
+			return nil, err
+		}
+	}
+
+	var refreshed = make([]CollectionStats, 0)
+
+	for _, name := range collections {
+		var stats = CollectionStats{
+			Collection: name,
+			UpdatedAt:  time.Now(),
+		}
+
+		if collection, err := self.backend.GetCollection(name); err == nil {
+			if count, err := countRecords(self.backend, collection); err == nil {
+				stats.Count = count
+			} else {
+				stats.Error = err.Error()
+			}
+		} else {
+			stats.Error = err.Error()
+		}
+
+		// alerts are sent outside of the lock so that slow webhooks don't hold up readers
+		if stats.Error == `` {
+			for _, notice := range self.record(&stats) {
+				self.notify(notice)
+			}
+		}
+
+		refreshed = append(refreshed, stats)
+	}
+
+	return refreshed, nil
+}
+
+// Return the most recently refreshed stats for all collections, sorted by name.
+func (self *StatsRefresher) Stats() []CollectionStats {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	var out = make([]CollectionStats, 0, len(self.stats))
+
+	for _, stats := range self.stats {
+		out = append(out, *stats)
+	}
+
+	sort.Slice(out, func(i int, j int) bool {
+		return out[i].Collection < out[j].Collection
+	})
+
+	return out
+}
+
+// Start refreshing stats in the background at the configured interval.
+func (self *StatsRefresher) Start() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.options.Interval <= 0 {
+		return fmt.Errorf("must specify an interval to schedule stats refreshes")
+	} else if self.stop != nil {
+		return nil
+	}
+
+	self.stop = make(chan bool)
+
+	go func(stop chan bool) {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(self.options.Interval):
+				if refreshed, err := self.Refresh(); err == nil {
+					for _, stats := range refreshed {
+						if stats.Error != `` {
+							log.Warningf("[%v] failed to count records in %q: %v", self.backend, stats.Collection, stats.Error)
+						}
+					}
+				} else {
+					log.Warningf("[%v] stats refresh failed: %v", self.backend, err)
+				}
+			}
+		}
+	}(self.stop)
+
+	return nil
+}
+
+// Stop refreshing stats in the background.
+func (self *StatsRefresher) Stop() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.stop != nil {
+		close(self.stop)
+		self.stop = nil
+	}
+}
+
+// stores the given stats and returns the alert events that the new count causes
+func (self *StatsRefresher) record(stats *CollectionStats) []alertNotice {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	var name = stats.Collection
+	var samples = self.samples[name]
+	var notices = make([]alertNotice, 0)
+
+	if len(samples) > 0 {
+		stats.Change = stats.Count - samples[len(samples)-1].count
+	}
+
+	for i, alert := range self.options.Alerts {
+		if !alert.appliesTo(name) {
+			continue
+		}
+
+		var growth int64
+		var hasGrowth bool
+
+		if alert.Window > 0 {
+			// compare against the most recent count taken at least a window ago
+			for j := len(samples) - 1; j >= 0; j-- {
+				if stats.UpdatedAt.Sub(samples[j].at) >= alert.Window {
+					growth = stats.Count - samples[j].count
+					hasGrowth = true
+					break
+				}
+			}
+		} else if len(samples) > 0 {
+			growth = stats.Change
+			hasGrowth = true
+		}
+
+		for _, condition := range []struct {
+			name      string
+			threshold *int64
+			value     int64
+			check     bool
+			above     bool
+		}{
+			{`above`, alert.Above, stats.Count, true, true},
+			{`below`, alert.Below, stats.Count, true, false},
+			{`growth_above`, alert.GrowthAbove, growth, hasGrowth, true},
+			{`growth_below`, alert.GrowthBelow, growth, hasGrowth, false},
+		} {
+			if condition.threshold == nil || !condition.check {
+				continue
+			}
+
+			var key = fmt.Sprintf("%d:%s:%s", i, name, condition.name)
+			var crossed bool
+
+			if condition.above {
+				crossed = (condition.value > *condition.threshold)
+			} else {
+				crossed = (condition.value < *condition.threshold)
+			}
+
+			if crossed != self.firing[key] {
+				var event = &CountAlertEvent{
+					Collection: name,
+					Condition:  condition.name,
+					Threshold:  *condition.threshold,
+					Count:      stats.Count,
+					Growth:     growth,
+					Resolved:   !crossed,
+					At:         stats.UpdatedAt,
+				}
+
+				if alert.Window > 0 {
+					event.Window = alert.Window.String()
+				}
+
+				self.firing[key] = crossed
+				notices = append(notices, alertNotice{
+					event:   event,
+					webhook: alert.Webhook,
+				})
+			}
+		}
+	}
+
+	// keep only as many samples as the longest window needs
+	var longest time.Duration
+
+	for _, alert := range self.options.Alerts {
+		if alert.Window > longest {
+			longest = alert.Window
+		}
+	}
+
+	samples = append(samples, countSample{
+		at:    stats.UpdatedAt,
+		count: stats.Count,
+	})
+
+	for len(samples) > 1 && stats.UpdatedAt.Sub(samples[1].at) >= longest {
+		samples = samples[1:]
+	}
+
+	var stored = *stats
+
+	self.samples[name] = samples
+	self.stats[name] = &stored
+
+	return notices
+}
+
+func (self *StatsRefresher) notify(notice alertNotice) {
+	if notice.event.Resolved {
+		log.Infof("[%v] record count alert %v", self.backend, notice.event)
+	} else {
+		log.Warningf("[%v] record count alert %v", self.backend, notice.event)
+	}
+
+	if notice.webhook == `` {
+		return
+	}
+
+	if data, err := json.Marshal(notice.event); err == nil {
+		if res, err := self.client.Post(notice.webhook, `application/json`, bytes.NewReader(data)); err == nil {
+			res.Body.Close()
+
+			if res.StatusCode >= 400 {
+				log.Warningf("[%v] alert webhook %v responded with HTTP %d", self.backend, notice.webhook, res.StatusCode)
+			}
+		} else {
+			log.Warningf("[%v] alert webhook %v failed: %v", self.backend, notice.webhook, err)
+		}
+	} else {
+		log.Warningf("[%v] failed to encode alert: %v", self.backend, err)
+	}
+}
+
+// returns the number of records in the given collection, counted by its aggregator if it has one
+func countRecords(backend Backend, collection *dal.Collection) (int64, error) {
+	if aggregator := backend.WithAggregator(collection); aggregator != nil {
+		count, err := aggregator.Count(collection, filter.All())
+		return int64(count), err
+	} else if search := backend.WithSearch(collection); search != nil {
+		var count int64
+
+		err := search.QueryFunc(collection, filter.All(), func(_ *dal.Record, err error, _ IndexPage) error {
+			if err != nil {
+				return err
+			}
+
+			count += 1
+			return nil
+		})
+
+		return count, err
+	} else {
+		return 0, fmt.Errorf("backend %v cannot count the records in %q", backend, collection.Name)
+	}
+}
@@ -0,0 +1,195 @@
+package backends_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsRefresherAlerts(t *testing.T) {
+	assert := require.New(t)
+
+	var lock sync.Mutex
+	var events []backends.CountAlertEvent
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var event backends.CountAlertEvent
+
+		assert.NoError(json.NewDecoder(req.Body).Decode(&event))
+
+		lock.Lock()
+		events = append(events, event)
+		lock.Unlock()
+	}))
+
+	defer webhook.Close()
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`things`)))
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`others`)))
+
+	one := int64(1)
+	three := int64(3)
+	two := int64(2)
+
+	refresher := backends.NewStatsRefresher(backend, backends.StatsOptions{
+		Alerts: []backends.CountAlert{
+			{
+				Collection: `things`,
+				Above:      &three,
+				Below:      &one,
+				Webhook:    webhook.URL,
+			}, {
+				Collection:  `*`,
+				GrowthAbove: &two,
+				Webhook:     webhook.URL,
+			},
+		},
+	})
+
+	received := func() []backends.CountAlertEvent {
+		lock.Lock()
+		defer lock.Unlock()
+
+		out := events
+		events = nil
+		return out
+	}
+
+	insert := func(ids ...int) {
+		for _, id := range ids {
+			assert.NoError(backend.Insert(`things`, dal.NewRecordSet(dal.NewRecord(id))))
+		}
+	}
+
+	// an empty collection is below the threshold
+	stats, err := refresher.Refresh(`things`)
+	assert.NoError(err)
+	assert.Len(stats, 1)
+	assert.EqualValues(0, stats[0].Count)
+
+	fired := received()
+	assert.Len(fired, 1)
+	assert.Equal(`things`, fired[0].Collection)
+	assert.Equal(`below`, fired[0].Condition)
+	assert.False(fired[0].Resolved)
+
+	// alerts fire once, not on every refresh
+	_, err = refresher.Refresh(`things`)
+	assert.NoError(err)
+	assert.Empty(received())
+
+	// the count recovers, and grows by more than the growth threshold
+	insert(1, 2, 3)
+
+	stats, err = refresher.Refresh(`things`)
+	assert.NoError(err)
+	assert.EqualValues(3, stats[0].Count)
+	assert.EqualValues(3, stats[0].Change)
+
+	fired = received()
+	assert.Len(fired, 2)
+	assert.Equal(`below`, fired[0].Condition)
+	assert.True(fired[0].Resolved)
+	assert.Equal(`growth_above`, fired[1].Condition)
+	assert.EqualValues(3, fired[1].Growth)
+
+	insert(4)
+
+	_, err = refresher.Refresh(`things`)
+	assert.NoError(err)
+
+	fired = received()
+	assert.Len(fired, 2)
+	assert.Equal(`above`, fired[0].Condition)
+	assert.EqualValues(4, fired[0].Count)
+	assert.Equal(`growth_above`, fired[1].Condition)
+	assert.True(fired[1].Resolved)
+
+	// wildcard alerts apply to every collection
+	_, err = refresher.Refresh()
+	assert.NoError(err)
+	assert.Empty(received())
+
+	latest := refresher.Stats()
+	assert.Len(latest, 2)
+	assert.Equal(`others`, latest[0].Collection)
+	assert.Equal(`things`, latest[1].Collection)
+	assert.EqualValues(4, latest[1].Count)
+}
+
+func TestStatsRefresherGrowthWindow(t *testing.T) {
+	assert := require.New(t)
+
+	var lock sync.Mutex
+	var fired []backends.CountAlertEvent
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var event backends.CountAlertEvent
+
+		assert.NoError(json.NewDecoder(req.Body).Decode(&event))
+
+		lock.Lock()
+		fired = append(fired, event)
+		lock.Unlock()
+	}))
+
+	defer webhook.Close()
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`things`)))
+
+	shrink := int64(-1)
+
+	refresher := backends.NewStatsRefresher(backend, backends.StatsOptions{
+		Alerts: []backends.CountAlert{
+			{
+				GrowthBelow: &shrink,
+				Window:      50 * time.Millisecond,
+				Webhook:     webhook.URL,
+			},
+		},
+	})
+
+	assert.NoError(backend.Insert(`things`, dal.NewRecordSet(dal.NewRecord(1), dal.NewRecord(2), dal.NewRecord(3))))
+
+	_, err := refresher.Refresh()
+	assert.NoError(err)
+
+	// growth isn't measured until a full window has passed
+	assert.NoError(backend.Delete(`things`, 1))
+
+	stats, err := refresher.Refresh()
+	assert.NoError(err)
+	assert.EqualValues(-1, stats[0].Change)
+
+	time.Sleep(60 * time.Millisecond)
+
+	// ...after which it is compared against the most recent count from at least a window ago
+	assert.NoError(backend.Delete(`things`, 2, 3))
+
+	stats, err = refresher.Refresh()
+	assert.NoError(err)
+	assert.EqualValues(-2, stats[0].Change)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	assert.Len(fired, 1)
+	assert.Equal(`growth_below`, fired[0].Condition)
+	assert.EqualValues(-2, fired[0].Growth)
+	assert.Equal(`50ms`, fired[0].Window)
+
+	// starting without an interval is an error
+	assert.Error(refresher.Start())
+}
@@ -0,0 +1,354 @@
+package backends
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// Returned by operations that did not complete within their deadline.
+type TimeoutError struct {
+	Operation  string        `json:"operation"`
+	Collection string        `json:"collection,omitempty"`
+	Timeout    time.Duration `json:"timeout"`
+}
+
+func (self *TimeoutError) Error() string {
+	if self.Collection != `` {
+		return fmt.Sprintf("%s on collection %q timed out after %v", self.Operation, self.Collection, self.Timeout)
+	} else {
+		return fmt.Sprintf("%s timed out after %v", self.Operation, self.Timeout)
+	}
+}
+
+// Always true; allows timeouts to be detected by interface rather than by type.
+func (self *TimeoutError) IsTimeout() bool {
+	return true
+}
+
+// Returns whether the given error is (or wraps) a TimeoutError.
+func IsTimeoutError(err error) bool {
+	for err != nil {
+		if _, ok := err.(*TimeoutError); ok {
+			return true
+		} else if wrapper, ok := err.(interface{ Unwrap() error }); ok {
+			err = wrapper.Unwrap()
+		} else {
+			break
+		}
+	}
+
+	return false
+}
+
+// The TimeoutBackend wraps another backend, placing a deadline on every read and write operation
+// performed through it.  Operations that don't complete in time return a TimeoutError to the
+// caller.  Reads and writes made against a ContextBackend are cancelled when their deadline
+// passes; other operations (and those against other backends) are abandoned rather than
+// cancelled, and their eventual result is discarded.
+type TimeoutBackend struct {
+	Backend
+	queryTimeout time.Duration
+	writeTimeout time.Duration
+}
+
+// Wrap the given backend, applying queryTimeout to reads, queries, and aggregations, and
+// writeTimeout to inserts, updates, and deletes.  A timeout of zero disables the deadline for
+// that class of operation.
+func NewTimeoutBackend(parent Backend, queryTimeout time.Duration, writeTimeout time.Duration) *TimeoutBackend {
+	return &TimeoutBackend{
+		Backend:      parent,
+		queryTimeout: queryTimeout,
+		writeTimeout: writeTimeout,
+	}
+}
+
+// Return the backend being wrapped.
+func (self *TimeoutBackend) GetBackend() Backend {
+	return self.Backend
+}
+
+// Runs fn in a transaction on the wrapped backend.  Operations made within the transaction have the
+// same deadlines as any other, but the transaction as a whole does not.
+func (self *TimeoutBackend) Transaction(fn func(tx Backend) error) error {
+	return Transaction(self.Backend, func(tx Backend) error {
+		return fn(NewTimeoutBackend(tx, self.queryTimeout, self.writeTimeout))
+	})
+}
+
+func (self *TimeoutBackend) Exists(collection string, id interface{}) bool {
+	var exists bool
+
+	if err := withTimeout(`exists`, collection, self.queryTimeout, func(_ context.Context) error {
+		exists = self.Backend.Exists(collection, id)
+		return nil
+	}); err != nil {
+		return false
+	}
+
+	return exists
+}
+
+func (self *TimeoutBackend) Retrieve(collection string, id interface{}, fields ...string) (*dal.Record, error) {
+	var record *dal.Record
+
+	err := withTimeout(`retrieve`, collection, self.queryTimeout, func(ctx context.Context) error {
+		var err error
+		record, err = RetrieveContext(ctx, self.Backend, collection, id, fields...)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func (self *TimeoutBackend) Insert(collection string, records *dal.RecordSet) error {
+	return withTimeout(`insert`, collection, self.writeTimeout, func(ctx context.Context) error {
+		return InsertContext(ctx, self.Backend, collection, records)
+	})
+}
+
+func (self *TimeoutBackend) Update(collection string, records *dal.RecordSet, target ...string) error {
+	return withTimeout(`update`, collection, self.writeTimeout, func(ctx context.Context) error {
+		return UpdateContext(ctx, self.Backend, collection, records, target...)
+	})
+}
+
+func (self *TimeoutBackend) Delete(collection string, ids ...interface{}) error {
+	return withTimeout(`delete`, collection, self.writeTimeout, func(ctx context.Context) error {
+		return DeleteContext(ctx, self.Backend, collection, ids...)
+	})
+}
+
+func (self *TimeoutBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	if search := self.Backend.WithSearch(collection, filters...); search != nil {
+		return &timeoutIndexer{
+			Indexer: search,
+			backend: self,
+		}
+	}
+
+	return nil
+}
+
+func (self *TimeoutBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	if aggregator := self.Backend.WithAggregator(collection); aggregator != nil {
+		var wrapped = &timeoutAggregator{
+			Aggregator: aggregator,
+			backend:    self,
+		}
+
+		// preserve the ability to count by several fields at once, if the aggregator has it
+		if counter, ok := aggregator.(GroupCounter); ok {
+			return &timeoutGroupCounter{
+				timeoutAggregator: wrapped,
+				counter:           counter,
+			}
+		}
+
+		return wrapped
+	}
+
+	return nil
+}
+
+// runs the given function, returning a TimeoutError if it has not completed by the time the
+// timeout elapses.  The function is given a context carrying the deadline.
+func withTimeout(operation string, collection string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return fn(context.Background())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var result = make(chan error, 1)
+
+	go func() {
+		result <- fn(ctx)
+	}()
+
+	var timeoutErr = &TimeoutError{
+		Operation:  operation,
+		Collection: collection,
+		Timeout:    timeout,
+	}
+
+	select {
+	case err := <-result:
+		// operations that were cancelled by the deadline fail with whatever error the backend
+		// reports, which is replaced by the TimeoutError
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			return timeoutErr
+		}
+
+		return err
+	case <-ctx.Done():
+		return timeoutErr
+	}
+}
+
+type timeoutIndexer struct {
+	Indexer
+	backend *TimeoutBackend
+}
+
+func (self *timeoutIndexer) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	return DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+// Queries are run in the background while results are passed back to resultFn.  Once the
+// deadline passes, no further results are passed along, so resultFn is never called after
+// QueryFunc returns.
+func (self *timeoutIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	var lock sync.Mutex
+	var expired bool
+
+	err := withTimeout(`query`, collection.Name, self.backend.queryTimeout, func(ctx context.Context) error {
+		return self.Indexer.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+			lock.Lock()
+			defer lock.Unlock()
+
+			if expired {
+				return ctx.Err()
+			}
+
+			return resultFn(record, err, page)
+		})
+	})
+
+	if IsTimeoutError(err) {
+		lock.Lock()
+		expired = true
+		lock.Unlock()
+	}
+
+	return err
+}
+
+func (self *timeoutIndexer) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	var values map[string][]interface{}
+
+	err := withTimeout(`list values`, collection.Name, self.backend.queryTimeout, func(_ context.Context) error {
+		var err error
+		values, err = self.Indexer.ListValues(collection, fields, f)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (self *timeoutIndexer) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	return withTimeout(`delete query`, collection.Name, self.backend.writeTimeout, func(_ context.Context) error {
+		return self.Indexer.DeleteQuery(collection, f)
+	})
+}
+
+type timeoutAggregator struct {
+	Aggregator
+	backend *TimeoutBackend
+}
+
+func (self *timeoutAggregator) Sum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(`sum`, collection, func() (float64, error) {
+		return self.Aggregator.Sum(collection, field, f...)
+	})
+}
+
+func (self *timeoutAggregator) Count(collection *dal.Collection, f ...*filter.Filter) (uint64, error) {
+	var count uint64
+
+	err := withTimeout(`count`, collection.Name, self.backend.queryTimeout, func(_ context.Context) error {
+		var err error
+		count, err = self.Aggregator.Count(collection, f...)
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (self *timeoutAggregator) Minimum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(`minimum`, collection, func() (float64, error) {
+		return self.Aggregator.Minimum(collection, field, f...)
+	})
+}
+
+func (self *timeoutAggregator) Maximum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(`maximum`, collection, func() (float64, error) {
+		return self.Aggregator.Maximum(collection, field, f...)
+	})
+}
+
+func (self *timeoutAggregator) Average(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(`average`, collection, func() (float64, error) {
+		return self.Aggregator.Average(collection, field, f...)
+	})
+}
+
+func (self *timeoutAggregator) GroupBy(collection *dal.Collection, fields []string, aggregates []filter.Aggregate, f ...*filter.Filter) (*dal.RecordSet, error) {
+	var recordset *dal.RecordSet
+
+	err := withTimeout(`group by`, collection.Name, self.backend.queryTimeout, func(_ context.Context) error {
+		var err error
+		recordset, err = self.Aggregator.GroupBy(collection, fields, aggregates, f...)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return recordset, nil
+}
+
+func (self *timeoutAggregator) aggregateFloat(operation string, collection *dal.Collection, fn func() (float64, error)) (float64, error) {
+	var value float64
+
+	err := withTimeout(operation, collection.Name, self.backend.queryTimeout, func(_ context.Context) error {
+		var err error
+		value, err = fn()
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return value, nil
+}
+
+type timeoutGroupCounter struct {
+	*timeoutAggregator
+	counter GroupCounter
+}
+
+func (self *timeoutGroupCounter) CountBy(collection *dal.Collection, fields []string, f ...*filter.Filter) ([]GroupCount, error) {
+	var groups []GroupCount
+
+	err := withTimeout(`count by`, collection.Name, self.backend.queryTimeout, func(_ context.Context) error {
+		var err error
+		groups, err = self.counter.CountBy(collection, fields, f...)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
@@ -0,0 +1,88 @@
+package backends_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// a backend whose writes and queries take a fixed amount of time to complete
+type slowBackend struct {
+	*spi.Adapter
+	delay time.Duration
+}
+
+func (self *slowBackend) Insert(collection string, records *dal.RecordSet) error {
+	time.Sleep(self.delay)
+	return self.Adapter.Insert(collection, records)
+}
+
+func (self *slowBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) backends.Indexer {
+	return &slowIndexer{
+		Indexer: self.Adapter,
+		delay:   self.delay,
+	}
+}
+
+type slowIndexer struct {
+	backends.Indexer
+	delay time.Duration
+}
+
+func (self *slowIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn backends.IndexResultFunc) error {
+	time.Sleep(self.delay)
+	return self.Indexer.QueryFunc(collection, f, resultFn)
+}
+
+func TestTimeoutBackend(t *testing.T) {
+	assert := require.New(t)
+
+	slow := &slowBackend{
+		Adapter: spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver()),
+	}
+
+	assert.NoError(slow.CreateCollection(dal.NewCollection(`things`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	collection, err := slow.GetCollection(`things`)
+	assert.NoError(err)
+
+	backend := backends.NewTimeoutBackend(slow, 50*time.Millisecond, 50*time.Millisecond)
+
+	// operations that finish in time are unaffected
+	assert.NoError(backend.Insert(`things`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `one`))))
+
+	recordset, err := backend.WithSearch(collection).Query(collection, filter.All())
+	assert.NoError(err)
+	assert.Len(recordset.Records, 1)
+
+	// operations that don't are reported as timeouts
+	slow.delay = 200 * time.Millisecond
+
+	err = backend.Insert(`things`, dal.NewRecordSet(dal.NewRecord(2).Set(`name`, `two`)))
+	assert.Error(err)
+	assert.True(backends.IsTimeoutError(err))
+
+	timeout, ok := err.(*backends.TimeoutError)
+	assert.True(ok)
+	assert.Equal(`insert`, timeout.Operation)
+	assert.Equal(`things`, timeout.Collection)
+
+	_, err = backend.WithSearch(collection).Query(collection, filter.All())
+	assert.True(backends.IsTimeoutError(err))
+
+	// reads aren't subject to the write timeout
+	backend = backends.NewTimeoutBackend(slow, 0, 50*time.Millisecond)
+
+	recordset, err = backend.WithSearch(collection).Query(collection, filter.All())
+	assert.NoError(err)
+	assert.NotEmpty(recordset.Records)
+}
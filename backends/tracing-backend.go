@@ -0,0 +1,385 @@
+package backends
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The name pivot's tracer is requested from the global OpenTelemetry tracer provider with.
+var TracerName = `github.com/ghetzel/pivot/v3`
+
+// The TracingBackend wraps another backend, recording an OpenTelemetry span for every read, write,
+// query, and aggregation performed through it.  Spans are children of whatever span is present in
+// the given context (for example, the one started for an incoming HTTP request), and carry the
+// backend type, collection name, operation, and number of records involved as attributes.  Spans
+// are exported by the global tracer provider, which is a no-op unless the application configures
+// one with otel.SetTracerProvider.
+//
+// Operations in the Backend interface don't accept a context of their own, so a TracingBackend is
+// scoped to a single context; use WithContext to trace operations performed on behalf of another
+// one.
+type TracingBackend struct {
+	Backend
+	ctx context.Context
+}
+
+func NewTracingBackend(parent Backend, ctx context.Context) *TracingBackend {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return &TracingBackend{
+		Backend: parent,
+		ctx:     ctx,
+	}
+}
+
+// Return the backend being wrapped.
+func (self *TracingBackend) GetBackend() Backend {
+	return self.Backend
+}
+
+// Return a copy of this backend whose spans are children of the given context.
+func (self *TracingBackend) WithContext(ctx context.Context) *TracingBackend {
+	return NewTracingBackend(self.Backend, ctx)
+}
+
+// Runs fn in a transaction on the wrapped backend, tracing the operations made within it.
+func (self *TracingBackend) Transaction(fn func(tx Backend) error) error {
+	return Transaction(self.Backend, func(tx Backend) error {
+		return fn(NewTracingBackend(tx, self.ctx))
+	})
+}
+
+func (self *TracingBackend) Exists(collection string, id interface{}) bool {
+	var exists bool
+
+	self.trace(`exists`, collection, func(span trace.Span) error {
+		exists = self.Backend.Exists(collection, id)
+		span.SetAttributes(attribute.Bool(`pivot.exists`, exists))
+		return nil
+	})
+
+	return exists
+}
+
+func (self *TracingBackend) Retrieve(collection string, id interface{}, fields ...string) (*dal.Record, error) {
+	var record *dal.Record
+
+	err := self.trace(`retrieve`, collection, func(span trace.Span) error {
+		var err error
+
+		if record, err = self.Backend.Retrieve(collection, id, fields...); err == nil {
+			span.SetAttributes(attribute.Int(`pivot.record_count`, 1))
+		}
+
+		return err
+	})
+
+	return record, err
+}
+
+func (self *TracingBackend) Insert(collection string, records *dal.RecordSet) error {
+	return self.trace(`insert`, collection, func(span trace.Span) error {
+		span.SetAttributes(attribute.Int(`pivot.record_count`, recordCount(records)))
+		return self.Backend.Insert(collection, records)
+	})
+}
+
+func (self *TracingBackend) Update(collection string, records *dal.RecordSet, target ...string) error {
+	return self.trace(`update`, collection, func(span trace.Span) error {
+		span.SetAttributes(attribute.Int(`pivot.record_count`, recordCount(records)))
+		return self.Backend.Update(collection, records, target...)
+	})
+}
+
+func (self *TracingBackend) Delete(collection string, ids ...interface{}) error {
+	return self.trace(`delete`, collection, func(span trace.Span) error {
+		span.SetAttributes(attribute.Int(`pivot.record_count`, len(ids)))
+		return self.Backend.Delete(collection, ids...)
+	})
+}
+
+func (self *TracingBackend) CreateCollection(definition *dal.Collection) error {
+	return self.trace(`create collection`, definition.Name, func(_ trace.Span) error {
+		return self.Backend.CreateCollection(definition)
+	})
+}
+
+func (self *TracingBackend) DeleteCollection(collection string) error {
+	return self.trace(`delete collection`, collection, func(_ trace.Span) error {
+		return self.Backend.DeleteCollection(collection)
+	})
+}
+
+func (self *TracingBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	if search := self.Backend.WithSearch(collection, filters...); search != nil {
+		return &tracingIndexer{
+			Indexer: search,
+			backend: self,
+		}
+	}
+
+	return nil
+}
+
+func (self *TracingBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	if aggregator := self.Backend.WithAggregator(collection); aggregator != nil {
+		var wrapped = &tracingAggregator{
+			Aggregator: aggregator,
+			backend:    self,
+		}
+
+		// preserve the ability to count by several fields at once, if the aggregator has it
+		if counter, ok := aggregator.(GroupCounter); ok {
+			return &tracingGroupCounter{
+				tracingAggregator: wrapped,
+				counter:           counter,
+			}
+		}
+
+		return wrapped
+	}
+
+	return nil
+}
+
+func (self *TracingBackend) trace(operation string, collection string, fn func(span trace.Span) error) error {
+	return traceOperation(self.ctx, self.Backend.GetConnectionString(), operation, collection, fn)
+}
+
+// runs the given function inside of a new span named for the operation and collection.  Errors
+// returned by the function are recorded on the span.
+func traceOperation(ctx context.Context, cs *dal.ConnectionString, operation string, collection string, fn func(span trace.Span) error) error {
+	var attrs = []attribute.KeyValue{
+		attribute.String(`pivot.operation`, operation),
+	}
+
+	if cs != nil {
+		attrs = append(attrs, attribute.String(`db.system`, cs.Backend()))
+	}
+
+	var name = `pivot ` + operation
+
+	if collection != `` {
+		name += ` ` + collection
+		attrs = append(attrs, attribute.String(`pivot.collection`, collection))
+	}
+
+	_, span := otel.Tracer(TracerName).Start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+
+	defer span.End()
+
+	err := fn(span)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+func recordCount(records *dal.RecordSet) int {
+	if records == nil {
+		return 0
+	}
+
+	return len(records.Records)
+}
+
+type tracingIndexer struct {
+	Indexer
+	backend *TracingBackend
+}
+
+func (self *tracingIndexer) trace(operation string, collection *dal.Collection, fn func(span trace.Span) error) error {
+	var name string
+
+	if collection != nil {
+		name = collection.Name
+	}
+
+	return traceOperation(self.backend.ctx, self.Indexer.IndexConnectionString(), operation, name, fn)
+}
+
+func (self *tracingIndexer) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	var record *dal.Record
+
+	err := self.trace(`index retrieve`, collection, func(span trace.Span) error {
+		var err error
+
+		if record, err = self.Indexer.IndexRetrieve(collection, id); err == nil {
+			span.SetAttributes(attribute.Int(`pivot.record_count`, 1))
+		}
+
+		return err
+	})
+
+	return record, err
+}
+
+func (self *tracingIndexer) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return self.trace(`index remove`, collection, func(span trace.Span) error {
+		span.SetAttributes(attribute.Int(`pivot.record_count`, len(ids)))
+		return self.Indexer.IndexRemove(collection, ids)
+	})
+}
+
+func (self *tracingIndexer) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return self.trace(`index`, collection, func(span trace.Span) error {
+		span.SetAttributes(attribute.Int(`pivot.record_count`, recordCount(records)))
+		return self.Indexer.Index(collection, records)
+	})
+}
+
+func (self *tracingIndexer) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	return DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+func (self *tracingIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	return self.trace(`query`, collection, func(span trace.Span) error {
+		var count int
+
+		if f != nil {
+			span.SetAttributes(attribute.String(`pivot.filter`, f.String()))
+		}
+
+		err := self.Indexer.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+			if err == nil && record != nil {
+				count += 1
+			}
+
+			return resultFn(record, err, page)
+		})
+
+		span.SetAttributes(attribute.Int(`pivot.record_count`, count))
+		return err
+	})
+}
+
+func (self *tracingIndexer) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	var values map[string][]interface{}
+
+	err := self.trace(`list values`, collection, func(span trace.Span) error {
+		var err error
+
+		span.SetAttributes(attribute.String(`pivot.fields`, strings.Join(fields, `,`)))
+		values, err = self.Indexer.ListValues(collection, fields, f)
+		return err
+	})
+
+	return values, err
+}
+
+func (self *tracingIndexer) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	return self.trace(`delete query`, collection, func(span trace.Span) error {
+		if f != nil {
+			span.SetAttributes(attribute.String(`pivot.filter`, f.String()))
+		}
+
+		return self.Indexer.DeleteQuery(collection, f)
+	})
+}
+
+type tracingAggregator struct {
+	Aggregator
+	backend *TracingBackend
+}
+
+func (self *tracingAggregator) trace(operation string, collection *dal.Collection, fn func(span trace.Span) error) error {
+	return traceOperation(self.backend.ctx, self.Aggregator.AggregatorConnectionString(), operation, collection.Name, fn)
+}
+
+func (self *tracingAggregator) Sum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(`sum`, collection, func() (float64, error) {
+		return self.Aggregator.Sum(collection, field, f...)
+	})
+}
+
+func (self *tracingAggregator) Count(collection *dal.Collection, f ...*filter.Filter) (uint64, error) {
+	var count uint64
+
+	err := self.trace(`count`, collection, func(_ trace.Span) error {
+		var err error
+		count, err = self.Aggregator.Count(collection, f...)
+		return err
+	})
+
+	return count, err
+}
+
+func (self *tracingAggregator) Minimum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(`minimum`, collection, func() (float64, error) {
+		return self.Aggregator.Minimum(collection, field, f...)
+	})
+}
+
+func (self *tracingAggregator) Maximum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(`maximum`, collection, func() (float64, error) {
+		return self.Aggregator.Maximum(collection, field, f...)
+	})
+}
+
+func (self *tracingAggregator) Average(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(`average`, collection, func() (float64, error) {
+		return self.Aggregator.Average(collection, field, f...)
+	})
+}
+
+func (self *tracingAggregator) GroupBy(collection *dal.Collection, fields []string, aggregates []filter.Aggregate, f ...*filter.Filter) (*dal.RecordSet, error) {
+	var recordset *dal.RecordSet
+
+	err := self.trace(`group by`, collection, func(span trace.Span) error {
+		var err error
+
+		if recordset, err = self.Aggregator.GroupBy(collection, fields, aggregates, f...); err == nil {
+			span.SetAttributes(attribute.Int(`pivot.record_count`, recordCount(recordset)))
+		}
+
+		return err
+	})
+
+	return recordset, err
+}
+
+func (self *tracingAggregator) aggregateFloat(operation string, collection *dal.Collection, fn func() (float64, error)) (float64, error) {
+	var value float64
+
+	err := self.trace(operation, collection, func(_ trace.Span) error {
+		var err error
+		value, err = fn()
+		return err
+	})
+
+	return value, err
+}
+
+type tracingGroupCounter struct {
+	*tracingAggregator
+	counter GroupCounter
+}
+
+func (self *tracingGroupCounter) CountBy(collection *dal.Collection, fields []string, f ...*filter.Filter) ([]GroupCount, error) {
+	var groups []GroupCount
+
+	err := self.trace(`count by`, collection, func(_ trace.Span) error {
+		var err error
+		groups, err = self.counter.CountBy(collection, fields, f...)
+		return err
+	})
+
+	return groups, err
+}
@@ -0,0 +1,88 @@
+package backends_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingBackend(t *testing.T) {
+	assert := require.New(t)
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	ctx, parent := otel.Tracer(`test`).Start(context.Background(), `request`)
+
+	backend := backends.NewTracingBackend(
+		spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver()),
+		ctx,
+	)
+
+	collection := dal.NewCollection(`traced`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	assert.NoError(backend.CreateCollection(collection))
+	assert.NoError(backend.Insert(`traced`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `one`),
+		dal.NewRecord(2).Set(`name`, `two`),
+	)))
+
+	rs, err := backend.WithSearch(collection).Query(collection, filter.All())
+	assert.NoError(err)
+	assert.EqualValues(2, rs.ResultCount)
+
+	_, err = backend.Retrieve(`traced`, 3)
+	assert.Error(err)
+
+	parent.End()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+
+		if span.Name() != `request` {
+			assert.Equal(parent.SpanContext().SpanID(), span.Parent().SpanID())
+		}
+	}
+
+	assert.Contains(spans, `pivot create collection traced`)
+	assert.Contains(spans, `pivot insert traced`)
+	assert.Contains(spans, `pivot query traced`)
+	assert.Contains(spans, `pivot retrieve traced`)
+
+	attrs := func(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+		out := make(map[attribute.Key]attribute.Value)
+
+		for _, kv := range span.Attributes() {
+			out[kv.Key] = kv.Value
+		}
+
+		return out
+	}
+
+	insert := attrs(spans[`pivot insert traced`])
+	assert.Equal(`memory`, insert[`db.system`].AsString())
+	assert.Equal(`traced`, insert[`pivot.collection`].AsString())
+	assert.Equal(`insert`, insert[`pivot.operation`].AsString())
+	assert.EqualValues(2, insert[`pivot.record_count`].AsInt64())
+
+	assert.EqualValues(2, attrs(spans[`pivot query traced`])[`pivot.record_count`].AsInt64())
+	assert.Equal(codes.Error, spans[`pivot retrieve traced`].Status().Code)
+}
@@ -0,0 +1,56 @@
+package backends
+
+import (
+	"fmt"
+)
+
+var TransactionsNotSupportedError = fmt.Errorf("Backend does not support transactions")
+
+// Implemented by backends that can group multiple Insert, Update, and Delete calls into a single
+// unit of work that is either committed or rolled back as a whole.
+type Transactor interface {
+	// Calls fn with a backend whose writes are all part of the same transaction.  If fn returns
+	// nil the transaction is committed; otherwise it is rolled back and the error is returned.
+	Transaction(fn func(tx Backend) error) error
+}
+
+// Runs fn inside of a transaction on the given backend.  Wrapping backends are unwrapped until one
+// that implements Transactor is found; wrappers that do (e.g.: change watching) wrap the backend
+// given to fn in turn, so writes made within the transaction still pass through them.  If none do, or if the backend does
+// not report support for the Transactions feature, TransactionsNotSupportedError is returned.
+func Transaction(backend Backend, fn func(tx Backend) error) error {
+	if !backend.Supports(Transactions) {
+		return TransactionsNotSupportedError
+	}
+
+	for _, backend := range BackendChain(backend) {
+		if transactor, ok := backend.(Transactor); ok {
+			return transactor.Transaction(fn)
+		}
+	}
+
+	return TransactionsNotSupportedError
+}
+
+// Runs fn in a transaction on backend, for wrapping backends whose own side effects (e.g.: change
+// events) should only happen once the writes that caused them are committed.  The backend passed to
+// fn is the one returned by view, which should queue such side effects on committed rather than
+// performing them.  Queued functions are called after the transaction commits, and discarded if it
+// is rolled back.
+func transactionView(backend Backend, fn func(tx Backend) error, view func(tx Backend, committed *[]func()) Backend) error {
+	var committed []func()
+
+	if err := Transaction(backend, func(tx Backend) error {
+		// the transaction may be retried, so only the last attempt's side effects are kept
+		committed = nil
+		return fn(view(tx, &committed))
+	}); err == nil {
+		for _, c := range committed {
+			c()
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
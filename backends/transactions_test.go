@@ -0,0 +1,95 @@
+package backends_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionsNotSupported(t *testing.T) {
+	assert := require.New(t)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	assert.False(backend.Supports(backends.Transactions))
+
+	var called bool
+
+	err := backends.Transaction(backends.NewCachingBackend(backend), func(tx backends.Backend) error {
+		called = true
+		return nil
+	})
+
+	assert.Equal(backends.TransactionsNotSupportedError, err)
+	assert.False(called)
+}
+
+// a backend whose transactions write straight through to it; rolling back only discards the error
+type transactionalBackend struct {
+	backends.Backend
+}
+
+func (self *transactionalBackend) Supports(feature ...backends.BackendFeature) bool {
+	return true
+}
+
+func (self *transactionalBackend) Transaction(fn func(tx backends.Backend) error) error {
+	return fn(self.Backend)
+}
+
+func TestTransactionWrappers(t *testing.T) {
+	assert := require.New(t)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`things`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	watched := backends.NewChangeWatchingBackend(
+		backends.NewTimeoutBackend(&transactionalBackend{backend}, time.Second, time.Second),
+	)
+
+	var events []dal.ChangeEvent
+
+	watched.Watch(`things`, func(event dal.ChangeEvent) {
+		events = append(events, event)
+	})
+
+	// writes made in a transaction pass through the same wrappers, and are announced once it commits
+	assert.NoError(backends.Transaction(watched, func(tx backends.Backend) error {
+		_, ok := tx.(*backends.ChangeWatchingBackend)
+		assert.True(ok)
+
+		_, ok = tx.(*backends.ChangeWatchingBackend).GetBackend().(*backends.TimeoutBackend)
+		assert.True(ok)
+
+		if err := tx.Insert(`things`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `one`))); err != nil {
+			return err
+		}
+
+		assert.Empty(events)
+		return nil
+	}))
+
+	assert.Len(events, 1)
+	assert.Equal(dal.RecordCreated, events[0].Type)
+	assert.EqualValues(1, events[0].ID)
+
+	// ...and not at all if it is rolled back
+	assert.Error(backends.Transaction(watched, func(tx backends.Backend) error {
+		if err := tx.Insert(`things`, dal.NewRecordSet(dal.NewRecord(2).Set(`name`, `two`))); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("nope")
+	}))
+
+	assert.Len(events, 1)
+}
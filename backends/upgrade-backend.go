@@ -0,0 +1,245 @@
+package backends
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// The field that each record's schema version is stored in (see UpgradingBackend).
+var RecordVersionField = `_schema_version`
+
+// Transforms a record from one schema version into the next, modifying it in place.
+type RecordUpgradeFunc func(record *dal.Record) error
+
+// The UpgradingBackend wraps another backend, lazily upgrading records to the latest version of
+// their collection's schema as they are read.  Each collection has a list of upgrade functions,
+// the first of which upgrades records from version 1 to version 2, the second from version 2 to
+// version 3, and so on.  A record's version is stored in the RecordVersionField field; records
+// without one are considered to be version 1.
+//
+// Upgraded records are not written back when they are read; instead, records written through
+// this backend are stamped with the current version, so a record is persisted in its new shape
+// the next time it is saved.  Updates to records that weren't read through this backend (and so
+// don't carry a version) upgrade the stored record first, then apply the update on top of it.
+// This lets large collections change shape without rewriting every record up front.
+//
+// Because stored records may still be in an older shape, queries are evaluated against the shape
+// each record was stored in; only the results are upgraded.
+type UpgradingBackend struct {
+	Backend
+	upgrades map[string][]RecordUpgradeFunc
+	lock     sync.RWMutex
+}
+
+func NewUpgradingBackend(parent Backend) *UpgradingBackend {
+	return &UpgradingBackend{
+		Backend:  parent,
+		upgrades: make(map[string][]RecordUpgradeFunc),
+	}
+}
+
+// Return the backend being wrapped.
+func (self *UpgradingBackend) GetBackend() Backend {
+	return self.Backend
+}
+
+// Append upgrade functions to those already defined for the given collection.
+func (self *UpgradingBackend) AddUpgrades(collection string, upgrades ...RecordUpgradeFunc) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.upgrades[collection] = append(self.upgrades[collection], upgrades...)
+}
+
+// Returns the version that records in the given collection are upgraded to.
+func (self *UpgradingBackend) CurrentVersion(collection string) int {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	return len(self.upgrades[collection]) + 1
+}
+
+// Upgrade the given record to the current version of the collection's schema, returning whether
+// any upgrades were applied.
+func (self *UpgradingBackend) UpgradeRecord(collection string, record *dal.Record) (bool, error) {
+	if record == nil {
+		return false, nil
+	}
+
+	self.lock.RLock()
+	upgrades := self.upgrades[collection]
+	self.lock.RUnlock()
+
+	var current = len(upgrades) + 1
+	var version = RecordVersion(record)
+
+	if version >= current {
+		return false, nil
+	}
+
+	for v := version; v < current; v++ {
+		if err := upgrades[v-1](record); err != nil {
+			return false, fmt.Errorf("failed to upgrade %s record %v from version %d to %d: %v", collection, record.ID, v, v+1, err)
+		}
+	}
+
+	record.Set(RecordVersionField, current)
+
+	return true, nil
+}
+
+// Returns the schema version the given record was stored with.
+func RecordVersion(record *dal.Record) int {
+	if v := int(typeutil.Int(record.Get(RecordVersionField))); v > 0 {
+		return v
+	}
+
+	return 1
+}
+
+func (self *UpgradingBackend) RegisterCollection(definition *dal.Collection) {
+	self.Backend.RegisterCollection(self.withVersionField(definition))
+}
+
+func (self *UpgradingBackend) CreateCollection(definition *dal.Collection) error {
+	return self.Backend.CreateCollection(self.withVersionField(definition))
+}
+
+func (self *UpgradingBackend) Retrieve(collection string, id interface{}, fields ...string) (*dal.Record, error) {
+	if self.CurrentVersion(collection) == 1 {
+		return self.Backend.Retrieve(collection, id, fields...)
+	}
+
+	// upgrades may need fields other than the ones being asked for, so retrieve the whole record
+	if record, err := self.Backend.Retrieve(collection, id); err == nil {
+		if _, err := self.UpgradeRecord(collection, record); err != nil {
+			return nil, err
+		}
+
+		if len(fields) > 0 {
+			record = record.OnlyFields(fields)
+		}
+
+		return record, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *UpgradingBackend) Insert(collection string, records *dal.RecordSet) error {
+	if current := self.CurrentVersion(collection); current > 1 && records != nil {
+		for _, record := range records.Records {
+			record.Set(RecordVersionField, current)
+		}
+	}
+
+	return self.Backend.Insert(collection, records)
+}
+
+func (self *UpgradingBackend) Update(collection string, records *dal.RecordSet, target ...string) error {
+	if current := self.CurrentVersion(collection); current > 1 && records != nil {
+		for _, record := range records.Records {
+			// records that weren't read through this backend might only contain the fields being
+			// changed, so the rest of the record is brought up to date before it's stamped
+			if record.Get(RecordVersionField) == nil {
+				if stored, err := self.Backend.Retrieve(collection, record.ID); err == nil && RecordVersion(stored) < current {
+					if _, err := self.UpgradeRecord(collection, stored); err != nil {
+						return err
+					}
+
+					record.Fields = stored.SetFields(record.Fields).Fields
+				}
+			}
+
+			record.Set(RecordVersionField, current)
+		}
+	}
+
+	return self.Backend.Update(collection, records, target...)
+}
+
+func (self *UpgradingBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	if search := self.Backend.WithSearch(collection, filters...); search != nil {
+		if collection != nil && self.CurrentVersion(collection.Name) > 1 {
+			return &upgradingIndexer{
+				Indexer: search,
+				backend: self,
+			}
+		}
+
+		return search
+	}
+
+	return nil
+}
+
+// adds the version field to collections that have upgrades, so that backends with fixed schemata
+// have somewhere to store it
+func (self *UpgradingBackend) withVersionField(definition *dal.Collection) *dal.Collection {
+	if definition == nil || self.CurrentVersion(definition.Name) == 1 {
+		return definition
+	}
+
+	if _, ok := definition.GetField(RecordVersionField); !ok {
+		definition.AddFields(dal.Field{
+			Name:         RecordVersionField,
+			Type:         dal.IntType,
+			DefaultValue: 1,
+		})
+	}
+
+	return definition
+}
+
+type upgradingIndexer struct {
+	Indexer
+	backend *UpgradingBackend
+}
+
+// records found by the query are retrieved through the upgrading backend, so they are upgraded too
+func (self *upgradingIndexer) GetBackend() Backend {
+	return self.backend
+}
+
+func (self *upgradingIndexer) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	if record, err := self.Indexer.IndexRetrieve(collection, id); err == nil {
+		if _, err := self.backend.UpgradeRecord(collection.Name, record); err != nil {
+			return nil, err
+		}
+
+		return record, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *upgradingIndexer) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	return DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+func (self *upgradingIndexer) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	var fields []string
+
+	// upgrades need the version field (and possibly others), so whole records are queried and
+	// trimmed down to the requested fields afterwards
+	if f != nil && len(f.Fields) > 0 {
+		fields = f.Fields
+		whole := filter.Copy(f)
+		whole.Fields = nil
+		f = &whole
+	}
+
+	return self.Indexer.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err == nil {
+			if _, err = self.backend.UpgradeRecord(collection.Name, record); err == nil && len(fields) > 0 {
+				record = record.OnlyFields(fields)
+			}
+		}
+
+		return resultFn(record, err, page)
+	})
+}
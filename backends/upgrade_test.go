@@ -0,0 +1,101 @@
+package backends_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradingBackend(t *testing.T) {
+	assert := require.New(t)
+	parent := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	backend := backends.NewUpgradingBackend(parent)
+
+	// v1 -> v2: split name into first and last names
+	// v2 -> v3: uppercase last names
+	backend.AddUpgrades(`people`, func(record *dal.Record) error {
+		parts := strings.SplitN(record.GetString(`name`), ` `, 2)
+		record.Set(`first_name`, parts[0]).Set(`last_name`, parts[1])
+		delete(record.Fields, `name`)
+		return nil
+	}, func(record *dal.Record) error {
+		record.Set(`last_name`, strings.ToUpper(record.GetString(`last_name`)))
+		return nil
+	})
+
+	assert.Equal(3, backend.CurrentVersion(`people`))
+	assert.Equal(1, backend.CurrentVersion(`other`))
+
+	collection := dal.NewCollection(`people`,
+		dal.Field{Name: `name`, Type: dal.StringType},
+		dal.Field{Name: `first_name`, Type: dal.StringType},
+		dal.Field{Name: `last_name`, Type: dal.StringType},
+		dal.Field{Name: `age`, Type: dal.IntType},
+	)
+
+	assert.NoError(backend.CreateCollection(collection))
+
+	_, ok := collection.GetField(backends.RecordVersionField)
+	assert.True(ok)
+
+	// records written before the upgrades existed
+	assert.NoError(parent.Insert(`people`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `Ada Lovelace`),
+		dal.NewRecord(2).Set(`name`, `Alan Turing`),
+	)))
+
+	record, err := backend.Retrieve(`people`, 1)
+	assert.NoError(err)
+	assert.Equal(`Ada`, record.Get(`first_name`))
+	assert.Equal(`LOVELACE`, record.Get(`last_name`))
+	assert.Nil(record.Get(`name`))
+	assert.Equal(3, backends.RecordVersion(record))
+
+	// reads don't write the upgraded record back
+	stored, err := parent.Retrieve(`people`, 1)
+	assert.NoError(err)
+	assert.Equal(1, backends.RecordVersion(stored))
+	assert.Equal(`Ada Lovelace`, stored.Get(`name`))
+
+	// a partial update brings the rest of the stored record up to date
+	assert.NoError(backend.Update(`people`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`age`, 36),
+	)))
+
+	stored, err = parent.Retrieve(`people`, 1)
+	assert.NoError(err)
+	assert.Equal(3, backends.RecordVersion(stored))
+	assert.Equal(`Ada`, stored.Get(`first_name`))
+	assert.Equal(`LOVELACE`, stored.Get(`last_name`))
+	assert.EqualValues(36, stored.Get(`age`))
+
+	// upgrades are not applied twice
+	record, err = backend.Retrieve(`people`, 1)
+	assert.NoError(err)
+	assert.Equal(`LOVELACE`, record.Get(`last_name`))
+
+	// query results are upgraded, even when only some fields are requested
+	f := filter.All()
+	f.Fields = []string{`last_name`}
+
+	rs, err := backend.WithSearch(collection).Query(collection, f)
+	assert.NoError(err)
+	assert.Len(rs.Records, 2)
+	assert.Equal(`TURING`, rs.Records[1].Get(`last_name`))
+	assert.Nil(rs.Records[1].Get(`first_name`))
+
+	// new records are stamped with the current version
+	assert.NoError(backend.Insert(`people`, dal.NewRecordSet(
+		dal.NewRecord(3).Set(`first_name`, `Grace`).Set(`last_name`, `HOPPER`),
+	)))
+
+	stored, err = parent.Retrieve(`people`, 3)
+	assert.NoError(err)
+	assert.Equal(3, backends.RecordVersion(stored))
+}
@@ -0,0 +1,628 @@
+package backends
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// The name of the collection that usage statistics are persisted to.
+var UsageCollectionName = `_pivot_usage`
+
+// The name of the collection that observed query shapes are persisted to.
+var QueryShapeCollectionName = `_pivot_query_shapes`
+
+// How often usage statistics are written to the usage collection.  Set to zero to disable
+// periodic persistence (statistics can still be persisted by calling PersistUsage).
+var UsagePersistInterval = time.Minute
+
+type usageOperation int
+
+const (
+	usageRead usageOperation = iota
+	usageWrite
+	usageQuery
+)
+
+// Tracks how often a collection (or a field within a collection) has been read, written, and
+// queried, and when that last happened.
+type UsageStat struct {
+	Collection  string    `json:"collection"`
+	Field       string    `json:"field,omitempty"`
+	Reads       int64     `json:"reads"`
+	Writes      int64     `json:"writes"`
+	Queries     int64     `json:"queries"`
+	LastRead    time.Time `json:"last_read,omitempty"`
+	LastWritten time.Time `json:"last_written,omitempty"`
+	LastQueried time.Time `json:"last_queried,omitempty"`
+}
+
+func (self *UsageStat) Key() string {
+	if self.Field == `` {
+		return self.Collection
+	} else {
+		return self.Collection + `.` + self.Field
+	}
+}
+
+// Returns the most recent time this collection or field was used in any way.
+func (self *UsageStat) LastUsed() time.Time {
+	last := self.LastRead
+
+	if self.LastWritten.After(last) {
+		last = self.LastWritten
+	}
+
+	if self.LastQueried.After(last) {
+		last = self.LastQueried
+	}
+
+	return last
+}
+
+func (self *UsageStat) touch(op usageOperation, at time.Time) {
+	switch op {
+	case usageRead:
+		self.Reads += 1
+		self.LastRead = at
+	case usageWrite:
+		self.Writes += 1
+		self.LastWritten = at
+	case usageQuery:
+		self.Queries += 1
+		self.LastQueried = at
+	}
+}
+
+func (self *UsageStat) toRecord() *dal.Record {
+	return dal.NewRecord(self.Key(), map[string]interface{}{
+		`collection`:   self.Collection,
+		`field`:        self.Field,
+		`reads`:        self.Reads,
+		`writes`:       self.Writes,
+		`queries`:      self.Queries,
+		`last_read`:    self.LastRead,
+		`last_written`: self.LastWritten,
+		`last_queried`: self.LastQueried,
+	})
+}
+
+// Describes the structure of a query performed against a collection: which fields were matched
+// exactly, which were matched by range or pattern, and which were used to sort the results.
+type QueryShape struct {
+	Collection  string    `json:"collection"`
+	Equality    []string  `json:"equality,omitempty"`
+	Range       []string  `json:"range,omitempty"`
+	Sort        []string  `json:"sort,omitempty"`
+	Count       int64     `json:"count"`
+	LastQueried time.Time `json:"last_queried,omitempty"`
+}
+
+func newQueryShape(collection string, f *filter.Filter) *QueryShape {
+	shape := &QueryShape{
+		Collection: collection,
+	}
+
+	for _, criterion := range f.Criteria {
+		if criterion.IsExactMatch() {
+			shape.Equality = append(shape.Equality, criterion.Field)
+		} else {
+			shape.Range = append(shape.Range, criterion.Field)
+		}
+	}
+
+	for _, sortBy := range f.GetSort() {
+		shape.Sort = append(shape.Sort, sortBy.Field)
+	}
+
+	// the order equality fields appear in does not change the shape of the query
+	shape.Equality = sliceutil.UniqueStrings(shape.Equality)
+	shape.Range = sliceutil.UniqueStrings(shape.Range)
+	sort.Strings(shape.Equality)
+
+	return shape
+}
+
+func (self *QueryShape) Key() string {
+	return fmt.Sprintf(
+		"%s:%s:%s:%s",
+		self.Collection,
+		strings.Join(self.Equality, `,`),
+		strings.Join(self.Range, `,`),
+		strings.Join(self.Sort, `,`),
+	)
+}
+
+// Returns all fields that participate in this query shape, in the order they would appear in a
+// composite index (equality matches, then range matches, then sort fields).
+func (self *QueryShape) Fields() []string {
+	fields := make([]string, 0)
+	fields = append(fields, self.Equality...)
+	fields = append(fields, self.Range...)
+	fields = append(fields, self.Sort...)
+
+	return sliceutil.UniqueStrings(fields)
+}
+
+func (self *QueryShape) toRecord() *dal.Record {
+	return dal.NewRecord(self.Key(), map[string]interface{}{
+		`collection`:   self.Collection,
+		`equality`:     strings.Join(self.Equality, `,`),
+		`range`:        strings.Join(self.Range, `,`),
+		`sort`:         strings.Join(self.Sort, `,`),
+		`count`:        self.Count,
+		`last_queried`: self.LastQueried,
+	})
+}
+
+// The UsageTrackingBackend wraps another backend and records which collections and fields are
+// read, written, and queried.  Statistics are kept in memory and periodically persisted to an
+// internal collection (see UsageCollectionName) on the wrapped backend.  The sizes of written
+// records are also tracked (see RecordSizes), but only in memory.
+type UsageTrackingBackend struct {
+	backend     Backend
+	stats       map[string]*UsageStat
+	shapes      map[string]*QueryShape
+	sizes       map[string]*recordSizeHistogram
+	statsLock   sync.Mutex
+	loadOnce    sync.Once
+	persistOnce sync.Once
+	stopOnce    sync.Once
+	stop        chan bool
+	parent      *UsageTrackingBackend // set on the views of this backend given to transactions
+}
+
+func NewUsageTrackingBackend(parent Backend) *UsageTrackingBackend {
+	return &UsageTrackingBackend{
+		backend: parent,
+		stats:   make(map[string]*UsageStat),
+		shapes:  make(map[string]*QueryShape),
+		sizes:   make(map[string]*recordSizeHistogram),
+		stop:    make(chan bool),
+	}
+}
+
+// Return the backend being tracked.
+func (self *UsageTrackingBackend) GetBackend() Backend {
+	return self.backend
+}
+
+// Return a copy of all usage statistics, sorted by collection and field name.
+func (self *UsageTrackingBackend) Usage() []*UsageStat {
+	self.loadUsage()
+	self.statsLock.Lock()
+	defer self.statsLock.Unlock()
+
+	stats := make([]*UsageStat, 0, len(self.stats))
+
+	for _, stat := range self.stats {
+		s := *stat
+		stats = append(stats, &s)
+	}
+
+	sort.Slice(stats, func(i int, j int) bool {
+		return stats[i].Key() < stats[j].Key()
+	})
+
+	return stats
+}
+
+// Return a copy of all observed query shapes, most frequently used first.
+func (self *UsageTrackingBackend) QueryShapes() []*QueryShape {
+	self.loadUsage()
+	self.statsLock.Lock()
+	defer self.statsLock.Unlock()
+
+	shapes := make([]*QueryShape, 0, len(self.shapes))
+
+	for _, shape := range self.shapes {
+		s := *shape
+		shapes = append(shapes, &s)
+	}
+
+	sort.Slice(shapes, func(i int, j int) bool {
+		if shapes[i].Count == shapes[j].Count {
+			return shapes[i].Key() < shapes[j].Key()
+		}
+
+		return shapes[i].Count > shapes[j].Count
+	})
+
+	return shapes
+}
+
+// Write the current usage statistics and query shapes to their respective collections.
+func (self *UsageTrackingBackend) PersistUsage() error {
+	statRecords := dal.NewRecordSet()
+	shapeRecords := dal.NewRecordSet()
+
+	for _, stat := range self.Usage() {
+		statRecords.Push(stat.toRecord())
+	}
+
+	for _, shape := range self.QueryShapes() {
+		shapeRecords.Push(shape.toRecord())
+	}
+
+	if err := self.persistRecords(usageCollection(), statRecords); err != nil {
+		return err
+	}
+
+	return self.persistRecords(queryShapeCollection(), shapeRecords)
+}
+
+func (self *UsageTrackingBackend) persistRecords(collection *dal.Collection, recordset *dal.RecordSet) error {
+	if _, err := self.backend.GetCollection(collection.Name); dal.IsCollectionNotFoundErr(err) {
+		if err := self.backend.CreateCollection(collection); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	inserts := dal.NewRecordSet()
+	updates := dal.NewRecordSet()
+
+	for _, record := range recordset.Records {
+		if self.backend.Exists(collection.Name, record.ID) {
+			updates.Push(record)
+		} else {
+			inserts.Push(record)
+		}
+	}
+
+	if len(inserts.Records) > 0 {
+		if err := self.backend.Insert(collection.Name, inserts); err != nil {
+			return err
+		}
+	}
+
+	if len(updates.Records) > 0 {
+		if err := self.backend.Update(collection.Name, updates); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func usageCollection() *dal.Collection {
+	collection := dal.NewCollection(UsageCollectionName,
+		dal.Field{Name: `collection`, Type: dal.StringType},
+		dal.Field{Name: `field`, Type: dal.StringType},
+		dal.Field{Name: `reads`, Type: dal.IntType},
+		dal.Field{Name: `writes`, Type: dal.IntType},
+		dal.Field{Name: `queries`, Type: dal.IntType},
+		dal.Field{Name: `last_read`, Type: dal.TimeType},
+		dal.Field{Name: `last_written`, Type: dal.TimeType},
+		dal.Field{Name: `last_queried`, Type: dal.TimeType},
+	)
+
+	collection.IdentityFieldType = dal.StringType
+
+	return collection
+}
+
+func queryShapeCollection() *dal.Collection {
+	collection := dal.NewCollection(QueryShapeCollectionName,
+		dal.Field{Name: `collection`, Type: dal.StringType},
+		dal.Field{Name: `equality`, Type: dal.StringType},
+		dal.Field{Name: `range`, Type: dal.StringType},
+		dal.Field{Name: `sort`, Type: dal.StringType},
+		dal.Field{Name: `count`, Type: dal.IntType},
+		dal.Field{Name: `last_queried`, Type: dal.TimeType},
+	)
+
+	collection.IdentityFieldType = dal.StringType
+
+	return collection
+}
+
+// populate the in-memory statistics from the usage collections the first time they are needed
+func (self *UsageTrackingBackend) loadUsage() {
+	self.loadOnce.Do(func() {
+		self.statsLock.Lock()
+		defer self.statsLock.Unlock()
+
+		self.loadRecords(UsageCollectionName, func(record *dal.Record) {
+			stat := &UsageStat{
+				Collection:  record.GetString(`collection`),
+				Field:       record.GetString(`field`),
+				Reads:       typeutil.Int(record.Get(`reads`)),
+				Writes:      typeutil.Int(record.Get(`writes`)),
+				Queries:     typeutil.Int(record.Get(`queries`)),
+				LastRead:    usageTime(record.Get(`last_read`)),
+				LastWritten: usageTime(record.Get(`last_written`)),
+				LastQueried: usageTime(record.Get(`last_queried`)),
+			}
+
+			self.stats[stat.Key()] = stat
+		})
+
+		self.loadRecords(QueryShapeCollectionName, func(record *dal.Record) {
+			shape := &QueryShape{
+				Collection:  record.GetString(`collection`),
+				Equality:    sliceutil.CompactString(strings.Split(record.GetString(`equality`), `,`)),
+				Range:       sliceutil.CompactString(strings.Split(record.GetString(`range`), `,`)),
+				Sort:        sliceutil.CompactString(strings.Split(record.GetString(`sort`), `,`)),
+				Count:       typeutil.Int(record.Get(`count`)),
+				LastQueried: usageTime(record.Get(`last_queried`)),
+			}
+
+			self.shapes[shape.Key()] = shape
+		})
+	})
+}
+
+func (self *UsageTrackingBackend) loadRecords(name string, fn func(record *dal.Record)) {
+	if collection, err := self.backend.GetCollection(name); err == nil {
+		if search := self.backend.WithSearch(collection); search != nil {
+			if err := search.QueryFunc(collection, filter.All(), func(record *dal.Record, err error, _ IndexPage) error {
+				if err == nil {
+					fn(record)
+				}
+
+				return nil
+			}); err != nil {
+				log.Warningf("[%v] failed to load %s: %v", self, name, err)
+			}
+		}
+	}
+}
+
+func (self *UsageTrackingBackend) startPersisting() {
+	if UsagePersistInterval <= 0 {
+		return
+	}
+
+	self.persistOnce.Do(func() {
+		go func() {
+			for {
+				select {
+				case <-self.stop:
+					return
+				case <-time.After(UsagePersistInterval):
+					if err := self.PersistUsage(); err != nil {
+						log.Warningf("[%v] failed to persist usage statistics: %v", self, err)
+					}
+				}
+			}
+		}()
+	})
+}
+
+// Stop persisting usage statistics in the background, and persist them one last time.
+func (self *UsageTrackingBackend) Close() error {
+	if self.parent != nil {
+		return nil
+	}
+
+	var closed bool
+
+	self.stopOnce.Do(func() {
+		close(self.stop)
+		closed = true
+	})
+
+	if closed {
+		return self.PersistUsage()
+	}
+
+	return nil
+}
+
+func (self *UsageTrackingBackend) track(op usageOperation, collection string, fields ...string) {
+	if self.parent != nil {
+		self.parent.track(op, collection, fields...)
+		return
+	} else if collection == `` || collection == UsageCollectionName || collection == QueryShapeCollectionName {
+		return
+	}
+
+	self.loadUsage()
+	self.statsLock.Lock()
+	defer self.statsLock.Unlock()
+
+	now := time.Now()
+	seen := make(map[string]bool)
+
+	for _, field := range append([]string{``}, fields...) {
+		if seen[field] {
+			continue
+		}
+
+		seen[field] = true
+		key := (&UsageStat{Collection: collection, Field: field}).Key()
+
+		if _, ok := self.stats[key]; !ok {
+			self.stats[key] = &UsageStat{
+				Collection: collection,
+				Field:      field,
+			}
+		}
+
+		self.stats[key].touch(op, now)
+	}
+}
+
+func (self *UsageTrackingBackend) trackQueryShape(collection string, f *filter.Filter) {
+	if self.parent != nil {
+		self.parent.trackQueryShape(collection, f)
+		return
+	} else if collection == `` || collection == UsageCollectionName || collection == QueryShapeCollectionName {
+		return
+	} else if f == nil || f.IsMatchAll() {
+		return
+	}
+
+	self.loadUsage()
+	self.statsLock.Lock()
+	defer self.statsLock.Unlock()
+
+	shape := newQueryShape(collection, f)
+
+	if existing, ok := self.shapes[shape.Key()]; ok {
+		shape = existing
+	} else {
+		self.shapes[shape.Key()] = shape
+	}
+
+	shape.Count += 1
+	shape.LastQueried = time.Now()
+}
+
+func (self *UsageTrackingBackend) trackRecordSet(collection string, recordset *dal.RecordSet) {
+	fields := make([]string, 0)
+
+	if recordset != nil {
+		for _, record := range recordset.Records {
+			for field := range record.Fields {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	self.track(usageWrite, collection, fields...)
+	self.trackRecordSizes(collection, recordset)
+}
+
+// Runs fn in a transaction on the tracked backend.  Usage by the transaction is tracked the same as
+// any other.
+func (self *UsageTrackingBackend) Transaction(fn func(tx Backend) error) error {
+	return Transaction(self.backend, func(tx Backend) error {
+		return fn(&UsageTrackingBackend{
+			backend: tx,
+			parent:  self,
+		})
+	})
+}
+
+func (self *UsageTrackingBackend) Initialize() error {
+	if err := self.backend.Initialize(); err != nil {
+		return err
+	}
+
+	self.startPersisting()
+	return nil
+}
+
+func (self *UsageTrackingBackend) Retrieve(collection string, id interface{}, fields ...string) (*dal.Record, error) {
+	self.track(usageRead, collection, fields...)
+	return self.backend.Retrieve(collection, id, fields...)
+}
+
+func (self *UsageTrackingBackend) Insert(collection string, records *dal.RecordSet) error {
+	self.trackRecordSet(collection, records)
+	return self.backend.Insert(collection, records)
+}
+
+func (self *UsageTrackingBackend) Update(collection string, records *dal.RecordSet, target ...string) error {
+	self.trackRecordSet(collection, records)
+	return self.backend.Update(collection, records, target...)
+}
+
+func (self *UsageTrackingBackend) Delete(collection string, ids ...interface{}) error {
+	self.track(usageWrite, collection)
+	return self.backend.Delete(collection, ids...)
+}
+
+func (self *UsageTrackingBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	if collection != nil {
+		fields := make([]string, 0)
+
+		for _, f := range filters {
+			if f == nil {
+				continue
+			}
+
+			fields = append(fields, f.CriteriaFields()...)
+			fields = append(fields, f.Fields...)
+
+			for _, sortBy := range f.GetSort() {
+				fields = append(fields, sortBy.Field)
+			}
+
+			self.trackQueryShape(collection.Name, f)
+		}
+
+		self.track(usageQuery, collection.Name, fields...)
+	}
+
+	return self.backend.WithSearch(collection, filters...)
+}
+
+func (self *UsageTrackingBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	if collection != nil {
+		self.track(usageQuery, collection.Name)
+	}
+
+	return self.backend.WithAggregator(collection)
+}
+
+// passthrough the remaining functions to fulfill the Backend interface
+// -------------------------------------------------------------------------------------------------
+func (self *UsageTrackingBackend) Exists(collection string, id interface{}) bool {
+	return self.backend.Exists(collection, id)
+}
+
+func (self *UsageTrackingBackend) SetIndexer(cs dal.ConnectionString) error {
+	return self.backend.SetIndexer(cs)
+}
+
+func (self *UsageTrackingBackend) RegisterCollection(c *dal.Collection) {
+	self.backend.RegisterCollection(c)
+}
+
+func (self *UsageTrackingBackend) GetConnectionString() *dal.ConnectionString {
+	return self.backend.GetConnectionString()
+}
+
+func (self *UsageTrackingBackend) CreateCollection(definition *dal.Collection) error {
+	return self.backend.CreateCollection(definition)
+}
+
+func (self *UsageTrackingBackend) DeleteCollection(collection string) error {
+	return self.backend.DeleteCollection(collection)
+}
+
+func (self *UsageTrackingBackend) ListCollections() ([]string, error) {
+	return self.backend.ListCollections()
+}
+
+func (self *UsageTrackingBackend) GetCollection(collection string) (*dal.Collection, error) {
+	return self.backend.GetCollection(collection)
+}
+
+func (self *UsageTrackingBackend) Flush() error {
+	return self.backend.Flush()
+}
+
+func (self *UsageTrackingBackend) Ping(d time.Duration) error {
+	return self.backend.Ping(d)
+}
+
+func (self *UsageTrackingBackend) String() string {
+	return self.backend.String()
+}
+
+func (self *UsageTrackingBackend) Supports(feature ...BackendFeature) bool {
+	return self.backend.Supports(feature...)
+}
+
+func usageTime(in interface{}) time.Time {
+	if t, ok := in.(time.Time); ok {
+		return t
+	} else if t, err := time.Parse(time.RFC3339Nano, fmt.Sprintf("%v", in)); err == nil {
+		return t
+	}
+
+	return time.Time{}
+}
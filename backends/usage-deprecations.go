@@ -0,0 +1,56 @@
+package backends
+
+import (
+	"sort"
+)
+
+// Describes how much a deprecated field is still being used, so that it can be removed once
+// nothing reads or writes it anymore.
+type DeprecatedFieldUsage struct {
+	UsageStat
+	ReplacedBy string `json:"replaced_by,omitempty"`
+}
+
+// Return the usage statistics of every deprecated field in every collection.  Since statistics are
+// persisted, they reflect all usage since tracking was enabled, not just that of this process.
+// Fields that haven't been used at all are included with zero counts.
+func (self *UsageTrackingBackend) DeprecatedFieldUsage() ([]*DeprecatedFieldUsage, error) {
+	var report = make([]*DeprecatedFieldUsage, 0)
+	var stats = make(map[string]*UsageStat)
+
+	for _, stat := range self.Usage() {
+		stats[stat.Key()] = stat
+	}
+
+	if names, err := self.backend.ListCollections(); err == nil {
+		for _, name := range names {
+			if collection, err := self.backend.GetCollection(name); err == nil {
+				for _, field := range collection.DeprecatedFields() {
+					var usage = &DeprecatedFieldUsage{
+						UsageStat: UsageStat{
+							Collection: collection.Name,
+							Field:      field.Name,
+						},
+						ReplacedBy: field.ReplacedBy,
+					}
+
+					if stat, ok := stats[usage.Key()]; ok {
+						usage.UsageStat = *stat
+					}
+
+					report = append(report, usage)
+				}
+			} else {
+				return nil, err
+			}
+		}
+	} else {
+		return nil, err
+	}
+
+	sort.Slice(report, func(i int, j int) bool {
+		return report[i].Key() < report[j].Key()
+	})
+
+	return report, nil
+}
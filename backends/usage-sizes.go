@@ -0,0 +1,209 @@
+package backends
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// The upper bounds (in bytes) of the buckets that the serialized sizes of written records are
+// counted in.  Records larger than the last bound are counted in an implicit "+Inf" bucket.  Changes
+// only apply to collections that haven't had any records written yet.
+var RecordSizeBuckets = []float64{
+	64,
+	256,
+	1024,
+	4096,
+	16384,
+	65536,
+	262144,
+	1048576,
+	4194304,
+	16777216,
+}
+
+// A single bucket of a RecordSizeStat histogram, counting the records whose size was less than or
+// equal to UpperBound (bytes).  Like Prometheus histogram buckets, counts are cumulative.
+type RecordSizeBucket struct {
+	UpperBound float64 `json:"le"`
+	Count      int64   `json:"count"`
+}
+
+func (self RecordSizeBucket) MarshalJSON() ([]byte, error) {
+	var bound interface{} = self.UpperBound
+
+	// JSON has no representation for infinity, so use the same one Prometheus does
+	if math.IsInf(self.UpperBound, 1) {
+		bound = `+Inf`
+	}
+
+	return json.Marshal(map[string]interface{}{
+		`le`:    bound,
+		`count`: self.Count,
+	})
+}
+
+// Describes the distribution of the serialized (JSON) sizes of the records written to a
+// collection, in bytes.  The percentiles are estimated from the histogram buckets.
+type RecordSizeStat struct {
+	Collection string             `json:"collection"`
+	Count      int64              `json:"count"`
+	Sum        int64              `json:"sum"`
+	Max        int64              `json:"max"`
+	P50        float64            `json:"p50"`
+	P95        float64            `json:"p95"`
+	Buckets    []RecordSizeBucket `json:"buckets"`
+}
+
+// Estimate the size below which the given fraction (0.0-1.0) of records fall, interpolating
+// linearly within the bucket the quantile lands in.
+func (self *RecordSizeStat) Quantile(q float64) float64 {
+	if self.Count == 0 {
+		return 0
+	}
+
+	var rank = q * float64(self.Count)
+	var lower float64
+	var below int64
+
+	for _, bucket := range self.Buckets {
+		if float64(bucket.Count) >= rank {
+			var estimate = lower
+
+			if inBucket := bucket.Count - below; inBucket > 0 {
+				var upper = bucket.UpperBound
+
+				if math.IsInf(upper, 1) {
+					upper = float64(self.Max)
+				}
+
+				estimate = lower + (upper-lower)*((rank-float64(below))/float64(inBucket))
+			}
+
+			return math.Min(estimate, float64(self.Max))
+		}
+
+		lower = bucket.UpperBound
+		below = bucket.Count
+	}
+
+	return float64(self.Max)
+}
+
+type recordSizeHistogram struct {
+	bounds []float64
+	counts []int64 // per-bucket (non-cumulative) counts, with a trailing +Inf bucket
+	count  int64
+	sum    int64
+	max    int64
+}
+
+func newRecordSizeHistogram() *recordSizeHistogram {
+	var bounds = make([]float64, len(RecordSizeBuckets))
+
+	copy(bounds, RecordSizeBuckets)
+	sort.Float64s(bounds)
+
+	return &recordSizeHistogram{
+		bounds: bounds,
+		counts: make([]int64, len(bounds)+1),
+	}
+}
+
+func (self *recordSizeHistogram) observe(size int64) {
+	var i = sort.SearchFloat64s(self.bounds, float64(size))
+
+	self.counts[i] += 1
+	self.count += 1
+	self.sum += size
+
+	if size > self.max {
+		self.max = size
+	}
+}
+
+func (self *recordSizeHistogram) stat(collection string) *RecordSizeStat {
+	var stat = &RecordSizeStat{
+		Collection: collection,
+		Count:      self.count,
+		Sum:        self.sum,
+		Max:        self.max,
+		Buckets:    make([]RecordSizeBucket, len(self.counts)),
+	}
+
+	var cumulative int64
+
+	for i, count := range self.counts {
+		var bucket = RecordSizeBucket{
+			UpperBound: math.Inf(1),
+		}
+
+		if i < len(self.bounds) {
+			bucket.UpperBound = self.bounds[i]
+		}
+
+		cumulative += count
+		bucket.Count = cumulative
+		stat.Buckets[i] = bucket
+	}
+
+	stat.P50 = stat.Quantile(0.5)
+	stat.P95 = stat.Quantile(0.95)
+
+	return stat
+}
+
+// Return the distribution of the sizes of the records written to each collection since this
+// backend was created, sorted by collection name.
+func (self *UsageTrackingBackend) RecordSizes() []*RecordSizeStat {
+	self.statsLock.Lock()
+	defer self.statsLock.Unlock()
+
+	stats := make([]*RecordSizeStat, 0, len(self.sizes))
+
+	for collection, histogram := range self.sizes {
+		stats = append(stats, histogram.stat(collection))
+	}
+
+	sort.Slice(stats, func(i int, j int) bool {
+		return stats[i].Collection < stats[j].Collection
+	})
+
+	return stats
+}
+
+func (self *UsageTrackingBackend) trackRecordSizes(collection string, recordset *dal.RecordSet) {
+	if self.parent != nil {
+		self.parent.trackRecordSizes(collection, recordset)
+		return
+	} else if collection == `` || collection == UsageCollectionName || collection == QueryShapeCollectionName {
+		return
+	} else if recordset == nil || len(recordset.Records) == 0 {
+		return
+	}
+
+	// measure outside of the lock, since serializing large records can take a while
+	var sizes = make([]int64, 0, len(recordset.Records))
+
+	for _, record := range recordset.Records {
+		if data, err := json.Marshal(record); err == nil {
+			sizes = append(sizes, int64(len(data)))
+		}
+	}
+
+	self.statsLock.Lock()
+	defer self.statsLock.Unlock()
+
+	histogram, ok := self.sizes[collection]
+
+	if !ok {
+		histogram = newRecordSizeHistogram()
+		self.sizes[collection] = histogram
+	}
+
+	for _, size := range sizes {
+		histogram.observe(size)
+	}
+}
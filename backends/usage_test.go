@@ -0,0 +1,176 @@
+package backends_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageTrackingBackend(t *testing.T) {
+	assert := require.New(t)
+
+	backend := backends.NewUsageTrackingBackend(
+		spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver()),
+	)
+
+	collection := dal.NewCollection(`usage`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	assert.NoError(backend.CreateCollection(collection))
+	assert.NoError(backend.Insert(`usage`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `one`),
+	)))
+
+	_, err := backend.Retrieve(`usage`, 1)
+	assert.NoError(err)
+
+	assert.NotNil(backend.WithSearch(collection, filter.MustParse(`name/one`)))
+
+	stats := make(map[string]*backends.UsageStat)
+
+	for _, stat := range backend.Usage() {
+		stats[stat.Key()] = stat
+	}
+
+	assert.Contains(stats, `usage`)
+	assert.EqualValues(1, stats[`usage`].Reads)
+	assert.EqualValues(1, stats[`usage`].Writes)
+	assert.EqualValues(1, stats[`usage`].Queries)
+
+	assert.Contains(stats, `usage.name`)
+	assert.EqualValues(0, stats[`usage.name`].Reads)
+	assert.EqualValues(1, stats[`usage.name`].Writes)
+	assert.EqualValues(1, stats[`usage.name`].Queries)
+
+	shapes := backend.QueryShapes()
+	assert.Len(shapes, 1)
+	assert.Equal([]string{`name`}, shapes[0].Equality)
+	assert.EqualValues(1, shapes[0].Count)
+
+	assert.NoError(backend.PersistUsage())
+	assert.True(backend.Exists(backends.UsageCollectionName, `usage.name`))
+	assert.True(backend.Exists(backends.QueryShapeCollectionName, shapes[0].Key()))
+}
+
+func TestUsageTrackingBackendClose(t *testing.T) {
+	assert := require.New(t)
+
+	defer func(interval time.Duration) {
+		backends.UsagePersistInterval = interval
+	}(backends.UsagePersistInterval)
+
+	backends.UsagePersistInterval = time.Hour
+
+	backend := backends.NewUsageTrackingBackend(
+		spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver()),
+	)
+
+	assert.NoError(backend.Initialize())
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`closing`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	assert.NoError(backend.Insert(`closing`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `one`),
+	)))
+
+	// statistics gathered since the last time they were persisted aren't lost
+	assert.False(backend.Exists(backends.UsageCollectionName, `closing.name`))
+	assert.NoError(backend.Close())
+	assert.True(backend.Exists(backends.UsageCollectionName, `closing.name`))
+
+	// closing again does nothing
+	assert.NoError(backend.Close())
+}
+
+func TestUsageTrackingRecordSizes(t *testing.T) {
+	assert := require.New(t)
+
+	backend := backends.NewUsageTrackingBackend(
+		spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver()),
+	)
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`sizes`, dal.Field{
+		Name: `body`,
+		Type: dal.StringType,
+	})))
+
+	records := dal.NewRecordSet()
+
+	for i := 0; i < 99; i++ {
+		records.Push(dal.NewRecord(i).Set(`body`, `small`))
+	}
+
+	records.Push(dal.NewRecord(99).Set(`body`, strings.Repeat(`x`, 100000)))
+
+	assert.NoError(backend.Insert(`sizes`, records))
+
+	stats := backend.RecordSizes()
+	assert.Len(stats, 1)
+
+	stat := stats[0]
+	assert.Equal(`sizes`, stat.Collection)
+	assert.EqualValues(100, stat.Count)
+	assert.True(stat.Max > 100000)
+	assert.True(stat.Max < 100100)
+
+	// most records are tiny, so the median is too, but the outlier shows up in the maximum
+	assert.True(stat.P50 <= 64)
+	assert.True(stat.P95 <= 64)
+	assert.Len(stat.Buckets, len(backends.RecordSizeBuckets)+1)
+	assert.EqualValues(99, stat.Buckets[0].Count)
+	assert.EqualValues(100, stat.Buckets[len(stat.Buckets)-1].Count)
+}
+
+func TestUsageTrackingDeprecatedFields(t *testing.T) {
+	assert := require.New(t)
+
+	backend := backends.NewUsageTrackingBackend(
+		spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver()),
+	)
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`users`, dal.Field{
+		Name:       `fullname`,
+		Type:       dal.StringType,
+		Deprecated: true,
+		ReplacedBy: `name`,
+	}, dal.Field{
+		Name:       `nickname`,
+		Type:       dal.StringType,
+		Deprecated: true,
+	}, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	assert.NoError(backend.Insert(`users`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`fullname`, `Jane Doe`).Set(`name`, `Jane`),
+	)))
+
+	_, err := backend.Retrieve(`users`, 1, `fullname`)
+	assert.NoError(err)
+
+	report, err := backend.DeprecatedFieldUsage()
+	assert.NoError(err)
+	assert.Len(report, 2)
+
+	assert.Equal(`fullname`, report[0].Field)
+	assert.Equal(`name`, report[0].ReplacedBy)
+	assert.EqualValues(1, report[0].Reads)
+	assert.EqualValues(1, report[0].Writes)
+
+	// unused deprecated fields are reported too, so they can be seen to be safe to remove
+	assert.Equal(`nickname`, report[1].Field)
+	assert.EqualValues(0, report[1].Reads)
+	assert.EqualValues(0, report[1].Writes)
+}
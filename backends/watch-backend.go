@@ -0,0 +1,234 @@
+package backends
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// Watchers registered for this collection name receive events for all collections.
+var WatchAllCollections = `*`
+
+type ChangeEventFunc func(event dal.ChangeEvent)
+
+// The ChangeWatchingBackend wraps another backend and notifies subscribers whenever records are
+// created, updated, or deleted through it.  Events are emitted synchronously, in the order the
+// writes occurred, and only after the wrapped backend reports success.
+//
+// When a collection has watchers, the current version of each record is retrieved before it is
+// updated or deleted so that events can describe which fields changed.  Events for writes made
+// within a transaction are emitted once it commits.  Writes that bypass this backend (e.g.: those
+// performed by other processes) do not emit events.
+type ChangeWatchingBackend struct {
+	Backend
+	watchers  map[string]map[int]ChangeEventFunc
+	nextID    int
+	lock      sync.RWMutex
+	parent    *ChangeWatchingBackend // set on the views of this backend given to transactions
+	committed *[]func()
+}
+
+func NewChangeWatchingBackend(parent Backend) *ChangeWatchingBackend {
+	return &ChangeWatchingBackend{
+		Backend:  parent,
+		watchers: make(map[string]map[int]ChangeEventFunc),
+	}
+}
+
+// Return the backend being watched.
+func (self *ChangeWatchingBackend) GetBackend() Backend {
+	return self.Backend
+}
+
+// Call fn whenever a record in the named collection (or any collection, if the name is
+// WatchAllCollections) changes.  The returned function stops fn from receiving further events.
+func (self *ChangeWatchingBackend) Watch(collection string, fn ChangeEventFunc) func() {
+	if self.parent != nil {
+		return self.parent.Watch(collection, fn)
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if collection == `` {
+		collection = WatchAllCollections
+	}
+
+	var id = self.nextID
+	self.nextID += 1
+
+	if _, ok := self.watchers[collection]; !ok {
+		self.watchers[collection] = make(map[int]ChangeEventFunc)
+	}
+
+	self.watchers[collection][id] = fn
+
+	return func() {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+
+		delete(self.watchers[collection], id)
+
+		if len(self.watchers[collection]) == 0 {
+			delete(self.watchers, collection)
+		}
+	}
+}
+
+func (self *ChangeWatchingBackend) Insert(collection string, records *dal.RecordSet) error {
+	if err := self.Backend.Insert(collection, records); err == nil {
+		if records != nil && self.watching(collection) {
+			for _, record := range records.Records {
+				self.emit(dal.RecordCreated, collection, record.ID, dal.DiffRecords(nil, record), record)
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *ChangeWatchingBackend) Update(collection string, records *dal.RecordSet, target ...string) error {
+	if records == nil || !self.watching(collection) {
+		return self.Backend.Update(collection, records, target...)
+	}
+
+	var previous = self.retrieveAll(collection, recordIds(records)...)
+
+	if err := self.Backend.Update(collection, records, target...); err == nil {
+		for i, record := range records.Records {
+			var changes map[string]dal.FieldChange
+			var current = record
+
+			if previous[i] != nil {
+				changes = dal.DiffRecords(previous[i], record)
+				current = mergeRecords(previous[i], record)
+			} else if len(target) == 0 {
+				changes = dal.DiffRecords(nil, record)
+			}
+
+			self.emit(dal.RecordUpdated, collection, record.ID, changes, current)
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *ChangeWatchingBackend) Delete(collection string, ids ...interface{}) error {
+	if !self.watching(collection) {
+		return self.Backend.Delete(collection, ids...)
+	}
+
+	var previous = self.retrieveAll(collection, ids...)
+
+	if err := self.Backend.Delete(collection, ids...); err == nil {
+		for i, id := range ids {
+			var changes map[string]dal.FieldChange
+
+			if previous[i] != nil {
+				changes = dal.DiffRecords(previous[i], nil)
+			}
+
+			self.emit(dal.RecordDeleted, collection, id, changes, previous[i])
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Runs fn in a transaction on the watched backend.  Events for the writes it makes are emitted
+// after the transaction commits, and not at all if it is rolled back.
+func (self *ChangeWatchingBackend) Transaction(fn func(tx Backend) error) error {
+	return transactionView(self.Backend, fn, func(tx Backend, committed *[]func()) Backend {
+		return &ChangeWatchingBackend{
+			Backend:   tx,
+			parent:    self,
+			committed: committed,
+		}
+	})
+}
+
+// returns whether anything is watching the given collection
+func (self *ChangeWatchingBackend) watching(collection string) bool {
+	if self.parent != nil {
+		return self.parent.watching(collection)
+	}
+
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	return len(self.watchers[collection]) > 0 || len(self.watchers[WatchAllCollections]) > 0
+}
+
+// retrieve the current version of the given records; records that can't be retrieved are nil
+func (self *ChangeWatchingBackend) retrieveAll(collection string, ids ...interface{}) []*dal.Record {
+	var records = make([]*dal.Record, len(ids))
+
+	for i, id := range ids {
+		if id == nil {
+			continue
+		}
+
+		if record, err := self.Backend.Retrieve(collection, id); err == nil {
+			records[i] = record
+		}
+	}
+
+	return records
+}
+
+// returns a copy of previous with the fields from an update applied to it
+func mergeRecords(previous *dal.Record, update *dal.Record) *dal.Record {
+	var merged = dal.NewRecord(update.ID)
+
+	for k, v := range previous.Fields {
+		merged.Set(k, v)
+	}
+
+	for k, v := range update.Fields {
+		merged.Set(k, v)
+	}
+
+	return merged
+}
+
+func (self *ChangeWatchingBackend) emit(changeType dal.ChangeType, collection string, id interface{}, changes map[string]dal.FieldChange, record *dal.Record) {
+	if self.parent != nil {
+		*self.committed = append(*self.committed, func() {
+			self.parent.emit(changeType, collection, id, changes, record)
+		})
+
+		return
+	}
+
+	var event = dal.ChangeEvent{
+		Type:       changeType,
+		Collection: collection,
+		ID:         id,
+		Changes:    changes,
+		Record:     record,
+		Timestamp:  time.Now(),
+	}
+
+	// copy the watchers so that they're free to watch or unwatch from within their callbacks
+	self.lock.RLock()
+	var fns = make([]ChangeEventFunc, 0)
+
+	for _, name := range []string{collection, WatchAllCollections} {
+		for _, fn := range self.watchers[name] {
+			fns = append(fns, fn)
+		}
+	}
+
+	self.lock.RUnlock()
+
+	for _, fn := range fns {
+		fn(event)
+	}
+}
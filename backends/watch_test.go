@@ -0,0 +1,93 @@
+package backends_test
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeWatchingBackend(t *testing.T) {
+	assert := require.New(t)
+
+	backend := backends.NewChangeWatchingBackend(
+		spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver()),
+	)
+
+	for _, name := range []string{`watched`, `other`} {
+		assert.NoError(backend.CreateCollection(dal.NewCollection(name, dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name: `age`,
+			Type: dal.IntType,
+		})))
+	}
+
+	var events []dal.ChangeEvent
+	var all []dal.ChangeEvent
+
+	unwatch := backend.Watch(`watched`, func(event dal.ChangeEvent) {
+		events = append(events, event)
+	})
+
+	backend.Watch(backends.WatchAllCollections, func(event dal.ChangeEvent) {
+		all = append(all, event)
+	})
+
+	assert.NoError(backend.Insert(`watched`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `one`).Set(`age`, 1),
+	)))
+
+	assert.NoError(backend.Update(`watched`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `uno`).Set(`age`, 1),
+	)))
+
+	assert.NoError(backend.Delete(`watched`, 1))
+
+	assert.NoError(backend.Insert(`other`, dal.NewRecordSet(
+		dal.NewRecord(2).Set(`name`, `two`),
+	)))
+
+	assert.Len(events, 3)
+	assert.Len(all, 4)
+
+	assert.Equal(dal.RecordCreated, events[0].Type)
+	assert.Equal(`watched`, events[0].Collection)
+	assert.EqualValues(1, events[0].ID)
+	assert.Equal(dal.FieldChange{
+		New: `one`,
+	}, events[0].Changes[`name`])
+
+	// only fields whose values changed are reported
+	assert.Equal(dal.RecordUpdated, events[1].Type)
+	assert.Len(events[1].Changes, 1)
+	assert.Equal(dal.FieldChange{
+		Old: `one`,
+		New: `uno`,
+	}, events[1].Changes[`name`])
+	assert.Equal(`uno`, events[1].Record.Get(`name`))
+	assert.EqualValues(1, events[1].Record.Get(`age`))
+
+	assert.Equal(dal.RecordDeleted, events[2].Type)
+	assert.EqualValues(1, events[2].ID)
+	assert.Equal(`uno`, events[2].Changes[`name`].Old)
+
+	assert.Equal(`other`, all[3].Collection)
+
+	// failed writes don't emit events
+	assert.Error(backend.Update(`missing`, dal.NewRecordSet(dal.NewRecord(3))))
+	assert.Len(all, 4)
+
+	unwatch()
+
+	assert.NoError(backend.Insert(`watched`, dal.NewRecordSet(
+		dal.NewRecord(3).Set(`name`, `three`),
+	)))
+
+	assert.Len(events, 3)
+	assert.Len(all, 5)
+}
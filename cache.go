@@ -0,0 +1,36 @@
+package pivot
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// Sets caching headers on a response containing records from the given collection, according to
+// the collection's cache policy (if any).  Only GET requests are cached.  Returns true if the
+// client's copy is still current, in which case a 304 Not Modified response has already been
+// sent and nothing else should be written.
+func (self *Server) applyCachePolicy(w http.ResponseWriter, req *http.Request, collection *dal.Collection, records ...*dal.Record) bool {
+	if collection == nil || collection.Cache == nil || req.Method != http.MethodGet {
+		return false
+	}
+
+	var policy = collection.Cache
+
+	w.Header().Set(`Cache-Control`, policy.CacheControl())
+
+	if modified := policy.LastModified(records...); !modified.IsZero() {
+		// HTTP dates only have a resolution of one second
+		modified = modified.UTC().Truncate(time.Second)
+
+		w.Header().Set(`Last-Modified`, modified.Format(http.TimeFormat))
+
+		if since, err := http.ParseTime(req.Header.Get(`If-Modified-Since`)); err == nil && !modified.After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
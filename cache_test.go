@@ -0,0 +1,58 @@
+package pivot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerCachePolicy(t *testing.T) {
+	assert := require.New(t)
+	server := NewServer(`memory://`)
+
+	collection := dal.NewCollection(`countries`, dal.Field{
+		Name: `updated_at`,
+		Type: dal.TimeType,
+	})
+
+	modified := time.Date(2021, 6, 1, 12, 0, 0, 500, time.UTC)
+	record := dal.NewRecord(`nz`).Set(`updated_at`, modified)
+
+	// collections without a policy aren't cached
+	w := httptest.NewRecorder()
+	assert.False(server.applyCachePolicy(w, httptest.NewRequest(`GET`, `/api/collections/countries/records/nz`, nil), collection, record))
+	assert.Empty(w.Header().Get(`Cache-Control`))
+
+	collection.Cache = &dal.CachePolicy{
+		MaxAge:            86400,
+		Immutable:         true,
+		LastModifiedField: `updated_at`,
+	}
+
+	w = httptest.NewRecorder()
+	assert.False(server.applyCachePolicy(w, httptest.NewRequest(`GET`, `/api/collections/countries/records/nz`, nil), collection, record))
+	assert.Equal(`public, max-age=86400, immutable`, w.Header().Get(`Cache-Control`))
+	assert.Equal(`Tue, 01 Jun 2021 12:00:00 GMT`, w.Header().Get(`Last-Modified`))
+
+	// clients with a current copy are told so
+	req := httptest.NewRequest(`GET`, `/api/collections/countries/records/nz`, nil)
+	req.Header.Set(`If-Modified-Since`, `Tue, 01 Jun 2021 12:00:00 GMT`)
+
+	w = httptest.NewRecorder()
+	assert.True(server.applyCachePolicy(w, req, collection, record))
+	assert.Equal(http.StatusNotModified, w.Code)
+
+	req.Header.Set(`If-Modified-Since`, `Mon, 31 May 2021 12:00:00 GMT`)
+
+	w = httptest.NewRecorder()
+	assert.False(server.applyCachePolicy(w, req, collection, record))
+
+	// only GET responses are cached
+	w = httptest.NewRecorder()
+	assert.False(server.applyCachePolicy(w, httptest.NewRequest(`POST`, `/api/collections/countries/query/`, nil), collection, record))
+	assert.Empty(w.Header().Get(`Cache-Control`))
+}
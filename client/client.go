@@ -2,8 +2,11 @@ package client
 
 import (
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/ghetzel/go-stockutil/httputil"
 	"github.com/ghetzel/go-stockutil/sliceutil"
@@ -13,37 +16,65 @@ import (
 )
 
 const DefaultPivotUrl = `http://localhost:29029`
+const ClientUserAgent = `pivot-client/` + util.Version
+
+var DefaultRetryBackoff = 250 * time.Millisecond
 
 type Status = util.Status
 
 type QueryOptions struct {
-	Limit       int      `json:"limit"`
-	Offset      int      `json:"offset"`
-	Sort        []string `json:"sort,omitempty"`
-	Fields      []string `json:"fields,omitempty"`
-	Conjunction string   `json:"conjunction,omitempty"`
+	Limit       int         `json:"limit"`
+	Offset      int         `json:"offset"`
+	Sort        []string    `json:"sort,omitempty"`
+	Fields      []string    `json:"fields,omitempty"`
+	Conjunction string      `json:"conjunction,omitempty"`
+	Links       bool        `json:"links,omitempty"`
+	After       interface{} `json:"after,omitempty"`
+	Consistency string      `json:"consistency,omitempty"`
+	Totals      string      `json:"totals,omitempty"`
 }
 
 type Pivot struct {
 	*httputil.Client
+	Retries      int           // how many times a failed read is retried before giving up
+	RetryBackoff time.Duration // how long to wait before the first retry (doubling after each one)
+	baseUrl      *url.URL
 }
 
-func New(url string) (*Pivot, error) {
-	if url == `` {
-		url = DefaultPivotUrl
+func New(address string) (*Pivot, error) {
+	if address == `` {
+		address = DefaultPivotUrl
+	}
+
+	base, err := url.Parse(address)
+
+	if err != nil {
+		return nil, err
 	}
 
-	if client, err := httputil.NewClient(url); err == nil {
+	if client, err := httputil.NewClient(address); err == nil {
+		// Accept-Encoding is deliberately left unset so that the underlying transport can
+		// negotiate gzip and transparently decompress responses
+		client.SetHeader(`Accept`, `application/json`)
+		client.SetHeader(`User-Agent`, ClientUserAgent)
+
 		return &Pivot{
-			Client: client,
+			Client:       client,
+			RetryBackoff: DefaultRetryBackoff,
+			baseUrl:      base,
 		}, nil
 	} else {
 		return nil, err
 	}
 }
 
+// Set how long each request may take (0 is unlimited).
+func (self *Pivot) SetTimeout(timeout time.Duration) {
+	self.Client.Client().Timeout = timeout
+}
+
 func (self *Pivot) Status() (*Status, error) {
-	if response, err := self.Get(`/api/status`, nil, nil); err == nil {
+	if response, err := self.get(`/api/status`, nil); err == nil {
 		status := Status{}
 
 		if err := self.Decode(response.Body, &status); err == nil {
@@ -57,7 +88,7 @@ func (self *Pivot) Status() (*Status, error) {
 }
 
 func (self *Pivot) Collections() ([]string, error) {
-	if response, err := self.Get(`/api/schema`, nil, nil); err == nil {
+	if response, err := self.get(`/api/schema`, nil); err == nil {
 		var names []string
 
 		if err := self.Decode(response.Body, &names); err == nil {
@@ -80,7 +111,7 @@ func (self *Pivot) DeleteCollection(name string) error {
 }
 
 func (self *Pivot) Collection(name string) (*dal.Collection, error) {
-	if response, err := self.Get(fmt.Sprintf("/api/schema/%s", name), nil, nil); err == nil {
+	if response, err := self.get(fmt.Sprintf("/api/schema/%s", name), nil); err == nil {
 		var collection dal.Collection
 
 		if err := self.Decode(response.Body, &collection); err == nil {
@@ -97,6 +128,78 @@ func (self *Pivot) Query(collection string, query interface{}, options *QueryOpt
 	var response *http.Response
 	var err error
 
+	opts := queryParams(options)
+
+	if typeutil.IsMap(query) {
+		response, err = self.retry(func() (*http.Response, error) {
+			return self.Post(fmt.Sprintf("/api/collections/%s/query/", collection), query, opts, nil)
+		})
+	} else {
+		response, err = self.get(wherePath(collection, query), opts)
+	}
+
+	if err == nil {
+		var recordset dal.RecordSet
+
+		if err := self.Decode(response.Body, &recordset); err == nil {
+			return &recordset, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// Query a collection and write the results to w in the given format (e.g.: "csv", "parquet") as
+// they are received from the server.
+func (self *Pivot) Export(collection string, query interface{}, options *QueryOptions, format string, w io.Writer) error {
+	opts := queryParams(options)
+	opts[`format`] = format
+
+	if options == nil || options.Limit == 0 {
+		delete(opts, `limit`)
+	}
+
+	if response, err := self.get(wherePath(collection, query), opts); err == nil {
+		defer response.Body.Close()
+
+		_, err = io.Copy(w, response.Body)
+		return err
+	} else {
+		return err
+	}
+}
+
+// performs a GET request, retrying it if it fails
+func (self *Pivot) get(path string, params map[string]interface{}) (*http.Response, error) {
+	return self.retry(func() (*http.Response, error) {
+		return self.Get(path, params, nil)
+	})
+}
+
+// runs fn, running it again (up to self.Retries times) for as long as it fails without reaching the
+// server or with a server error, waiting a little longer before each attempt
+func (self *Pivot) retry(fn func() (*http.Response, error)) (*http.Response, error) {
+	var backoff = self.RetryBackoff
+
+	for attempt := 0; ; attempt++ {
+		if response, err := fn(); err == nil {
+			return response, nil
+		} else if attempt >= self.Retries || (response != nil && response.StatusCode < 500) {
+			return response, err
+		} else {
+			if response != nil {
+				response.Body.Close()
+			}
+
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+func queryParams(options *QueryOptions) map[string]interface{} {
 	opts := make(map[string]interface{})
 
 	if options != nil {
@@ -110,39 +213,47 @@ func (self *Pivot) Query(collection string, query interface{}, options *QueryOpt
 		if len(options.Fields) > 0 {
 			opts[`fields`] = strings.Join(options.Fields, `,`)
 		}
-	}
 
-	if typeutil.IsMap(query) {
-		response, err = self.Post(fmt.Sprintf("/api/collections/%s/query/", collection), query, opts, nil)
-	} else if typeutil.IsArray(query) {
-		qS := sliceutil.Stringify(query)
-
-		if len(qS) == 0 {
-			qS = []string{`all`}
+		if options.Links {
+			opts[`links`] = true
 		}
 
-		response, err = self.Get(fmt.Sprintf("/api/collections/%s/where/%s", collection, strings.Join(qS, `/`)), opts, nil)
-	} else {
-		q := typeutil.String(query)
+		if options.After != nil {
+			opts[`after`] = options.After
+		}
 
-		if q == `` {
-			q = `all`
+		if options.Consistency != `` {
+			opts[`consistency`] = options.Consistency
 		}
 
-		response, err = self.Get(fmt.Sprintf("/api/collections/%s/where/%s", collection, q), opts, nil)
+		if options.Totals != `` {
+			opts[`totals`] = options.Totals
+		}
 	}
 
-	if err == nil {
-		var recordset dal.RecordSet
+	return opts
+}
 
-		if err := self.Decode(response.Body, &recordset); err == nil {
-			return &recordset, nil
-		} else {
-			return nil, err
-		}
+// returns the path used to query a collection with a filter spec (given as a string or a list of criteria)
+func wherePath(collection string, query interface{}) string {
+	return fmt.Sprintf("/api/collections/%s/where/%s", collection, querySpec(query))
+}
+
+// returns a filter spec given as a string or a list of criteria
+func querySpec(query interface{}) string {
+	var q string
+
+	if typeutil.IsArray(query) {
+		q = strings.Join(sliceutil.Stringify(query), `/`)
 	} else {
-		return nil, err
+		q = typeutil.String(query)
+	}
+
+	if q == `` {
+		q = `all`
 	}
+
+	return q
 }
 
 func (self *Pivot) Aggregate(collection string, query interface{}) (*dal.RecordSet, error) {
@@ -150,7 +261,7 @@ func (self *Pivot) Aggregate(collection string, query interface{}) (*dal.RecordS
 }
 
 func (self *Pivot) GetRecord(collection string, id interface{}) (*dal.Record, error) {
-	if response, err := self.Get(fmt.Sprintf("/api/collections/%s/records/%v", collection, id), nil, nil); err == nil {
+	if response, err := self.get(fmt.Sprintf("/api/collections/%s/records/%v", collection, id), nil); err == nil {
 		var record dal.Record
 
 		if err := self.Decode(response.Body, &record); err == nil {
@@ -202,3 +313,34 @@ func (self *Pivot) DeleteRecords(collection string, ids ...interface{}) error {
 	), nil, nil)
 	return err
 }
+
+// Returns the absolute URL of the given link relation (default: "self") for a record.  Links
+// embedded in the record by the server are used when present; otherwise the "self" link is derived
+// from the record's collection name and ID.
+func (self *Pivot) LinkFor(record *dal.Record, rel ...string) (string, error) {
+	var href string
+
+	if record == nil {
+		return ``, fmt.Errorf("cannot link to a nil record")
+	}
+
+	name := `self`
+
+	if len(rel) > 0 && rel[0] != `` {
+		name = rel[0]
+	}
+
+	if link, ok := record.Links[name]; ok {
+		href = link.Href
+	} else if name == `self` && record.CollectionName != `` && !typeutil.IsZero(record.ID) {
+		href = dal.RecordPath(record.CollectionName, record.ID)
+	} else {
+		return ``, fmt.Errorf("record does not have a %q link", name)
+	}
+
+	if ref, err := url.Parse(href); err == nil {
+		return self.baseUrl.ResolveReference(ref).String(), nil
+	} else {
+		return ``, err
+	}
+}
@@ -0,0 +1,558 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+var codegenTsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// The name of the Go package generated clients are placed in unless another is given.
+var DefaultGeneratedPackage = `pivotclient`
+
+// Options controlling how typed clients are generated.
+type GenerateOptions struct {
+	// The language to generate a client in (one of: go, typescript).
+	Language string
+
+	// The package name used for generated Go code.
+	Package string
+}
+
+type codegenOperator struct {
+	Operator    string
+	Method      string
+	Description string
+}
+
+type codegenField struct {
+	Name        string
+	GoName      string
+	GoType      string
+	TsName      string
+	TsMethod    string
+	TsType      string
+	Description string
+	IsTime      bool
+	Operators   []codegenOperator
+}
+
+type codegenCollection struct {
+	Name       string
+	GoName     string
+	TsName     string
+	Identity   codegenField
+	Fields     []codegenField
+	Filterable []codegenField
+}
+
+type codegenData struct {
+	Package     string
+	Collections []codegenCollection
+	UsesTime    bool
+}
+
+// Write a client for the given collections that wraps the HTTP API with a type for each
+// collection's records, methods for retrieving and modifying them, and builders for filters on
+// their fields.
+func Generate(w io.Writer, collections []*dal.Collection, options GenerateOptions) error {
+	var data = codegenData{
+		Package: options.Package,
+	}
+
+	if data.Package == `` {
+		data.Package = DefaultGeneratedPackage
+	}
+
+	var sorted = append([]*dal.Collection{}, collections...)
+
+	sort.Slice(sorted, func(i int, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	for _, collection := range sorted {
+		var cc = codegenCollection{
+			Name:     collection.Name,
+			GoName:   codegenIdentifier(collection.Name, true),
+			TsName:   codegenIdentifier(collection.Name, false),
+			Identity: makeCodegenField(collection.GetIdentityFieldName(), collection.IdentityFieldType),
+		}
+
+		cc.Filterable = append(cc.Filterable, cc.Identity)
+
+		for _, field := range collection.Fields {
+			if field.Name == cc.Identity.Name {
+				continue
+			}
+
+			var cf = makeCodegenField(field.Name, field.Type)
+
+			cf.Description = strings.Join(strings.Fields(field.Description), ` `)
+			cc.Fields = append(cc.Fields, cf)
+			cc.Filterable = append(cc.Filterable, cf)
+		}
+
+		for _, cf := range cc.Filterable {
+			if cf.IsTime {
+				data.UsesTime = true
+			}
+		}
+
+		data.Collections = append(data.Collections, cc)
+	}
+
+	switch options.Language {
+	case `go`, `golang`:
+		var buf bytes.Buffer
+
+		if err := goClientTemplate.Execute(&buf, data); err != nil {
+			return err
+		}
+
+		if src, err := format.Source(buf.Bytes()); err == nil {
+			_, err = w.Write(src)
+			return err
+		} else {
+			return fmt.Errorf("generated invalid Go code: %v", err)
+		}
+
+	case `typescript`, `ts`:
+		return tsClientTemplate.Execute(w, data)
+
+	default:
+		return fmt.Errorf("Unsupported client language %q", options.Language)
+	}
+}
+
+func makeCodegenField(name string, fieldType dal.Type) codegenField {
+	var field = codegenField{
+		Name:     name,
+		GoName:   codegenIdentifier(name, true),
+		TsName:   name,
+		TsMethod: codegenIdentifier(name, false),
+		Operators: []codegenOperator{
+			{`is`, `Is`, `is one of`},
+			{`not`, `Not`, `is none of`},
+		},
+	}
+
+	// property names that aren't valid identifiers need to be quoted
+	if !codegenTsIdentifier.MatchString(name) {
+		field.TsName = strconv.Quote(name)
+	}
+
+	switch fieldType {
+	case dal.StringType:
+		field.GoType = `string`
+		field.TsType = `string`
+		field.Operators = append(field.Operators,
+			codegenOperator{`like`, `Like`, `is like one of`},
+			codegenOperator{`prefix`, `Prefix`, `starts with one of`},
+			codegenOperator{`suffix`, `Suffix`, `ends with one of`},
+			codegenOperator{`contains`, `Contains`, `contains one of`},
+		)
+	case dal.IntType:
+		field.GoType = `int64`
+		field.TsType = `number`
+	case dal.FloatType:
+		field.GoType = `float64`
+		field.TsType = `number`
+	case dal.BooleanType:
+		field.GoType = `bool`
+		field.TsType = `boolean`
+	case dal.TimeType:
+		field.GoType = `time.Time`
+		field.TsType = `string`
+		field.IsTime = true
+	case dal.ObjectType:
+		field.GoType = `map[string]interface{}`
+		field.TsType = `Record<string, any>`
+		field.Operators = nil
+	case dal.ArrayType:
+		field.GoType = `[]interface{}`
+		field.TsType = `any[]`
+		field.Operators = nil
+	case dal.RawType:
+		field.GoType = `[]byte`
+		field.TsType = `string`
+		field.Operators = nil
+	default:
+		field.GoType = `interface{}`
+		field.TsType = `any`
+	}
+
+	switch fieldType {
+	case dal.IntType, dal.FloatType, dal.TimeType:
+		field.Operators = append(field.Operators,
+			codegenOperator{`gt`, `Gt`, `is greater than one of`},
+			codegenOperator{`gte`, `Gte`, `is greater than or equal to one of`},
+			codegenOperator{`lt`, `Lt`, `is less than one of`},
+			codegenOperator{`lte`, `Lte`, `is less than or equal to one of`},
+		)
+	}
+
+	return field
+}
+
+// converts a collection or field name into an identifier, e.g.: "user_accounts" becomes
+// "UserAccounts" (exported) or "userAccounts".  Initialisms like "ID" and "URL" are kept uppercase
+// in exported names.
+func codegenIdentifier(name string, exported bool) string {
+	var words = strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var out string
+
+	for i, word := range words {
+		if i == 0 && !exported {
+			out += strings.ToLower(word[:1]) + word[1:]
+			continue
+		}
+
+		switch upper := strings.ToUpper(word); upper {
+		case `ID`, `URL`, `URI`, `API`, `UUID`, `HTTP`, `JSON`, `IP`:
+			if exported {
+				out += upper
+				continue
+			}
+		}
+
+		out += strings.ToUpper(word[:1]) + word[1:]
+	}
+
+	if out == `` {
+		out = `X`
+	} else if unicode.IsDigit(rune(out[0])) {
+		out = `X` + out
+	}
+
+	return out
+}
+
+var goClientTemplate = template.Must(template.New(`go`).Parse(`// Code generated by "pivot generate client"; DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"fmt"
+	"strings"
+{{- if .UsesTime }}
+	"time"
+{{- end }}
+
+	"github.com/ghetzel/pivot/v3/client"
+)
+
+// Client wraps the Pivot HTTP API with typed access to each collection.
+type Client struct {
+	*client.Pivot
+}
+
+// New returns a client connected to the Pivot API at the given URL.
+func New(address string) (*Client, error) {
+	if pivot, err := client.New(address); err == nil {
+		return &Client{
+			Pivot: pivot,
+		}, nil
+	} else {
+		return nil, err
+	}
+}
+
+func joinFilter(criteria []string) string {
+	if len(criteria) == 0 {
+		return ` + "`all`" + `
+	}
+
+	return strings.Join(criteria, ` + "`/`" + `)
+}
+
+func filterCriterion(field string, operator string, values []string) string {
+	return fmt.Sprintf("%s/%s:%s", field, operator, strings.Join(values, ` + "`|`" + `))
+}
+{{ range $c := .Collections }}
+// {{ $c.GoName }}Record is a record in the "{{ $c.Name }}" collection.
+type {{ $c.GoName }}Record struct {
+	{{ $c.Identity.GoName }} {{ $c.Identity.GoType }} ` + "`json:\"{{ $c.Identity.Name }},omitempty\"`" + `
+{{- range $f := $c.Fields }}
+{{- if $f.Description }}
+	// {{ $f.Description }}
+{{- end }}
+	{{ $f.GoName }} {{ $f.GoType }} ` + "`json:\"{{ $f.Name }},omitempty\"`" + `
+{{- end }}
+}
+
+// {{ $c.GoName }}Collection provides access to records in the "{{ $c.Name }}" collection.
+type {{ $c.GoName }}Collection struct {
+	pivot *client.Pivot
+}
+
+// {{ $c.GoName }} returns the "{{ $c.Name }}" collection.
+func (self *Client) {{ $c.GoName }}() *{{ $c.GoName }}Collection {
+	return &{{ $c.GoName }}Collection{
+		pivot: self.Pivot,
+	}
+}
+
+// Filter starts building a filter for querying this collection.
+func (self *{{ $c.GoName }}Collection) Filter() *{{ $c.GoName }}Filter {
+	return &{{ $c.GoName }}Filter{}
+}
+
+// Get retrieves a record by its ID.
+func (self *{{ $c.GoName }}Collection) Get(id {{ $c.Identity.GoType }}) (*{{ $c.GoName }}Record, error) {
+	if record, err := self.pivot.GetRecord(` + "`{{ $c.Name }}`" + `, id); err == nil {
+		var out {{ $c.GoName }}Record
+
+		if err := client.DecodeRecord(record, ` + "`{{ $c.Identity.Name }}`" + `, &out); err == nil {
+			return &out, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// Create inserts new records.
+func (self *{{ $c.GoName }}Collection) Create(items ...*{{ $c.GoName }}Record) error {
+	if records, err := client.EncodeRecords(` + "`{{ $c.Identity.Name }}`" + `, items); err == nil {
+		_, err = self.pivot.CreateRecord(` + "`{{ $c.Name }}`" + `, records...)
+		return err
+	} else {
+		return err
+	}
+}
+
+// Update modifies existing records.
+func (self *{{ $c.GoName }}Collection) Update(items ...*{{ $c.GoName }}Record) error {
+	if records, err := client.EncodeRecords(` + "`{{ $c.Identity.Name }}`" + `, items); err == nil {
+		_, err = self.pivot.UpdateRecord(` + "`{{ $c.Name }}`" + `, records...)
+		return err
+	} else {
+		return err
+	}
+}
+
+// Delete removes records by their IDs.
+func (self *{{ $c.GoName }}Collection) Delete(ids ...{{ $c.Identity.GoType }}) error {
+	for _, id := range ids {
+		if err := self.pivot.DeleteRecords(` + "`{{ $c.Name }}`" + `, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Query returns the records matching the given filter (or all records if the filter is nil.)
+func (self *{{ $c.GoName }}Collection) Query(filter *{{ $c.GoName }}Filter, options *client.QueryOptions) ([]*{{ $c.GoName }}Record, error) {
+	if recordset, err := self.pivot.Query(` + "`{{ $c.Name }}`" + `, filter.String(), options); err == nil {
+		var out = make([]*{{ $c.GoName }}Record, 0, len(recordset.Records))
+
+		for _, record := range recordset.Records {
+			var item {{ $c.GoName }}Record
+
+			if err := client.DecodeRecord(record, ` + "`{{ $c.Identity.Name }}`" + `, &item); err == nil {
+				out = append(out, &item)
+			} else {
+				return nil, err
+			}
+		}
+
+		return out, nil
+	} else {
+		return nil, err
+	}
+}
+
+// {{ $c.GoName }}Filter builds filters for querying the "{{ $c.Name }}" collection.  All criteria
+// must match.
+type {{ $c.GoName }}Filter struct {
+	criteria []string
+}
+
+// String returns the filter in Pivot's filter syntax.
+func (self *{{ $c.GoName }}Filter) String() string {
+	if self == nil {
+		return joinFilter(nil)
+	}
+
+	return joinFilter(self.criteria)
+}
+{{ range $f := $c.Filterable }}{{ range $op := $f.Operators }}
+// {{ $f.GoName }}{{ $op.Method }} matches records whose "{{ $f.Name }}" field {{ $op.Description }} the given values.
+func (self *{{ $c.GoName }}Filter) {{ $f.GoName }}{{ $op.Method }}(values ...{{ $f.GoType }}) *{{ $c.GoName }}Filter {
+	var strs = make([]string, len(values))
+
+	for i, v := range values {
+{{- if $f.IsTime }}
+		strs[i] = v.Format(time.RFC3339Nano)
+{{- else }}
+		strs[i] = fmt.Sprintf("%v", v)
+{{- end }}
+	}
+
+	self.criteria = append(self.criteria, filterCriterion(` + "`{{ $f.Name }}`" + `, ` + "`{{ $op.Operator }}`" + `, strs))
+	return self
+}
+{{ end }}{{ end }}{{ end }}`))
+
+var tsClientTemplate = template.Must(template.New(`typescript`).Parse(`// Code generated by "pivot generate client"; DO NOT EDIT.
+
+export interface QueryOptions {
+  limit?: number;
+  offset?: number;
+  sort?: string[];
+  fields?: string[];
+}
+
+interface PivotRecord {
+  id?: any;
+  fields?: Record<string, any>;
+}
+
+function fromRecord<T>(identity: string, record: PivotRecord): T {
+  return { ...(record.fields || {}), [identity]: record.id } as any;
+}
+
+function toRecord(identity: string, item: Record<string, any>): PivotRecord {
+  const { [identity]: id, ...fields } = item;
+  return { id: id, fields: fields };
+}
+
+function criterion(field: string, operator: string, values: any[]): string {
+  return field + '/' + operator + ':' + values.map((v) => String(v)).join('|');
+}
+
+export class PivotClient {
+  constructor(
+    public baseUrl: string = 'http://localhost:29029',
+    private fetchFn: typeof fetch = fetch,
+  ) {}
+
+  async request<T>(method: string, path: string, body?: any, params?: Record<string, string>): Promise<T> {
+    let url = this.baseUrl.replace(/\/+$/, '') + path;
+
+    if (params && Object.keys(params).length > 0) {
+      url += '?' + new URLSearchParams(params).toString();
+    }
+
+    const response = await this.fetchFn(url, {
+      method: method,
+      headers: {
+        Accept: 'application/json',
+        'Content-Type': 'application/json',
+      },
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+
+    const text = await response.text();
+    const data = text ? JSON.parse(text) : null;
+
+    if (!response.ok) {
+      throw new Error((data && data.error) || response.status + ' ' + response.statusText);
+    }
+
+    return data as T;
+  }
+{{ range $c := .Collections }}
+  get {{ $c.TsName }}(): {{ $c.GoName }}Collection {
+    return new {{ $c.GoName }}Collection(this);
+  }
+{{ end -}}
+}
+{{ range $c := .Collections }}
+/** A record in the "{{ $c.Name }}" collection. */
+export interface {{ $c.GoName }}Record {
+  {{ $c.Identity.TsName }}?: {{ $c.Identity.TsType }};
+{{- range $f := $c.Fields }}
+{{- if $f.Description }}
+  /** {{ $f.Description }} */
+{{- end }}
+  {{ $f.TsName }}?: {{ $f.TsType }};
+{{- end }}
+}
+
+/** Builds filters for querying the "{{ $c.Name }}" collection.  All criteria must match. */
+export class {{ $c.GoName }}Filter {
+  private criteria: string[] = [];
+
+  toString(): string {
+    return this.criteria.length > 0 ? this.criteria.join('/') : 'all';
+  }
+{{ range $f := $c.Filterable }}{{ range $op := $f.Operators }}
+  {{ $f.TsMethod }}{{ $op.Method }}(...values: {{ $f.TsType }}[]): this {
+    this.criteria.push(criterion('{{ $f.Name }}', '{{ $op.Operator }}', values));
+    return this;
+  }
+{{ end }}{{ end -}}
+}
+
+/** Provides access to records in the "{{ $c.Name }}" collection. */
+export class {{ $c.GoName }}Collection {
+  constructor(private client: PivotClient) {}
+
+  filter(): {{ $c.GoName }}Filter {
+    return new {{ $c.GoName }}Filter();
+  }
+
+  async get(id: {{ $c.Identity.TsType }}): Promise<{{ $c.GoName }}Record> {
+    const record = await this.client.request<PivotRecord>('GET', '/api/collections/{{ $c.Name }}/records/' + encodeURIComponent(String(id)));
+    return fromRecord<{{ $c.GoName }}Record>('{{ $c.Identity.Name }}', record);
+  }
+
+  async create(...items: {{ $c.GoName }}Record[]): Promise<{{ $c.GoName }}Record[]> {
+    const result = await this.client.request<{ records: PivotRecord[] }>('POST', '/api/collections/{{ $c.Name }}/records', {
+      records: items.map((item) => toRecord('{{ $c.Identity.Name }}', item)),
+    });
+
+    return (result.records || []).map((r) => fromRecord<{{ $c.GoName }}Record>('{{ $c.Identity.Name }}', r));
+  }
+
+  async update(...items: {{ $c.GoName }}Record[]): Promise<{{ $c.GoName }}Record[]> {
+    const result = await this.client.request<{ records: PivotRecord[] }>('PUT', '/api/collections/{{ $c.Name }}/records', {
+      records: items.map((item) => toRecord('{{ $c.Identity.Name }}', item)),
+    });
+
+    return (result.records || []).map((r) => fromRecord<{{ $c.GoName }}Record>('{{ $c.Identity.Name }}', r));
+  }
+
+  async delete(...ids: {{ $c.Identity.TsType }}[]): Promise<void> {
+    for (const id of ids) {
+      await this.client.request<void>('DELETE', '/api/collections/{{ $c.Name }}/records/' + encodeURIComponent(String(id)));
+    }
+  }
+
+  async query(filter?: {{ $c.GoName }}Filter, options?: QueryOptions): Promise<{{ $c.GoName }}Record[]> {
+    const params: Record<string, string> = {};
+
+    if (options) {
+      if (options.limit !== undefined) params['limit'] = String(options.limit);
+      if (options.offset !== undefined) params['offset'] = String(options.offset);
+      if (options.sort) params['sort'] = options.sort.join(',');
+      if (options.fields) params['fields'] = options.fields.join(',');
+    }
+
+    const result = await this.client.request<{ records: PivotRecord[] }>(
+      'GET',
+      '/api/collections/{{ $c.Name }}/where/' + (filter || new {{ $c.GoName }}Filter()).toString(),
+      undefined,
+      params,
+    );
+
+    return (result.records || []).map((r) => fromRecord<{{ $c.GoName }}Record>('{{ $c.Identity.Name }}', r));
+  }
+}
+{{ end }}`))
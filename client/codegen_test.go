@@ -0,0 +1,96 @@
+package client
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateClient(t *testing.T) {
+	assert := require.New(t)
+
+	collections := []*dal.Collection{
+		dal.NewCollection(`user_accounts`, dal.Field{
+			Name:        `name`,
+			Type:        dal.StringType,
+			Description: `The user's display name`,
+		}, dal.Field{
+			Name: `created_at`,
+			Type: dal.TimeType,
+		}, dal.Field{
+			Name: `score`,
+			Type: dal.FloatType,
+		}),
+	}
+
+	var out bytes.Buffer
+
+	assert.NoError(Generate(&out, collections, GenerateOptions{
+		Language: `go`,
+		Package:  `accounts`,
+	}))
+
+	src := out.String()
+	assert.Contains(src, "package accounts\n")
+	assert.Contains(src, `"time"`)
+	assert.Contains(src, "type UserAccountsRecord struct {")
+	assert.Contains(src, "// The user's display name")
+	assert.Contains(src, "CreatedAt time.Time `json:\"created_at,omitempty\"`")
+	assert.Contains(src, "func (self *Client) UserAccounts() *UserAccountsCollection {")
+	assert.Contains(src, "func (self *UserAccountsCollection) Get(id int64) (*UserAccountsRecord, error) {")
+	assert.Contains(src, "func (self *UserAccountsFilter) NamePrefix(values ...string) *UserAccountsFilter {")
+	assert.Contains(src, "func (self *UserAccountsFilter) ScoreGte(values ...float64) *UserAccountsFilter {")
+	assert.NotContains(src, "ScorePrefix")
+
+	out.Reset()
+
+	assert.NoError(Generate(&out, collections, GenerateOptions{
+		Language: `typescript`,
+	}))
+
+	src = out.String()
+	assert.Contains(src, "export interface UserAccountsRecord {")
+	assert.Contains(src, "  created_at?: string;")
+	assert.Contains(src, "  get userAccounts(): UserAccountsCollection {")
+	assert.Contains(src, "  createdAtLt(...values: string[]): this {")
+	assert.Contains(src, "'/api/collections/user_accounts/where/'")
+
+	assert.Error(Generate(&out, collections, GenerateOptions{
+		Language: `cobol`,
+	}))
+}
+
+func TestEncodeDecodeRecord(t *testing.T) {
+	assert := require.New(t)
+
+	type item struct {
+		ID   int64  `json:"id,omitempty"`
+		Name string `json:"name,omitempty"`
+	}
+
+	record, err := EncodeRecord(`id`, &item{
+		ID:   42,
+		Name: `test`,
+	})
+
+	assert.NoError(err)
+	assert.EqualValues(42, record.ID)
+	assert.Equal(`test`, record.Get(`name`))
+	assert.NotContains(record.Fields, `id`)
+
+	var decoded item
+
+	assert.NoError(DecodeRecord(record, `id`, &decoded))
+	assert.Equal(item{
+		ID:   42,
+		Name: `test`,
+	}, decoded)
+
+	// zero IDs are left for the server to generate
+	records, err := EncodeRecords(`id`, []*item{{Name: `new`}})
+	assert.NoError(err)
+	assert.Len(records, 1)
+	assert.Nil(records[0].ID)
+}
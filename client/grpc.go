@@ -0,0 +1,217 @@
+package client
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const DefaultGRPCAddress = `localhost:29030`
+
+// A client for pivot's gRPC service (see rpc/pivot.proto), which streams query results as they are
+// read instead of waiting for the whole recordset.
+type GRPC struct {
+	Timeout time.Duration // how long each call may take (0 is unlimited)
+	conn    *grpc.ClientConn
+}
+
+// Connect to a pivot server's gRPC service.  If no dial options are given, the connection is made
+// without TLS.
+func NewGRPC(address string, options ...grpc.DialOption) (*GRPC, error) {
+	if address == `` {
+		address = DefaultGRPCAddress
+	}
+
+	if len(options) == 0 {
+		options = []grpc.DialOption{grpc.WithInsecure()}
+	}
+
+	options = append(options, grpc.WithUserAgent(ClientUserAgent))
+
+	if conn, err := grpc.Dial(address, options...); err == nil {
+		return &GRPC{
+			conn: conn,
+		}, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Close the connection to the server.
+func (self *GRPC) Close() error {
+	return self.conn.Close()
+}
+
+func (self *GRPC) Status() (*Status, error) {
+	var status Status
+	var out = new(structpb.Struct)
+
+	if err := self.invoke(rpc.MethodStatus, new(emptypb.Empty), out); err == nil {
+		return &status, rpc.Decode(out, &status)
+	} else {
+		return nil, err
+	}
+}
+
+func (self *GRPC) Collections() ([]string, error) {
+	var out = new(structpb.ListValue)
+
+	if err := self.invoke(rpc.MethodCollections, new(emptypb.Empty), out); err == nil {
+		var names = make([]string, 0, len(out.GetValues()))
+
+		for _, value := range out.GetValues() {
+			names = append(names, value.GetStringValue())
+		}
+
+		return names, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *GRPC) Collection(name string) (*dal.Collection, error) {
+	var collection dal.Collection
+	var out = new(structpb.Struct)
+
+	if err := self.invoke(rpc.MethodGetCollection, wrapperspb.String(name), out); err == nil {
+		return &collection, rpc.Decode(out, &collection)
+	} else {
+		return nil, err
+	}
+}
+
+func (self *GRPC) GetRecord(collection string, id interface{}, fields ...string) (*dal.Record, error) {
+	var record dal.Record
+	var out = new(structpb.Struct)
+
+	if err := self.call(rpc.MethodRetrieve, &rpc.RetrieveRequest{
+		Collection: collection,
+		ID:         id,
+		Fields:     fields,
+	}, out); err == nil {
+		return &record, rpc.Decode(out, &record)
+	} else {
+		return nil, err
+	}
+}
+
+func (self *GRPC) CreateRecord(collection string, records ...*dal.Record) (*dal.RecordSet, error) {
+	return self.write(rpc.MethodCreate, collection, records)
+}
+
+func (self *GRPC) UpdateRecord(collection string, records ...*dal.Record) (*dal.RecordSet, error) {
+	return self.write(rpc.MethodUpdate, collection, records)
+}
+
+func (self *GRPC) DeleteRecords(collection string, ids ...interface{}) error {
+	return self.call(rpc.MethodDelete, &rpc.DeleteRequest{
+		Collection: collection,
+		IDs:        ids,
+	}, new(emptypb.Empty))
+}
+
+// Query a collection, gathering all of the streamed results into a recordset.  Unlike the REST
+// API, results are unlimited unless options.Limit is set.
+func (self *GRPC) Query(collection string, query interface{}, options *QueryOptions) (*dal.RecordSet, error) {
+	var recordset = dal.NewRecordSet()
+
+	if err := self.QueryFunc(collection, query, options, func(record *dal.Record) error {
+		recordset.Push(record)
+		return nil
+	}); err == nil {
+		return recordset, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Query a collection, calling fn with each record as it is received.  Returning an error from fn
+// stops the query and returns that error.
+func (self *GRPC) QueryFunc(collection string, query interface{}, options *QueryOptions, fn func(record *dal.Record) error) error {
+	var request = rpc.QueryRequest{
+		Collection: collection,
+		Filter:     querySpec(query),
+	}
+
+	if options != nil {
+		request.Limit = options.Limit
+		request.Offset = options.Offset
+		request.Sort = options.Sort
+		request.Fields = options.Fields
+		request.Conjunction = options.Conjunction
+		request.After = options.After
+	}
+
+	in, err := rpc.Encode(&request)
+
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := self.context()
+	defer cancel()
+
+	if stream, err := rpc.NewQueryStream(ctx, self.conn, in); err == nil {
+		for {
+			var out = new(structpb.Struct)
+			var record dal.Record
+
+			if err := stream.RecvMsg(out); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			} else if err := rpc.Decode(out, &record); err != nil {
+				return err
+			} else if err := fn(&record); err != nil {
+				return err
+			}
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *GRPC) write(method string, collection string, records []*dal.Record) (*dal.RecordSet, error) {
+	var recordset dal.RecordSet
+	var out = new(structpb.Struct)
+
+	if err := self.call(method, &rpc.RecordsRequest{
+		Collection: collection,
+		Records:    records,
+	}, out); err == nil {
+		return &recordset, rpc.Decode(out, &recordset)
+	} else {
+		return nil, err
+	}
+}
+
+// encodes the request before invoking the method with it
+func (self *GRPC) call(method string, request interface{}, out interface{}) error {
+	if in, err := rpc.Encode(request); err == nil {
+		return self.invoke(method, in, out)
+	} else {
+		return err
+	}
+}
+
+func (self *GRPC) invoke(method string, in interface{}, out interface{}) error {
+	ctx, cancel := self.context()
+	defer cancel()
+
+	return self.conn.Invoke(ctx, method, in, out)
+}
+
+func (self *GRPC) context() (context.Context, context.CancelFunc) {
+	if self.Timeout > 0 {
+		return context.WithTimeout(context.Background(), self.Timeout)
+	}
+
+	return context.WithCancel(context.Background())
+}
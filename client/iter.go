@@ -0,0 +1,73 @@
+package client
+
+import (
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// Iterates over the results of a query, requesting each page of results from the server as the
+// previous one is exhausted.
+type RecordIterator struct {
+	pivot      *Pivot
+	collection string
+	query      interface{}
+	options    QueryOptions
+	page       []*dal.Record
+	record     *dal.Record
+	err        error
+	done       bool
+}
+
+// Query a collection, returning an iterator that transparently pages through all of the matching
+// records.  If options.Limit is set, it is used as the page size; otherwise the server's default
+// limit is.  Paging starts at options.Offset.
+func (self *Pivot) QueryIter(collection string, query interface{}, options *QueryOptions) *RecordIterator {
+	var iter = &RecordIterator{
+		pivot:      self,
+		collection: collection,
+		query:      query,
+	}
+
+	if options != nil {
+		iter.options = *options
+	}
+
+	return iter
+}
+
+// Advance to the next record, retrieving the next page of results if necessary.  Returns false
+// once all records have been read or an error occurs (see Err).
+func (self *RecordIterator) Next() bool {
+	if self.err != nil {
+		return false
+	}
+
+	if len(self.page) == 0 && !self.done {
+		if results, err := self.pivot.Query(self.collection, self.query, &self.options); err == nil {
+			self.page = results.Records
+			self.options.Offset += len(results.Records)
+			self.done = (len(results.Records) == 0)
+		} else {
+			self.err = err
+			return false
+		}
+	}
+
+	if len(self.page) > 0 {
+		self.record = self.page[0]
+		self.page = self.page[1:]
+		return true
+	}
+
+	self.record = nil
+	return false
+}
+
+// Return the current record.
+func (self *RecordIterator) Record() *dal.Record {
+	return self.record
+}
+
+// Return the error that stopped iteration, if any.
+func (self *RecordIterator) Err() error {
+	return self.err
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryIter(t *testing.T) {
+	assert := require.New(t)
+	var requests int
+	var failures = 1
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+
+		// fail the first request to exercise retries
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		offset, _ := strconv.Atoi(req.URL.Query().Get(`offset`))
+		limit, _ := strconv.Atoi(req.URL.Query().Get(`limit`))
+		recordset := dal.NewRecordSet()
+
+		for i := offset; i < offset+limit && i < 5; i++ {
+			recordset.Push(dal.NewRecord(i + 1))
+		}
+
+		json.NewEncoder(w).Encode(recordset)
+	}))
+
+	defer server.Close()
+
+	pc, err := New(server.URL)
+	assert.NoError(err)
+	pc.Retries = 1
+	pc.RetryBackoff = 0
+
+	var ids []int64
+	records := pc.QueryIter(`things`, nil, &QueryOptions{
+		Limit: 2,
+	})
+
+	for records.Next() {
+		ids = append(ids, typeutil.Int(records.Record().ID))
+	}
+
+	assert.NoError(records.Err())
+	assert.Equal([]int64{1, 2, 3, 4, 5}, ids)
+
+	// one failure, three full or partial pages, and an empty page
+	assert.Equal(5, requests)
+}
+
+func TestQueryIterError(t *testing.T) {
+	assert := require.New(t)
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+
+	defer server.Close()
+
+	pc, err := New(server.URL)
+	assert.NoError(err)
+	pc.Retries = 3
+	pc.RetryBackoff = 0
+
+	records := pc.QueryIter(`things`, nil, nil)
+
+	assert.False(records.Next())
+	assert.Error(records.Err())
+	assert.Nil(records.Record())
+
+	// client errors are not retried
+	assert.Equal(1, requests)
+}
@@ -0,0 +1,281 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// The name of the Go package generated models are placed in unless another is given.
+var DefaultModelsPackage = `models`
+
+type modelField struct {
+	GoName      string
+	GoType      string
+	Tag         string
+	Description string
+	Definition  []string // the properties set in the field's dal.Field literal
+}
+
+type modelCollection struct {
+	Name       string
+	GoName     string
+	Definition []string // the properties set in the collection's dal.Collection literal
+	Fields     []modelField
+}
+
+type modelData struct {
+	Package     string
+	Collections []modelCollection
+	UsesTime    bool
+}
+
+// Write Go source declaring, for each of the given collections, a struct whose `pivot` tags
+// describe the collection's fields (such that dal.CollectionFromStruct rebuilds it), and a
+// dal.Collection literal defining the collection.
+func GenerateModels(w io.Writer, collections []*dal.Collection, pkg string) error {
+	var data = modelData{
+		Package: pkg,
+	}
+
+	if data.Package == `` {
+		data.Package = DefaultModelsPackage
+	}
+
+	var sorted = append([]*dal.Collection{}, collections...)
+
+	sort.Slice(sorted, func(i int, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	for _, collection := range sorted {
+		var identity = collection.GetIdentityFieldName()
+		var mc = modelCollection{
+			Name:   collection.Name,
+			GoName: codegenIdentifier(collection.Name, true),
+		}
+
+		mc.Definition = append(mc.Definition,
+			`Name: `+codegenGoString(collection.Name),
+			`IdentityField: `+codegenGoString(identity),
+			`IdentityFieldType: `+modelTypeConstant(collection.IdentityFieldType),
+		)
+
+		if collection.AutoIdentity != `` {
+			mc.Definition = append(mc.Definition, `AutoIdentity: `+codegenGoString(collection.AutoIdentity))
+		}
+
+		var identityTag = identity + `,identity`
+
+		if collection.AutoIdentity != `` && !strings.Contains(collection.AutoIdentity, `,`) {
+			identityTag += `,autoidentity=` + collection.AutoIdentity
+		}
+
+		mc.Fields = append(mc.Fields, modelField{
+			GoName: codegenIdentifier(identity, true),
+			GoType: makeCodegenField(identity, collection.IdentityFieldType).GoType,
+			Tag:    identityTag,
+		})
+
+		if mc.Fields[0].GoType == `time.Time` {
+			data.UsesTime = true
+		}
+
+		for _, field := range collection.Fields {
+			if field.Name == identity {
+				continue
+			}
+
+			var mf = modelField{
+				GoName:      codegenIdentifier(field.Name, true),
+				GoType:      makeCodegenField(field.Name, field.Type).GoType,
+				Description: strings.Join(strings.Fields(field.Description), ` `),
+			}
+
+			if mf.GoType == `time.Time` {
+				data.UsesTime = true
+			}
+
+			var options = []string{field.Name}
+
+			mf.Definition = append(mf.Definition,
+				`Name: `+codegenGoString(field.Name),
+				`Type: `+modelTypeConstant(field.Type),
+			)
+
+			// types that don't follow from the Go type need to be given explicitly
+			if field.Type == dal.GeopointType {
+				options = append(options, `type=`+string(field.Type))
+			}
+
+			if mf.Description != `` {
+				mf.Definition = append(mf.Definition, `Description: `+codegenGoString(mf.Description))
+			}
+
+			if field.NativeType != `` {
+				mf.Definition = append(mf.Definition, `NativeType: `+codegenGoString(field.NativeType))
+			}
+
+			if field.Length > 0 {
+				mf.Definition = append(mf.Definition, fmt.Sprintf("Length: %d", field.Length))
+				options = append(options, fmt.Sprintf("length=%d", field.Length))
+			}
+
+			if field.Precision > 0 {
+				mf.Definition = append(mf.Definition, fmt.Sprintf("Precision: %d", field.Precision))
+			}
+
+			for _, flag := range []struct {
+				set      bool
+				property string
+				option   string
+			}{
+				{field.Key, `Key`, `key`},
+				{field.Required, `Required`, `required`},
+				{field.Unique, `Unique`, `unique`},
+				{field.Indexed, `Indexed`, `index`},
+				{field.ReadOnly, `ReadOnly`, `readonly`},
+			} {
+				if flag.set {
+					mf.Definition = append(mf.Definition, flag.property+`: true`)
+					options = append(options, flag.option)
+				}
+			}
+
+			if literal, ok := modelDefaultValue(field.DefaultValue); ok {
+				mf.Definition = append(mf.Definition, `DefaultValue: `+literal)
+
+				// options are comma-separated, and the tag is quoted
+				if value := typeutil.String(field.DefaultValue); !strings.ContainsAny(value, ",\"`") {
+					options = append(options, `default=`+value)
+				}
+			}
+
+			if related, ok := field.BelongsTo.(string); ok && related != `` {
+				mf.Definition = append(mf.Definition, `BelongsTo: `+codegenGoString(related))
+				options = append(options, `belongs_to=`+related)
+			}
+
+			mf.Tag = strings.Join(options, `,`)
+			mc.Fields = append(mc.Fields, mf)
+		}
+
+		data.Collections = append(data.Collections, mc)
+	}
+
+	var buf bytes.Buffer
+
+	if err := goModelsTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+
+	if src, err := format.Source(buf.Bytes()); err == nil {
+		_, err = w.Write(src)
+		return err
+	} else {
+		return fmt.Errorf("generated invalid Go code: %v", err)
+	}
+}
+
+// returns the name of the dal constant for the given field type
+func modelTypeConstant(fieldType dal.Type) string {
+	switch fieldType {
+	case dal.StringType:
+		return `dal.StringType`
+	case dal.AutoType:
+		return `dal.AutoType`
+	case dal.BooleanType:
+		return `dal.BooleanType`
+	case dal.IntType:
+		return `dal.IntType`
+	case dal.FloatType:
+		return `dal.FloatType`
+	case dal.TimeType:
+		return `dal.TimeType`
+	case dal.ObjectType:
+		return `dal.ObjectType`
+	case dal.RawType:
+		return `dal.RawType`
+	case dal.ArrayType:
+		return `dal.ArrayType`
+	case dal.GeopointType:
+		return `dal.GeopointType`
+	default:
+		return fmt.Sprintf("dal.Type(%s)", codegenGoString(string(fieldType)))
+	}
+}
+
+// returns a Go literal for the given default value, if it is one that can be written as such
+func modelDefaultValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return ``, false
+	case string:
+		return codegenGoString(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v), true
+	case float32, float64:
+		return fmt.Sprintf("float64(%v)", v), true
+	default:
+		return ``, false
+	}
+}
+
+// quotes the given string with backticks where possible
+func codegenGoString(value string) string {
+	if strings.ContainsAny(value, "`\r\n") {
+		return strconv.Quote(value)
+	}
+
+	return "`" + value + "`"
+}
+
+var goModelsTemplate = template.Must(template.New(`models`).Parse(`// Code generated by "pivot codegen"; DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+{{- if .UsesTime }}
+	"time"
+{{ end }}
+	"github.com/ghetzel/pivot/v3/dal"
+)
+{{ range $c := .Collections }}
+// {{ $c.GoName }} is a record in the "{{ $c.Name }}" collection.
+type {{ $c.GoName }} struct {
+{{- range $f := $c.Fields }}
+{{- if $f.Description }}
+	// {{ $f.Description }}
+{{- end }}
+	{{ $f.GoName }} {{ $f.GoType }} ` + "`pivot:\"{{ $f.Tag }}\"`" + `
+{{- end }}
+}
+
+// {{ $c.GoName }}Collection defines the "{{ $c.Name }}" collection.
+var {{ $c.GoName }}Collection = &dal.Collection{
+{{- range $p := $c.Definition }}
+	{{ $p }},
+{{- end }}
+	Fields: []dal.Field{
+{{- range $f := $c.Fields }}
+{{- if $f.Definition }}
+		{
+{{- range $p := $f.Definition }}
+			{{ $p }},
+{{- end }}
+		},
+{{- end }}
+{{- end }}
+	},
+}
+{{ end }}`))
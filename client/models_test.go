@@ -0,0 +1,87 @@
+package client
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateModels(t *testing.T) {
+	assert := require.New(t)
+
+	collections := []*dal.Collection{
+		&dal.Collection{
+			Name:              `user_accounts`,
+			IdentityField:     `id`,
+			IdentityFieldType: dal.StringType,
+			AutoIdentity:      `uuid`,
+			Fields: []dal.Field{
+				{
+					Name:        `email`,
+					Type:        dal.StringType,
+					Description: `The user's email address`,
+					Required:    true,
+					Unique:      true,
+					Length:      255,
+				}, {
+					Name:         `enabled`,
+					Type:         dal.BooleanType,
+					DefaultValue: true,
+				}, {
+					Name:         `created_at`,
+					Type:         dal.TimeType,
+					DefaultValue: `now`,
+				}, {
+					Name:      `group_id`,
+					Type:      dal.IntType,
+					BelongsTo: `groups`,
+				}, {
+					Name: `location`,
+					Type: dal.GeopointType,
+				},
+			},
+		},
+		dal.NewCollection(`groups`, dal.Field{
+			Name:         `weight`,
+			Type:         dal.FloatType,
+			DefaultValue: 1.0,
+		}),
+	}
+
+	var out bytes.Buffer
+
+	assert.NoError(GenerateModels(&out, collections, `accounts`))
+
+	// gofmt aligns fields, so compare with runs of whitespace collapsed
+	src := strings.Join(strings.Fields(out.String()), ` `)
+	assert.Contains(src, `package accounts`)
+	assert.Contains(src, `"time"`)
+
+	// collections are written in order of name
+	assert.True(strings.Index(src, `type Groups struct`) < strings.Index(src, `type UserAccounts struct`))
+
+	assert.Contains(src, "ID string `pivot:\"id,identity,autoidentity=uuid\"`")
+	assert.Contains(src, `// The user's email address`)
+	assert.Contains(src, "Email string `pivot:\"email,length=255,required,unique\"`")
+	assert.Contains(src, "Enabled bool `pivot:\"enabled,default=true\"`")
+	assert.Contains(src, "CreatedAt time.Time `pivot:\"created_at,default=now\"`")
+	assert.Contains(src, "GroupID int64 `pivot:\"group_id,belongs_to=groups\"`")
+	assert.Contains(src, "Location interface{} `pivot:\"location,type=geopoint\"`")
+
+	assert.Contains(src, "var UserAccountsCollection = &dal.Collection{")
+	assert.Contains(src, "AutoIdentity: `uuid`,")
+	assert.Contains(src, "Type: dal.TimeType,")
+	assert.Contains(src, "DefaultValue: `now`,")
+	assert.Contains(src, "DefaultValue: float64(1),")
+	assert.Contains(src, "BelongsTo: `groups`,")
+
+	// the time package is only imported when it's used
+	out.Reset()
+
+	assert.NoError(GenerateModels(&out, collections[1:], ``))
+	assert.Contains(out.String(), `package models`)
+	assert.NotContains(out.String(), `"time"`)
+}
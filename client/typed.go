@@ -0,0 +1,71 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// Populate a struct from a record, treating the record's fields (and its ID, under the given field
+// name) as the struct's JSON representation.  This is used by generated clients.
+func DecodeRecord(record *dal.Record, identityField string, into interface{}) error {
+	if record == nil {
+		return fmt.Errorf("cannot decode a nil record")
+	}
+
+	var data = make(map[string]interface{})
+
+	for k, v := range record.Fields {
+		data[k] = v
+	}
+
+	data[identityField] = record.ID
+
+	if encoded, err := json.Marshal(data); err == nil {
+		return json.Unmarshal(encoded, into)
+	} else {
+		return err
+	}
+}
+
+// Convert a struct into a record using its JSON representation, taking the record's ID from the
+// given field.  A zero ID is left unset so that the server can generate one.
+func EncodeRecord(identityField string, in interface{}) (*dal.Record, error) {
+	var data = make(map[string]interface{})
+
+	if encoded, err := json.Marshal(in); err == nil {
+		if err := json.Unmarshal(encoded, &data); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+
+	var id = data[identityField]
+
+	delete(data, identityField)
+
+	if typeutil.IsZero(id) {
+		id = nil
+	}
+
+	return dal.NewRecord(id, data), nil
+}
+
+// Convert a slice of structs into records.
+func EncodeRecords(identityField string, in interface{}) ([]*dal.Record, error) {
+	var records = make([]*dal.Record, 0)
+
+	for _, item := range sliceutil.Sliceify(in) {
+		if record, err := EncodeRecord(identityField, item); err == nil {
+			records = append(records, record)
+		} else {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
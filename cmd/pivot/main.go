@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/ghetzel/cli"
 	"github.com/ghetzel/go-stockutil/fileutil"
@@ -20,7 +22,6 @@ import (
 	"github.com/ghetzel/pivot/v3/dal"
 	"github.com/ghetzel/pivot/v3/filter"
 	"github.com/ghetzel/pivot/v3/filter/generators"
-	"github.com/ghetzel/pivot/v3/mapper"
 )
 
 func main() {
@@ -95,6 +96,103 @@ func main() {
 					Usage: `The path to the UI directory`,
 					Value: pivot.DefaultUiDirectory,
 				},
+				cli.BoolFlag{
+					Name:  `no-ui`,
+					Usage: `Only serve the REST API, responding to all other requests with a 404 Not Found.`,
+				},
+				cli.BoolFlag{
+					Name:  `track-usage`,
+					Usage: `Record which collections and fields are read, written, and queried.`,
+				},
+				cli.BoolFlag{
+					Name:  `persist-collections`,
+					Usage: `Store collection definitions in the backend so they are known on subsequent runs.`,
+				},
+				cli.BoolFlag{
+					Name:  `links`,
+					Usage: `Embed hypermedia links (self, collection, related records) in record responses.`,
+				},
+				cli.BoolFlag{
+					Name:  `graphql`,
+					Usage: `Serve a GraphQL API for the backend's collections at /api/graphql.`,
+				},
+				cli.StringFlag{
+					Name:  `grpc-address`,
+					Usage: `Also serve the gRPC API at this address (e.g.: ":29030").`,
+				},
+				cli.IntFlag{
+					Name:  `max-embedded-records`,
+					Usage: `The most related records to embed at each key of a record when expanding relationships (0 is unlimited).`,
+				},
+				cli.IntFlag{
+					Name:  `max-embedded-bytes`,
+					Usage: `The most bytes of related records to embed at each key of a record when expanding relationships (0 is unlimited).`,
+				},
+				cli.StringFlag{
+					Name:  `tls-cert`,
+					Usage: `Path to a TLS certificate; serving over TLS also enables HTTP/2.`,
+				},
+				cli.StringFlag{
+					Name:  `tls-key`,
+					Usage: `Path to the private key for the TLS certificate.`,
+				},
+				cli.BoolFlag{
+					Name:  `no-compression`,
+					Usage: `Disable gzip/deflate compression of API responses.`,
+				},
+				cli.BoolFlag{
+					Name:  `no-coalescing`,
+					Usage: `Execute every query request separately instead of sharing the results of identical concurrent queries.`,
+				},
+				cli.Float64Flag{
+					Name:  `rate-limit`,
+					Usage: `The number of API requests per second each client may make (0 is unlimited).`,
+				},
+				cli.IntFlag{
+					Name:  `rate-burst`,
+					Usage: `The number of requests a client may make in a burst above the rate limit (defaults to the rate limit).`,
+				},
+				cli.IntFlag{
+					Name:  `max-body-size`,
+					Usage: `The largest request body, in bytes, accepted when writing records (0 is unlimited).`,
+				},
+				cli.StringSliceFlag{
+					Name:  `route, R`,
+					Usage: `A PATTERN=CONNECTION_STRING pair specifying that collections whose names match the glob PATTERN are served from another backend.`,
+				},
+				cli.StringSliceFlag{
+					Name:  `join-backend, j`,
+					Usage: `A NAME=CONNECTION_STRING pair specifying an additional backend that joined queries can use as their right-hand side.`,
+				},
+				cli.DurationFlag{
+					Name:  `query-timeout`,
+					Usage: `The longest that reads, queries, and aggregations may take before failing (0 disables the deadline).`,
+				},
+				cli.DurationFlag{
+					Name:  `write-timeout`,
+					Usage: `The longest that inserts, updates, and deletes may take before failing (0 disables the deadline).`,
+				},
+				cli.DurationFlag{
+					Name:  `stats-interval`,
+					Usage: `How often to count the records in each collection, checking the counts against the alerts in the configuration file (0 disables).`,
+				},
+				cli.DurationFlag{
+					Name:  `health-check-interval`,
+					Usage: `How often to check that the backend and indexer are reachable, reconnecting if they aren't (e.g.: "10s"; health checks are disabled if not given).`,
+				},
+				cli.StringFlag{
+					Name:  `mirror-to`,
+					Usage: `The connection string of a secondary backend to mirror requests to, logging any results that differ from the primary backend.`,
+				},
+				cli.Float64Flag{
+					Name:  `mirror-percent`,
+					Usage: `The percentage of reads to repeat against the mirror backend.`,
+					Value: 100,
+				},
+				cli.BoolFlag{
+					Name:  `mirror-writes`,
+					Usage: `Also apply inserts, updates, and deletes to the mirror backend.`,
+				},
 			},
 			Action: func(c *cli.Context) {
 				var backend string
@@ -128,6 +226,18 @@ func main() {
 					config.Autoexpand = c.GlobalBool(`autoexpand`)
 				}
 
+				if c.IsSet(`track-usage`) {
+					config.TrackUsage = c.Bool(`track-usage`)
+				}
+
+				if c.IsSet(`persist-collections`) {
+					config.PersistCollections = c.Bool(`persist-collections`)
+				}
+
+				if c.IsSet(`links`) {
+					config.EmbedLinks = c.Bool(`links`)
+				}
+
 				if backend == `` {
 					log.Fatalf("Must specify a backend to connect to.")
 				}
@@ -135,9 +245,49 @@ func main() {
 				server := pivot.NewServer(backend)
 				server.Address = c.String(`address`)
 				server.UiDirectory = c.String(`ui-dir`)
+
+				if c.Bool(`no-ui`) {
+					server.UiDirectory = ``
+				}
+
 				server.ConnectOptions.Indexer = indexer
 				server.ConnectOptions.AutocreateCollections = config.AutocreateCollections
+				server.ConnectOptions.TrackUsage = config.TrackUsage
+				server.ConnectOptions.PersistCollections = config.PersistCollections
+				server.ConnectOptions.DefaultQueryTimeout = c.Duration(`query-timeout`)
+				server.ConnectOptions.DefaultWriteTimeout = c.Duration(`write-timeout`)
+				server.ConnectOptions.HealthCheck.Interval = c.Duration(`health-check-interval`)
+				server.ConnectOptions.Stats = config.Stats
+				server.ConnectOptions.Routes = config.Routes
+
+				for _, spec := range c.StringSlice(`route`) {
+					if route, err := backends.ParseCollectionRoute(spec); err == nil {
+						server.ConnectOptions.Routes = append(server.ConnectOptions.Routes, route)
+					} else {
+						log.Fatal(err)
+					}
+				}
+
+				if c.IsSet(`stats-interval`) {
+					server.ConnectOptions.Stats.Interval = c.Duration(`stats-interval`)
+				}
+
 				server.Autoexpand = config.Autoexpand
+				server.MaxEmbeddedRecords = c.Int(`max-embedded-records`)
+				server.MaxEmbeddedBytes = c.Int(`max-embedded-bytes`)
+				server.EmbedLinks = config.EmbedLinks
+				server.GraphQL = c.Bool(`graphql`)
+				server.GRPCAddress = c.String(`grpc-address`)
+				server.TLSCertFile = c.String(`tls-cert`)
+				server.TLSKeyFile = c.String(`tls-key`)
+				server.DisableCompression = c.Bool(`no-compression`)
+				server.DisableCoalescing = c.Bool(`no-coalescing`)
+				server.Limits.RequestsPerSecond = c.Float64(`rate-limit`)
+				server.Limits.Burst = c.Int(`rate-burst`)
+				server.Limits.MaxBodySize = int64(c.Int(`max-body-size`))
+				server.MirrorTo = c.String(`mirror-to`)
+				server.Mirror.Percent = c.Float64(`mirror-percent`)
+				server.Mirror.Writes = c.Bool(`mirror-writes`)
 
 				for _, filename := range c.GlobalStringSlice(`schema`) {
 					server.AddSchemaDefinition(filename)
@@ -147,6 +297,18 @@ func main() {
 					server.AddFixturePath(filename)
 				}
 
+				for name, connectionString := range config.JoinBackends {
+					server.AddJoinBackend(name, connectionString)
+				}
+
+				for _, pair := range c.StringSlice(`join-backend`) {
+					if name, connectionString := stringutil.SplitPair(pair, `=`); name != `` && connectionString != `` {
+						server.AddJoinBackend(name, connectionString)
+					} else {
+						log.Fatalf("Invalid join backend %q: must be in the form NAME=CONNECTION_STRING", pair)
+					}
+				}
+
 				if err := server.ListenAndServe(); err != nil {
 					log.Fatalf("Failed to start server: %v", err)
 					os.Exit(3)
@@ -229,128 +391,1295 @@ func main() {
 									log.Warningf("no schema found for database collection %q", actual.Name)
 								}
 							} else {
-								log.Warningf("failed to retrieve collection: %v", err)
+								log.Warningf("failed to retrieve collection: %v", err)
+							}
+						}
+					} else {
+						log.Fatalf("schema: %v", err)
+					}
+				} else {
+					log.Fatalf("connect: %v", err)
+				}
+			},
+		}, {
+			Name:      `migrate`,
+			Usage:     `Show (or apply) the changes needed to bring the given connection in line with the loaded schema.`,
+			ArgsUsage: `CONNECTION_STRING [COLLECTION ..]`,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  `plan`,
+					Usage: `Only print the changes that would be made (this is the default).`,
+				},
+				cli.BoolFlag{
+					Name:  `apply`,
+					Usage: `Make the changes.`,
+				},
+				cli.BoolFlag{
+					Name:  `skip-unsupported`,
+					Usage: `When applying, make the changes the backend supports and skip the rest instead of failing.`,
+				},
+				cli.StringFlag{
+					Name:  `format, f`,
+					Usage: `How to format the output. (one of: text, json)`,
+					Value: `text`,
+				},
+				cli.BoolFlag{
+					Name:  `pretty, P`,
+					Usage: `Pretty-print the formatted output (indenting where applicable)`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				var backend string
+				var config pivot.Configuration
+
+				if c.Bool(`plan`) && c.Bool(`apply`) {
+					log.Fatalf("Cannot specify both --plan and --apply")
+				}
+
+				if cnf, err := pivot.LoadConfigFile(c.GlobalString(`config`)); err == nil {
+					config = cnf.ForEnv(os.Getenv(`PIVOT_ENV`))
+				} else if !os.IsNotExist(err) {
+					log.Fatalf("Configuration error: %v", err)
+				}
+
+				if cs := c.Args().First(); cs != `` {
+					backend = cs
+				} else {
+					backend = config.Backend
+				}
+
+				if backend == `` {
+					log.Fatalf("Must specify a backend to connect to.")
+				}
+
+				db, err := pivot.NewDatabaseWithOptions(backend, pivot.ConnectOptions{})
+
+				if err != nil {
+					log.Fatalf("connect: %v", err)
+				}
+
+				loaded, err := pivot.LoadSchemata(c.GlobalStringSlice(`schema`)...)
+
+				if err != nil {
+					log.Fatalf("schema: %v", err)
+				}
+
+				var only = c.Args().Tail()
+				var plans = make([]*backends.MigrationPlan, 0)
+
+				for _, definition := range loaded {
+					if definition.View {
+						continue
+					} else if len(only) > 0 && !sliceutil.ContainsString(only, definition.Name) {
+						continue
+					}
+
+					if plan, err := backends.PlanMigration(db, definition); err == nil {
+						plans = append(plans, plan)
+					} else if dal.IsCollectionNotFoundErr(err) {
+						log.Warningf("%s: collection does not exist", definition.Name)
+					} else {
+						log.Fatalf("%s: %v", definition.Name, err)
+					}
+				}
+
+				if c.Bool(`apply`) {
+					for _, plan := range plans {
+						if c.Bool(`skip-unsupported`) {
+							for _, step := range plan.Unsupported() {
+								log.Warningf("%s: skipping %v", plan.Collection, step)
+							}
+
+							plan = plan.Supported()
+						}
+
+						if err := backends.ApplyMigration(db, plan); err == nil {
+							log.Noticef("%s: applied %d change(s)", plan.Collection, len(plan.Steps))
+						} else {
+							log.Fatalf("%s: %v", plan.Collection, err)
+						}
+					}
+				}
+
+				output(c, plans, func() error {
+					for _, plan := range plans {
+						if plan.IsEmpty() {
+							fmt.Printf("%s: up-to-date\n", plan.Collection)
+							continue
+						}
+
+						fmt.Printf("%s: %d change(s)\n", plan.Collection, len(plan.Steps))
+
+						for _, step := range plan.Steps {
+							fmt.Printf("    # %v\n", step.Delta)
+
+							if step.IsSupported() {
+								fmt.Printf("    %s;\n", step.Statement)
+							} else {
+								fmt.Printf("    -- unsupported: %s\n", step.Error)
+							}
+						}
+					}
+
+					return nil
+				})
+			},
+		}, {
+			Name:      `migrations`,
+			Usage:     `Show the status of (or apply, or roll back) the migration files in a directory.`,
+			ArgsUsage: `[CONNECTION_STRING]`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `dir, d`,
+					Usage: `The directory containing migration files.`,
+					Value: `migrations`,
+				},
+				cli.BoolFlag{
+					Name:  `up`,
+					Usage: `Apply all pending migrations (or those up to the version given by --to).`,
+				},
+				cli.StringFlag{
+					Name:  `to`,
+					Usage: `When applying, stop after the migration with this version.`,
+				},
+				cli.IntFlag{
+					Name:  `down`,
+					Usage: `Roll back this many of the most recently applied migrations.`,
+				},
+				cli.StringFlag{
+					Name:  `format, f`,
+					Usage: `How to format the output. (one of: text, json)`,
+					Value: `text`,
+				},
+				cli.BoolFlag{
+					Name:  `pretty, P`,
+					Usage: `Pretty-print the formatted output (indenting where applicable)`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				var backend string
+				var config pivot.Configuration
+
+				if c.Bool(`up`) && c.Int(`down`) > 0 {
+					log.Fatalf("Cannot specify both --up and --down")
+				}
+
+				if cnf, err := pivot.LoadConfigFile(c.GlobalString(`config`)); err == nil {
+					config = cnf.ForEnv(os.Getenv(`PIVOT_ENV`))
+				} else if !os.IsNotExist(err) {
+					log.Fatalf("Configuration error: %v", err)
+				}
+
+				if cs := c.Args().First(); cs != `` {
+					backend = cs
+				} else {
+					backend = config.Backend
+				}
+
+				if backend == `` {
+					log.Fatalf("Must specify a backend to connect to.")
+				}
+
+				db, err := pivot.NewDatabaseWithOptions(backend, pivot.ConnectOptions{})
+
+				if err != nil {
+					log.Fatalf("connect: %v", err)
+				}
+
+				migrations, err := backends.LoadMigrations(c.String(`dir`))
+
+				if err != nil {
+					log.Fatalf("migrations: %v", err)
+				}
+
+				if c.Bool(`up`) {
+					if applied, err := backends.MigrateUp(db, migrations, c.String(`to`)); err == nil {
+						log.Noticef("applied %d migration(s)", len(applied))
+					} else {
+						log.Fatalf("after applying %d migration(s): %v", len(applied), err)
+					}
+				} else if count := c.Int(`down`); count > 0 {
+					if rolledBack, err := backends.MigrateDown(db, migrations, count); err == nil {
+						log.Noticef("rolled back %d migration(s)", len(rolledBack))
+					} else {
+						log.Fatalf("after rolling back %d migration(s): %v", len(rolledBack), err)
+					}
+				}
+
+				statuses, err := backends.GetMigrationStatus(db, migrations)
+
+				if err != nil {
+					log.Fatalf("status: %v", err)
+				}
+
+				output(c, statuses, func() error {
+					for _, status := range statuses {
+						if status.Applied {
+							fmt.Printf("%-20s applied %v\n", status.Migration, status.AppliedAt.Format(time.RFC3339))
+						} else {
+							fmt.Printf("%-20s pending\n", status.Migration)
+						}
+					}
+
+					return nil
+				})
+			},
+		}, {
+			Name:      `copy`,
+			Usage:     `Copies data from one datasource to another`,
+			ArgsUsage: `SOURCE DESTINATION`,
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  `collection, c`,
+					Usage: `A specific collection to copy (can be specified multiple times).`,
+				},
+				cli.BoolFlag{
+					Name:  `no-schema-check, S`,
+					Usage: `Skip verifying schema equality.`,
+				},
+				cli.StringFlag{
+					Name:  `key, k`,
+					Usage: `A comma-separated list of fields used to match existing destination records; matching records are updated instead of duplicated.`,
+				},
+				cli.BoolFlag{
+					Name:  `accept-downgrades`,
+					Usage: `Copy collections whose field types, lengths, or constraints cannot be fully represented by the destination.`,
+				},
+				cli.IntFlag{
+					Name:  `concurrency, j`,
+					Usage: `The number of collections to copy at the same time.`,
+					Value: backends.CopyConcurrency,
+				},
+				cli.IntFlag{
+					Name:  `batch-size, b`,
+					Usage: `The number of records to read and write at a time.`,
+					Value: backends.CopyBatchSize,
+				},
+				cli.StringFlag{
+					Name:  `checkpoint`,
+					Usage: `Save progress to this file so that an interrupted copy can be resumed by running the same command again.`,
+				},
+				cli.StringFlag{
+					Name:  `remap, R`,
+					Usage: `A file (written by the "collisions" command) containing new IDs to give records as they are copied; references to remapped records are rewritten to match.`,
+				},
+				cli.StringFlag{
+					Name:  `format, f`,
+					Usage: `How to format the copy report. (one of: text, json)`,
+					Value: `text`,
+				},
+				cli.BoolFlag{
+					Name:  `pretty, P`,
+					Usage: `Pretty-print the formatted output (indenting where applicable)`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				var source backends.Backend
+				var destination backends.Backend
+				var keyFields []string
+				var remaps *backends.IdRemapTable
+
+				if key := c.String(`key`); key != `` {
+					keyFields = sliceutil.CompactString(strings.Split(key, `,`))
+				}
+
+				if filename := c.String(`remap`); filename != `` {
+					if table, err := backends.LoadIdRemapTable(filename); err == nil {
+						remaps = table
+					} else {
+						log.Fatalf("failed to load ID remap table: %v", err)
+					}
+				}
+
+				if sourceURI := c.Args().Get(0); sourceURI != `` {
+					if destinationURI := c.Args().Get(1); destinationURI != `` {
+						if s, err := pivot.NewDatabase(sourceURI); err == nil {
+							if err := s.Initialize(); err != nil {
+								log.Fatalf("failed to initialize source: %v", err)
+							}
+
+							if d, err := pivot.NewDatabase(destinationURI); err == nil {
+								if err := d.Initialize(); err != nil {
+									log.Fatalf("failed to initialize destination: %v", err)
+								}
+
+								source = s
+								destination = d
+							} else {
+								log.Fatalf("failed to connect to destination: %v", err)
+							}
+						} else {
+							log.Fatalf("failed to connect to source: %v", err)
+						}
+					} else {
+						log.Fatalf("Must specify a destination")
+					}
+				} else {
+					log.Fatalf("Must specify a source")
+				}
+
+				collections := c.StringSlice(`collection`)
+
+				if len(collections) == 0 {
+					if c, err := source.ListCollections(); err == nil {
+						collections = c
+					} else {
+						log.Fatalf("failed to list source collections: %v", err)
+					}
+				}
+
+				reports := make([]*backends.CopyReport, 0)
+				var blocked int
+
+				// before copying anything, find out what the destination can't represent
+				for _, name := range collections {
+					report := &backends.CopyReport{
+						Collection: name,
+					}
+
+					if collection, err := source.GetCollection(name); err == nil {
+						report.Downgrades = backends.AnalyzeSchemaDowngrades(destination, collection)
+
+						for _, downgrade := range report.Downgrades {
+							if c.Bool(`accept-downgrades`) {
+								log.Warningf("Accepting downgrade: %v", downgrade)
+							} else {
+								log.Errorf("Downgrade required: %v", downgrade)
+							}
+						}
+
+						if len(report.Downgrades) > 0 && !c.Bool(`accept-downgrades`) {
+							blocked += 1
+						}
+					}
+
+					reports = append(reports, report)
+				}
+
+				if blocked > 0 {
+					log.Fatalf("%d collection(s) cannot be copied without losing type fidelity; specify --accept-downgrades to copy anyway", blocked)
+				}
+
+				log.Debugf("Copying %d collections", len(collections))
+
+				if err := backends.Copy(source, destination, reports, backends.CopyOptions{
+					KeyFields:       keyFields,
+					Remaps:          remaps,
+					BatchSize:       c.Int(`batch-size`),
+					Concurrency:     c.Int(`concurrency`),
+					SkipSchemaCheck: c.Bool(`no-schema-check`),
+					CheckpointFile:  c.String(`checkpoint`),
+					Progress: func(report *backends.CopyReport) {
+						log.Debugf("Copied %d records from collection %q (failed=%d)", report.Copied, report.Collection, report.Failed)
+					},
+				}); err != nil {
+					log.Fatalf("copy failed: %v", err)
+				}
+
+				output(c, reports, func() error {
+					for _, report := range reports {
+						fmt.Printf("%s: copied=%d failed=%d remapped=%d references=%d\n", report.Collection, report.Copied, report.Failed, report.Remapped, report.References)
+
+						if report.Error != `` {
+							fmt.Printf("    error: %s\n", report.Error)
+						}
+
+						for _, downgrade := range report.Downgrades {
+							fmt.Printf("    downgraded %s: %s -> %s (%s)\n", downgrade.Field, downgrade.Source, downgrade.Destination, downgrade.Reason)
+						}
+					}
+
+					return nil
+				})
+			},
+		}, {
+			Name:      `collisions`,
+			Usage:     `Find records whose IDs exist in both the source and destination, optionally assigning them new IDs to be used by "copy --remap".`,
+			ArgsUsage: `SOURCE DESTINATION`,
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  `collection, c`,
+					Usage: `A specific collection to check (can be specified multiple times).`,
+				},
+				cli.StringFlag{
+					Name:  `strategy, s`,
+					Usage: `How to assign new IDs to colliding records. (one of: offset, regenerate, prefix)`,
+				},
+				cli.IntFlag{
+					Name:  `offset`,
+					Usage: `The amount to add to colliding IDs when using the "offset" strategy (default: the largest existing ID).`,
+				},
+				cli.StringFlag{
+					Name:  `prefix`,
+					Usage: `The string to prepend to colliding IDs when using the "prefix" strategy.`,
+				},
+				cli.StringFlag{
+					Name:  `output, o`,
+					Usage: `The file to write new IDs to (required when a strategy is given).`,
+				},
+				cli.StringFlag{
+					Name:  `format, f`,
+					Usage: `How to format the output. (one of: text, json)`,
+					Value: `text`,
+				},
+				cli.BoolFlag{
+					Name:  `pretty, P`,
+					Usage: `Pretty-print the formatted output (indenting where applicable)`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				var options backends.IdCollisionOptions
+				var remaps = backends.NewIdRemapTable()
+
+				if v := c.String(`strategy`); v != `` {
+					if strategy, err := backends.ParseIdRemapStrategy(v); err == nil {
+						options.Strategy = strategy
+						options.Offset = int64(c.Int(`offset`))
+						options.Prefix = c.String(`prefix`)
+					} else {
+						log.Fatal(err)
+					}
+
+					if c.String(`output`) == `` {
+						log.Fatalf("Must specify a file to write new IDs to (--output)")
+					}
+				}
+
+				if c.Args().Get(0) == `` {
+					log.Fatalf("Must specify a source")
+				} else if c.Args().Get(1) == `` {
+					log.Fatalf("Must specify a destination")
+				}
+
+				source, err := pivot.NewDatabase(c.Args().Get(0))
+
+				if err != nil {
+					log.Fatalf("failed to connect to source: %v", err)
+				}
+
+				destination, err := pivot.NewDatabase(c.Args().Get(1))
+
+				if err != nil {
+					log.Fatalf("failed to connect to destination: %v", err)
+				}
+
+				collections := c.StringSlice(`collection`)
+
+				if len(collections) == 0 {
+					if names, err := source.ListCollections(); err == nil {
+						collections = names
+					} else {
+						log.Fatalf("failed to list source collections: %v", err)
+					}
+				}
+
+				reports := make([]*backends.IdCollisionReport, 0)
+
+				for _, name := range collections {
+					if collection, err := source.GetCollection(name); err == nil {
+						if report, err := backends.AnalyzeIdCollisions(source, destination, collection, options, remaps); err == nil {
+							reports = append(reports, report)
+						} else {
+							reports = append(reports, &backends.IdCollisionReport{
+								Collection: name,
+								Error:      err.Error(),
+							})
+						}
+					} else {
+						reports = append(reports, &backends.IdCollisionReport{
+							Collection: name,
+							Error:      err.Error(),
+						})
+					}
+				}
+
+				if options.Strategy != `` {
+					if err := remaps.Save(c.String(`output`)); err != nil {
+						log.Fatalf("failed to write ID remap table: %v", err)
+					}
+				}
+
+				output(c, reports, func() error {
+					for _, report := range reports {
+						if report.Error != `` {
+							fmt.Printf("%s: error: %s\n", report.Collection, report.Error)
+						} else {
+							fmt.Printf("%s: %d of %d IDs collide, remapped=%d\n", report.Collection, len(report.Collisions), report.SourceCount, report.Remapped)
+						}
+					}
+
+					return nil
+				})
+			},
+		}, {
+			Name:      `dump`,
+			Usage:     `Stream the records in a collection to standard output as newline-delimited JSON.`,
+			ArgsUsage: `CONNECTION_STRING COLLECTION`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `query, q`,
+					Usage: `Only dump records matching this filter.`,
+					Value: filter.AllValue,
+				},
+				cli.IntFlag{
+					Name:  `batch-size, b`,
+					Usage: `The number of records to write between flushes of the output.`,
+					Value: backends.DumpBatchSize,
+				},
+			},
+			Action: func(c *cli.Context) {
+				if cs := c.Args().First(); cs != `` {
+					if db, err := pivot.NewDatabase(cs); err == nil {
+						if err := db.Initialize(); err != nil {
+							log.Fatalf("failed to initialize backend: %v", err)
+						}
+
+						for _, filename := range c.GlobalStringSlice(`schema`) {
+							if err := pivot.ApplySchemata(filename, db); err != nil {
+								log.Fatalf("failed to load schemata: %v", err)
+							}
+						}
+
+						if f, err := filter.Parse(c.String(`query`)); err == nil {
+							if n, err := backends.Dump(db, c.Args().Get(1), f, os.Stdout, c.Int(`batch-size`)); err == nil {
+								log.Infof("Dumped %d records", n)
+							} else {
+								log.Fatalf("dump failed after %d records: %v", n, err)
+							}
+						} else {
+							log.Fatalf("invalid query: %v", err)
+						}
+					} else {
+						log.Fatalf("connect: %v", err)
+					}
+				} else {
+					log.Fatalf("Must specify a backend to connect to.")
+				}
+			},
+		}, {
+			Name:      `restore`,
+			Usage:     `Write newline-delimited JSON records read from standard input into a collection.`,
+			ArgsUsage: `CONNECTION_STRING COLLECTION`,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  `batch-size, b`,
+					Usage: `The number of records to write to the backend at a time.`,
+					Value: backends.DumpBatchSize,
+				},
+				cli.StringFlag{
+					Name:  `key, k`,
+					Usage: `A comma-separated list of fields used to match existing records; matching records are updated instead of duplicated.`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				var keyFields []string
+
+				if key := c.String(`key`); key != `` {
+					keyFields = sliceutil.CompactString(strings.Split(key, `,`))
+				}
+
+				if cs := c.Args().First(); cs != `` {
+					if db, err := pivot.NewDatabase(cs); err == nil {
+						if err := db.Initialize(); err != nil {
+							log.Fatalf("failed to initialize backend: %v", err)
+						}
+
+						for _, filename := range c.GlobalStringSlice(`schema`) {
+							if err := pivot.ApplySchemata(filename, db); err != nil {
+								log.Fatalf("failed to load schemata: %v", err)
+							}
+						}
+
+						if n, err := backends.Restore(db, c.Args().Get(1), os.Stdin, c.Int(`batch-size`), keyFields...); err == nil {
+							log.Infof("Restored %d records", n)
+						} else {
+							log.Fatalf("restore failed after %d records: %v", n, err)
+						}
+					} else {
+						log.Fatalf("connect: %v", err)
+					}
+				} else {
+					log.Fatalf("Must specify a backend to connect to.")
+				}
+			},
+		}, {
+			Name:      `export`,
+			Aliases:   []string{`backup`},
+			Usage:     `Write collections to a compressed archive that can be verified and restored with "import".`,
+			ArgsUsage: `CONNECTION_STRING [COLLECTION ..]`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `output, o`,
+					Usage: `The file to write the archive to (defaults to standard output).`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				if cs := c.Args().First(); cs != `` {
+					if db, err := pivot.NewDatabase(cs); err == nil {
+						if err := db.Initialize(); err != nil {
+							log.Fatalf("failed to initialize backend: %v", err)
+						}
+
+						for _, filename := range c.GlobalStringSlice(`schema`) {
+							if err := pivot.ApplySchemata(filename, db); err != nil {
+								log.Fatalf("failed to load schemata: %v", err)
+							}
+						}
+
+						var output io.Writer = os.Stdout
+
+						if filename := c.String(`output`); filename != `` {
+							if file, err := os.Create(filename); err == nil {
+								defer file.Close()
+								output = file
+							} else {
+								log.Fatalf("export: %v", err)
+							}
+						}
+
+						if manifest, err := backends.ExportArchive(db, output, c.Args().Tail()...); err == nil {
+							for _, collection := range manifest.Collections {
+								log.Infof("Exported %d records from %s", collection.Records, collection.Name)
+							}
+						} else {
+							log.Fatalf("export failed: %v", err)
+						}
+					} else {
+						log.Fatalf("connect: %v", err)
+					}
+				} else {
+					log.Fatalf("Must specify a backend to connect to.")
+				}
+			},
+		}, {
+			Name:      `import`,
+			Usage:     `Verify an archive written by "export" and restore its collections.`,
+			ArgsUsage: `CONNECTION_STRING [COLLECTION ..]`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `input, i`,
+					Usage: `The file to read the archive from (defaults to standard input).`,
+				},
+				cli.IntFlag{
+					Name:  `batch-size, b`,
+					Usage: `The number of records to write to the backend at a time.`,
+					Value: backends.DumpBatchSize,
+				},
+				cli.BoolFlag{
+					Name:  `create, c`,
+					Usage: `Create collections that don't exist using the schema stored in the archive.`,
+				},
+				cli.BoolFlag{
+					Name:  `verify-only`,
+					Usage: `Only verify the integrity of the archive; don't restore anything.`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				var input io.Reader = os.Stdin
+
+				if filename := c.String(`input`); filename != `` {
+					if file, err := os.Open(filename); err == nil {
+						defer file.Close()
+						input = file
+					} else {
+						log.Fatalf("import: %v", err)
+					}
+				}
+
+				if c.Bool(`verify-only`) {
+					if manifest, err := backends.VerifyArchive(input); err == nil {
+						for _, collection := range manifest.Collections {
+							log.Infof("%s: %d records OK (%s)", collection.Name, collection.Records, collection.Checksum)
+						}
+					} else {
+						log.Fatalf("verification failed: %v", err)
+					}
+
+					return
+				}
+
+				if cs := c.Args().First(); cs != `` {
+					if db, err := pivot.NewDatabase(cs); err == nil {
+						if err := db.Initialize(); err != nil {
+							log.Fatalf("failed to initialize backend: %v", err)
+						}
+
+						for _, filename := range c.GlobalStringSlice(`schema`) {
+							if err := pivot.ApplySchemata(filename, db); err != nil {
+								log.Fatalf("failed to load schemata: %v", err)
+							}
+						}
+
+						if manifest, err := backends.ImportArchive(db, input, backends.ImportArchiveOptions{
+							Collections:       c.Args().Tail(),
+							CreateCollections: c.Bool(`create`),
+							BatchSize:         c.Int(`batch-size`),
+						}); err == nil {
+							for _, collection := range manifest.Collections {
+								if len(c.Args().Tail()) == 0 || sliceutil.ContainsString(c.Args().Tail(), collection.Name) {
+									log.Infof("Imported %d records into %s", collection.Records, collection.Name)
+								}
+							}
+						} else {
+							log.Fatalf("import failed: %v", err)
+						}
+					} else {
+						log.Fatalf("connect: %v", err)
+					}
+				} else {
+					log.Fatalf("Must specify a backend to connect to.")
+				}
+			},
+		}, {
+			Name:      `check`,
+			Usage:     `Scan collections for records that reference related records which do not exist.`,
+			ArgsUsage: `CONNECTION_STRING [COLLECTION ..]`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `repair, r`,
+					Usage: `What to do with orphaned records (one of: nullify, delete); if empty, only report them.`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				var collections []string
+
+				mode, err := backends.ParseIntegrityRepairMode(c.String(`repair`))
+
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				if len(c.Args()) > 1 {
+					collections = c.Args()[1:]
+				}
+
+				if cs := c.Args().First(); cs != `` {
+					if db, err := pivot.NewDatabase(cs); err == nil {
+						if err := db.Initialize(); err != nil {
+							log.Fatalf("failed to initialize backend: %v", err)
+						}
+
+						if report, err := backends.CheckIntegrity(db, mode, collections...); err == nil {
+							for _, orphan := range report.Orphans {
+								if orphan.Repaired {
+									log.Noticef("repaired: %v", orphan)
+								} else {
+									log.Warningf("orphan: %v", orphan)
+								}
+							}
+
+							for _, err := range report.Errors {
+								log.Errorf("%v", err)
+							}
+
+							log.Infof(
+								"Scanned %d records in %d collections, found %d orphans",
+								report.RecordsScanned,
+								len(report.Collections),
+								len(report.Orphans),
+							)
+
+							if !report.OK() && mode == backends.IntegrityReportOnly {
+								os.Exit(1)
+							}
+						} else {
+							log.Fatalf("check failed: %v", err)
+						}
+					} else {
+						log.Fatalf("connect: %v", err)
+					}
+				} else {
+					log.Fatalf("Must specify a backend to connect to.")
+				}
+			},
+		}, {
+			Name:      `advise`,
+			Usage:     `Suggest indexes and unused fields based on recorded usage statistics and query shapes.`,
+			ArgsUsage: `CONNECTION_STRING [COLLECTION ..]`,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  `min-queries, m`,
+					Usage: `The minimum number of times a query must have been performed before an index is suggested for it.`,
+					Value: int(backends.AdvisorMinimumQueries),
+				},
+				cli.StringFlag{
+					Name:  `format, f`,
+					Usage: `How to format the output. (one of: text, json)`,
+					Value: `text`,
+				},
+				cli.BoolFlag{
+					Name:  `pretty, P`,
+					Usage: `Pretty-print the formatted output (indenting where applicable)`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				var collections []string
+
+				if len(c.Args()) > 1 {
+					collections = c.Args()[1:]
+				}
+
+				if cs := c.Args().First(); cs != `` {
+					if db, err := pivot.NewDatabase(cs); err == nil {
+						if err := db.Initialize(); err != nil {
+							log.Fatalf("failed to initialize backend: %v", err)
+						}
+
+						tracker := backends.NewUsageTrackingBackend(db)
+
+						if advice, err := backends.Advise(tracker, int64(c.Int(`min-queries`)), collections...); err == nil {
+							output(c, advice, func() error {
+								for _, suggestion := range advice {
+									fmt.Printf("-- %v\n", suggestion)
+
+									if suggestion.Snippet != `` {
+										fmt.Printf("%s\n", suggestion.Snippet)
+									}
+
+									fmt.Println()
+								}
+
+								return nil
+							})
+						} else {
+							log.Fatalf("advise failed: %v", err)
+						}
+					} else {
+						log.Fatalf("connect: %v", err)
+					}
+				} else {
+					log.Fatalf("Must specify a backend to connect to.")
+				}
+			},
+		}, {
+			Name:      `seed`,
+			Usage:     `Generate synthetic records for a collection, for load testing and demo environments.`,
+			ArgsUsage: `CONNECTION_STRING COLLECTION`,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  `count, n`,
+					Usage: `The number of records to generate.`,
+					Value: 100,
+				},
+				cli.StringFlag{
+					Name:  `template, t`,
+					Usage: `A YAML file describing how values for specific fields should be generated.`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				var template *backends.SeedTemplate
+
+				if filename := c.String(`template`); filename != `` {
+					if t, err := backends.LoadSeedTemplate(filename); err == nil {
+						template = t
+					} else {
+						log.Fatal(err)
+					}
+				}
+
+				if cs := c.Args().First(); cs != `` {
+					if db, err := pivot.NewDatabase(cs); err == nil {
+						if err := db.Initialize(); err != nil {
+							log.Fatalf("failed to initialize backend: %v", err)
+						}
+
+						for _, filename := range c.GlobalStringSlice(`schema`) {
+							if err := pivot.ApplySchemata(filename, db); err != nil {
+								log.Fatalf("failed to load schemata: %v", err)
+							}
+						}
+
+						if collection, err := db.GetCollection(c.Args().Get(1)); err == nil {
+							if n, err := backends.NewSeeder(db, collection, template).Seed(c.Int(`count`)); err == nil {
+								log.Infof("Generated %d records in %s", n, collection.Name)
+							} else {
+								log.Fatalf("seed failed after %d records: %v", n, err)
+							}
+						} else {
+							log.Fatalf("collection: %v", err)
+						}
+					} else {
+						log.Fatalf("connect: %v", err)
+					}
+				} else {
+					log.Fatalf("Must specify a backend to connect to.")
+				}
+			},
+		}, {
+			Name:      `backfill`,
+			Usage:     `Populate fields in existing records of a collection, e.g.: after adding them to the schema.`,
+			ArgsUsage: `CONNECTION_STRING COLLECTION`,
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  `set, s`,
+					Usage: `A field=expression pair; expressions are static values or may reference other fields, e.g.: "full_name={first} {last}" (can be specified multiple times).`,
+				},
+				cli.StringFlag{
+					Name:  `where, w`,
+					Usage: `Only backfill records matching this filter.`,
+				},
+				cli.IntFlag{
+					Name:  `batch, b`,
+					Usage: `The number of records to read and update at a time.`,
+					Value: backends.BackfillBatchSize,
+				},
+				cli.StringFlag{
+					Name:  `rate, r`,
+					Usage: `The maximum number of records to process per unit of time (e.g.: "100/s", "5000/m").`,
+				},
+				cli.StringFlag{
+					Name:  `state`,
+					Usage: `A file used to record progress so that an interrupted backfill can be resumed.`,
+				},
+				cli.BoolFlag{
+					Name:  `overwrite, O`,
+					Usage: `Replace values that are already present instead of only populating empty fields.`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				options := backends.BackfillOptions{
+					Set:       make(map[string]backends.BackfillExpression),
+					BatchSize: c.Int(`batch`),
+					StateFile: c.String(`state`),
+					Overwrite: c.Bool(`overwrite`),
+					Progress: func(progress *backends.BackfillProgress) {
+						log.Infof(
+							"%s: scanned=%d updated=%d last=%v elapsed=%v",
+							progress.Collection,
+							progress.Scanned,
+							progress.Updated,
+							progress.After,
+							progress.Elapsed.Round(time.Millisecond),
+						)
+					},
+				}
+
+				for _, assignment := range c.StringSlice(`set`) {
+					if field, expr, err := backends.ParseBackfillAssignment(assignment); err == nil {
+						options.Set[field] = expr
+					} else {
+						log.Fatal(err)
+					}
+				}
+
+				if where := c.String(`where`); where != `` {
+					if f, err := filter.Parse(where); err == nil {
+						options.Filter = f
+					} else {
+						log.Fatalf("invalid filter: %v", err)
+					}
+				}
+
+				if rate, err := backends.ParseBackfillRate(c.String(`rate`)); err == nil {
+					options.Rate = rate
+				} else {
+					log.Fatal(err)
+				}
+
+				if cs := c.Args().First(); cs != `` {
+					if db, err := pivot.NewDatabase(cs); err == nil {
+						if err := db.Initialize(); err != nil {
+							log.Fatalf("failed to initialize backend: %v", err)
+						}
+
+						for _, filename := range c.GlobalStringSlice(`schema`) {
+							if err := pivot.ApplySchemata(filename, db); err != nil {
+								log.Fatalf("failed to load schemata: %v", err)
+							}
+						}
+
+						if progress, err := backends.Backfill(db, c.Args().Get(1), options); err == nil {
+							log.Infof("Backfilled %d of %d records in %s", progress.Updated, progress.Scanned, progress.Collection)
+						} else {
+							log.Fatalf("backfill failed after %d records (resume from %v): %v", progress.Scanned, progress.After, err)
+						}
+					} else {
+						log.Fatalf("connect: %v", err)
+					}
+				} else {
+					log.Fatalf("Must specify a backend to connect to.")
+				}
+			},
+		}, {
+			Name:      `index-gc`,
+			Usage:     `Remove entries from an external index whose records no longer exist in the backend.`,
+			ArgsUsage: `CONNECTION_STRING [COLLECTION ..]`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `indexer, i`,
+					Usage: `The connection string of the index to collect garbage from.`,
+				},
+				cli.IntFlag{
+					Name:  `sample, n`,
+					Usage: `The maximum number of index entries to check per collection.`,
+					Value: backends.IndexGCSampleSize,
+				},
+				cli.Float64Flag{
+					Name:  `rate, r`,
+					Usage: `The maximum number of index entries to check per second (0 = unlimited).`,
+				},
+				cli.BoolFlag{
+					Name:  `dry-run, D`,
+					Usage: `Report orphaned index entries without removing them.`,
+				},
+				cli.StringFlag{
+					Name:  `format, f`,
+					Usage: `How to format the output. (one of: text, json)`,
+					Value: `text`,
+				},
+				cli.BoolFlag{
+					Name:  `pretty, P`,
+					Usage: `Pretty-print the formatted output (indenting where applicable)`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				var collections []string
+
+				if len(c.Args()) > 1 {
+					collections = c.Args()[1:]
+				}
+
+				if c.String(`indexer`) == `` {
+					log.Fatalf("Must specify an indexer to collect garbage from.")
+				}
+
+				if cs := c.Args().First(); cs != `` {
+					if db, err := pivot.NewDatabaseWithOptions(cs, pivot.ConnectOptions{
+						Indexer: c.String(`indexer`),
+					}); err == nil {
+						for _, filename := range c.GlobalStringSlice(`schema`) {
+							if err := pivot.ApplySchemata(filename, db); err != nil {
+								log.Fatalf("failed to load schemata: %v", err)
+							}
+						}
+
+						gc := backends.NewIndexGarbageCollector(db, backends.IndexGCOptions{
+							SampleSize: c.Int(`sample`),
+							Rate:       c.Float64(`rate`),
+							DryRun:     c.Bool(`dry-run`),
+						})
+
+						if reports, err := gc.Run(collections...); err == nil {
+							output(c, reports, func() error {
+								for _, report := range reports {
+									if report.Error != `` {
+										fmt.Printf("%s: error: %s\n", report.Collection, report.Error)
+									} else if report.Removed {
+										fmt.Printf("%s: removed %d of %d index entries checked: %v\n", report.Collection, len(report.Orphans), report.Scanned, report.Orphans)
+									} else {
+										fmt.Printf("%s: found %d orphans in %d index entries checked: %v\n", report.Collection, len(report.Orphans), report.Scanned, report.Orphans)
+									}
+								}
+
+								return nil
+							})
+						} else {
+							log.Fatalf("index garbage collection failed: %v", err)
+						}
+					} else {
+						log.Fatalf("connect: %v", err)
+					}
+				} else {
+					log.Fatalf("Must specify a backend to connect to.")
+				}
+			},
+		}, {
+			Name:      `reindex`,
+			Usage:     `Populate or rebuild the external index of one or more collections from the records in the backend.`,
+			ArgsUsage: `CONNECTION_STRING`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `indexer, i`,
+					Usage: `The connection string of the index to populate.`,
+				},
+				cli.StringSliceFlag{
+					Name:  `collection, c`,
+					Usage: `A collection to reindex (can be specified multiple times; defaults to all collections).`,
+				},
+				cli.StringFlag{
+					Name:  `where, w`,
+					Usage: `Only reindex records matching this filter.`,
+				},
+				cli.IntFlag{
+					Name:  `batch, b`,
+					Usage: `The number of records to read and index at a time.`,
+					Value: backends.ReindexBatchSize,
+				},
+				cli.StringFlag{
+					Name:  `rate, r`,
+					Usage: `The maximum number of records to index per unit of time (e.g.: "100/s", "5000/m").`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				options := backends.ReindexOptions{
+					BatchSize: c.Int(`batch`),
+					Progress: func(progress *backends.ReindexProgress) {
+						log.Infof(
+							"%s: indexed=%d last=%v elapsed=%v",
+							progress.Collection,
+							progress.Indexed,
+							progress.After,
+							progress.Elapsed.Round(time.Millisecond),
+						)
+					},
+				}
+
+				if where := c.String(`where`); where != `` {
+					if f, err := filter.Parse(where); err == nil {
+						options.Filter = f
+					} else {
+						log.Fatalf("invalid filter: %v", err)
+					}
+				}
+
+				if rate, err := backends.ParseBackfillRate(c.String(`rate`)); err == nil {
+					options.Rate = rate
+				} else {
+					log.Fatal(err)
+				}
+
+				if cs := c.Args().First(); cs != `` {
+					if db, err := pivot.NewDatabaseWithOptions(cs, pivot.ConnectOptions{
+						Indexer: c.String(`indexer`),
+					}); err == nil {
+						for _, filename := range c.GlobalStringSlice(`schema`) {
+							if err := pivot.ApplySchemata(filename, db); err != nil {
+								log.Fatalf("failed to load schemata: %v", err)
+							}
+						}
+
+						var collections = c.StringSlice(`collection`)
+
+						if len(collections) == 0 {
+							if names, err := db.ListCollections(); err == nil {
+								collections = names
+							} else {
+								log.Fatalf("failed to list collections: %v", err)
+							}
+						}
+
+						for _, name := range collections {
+							if progress, err := backends.Reindex(db, name, options); err == nil {
+								log.Infof("Reindexed %d records in %s in %v", progress.Indexed, progress.Collection, progress.Elapsed.Round(time.Millisecond))
+							} else {
+								log.Fatalf("reindexing %s failed after %d records (last was %v): %v", name, progress.Indexed, progress.After, err)
 							}
 						}
 					} else {
-						log.Fatalf("schema: %v", err)
+						log.Fatalf("connect: %v", err)
 					}
 				} else {
-					log.Fatalf("connect: %v", err)
+					log.Fatalf("Must specify a backend to connect to.")
 				}
 			},
 		}, {
-			Name:      `copy`,
-			Usage:     `Copies data from one datasource to another`,
-			ArgsUsage: `SOURCE DESTINATION`,
+			Name:      `bench`,
+			Usage:     `Drive a mix of reads, writes, and queries against a collection and report latency and error rates.`,
+			ArgsUsage: `CONNECTION_STRING`,
 			Flags: []cli.Flag{
-				cli.StringSliceFlag{
+				cli.StringFlag{
 					Name:  `collection, c`,
-					Usage: `A specific collection to copy (can be specified multiple times).`,
+					Usage: `The collection to benchmark against (must already contain records).`,
+				},
+				cli.StringFlag{
+					Name:  `mix, m`,
+					Usage: `The relative weights of reads, writes, and queries to perform.`,
+					Value: `70r/20w/10q`,
+				},
+				cli.IntFlag{
+					Name:  `concurrency, n`,
+					Usage: `The number of concurrent workers performing operations.`,
+					Value: 8,
+				},
+				cli.DurationFlag{
+					Name:  `duration, d`,
+					Usage: `How long to run the benchmark for.`,
+					Value: 30 * time.Second,
+				},
+				cli.StringFlag{
+					Name:  `query, q`,
+					Usage: `A filter to use for query operations (default: match field values from existing records).`,
+				},
+				cli.StringFlag{
+					Name:  `template, t`,
+					Usage: `A seed template describing how values for written records should be generated.`,
+				},
+				cli.StringFlag{
+					Name:  `format, f`,
+					Usage: `How to format the output. (one of: text, json)`,
+					Value: `text`,
 				},
 				cli.BoolFlag{
-					Name:  `no-schema-check, S`,
-					Usage: `Skip verifying schema equality.`,
+					Name:  `pretty, P`,
+					Usage: `Pretty-print the formatted output (indenting where applicable)`,
 				},
 			},
 			Action: func(c *cli.Context) {
-				var source backends.Backend
-				var destination backends.Backend
-
-				if sourceURI := c.Args().Get(0); sourceURI != `` {
-					if destinationURI := c.Args().Get(1); destinationURI != `` {
-						if s, err := pivot.NewDatabase(sourceURI); err == nil {
-							if err := s.Initialize(); err != nil {
-								log.Fatalf("failed to initialize source: %v", err)
-							}
-
-							if d, err := pivot.NewDatabase(destinationURI); err == nil {
-								if err := d.Initialize(); err != nil {
-									log.Fatalf("failed to initialize destination: %v", err)
-								}
+				options := backends.BenchOptions{
+					Collection:  c.String(`collection`),
+					Concurrency: c.Int(`concurrency`),
+					Duration:    c.Duration(`duration`),
+					Query:       c.String(`query`),
+				}
 
-								source = s
-								destination = d
-							} else {
-								log.Fatalf("failed to connect to destination: %v", err)
-							}
-						} else {
-							log.Fatalf("failed to connect to source: %v", err)
-						}
-					} else {
-						log.Fatalf("Must specify a destination")
-					}
+				if mix, err := backends.ParseBenchMix(c.String(`mix`)); err == nil {
+					options.Mix = mix
 				} else {
-					log.Fatalf("Must specify a source")
+					log.Fatal(err)
 				}
 
-				collections := c.StringSlice(`collection`)
-
-				if len(collections) == 0 {
-					if c, err := source.ListCollections(); err == nil {
-						collections = c
+				if filename := c.String(`template`); filename != `` {
+					if t, err := backends.LoadSeedTemplate(filename); err == nil {
+						options.Template = t
 					} else {
-						log.Fatalf("failed to list source collections: %v", err)
+						log.Fatal(err)
 					}
 				}
 
-				log.Debugf("Copying %d collections", len(collections))
-
-				for _, name := range collections {
-					if collection, err := source.GetCollection(name); err == nil {
-						if indexer := source.WithSearch(collection); indexer != nil {
-							var destCollection *dal.Collection
-
-							if dc, err := destination.GetCollection(name); err == nil {
-								destCollection = dc
-
-							} else if dal.IsCollectionNotFoundErr(err) {
-								if err := destination.CreateCollection(collection); err == nil {
-									destCollection = collection
-								} else {
-									log.Errorf("Cannot create destination collection %q: %v", name, err)
-									continue
-								}
-							} else {
-								log.Errorf("Cannot import to destination collection %q: %v", name, err)
-								continue
-							}
+				if options.Collection == `` {
+					log.Fatalf("Must specify a collection to benchmark.")
+				}
 
-							if diffs := destCollection.Diff(collection); len(diffs) == 0 || c.Bool(`no-schema-check`) {
-								sourceItem := mapper.NewModel(source, collection)
-								var i int
+				if cs := c.Args().First(); cs != `` {
+					if db, err := pivot.NewDatabase(cs); err == nil {
+						if err := db.Initialize(); err != nil {
+							log.Fatalf("failed to initialize backend: %v", err)
+						}
 
-								if err := sourceItem.Each(&dal.Record{}, func(ptrToInstance interface{}, err error) {
-									if newRecord, ok := ptrToInstance.(*dal.Record); ok && err == nil {
-										if err := destination.Insert(name, dal.NewRecordSet(newRecord)); err == nil {
-											i += 1
-											log.Debugf("Copied record %v", newRecord.ID)
-										} else {
-											log.Warningf("failed to write record to destination: %v", err)
+						log.Infof(
+							"Benchmarking %s with mix=%v concurrency=%d for %v",
+							options.Collection,
+							options.Mix,
+							options.Concurrency,
+							options.Duration,
+						)
+
+						if report, err := backends.Benchmark(db, options); err == nil {
+							output(c, report, func() error {
+								for _, op := range []backends.BenchOperation{
+									backends.BenchRead,
+									backends.BenchWrite,
+									backends.BenchQuery,
+								} {
+									if result := report.Results[op]; result.Count > 0 {
+										fmt.Println(result.String())
+
+										if result.LastError != `` {
+											fmt.Printf("       last error: %s\n", result.LastError)
 										}
-									} else if !ok {
-										log.Warningf("failed to copy record: invalid return type %T", ptrToInstance)
-									} else {
-										log.Warningf("failed to copy record %d: %v", i, err)
 									}
-								}); err == nil {
-									log.Noticef("Successfully copied %d records from collection %q", i, name)
-								} else {
-									log.Errorf("Failed to copy collection %q: %v", name, err)
 								}
-							} else {
-								log.Errorf("Cannot import to destination collection %q: collections differ", name)
 
-								for _, diff := range diffs {
-									log.Errorf("  %v", diff)
-								}
-							}
+								return nil
+							})
 						} else {
-							log.Errorf("Cannot export source collection %q: collection is not enumerable", name)
+							log.Fatalf("bench failed: %v", err)
 						}
 					} else {
-						log.Errorf("Cannot export source collection %q: %v", name, err)
+						log.Fatalf("connect: %v", err)
 					}
+				} else {
+					log.Fatalf("Must specify a backend to connect to.")
 				}
 			},
 		}, {
@@ -396,6 +1725,268 @@ func main() {
 					log.Fatalf("invalid filter: %v", err)
 				}
 			},
+			Subcommands: cli.Commands{
+				{
+					Name:      `lint`,
+					Usage:     `Report problems with filters (read from standard input if none are given), exiting non-zero if any are found.`,
+					ArgsUsage: `[FILTER ..]`,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  `collection, c`,
+							Usage: `Check fields against this collection, as defined in the schema files given with --schema.`,
+						},
+						cli.StringFlag{
+							Name:  `format, f`,
+							Usage: `How to format the output. (one of: text, json)`,
+							Value: `text`,
+						},
+						cli.BoolFlag{
+							Name:  `pretty, P`,
+							Usage: `Pretty-print the formatted output (indenting where applicable)`,
+						},
+					},
+					Action: func(c *cli.Context) {
+						var collection *dal.Collection
+						var results = make(map[string][]filter.LintIssue)
+						var failed bool
+
+						if name := c.String(`collection`); name != `` {
+							if loaded, err := pivot.LoadSchemata(c.GlobalStringSlice(`schema`)...); err == nil {
+								for _, schema := range loaded {
+									if schema.Name == name {
+										collection = schema
+									}
+								}
+							} else {
+								log.Fatalf("failed to load schemata: %v", err)
+							}
+
+							if collection == nil {
+								log.Fatalf("Collection %q is not defined in any of the given schema files", name)
+							}
+						}
+
+						var specs = filterSpecsFromInput(c)
+
+						for _, spec := range specs {
+							if issues, err := filter.Lint(spec, collection); err == nil {
+								results[spec] = issues
+							} else {
+								results[spec] = []filter.LintIssue{{
+									Message: err.Error(),
+								}}
+							}
+
+							if len(results[spec]) > 0 {
+								failed = true
+							}
+						}
+
+						output(c, results, func() error {
+							for _, spec := range specs {
+								for _, issue := range results[spec] {
+									fmt.Printf("%s: %v\n", spec, issue)
+								}
+							}
+
+							return nil
+						})
+
+						if failed {
+							os.Exit(1)
+						}
+					},
+				}, {
+					Name:      `fmt`,
+					Usage:     `Print filters (read from standard input if none are given) in their canonical form.`,
+					ArgsUsage: `[FILTER ..]`,
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  `check`,
+							Usage: `Print filters that are not already in canonical form, and exit non-zero if there are any.`,
+						},
+					},
+					Action: func(c *cli.Context) {
+						var unformatted bool
+
+						for _, spec := range filterSpecsFromInput(c) {
+							if formatted, err := filter.Format(spec); err == nil {
+								if !c.Bool(`check`) {
+									fmt.Println(formatted)
+								} else if formatted != spec {
+									fmt.Println(spec)
+									unformatted = true
+								}
+							} else {
+								log.Fatalf("invalid filter %q: %v", spec, err)
+							}
+						}
+
+						if unformatted {
+							os.Exit(1)
+						}
+					},
+				},
+			},
+		}, {
+			Name:  `generate`,
+			Usage: `Generate code from schema definitions.`,
+			Subcommands: cli.Commands{
+				{
+					Name:  `client`,
+					Usage: `Generate a typed client for the HTTP API from the schemata given with --schema.`,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  `lang, l`,
+							Usage: `The language to generate the client in. (one of: go, typescript)`,
+							Value: `go`,
+						},
+						cli.StringFlag{
+							Name:  `package, p`,
+							Usage: `The package name to use for generated Go code.`,
+							Value: client.DefaultGeneratedPackage,
+						},
+						cli.StringFlag{
+							Name:  `output, o`,
+							Usage: `Write the generated client to this file instead of standard output.`,
+						},
+					},
+					Action: func(c *cli.Context) {
+						var out io.Writer = os.Stdout
+
+						collections, err := pivot.LoadSchemata(c.GlobalStringSlice(`schema`)...)
+
+						if err != nil {
+							log.Fatalf("failed to load schemata: %v", err)
+						} else if len(collections) == 0 {
+							log.Fatalf("No collections were loaded; specify schema files with --schema")
+						}
+
+						if filename := c.String(`output`); filename != `` {
+							if file, err := os.Create(filename); err == nil {
+								defer file.Close()
+								out = file
+							} else {
+								log.Fatal(err)
+							}
+						}
+
+						if err := client.Generate(out, collections, client.GenerateOptions{
+							Language: c.String(`lang`),
+							Package:  c.String(`package`),
+						}); err != nil {
+							log.Fatal(err)
+						}
+					},
+				},
+			},
+		}, {
+			Name:      `codegen`,
+			Usage:     `Generate Go structs and collection definitions from the schema of an existing database.`,
+			ArgsUsage: `CONNECTION_STRING [COLLECTION ..]`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `package, p`,
+					Usage: `The package name to use for generated Go code.`,
+					Value: client.DefaultModelsPackage,
+				},
+				cli.StringFlag{
+					Name:  `output, o`,
+					Usage: `Write the generated code to this file (or, if it is a directory, one file per collection in it) instead of standard output.`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				var backend string
+				var config pivot.Configuration
+
+				if cnf, err := pivot.LoadConfigFile(c.GlobalString(`config`)); err == nil {
+					config = cnf.ForEnv(os.Getenv(`PIVOT_ENV`))
+				} else if !os.IsNotExist(err) {
+					log.Fatalf("Configuration error: %v", err)
+				}
+
+				if cs := c.Args().First(); cs != `` {
+					backend = cs
+				} else {
+					backend = config.Backend
+				}
+
+				if backend == `` {
+					log.Fatalf("Must specify a backend to connect to.")
+				}
+
+				db, err := pivot.NewDatabaseWithOptions(backend, pivot.ConnectOptions{})
+
+				if err != nil {
+					log.Fatalf("connect: %v", err)
+				}
+
+				var names = c.Args().Tail()
+				var collections = make([]*dal.Collection, 0)
+
+				if len(names) == 0 {
+					if all, err := db.ListCollections(); err == nil {
+						for _, name := range all {
+							// skip the collections pivot keeps its own bookkeeping in
+							if !strings.HasPrefix(name, `__pivot_`) {
+								names = append(names, name)
+							}
+						}
+					} else {
+						log.Fatalf("failed to retrieve collections: %v", err)
+					}
+				}
+
+				for _, name := range names {
+					if collection, err := db.GetCollection(name); err == nil {
+						collections = append(collections, collection)
+					} else {
+						log.Fatalf("%s: %v", name, err)
+					}
+				}
+
+				if len(collections) == 0 {
+					log.Fatalf("No collections were found")
+				}
+
+				var filename = c.String(`output`)
+
+				if filename != `` && fileutil.DirExists(filename) {
+					for _, collection := range collections {
+						var path = filepath.Join(filename, collection.Name+`.go`)
+
+						if file, err := os.Create(path); err == nil {
+							err = client.GenerateModels(file, []*dal.Collection{collection}, c.String(`package`))
+							file.Close()
+
+							if err == nil {
+								log.Infof("%s: wrote %s", collection.Name, path)
+							} else {
+								log.Fatalf("%s: %v", collection.Name, err)
+							}
+						} else {
+							log.Fatal(err)
+						}
+					}
+
+					return
+				}
+
+				var out io.Writer = os.Stdout
+
+				if filename != `` {
+					if file, err := os.Create(filename); err == nil {
+						defer file.Close()
+						out = file
+					} else {
+						log.Fatal(err)
+					}
+				}
+
+				if err := client.GenerateModels(out, collections, c.String(`package`)); err != nil {
+					log.Fatal(err)
+				}
+			},
 		}, {
 			Name:  `client`,
 			Usage: `Provides an HTTP API client for interacting with a running Pivot instance.`,
@@ -408,12 +1999,20 @@ func main() {
 				},
 				cli.StringFlag{
 					Name:  `format, f`,
-					Usage: `How to format API result output. (one of: text, json, yaml)`,
+					Usage: `How to format API result output. (one of: text, json, yaml; queries may also use csv or parquet)`,
 				},
 				cli.BoolFlag{
 					Name:  `pretty, P`,
 					Usage: `Pretty-print the formatted output (indenting where applicable)`,
 				},
+				cli.DurationFlag{
+					Name:  `timeout, t`,
+					Usage: `How long each API request may take (0 is unlimited).`,
+				},
+				cli.IntFlag{
+					Name:  `retries, r`,
+					Usage: `How many times to retry a failed read request.`,
+				},
 			},
 			Subcommands: cli.Commands{
 				{
@@ -582,7 +2181,6 @@ func main() {
 					Action: func(c *cli.Context) {
 						if collection := c.Args().First(); collection != `` {
 							filters := make([]string, 0)
-							offset := c.Int(`offset`)
 							fSort := sliceutil.CompactString(strings.Split(c.String(`sort`), `,`))
 							fFields := sliceutil.CompactString(strings.Split(c.String(`fields`), `,`))
 
@@ -590,28 +2188,43 @@ func main() {
 								filters = args[1:]
 							}
 
-							for {
-								if results, err := pivotClient(c).Query(collection, filters, &client.QueryOptions{
-									Limit:  c.Int(`limit`),
-									Offset: offset,
-									Sort:   fSort,
-									Fields: fFields,
-								}); err == nil {
+							options := &client.QueryOptions{
+								Limit:  c.Int(`limit`),
+								Offset: c.Int(`offset`),
+								Sort:   fSort,
+								Fields: fFields,
+							}
+
+							// tabular formats are streamed directly from the server
+							switch format := c.String(`format`); format {
+							case `csv`, `parquet`:
+								if err := pivotClient(c).Export(collection, filters, options, format, os.Stdout); err != nil {
+									log.Fatal(err)
+								}
+
+								return
+							}
+
+							if !c.Bool(`autopage`) {
+								if results, err := pivotClient(c).Query(collection, filters, options); err == nil {
 									for _, record := range results.Records {
 										output(c, record.Map(fFields...), nil)
 									}
-
-									if len(results.Records) == 0 {
-										return
-									} else if !c.Bool(`autopage`) {
-										return
-									}
-
-									offset += len(results.Records)
 								} else {
 									log.Fatal(err)
-									return
 								}
+
+								return
+							}
+
+							records := pivotClient(c).QueryIter(collection, filters, options)
+
+							for records.Next() {
+								output(c, records.Record().Map(fFields...), nil)
+							}
+
+							if err := records.Err(); err != nil {
+								log.Fatal(err)
 							}
 						} else {
 							log.Fatalf("Must specify a collection to query.")
@@ -633,9 +2246,35 @@ func logerr(c *cli.Context, format string, args ...interface{}) {
 	}
 }
 
+// returns the filters given as arguments, or read one per line from standard input if there are none
+func filterSpecsFromInput(c *cli.Context) []string {
+	var specs = make([]string, 0)
+
+	if len(c.Args()) > 0 {
+		return c.Args()
+	} else if !fileutil.IsTerminal() {
+		lines := bufio.NewScanner(os.Stdin)
+
+		for lines.Scan() {
+			if line := strings.TrimSpace(lines.Text()); line != `` && !strings.HasPrefix(line, `#`) {
+				specs = append(specs, line)
+			}
+		}
+
+		if err := lines.Err(); err != nil {
+			log.Fatalf("failed to read filters: %v", err)
+		}
+	}
+
+	return specs
+}
+
 func pivotClient(c *cli.Context) *client.Pivot {
-	if c, err := client.New(c.String(`url`)); err == nil {
-		return c
+	if pc, err := client.New(c.String(`url`)); err == nil {
+		pc.Retries = c.Int(`retries`)
+		pc.SetTimeout(c.Duration(`timeout`))
+
+		return pc
 	} else {
 		log.Fatalf("client error: %v", err)
 		return nil
@@ -0,0 +1,225 @@
+package pivot
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Responses smaller than this many bytes are sent uncompressed, since the overhead of compressing
+// them outweighs the savings.
+var CompressionMinimumSize = 1024
+
+// The compression level used for gzip and deflate response encoding.
+var CompressionLevel = gzip.DefaultCompression
+
+// Transparently decompresses request bodies sent with a Content-Encoding of gzip or deflate, and
+// compresses responses for clients that advertise support for either via Accept-Encoding.
+func compressionMiddleware(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	switch encoding := strings.ToLower(req.Header.Get(`Content-Encoding`)); encoding {
+	case ``, `identity`:
+		break
+	case `gzip`, `x-gzip`:
+		if reader, err := gzip.NewReader(req.Body); err == nil {
+			req.Body = readCloser{reader, req.Body}
+		} else {
+			http.Error(w, fmt.Sprintf("invalid gzip request body: %v", err), http.StatusBadRequest)
+			return
+		}
+	case `deflate`:
+		req.Body = readCloser{flate.NewReader(req.Body), req.Body}
+	default:
+		http.Error(w, fmt.Sprintf("unsupported content encoding %q", encoding), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if req.Header.Get(`Content-Encoding`) != `` {
+		req.Header.Del(`Content-Encoding`)
+		req.Header.Del(`Content-Length`)
+		req.ContentLength = -1
+	}
+
+	if encoding := negotiateEncoding(req.Header.Get(`Accept-Encoding`)); encoding != `` && req.Method != `HEAD` {
+		cw := &compressingResponseWriter{
+			ResponseWriter: w,
+			encoding:       encoding,
+			status:         http.StatusOK,
+		}
+
+		defer cw.Close()
+
+		next(cw, req)
+	} else {
+		next(w, req)
+	}
+}
+
+// pick the preferred encoding we support from an Accept-Encoding header
+func negotiateEncoding(header string) string {
+	var deflate bool
+
+	for _, part := range strings.Split(header, `,`) {
+		name, params := part, ``
+
+		if i := strings.Index(part, `;`); i >= 0 {
+			name, params = part[:i], part[i+1:]
+		}
+
+		// honor explicit refusals (e.g.: "gzip;q=0")
+		if q := strings.TrimSpace(params); strings.HasPrefix(q, `q=`) {
+			if v, err := strconv.ParseFloat(strings.TrimPrefix(q, `q=`), 64); err == nil && v == 0 {
+				continue
+			}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case `gzip`, `x-gzip`:
+			return `gzip`
+		case `deflate`:
+			deflate = true
+		}
+	}
+
+	if deflate {
+		return `deflate`
+	}
+
+	return ``
+}
+
+type readCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (self readCloser) Close() error {
+	return self.closer.Close()
+}
+
+// buffers the start of a response until it is large enough to be worth compressing, then streams
+// the remainder through the compressor
+type compressingResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	status      int
+	buffer      bytes.Buffer
+	compressor  io.WriteCloser
+	wroteHeader bool
+	passthrough bool
+}
+
+func (self *compressingResponseWriter) WriteHeader(status int) {
+	self.status = status
+}
+
+func (self *compressingResponseWriter) Write(data []byte) (int, error) {
+	if self.passthrough {
+		return self.ResponseWriter.Write(data)
+	} else if self.compressor != nil {
+		return self.compressor.Write(data)
+	}
+
+	// responses that are already encoded are left alone
+	if self.Header().Get(`Content-Encoding`) != `` {
+		return self.startPassthrough(data)
+	}
+
+	self.buffer.Write(data)
+
+	if self.buffer.Len() >= CompressionMinimumSize {
+		if err := self.startCompressing(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(data), nil
+}
+
+func (self *compressingResponseWriter) startPassthrough(data []byte) (int, error) {
+	self.passthrough = true
+	self.flushHeader()
+
+	if self.buffer.Len() > 0 {
+		if _, err := self.ResponseWriter.Write(self.buffer.Bytes()); err != nil {
+			return 0, err
+		}
+
+		self.buffer.Reset()
+	}
+
+	return self.ResponseWriter.Write(data)
+}
+
+func (self *compressingResponseWriter) startCompressing() error {
+	var err error
+
+	switch self.encoding {
+	case `gzip`:
+		self.compressor, err = gzip.NewWriterLevel(self.ResponseWriter, CompressionLevel)
+	case `deflate`:
+		self.compressor, err = flate.NewWriter(self.ResponseWriter, CompressionLevel)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	self.Header().Set(`Content-Encoding`, self.encoding)
+	self.Header().Del(`Content-Length`)
+	self.Header().Add(`Vary`, `Accept-Encoding`)
+	self.flushHeader()
+
+	_, err = self.compressor.Write(self.buffer.Bytes())
+	self.buffer.Reset()
+
+	return err
+}
+
+func (self *compressingResponseWriter) flushHeader() {
+	if !self.wroteHeader {
+		self.wroteHeader = true
+		self.ResponseWriter.WriteHeader(self.status)
+	}
+}
+
+func (self *compressingResponseWriter) Flush() {
+	if self.compressor == nil && !self.passthrough {
+		self.startPassthrough(nil)
+	}
+
+	if flusher, ok := self.compressor.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+
+	if flusher, ok := self.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (self *compressingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := self.ResponseWriter.(http.Hijacker); ok {
+		self.passthrough = true
+		return hijacker.Hijack()
+	}
+
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
+}
+
+// Close finishes the response, writing out any small responses that were buffered uncompressed.
+func (self *compressingResponseWriter) Close() error {
+	if self.compressor != nil {
+		return self.compressor.Close()
+	} else if !self.passthrough {
+		_, err := self.startPassthrough(nil)
+		return err
+	}
+
+	return nil
+}
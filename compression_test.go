@@ -0,0 +1,85 @@
+package pivot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	assert := require.New(t)
+
+	large := strings.Repeat(`{"id":1,"fields":{"name":"test"}}`, 100)
+
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+
+		w.Header().Set(`Content-Type`, `application/json`)
+		w.WriteHeader(http.StatusAccepted)
+
+		if len(body) > 0 {
+			w.Write(body)
+		} else if req.URL.Path == `/small` {
+			w.Write([]byte(`{}`))
+		} else {
+			w.Write([]byte(large))
+		}
+	}
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		compressionMiddleware(w, req, handler)
+		return w
+	}
+
+	// large responses are compressed when the client accepts gzip
+	req := httptest.NewRequest(`GET`, `/large`, nil)
+	req.Header.Set(`Accept-Encoding`, `deflate, gzip`)
+	w := serve(req)
+
+	assert.Equal(http.StatusAccepted, w.Code)
+	assert.Equal(`gzip`, w.Header().Get(`Content-Encoding`))
+
+	reader, err := gzip.NewReader(w.Body)
+	assert.NoError(err)
+	data, err := ioutil.ReadAll(reader)
+	assert.NoError(err)
+	assert.Equal(large, string(data))
+
+	// small responses are sent as-is
+	req = httptest.NewRequest(`GET`, `/small`, nil)
+	req.Header.Set(`Accept-Encoding`, `gzip`)
+	w = serve(req)
+
+	assert.Equal(http.StatusAccepted, w.Code)
+	assert.Empty(w.Header().Get(`Content-Encoding`))
+	assert.Equal(`{}`, w.Body.String())
+
+	// clients that don't ask for compression don't get it
+	w = serve(httptest.NewRequest(`GET`, `/large`, nil))
+	assert.Empty(w.Header().Get(`Content-Encoding`))
+	assert.Equal(large, w.Body.String())
+
+	req = httptest.NewRequest(`GET`, `/large`, nil)
+	req.Header.Set(`Accept-Encoding`, `gzip;q=0`)
+	w = serve(req)
+	assert.Empty(w.Header().Get(`Content-Encoding`))
+
+	// compressed request bodies are decompressed before reaching the handler
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(`{"records":[]}`))
+	gz.Close()
+
+	req = httptest.NewRequest(`POST`, `/`, &compressed)
+	req.Header.Set(`Content-Encoding`, `gzip`)
+	w = serve(req)
+
+	assert.Equal(`{"records":[]}`, w.Body.String())
+}
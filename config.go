@@ -4,15 +4,22 @@ import (
 	"io/ioutil"
 
 	"github.com/fatih/structs"
+	"github.com/ghetzel/pivot/v3/backends"
 	"github.com/ghodss/yaml"
 )
 
 type Configuration struct {
-	Backend               string                   `json:"backend"`
-	Indexer               string                   `json:"indexer"`
-	Autoexpand            bool                     `json:"autoexpand"`
-	AutocreateCollections bool                     `json:"autocreate"`
-	Environments          map[string]Configuration `json:"environments"`
+	Backend               string                     `json:"backend"`
+	Indexer               string                     `json:"indexer"`
+	Autoexpand            bool                       `json:"autoexpand"`
+	EmbedLinks            bool                       `json:"links"`
+	AutocreateCollections bool                       `json:"autocreate"`
+	TrackUsage            bool                       `json:"track_usage"`
+	PersistCollections    bool                       `json:"persist_collections"`
+	Stats                 backends.StatsOptions      `json:"stats"`
+	JoinBackends          map[string]string          `json:"join_backends"`
+	Routes                []backends.CollectionRoute `json:"routes"`
+	Environments          map[string]Configuration   `json:"environments"`
 }
 
 func LoadConfigFile(path string) (Configuration, error) {
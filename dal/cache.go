@@ -0,0 +1,88 @@
+package dal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+)
+
+// Describes how long clients and shared caches (e.g.: CDNs, proxies) may cache records retrieved
+// from a collection.  This is most useful for reference data that changes rarely.
+type CachePolicy struct {
+	// How long (in seconds) responses may be used without checking with the server again.
+	MaxAge int `json:"max_age,omitempty"`
+
+	// Stale responses must not be used without first checking with the server.
+	MustRevalidate bool `json:"must_revalidate,omitempty"`
+
+	// Responses will never change while they are fresh, so clients need not revalidate them (even
+	// when the user reloads the page).
+	Immutable bool `json:"immutable,omitempty"`
+
+	// Only the requesting client may cache responses; shared caches must not.
+	Private bool `json:"private,omitempty"`
+
+	// The name of a field containing the time each record was last modified.  If set, responses
+	// include a Last-Modified header with the most recent of these times.
+	LastModifiedField string `json:"last_modified_field,omitempty"`
+}
+
+// Returns the value of the Cache-Control header describing this policy.
+func (self *CachePolicy) CacheControl() string {
+	var directives = make([]string, 0)
+
+	if self.Private {
+		directives = append(directives, `private`)
+	} else {
+		directives = append(directives, `public`)
+	}
+
+	directives = append(directives, fmt.Sprintf("max-age=%d", self.MaxAge))
+
+	if self.MustRevalidate {
+		directives = append(directives, `must-revalidate`)
+	}
+
+	if self.Immutable {
+		directives = append(directives, `immutable`)
+	}
+
+	return strings.Join(directives, `, `)
+}
+
+// Returns the most recent modification time of the given records, or a zero time if none of them
+// have one (or no LastModifiedField is set).
+func (self *CachePolicy) LastModified(records ...*Record) time.Time {
+	var latest time.Time
+
+	if self.LastModifiedField == `` {
+		return latest
+	}
+
+	for _, record := range records {
+		if record == nil {
+			continue
+		}
+
+		if t := typeutil.V(record.Get(self.LastModifiedField)).Time(); t.After(latest) {
+			latest = t
+		}
+	}
+
+	return latest
+}
+
+// Verify that the policy is valid for the given collection.
+func (self *CachePolicy) Validate(collection *Collection) error {
+	if self.MaxAge < 0 {
+		return fmt.Errorf("cache policy: max_age cannot be negative")
+	} else if field := self.LastModifiedField; field != `` {
+		if _, ok := collection.GetField(field); !ok {
+			return fmt.Errorf("cache policy: last_modified_field %q: no such field", field)
+		}
+	}
+
+	return nil
+}
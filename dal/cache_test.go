@@ -0,0 +1,53 @@
+package dal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachePolicy(t *testing.T) {
+	assert := require.New(t)
+
+	policy := &CachePolicy{
+		MaxAge: 3600,
+	}
+
+	assert.Equal(`public, max-age=3600`, policy.CacheControl())
+
+	policy.Private = true
+	policy.MustRevalidate = true
+	policy.Immutable = true
+
+	assert.Equal(`private, max-age=3600, must-revalidate, immutable`, policy.CacheControl())
+
+	// without a field to check, nothing has a modification time
+	assert.True(policy.LastModified(NewRecord(1).Set(`updated_at`, time.Now())).IsZero())
+
+	policy.LastModifiedField = `updated_at`
+
+	older := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(newer, policy.LastModified(
+		NewRecord(1).Set(`updated_at`, older),
+		NewRecord(2).Set(`updated_at`, newer),
+		NewRecord(3),
+		nil,
+	))
+
+	collection := NewCollection(`countries`, Field{
+		Name: `updated_at`,
+		Type: TimeType,
+	})
+
+	assert.NoError(policy.Validate(collection))
+
+	policy.LastModifiedField = `modified`
+	assert.Error(policy.Validate(collection))
+
+	policy.LastModifiedField = ``
+	policy.MaxAge = -1
+	assert.Error(policy.Validate(collection))
+}
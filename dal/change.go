@@ -0,0 +1,78 @@
+package dal
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+type ChangeType string
+
+const (
+	RecordCreated ChangeType = `create`
+	RecordUpdated ChangeType = `update`
+	RecordDeleted ChangeType = `delete`
+)
+
+// Describes the value of a single field before and after a change.
+type FieldChange struct {
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+// Describes a record that was successfully created, updated, or deleted.
+type ChangeEvent struct {
+	Type       ChangeType             `json:"type"`
+	Collection string                 `json:"collection"`
+	ID         interface{}            `json:"id"`
+	Changes    map[string]FieldChange `json:"changes,omitempty"` // nil if the changed fields are not known
+	Record     *Record                `json:"record,omitempty"`  // the record after the change (or before it, for deletes), if known
+	Timestamp  time.Time              `json:"timestamp"`
+}
+
+// Returns the fields whose values differ between two versions of a record.  Only fields present
+// in after are compared; either record may be nil (e.g.: when a record is created or deleted).
+func DiffRecords(before *Record, after *Record) map[string]FieldChange {
+	var changes = make(map[string]FieldChange)
+
+	if after == nil {
+		if before != nil {
+			for key, value := range before.Fields {
+				changes[key] = FieldChange{
+					Old: value,
+				}
+			}
+		}
+
+		return changes
+	}
+
+	for key, value := range after.Fields {
+		var old interface{}
+
+		if before != nil {
+			old = before.Get(key)
+		}
+
+		if !valuesEqual(old, value) {
+			changes[key] = FieldChange{
+				Old: old,
+				New: value,
+			}
+		}
+	}
+
+	return changes
+}
+
+// backends don't always hand back the same types they were given (e.g.: int vs. int64), so values
+// that print identically are considered equal
+func valuesEqual(a interface{}, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	} else if a == nil || b == nil {
+		return false
+	}
+
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
@@ -47,6 +47,11 @@ type Collection struct {
 	// Disable automatically dual-writing modified records into the external index.
 	SkipIndexPersistence bool `json:"skip_index_persistence,omitempty"`
 
+	// Specifies that queries against this collection's external index only determine which records
+	// match; each result is then retrieved by ID from the backend so that stale or partial index
+	// documents are never returned directly.
+	HydrateFromBackend bool `json:"hydrate_from_backend,omitempty"`
+
 	// The fields that belong to this collection (all except the primary key/identity field/first
 	// field in a composite key)
 	Fields []Field `json:"fields"`
@@ -71,6 +76,17 @@ type Collection struct {
 	// backends that support such guarantees (e.g.: ACID-compliant RDBMS').
 	Constraints []Constraint `json:"constraints,omitempty"`
 
+	// Declares non-unique secondary indexes (on one or more fields) that should be created by
+	// backends that support them.  Single-field indexes can also be declared by setting Indexed on
+	// the field itself.
+	Indexes []SecondaryIndex `json:"indexes,omitempty"`
+
+	// Declares which fields are included in full-text searches of this collection, and how heavily
+	// matches in each are weighted.  Indexers use this when building their mappings and when
+	// evaluating free-text queries; if no search fields are declared, full-text queries are run
+	// against all fields.
+	SearchFields []SearchField `json:"search_fields,omitempty"`
+
 	// Specifies which fields can be seen when records are from relationships defined on other
 	// Collections.  This can be used to restrict the exposure) of sensitive data in this Collection
 	// be being an embedded field in another Collection.
@@ -79,6 +95,20 @@ type Collection struct {
 	// Specify whether missing related fields generate an error when retrieving a record.
 	AllowMissingEmbeddedRecords bool `json:"allow_missing_embedded_records"`
 
+	// Specifies what happens to fields that aren't defined in this Collection when records are
+	// written: they are either dropped ("ignore"), cause the write to fail ("reject"), or are
+	// written as-is ("passthrough").  Defaults to "ignore", or "passthrough" if the Collection
+	// does not define any fields.
+	UnknownFields UnknownFieldPolicy `json:"unknown_fields,omitempty"`
+
+	// Describes how long clients and shared caches may cache records from this Collection when
+	// they are served over HTTP.  If not set, no caching headers are sent.
+	Cache *CachePolicy `json:"cache,omitempty"`
+
+	// Describes how the Collection's data is partitioned and placed across the nodes of
+	// distributed databases that support it.
+	Placement *Placement `json:"placement,omitempty"`
+
 	// A read-only count of the number of records in this Collection
 	TotalRecords int64 `json:"total_records,omitempty"`
 
@@ -109,6 +139,12 @@ type Collection struct {
 	// validator when validation requires checking multiple fields at once.
 	PreSaveValidator CollectionValidatorFunc `json:"-"`
 
+	// If specified, this function is called exactly once per Insert or Update operation and receives
+	// the entire RecordSet being written.  This allows for modifications that require knowledge of
+	// every record in the batch (e.g.: assigning sequential positions, removing duplicates).  It is
+	// called before any per-record formatters or validators.
+	PreSaveRecordSetFormatter RecordSetFormatterFunc `json:"-"`
+
 	// Specifies that this collection is a read-only view on data that is queried by the underlying database engine.
 	View bool `json:"view,omitempty"`
 
@@ -263,6 +299,30 @@ func (self *Collection) ApplyDefinition(definition *Collection) error {
 			self.IdentityFieldValidator = fn
 		}
 
+		if fn := definition.PreSaveRecordSetFormatter; fn != nil {
+			self.PreSaveRecordSetFormatter = fn
+		}
+
+		if len(definition.Indexes) > 0 {
+			self.Indexes = definition.Indexes
+		}
+
+		if len(definition.SearchFields) > 0 {
+			self.SearchFields = definition.SearchFields
+		}
+
+		if v := definition.UnknownFields; v != `` {
+			self.UnknownFields = v
+		}
+
+		if v := definition.Cache; v != nil {
+			self.Cache = v
+		}
+
+		if definition.HydrateFromBackend {
+			self.HydrateFromBackend = true
+		}
+
 		for i, field := range self.Fields {
 			if defField, ok := definition.GetField(field.Name); ok {
 				if field.Description == `` {
@@ -287,6 +347,8 @@ func (self *Collection) ApplyDefinition(definition *Collection) error {
 				self.Fields[i].ValidateOnPopulate = defField.ValidateOnPopulate
 				self.Fields[i].Validator = defField.Validator
 				self.Fields[i].Formatter = defField.Formatter
+				self.Fields[i].Schema = defField.Schema
+				self.Fields[i].Indexed = defField.Indexed
 			} else {
 				return fmt.Errorf("Definition is missing field %q", field.Name)
 			}
@@ -313,7 +375,7 @@ func (self *Collection) HasRecordType() bool {
 func (self *Collection) FillDefaults(record *Record) {
 	for _, field := range self.Fields {
 		if field.DefaultValue != nil {
-			if typeutil.IsZero(record.Get(field.Name)) {
+			if v := record.Get(field.Name); typeutil.IsZero(v) && !IsNull(v) {
 				record.Set(field.Name, field.GetDefaultValue())
 			}
 		}
@@ -456,6 +518,12 @@ func (self *Collection) ConvertValue(name string, value interface{}) interface{}
 // should be returned to the user (for retrieval operations) in accordance with the named field's data type and
 // formatters.  Invalid values (determined by Validators and the Required option in the Field) will return an error.
 func (self *Collection) ValueForField(name string, value interface{}, op FieldOperation) (interface{}, error) {
+	value, _, err := self.valueForField(name, value, op)
+	return value, err
+}
+
+// same as ValueForField, but also identifies the reason the value was rejected (if it was)
+func (self *Collection) valueForField(name string, value interface{}, op FieldOperation) (interface{}, ValidationErrorCode, error) {
 	var formatter FieldFormatterFunc
 	var validator FieldValidatorFunc
 
@@ -467,7 +535,7 @@ func (self *Collection) ValueForField(name string, value interface{}, op FieldOp
 		if v, err := self.extractValueFromRelationship(&field, value, op); err == nil {
 			value = v
 		} else {
-			return nil, err
+			return nil, InvalidCode, err
 		}
 
 		if v, err := field.ConvertValue(value); err == nil {
@@ -476,28 +544,43 @@ func (self *Collection) ValueForField(name string, value interface{}, op FieldOp
 
 			// fmt.Printf("%v: value %T(%v) becomes %T(%v)\n", name, value, value, v, v)
 			value = v
+		} else if IsNull(value) {
+			return nil, RequiredCode, err
 		} else {
-			return nil, err
+			return nil, InvalidTypeCode, err
 		}
+	} else if self.GetUnknownFieldPolicy() == PassthroughUnknownFields {
+		return value, ``, nil
 	} else {
-		return nil, FieldNotFound
+		return nil, UnknownFieldCode, FieldNotFound
+	}
+
+	// explicit nulls aren't subject to formatting or validation
+	if IsNull(value) {
+		return value, ``, nil
 	}
 
 	if formatter != nil {
 		if v, err := formatter(value, op); err == nil {
 			value = v
 		} else {
-			return nil, err
+			return nil, FormatCode, err
 		}
 	}
 
 	if validator != nil {
 		if err := validator(value); err != nil {
-			return nil, err
+			return nil, InvalidCode, err
+		}
+	}
+
+	if field, ok := self.GetField(name); ok {
+		if err := field.ValidateSchema(value); err != nil {
+			return nil, SchemaCode, err
 		}
 	}
 
-	return value, nil
+	return value, ``, nil
 }
 
 // Takes a value provided for either persistence to the backend, or as retrieved from the backend, and hammers
@@ -508,7 +591,7 @@ func (self *Collection) extractValueFromRelationship(field *Field, input interfa
 	//	- AND this field has a relationship
 	//	- AND we have a struct
 	//	THEN we need to extract key(s) from that struct
-	if constraint := field.BelongsToConstraint(); constraint != nil {
+	if constraint := field.BelongsToConstraint(); constraint != nil && !IsNull(input) {
 		if resolved := typeutil.ResolveValue(input); typeutil.IsStruct(resolved) {
 			if relatedTo, err := self.GetRelatedCollection(constraint.Collection); err == nil {
 				if relatedRecord, err := relatedTo.StructToRecord(input); err == nil {
@@ -568,6 +651,46 @@ func (self *Collection) formatAndValidateId(id interface{}, op FieldOperation, r
 	return id, nil
 }
 
+// Returns the policy for handling fields that aren't defined in this collection.
+func (self *Collection) GetUnknownFieldPolicy() UnknownFieldPolicy {
+	if self.UnknownFields != `` {
+		return self.UnknownFields
+	} else if len(self.Fields) == 0 {
+		return PassthroughUnknownFields
+	} else {
+		return IgnoreUnknownFields
+	}
+}
+
+// Verify that the given record only contains fields defined in this collection, if the
+// collection's policy is to reject unknown fields.
+func (self *Collection) CheckUnknownFields(record *Record) error {
+	if record != nil {
+		for key := range record.Fields {
+			if key == self.GetIdentityFieldName() {
+				continue
+			} else if _, ok := self.GetField(key); !ok {
+				if err := self.checkUnknownField(key); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (self *Collection) checkUnknownField(name string) error {
+	if self.GetUnknownFieldPolicy() == RejectUnknownFields {
+		return UnknownFieldError{
+			Collection: self.Name,
+			Field:      name,
+		}
+	}
+
+	return nil
+}
+
 func (self *Collection) EmptyRecord() *Record {
 	record := NewRecord(nil)
 	self.FillDefaults(record)
@@ -583,7 +706,10 @@ func (self *Collection) StructToRecord(in interface{}) (*Record, error) {
 		return nil, err
 	}
 
-	output := self.EmptyRecord()
+	output := NewRecord(nil)
+
+	// every field is checked, and all of the problems found are returned together
+	verrs := NewValidationErrors(self.Name)
 
 	// if the argument is already a record, return it as-is
 	if record, ok := in.(*Record); ok {
@@ -595,12 +721,12 @@ func (self *Collection) StructToRecord(in interface{}) (*Record, error) {
 
 		// we're returning the record we were given, but first we need to validate and format it
 		for key, value := range record.Fields {
-			if v, err := self.ValueForField(key, value, PersistOperation); err == nil {
+			if v, code, err := self.valueForField(key, value, PersistOperation); err == nil {
 				output.Set(key, v)
 			} else if IsFieldNotFoundErr(err) {
-				continue
+				verrs.Add(key, UnknownFieldCode, self.checkUnknownField(key))
 			} else {
-				return nil, err
+				verrs.Add(key, code, err)
 			}
 		}
 	} else {
@@ -617,9 +743,19 @@ func (self *Collection) StructToRecord(in interface{}) (*Record, error) {
 				return nil
 			}
 
+			// set this so that generated IDs are written back to the struct we were given as input
+			// (even if the identity field is empty and omitted)
+			if idFieldName != `` && field.Name == idFieldName {
+				idDesc = desc
+			}
+
+			// nil fields tagged with "explicitnull" are set to NULL instead of being omitted or
+			// replaced with their default value
+			var explicitNull = desc.ExplicitNull && isNilValue(value)
+
 			// don't clobber existing fields with empty data, except for bools, whose
 			// zero value is meaningful
-			if typeutil.IsZero(value) && value.Kind() != reflect.Bool && desc.OmitEmpty {
+			if typeutil.IsZero(value) && value.Kind() != reflect.Bool && desc.OmitEmpty && !explicitNull {
 				return nil
 			}
 
@@ -627,15 +763,18 @@ func (self *Collection) StructToRecord(in interface{}) (*Record, error) {
 			if value.CanInterface() {
 				fieldValue := value.Interface()
 
-				if idFieldName != `` && field.Name == idFieldName {
-					output.ID = fieldValue
+				if explicitNull {
+					fieldValue = Null
+				}
 
-					// set this so that generated IDs are written back to the struct we were given as input
-					idDesc = desc
-				} else if v, err := self.ValueForField(desc.RecordKey, fieldValue, PersistOperation); err == nil {
+				if idFieldName != `` && field.Name == idFieldName {
+					output.ID = identityValueFromStruct(value)
+				} else if v, code, err := self.valueForField(desc.RecordKey, fieldValue, PersistOperation); err == nil {
 					output.Set(desc.RecordKey, v)
 				} else if !IsFieldNotFoundErr(err) {
-					return err
+					verrs.Add(desc.RecordKey, code, err)
+				} else {
+					verrs.Add(desc.RecordKey, UnknownFieldCode, self.checkUnknownField(desc.RecordKey))
 				}
 			}
 
@@ -645,19 +784,27 @@ func (self *Collection) StructToRecord(in interface{}) (*Record, error) {
 		}
 	}
 
+	// defaults are filled in last so that expressions (e.g.: sequences) are only evaluated for
+	// fields that need them
+	self.FillDefaults(output)
+
 	// validate the ID is cool and good
 	if idI, err := self.formatAndValidateId(output.ID, PersistOperation, output); err == nil {
 		output.ID = idI
-
-		if idDesc != nil {
-			if err := idDesc.Set(output.ID); err != nil {
-				return nil, fmt.Errorf("failed to writeback ID to input object: %v", err)
-			}
-		}
 	} else {
+		verrs.Add(self.GetIdentityFieldName(), InvalidCode, err)
+	}
+
+	if err := verrs.ErrorOrNil(); err != nil {
 		return nil, err
 	}
 
+	if idDesc != nil {
+		if err := idDesc.SetIdentity(output.ID); err != nil {
+			return nil, fmt.Errorf("failed to writeback ID to input object: %v", err)
+		}
+	}
+
 	// validate whole record
 	if err := self.ValidateRecord(output, PersistOperation); err != nil {
 		return nil, err
@@ -673,6 +820,23 @@ func (self *Collection) StructToRecord(in interface{}) (*Record, error) {
 	return output, nil
 }
 
+// Returns the identity of the given record, struct, or ID value without otherwise formatting or
+// validating it.  Pointers to structs are inspected for the field that StructToRecord would use
+// as the identity, and all other values are assumed to be IDs already.
+func (self *Collection) IdentityValueOf(in interface{}) interface{} {
+	if record, ok := in.(*Record); ok {
+		return record.ID
+	} else if validatePtrToStructType(in) == nil {
+		if name := getIdentityFieldName(in, self); name != `` {
+			return identityValueFromStruct(reflect.ValueOf(in).Elem().FieldByName(name))
+		} else {
+			return nil
+		}
+	} else {
+		return in
+	}
+}
+
 // Convert the given record into a map.
 func (self *Collection) MapFromRecord(record *Record, fields ...string) (map[string]interface{}, error) {
 	rv := make(map[string]interface{})
@@ -706,8 +870,17 @@ func (self *Collection) MapFromRecord(record *Record, fields ...string) (map[str
 					rv[field.Name] = v
 				}
 			}
-		} else {
+		}
+
+		// include any fields the collection doesn't define if we're allowed to
+		if self.GetUnknownFieldPolicy() == PassthroughUnknownFields {
 			for k, v := range record.Fields {
+				if _, ok := self.GetField(k); ok {
+					continue
+				} else if len(fields) > 0 && !sliceutil.ContainsString(fields, k) {
+					continue
+				}
+
 				rv[k] = v
 			}
 		}
@@ -731,6 +904,19 @@ func (self *Collection) ValidateRecord(record *Record, op FieldOperation) error
 	return nil
 }
 
+// Run the PreSaveRecordSetFormatter (if one is specified) against the given RecordSet.  Backends
+// should call this once at the start of every Insert and Update operation.  A warning is also
+// logged if any of the records write to a deprecated field.
+func (self *Collection) FormatRecordSet(recordset *RecordSet, isCreate bool) error {
+	self.warnDeprecatedFields(recordset)
+
+	if self.PreSaveRecordSetFormatter != nil && recordset != nil {
+		return self.PreSaveRecordSetFormatter(recordset, isCreate)
+	}
+
+	return nil
+}
+
 // Verifies that the schema passes some basic sanity checks.
 func (self *Collection) Check() error {
 	var merr error
@@ -743,6 +929,59 @@ func (self *Collection) Check() error {
 		if ParseFieldType(string(field.Type)) == `` {
 			merr = log.AppendError(merr, fmt.Errorf("collection[%s] field[%s]: invalid type %q", self.Name, field.Name, field.Type))
 		}
+
+		if fn, arg, ok := parseDefaultExpression(field.DefaultValue); ok {
+			if _, err := fn(&field, arg); err != nil {
+				merr = log.AppendError(merr, fmt.Errorf("collection[%s] field[%s]: invalid default value: %v", self.Name, field.Name, err))
+			}
+		}
+
+		if field.ReplacedBy != `` {
+			if !field.Deprecated {
+				merr = log.AppendError(merr, fmt.Errorf("collection[%s] field[%s]: only deprecated fields can specify a replacement", self.Name, field.Name))
+			} else if _, ok := self.GetField(field.ReplacedBy); !ok || field.ReplacedBy == field.Name {
+				merr = log.AppendError(merr, fmt.Errorf("collection[%s] field[%s]: invalid replacement field %q", self.Name, field.Name, field.ReplacedBy))
+			}
+		}
+
+		if len(field.Schema) > 0 {
+			if field.Type != ObjectType && field.Type != ArrayType {
+				merr = log.AppendError(merr, fmt.Errorf("collection[%s] field[%s]: schemas are only supported on object and array fields", self.Name, field.Name))
+			} else if err := CheckJSONSchema(field.Schema); err != nil {
+				merr = log.AppendError(merr, fmt.Errorf("collection[%s] field[%s]: invalid schema: %v", self.Name, field.Name, err))
+			}
+		}
+	}
+
+	for _, relationship := range self.EmbeddedCollections {
+		if err := relationship.Validate(); err != nil {
+			merr = log.AppendError(merr, fmt.Errorf("collection[%s]: %v", self.Name, err))
+		}
+	}
+
+	for _, sf := range self.SearchFields {
+		if err := sf.Validate(self); err != nil {
+			merr = log.AppendError(merr, fmt.Errorf("collection[%s]: %v", self.Name, err))
+		}
+	}
+
+	if self.Cache != nil {
+		if err := self.Cache.Validate(self); err != nil {
+			merr = log.AppendError(merr, fmt.Errorf("collection[%s]: %v", self.Name, err))
+		}
+	}
+
+	if self.Placement != nil {
+		if err := self.Placement.Validate(self); err != nil {
+			merr = log.AppendError(merr, fmt.Errorf("collection[%s]: %v", self.Name, err))
+		}
+	}
+
+	switch self.UnknownFields {
+	case ``, IgnoreUnknownFields, RejectUnknownFields, PassthroughUnknownFields:
+		break
+	default:
+		merr = log.AppendError(merr, fmt.Errorf("collection[%s]: invalid unknown field policy %q", self.Name, self.UnknownFields))
 	}
 
 	return merr
@@ -799,6 +1038,20 @@ func (self *Collection) Diff(actual *Collection) []*SchemaDelta {
 
 				differences = append(differences, diff...)
 			}
+		} else if previous, ok := actual.renamedField(myField); ok {
+			desired := myField
+
+			differences = append(differences, &SchemaDelta{
+				Type:           FieldDelta,
+				Issue:          FieldNameIssue,
+				Message:        `was renamed`,
+				Collection:     self.Name,
+				Name:           myField.Name,
+				Parameter:      `Name`,
+				Desired:        myField.Name,
+				Actual:         previous.Name,
+				ReferenceField: &desired,
+			})
 		} else {
 			differences = append(differences, &SchemaDelta{
 				Type:           FieldDelta,
@@ -818,6 +1071,17 @@ func (self *Collection) Diff(actual *Collection) []*SchemaDelta {
 	return differences
 }
 
+// returns the field in this collection that the given field was previously named, if any
+func (self *Collection) renamedField(field Field) (Field, bool) {
+	for _, name := range field.RenamedFrom {
+		if previous, ok := self.GetField(name); ok {
+			return previous, true
+		}
+	}
+
+	return Field{}, false
+}
+
 // Retrieve the set of all Constraints on this collection, both explicitly provided
 // via the Constraints field, as well as constraints specified using the "BelongsTo"
 // shorthand on Fields.
@@ -847,6 +1111,33 @@ func (self *Collection) GetAllConstraints() (constraints []Constraint) {
 	return
 }
 
+// Returns all secondary indexes declared on this collection, including single-field indexes on
+// fields that have Indexed set.
+func (self *Collection) GetAllIndexes() (indexes []SecondaryIndex) {
+	indexes = append(indexes, self.Indexes...)
+
+	for _, field := range self.Fields {
+		if field.Indexed {
+			var exists bool
+
+			for _, index := range indexes {
+				if len(index.Fields) == 1 && index.Fields[0] == field.Name {
+					exists = true
+					break
+				}
+			}
+
+			if !exists {
+				indexes = append(indexes, SecondaryIndex{
+					Fields: []string{field.Name},
+				})
+			}
+		}
+	}
+
+	return
+}
+
 // Retrieves a Collection by name from the backend this Collection is registered to.
 func (self *Collection) GetRelatedCollection(name string) (*Collection, error) {
 	if self.backend == nil {
@@ -2,6 +2,7 @@ package dal
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -109,6 +110,107 @@ func TestCollectionStructToRecord(t *testing.T) {
 	assert.EqualValues(0, record.Get(`age`))
 }
 
+type testUserID string
+
+type testTextID struct {
+	Prefix string
+	Number int
+}
+
+func (self testTextID) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%s-%d", self.Prefix, self.Number)), nil
+}
+
+func (self *testTextID) UnmarshalText(text []byte) error {
+	if _, err := fmt.Sscanf(strings.Replace(string(text), `-`, ` `, 1), "%s %d", &self.Prefix, &self.Number); err != nil {
+		return fmt.Errorf("invalid ID %q: %v", string(text), err)
+	}
+
+	return nil
+}
+
+func TestCollectionStructToRecordIdentityWriteback(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestCollectionStructToRecordIdentityWriteback`, Field{
+		Name: `name`,
+		Type: StringType,
+	})
+
+	collection.IdentityFieldFormatter = func(id interface{}, op FieldOperation) (interface{}, error) {
+		if record, ok := id.(*Record); ok && record.ID == nil {
+			return `usr-42`, nil
+		} else if ok {
+			return record.ID, nil
+		} else {
+			return id, nil
+		}
+	}
+
+	// named types
+	type NamedRecord struct {
+		ID   testUserID `pivot:"id,identity"`
+		Name string     `pivot:"name"`
+	}
+
+	named := NamedRecord{
+		Name: `tester`,
+	}
+
+	record, err := collection.StructToRecord(&named)
+	assert.NoError(err)
+	assert.Equal(`usr-42`, record.ID)
+	assert.Equal(testUserID(`usr-42`), named.ID)
+
+	named.ID = testUserID(`usr-7`)
+	record, err = collection.StructToRecord(&named)
+	assert.NoError(err)
+	assert.Equal(`usr-7`, record.ID)
+
+	// pointers
+	type PointerRecord struct {
+		ID   *string `pivot:"id,identity"`
+		Name string  `pivot:"name"`
+	}
+
+	pointer := PointerRecord{
+		Name: `tester`,
+	}
+
+	record, err = collection.StructToRecord(&pointer)
+	assert.NoError(err)
+	assert.Equal(`usr-42`, record.ID)
+	assert.NotNil(pointer.ID)
+	assert.Equal(`usr-42`, *pointer.ID)
+
+	// encoding.TextUnmarshaler
+	type TextRecord struct {
+		ID   testTextID `pivot:"id,identity"`
+		Name string     `pivot:"name"`
+	}
+
+	text := TextRecord{
+		Name: `tester`,
+	}
+
+	record, err = collection.StructToRecord(&text)
+	assert.NoError(err)
+	assert.Equal(`usr-42`, record.ID)
+	assert.Equal(testTextID{
+		Prefix: `usr`,
+		Number: 42,
+	}, text.ID)
+
+	text.ID = testTextID{
+		Prefix: `grp`,
+		Number: 3,
+	}
+
+	record, err = collection.StructToRecord(&text)
+	assert.NoError(err)
+	assert.Equal(`grp-3`, record.ID)
+}
+
 func TestCollectionStructToRecordRelated(t *testing.T) {
 	assert := require.New(t)
 
@@ -169,6 +271,35 @@ func TestCollectionValidator(t *testing.T) {
 	assert.NoError(collection.ValidateRecord(NewRecord(`three`), PersistOperation))
 }
 
+func TestCollectionFormatRecordSet(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestCollectionFormatRecordSet`)
+
+	// no formatter is a no-op
+	assert.NoError(collection.FormatRecordSet(NewRecordSet(NewRecord(1)), true))
+
+	collection.PreSaveRecordSetFormatter = func(recordset *RecordSet, isCreate bool) error {
+		if !isCreate {
+			return fmt.Errorf("updates not allowed")
+		}
+
+		for i, record := range recordset.Records {
+			record.Set(`position`, i+1)
+		}
+
+		return nil
+	}
+
+	recordset := NewRecordSet(NewRecord(`a`), NewRecord(`b`), NewRecord(`c`))
+
+	assert.NoError(collection.FormatRecordSet(recordset, true))
+	assert.Equal(1, recordset.Records[0].Get(`position`))
+	assert.Equal(2, recordset.Records[1].Get(`position`))
+	assert.Equal(3, recordset.Records[2].Get(`position`))
+	assert.Error(collection.FormatRecordSet(recordset, false))
+}
+
 func TestCollectionMapFromRecord(t *testing.T) {
 	assert := require.New(t)
 
@@ -299,3 +430,288 @@ func TestCollectionExtractValueFromRelationship(t *testing.T) {
 	assert.EqualValues(5432, keys)
 
 }
+
+func TestCollectionGetAllIndexes(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`users`, Field{
+		Name:    `email`,
+		Type:    StringType,
+		Indexed: true,
+	}, Field{
+		Name:    `last_name`,
+		Type:    StringType,
+		Indexed: true,
+	}, Field{
+		Name: `first_name`,
+		Type: StringType,
+	})
+
+	collection.Indexes = []SecondaryIndex{
+		{
+			Fields: []string{`last_name`, `first_name`},
+		}, {
+			Name:   `users_by_last_name`,
+			Fields: []string{`last_name`},
+		},
+	}
+
+	indexes := collection.GetAllIndexes()
+	assert.Len(indexes, 3)
+
+	assert.Equal(`users_last_name_first_name_idx`, indexes[0].GetName(collection.Name))
+	assert.Equal(`users_by_last_name`, indexes[1].GetName(collection.Name))
+	assert.Equal(`users_email_idx`, indexes[2].GetName(collection.Name))
+
+	for _, index := range indexes {
+		assert.NoError(index.Validate(collection))
+	}
+
+	assert.Error(SecondaryIndex{}.Validate(collection))
+	assert.Error(SecondaryIndex{
+		Fields: []string{`nope`},
+	}.Validate(collection))
+}
+
+func TestCollectionUnknownFieldPolicy(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestCollectionUnknownFieldPolicy`, Field{
+		Name: `name`,
+		Type: StringType,
+	})
+
+	assert.Equal(IgnoreUnknownFields, collection.GetUnknownFieldPolicy())
+	assert.Equal(PassthroughUnknownFields, NewCollection(`schemaless`).GetUnknownFieldPolicy())
+
+	input := func() *Record {
+		return NewRecord(1).Set(`name`, `tester`).Set(`extra`, true)
+	}
+
+	// ignore
+	record, err := collection.StructToRecord(input())
+	assert.NoError(err)
+	assert.Equal(`tester`, record.Get(`name`))
+	assert.Nil(record.Get(`extra`))
+	assert.NoError(collection.CheckUnknownFields(input()))
+
+	data, err := collection.MapFromRecord(input())
+	assert.NoError(err)
+	assert.NotContains(data, `extra`)
+
+	// reject
+	collection.UnknownFields = RejectUnknownFields
+
+	_, err = collection.StructToRecord(input())
+	assert.True(IsUnknownFieldErr(err))
+	assert.True(IsUnknownFieldErr(collection.CheckUnknownFields(input())))
+	assert.NoError(collection.CheckUnknownFields(NewRecord(1).Set(`name`, `tester`)))
+
+	type TestRecord struct {
+		Name  string `pivot:"name"`
+		Extra bool   `pivot:"extra"`
+	}
+
+	_, err = collection.StructToRecord(&TestRecord{
+		Name: `tester`,
+	})
+
+	assert.True(IsUnknownFieldErr(err))
+
+	// passthrough
+	collection.UnknownFields = PassthroughUnknownFields
+
+	record, err = collection.StructToRecord(input())
+	assert.NoError(err)
+	assert.Equal(`tester`, record.Get(`name`))
+	assert.Equal(true, record.Get(`extra`))
+
+	data, err = collection.MapFromRecord(input())
+	assert.NoError(err)
+	assert.Equal(true, data[`extra`])
+
+	collection.UnknownFields = `sometimes`
+	assert.Error(collection.Check())
+}
+
+func TestCollectionSearchFields(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`posts`, Field{
+		Name: `title`,
+		Type: StringType,
+	}, Field{
+		Name: `views`,
+		Type: IntType,
+	})
+
+	collection.SearchFields = []SearchField{
+		{
+			Name:  `title`,
+			Boost: 2,
+		}, {
+			Name: `views`,
+		},
+	}
+
+	assert.NoError(collection.Check())
+
+	title, ok := collection.GetSearchField(`title`)
+	assert.True(ok)
+	assert.Equal(float64(2), title.GetBoost())
+	assert.Equal(`title.search`, title.GetSearchPath(collection))
+
+	views, ok := collection.GetSearchField(`views`)
+	assert.True(ok)
+	assert.Equal(float64(1), views.GetBoost())
+	assert.Equal(`views`, views.GetSearchPath(collection))
+
+	_, ok = collection.GetSearchField(`body`)
+	assert.False(ok)
+
+	collection.SearchFields = append(collection.SearchFields, SearchField{
+		Name: `body`,
+	})
+
+	assert.Error(collection.Check())
+}
+
+func TestCollectionDeprecatedFields(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`users`, Field{
+		Name:       `fullname`,
+		Type:       StringType,
+		Deprecated: true,
+		ReplacedBy: `name`,
+	}, Field{
+		Name: `name`,
+		Type: StringType,
+	})
+
+	assert.NoError(collection.Check())
+
+	deprecated := collection.DeprecatedFields()
+	assert.Len(deprecated, 1)
+	assert.Equal(`fullname`, deprecated[0].Name)
+	assert.Equal(`name`, deprecated[0].ReplacedBy)
+
+	// writing to a deprecated field warns, but doesn't fail
+	assert.NoError(collection.FormatRecordSet(NewRecordSet(
+		NewRecord(1).Set(`fullname`, `Jane Doe`),
+	), true))
+
+	// replacements must exist
+	collection.Fields[0].ReplacedBy = `surname`
+	assert.Error(collection.Check())
+
+	// ...and can only be given for deprecated fields
+	collection.Fields[0].ReplacedBy = `name`
+	collection.Fields[0].Deprecated = false
+	assert.Error(collection.Check())
+}
+
+func TestCollectionStructToRecordExplicitNull(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestCollectionStructToRecordExplicitNull`, Field{
+		Name:         `nickname`,
+		Type:         StringType,
+		DefaultValue: `anonymous`,
+	}, Field{
+		Name:         `address`,
+		Type:         StringType,
+		DefaultValue: `unknown`,
+	}, Field{
+		Name:     `name`,
+		Type:     StringType,
+		Required: true,
+	})
+
+	type TestRecord struct {
+		ID       int     `pivot:"id,identity"`
+		Nickname *string `pivot:"nickname,omitempty"`
+		Address  *string `pivot:"address,omitempty,explicitnull"`
+	}
+
+	// nil fields are omitted (or defaulted) unless they are tagged as explicitly null
+	record, err := collection.StructToRecord(&TestRecord{
+		ID: 1,
+	})
+	assert.NoError(err)
+	assert.Equal(`anonymous`, record.Get(`nickname`))
+	assert.Equal(Null, record.Get(`address`))
+
+	var address = `123 Main St`
+
+	record, err = collection.StructToRecord(&TestRecord{
+		ID:      1,
+		Address: &address,
+	})
+	assert.NoError(err)
+	assert.Equal(address, record.Get(`address`))
+
+	// records can be given Null directly
+	record, err = collection.StructToRecord(NewRecord(1).Set(`nickname`, Null).Set(`address`, nil))
+	assert.NoError(err)
+	assert.Equal(Null, record.Get(`nickname`))
+	assert.Equal(`unknown`, record.Get(`address`))
+
+	// ...but not for required fields
+	_, err = collection.StructToRecord(NewRecord(1).Set(`name`, Null))
+	assert.Error(err)
+
+	assert.True(IsNull(Null))
+	assert.False(IsNull(nil))
+}
+
+func TestCollectionStructToRecordValidationErrors(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestCollectionStructToRecordValidationErrors`, Field{
+		Name:     `name`,
+		Type:     StringType,
+		Required: true,
+	}, Field{
+		Name: `email`,
+		Type: StringType,
+		Validator: func(value interface{}) error {
+			if !strings.Contains(fmt.Sprintf("%v", value), `@`) {
+				return fmt.Errorf("not an email address")
+			}
+
+			return nil
+		},
+	}, Field{
+		Name: `age`,
+		Type: IntType,
+		Formatter: func(value interface{}, op FieldOperation) (interface{}, error) {
+			return nil, fmt.Errorf("cannot format")
+		},
+	})
+
+	collection.UnknownFields = RejectUnknownFields
+
+	// every failing field is reported, not just the first
+	_, err := collection.StructToRecord(
+		NewRecord(1).Set(`name`, Null).Set(`email`, `nope`).Set(`age`, 42).Set(`extra`, true),
+	)
+
+	assert.True(IsValidationErr(err))
+	assert.True(IsUnknownFieldErr(err))
+
+	verrs := err.(*ValidationErrors)
+	assert.Equal(4, verrs.Len())
+	assert.Equal(RequiredCode, verrs.Fields[`name`][0].Code)
+	assert.Equal(InvalidCode, verrs.Fields[`email`][0].Code)
+	assert.Equal(`not an email address`, verrs.Fields[`email`][0].Message)
+	assert.Equal(FormatCode, verrs.Fields[`age`][0].Code)
+	assert.Equal(UnknownFieldCode, verrs.Fields[`extra`][0].Code)
+	assert.Contains(verrs.Error(), `email: not an email address`)
+
+	// valid records pass
+	collection.Fields[2].Formatter = nil
+
+	_, err = collection.StructToRecord(NewRecord(1).Set(`name`, `Bob`).Set(`email`, `bob@example.com`).Set(`age`, 42))
+	assert.NoError(err)
+}
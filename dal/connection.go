@@ -16,6 +16,8 @@ import (
 
 var schemeAliasMap = make(map[string]string)
 
+// Register an alternative name for a connection string scheme.  Connection strings using the alias
+// are handled (and validated) exactly as if they had used the scheme itself.
 func AddConnectionSchemeAlias(from string, to string) {
 	schemeAliasMap[from] = to
 }
@@ -210,10 +212,16 @@ func (self *ConnectionString) OptDuration(key string, fallback time.Duration) ti
 func ParseConnectionString(conn string) (ConnectionString, error) {
 	if uri, err := url.Parse(conn); err == nil {
 		if err := prepareURI(uri); err == nil {
-			return ConnectionString{
+			var cs = ConnectionString{
 				URI:     uri,
 				Options: optionsFromURI(uri),
-			}, nil
+			}
+
+			if err := applyConnectionScheme(&cs); err == nil {
+				return cs, nil
+			} else {
+				return ConnectionString{}, err
+			}
 		} else {
 			return ConnectionString{}, err
 		}
@@ -252,10 +260,16 @@ func MakeConnectionString(scheme string, host string, dataset string, options ma
 	}
 
 	if err := prepareURI(uri); err == nil {
-		return ConnectionString{
+		var cs = ConnectionString{
 			URI:     uri,
 			Options: optionsFromURI(uri),
-		}, nil
+		}
+
+		if err := applyConnectionScheme(&cs); err == nil {
+			return cs, nil
+		} else {
+			return ConnectionString{}, err
+		}
 	} else {
 		return ConnectionString{}, err
 	}
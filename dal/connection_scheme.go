@@ -0,0 +1,286 @@
+package dal
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+)
+
+var connectionSchemes = make(map[string]*ConnectionScheme)
+var connectionSchemesLock sync.RWMutex
+
+// Options that are accepted by connection strings of every registered scheme, in addition to the
+// options the scheme itself declares.
+var CommonConnectionOptions = []ConnectionOption{
+	{
+		Name:        `ping`,
+		Description: `how often to ping the backend to verify that it is reachable (e.g.: "30s")`,
+	},
+}
+
+// Describes an option that may be given in the query string of a connection string.
+type ConnectionOption struct {
+	// The name of the option as it appears in the query string.
+	Name string `json:"name"`
+
+	// If set, the option's value must be convertible to this type.
+	Type Type `json:"type,omitempty"`
+
+	// Whether the option must be given.
+	Required bool `json:"required,omitempty"`
+
+	// The value the option takes when it is not given.
+	Default interface{} `json:"default,omitempty"`
+
+	// A description of what the option does.
+	Description string `json:"description,omitempty"`
+
+	// An optional function that performs additional validation of the option's value.
+	Validate func(value interface{}) error `json:"-"`
+}
+
+// Describes a connection string scheme (e.g.: "mysql", "mongodb") and the options it accepts.
+// Connection strings for registered schemes have their options validated and normalized at parse
+// time, so that misspelled or malformed options are caught before a backend is ever created.
+type ConnectionScheme struct {
+	// The scheme being described.  Aliases registered with AddConnectionSchemeAlias will also
+	// resolve to this scheme.
+	Name string `json:"name"`
+
+	// The options accepted by connection strings using this scheme.
+	Options []ConnectionOption `json:"options,omitempty"`
+
+	// Whether options that are not declared in Options should be accepted.
+	AllowUnknownOptions bool `json:"allow_unknown_options,omitempty"`
+
+	// An optional function called after options are validated and defaults are applied, which may
+	// modify the connection string further.
+	Normalize func(connection *ConnectionString) error `json:"-"`
+}
+
+// Register a connection string scheme, replacing any existing registration for the same scheme.
+func RegisterConnectionScheme(scheme ConnectionScheme) error {
+	if scheme.Name == `` {
+		return fmt.Errorf("connection schemes must have a name")
+	}
+
+	var seen = make(map[string]bool)
+
+	for _, option := range scheme.Options {
+		if option.Name == `` {
+			return fmt.Errorf("scheme %q: options must have a name", scheme.Name)
+		} else if seen[option.Name] {
+			return fmt.Errorf("scheme %q: option %q is declared more than once", scheme.Name, option.Name)
+		} else if option.Type != `` && ParseFieldType(string(option.Type)) == `` {
+			return fmt.Errorf("scheme %q: option %q has unknown type %q", scheme.Name, option.Name, option.Type)
+		}
+
+		seen[option.Name] = true
+	}
+
+	connectionSchemesLock.Lock()
+	defer connectionSchemesLock.Unlock()
+
+	connectionSchemes[scheme.Name] = &scheme
+	return nil
+}
+
+// Retrieve the registered connection string scheme with the given name (or alias).
+func GetConnectionScheme(name string) (*ConnectionScheme, bool) {
+	if actual, ok := schemeAliasMap[name]; ok && actual != `` {
+		name = actual
+	}
+
+	connectionSchemesLock.RLock()
+	defer connectionSchemesLock.RUnlock()
+
+	scheme, ok := connectionSchemes[name]
+	return scheme, ok
+}
+
+// Retrieve the declaration of the named option, which may be one of the CommonConnectionOptions.
+func (self *ConnectionScheme) GetOption(name string) (ConnectionOption, bool) {
+	for _, options := range [][]ConnectionOption{self.Options, CommonConnectionOptions} {
+		for _, option := range options {
+			if option.Name == name {
+				return option, true
+			}
+		}
+	}
+
+	return ConnectionOption{}, false
+}
+
+// Validates the options of the given connection string against this scheme, applies defaults for
+// options that weren't given, then normalizes the connection string.  All problems with the
+// options are reported together.
+func (self *ConnectionScheme) Apply(connection *ConnectionString) error {
+	var merr error
+	var names = make([]string, 0, len(connection.Options))
+
+	if connection.Options == nil {
+		connection.Options = make(map[string]interface{})
+	}
+
+	for name := range connection.Options {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		var value = connection.Options[name]
+
+		if option, ok := self.GetOption(name); ok {
+			if err := option.check(value); err != nil {
+				merr = log.AppendError(merr, fmt.Errorf("option '%s': %v", name, err))
+			}
+		} else if !self.AllowUnknownOptions {
+			if suggestion := self.suggestOption(name); suggestion != `` {
+				merr = log.AppendError(merr, fmt.Errorf("unknown option '%s', did you mean '%s'?", name, suggestion))
+			} else {
+				merr = log.AppendError(merr, fmt.Errorf("unknown option '%s'", name))
+			}
+		}
+	}
+
+	for _, option := range self.Options {
+		if _, ok := connection.Options[option.Name]; ok {
+			continue
+		} else if option.Required {
+			merr = log.AppendError(merr, fmt.Errorf("missing required option '%s'", option.Name))
+		} else if option.Default != nil {
+			connection.Options[option.Name] = option.Default
+		}
+	}
+
+	if merr != nil {
+		return fmt.Errorf("%s: %v", self.Name, merr)
+	}
+
+	if self.Normalize != nil {
+		if err := self.Normalize(connection); err != nil {
+			return fmt.Errorf("%s: %v", self.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// returns the name of the declared option most similar to the given (unknown) one, provided that
+// it is close enough to plausibly be a typo.
+func (self *ConnectionScheme) suggestOption(name string) string {
+	var best string
+	var bestDistance = -1
+
+	for _, options := range [][]ConnectionOption{self.Options, CommonConnectionOptions} {
+		for _, option := range options {
+			if d := editDistance(name, option.Name); bestDistance < 0 || d < bestDistance {
+				best = option.Name
+				bestDistance = d
+			}
+		}
+	}
+
+	if bestDistance >= 0 && bestDistance <= 2 && bestDistance < len(name) {
+		return best
+	}
+
+	return ``
+}
+
+func (self ConnectionOption) check(value interface{}) error {
+	var values = []interface{}{value}
+
+	if typeutil.IsArray(value) {
+		if self.Type != `` && self.Type != ArrayType {
+			return fmt.Errorf("expected a single value, got %d", len(sliceutil.Sliceify(value)))
+		}
+
+		values = nil
+	}
+
+	for _, v := range values {
+		var ok = true
+
+		switch self.Type {
+		case IntType:
+			_, err := stringutil.ConvertToInteger(v)
+			ok = (err == nil)
+		case FloatType:
+			_, err := stringutil.ConvertToFloat(v)
+			ok = (err == nil)
+		case BooleanType:
+			ok = stringutil.IsBooleanTrue(v) || stringutil.IsBooleanFalse(v)
+		case TimeType:
+			_, err := stringutil.ConvertToTime(v)
+			ok = (err == nil)
+		}
+
+		if !ok {
+			return fmt.Errorf("expected %v value, got %q", self.Type, typeutil.String(v))
+		}
+	}
+
+	if self.Validate != nil {
+		return self.Validate(value)
+	}
+
+	return nil
+}
+
+// returns the Levenshtein distance between two strings
+func editDistance(a string, b string) int {
+	var ra = []rune(a)
+	var rb = []rune(b)
+	var previous = make([]int, len(rb)+1)
+	var current = make([]int, len(rb)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			var cost = 1
+
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			current[j] = current[j-1] + 1
+
+			if d := previous[j] + 1; d < current[j] {
+				current[j] = d
+			}
+
+			if d := previous[j-1] + cost; d < current[j] {
+				current[j] = d
+			}
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+// validates and normalizes the given connection string if its scheme has been registered
+func applyConnectionScheme(connection *ConnectionString) error {
+	if connection.URI == nil {
+		return nil
+	}
+
+	if scheme, ok := GetConnectionScheme(connection.Backend()); ok {
+		return scheme.Apply(connection)
+	}
+
+	return nil
+}
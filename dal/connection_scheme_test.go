@@ -0,0 +1,93 @@
+package dal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionScheme(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Error(RegisterConnectionScheme(ConnectionScheme{}))
+	assert.Error(RegisterConnectionScheme(ConnectionScheme{
+		Name: `testscheme`,
+		Options: []ConnectionOption{
+			{Name: `a`},
+			{Name: `a`},
+		},
+	}))
+
+	assert.NoError(RegisterConnectionScheme(ConnectionScheme{
+		Name: `testscheme`,
+		Options: []ConnectionOption{
+			{
+				Name:    `autocount`,
+				Type:    BooleanType,
+				Default: true,
+			}, {
+				Name: `timeout`,
+				Type: IntType,
+			}, {
+				Name:     `region`,
+				Required: true,
+				Validate: func(value interface{}) error {
+					if value != `east` && value != `west` {
+						return fmt.Errorf("must be east or west")
+					}
+
+					return nil
+				},
+			},
+		},
+		Normalize: func(connection *ConnectionString) error {
+			connection.Options[`normalized`] = true
+			return nil
+		},
+	}))
+
+	AddConnectionSchemeAlias(`ts`, `testscheme`)
+
+	// defaults are applied and the normalizer is called
+	cs, err := ParseConnectionString(`testscheme://localhost/db?region=east&timeout=5&ping=30s`)
+	assert.NoError(err)
+	assert.Equal(true, cs.OptBool(`autocount`, false))
+	assert.EqualValues(5, cs.OptInt(`timeout`, 0))
+	assert.True(cs.OptBool(`normalized`, false))
+
+	// aliases are validated too
+	_, err = ParseConnectionString(`ts://localhost/db?region=west&autocont=false`)
+	assert.Error(err)
+	assert.Contains(err.Error(), `unknown option 'autocont', did you mean 'autocount'?`)
+
+	_, err = ParseConnectionString(`testscheme://localhost/db?region=west&xyz=1`)
+	assert.Error(err)
+	assert.Contains(err.Error(), `unknown option 'xyz'`)
+	assert.NotContains(err.Error(), `did you mean`)
+
+	_, err = ParseConnectionString(`testscheme://localhost/db`)
+	assert.Error(err)
+	assert.Contains(err.Error(), `missing required option 'region'`)
+
+	_, err = ParseConnectionString(`testscheme://localhost/db?region=north`)
+	assert.Error(err)
+	assert.Contains(err.Error(), `option 'region': must be east or west`)
+
+	_, err = ParseConnectionString(`testscheme://localhost/db?region=east&timeout=soon`)
+	assert.Error(err)
+	assert.Contains(err.Error(), `option 'timeout': expected int value, got "soon"`)
+
+	_, err = ParseConnectionString(`testscheme://localhost/db?region=east&timeout=1&timeout=2`)
+	assert.Error(err)
+	assert.Contains(err.Error(), `option 'timeout': expected a single value, got 2`)
+
+	_, err = MakeConnectionString(`testscheme`, `localhost`, `db`, map[string]interface{}{
+		`regoin`: `east`,
+	})
+	assert.Error(err)
+
+	// unregistered schemes accept any options
+	_, err = ParseConnectionString(`otherscheme://localhost/db?anything=goes`)
+	assert.NoError(err)
+}
@@ -0,0 +1,105 @@
+package dal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+)
+
+// Generates a default value for the given field.  The argument is the text following the
+// expression's name and a colon (e.g.: "FOO" in "env:FOO"), or an empty string if there was none.
+type DefaultExpressionFunc func(field *Field, arg string) (interface{}, error)
+
+// The prefix used to give a literal default value that would otherwise be taken as an expression
+// (e.g.: "literal:now" is the string "now").
+var DefaultLiteralPrefix = `literal:`
+
+// Returns the next value of the named sequence.  By default, sequences are kept in memory and so
+// start over whenever the process does; replace this to use a source that persists them.
+var NextSequenceValue = func(name string) (int64, error) {
+	sequenceLock.Lock()
+	defer sequenceLock.Unlock()
+
+	sequences[name] += 1
+	return sequences[name], nil
+}
+
+var sequences = make(map[string]int64)
+var sequenceLock sync.Mutex
+
+var defaultExpressions = map[string]DefaultExpressionFunc{
+	// the current time
+	`now`: func(field *Field, _ string) (interface{}, error) {
+		return time.Now(), nil
+	},
+
+	// a random V4 UUID
+	`uuid`: func(field *Field, _ string) (interface{}, error) {
+		return stringutil.UUID().String(), nil
+	},
+
+	// the next value of a sequence, which is named after the field unless a name is given
+	// (e.g.: "sequence:invoices")
+	`sequence`: func(field *Field, name string) (interface{}, error) {
+		if name == `` {
+			name = field.Name
+		}
+
+		return NextSequenceValue(name)
+	},
+
+	// the value of an environment variable (e.g.: "env:REGION")
+	`env`: func(field *Field, name string) (interface{}, error) {
+		if name == `` {
+			return nil, fmt.Errorf("env: must specify an environment variable name")
+		}
+
+		return os.Getenv(name), nil
+	},
+}
+
+// Registers a named expression that can be given as a field's default value (e.g.: in schema
+// files).  Expressions are written as either "name" or "name:argument".
+func RegisterDefaultExpression(name string, fn DefaultExpressionFunc) {
+	if fn != nil {
+		defaultExpressions[name] = fn
+	} else {
+		delete(defaultExpressions, name)
+	}
+}
+
+// splits a default value into the name and argument of a registered expression, if it is one
+func parseDefaultExpression(value interface{}) (DefaultExpressionFunc, string, bool) {
+	if expr, ok := value.(string); ok {
+		name, arg := stringutil.SplitPair(expr, `:`)
+
+		if fn, ok := defaultExpressions[name]; ok {
+			return fn, arg, true
+		}
+	}
+
+	return nil, ``, false
+}
+
+// Returns whether the given default value is an expression that is evaluated each time a default
+// value is needed, rather than a literal value.
+func IsDefaultExpression(value interface{}) bool {
+	_, _, ok := parseDefaultExpression(value)
+	return ok
+}
+
+// evaluates the field's default value if it is an expression, and unwraps it if it is an escaped
+// literal
+func (self *Field) evaluateDefaultValue() (interface{}, error) {
+	if fn, arg, ok := parseDefaultExpression(self.DefaultValue); ok {
+		return fn(self, arg)
+	} else if expr, ok := self.DefaultValue.(string); ok && strings.HasPrefix(expr, DefaultLiteralPrefix) {
+		return strings.TrimPrefix(expr, DefaultLiteralPrefix), nil
+	}
+
+	return self.DefaultValue, nil
+}
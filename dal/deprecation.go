@@ -0,0 +1,64 @@
+package dal
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+)
+
+// The least amount of time between warnings about records being written to the same deprecated
+// field.  Zero logs a warning for every write.
+var DeprecatedFieldWarningInterval = time.Minute
+
+var deprecationWarnings sync.Map
+
+// Returns the fields in this collection that have been marked as deprecated.
+func (self *Collection) DeprecatedFields() []Field {
+	var fields = make([]Field, 0)
+
+	for _, field := range self.Fields {
+		if field.Deprecated {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// logs a warning for each deprecated field the given records write to, at most once per
+// DeprecatedFieldWarningInterval for each field
+func (self *Collection) warnDeprecatedFields(recordset *RecordSet) {
+	if recordset == nil {
+		return
+	}
+
+	for _, field := range self.DeprecatedFields() {
+		var writes int
+
+		for _, record := range recordset.Records {
+			if _, ok := record.Fields[field.Name]; ok {
+				writes += 1
+			}
+		}
+
+		if writes == 0 {
+			continue
+		}
+
+		var key = self.Name + `.` + field.Name
+		var now = time.Now()
+
+		if last, ok := deprecationWarnings.Load(key); ok && now.Sub(last.(time.Time)) < DeprecatedFieldWarningInterval {
+			continue
+		}
+
+		deprecationWarnings.Store(key, now)
+
+		if field.ReplacedBy != `` {
+			log.Warningf("collection[%s] field[%s]: %d record(s) written to deprecated field (use %q instead)", self.Name, field.Name, writes, field.ReplacedBy)
+		} else {
+			log.Warningf("collection[%s] field[%s]: %d record(s) written to deprecated field", self.Name, field.Name, writes)
+		}
+	}
+}
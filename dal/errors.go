@@ -2,6 +2,8 @@ package dal
 
 import (
 	"errors"
+	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -31,3 +33,142 @@ func IsExistError(err error) bool {
 func IsFieldNotFoundErr(err error) bool {
 	return (err == FieldNotFound)
 }
+
+// Returned when a record being written contains a field that is not defined in a collection whose
+// UnknownFieldPolicy is RejectUnknownFields.
+type UnknownFieldError struct {
+	Collection string
+	Field      string
+}
+
+func (self UnknownFieldError) Error() string {
+	return fmt.Sprintf("collection %q does not define field %q", self.Collection, self.Field)
+}
+
+// Returns whether the given error is (or, for ValidationErrors, includes) an UnknownFieldError.
+func IsUnknownFieldErr(err error) bool {
+	if verrs, ok := err.(*ValidationErrors); ok {
+		return verrs.HasCode(UnknownFieldCode)
+	}
+
+	_, ok := err.(UnknownFieldError)
+	return ok
+}
+
+// Returned when creating a collection that already exists.
+type CollectionExistsError struct {
+	Collection string
+}
+
+func (self CollectionExistsError) Error() string {
+	return fmt.Sprintf("collection %q already exists", self.Collection)
+}
+
+func IsCollectionExistsErr(err error) bool {
+	_, ok := err.(CollectionExistsError)
+	return ok
+}
+
+// Identifies the reason a field failed validation.
+type ValidationErrorCode string
+
+const (
+	RequiredCode     ValidationErrorCode = `required`      // a required field was set to null
+	InvalidTypeCode  ValidationErrorCode = `invalid_type`  // the value could not be converted to the field's type
+	FormatCode       ValidationErrorCode = `format`        // the field's formatter failed
+	InvalidCode      ValidationErrorCode = `invalid`       // the field's validator rejected the value
+	SchemaCode       ValidationErrorCode = `schema`        // the value does not conform to the field's JSON Schema
+	UnknownFieldCode ValidationErrorCode = `unknown_field` // the collection does not define the field
+)
+
+// Describes why a single field failed validation.
+type FieldError struct {
+	Code       ValidationErrorCode `json:"code"`
+	Message    string              `json:"message"`
+	Violations []SchemaViolation   `json:"violations,omitempty"`
+}
+
+// Returned when a record fails validation.  Rather than stopping at the first problem, every
+// field is checked and all of the errors for each are collected, so that clients can report them
+// all at once.
+type ValidationErrors struct {
+	Collection string                  `json:"collection,omitempty"`
+	Fields     map[string][]FieldError `json:"fields"`
+}
+
+func NewValidationErrors(collection string) *ValidationErrors {
+	return &ValidationErrors{
+		Collection: collection,
+		Fields:     make(map[string][]FieldError),
+	}
+}
+
+// Records that the named field failed validation for the given reason.
+func (self *ValidationErrors) Add(field string, code ValidationErrorCode, err error) {
+	if err == nil {
+		return
+	}
+
+	var fieldError = FieldError{
+		Code:    code,
+		Message: err.Error(),
+	}
+
+	if verr, ok := err.(*SchemaValidationError); ok {
+		fieldError.Code = SchemaCode
+		fieldError.Violations = verr.Violations
+	}
+
+	self.Fields[field] = append(self.Fields[field], fieldError)
+}
+
+// Returns whether any field failed validation for the given reason.
+func (self *ValidationErrors) HasCode(code ValidationErrorCode) bool {
+	for _, errs := range self.Fields {
+		for _, fieldError := range errs {
+			if fieldError.Code == code {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Returns the number of fields that failed validation.
+func (self *ValidationErrors) Len() int {
+	return len(self.Fields)
+}
+
+// Returns nil if no fields failed validation, or the ValidationErrors itself otherwise.
+func (self *ValidationErrors) ErrorOrNil() error {
+	if self.Len() == 0 {
+		return nil
+	}
+
+	return self
+}
+
+func (self *ValidationErrors) Error() string {
+	var names = make([]string, 0, len(self.Fields))
+	var messages = make([]string, 0)
+
+	for name := range self.Fields {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, fieldError := range self.Fields[name] {
+			messages = append(messages, fmt.Sprintf("%s: %s", name, fieldError.Message))
+		}
+	}
+
+	return fmt.Sprintf("collection %q: %d invalid field(s): %s", self.Collection, len(names), strings.Join(messages, `; `))
+}
+
+func IsValidationErr(err error) bool {
+	_, ok := err.(*ValidationErrors)
+	return ok
+}
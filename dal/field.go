@@ -55,7 +55,9 @@ type Field struct {
 	UniqueGroup string `json:"unique_group,omitempty"`
 
 	// The default value of the field is one is not explicitly specified.  Can be any type or a
-	// function that takes zero arguments and returns a single value.
+	// function that takes zero arguments and returns a single value.  Strings naming a default
+	// value expression ("now", "uuid", "sequence[:name]", "env:NAME", or any registered with
+	// RegisterDefaultExpression) are evaluated each time a default value is needed.
 	DefaultValue interface{} `json:"default,omitempty"`
 
 	// Represents the native datatype of the underlying Backend object (read only)
@@ -104,6 +106,28 @@ type Field struct {
 
 	// Specifies that the field may not be updated, only read.  Attempts to update the field will be silently discarded.
 	ReadOnly bool `json:"readonly,omitempty"`
+
+	// Whether a (non-unique) secondary index should be created on this field, for backends that
+	// support them.  See Collection.Indexes for declaring indexes that span multiple fields.
+	Indexed bool `json:"indexed,omitempty"`
+
+	// A JSON Schema document describing the structure of values stored in ObjectType (and ArrayType)
+	// fields.  Values are validated against the schema on create and update; the schema is also
+	// exposed via the API so that clients can generate forms for nested data.
+	Schema map[string]interface{} `json:"schema,omitempty"`
+
+	// The names this field previously had.  When migrating a collection whose schema no longer has
+	// a field by this name, a field with one of these names is renamed instead of a new field being
+	// added.
+	RenamedFrom []string `json:"renamed_from,omitempty"`
+
+	// Marks the field as being phased out.  Records that write to a deprecated field cause a
+	// warning to be logged (see DeprecatedFieldWarningInterval), and the field is flagged as such
+	// in the API's schema responses.
+	Deprecated bool `json:"deprecated,omitempty"`
+
+	// The name of the field that should be used instead of this (deprecated) one.
+	ReplacedBy string `json:"replaced_by,omitempty"`
 }
 
 func (self *Field) normalizeType(in interface{}) (interface{}, error) {
@@ -197,6 +221,14 @@ func (self *Field) normalizeType(in interface{}) (interface{}, error) {
 		} else {
 			in = variant.Time()
 		}
+	case GeopointType:
+		if in == nil {
+			return nil, nil
+		} else if point, err := ParseGeopoint(in); err == nil {
+			return point, nil
+		} else {
+			return nil, err
+		}
 	default:
 		switch strings.ToLower(fmt.Sprintf("%v", in)) {
 		case `null`, `nil`:
@@ -208,6 +240,15 @@ func (self *Field) normalizeType(in interface{}) (interface{}, error) {
 }
 
 func (self *Field) ConvertValue(in interface{}) (interface{}, error) {
+	// explicit nulls are passed through as-is so that they aren't replaced by the default value
+	if IsNull(in) {
+		if self.Required {
+			return nil, fmt.Errorf("field %q is required and cannot be set to null", self.Name)
+		}
+
+		return Null, nil
+	}
+
 	if norm, err := self.normalizeType(in); err == nil {
 		in = norm
 	} else {
@@ -241,11 +282,14 @@ func (self *Field) GetDefaultValue() interface{} {
 		}
 	}
 
-	if norm, err := self.normalizeType(self.DefaultValue); err == nil {
-		return norm
-	} else {
-		return nil
+	// default value expressions (e.g.: "now", "uuid") are evaluated each time
+	if value, err := self.evaluateDefaultValue(); err == nil {
+		if norm, err := self.normalizeType(value); err == nil {
+			return norm
+		}
 	}
+
+	return nil
 }
 
 func (self *Field) GetTypeInstance() interface{} {
@@ -264,6 +308,8 @@ func (self *Field) GetTypeInstance() interface{} {
 		return make(map[string]interface{})
 	case ArrayType:
 		return make([]interface{}, 0)
+	case GeopointType:
+		return Geopoint{}
 	default:
 		return make([]byte, 0)
 	}
@@ -271,8 +317,10 @@ func (self *Field) GetTypeInstance() interface{} {
 
 func (self *Field) Validate(value interface{}) error {
 	// automatically validate that required fields aren't being given a nil value
-	if self.Required && value == nil {
+	if self.Required && (value == nil || IsNull(value)) {
 		return fmt.Errorf("field %q is required", self.Name)
+	} else if IsNull(value) {
+		return nil
 	}
 
 	if self.Validator == nil {
@@ -284,6 +332,23 @@ func (self *Field) Validate(value interface{}) error {
 	}
 }
 
+// Validates the given value against the field's JSON Schema (if one is specified).  Violations
+// are returned as a *SchemaValidationError.
+func (self *Field) ValidateSchema(value interface{}) error {
+	if len(self.Schema) == 0 || value == nil || IsNull(value) {
+		return nil
+	}
+
+	if violations := ValidateJSONSchema(self.Schema, value); len(violations) > 0 {
+		return &SchemaValidationError{
+			Field:      self.Name,
+			Violations: violations,
+		}
+	}
+
+	return nil
+}
+
 func (self *Field) Format(value interface{}, op FieldOperation) (interface{}, error) {
 	if self.Formatter == nil {
 		return value, nil
@@ -315,13 +380,20 @@ func (self *Field) Diff(other *Field) []*SchemaDelta {
 			//		this is largely for the use of the client application and won't always have a backend-persistent counterpart
 			//  DefaultValue:
 			//		this is a value that is interpreted by the backend and may not be retrievable after definition
+			//  Schema:
+			//		this is enforced by Pivot when values are written, not stored by the backend
 			//
-			case `NativeType`, `Description`, `Validator`, `Formatter`, `FormatterConfig`, `ValidatorConfig`, `Key`, `ReadOnly`:
+			case `NativeType`, `Description`, `Validator`, `Formatter`, `FormatterConfig`, `ValidatorConfig`, `Key`, `ReadOnly`, `RenamedFrom`, `Schema`:
 				continue
 			case `DefaultValue`:
 				myDefault := myField.Value()
 				theirDefault := theirField.Value()
 
+				// expressions other than "now" are evaluated by Pivot, not stored by the backend
+				if IsDefaultExpression(myDefault) && myDefault != `now` {
+					continue
+				}
+
 				if typeutil.IsScalar(myDefault) {
 					if typeutil.IsScalar(theirDefault) {
 						if myDefault != theirDefault {
@@ -2,6 +2,7 @@ package dal
 
 import (
 	"fmt"
+	"os"
 	"testing"
 	"time"
 
@@ -543,3 +544,71 @@ func TestFieldConvertValueArray(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal([]interface{}{int64(9), int64(8), int64(7)}, value)
 }
+
+func TestFieldDefaultValueExpressions(t *testing.T) {
+	assert := require.New(t)
+
+	created := Field{
+		Name:         `created_at`,
+		Type:         TimeType,
+		DefaultValue: `now`,
+	}
+
+	assert.WithinDuration(time.Now(), created.GetDefaultValue().(time.Time), time.Second)
+
+	token := Field{
+		Name:         `token`,
+		Type:         StringType,
+		DefaultValue: `uuid`,
+	}
+
+	assert.Len(token.GetDefaultValue(), 36)
+	assert.NotEqual(token.GetDefaultValue(), token.GetDefaultValue())
+
+	number := Field{
+		Name:         `number`,
+		Type:         IntType,
+		DefaultValue: `sequence:TestFieldDefaultValueExpressions`,
+	}
+
+	assert.Equal(int64(1), number.GetDefaultValue())
+	assert.Equal(int64(2), number.GetDefaultValue())
+
+	os.Setenv(`PIVOT_TEST_REGION`, `eu-west-1`)
+	defer os.Unsetenv(`PIVOT_TEST_REGION`)
+
+	region := Field{
+		Name:         `region`,
+		Type:         StringType,
+		DefaultValue: `env:PIVOT_TEST_REGION`,
+	}
+
+	assert.Equal(`eu-west-1`, region.GetDefaultValue())
+
+	// escaped and unrecognized expressions are literal values
+	word := Field{
+		Name:         `word`,
+		Type:         StringType,
+		DefaultValue: `literal:now`,
+	}
+
+	assert.Equal(`now`, word.GetDefaultValue())
+
+	word.DefaultValue = `other:thing`
+	assert.Equal(`other:thing`, word.GetDefaultValue())
+
+	// expressions are only evaluated for records that need them
+	collection := NewCollection(`TestFieldDefaultValueExpressions`, number)
+
+	record, err := collection.StructToRecord(NewRecord(1).Set(`number`, 42))
+	assert.NoError(err)
+	assert.EqualValues(42, record.Get(`number`))
+
+	record, err = collection.StructToRecord(NewRecord(2))
+	assert.NoError(err)
+	assert.Equal(int64(3), record.Get(`number`))
+
+	// invalid expressions are caught when the schema is checked
+	collection.Fields[0].DefaultValue = `env:`
+	assert.Error(collection.Check())
+}
@@ -0,0 +1,188 @@
+package dal
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+)
+
+// The mean radius of the Earth, in meters, used when calculating distances between points.
+var EarthRadiusMeters = 6371008.8
+
+// The spatial reference system that geopoints are stored in by backends that support one (WGS 84).
+const GeopointSRID = 4326
+
+// A location on the surface of the Earth, expressed as a latitude and longitude in degrees.
+type Geopoint struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+}
+
+// Parses the given value as a Geopoint.  Accepted values are Geopoints, maps with "lat" and "lon"
+// (or "latitude" and "longitude") keys, GeoJSON Point objects, "lat,lon" strings, JSON-encoded
+// strings of any of these, and hex-encoded (E)WKB points (as returned by PostGIS).
+func ParseGeopoint(in interface{}) (Geopoint, error) {
+	var point Geopoint
+
+	switch v := in.(type) {
+	case Geopoint:
+		point = v
+	case *Geopoint:
+		if v == nil {
+			return point, fmt.Errorf("Cannot parse nil as a geopoint")
+		}
+
+		point = *v
+	case []byte:
+		return ParseGeopoint(string(v))
+	case string:
+		var s = strings.TrimSpace(v)
+
+		if strings.HasPrefix(s, `{`) {
+			var obj map[string]interface{}
+
+			if err := json.Unmarshal([]byte(s), &obj); err == nil {
+				return ParseGeopoint(obj)
+			} else {
+				return point, fmt.Errorf("Invalid geopoint %q: %v", s, err)
+			}
+		} else if parts := strings.Split(s, `,`); len(parts) == 2 {
+			if lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64); err == nil {
+				if lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err == nil {
+					point = Geopoint{
+						Latitude:  lat,
+						Longitude: lon,
+					}
+				} else {
+					return point, fmt.Errorf("Invalid geopoint longitude %q", parts[1])
+				}
+			} else {
+				return point, fmt.Errorf("Invalid geopoint latitude %q", parts[0])
+			}
+		} else if wkb, err := hex.DecodeString(s); err == nil && len(wkb) > 0 {
+			return parseWKBPoint(wkb)
+		} else {
+			return point, fmt.Errorf("Invalid geopoint %q", s)
+		}
+	default:
+		if !typeutil.IsMap(in) {
+			return point, fmt.Errorf("Cannot use %T as a geopoint", in)
+		}
+
+		var obj = typeutil.V(in).MapNative()
+
+		if typeutil.String(obj[`type`]) == `Point` {
+			// GeoJSON coordinates are ordered longitude first
+			if values := sliceutil.Sliceify(obj[`coordinates`]); len(values) == 2 {
+				point = Geopoint{
+					Latitude:  typeutil.Float(values[1]),
+					Longitude: typeutil.Float(values[0]),
+				}
+			} else {
+				return point, fmt.Errorf("Invalid GeoJSON point: expected two coordinates")
+			}
+		} else if lat, ok := obj[`lat`]; ok {
+			point = Geopoint{
+				Latitude:  typeutil.Float(lat),
+				Longitude: typeutil.Float(obj[`lon`]),
+			}
+		} else if lat, ok := obj[`latitude`]; ok {
+			point = Geopoint{
+				Latitude:  typeutil.Float(lat),
+				Longitude: typeutil.Float(obj[`longitude`]),
+			}
+		} else {
+			return point, fmt.Errorf("Invalid geopoint: expected lat and lon keys or a GeoJSON point")
+		}
+	}
+
+	return point, point.Validate()
+}
+
+// parse a point encoded as Well-Known Binary, optionally with an embedded SRID (EWKB)
+func parseWKBPoint(wkb []byte) (Geopoint, error) {
+	var point Geopoint
+	var order binary.ByteOrder = binary.BigEndian
+
+	if len(wkb) < 21 {
+		return point, fmt.Errorf("Invalid WKB point: too short")
+	} else if wkb[0] == 1 {
+		order = binary.LittleEndian
+	}
+
+	var geomType = order.Uint32(wkb[1:5])
+	var offset = 5
+
+	// the EWKB flag indicating that an SRID follows the geometry type
+	if geomType&0x20000000 != 0 {
+		offset += 4
+	}
+
+	if geomType&0xffff != 1 {
+		return point, fmt.Errorf("Invalid WKB point: geometry type %d is not a point", geomType&0xffff)
+	} else if len(wkb) < offset+16 {
+		return point, fmt.Errorf("Invalid WKB point: too short")
+	}
+
+	point.Longitude = math.Float64frombits(order.Uint64(wkb[offset : offset+8]))
+	point.Latitude = math.Float64frombits(order.Uint64(wkb[offset+8 : offset+16]))
+
+	return point, point.Validate()
+}
+
+// Returns an error if the point's latitude or longitude are out of range.
+func (self Geopoint) Validate() error {
+	if math.IsNaN(self.Latitude) || self.Latitude < -90 || self.Latitude > 90 {
+		return fmt.Errorf("Invalid geopoint: latitude %v is not between -90 and 90", self.Latitude)
+	} else if math.IsNaN(self.Longitude) || self.Longitude < -180 || self.Longitude > 180 {
+		return fmt.Errorf("Invalid geopoint: longitude %v is not between -180 and 180", self.Longitude)
+	}
+
+	return nil
+}
+
+// Returns the great-circle distance between this point and another, in meters.
+func (self Geopoint) Distance(other Geopoint) float64 {
+	var lat1 = self.Latitude * math.Pi / 180
+	var lat2 = other.Latitude * math.Pi / 180
+	var dLat = lat2 - lat1
+	var dLon = (other.Longitude - self.Longitude) * math.Pi / 180
+
+	var a = math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+
+	return 2 * EarthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Returns the point as Extended Well-Known Text (e.g.: "SRID=4326;POINT(-74.0 40.7)").
+func (self Geopoint) EWKT() string {
+	return fmt.Sprintf(
+		"SRID=%d;POINT(%s %s)",
+		GeopointSRID,
+		strconv.FormatFloat(self.Longitude, 'f', -1, 64),
+		strconv.FormatFloat(self.Latitude, 'f', -1, 64),
+	)
+}
+
+// Returns the point as a GeoJSON Point object.
+func (self Geopoint) GeoJSON() map[string]interface{} {
+	return map[string]interface{}{
+		`type`:        `Point`,
+		`coordinates`: []float64{self.Longitude, self.Latitude},
+	}
+}
+
+// Geopoints are stored in MongoDB as GeoJSON so that they can be queried using 2dsphere indexes.
+func (self Geopoint) GetBSON() (interface{}, error) {
+	return self.GeoJSON(), nil
+}
+
+func (self Geopoint) String() string {
+	return strconv.FormatFloat(self.Latitude, 'f', -1, 64) + `,` + strconv.FormatFloat(self.Longitude, 'f', -1, 64)
+}
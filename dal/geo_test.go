@@ -0,0 +1,98 @@
+package dal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGeopoint(t *testing.T) {
+	assert := require.New(t)
+
+	var nyc = Geopoint{
+		Latitude:  40.7128,
+		Longitude: -74.006,
+	}
+
+	for _, input := range []interface{}{
+		nyc,
+		&nyc,
+		`40.7128,-74.006`,
+		` 40.7128 , -74.006 `,
+		`{"lat": 40.7128, "lon": -74.006}`,
+		[]byte(`{"latitude": 40.7128, "longitude": -74.006}`),
+		map[string]interface{}{
+			`lat`: 40.7128,
+			`lon`: `-74.006`,
+		},
+		map[string]interface{}{
+			`type`:        `Point`,
+			`coordinates`: []interface{}{-74.006, 40.7128},
+		},
+		`0101000020E6100000AAF1D24D628052C05E4BC8073D5B4440`,
+	} {
+		point, err := ParseGeopoint(input)
+		assert.NoError(err, "input: %v", input)
+		assert.Equal(nyc, point, "input: %v", input)
+	}
+
+	for _, input := range []interface{}{
+		nil,
+		``,
+		`40.7128`,
+		`91,0`,
+		`0,181`,
+		`north,west`,
+		map[string]interface{}{
+			`x`: 1,
+		},
+		map[string]interface{}{
+			`type`:        `Point`,
+			`coordinates`: []interface{}{1},
+		},
+		true,
+	} {
+		_, err := ParseGeopoint(input)
+		assert.Error(err, "input: %v", input)
+	}
+}
+
+func TestGeopointDistance(t *testing.T) {
+	assert := require.New(t)
+
+	var nyc = Geopoint{40.7128, -74.006}
+	var london = Geopoint{51.5074, -0.1278}
+
+	assert.Zero(nyc.Distance(nyc))
+	assert.InDelta(5570230, nyc.Distance(london), 1)
+	assert.InDelta(nyc.Distance(london), london.Distance(nyc), 0.001)
+
+	assert.Equal(`SRID=4326;POINT(-74.006 40.7128)`, nyc.EWKT())
+	assert.Equal(`40.7128,-74.006`, nyc.String())
+	assert.Equal(map[string]interface{}{
+		`type`:        `Point`,
+		`coordinates`: []float64{-74.006, 40.7128},
+	}, nyc.GeoJSON())
+}
+
+func TestGeopointField(t *testing.T) {
+	assert := require.New(t)
+
+	var field = Field{
+		Name: `location`,
+		Type: ParseFieldType(`geopoint`),
+	}
+
+	assert.EqualValues(GeopointType, field.Type)
+
+	v, err := field.ConvertValue(`40.7128,-74.006`)
+	assert.NoError(err)
+	assert.Equal(Geopoint{40.7128, -74.006}, v)
+
+	v, err = field.ConvertValue(nil)
+	assert.NoError(err)
+	assert.Nil(v)
+
+	_, err = field.ConvertValue(`somewhere`)
+	assert.Error(err)
+}
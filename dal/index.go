@@ -0,0 +1,41 @@
+package dal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Describes a non-unique secondary index on one or more fields of a Collection.
+type SecondaryIndex struct {
+	// The name of the index.  If not specified, a name is derived from the collection and field names.
+	Name string `json:"name,omitempty"`
+
+	// The fields covered by the index, in order.
+	Fields []string `json:"fields"`
+}
+
+// Returns the name of the index, deriving one (e.g.: "users_last_name_first_name_idx") if it
+// was not explicitly specified.
+func (self SecondaryIndex) GetName(collection string) string {
+	if self.Name != `` {
+		return self.Name
+	}
+
+	return strings.Join(append(append([]string{collection}, self.Fields...), `idx`), `_`)
+}
+
+// Verify that the index covers at least one field, and that all of the fields it covers exist in
+// the given collection.
+func (self SecondaryIndex) Validate(collection *Collection) error {
+	if len(self.Fields) == 0 {
+		return fmt.Errorf("index %q must specify at least one field", self.GetName(collection.Name))
+	}
+
+	for _, name := range self.Fields {
+		if _, ok := collection.GetField(name); !ok {
+			return fmt.Errorf("index %q: no such field %q", self.GetName(collection.Name), name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,316 @@
+package dal
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+)
+
+// Describes a single location in a value that failed validation against a JSON Schema.
+type SchemaViolation struct {
+	Path    string `json:"path"`
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+}
+
+func (self SchemaViolation) String() string {
+	return fmt.Sprintf("%s: %s", self.Path, self.Message)
+}
+
+// Returned when a value does not conform to a field's JSON Schema.  All violations are collected
+// (rather than stopping at the first) so that clients can report every problem at once.
+type SchemaValidationError struct {
+	Field      string            `json:"field"`
+	Violations []SchemaViolation `json:"violations"`
+}
+
+func (self *SchemaValidationError) Error() string {
+	messages := make([]string, len(self.Violations))
+
+	for i, violation := range self.Violations {
+		messages[i] = violation.String()
+	}
+
+	return fmt.Sprintf("field %q does not match schema: %s", self.Field, strings.Join(messages, `; `))
+}
+
+func IsSchemaValidationErr(err error) bool {
+	_, ok := err.(*SchemaValidationError)
+	return ok
+}
+
+// Validates the given value against a JSON Schema document.  A practical subset of the
+// specification is supported: type, enum, const, properties, required, additionalProperties,
+// items, minItems, maxItems, uniqueItems, minLength, maxLength, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, allOf, anyOf, and oneOf.
+func ValidateJSONSchema(schema map[string]interface{}, value interface{}) []SchemaViolation {
+	violations := make([]SchemaViolation, 0)
+	validateSchemaNode(schema, value, `$`, &violations)
+	return violations
+}
+
+// Checks that the given JSON Schema document only uses supported constructs and is well-formed.
+func CheckJSONSchema(schema map[string]interface{}) error {
+	if t, ok := schema[`type`]; ok {
+		for _, name := range sliceutil.Stringify(t) {
+			switch name {
+			case `object`, `array`, `string`, `number`, `integer`, `boolean`, `null`:
+				continue
+			default:
+				return fmt.Errorf("unsupported schema type %q", name)
+			}
+		}
+	}
+
+	if pattern, ok := schema[`pattern`]; ok {
+		if _, err := regexp.Compile(typeutil.String(pattern)); err != nil {
+			return fmt.Errorf("invalid pattern: %v", err)
+		}
+	}
+
+	for _, key := range []string{`properties`} {
+		if props, ok := schema[key]; ok {
+			for name, sub := range maputil.M(props).MapNative() {
+				if subschema, ok := sub.(map[string]interface{}); ok {
+					if err := CheckJSONSchema(subschema); err != nil {
+						return fmt.Errorf("%s.%s: %v", key, name, err)
+					}
+				} else {
+					return fmt.Errorf("%s.%s: must be an object", key, name)
+				}
+			}
+		}
+	}
+
+	if items, ok := schema[`items`].(map[string]interface{}); ok {
+		if err := CheckJSONSchema(items); err != nil {
+			return fmt.Errorf("items: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func validateSchemaNode(schema map[string]interface{}, value interface{}, path string, violations *[]SchemaViolation) {
+	fail := func(keyword string, format string, args ...interface{}) {
+		*violations = append(*violations, SchemaViolation{
+			Path:    path,
+			Keyword: keyword,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if types, ok := schema[`type`]; ok {
+		allowed := sliceutil.Stringify(types)
+		actual := jsonSchemaTypeOf(value)
+
+		if !sliceutil.ContainsString(allowed, actual) && !(actual == `integer` && sliceutil.ContainsString(allowed, `number`)) {
+			fail(`type`, "expected %s, got %s", strings.Join(allowed, ` or `), actual)
+			return
+		}
+	}
+
+	if enum, ok := schema[`enum`]; ok {
+		var found bool
+
+		for _, candidate := range sliceutil.Sliceify(enum) {
+			if jsonSchemaEqual(candidate, value) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			fail(`enum`, "value %v is not one of %v", value, enum)
+		}
+	}
+
+	if constant, ok := schema[`const`]; ok && !jsonSchemaEqual(constant, value) {
+		fail(`const`, "value must be %v", constant)
+	}
+
+	switch jsonSchemaTypeOf(value) {
+	case `object`:
+		obj := maputil.M(value).MapNative()
+
+		for _, name := range sliceutil.Stringify(schema[`required`]) {
+			if _, ok := obj[name]; !ok {
+				fail(`required`, "missing required property %q", name)
+			}
+		}
+
+		properties := maputil.M(schema[`properties`]).MapNative()
+		names := maputil.StringKeys(obj)
+		sort.Strings(names)
+
+		for _, name := range names {
+			if sub, ok := properties[name].(map[string]interface{}); ok {
+				validateSchemaNode(sub, obj[name], path+`.`+name, violations)
+			} else if additional, ok := schema[`additionalProperties`]; ok {
+				if allowed, ok := additional.(bool); ok && !allowed {
+					fail(`additionalProperties`, "property %q is not allowed", name)
+				} else if sub, ok := additional.(map[string]interface{}); ok {
+					validateSchemaNode(sub, obj[name], path+`.`+name, violations)
+				}
+			}
+		}
+
+	case `array`:
+		arr := sliceutil.Sliceify(value)
+
+		if min, ok := schema[`minItems`]; ok && int64(len(arr)) < typeutil.Int(min) {
+			fail(`minItems`, "must contain at least %v items", min)
+		}
+
+		if max, ok := schema[`maxItems`]; ok && int64(len(arr)) > typeutil.Int(max) {
+			fail(`maxItems`, "must contain at most %v items", max)
+		}
+
+		if typeutil.Bool(schema[`uniqueItems`]) {
+			for i := 0; i < len(arr); i++ {
+				for j := i + 1; j < len(arr); j++ {
+					if jsonSchemaEqual(arr[i], arr[j]) {
+						fail(`uniqueItems`, "items %d and %d are identical", i, j)
+					}
+				}
+			}
+		}
+
+		if items, ok := schema[`items`].(map[string]interface{}); ok {
+			for i, item := range arr {
+				validateSchemaNode(items, item, fmt.Sprintf("%s[%d]", path, i), violations)
+			}
+		}
+
+	case `string`:
+		str := typeutil.String(value)
+		length := int64(len([]rune(str)))
+
+		if min, ok := schema[`minLength`]; ok && length < typeutil.Int(min) {
+			fail(`minLength`, "must be at least %v characters long", min)
+		}
+
+		if max, ok := schema[`maxLength`]; ok && length > typeutil.Int(max) {
+			fail(`maxLength`, "must be at most %v characters long", max)
+		}
+
+		if pattern, ok := schema[`pattern`]; ok {
+			if rx, err := regexp.Compile(typeutil.String(pattern)); err == nil {
+				if !rx.MatchString(str) {
+					fail(`pattern`, "must match pattern %v", pattern)
+				}
+			} else {
+				fail(`pattern`, "invalid pattern: %v", err)
+			}
+		}
+
+	case `number`, `integer`:
+		num := typeutil.Float(value)
+
+		if min, ok := schema[`minimum`]; ok && num < typeutil.Float(min) {
+			fail(`minimum`, "must be greater than or equal to %v", min)
+		}
+
+		if max, ok := schema[`maximum`]; ok && num > typeutil.Float(max) {
+			fail(`maximum`, "must be less than or equal to %v", max)
+		}
+
+		if min, ok := schema[`exclusiveMinimum`]; ok && num <= typeutil.Float(min) {
+			fail(`exclusiveMinimum`, "must be greater than %v", min)
+		}
+
+		if max, ok := schema[`exclusiveMaximum`]; ok && num >= typeutil.Float(max) {
+			fail(`exclusiveMaximum`, "must be less than %v", max)
+		}
+	}
+
+	if all, ok := schema[`allOf`]; ok {
+		for _, sub := range sliceutil.Sliceify(all) {
+			if subschema, ok := sub.(map[string]interface{}); ok {
+				validateSchemaNode(subschema, value, path, violations)
+			}
+		}
+	}
+
+	if any, ok := schema[`anyOf`]; ok {
+		if jsonSchemaMatchCount(sliceutil.Sliceify(any), value, path) == 0 {
+			fail(`anyOf`, "must match at least one of the given schemas")
+		}
+	}
+
+	if one, ok := schema[`oneOf`]; ok {
+		if n := jsonSchemaMatchCount(sliceutil.Sliceify(one), value, path); n != 1 {
+			fail(`oneOf`, "must match exactly one of the given schemas (matched %d)", n)
+		}
+	}
+}
+
+func jsonSchemaMatchCount(schemas []interface{}, value interface{}, path string) int {
+	var matches int
+
+	for _, sub := range schemas {
+		if subschema, ok := sub.(map[string]interface{}); ok {
+			subviolations := make([]SchemaViolation, 0)
+			validateSchemaNode(subschema, value, path, &subviolations)
+
+			if len(subviolations) == 0 {
+				matches += 1
+			}
+		}
+	}
+
+	return matches
+}
+
+func jsonSchemaTypeOf(value interface{}) string {
+	if value == nil {
+		return `null`
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Map, reflect.Struct:
+		return `object`
+	case reflect.Slice, reflect.Array:
+		return `array`
+	case reflect.String:
+		return `string`
+	case reflect.Bool:
+		return `boolean`
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return `integer`
+	case reflect.Float32, reflect.Float64:
+		if f := typeutil.Float(value); f == float64(int64(f)) {
+			return `integer`
+		}
+
+		return `number`
+	default:
+		return `object`
+	}
+}
+
+// values decoded from JSON and values constructed in Go may differ in numeric type, so compare
+// numbers by value
+func jsonSchemaEqual(a interface{}, b interface{}) bool {
+	if jsonSchemaIsNumber(a) && jsonSchemaIsNumber(b) {
+		return typeutil.Float(a) == typeutil.Float(b)
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func jsonSchemaIsNumber(value interface{}) bool {
+	switch jsonSchemaTypeOf(value) {
+	case `integer`, `number`:
+		return true
+	default:
+		return false
+	}
+}
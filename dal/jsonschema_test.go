@@ -0,0 +1,101 @@
+package dal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateJSONSchema(t *testing.T) {
+	assert := require.New(t)
+
+	var schema map[string]interface{}
+
+	assert.NoError(json.Unmarshal([]byte(`{
+		"type": "object",
+		"required": ["name", "port"],
+		"additionalProperties": false,
+		"properties": {
+			"name":    {"type": "string", "minLength": 1},
+			"port":    {"type": "integer", "minimum": 1, "maximum": 65535},
+			"mode":    {"enum": ["active", "passive"]},
+			"tags":    {"type": "array", "items": {"type": "string"}, "uniqueItems": true}
+		}
+	}`), &schema))
+
+	assert.NoError(CheckJSONSchema(schema))
+
+	assert.Empty(ValidateJSONSchema(schema, map[string]interface{}{
+		`name`: `web`,
+		`port`: 8080,
+		`mode`: `active`,
+		`tags`: []interface{}{`a`, `b`},
+	}))
+
+	violations := ValidateJSONSchema(schema, map[string]interface{}{
+		`name`:  ``,
+		`port`:  float64(70000),
+		`mode`:  `other`,
+		`tags`:  []interface{}{`a`, 2, `a`},
+		`extra`: true,
+	})
+
+	paths := make(map[string]string)
+
+	for _, violation := range violations {
+		paths[violation.Path] = violation.Keyword
+	}
+
+	assert.Equal(map[string]string{
+		`$`:         `additionalProperties`,
+		`$.name`:    `minLength`,
+		`$.port`:    `maximum`,
+		`$.mode`:    `enum`,
+		`$.tags`:    `uniqueItems`,
+		`$.tags[1]`: `type`,
+	}, paths)
+
+	violations = ValidateJSONSchema(schema, map[string]interface{}{})
+	assert.Len(violations, 2)
+	assert.Equal(`required`, violations[0].Keyword)
+
+	assert.Error(CheckJSONSchema(map[string]interface{}{`type`: `thing`}))
+}
+
+func TestFieldSchemaValidation(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`configs`, Field{
+		Name: `settings`,
+		Type: ObjectType,
+		Schema: map[string]interface{}{
+			`type`:     `object`,
+			`required`: []interface{}{`enabled`},
+			`properties`: map[string]interface{}{
+				`enabled`: map[string]interface{}{`type`: `boolean`},
+			},
+		},
+	})
+
+	assert.NoError(collection.Check())
+
+	_, err := collection.ValueForField(`settings`, map[string]interface{}{`enabled`: true}, PersistOperation)
+	assert.NoError(err)
+
+	_, err = collection.ValueForField(`settings`, map[string]interface{}{`enabled`: `yes`}, PersistOperation)
+	assert.True(IsSchemaValidationErr(err))
+	assert.Equal(`settings`, err.(*SchemaValidationError).Field)
+	assert.Equal(`$.enabled`, err.(*SchemaValidationError).Violations[0].Path)
+
+	// schemas aren't stored by backends, so they don't count as differences from what they report
+	actual := NewCollection(`configs`, Field{
+		Name: `settings`,
+		Type: ObjectType,
+	})
+
+	assert.Empty(collection.Diff(actual))
+
+	collection.Fields[0].Type = StringType
+	assert.Error(collection.Check())
+}
@@ -0,0 +1,113 @@
+package dal
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+)
+
+// The API path that collection and record links are generated under.
+var LinkPathPrefix = `/api/collections`
+
+// The separator used to join the values of composite keys in record paths.
+var LinkKeySeparator = `:`
+
+// A hypermedia link to another API resource.
+type Link struct {
+	Href       string `json:"href"`
+	Collection string `json:"collection,omitempty"`
+}
+
+// Returns the API path for the given collection.
+func CollectionPath(collection string) string {
+	return LinkPathPrefix + `/` + url.PathEscape(collection)
+}
+
+// Returns the API path for retrieving the record with the given ID from a collection.  Composite
+// IDs are joined with LinkKeySeparator.
+func RecordPath(collection string, id interface{}) string {
+	ids := sliceutil.Stringify(id)
+
+	for i, v := range ids {
+		ids[i] = url.PathEscape(v)
+	}
+
+	return CollectionPath(collection) + `/records/` + strings.Join(ids, LinkKeySeparator)
+}
+
+// Returns the links for a record: "self", "collection", and one for each single-field constraint
+// the record has a value for (named after the constraint's Into field, or its local field).
+// Constraints on the remote collection's identity field link directly to the related record; all
+// others link to a query for matching records.
+func RecordLinks(collection *Collection, record *Record) map[string]Link {
+	links := make(map[string]Link)
+
+	if collection == nil || record == nil {
+		return links
+	}
+
+	links[`collection`] = Link{
+		Href:       CollectionPath(collection.Name),
+		Collection: collection.Name,
+	}
+
+	if !typeutil.IsZero(record.ID) {
+		links[`self`] = Link{
+			Href:       RecordPath(collection.Name, record.ID),
+			Collection: collection.Name,
+		}
+	}
+
+	for _, constraint := range collection.GetAllConstraints() {
+		localFields := sliceutil.Stringify(constraint.On)
+		remoteFields := sliceutil.Stringify(constraint.Field)
+
+		if len(localFields) != 1 || len(remoteFields) != 1 {
+			continue
+		}
+
+		value := record.Get(localFields[0])
+
+		// expanded (embedded) records carry the related key inside of them
+		if typeutil.IsMap(value) {
+			value = maputil.M(value).Get(remoteFields[0]).Value
+		}
+
+		if typeutil.IsZero(value) || typeutil.IsArray(value) {
+			continue
+		}
+
+		name := constraint.Into
+
+		if name == `` {
+			name = localFields[0]
+		}
+
+		if _, ok := links[name]; ok {
+			continue
+		}
+
+		link := Link{
+			Collection: constraint.Collection,
+		}
+
+		if remoteFields[0] == DefaultIdentityField {
+			link.Href = RecordPath(constraint.Collection, value)
+		} else {
+			link.Href = fmt.Sprintf(
+				"%s/where/%s/%s",
+				CollectionPath(constraint.Collection),
+				url.PathEscape(remoteFields[0]),
+				url.PathEscape(typeutil.String(value)),
+			)
+		}
+
+		links[name] = link
+	}
+
+	return links
+}
@@ -0,0 +1,47 @@
+package dal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordLinks(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`orders`, Field{
+		Name:      `user_id`,
+		Type:      IntType,
+		BelongsTo: `users`,
+	}, Field{
+		Name: `sku`,
+		Type: StringType,
+	})
+
+	collection.Constraints = append(collection.Constraints, Constraint{
+		On:         `sku`,
+		Collection: `products`,
+		Field:      `code`,
+		Into:       `product`,
+	})
+
+	links := RecordLinks(collection, NewRecord(42).Set(`user_id`, 7).Set(`sku`, `AB 12`))
+
+	assert.Equal(map[string]Link{
+		`self`:       {Href: `/api/collections/orders/records/42`, Collection: `orders`},
+		`collection`: {Href: `/api/collections/orders`, Collection: `orders`},
+		`user_id`:    {Href: `/api/collections/users/records/7`, Collection: `users`},
+		`product`:    {Href: `/api/collections/products/where/code/AB%2012`, Collection: `products`},
+	}, links)
+
+	// expanded related records still link to the record they were expanded from
+	links = RecordLinks(collection, NewRecord(42).Set(`user_id`, map[string]interface{}{
+		`id`:   7,
+		`name`: `someone`,
+	}))
+
+	assert.Equal(`/api/collections/users/records/7`, links[`user_id`].Href)
+	assert.NotContains(links, `product`)
+
+	assert.Equal(`/api/collections/orders/records/1:a`, RecordPath(`orders`, []interface{}{1, `a`}))
+}
@@ -0,0 +1,32 @@
+package dal
+
+// The type of the Null sentinel value.
+type NullValue struct{}
+
+// A value that explicitly sets a field to NULL.  Unlike nil (which is treated as the field being
+// absent, and so is replaced with the field's DefaultValue if it has one), fields set to Null are
+// written as NULL by backends, including in updates.
+var Null = NullValue{}
+
+// Returns whether the given value is the Null sentinel.
+func IsNull(value interface{}) bool {
+	switch value.(type) {
+	case NullValue, *NullValue:
+		return true
+	default:
+		return false
+	}
+}
+
+func (self NullValue) String() string {
+	return `null`
+}
+
+func (self NullValue) MarshalJSON() ([]byte, error) {
+	return []byte(`null`), nil
+}
+
+// Null is stored in MongoDB as a BSON null.
+func (self NullValue) GetBSON() (interface{}, error) {
+	return nil, nil
+}
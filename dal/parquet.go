@@ -0,0 +1,470 @@
+package dal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+)
+
+// The maximum number of records buffered in memory before being written out as a Parquet row group.
+var ParquetRowGroupSize = 10000
+
+var parquetMagic = []byte(`PAR1`)
+
+// Parquet physical types
+const (
+	parquetBoolean   int32 = 0
+	parquetInt64     int32 = 2
+	parquetDouble    int32 = 5
+	parquetByteArray int32 = 6
+)
+
+// Parquet converted (logical) types
+const (
+	parquetNoConvertedType int32 = -1
+	parquetUTF8            int32 = 0
+	parquetTimestampMillis int32 = 9
+)
+
+// Parquet encodings
+const (
+	parquetPlainEncoding int32 = 0
+	parquetRLEEncoding   int32 = 3
+)
+
+type parquetColumn struct {
+	name          string
+	physicalType  int32
+	convertedType int32
+}
+
+type parquetColumnChunk struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+type parquetRowGroup struct {
+	columns []parquetColumnChunk
+	size    int64
+	numRows int64
+}
+
+// Writes records as an (uncompressed) Apache Parquet file.  Every column is optional, and is typed
+// according to the collection's field definitions where they are known; values of other fields
+// are written as UTF-8 strings.  Records are buffered into row groups of ParquetRowGroupSize rows,
+// and the file footer is written when the writer is closed.
+type parquetRecordWriter struct {
+	output    io.Writer
+	columns   *recordColumns
+	schema    []parquetColumn
+	rows      [][]interface{}
+	rowGroups []parquetRowGroup
+	offset    int64
+	numRows   int64
+}
+
+func newParquetRecordWriter(w io.Writer, columns *recordColumns) *parquetRecordWriter {
+	return &parquetRecordWriter{
+		output:  w,
+		columns: columns,
+	}
+}
+
+func (self *parquetRecordWriter) Write(record *Record) error {
+	if self.schema == nil {
+		for _, name := range self.columns.resolve(record) {
+			self.schema = append(self.schema, parquetColumnFor(name, self.columns.typeOf(name)))
+		}
+	}
+
+	var row = make([]interface{}, len(self.schema))
+
+	for i, column := range self.schema {
+		row[i] = column.convert(self.columns.value(record, column.name))
+	}
+
+	self.rows = append(self.rows, row)
+
+	if len(self.rows) >= ParquetRowGroupSize {
+		return self.flushRowGroup()
+	}
+
+	return nil
+}
+
+func (self *parquetRecordWriter) Close() error {
+	if self.schema == nil {
+		for _, name := range self.columns.names {
+			self.schema = append(self.schema, parquetColumnFor(name, self.columns.typeOf(name)))
+		}
+	}
+
+	if err := self.flushRowGroup(); err != nil {
+		return err
+	}
+
+	if self.offset == 0 {
+		if err := self.write(parquetMagic); err != nil {
+			return err
+		}
+	}
+
+	var footer = self.fileMetadata()
+	var length = make([]byte, 4)
+
+	binary.LittleEndian.PutUint32(length, uint32(len(footer)))
+
+	for _, data := range [][]byte{footer, length, parquetMagic} {
+		if err := self.write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *parquetRecordWriter) write(data []byte) error {
+	n, err := self.output.Write(data)
+	self.offset += int64(n)
+	return err
+}
+
+// writes the buffered rows out as a row group containing a single data page per column
+func (self *parquetRecordWriter) flushRowGroup() error {
+	if len(self.rows) == 0 {
+		return nil
+	}
+
+	if self.offset == 0 {
+		if err := self.write(parquetMagic); err != nil {
+			return err
+		}
+	}
+
+	var group = parquetRowGroup{
+		numRows: int64(len(self.rows)),
+	}
+
+	for i, column := range self.schema {
+		var page = column.encodePage(self.rows, i)
+		var header = parquetPageHeader(len(self.rows), len(page))
+		var chunk = parquetColumnChunk{
+			offset:    self.offset,
+			size:      int64(len(header) + len(page)),
+			numValues: int64(len(self.rows)),
+		}
+
+		if err := self.write(header); err != nil {
+			return err
+		} else if err := self.write(page); err != nil {
+			return err
+		}
+
+		group.columns = append(group.columns, chunk)
+		group.size += chunk.size
+	}
+
+	self.rowGroups = append(self.rowGroups, group)
+	self.numRows += group.numRows
+	self.rows = nil
+
+	return nil
+}
+
+func (self *parquetRecordWriter) fileMetadata() []byte {
+	var t = newThriftCompactWriter()
+
+	t.i32(1, 1) // version
+
+	// schema: a root element followed by one element per column
+	t.list(2, thriftStruct, len(self.schema)+1)
+	t.beginStruct()
+	t.binary(4, []byte(`schema`))
+	t.i32(5, int32(len(self.schema)))
+	t.endStruct()
+
+	for _, column := range self.schema {
+		t.beginStruct()
+		t.i32(1, column.physicalType)
+		t.i32(3, 1) // OPTIONAL
+		t.binary(4, []byte(column.name))
+
+		if column.convertedType != parquetNoConvertedType {
+			t.i32(6, column.convertedType)
+		}
+
+		t.endStruct()
+	}
+
+	t.i64(3, self.numRows)
+
+	t.list(4, thriftStruct, len(self.rowGroups))
+
+	for _, group := range self.rowGroups {
+		t.beginStruct()
+		t.list(1, thriftStruct, len(group.columns))
+
+		for i, chunk := range group.columns {
+			var column = self.schema[i]
+
+			t.beginStruct()
+			t.i64(2, chunk.offset)
+			t.beginFieldStruct(3)
+			t.i32(1, column.physicalType)
+			t.list(2, thriftI32, 2)
+			t.listI32(parquetPlainEncoding)
+			t.listI32(parquetRLEEncoding)
+			t.list(3, thriftBinary, 1)
+			t.listBinary([]byte(column.name))
+			t.i32(4, 0) // UNCOMPRESSED
+			t.i64(5, chunk.numValues)
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.endStruct()
+			t.endStruct()
+		}
+
+		t.i64(2, group.size)
+		t.i64(3, group.numRows)
+		t.endStruct()
+	}
+
+	t.binary(6, []byte(`pivot`)) // created_by
+	t.endStruct()
+
+	return t.Bytes()
+}
+
+func parquetColumnFor(name string, fieldType Type) parquetColumn {
+	var column = parquetColumn{
+		name:          name,
+		physicalType:  parquetByteArray,
+		convertedType: parquetUTF8,
+	}
+
+	switch fieldType {
+	case BooleanType:
+		column.physicalType = parquetBoolean
+		column.convertedType = parquetNoConvertedType
+	case IntType:
+		column.physicalType = parquetInt64
+		column.convertedType = parquetNoConvertedType
+	case FloatType:
+		column.physicalType = parquetDouble
+		column.convertedType = parquetNoConvertedType
+	case TimeType:
+		column.physicalType = parquetInt64
+		column.convertedType = parquetTimestampMillis
+	}
+
+	return column
+}
+
+// converts a value to the Go type used to encode it, or nil if the value is null
+func (self parquetColumn) convert(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	switch self.physicalType {
+	case parquetBoolean:
+		return typeutil.Bool(value)
+	case parquetDouble:
+		return typeutil.Float(value)
+	case parquetInt64:
+		if self.convertedType == parquetTimestampMillis {
+			var t, ok = value.(time.Time)
+
+			if !ok {
+				t = typeutil.V(value).Time()
+			}
+
+			if t.IsZero() {
+				return nil
+			}
+
+			return t.UnixNano() / int64(time.Millisecond)
+		}
+
+		return typeutil.Int(value)
+	default:
+		return []byte(columnValueString(value))
+	}
+}
+
+// encodes the definition levels and values of the given column as a PLAIN-encoded data page
+func (self parquetColumn) encodePage(rows [][]interface{}, index int) []byte {
+	var page bytes.Buffer
+	var levels = make([]bool, len(rows))
+	var values = make([]interface{}, 0, len(rows))
+
+	for i, row := range rows {
+		if row[index] != nil {
+			levels[i] = true
+			values = append(values, row[index])
+		}
+	}
+
+	// definition levels are RLE/bit-packed hybrid encoded (as a single bit-packed run), prefixed with
+	// their length
+	var encodedLevels = appendUvarint(nil, uint64(((len(levels)+7)/8)<<1|1))
+	encodedLevels = append(encodedLevels, packBits(levels)...)
+
+	binary.Write(&page, binary.LittleEndian, uint32(len(encodedLevels)))
+	page.Write(encodedLevels)
+
+	switch self.physicalType {
+	case parquetBoolean:
+		var bits = make([]bool, len(values))
+
+		for i, value := range values {
+			bits[i] = value.(bool)
+		}
+
+		page.Write(packBits(bits))
+	case parquetInt64:
+		for _, value := range values {
+			binary.Write(&page, binary.LittleEndian, value.(int64))
+		}
+	case parquetDouble:
+		for _, value := range values {
+			binary.Write(&page, binary.LittleEndian, math.Float64bits(value.(float64)))
+		}
+	default:
+		for _, value := range values {
+			var data = value.([]byte)
+
+			binary.Write(&page, binary.LittleEndian, uint32(len(data)))
+			page.Write(data)
+		}
+	}
+
+	return page.Bytes()
+}
+
+func parquetPageHeader(numValues int, size int) []byte {
+	var t = newThriftCompactWriter()
+
+	t.i32(1, 0) // DATA_PAGE
+	t.i32(2, int32(size))
+	t.i32(3, int32(size))
+	t.beginFieldStruct(5)
+	t.i32(1, int32(numValues))
+	t.i32(2, parquetPlainEncoding)
+	t.i32(3, parquetRLEEncoding)
+	t.i32(4, parquetRLEEncoding)
+	t.endStruct()
+	t.endStruct()
+
+	return t.Bytes()
+}
+
+// packs booleans into bytes, least significant bit first
+func packBits(bits []bool) []byte {
+	var packed = make([]byte, (len(bits)+7)/8)
+
+	for i, bit := range bits {
+		if bit {
+			packed[i/8] |= 1 << uint(i%8)
+		}
+	}
+
+	return packed
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp = make([]byte, binary.MaxVarintLen64)
+	return append(buf, tmp[:binary.PutUvarint(tmp, v)]...)
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    byte = 5
+	thriftI64    byte = 6
+	thriftBinary byte = 8
+	thriftList   byte = 9
+	thriftStruct byte = 12
+)
+
+// a minimal writer for the Thrift compact protocol, which Parquet uses to encode its metadata
+type thriftCompactWriter struct {
+	bytes.Buffer
+	lastField []int16
+}
+
+// starts writing a top-level struct
+func newThriftCompactWriter() *thriftCompactWriter {
+	return &thriftCompactWriter{
+		lastField: []int16{0},
+	}
+}
+
+func (self *thriftCompactWriter) fieldHeader(id int16, fieldType byte) {
+	var last = &self.lastField[len(self.lastField)-1]
+
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		self.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		self.WriteByte(fieldType)
+		self.Write(appendUvarint(nil, uint64(uint16((id<<1)^(id>>15)))))
+	}
+
+	*last = id
+}
+
+func (self *thriftCompactWriter) i32(id int16, v int32) {
+	self.fieldHeader(id, thriftI32)
+	self.listI32(v)
+}
+
+func (self *thriftCompactWriter) i64(id int16, v int64) {
+	self.fieldHeader(id, thriftI64)
+	self.Write(appendUvarint(nil, uint64((v<<1)^(v>>63))))
+}
+
+func (self *thriftCompactWriter) binary(id int16, v []byte) {
+	self.fieldHeader(id, thriftBinary)
+	self.listBinary(v)
+}
+
+func (self *thriftCompactWriter) list(id int16, elementType byte, size int) {
+	self.fieldHeader(id, thriftList)
+
+	if size < 15 {
+		self.WriteByte(byte(size)<<4 | elementType)
+	} else {
+		self.WriteByte(0xf0 | elementType)
+		self.Write(appendUvarint(nil, uint64(size)))
+	}
+}
+
+func (self *thriftCompactWriter) listI32(v int32) {
+	self.Write(appendUvarint(nil, uint64(uint32((v<<1)^(v>>31)))))
+}
+
+func (self *thriftCompactWriter) listBinary(v []byte) {
+	self.Write(appendUvarint(nil, uint64(len(v))))
+	self.Write(v)
+}
+
+// starts a struct that is an element of a list
+func (self *thriftCompactWriter) beginStruct() {
+	self.lastField = append(self.lastField, 0)
+}
+
+// starts a struct that is the value of a field
+func (self *thriftCompactWriter) beginFieldStruct(id int16) {
+	self.fieldHeader(id, thriftStruct)
+	self.beginStruct()
+}
+
+func (self *thriftCompactWriter) endStruct() {
+	self.WriteByte(0)
+	self.lastField = self.lastField[:len(self.lastField)-1]
+}
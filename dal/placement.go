@@ -0,0 +1,92 @@
+package dal
+
+import (
+	"fmt"
+)
+
+type PartitionScheme string
+
+const (
+	// Rows are assigned to partitions by the (integer) value of a single field, modulo the number
+	// of partitions.
+	HashPartitioning PartitionScheme = `hash`
+
+	// Rows are assigned to partitions by a hash of the values of one or more fields, which may be
+	// of any type.
+	KeyPartitioning PartitionScheme = `key`
+)
+
+// Describes how a Collection's data is split up and placed across the nodes of a distributed
+// database.  Backends that don't support placement (which is most of them) ignore it.
+type Placement struct {
+	// The name of a placement policy (which must already exist in the database) governing which
+	// regions, zones, or nodes the collection's data is stored on.
+	Policy string `json:"policy,omitempty"`
+
+	// How rows are assigned to partitions.  If not set, the collection is not partitioned.
+	Partitioning PartitionScheme `json:"partitioning,omitempty"`
+
+	// The fields that rows are partitioned by.  Every one of them must be a key field, since
+	// partitioned tables can only enforce uniqueness within a partition.  Defaults to the identity
+	// field.
+	PartitionFields []string `json:"partition_fields,omitempty"`
+
+	// The number of partitions the collection is split into.
+	Partitions int `json:"partitions,omitempty"`
+}
+
+// Returns the fields that rows are partitioned by.
+func (self *Placement) GetPartitionFields(collection *Collection) []string {
+	if len(self.PartitionFields) > 0 {
+		return self.PartitionFields
+	}
+
+	return []string{collection.GetIdentityFieldName()}
+}
+
+// Verify that the partitioning scheme is supported and that the partition fields are key fields
+// of the given collection.
+func (self *Placement) Validate(collection *Collection) error {
+	switch self.Partitioning {
+	case ``:
+		if len(self.PartitionFields) > 0 || self.Partitions > 0 {
+			return fmt.Errorf("placement: partitions can only be given along with a partitioning scheme")
+		}
+
+		return nil
+	case HashPartitioning, KeyPartitioning:
+		break
+	default:
+		return fmt.Errorf("placement: unknown partitioning scheme %q", self.Partitioning)
+	}
+
+	if self.Partitions < 1 {
+		return fmt.Errorf("placement: %s partitioning requires at least one partition", self.Partitioning)
+	}
+
+	var fields = self.GetPartitionFields(collection)
+
+	if self.Partitioning == HashPartitioning && len(fields) != 1 {
+		return fmt.Errorf("placement: hash partitioning requires exactly one partition field")
+	}
+
+	for _, name := range fields {
+		if field, ok := collection.GetField(name); !ok {
+			return fmt.Errorf("placement: no such partition field %q", name)
+		} else if !field.Identity && !field.Key {
+			return fmt.Errorf("placement: partition field %q must be a key field", name)
+		} else if self.Partitioning == HashPartitioning {
+			var fieldType = field.Type
+
+			if field.Identity {
+				fieldType = identityFieldType(collection)
+			}
+
+			if fieldType != IntType {
+				return fmt.Errorf("placement: hash partition field %q must be an integer", name)
+			}
+		}
+	}
+
+	return nil
+}
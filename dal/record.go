@@ -23,6 +23,8 @@ type Record struct {
 	CollectionName string                 `json:"collection,omitempty"`
 	Operation      string                 `json:"operation,omitempty"`
 	Optional       bool                   `json:"optional,omitempty"` // Specifies that the record is "optional", which is namely used in fixtures to indicate that a missing collection should not be considered fatal.
+	Links          map[string]Link        `json:"_links,omitempty"`
+	Score          float64                `json:"_score,omitempty"` // the relevance of the record to the full-text query that returned it, if any
 }
 
 func NewRecord(id interface{}, data ...map[string]interface{}) *Record {
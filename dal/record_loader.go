@@ -1,6 +1,7 @@
 package dal
 
 import (
+	"encoding"
 	"fmt"
 	"reflect"
 	"strings"
@@ -20,6 +21,7 @@ type fieldDescription struct {
 	RecordKey    string
 	Identity     bool
 	OmitEmpty    bool
+	ExplicitNull bool
 	FieldValue   reflect.Value
 	FieldType    reflect.Type
 	DataValue    interface{}
@@ -37,6 +39,136 @@ func (self *fieldDescription) Set(value interface{}) error {
 	}
 }
 
+// Writes an identity value (e.g.: one generated when the record was persisted) back into the struct
+// field it came from.  In addition to fields whose type the value can be assigned to directly, this
+// supports named types (e.g.: "type UserID string"), pointer fields, and types that implement
+// encoding.TextUnmarshaler.
+func (self *fieldDescription) SetIdentity(value interface{}) error {
+	if !self.FieldValue.IsValid() {
+		return fmt.Errorf("cannot set field %q: no value available", self.OriginalName)
+	} else if !self.FieldValue.CanSet() {
+		return fmt.Errorf("cannot set field %q: field is unsettable", self.OriginalName)
+	} else if value == nil {
+		return nil
+	}
+
+	var target = self.FieldValue
+
+	if target.Kind() == reflect.Ptr {
+		if reflect.TypeOf(value).AssignableTo(target.Type()) {
+			target.Set(reflect.ValueOf(value))
+			return nil
+		}
+
+		// only replace the pointer once the value it points to has been successfully set
+		var elem = reflect.New(target.Type().Elem())
+
+		if err := setIdentityValue(elem.Elem(), value); err == nil {
+			target.Set(elem)
+			return nil
+		} else {
+			return fmt.Errorf("cannot set field %q: %v", self.OriginalName, err)
+		}
+	}
+
+	if err := setIdentityValue(target, value); err == nil {
+		return nil
+	} else {
+		return fmt.Errorf("cannot set field %q: %v", self.OriginalName, err)
+	}
+}
+
+func setIdentityValue(target reflect.Value, value interface{}) error {
+	var input = reflect.ValueOf(value)
+
+	if input.Type().AssignableTo(target.Type()) {
+		target.Set(input)
+		return nil
+	}
+
+	if target.CanAddr() {
+		if unmarshaler, ok := target.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return unmarshaler.UnmarshalText([]byte(typeutil.String(value)))
+		}
+	}
+
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(typeutil.String(value))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		target.SetInt(typeutil.Int(value))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		target.SetUint(uint64(typeutil.Int(value)))
+	case reflect.Float32, reflect.Float64:
+		target.SetFloat(typeutil.Float(value))
+	default:
+		return typeutil.SetValue(target, value)
+	}
+
+	return nil
+}
+
+var builtinKindTypes = map[reflect.Kind]reflect.Type{
+	reflect.String:  reflect.TypeOf(``),
+	reflect.Int:     reflect.TypeOf(int(0)),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+}
+
+// Returns the value of a struct's identity field in a form suitable for use as a Record ID.  Pointers
+// are dereferenced, named types (e.g.: "type UserID string") are converted to their underlying type,
+// and non-scalar types that implement encoding.TextMarshaler (e.g.: UUIDs) are converted to strings.
+// Zero values are returned as nil so that an identity can be generated for them.
+func identityValueFromStruct(value reflect.Value) interface{} {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+
+		value = value.Elem()
+	}
+
+	if builtin, ok := builtinKindTypes[value.Kind()]; ok {
+		if typeutil.IsZero(value.Interface()) {
+			return nil
+		}
+
+		return value.Convert(builtin).Interface()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct, reflect.Array, reflect.Slice:
+		if marshaler, ok := value.Interface().(encoding.TextMarshaler); ok {
+			if reflect.DeepEqual(value.Interface(), reflect.Zero(value.Type()).Interface()) {
+				return nil
+			} else if text, err := marshaler.MarshalText(); err == nil {
+				return string(text)
+			}
+		}
+	}
+
+	return value.Interface()
+}
+
+// Returns whether the given struct field value is a nil pointer, map, slice, or interface.
+func isNilValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return value.IsNil()
+	default:
+		return false
+	}
+}
+
 type Model interface{}
 
 func structFieldToDesc(field *reflect.StructField) *fieldDescription {
@@ -59,6 +191,8 @@ func structFieldToDesc(field *reflect.StructField) *fieldDescription {
 				desc.Identity = true
 			case `omitempty`:
 				desc.OmitEmpty = true
+			case `explicitnull`:
+				desc.ExplicitNull = true
 			}
 		}
 	}
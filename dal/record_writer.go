@@ -0,0 +1,182 @@
+package dal
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+)
+
+// The formats that records can be written in by NewRecordWriter.
+var RecordWriterFormats = []string{`json`, `csv`, `parquet`}
+
+// A RecordWriter writes records to an output stream in a particular format one at a time, so that
+// large result sets can be exported without holding them in memory.
+type RecordWriter interface {
+	// Write a single record.
+	Write(record *Record) error
+
+	// Flush any buffered output and write any trailing data required by the format.  The
+	// underlying writer is not closed.
+	Close() error
+}
+
+// Returns a RecordWriter that writes records to w in the given format: "json" (newline-delimited),
+// "csv", or "parquet".  Tabular formats write one column per field; if fields are not given, the
+// collection's identity field followed by each of its fields are used.  If the collection does not
+// define any fields, the columns are taken from the first record written.
+func NewRecordWriter(format string, w io.Writer, collection *Collection, fields ...string) (RecordWriter, error) {
+	switch format {
+	case `json`, ``:
+		return &jsonRecordWriter{
+			encoder: json.NewEncoder(w),
+		}, nil
+	case `csv`:
+		return &csvRecordWriter{
+			writer:  csv.NewWriter(w),
+			columns: newRecordColumns(collection, fields),
+		}, nil
+	case `parquet`:
+		return newParquetRecordWriter(w, newRecordColumns(collection, fields)), nil
+	default:
+		return nil, fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+type jsonRecordWriter struct {
+	encoder *json.Encoder
+}
+
+func (self *jsonRecordWriter) Write(record *Record) error {
+	return self.encoder.Encode(record)
+}
+
+func (self *jsonRecordWriter) Close() error {
+	return nil
+}
+
+// decides which columns tabular formats write, and extracts the value of each column from records
+type recordColumns struct {
+	collection *Collection
+	names      []string
+}
+
+func newRecordColumns(collection *Collection, fields []string) *recordColumns {
+	var columns = &recordColumns{
+		collection: collection,
+	}
+
+	if len(fields) > 0 {
+		columns.names = fields
+	} else if collection != nil && len(collection.Fields) > 0 {
+		columns.names = []string{collection.GetIdentityFieldName()}
+
+		for _, field := range collection.Fields {
+			columns.names = append(columns.names, field.Name)
+		}
+	}
+
+	return columns
+}
+
+// settles the list of columns using the first record written, if they aren't already known
+func (self *recordColumns) resolve(record *Record) []string {
+	if self.names == nil {
+		var idField = DefaultIdentityField
+
+		if self.collection != nil {
+			idField = self.collection.GetIdentityFieldName()
+		}
+
+		var names = make([]string, 0, len(record.Fields))
+
+		for name := range record.Fields {
+			if name != idField {
+				names = append(names, name)
+			}
+		}
+
+		sort.Strings(names)
+
+		self.names = append([]string{idField}, names...)
+	}
+
+	return self.names
+}
+
+// returns the type of the named column, or StringType if it isn't known
+func (self *recordColumns) typeOf(name string) Type {
+	if self.collection != nil {
+		if field, ok := self.collection.GetField(name); ok && field.Type != `` {
+			return field.Type
+		}
+	}
+
+	return StringType
+}
+
+func (self *recordColumns) value(record *Record, name string) interface{} {
+	if self.collection != nil && name == self.collection.GetIdentityFieldName() {
+		return record.ID
+	} else if name == DefaultIdentityField {
+		return record.ID
+	} else {
+		return record.Get(name)
+	}
+}
+
+// returns the textual representation of a value for use in text-based tabular formats
+func columnValueString(value interface{}) string {
+	if value == nil {
+		return ``
+	} else if t, ok := value.(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	} else if typeutil.IsMap(value) || typeutil.IsArray(value) {
+		if data, err := json.Marshal(value); err == nil {
+			return string(data)
+		}
+	}
+
+	return typeutil.String(value)
+}
+
+type csvRecordWriter struct {
+	writer      *csv.Writer
+	columns     *recordColumns
+	wroteHeader bool
+}
+
+func (self *csvRecordWriter) Write(record *Record) error {
+	var names = self.columns.resolve(record)
+
+	if !self.wroteHeader {
+		if err := self.writer.Write(names); err != nil {
+			return err
+		}
+
+		self.wroteHeader = true
+	}
+
+	var row = make([]string, len(names))
+
+	for i, name := range names {
+		row[i] = columnValueString(self.columns.value(record, name))
+	}
+
+	return self.writer.Write(row)
+}
+
+func (self *csvRecordWriter) Close() error {
+	if !self.wroteHeader && self.columns.names != nil {
+		if err := self.writer.Write(self.columns.names); err != nil {
+			return err
+		}
+	}
+
+	self.writer.Flush()
+	return self.writer.Error()
+}
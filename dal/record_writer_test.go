@@ -0,0 +1,93 @@
+package dal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordWriterCSV(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`users`, Field{
+		Name: `name`,
+		Type: StringType,
+	}, Field{
+		Name: `tags`,
+		Type: ArrayType,
+	})
+
+	var buf bytes.Buffer
+
+	writer, err := NewRecordWriter(`csv`, &buf, collection)
+	assert.NoError(err)
+	assert.NoError(writer.Write(NewRecord(1).Set(`name`, `Bob, Jr.`).Set(`tags`, []string{`a`, `b`})))
+	assert.NoError(writer.Write(NewRecord(2).Set(`name`, `Alice`)))
+	assert.NoError(writer.Close())
+
+	assert.Equal("id,name,tags\n1,\"Bob, Jr.\",\"[\"\"a\"\",\"\"b\"\"]\"\n2,Alice,\n", buf.String())
+
+	// explicit fields
+	buf.Reset()
+
+	writer, err = NewRecordWriter(`csv`, &buf, collection, `name`)
+	assert.NoError(err)
+	assert.NoError(writer.Write(NewRecord(1).Set(`name`, `Bob`)))
+	assert.NoError(writer.Close())
+	assert.Equal("name\nBob\n", buf.String())
+
+	// columns come from the first record when the collection doesn't define any fields
+	buf.Reset()
+
+	writer, err = NewRecordWriter(`csv`, &buf, nil)
+	assert.NoError(err)
+	assert.NoError(writer.Write(NewRecord(`a`).Set(`z`, 1).Set(`y`, true)))
+	assert.NoError(writer.Write(NewRecord(`b`).Set(`z`, 2).Set(`x`, 3)))
+	assert.NoError(writer.Close())
+	assert.Equal("id,y,z\na,true,1\nb,,2\n", buf.String())
+
+	_, err = NewRecordWriter(`xml`, &buf, nil)
+	assert.Error(err)
+}
+
+func TestRecordWriterParquet(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`users`, Field{
+		Name: `name`,
+		Type: StringType,
+	}, Field{
+		Name: `age`,
+		Type: IntType,
+	})
+
+	var buf bytes.Buffer
+
+	writer, err := NewRecordWriter(`parquet`, &buf, collection)
+	assert.NoError(err)
+
+	for i := 0; i < 25; i++ {
+		assert.NoError(writer.Write(NewRecord(i).Set(`name`, `user`).Set(`age`, i)))
+	}
+
+	assert.NoError(writer.Close())
+
+	data := buf.Bytes()
+	assert.True(len(data) > 12)
+	assert.Equal(`PAR1`, string(data[:4]))
+	assert.Equal(`PAR1`, string(data[len(data)-4:]))
+
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-8 : len(data)-4]))
+	assert.True(footerLength > 0 && footerLength < len(data)-12)
+	assert.Contains(string(data[len(data)-8-footerLength:]), `pivot`)
+
+	// an empty result still produces a valid file
+	buf.Reset()
+
+	writer, err = NewRecordWriter(`parquet`, &buf, collection)
+	assert.NoError(err)
+	assert.NoError(writer.Close())
+	assert.Equal(`PAR1`, string(buf.Bytes()[:4]))
+}
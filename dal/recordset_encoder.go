@@ -0,0 +1,119 @@
+package dal
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// the fields of a RecordSet other than its records, written after the records by RecordSetEncoder
+type recordSetEnvelope struct {
+	ResultCount    int64                  `json:"result_count"`
+	Page           int                    `json:"page,omitempty"`
+	TotalPages     int                    `json:"total_pages,omitempty"`
+	RecordsPerPage int                    `json:"records_per_page,omitempty"`
+	Options        map[string]interface{} `json:"options"`
+	KnownSize      bool                   `json:"known_size"`
+	Error          string                 `json:"error,omitempty"`
+}
+
+// A RecordSetEncoder writes a RecordSet as a JSON object one record at a time, so that large result
+// sets can be written without encoding (or even holding) all of their records in memory at once.
+// The records array is written first and the rest of the RecordSet's fields follow it, since page
+// details are often not known until all of the records have been read.
+type RecordSetEncoder struct {
+	w       io.Writer
+	started bool
+	written int64
+}
+
+func NewRecordSetEncoder(w io.Writer) *RecordSetEncoder {
+	return &RecordSetEncoder{
+		w: w,
+	}
+}
+
+// Write a single record to the records array.
+func (self *RecordSetEncoder) Encode(record *Record) error {
+	if data, err := json.Marshal(record); err == nil {
+		if err := self.begin(); err != nil {
+			return err
+		}
+
+		if self.written > 0 {
+			if _, err := self.w.Write([]byte(`,`)); err != nil {
+				return err
+			}
+		}
+
+		if _, err := self.w.Write(data); err != nil {
+			return err
+		}
+
+		self.written += 1
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Close the records array and write the remaining fields of the given RecordSet (its records are
+// ignored).  If envelope is nil, the result count is the number of records written.  If err is
+// given, its message is included in the object as "error" so that clients can tell a response that
+// was cut short from a complete one.
+func (self *RecordSetEncoder) Finish(envelope *RecordSet, err error) error {
+	var tail = recordSetEnvelope{
+		ResultCount: self.written,
+		Options:     make(map[string]interface{}),
+	}
+
+	if envelope != nil {
+		tail.ResultCount = envelope.ResultCount
+		tail.Page = envelope.Page
+		tail.TotalPages = envelope.TotalPages
+		tail.RecordsPerPage = envelope.RecordsPerPage
+		tail.KnownSize = envelope.KnownSize
+
+		if envelope.Options != nil {
+			tail.Options = envelope.Options
+		}
+	}
+
+	if err != nil {
+		tail.Error = err.Error()
+	}
+
+	if data, err := json.Marshal(&tail); err == nil {
+		if err := self.begin(); err != nil {
+			return err
+		}
+
+		// replace the opening brace of the envelope with the end of the records array
+		_, err := self.w.Write(append([]byte(`],`), data[1:]...))
+		return err
+	} else {
+		return err
+	}
+}
+
+func (self *RecordSetEncoder) begin() error {
+	if self.started {
+		return nil
+	}
+
+	self.started = true
+	_, err := self.w.Write([]byte(`{"records":[`))
+	return err
+}
+
+// Write the RecordSet to w as JSON, encoding one record at a time.
+func (self *RecordSet) EncodeJSON(w io.Writer) error {
+	var encoder = NewRecordSetEncoder(w)
+
+	for _, record := range self.Records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	return encoder.Finish(self, nil)
+}
@@ -0,0 +1,55 @@
+package dal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordSetEncoder(t *testing.T) {
+	assert := require.New(t)
+
+	recordset := NewRecordSet(
+		NewRecord(1).Set(`name`, `first`),
+		NewRecord(2).Set(`name`, `second`),
+	)
+
+	recordset.Page = 1
+	recordset.TotalPages = 3
+	recordset.RecordsPerPage = 2
+	recordset.ResultCount = 6
+	recordset.KnownSize = true
+
+	var buf bytes.Buffer
+	assert.NoError(recordset.EncodeJSON(&buf))
+	assert.True(json.Valid(buf.Bytes()))
+
+	// decodes the same as the RecordSet would have been encoded all at once
+	var streamed, whole map[string]interface{}
+	expected, err := json.Marshal(recordset)
+	assert.NoError(err)
+
+	assert.NoError(json.Unmarshal(buf.Bytes(), &streamed))
+	assert.NoError(json.Unmarshal(expected, &whole))
+	assert.Equal(whole, streamed)
+
+	// empty record sets still have a records array
+	buf.Reset()
+	assert.NoError(NewRecordSet().EncodeJSON(&buf))
+	assert.Equal(`{"records":[],"result_count":0,"options":{},"known_size":false}`, buf.String())
+
+	// without an envelope, the result count is the number of records written; errors are included
+	buf.Reset()
+	encoder := NewRecordSetEncoder(&buf)
+	assert.NoError(encoder.Encode(NewRecord(1)))
+	assert.NoError(encoder.Finish(nil, fmt.Errorf("connection lost")))
+
+	var partial map[string]interface{}
+	assert.NoError(json.Unmarshal(buf.Bytes(), &partial))
+	assert.EqualValues(1, partial[`result_count`])
+	assert.Equal(`connection lost`, partial[`error`])
+	assert.Len(partial[`records`], 1)
+}
@@ -1,11 +1,57 @@
 package dal
 
+import (
+	"fmt"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+)
+
+type RelationshipKind string
+
+const (
+	// The default kind of relationship, in which the values of the local key field(s) are the IDs
+	// of records in the related collection, which are embedded in their place.
+	EmbedRelationship RelationshipKind = ``
+
+	// A relationship in which records on either side may be related to any number of records on
+	// the other, as recorded by the records of a join collection.
+	ManyToManyRelationship RelationshipKind = `many-to-many`
+)
+
 type Relationship struct {
-	Keys           interface{} `json:"key"`
-	Collection     *Collection `json:"-"`
-	CollectionName string      `json:"collection,omitempty"`
-	Fields         []string    `json:"fields,omitempty"`
-	Force          bool        `json:"force,omitempty"`
+	Keys           interface{}      `json:"key"`
+	Collection     *Collection      `json:"-"`
+	CollectionName string           `json:"collection,omitempty"`
+	Fields         []string         `json:"fields,omitempty"`
+	Force          bool             `json:"force,omitempty"`
+	Kind           RelationshipKind `json:"kind,omitempty"`
+
+	// For many-to-many relationships, the name of the join collection that relates records from
+	// this collection (by the ThroughKey field) to records in the related collection (by the
+	// ThroughRelatedKey field).
+	Through           string `json:"through,omitempty"`
+	ThroughKey        string `json:"through_key,omitempty"`
+	ThroughRelatedKey string `json:"through_related_key,omitempty"`
+
+	// When the key holds many IDs, the most related records that are embedded in a single record,
+	// and the most bytes (encoded as JSON) they may total.  Related records past either limit are
+	// left out and described in the record's EmbedTruncationField.  Zero uses the limits of the
+	// backend doing the embedding.
+	MaxRecords int `json:"max_records,omitempty"`
+	MaxBytes   int `json:"max_bytes,omitempty"`
+}
+
+// The field of a record that describes the related records left out of it due to embedding limits,
+// keyed by relationship key.
+var EmbedTruncationField = `_truncated`
+
+// Describes the related records that were left out of a record when embedding them, so that they
+// can be retrieved separately.
+type EmbedTruncation struct {
+	Collection string        `json:"collection"`
+	Total      int           `json:"total"`
+	Included   int           `json:"included"`
+	Remaining  []interface{} `json:"remaining"`
 }
 
 func (self *Relationship) RelatedCollectionName() string {
@@ -15,3 +61,85 @@ func (self *Relationship) RelatedCollectionName() string {
 		return self.CollectionName
 	}
 }
+
+// Returns the name the relationship is referred to by; for many-to-many relationships, this is
+// the (virtual) field that related records are loaded into.
+func (self *Relationship) Name() string {
+	return typeutil.String(self.Keys)
+}
+
+func (self *Relationship) IsManyToMany() bool {
+	return self.Kind == ManyToManyRelationship
+}
+
+// Verifies that the relationship specifies everything its kind requires.
+func (self *Relationship) Validate() error {
+	if self.RelatedCollectionName() == `` {
+		return fmt.Errorf("relationship %q must specify a related collection", self.Name())
+	}
+
+	switch self.Kind {
+	case EmbedRelationship:
+		return nil
+	case ManyToManyRelationship:
+		if self.Name() == `` {
+			return fmt.Errorf("many-to-many relationships must specify a key")
+		} else if self.Through == `` {
+			return fmt.Errorf("many-to-many relationship %q must specify a join collection", self.Name())
+		} else if self.ThroughKey == `` || self.ThroughRelatedKey == `` {
+			return fmt.Errorf("many-to-many relationship %q must specify both join collection keys", self.Name())
+		} else if self.ThroughKey == self.ThroughRelatedKey {
+			return fmt.Errorf("many-to-many relationship %q must use different join collection keys", self.Name())
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("relationship %q: unknown kind %q", self.Name(), self.Kind)
+	}
+}
+
+// Generates the definition of the join collection for a many-to-many relationship between the
+// parent and related collections.  The join collection's key fields are indexed and constrained to
+// the identity fields of the collections they refer to, and its records are identified by UUIDs.
+func (self *Relationship) JoinCollection(parent *Collection, related *Collection) (*Collection, error) {
+	if !self.IsManyToMany() {
+		return nil, fmt.Errorf("relationship %q is not many-to-many", self.Name())
+	} else if err := self.Validate(); err != nil {
+		return nil, err
+	}
+
+	var join = NewCollection(self.Through, Field{
+		Name:     self.ThroughKey,
+		Type:     identityFieldType(parent),
+		Required: true,
+		Indexed:  true,
+		BelongsTo: Constraint{
+			Collection: parent.Name,
+			Field:      parent.GetIdentityFieldName(),
+			NoEmbed:    true,
+		},
+	}, Field{
+		Name:     self.ThroughRelatedKey,
+		Type:     identityFieldType(related),
+		Required: true,
+		Indexed:  true,
+		BelongsTo: Constraint{
+			Collection: related.Name,
+			Field:      related.GetIdentityFieldName(),
+			NoEmbed:    true,
+		},
+	})
+
+	join.IdentityFieldType = StringType
+	join.AutoIdentity = `uuid`
+
+	return join, nil
+}
+
+func identityFieldType(collection *Collection) Type {
+	if collection.IdentityFieldType == `` {
+		return DefaultIdentityFieldType
+	} else {
+		return collection.IdentityFieldType
+	}
+}
@@ -0,0 +1,95 @@
+package dal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelationshipManyToMany(t *testing.T) {
+	assert := require.New(t)
+
+	items := NewCollection(`items`, Field{
+		Name: `name`,
+		Type: StringType,
+	})
+
+	orders := NewCollection(`orders`, Field{
+		Name: `status`,
+		Type: StringType,
+	})
+
+	orders.IdentityFieldType = StringType
+
+	relationship := Relationship{
+		Kind:              ManyToManyRelationship,
+		Keys:              `items`,
+		Collection:        items,
+		Through:           `orders_items`,
+		ThroughKey:        `order_id`,
+		ThroughRelatedKey: `item_id`,
+	}
+
+	orders.EmbeddedCollections = []Relationship{relationship}
+
+	assert.NoError(relationship.Validate())
+	assert.NoError(orders.Check())
+	assert.Equal(`items`, relationship.Name())
+
+	join, err := relationship.JoinCollection(orders, items)
+	assert.NoError(err)
+	assert.Equal(`orders_items`, join.Name)
+	assert.Equal(`uuid`, join.AutoIdentity)
+
+	orderId, ok := join.GetField(`order_id`)
+	assert.True(ok)
+	assert.Equal(StringType, orderId.Type)
+	assert.True(orderId.Indexed)
+
+	itemId, ok := join.GetField(`item_id`)
+	assert.True(ok)
+	assert.EqualValues(IntType, itemId.Type)
+
+	constraints := join.GetAllConstraints()
+	assert.Len(constraints, 2)
+	assert.Equal(`order_id`, constraints[0].On)
+	assert.Equal(`orders`, constraints[0].Collection)
+	assert.Equal(`item_id`, constraints[1].On)
+	assert.Equal(`items`, constraints[1].Collection)
+
+	// join records are given generated IDs
+	record, err := join.StructToRecord(NewRecord(nil).Set(`order_id`, `a`).Set(`item_id`, 1))
+	assert.NoError(err)
+	assert.NotEmpty(record.ID)
+
+	// many-to-many relationships must say how they're joined
+	relationship.ThroughRelatedKey = ``
+	assert.Error(relationship.Validate())
+
+	orders.EmbeddedCollections = []Relationship{relationship}
+	assert.Error(orders.Check())
+
+	_, err = relationship.JoinCollection(orders, items)
+	assert.Error(err)
+
+	// ...and only they have join collections
+	_, err = (&Relationship{
+		Keys:           `items`,
+		CollectionName: `items`,
+	}).JoinCollection(orders, items)
+	assert.Error(err)
+}
+
+func TestCollectionIdentityValueOf(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`users`)
+
+	assert.Equal(1, collection.IdentityValueOf(&testUser{
+		ID: 1,
+	}))
+
+	assert.Zero(collection.IdentityValueOf(&testGroup{}))
+	assert.Equal(2, collection.IdentityValueOf(NewRecord(2)))
+	assert.Equal(3, collection.IdentityValueOf(3))
+}
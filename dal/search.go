@@ -0,0 +1,64 @@
+package dal
+
+import (
+	"fmt"
+)
+
+// The suffix appended to the name of a string field to form the name of the analyzed (tokenized)
+// copy of that field that indexers maintain for full-text search.
+var SearchFieldSuffix = `.search`
+
+// Declares a field that is included in a collection's full-text index, and how heavily matches in
+// that field are weighted relative to matches in other fields.
+type SearchField struct {
+	// The name of the field.
+	Name string `json:"name"`
+
+	// A multiplier applied to the relevance of matches in this field.  Defaults to 1.
+	Boost float64 `json:"boost,omitempty"`
+}
+
+// Returns the boost applied to matches in this field.
+func (self SearchField) GetBoost() float64 {
+	if self.Boost > 0 {
+		return self.Boost
+	}
+
+	return 1
+}
+
+// Returns the name of the field that full-text queries should be run against in the given
+// collection.  String fields are searched using an analyzed copy of the field (named with
+// SearchFieldSuffix), while all other fields are searched as-is.
+func (self SearchField) GetSearchPath(collection *Collection) string {
+	if field, ok := collection.GetField(self.Name); ok && field.Type != StringType {
+		return self.Name
+	}
+
+	return self.Name + SearchFieldSuffix
+}
+
+// Verify that the search field refers to a field that exists in the given collection.
+func (self SearchField) Validate(collection *Collection) error {
+	if self.Name == `` {
+		return fmt.Errorf("search fields must have a name")
+	} else if self.Boost < 0 {
+		return fmt.Errorf("search field %q: boost cannot be negative", self.Name)
+	} else if _, ok := collection.GetField(self.Name); !ok {
+		return fmt.Errorf("search field %q: no such field", self.Name)
+	}
+
+	return nil
+}
+
+// Returns the search field declaration for the named field, if it is included in this
+// collection's full-text index.
+func (self *Collection) GetSearchField(name string) (SearchField, bool) {
+	for _, sf := range self.SearchFields {
+		if sf.Name == name {
+			return sf, true
+		}
+	}
+
+	return SearchField{}, false
+}
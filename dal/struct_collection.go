@@ -0,0 +1,246 @@
+package dal
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+)
+
+// Implemented by structs that name the collection CollectionFromStruct builds from them.
+type CollectionNamer interface {
+	CollectionName() string
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// Builds a collection definition from the fields of the given struct (or pointer to one) and their
+// `pivot` tags, so that the struct is the only definition of the collection's schema.  The
+// collection is named by the struct's CollectionName() method if it has one, otherwise by its
+// type name in snake_case.
+//
+// In addition to the field name and the "identity", "omitempty", and "explicitnull" options that
+// records use, tags may specify:
+//
+//	required               the field must have a value
+//	unique                 values must be unique across the collection
+//	key                    the field is part of a composite key
+//	index                  the field is indexed
+//	readonly               the field cannot be written to
+//	type=TYPE              the field's type, instead of inferring it from the Go type
+//	length=N               the maximum length of the field
+//	default=VALUE          the field's default value (or default value expression, e.g. "now")
+//	belongs_to=COLLECTION  values are the IDs of records in another collection
+//	autoidentity=FORMAT    on the identity field, how IDs are generated (e.g.: "uuid")
+//
+// Since options are separated by commas, default values cannot contain them.  Fields tagged "-"
+// and unexported fields are skipped, and the fields of embedded structs are included as though
+// they were declared on the outer struct.
+func CollectionFromStruct(instance interface{}) (*Collection, error) {
+	var structType = reflect.TypeOf(instance)
+
+	for structType != nil && structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	if structType == nil || structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Can only build a collection from a struct, got %T", instance)
+	}
+
+	var name string
+
+	if namer, ok := instance.(CollectionNamer); ok {
+		name = namer.CollectionName()
+	} else {
+		name = stringutil.Underscore(structType.Name())
+	}
+
+	var collection = NewCollection(name)
+	var identity = structIdentity{
+		fallback: -1,
+	}
+
+	if err := collectionFieldsFromStruct(collection, structType, &identity); err != nil {
+		return nil, err
+	}
+
+	// as with records, a field named ID is the identity if no field is tagged as such
+	if !identity.tagged && identity.fallback >= 0 {
+		var field = collection.Fields[identity.fallback]
+
+		collection.Fields = append(collection.Fields[:identity.fallback], collection.Fields[identity.fallback+1:]...)
+		collection.IdentityField = field.Name
+		collection.IdentityFieldType = field.Type
+	}
+
+	if err := collection.Check(); err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// tracks whether a struct's identity field has been found, and which field to use if not
+type structIdentity struct {
+	tagged   bool
+	fallback int
+}
+
+func collectionFieldsFromStruct(collection *Collection, structType reflect.Type, identity *structIdentity) error {
+	for i := 0; i < structType.NumField(); i++ {
+		var structField = structType.Field(i)
+		var tag = strings.TrimSpace(structField.Tag.Get(RecordStructTag))
+
+		if tag == `-` {
+			continue
+		} else if structField.Anonymous && tag == `` {
+			var embedded = structField.Type
+
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				if err := collectionFieldsFromStruct(collection, embedded, identity); err != nil {
+					return err
+				}
+
+				continue
+			}
+		}
+
+		if structField.PkgPath != `` {
+			continue
+		}
+
+		var desc = structFieldToDesc(&structField)
+		var field = Field{
+			Name: desc.RecordKey,
+			Type: fieldTypeFromGoType(structField.Type),
+		}
+
+		var options = make(map[string]string)
+
+		if _, rest := stringutil.SplitPair(tag, `,`); rest != `` {
+			for _, opt := range strings.Split(rest, `,`) {
+				k, v := stringutil.SplitPair(strings.TrimSpace(opt), `=`)
+				options[k] = v
+			}
+		}
+
+		for opt, value := range options {
+			switch opt {
+			case `identity`, `omitempty`, `explicitnull`, `default`:
+				continue
+			case `required`:
+				field.Required = true
+			case `unique`:
+				field.Unique = true
+			case `key`:
+				field.Key = true
+			case `index`:
+				field.Indexed = true
+			case `readonly`:
+				field.ReadOnly = true
+			case `type`:
+				if field.Type = ParseFieldType(value); field.Type == `` {
+					return fmt.Errorf("field %v: invalid type %q", structField.Name, value)
+				}
+			case `length`:
+				if n, err := strconv.Atoi(value); err == nil {
+					field.Length = n
+				} else {
+					return fmt.Errorf("field %v: invalid length %q", structField.Name, value)
+				}
+			case `belongs_to`:
+				field.BelongsTo = value
+			case `autoidentity`:
+				if !desc.Identity {
+					return fmt.Errorf("field %v: only the identity field can specify autoidentity", structField.Name)
+				}
+
+				collection.AutoIdentity = value
+			default:
+				return fmt.Errorf("field %v: unknown option %q", structField.Name, opt)
+			}
+		}
+
+		// the default is converted to the field's type once that's known
+		if value, ok := options[`default`]; ok {
+			if IsDefaultExpression(value) {
+				field.DefaultValue = value
+			} else if norm, err := parseStructDefaultValue(&field, value); err == nil {
+				field.DefaultValue = norm
+			} else {
+				return fmt.Errorf("field %v: invalid default value %q: %v", structField.Name, value, err)
+			}
+		}
+
+		if desc.Identity {
+			collection.IdentityField = field.Name
+			collection.IdentityFieldType = field.Type
+			identity.tagged = true
+			continue
+		} else if structField.Name == DefaultStructIdentityFieldName && identity.fallback < 0 {
+			identity.fallback = len(collection.Fields)
+		}
+
+		collection.Fields = append(collection.Fields, field)
+	}
+
+	return nil
+}
+
+// converts a default value given in a struct tag to the field's type, rejecting values that aren't
+// valid for it (which normalizeType would quietly zero)
+func parseStructDefaultValue(field *Field, value string) (interface{}, error) {
+	switch field.Type {
+	case BooleanType:
+		return strconv.ParseBool(value)
+	case IntType:
+		return strconv.ParseInt(value, 10, 64)
+	case FloatType:
+		return strconv.ParseFloat(value, 64)
+	default:
+		return field.normalizeType(value)
+	}
+}
+
+// returns the type of field that values of the given Go type are stored as
+func fieldTypeFromGoType(goType reflect.Type) Type {
+	for goType.Kind() == reflect.Ptr {
+		goType = goType.Elem()
+	}
+
+	if goType == timeType || goType.ConvertibleTo(timeType) {
+		return TimeType
+	} else if goType.Implements(textMarshalerType) || reflect.PtrTo(goType).Implements(textMarshalerType) {
+		// e.g.: UUIDs, IP addresses
+		return StringType
+	}
+
+	switch goType.Kind() {
+	case reflect.String:
+		return StringType
+	case reflect.Bool:
+		return BooleanType
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return IntType
+	case reflect.Float32, reflect.Float64:
+		return FloatType
+	case reflect.Slice, reflect.Array:
+		if goType.Elem().Kind() == reflect.Uint8 {
+			return RawType
+		}
+
+		return ArrayType
+	default:
+		return ObjectType
+	}
+}
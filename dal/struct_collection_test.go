@@ -0,0 +1,135 @@
+package dal
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type structSchemaTimestamps struct {
+	CreatedAt time.Time  `pivot:"created_at,default=now"`
+	UpdatedAt *time.Time `pivot:"updated_at"`
+}
+
+type structSchemaAccount struct {
+	Username string `pivot:"username,identity"`
+	Email    string `pivot:"email,required,unique,length=255"`
+	Enabled  bool   `pivot:"enabled,default=true"`
+	Logins   int64  `pivot:"logins,default=0,index"`
+	Balance  float64
+	Address  net.IP            `pivot:"address"`
+	Tags     []string          `pivot:"tags"`
+	Avatar   []byte            `pivot:"avatar"`
+	Settings map[string]string `pivot:"settings,type=raw"`
+	GroupID  int               `pivot:"group_id,belongs_to=groups"`
+	Ignored  string            `pivot:"-"`
+	internal string
+	structSchemaTimestamps
+}
+
+type structSchemaGroup struct {
+	ID   int
+	Name string `pivot:"name,required"`
+}
+
+func (self *structSchemaGroup) CollectionName() string {
+	return `groups`
+}
+
+func TestCollectionFromStruct(t *testing.T) {
+	assert := require.New(t)
+
+	collection, err := CollectionFromStruct(&structSchemaAccount{})
+	assert.NoError(err)
+	assert.Equal(`struct_schema_account`, collection.Name)
+	assert.Equal(`username`, collection.IdentityField)
+	assert.Equal(StringType, collection.IdentityFieldType)
+
+	var names []string
+
+	for _, field := range collection.Fields {
+		names = append(names, field.Name)
+	}
+
+	assert.Equal([]string{
+		`email`,
+		`enabled`,
+		`logins`,
+		`Balance`,
+		`address`,
+		`tags`,
+		`avatar`,
+		`settings`,
+		`group_id`,
+		`created_at`,
+		`updated_at`,
+	}, names)
+
+	email, _ := collection.GetField(`email`)
+	assert.Equal(StringType, email.Type)
+	assert.True(email.Required)
+	assert.True(email.Unique)
+	assert.Equal(255, email.Length)
+
+	enabled, _ := collection.GetField(`enabled`)
+	assert.EqualValues(BooleanType, enabled.Type)
+	assert.Equal(true, enabled.DefaultValue)
+
+	logins, _ := collection.GetField(`logins`)
+	assert.EqualValues(IntType, logins.Type)
+	assert.True(logins.Indexed)
+
+	balance, _ := collection.GetField(`Balance`)
+	assert.EqualValues(FloatType, balance.Type)
+
+	address, _ := collection.GetField(`address`)
+	assert.Equal(StringType, address.Type)
+
+	tags, _ := collection.GetField(`tags`)
+	assert.EqualValues(ArrayType, tags.Type)
+
+	avatar, _ := collection.GetField(`avatar`)
+	assert.EqualValues(RawType, avatar.Type)
+
+	settings, _ := collection.GetField(`settings`)
+	assert.EqualValues(RawType, settings.Type)
+
+	groupId, _ := collection.GetField(`group_id`)
+	assert.Equal(`groups`, groupId.BelongsTo)
+
+	createdAt, _ := collection.GetField(`created_at`)
+	assert.EqualValues(TimeType, createdAt.Type)
+	assert.Equal(`now`, createdAt.DefaultValue)
+
+	updatedAt, _ := collection.GetField(`updated_at`)
+	assert.EqualValues(TimeType, updatedAt.Type)
+	assert.False(updatedAt.Required)
+
+	// the struct can name its collection, and a field named ID is the identity by default
+	groups, err := CollectionFromStruct(structSchemaGroup{})
+	assert.NoError(err)
+	assert.Equal(`struct_schema_group`, groups.Name)
+
+	groups, err = CollectionFromStruct(&structSchemaGroup{})
+	assert.NoError(err)
+	assert.Equal(`groups`, groups.Name)
+	assert.Equal(`ID`, groups.IdentityField)
+	assert.EqualValues(IntType, groups.IdentityFieldType)
+	assert.Len(groups.Fields, 1)
+
+	// invalid tags are rejected
+	_, err = CollectionFromStruct(&struct {
+		Name string `pivot:"name,sparkly"`
+	}{})
+	assert.Error(err)
+
+	_, err = CollectionFromStruct(&struct {
+		Age int `pivot:"age,default=old"`
+	}{})
+	assert.Error(err)
+
+	_, err = CollectionFromStruct(`users`)
+	assert.Error(err)
+}
@@ -11,15 +11,16 @@ import (
 type Type string
 
 const (
-	StringType  Type = `str`
-	AutoType         = `auto`
-	BooleanType      = `bool`
-	IntType          = `int`
-	FloatType        = `float`
-	TimeType         = `time`
-	ObjectType       = `object`
-	RawType          = `raw`
-	ArrayType        = `array`
+	StringType   Type = `str`
+	AutoType          = `auto`
+	BooleanType       = `bool`
+	IntType           = `int`
+	FloatType         = `float`
+	TimeType          = `time`
+	ObjectType        = `object`
+	RawType           = `raw`
+	ArrayType         = `array`
+	GeopointType      = `geopoint`
 )
 
 func (self Type) String() string {
@@ -44,6 +45,8 @@ func ParseFieldType(in string) Type {
 		return RawType
 	case `array`:
 		return ArrayType
+	case `geopoint`:
+		return GeopointType
 	default:
 		return ``
 	}
@@ -59,6 +62,16 @@ const (
 type FieldValidatorFunc func(interface{}) error
 type FieldFormatterFunc func(interface{}, FieldOperation) (interface{}, error)
 type CollectionValidatorFunc func(*Record) error
+type RecordSetFormatterFunc func(recordset *RecordSet, isCreate bool) error
+
+// Specifies what happens to fields that are not defined in a collection when records are written.
+type UnknownFieldPolicy string
+
+const (
+	IgnoreUnknownFields      UnknownFieldPolicy = `ignore`      // unknown fields are silently dropped
+	RejectUnknownFields      UnknownFieldPolicy = `reject`      // writes containing unknown fields fail
+	PassthroughUnknownFields UnknownFieldPolicy = `passthrough` // unknown fields are written as-is
+)
 
 type DeltaType string
 
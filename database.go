@@ -2,10 +2,12 @@ package pivot
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/ghetzel/pivot/v3/backends"
 	"github.com/ghetzel/pivot/v3/dal"
 	"github.com/ghetzel/pivot/v3/mapper"
+	"github.com/ghetzel/pivot/v3/util"
 )
 
 type DB interface {
@@ -18,6 +20,10 @@ type DB interface {
 	LoadFixtures(fileOrDirPath string) error
 	GetBackend() Backend
 	SetBackend(Backend)
+	Transaction(func(tx DB) error) error
+	Watch(collection string, fn func(event dal.ChangeEvent)) func()
+	Health() *util.HealthStatus
+	Stats() []backends.CollectionStats
 }
 
 type schemaModel struct {
@@ -35,7 +41,10 @@ func (self *schemaModel) String() string {
 
 type db struct {
 	backends.Backend
-	models []*schemaModel
+	models    []*schemaModel
+	watchLock sync.Mutex
+	health    *backends.HealthMonitor
+	stats     *backends.StatsRefresher
 }
 
 func newdb(backend backends.Backend) *db {
@@ -53,6 +62,27 @@ func (self *db) SetBackend(backend Backend) {
 	self.Backend = backend
 }
 
+// Returns the results of the most recent health check of the backend and indexer, or nil if the
+// database was not connected with ConnectOptions.HealthCheck set.
+func (self *db) Health() *util.HealthStatus {
+	if self.health == nil {
+		return nil
+	}
+
+	status := self.health.Status()
+	return &status
+}
+
+// Returns the most recently refreshed record counts of each collection, or nil if the database was
+// not connected with ConnectOptions.Stats set.
+func (self *db) Stats() []backends.CollectionStats {
+	if self.stats == nil {
+		return nil
+	}
+
+	return self.stats.Stats()
+}
+
 // A version of GetCollection that panics if the collection does not exist.
 func (self *db) C(name string) *Collection {
 	if collection, err := self.GetCollection(name); err == nil {
@@ -107,3 +137,38 @@ func (self *db) ApplySchemata(fileOrDirPath string) error {
 func (self *db) LoadFixtures(fileOrDirPath string) error {
 	return LoadFixtures(fileOrDirPath, self)
 }
+
+// Runs fn with a DB whose Insert, Update, and Delete calls are committed together if fn returns nil,
+// or rolled back if it returns an error.  Callers can check Supports(backends.Transactions) first;
+// backends without transaction support return backends.TransactionsNotSupportedError.  Note that
+// models attached to this DB continue to write outside of the transaction; use the tx DB directly.
+func (self *db) Transaction(fn func(tx DB) error) error {
+	return backends.Transaction(self.Backend, func(tx backends.Backend) error {
+		return fn(&db{
+			Backend: tx,
+			models:  self.models,
+			health:  self.health,
+			stats:   self.stats,
+		})
+	})
+}
+
+// Calls fn after every record in the named collection is successfully created, updated, or deleted
+// through this DB (pass backends.WatchAllCollections to watch every collection).  The returned
+// function stops fn from receiving further events.  See backends.ChangeWatchingBackend for details.
+func (self *db) Watch(collection string, fn func(event dal.ChangeEvent)) func() {
+	self.watchLock.Lock()
+	defer self.watchLock.Unlock()
+
+	// find the watching backend in the chain of wrapped backends, adding one if there isn't one
+	for _, backend := range backends.BackendChain(self.Backend) {
+		if watcher, ok := backend.(*backends.ChangeWatchingBackend); ok {
+			return watcher.Watch(collection, fn)
+		}
+	}
+
+	var watcher = backends.NewChangeWatchingBackend(self.Backend)
+	self.Backend = watcher
+
+	return watcher.Watch(collection, fn)
+}
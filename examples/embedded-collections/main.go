@@ -62,7 +62,20 @@ var ItemsTable = &dal.Collection{
 }
 
 var OrdersTable = &dal.Collection{
-	Name: `items`,
+	Name:              `orders`,
+	IdentityFieldType: dal.StringType,
+	EmbeddedCollections: []dal.Relationship{
+		{
+			// order items are related through the orders_items collection, which is created
+			// (along with its constraints) when the orders collection is migrated
+			Kind:              dal.ManyToManyRelationship,
+			Keys:              `items`,
+			Collection:        ItemsTable,
+			Through:           `orders_items`,
+			ThroughKey:        `order_id`,
+			ThroughRelatedKey: `item_id`,
+		},
+	},
 	Fields: []dal.Field{
 		{
 			Name:         `status`,
@@ -93,22 +106,6 @@ var OrdersTable = &dal.Collection{
 	},
 }
 
-var OrdersItemsTable = &dal.Collection{
-	Fields: []dal.Field{
-		{
-			Name:      `order_id`,
-			Type:      dal.StringType,
-			Required:  true,
-			BelongsTo: OrdersTable,
-		}, {
-			Name:      `item_id`,
-			Type:      dal.IntType,
-			Required:  true,
-			BelongsTo: ItemsTable,
-		},
-	},
-}
-
 var ContactsTable = &dal.Collection{
 	Name: `contacts`,
 	Fields: []dal.Field{
@@ -141,7 +138,6 @@ var ContactsTable = &dal.Collection{
 var Contacts pivot.Model
 var Items pivot.Model
 var Orders pivot.Model
-var OrdersItems pivot.Model
 
 func main() {
 	var connectionString string
@@ -164,7 +160,6 @@ func main() {
 		Contacts = db.AttachCollection(ContactsTable)
 		Items = db.AttachCollection(ItemsTable)
 		Orders = db.AttachCollection(OrdersTable)
-		OrdersItems = db.AttachCollection(OrdersItemsTable)
 
 		// create tables as necessary
 		log.FatalfIf("migrate failed: %v", db.Migrate())
@@ -28,6 +28,10 @@ var AllValue = `all`
 var SortAscending = `+`
 var SortDescending = `-`
 var DefaultIdentityField = `id`
+
+// The field name used by full-text criteria that should be matched against all of a collection's
+// search fields (e.g.: "_search/fulltext:some words").
+var SearchAllField = `_search`
 var rxCharFilter = regexp.MustCompile(`[\W\s\_]+`)
 
 type NormalizerFunc func(in string) string // {}
@@ -79,11 +83,67 @@ const (
 	OrConjunction                  = `or`
 )
 
+// Specifies how up-to-date query results must be with respect to recent writes when queries are
+// served by an external indexer.
+type ConsistencyLevel string
+
+const (
+	EventualConsistency ConsistencyLevel = ``        // results may lag behind recent writes
+	RefreshConsistency                   = `refresh` // force the index to refresh before querying
+	MergeConsistency                     = `merge`   // merge recently-written records into results
+	BackendConsistency                   = `backend` // query the backend directly, bypassing the indexer
+)
+
+func ParseConsistencyLevel(in string) (ConsistencyLevel, error) {
+	switch level := ConsistencyLevel(in); level {
+	case EventualConsistency, RefreshConsistency, MergeConsistency, BackendConsistency:
+		return level, nil
+	default:
+		return EventualConsistency, fmt.Errorf("unsupported consistency level %q", in)
+	}
+}
+
+// Specifies whether (and how) backends determine the total number of records matching a query,
+// beyond those in the page of results being returned.
+type TotalsMode string
+
+const (
+	DefaultTotals TotalsMode = ``       // whatever the backend does by default
+	WindowTotals             = `window` // count matching records as part of the query itself (e.g.: COUNT(*) OVER ())
+	CountTotals              = `count`  // count matching records with a separate query, up to a backend-specific limit
+)
+
+func ParseTotalsMode(in string) (TotalsMode, error) {
+	switch mode := TotalsMode(in); mode {
+	case DefaultTotals, WindowTotals, CountTotals:
+		return mode, nil
+	default:
+		return DefaultTotals, fmt.Errorf("unsupported totals mode %q", in)
+	}
+}
+
 type Aggregate struct {
 	Aggregation Aggregation
 	Field       string
 }
 
+type JoinType string
+
+const (
+	InnerJoin JoinType = `inner`
+	LeftJoin           = `left`
+)
+
+// Declares another collection whose records are joined to those of the collection being queried.
+// Fields belonging to the joined collection are referenced by qualifying them with its name
+// (e.g.: "orders.total").
+type Join struct {
+	Type        JoinType `json:"type,omitempty"`         // the type of join to perform (default: inner)
+	Collection  string   `json:"collection"`             // the name of the collection being joined
+	LocalField  string   `json:"local_field"`            // the field in the queried collection to join on
+	RemoteField string   `json:"remote_field,omitempty"` // the field in the joined collection to join on (default: "id")
+}
+
 func (self *Criterion) String() string {
 	rv := ``
 
@@ -124,6 +184,7 @@ type Filter struct {
 	Offset        int
 	Limit         int
 	Criteria      []Criterion
+	Groups        []Group
 	Sort          []string
 	Fields        []string
 	Options       map[string]interface{}
@@ -131,6 +192,11 @@ type Filter struct {
 	IdentityField string
 	Normalizer    NormalizerFunc `json:"-" bson:"-" pivot:"-"`
 	Conjunction   ConjunctionType
+	After         interface{}
+	Consistency   ConsistencyLevel
+	Totals        TotalsMode
+	Joins         []Join
+	Expand        []string // related records to embed in results, as dot-separated paths of keys (e.g.: "items.item_id")
 }
 
 func New() *Filter {
@@ -249,18 +315,7 @@ func ParseSpec(spec string) (*Filter, error) {
 
 	rvV := MakeFilter(spec)
 	rv := &rvV
-	criteriaPre := strings.Split(spec, CriteriaSeparator)
-	criteria := make([]string, 0)
-
-	if CriteriaSeparator == FieldTermSeparator {
-		criteria = criteriaPre
-	} else {
-		for _, fieldTerm := range criteriaPre {
-			parts := strings.SplitN(fieldTerm, FieldTermSeparator, 2)
-
-			criteria = append(criteria, parts...)
-		}
-	}
+	criteria := splitSpec(spec)
 
 	switch {
 	case spec == ``:
@@ -270,6 +325,15 @@ func ParseSpec(spec string) (*Filter, error) {
 	case spec == AllValue:
 		return rv, nil
 
+	case isGroupedSpec(spec):
+		if group, err := parseGroupExpression(spec, rv); err == nil {
+			rv.Conjunction = group.Conjunction
+			rv.Criteria = group.Criteria
+			rv.Groups = group.Groups
+		} else {
+			return rv, fmt.Errorf("Invalid filter spec: %v", err)
+		}
+
 	case len(criteria) >= 2:
 		for i, token := range criteria {
 			if (i % 2) == 0 {
@@ -375,12 +439,44 @@ func ParseSpec(spec string) (*Filter, error) {
 	return rv, nil
 }
 
+// split a filter spec into alternating field and value tokens
+func splitSpec(spec string) []string {
+	criteriaPre := strings.Split(spec, CriteriaSeparator)
+	criteria := make([]string, 0)
+
+	if CriteriaSeparator == FieldTermSeparator {
+		criteria = criteriaPre
+	} else {
+		for _, fieldTerm := range criteriaPre {
+			parts := strings.SplitN(fieldTerm, FieldTermSeparator, 2)
+
+			criteria = append(criteria, parts...)
+		}
+	}
+
+	return criteria
+}
+
 func (self *Filter) AddCriteria(criteria ...Criterion) *Filter {
 	self.MatchAll = false
 	self.Criteria = append(self.Criteria, criteria...)
 	return self
 }
 
+// Adds a full-text criterion that matches the given query against all of the fields the
+// collection being queried declares as searchable.
+func (self *Filter) Search(query string) *Filter {
+	if self.Spec == AllValue {
+		self.Spec = ``
+	}
+
+	return self.AddCriteria(Criterion{
+		Field:    SearchAllField,
+		Operator: `fulltext`,
+		Values:   []interface{}{query},
+	})
+}
+
 func (self *Filter) SortBy(fields ...string) *Filter {
 	if len(fields) > 0 {
 		self.Sort = fields
@@ -397,6 +493,14 @@ func (self *Filter) WithFields(fields ...string) *Filter {
 	return self
 }
 
+func (self *Filter) WithJoins(joins ...Join) *Filter {
+	if len(joins) > 0 {
+		self.Joins = append(self.Joins, joins...)
+	}
+
+	return self
+}
+
 func (self *Filter) BoundedBy(limit int, offset int) *Filter {
 	if limit >= 0 {
 		self.Limit = limit
@@ -410,13 +514,7 @@ func (self *Filter) BoundedBy(limit int, offset int) *Filter {
 }
 
 func (self *Filter) CriteriaFields() []string {
-	fields := make([]string, len(self.Criteria))
-
-	for i, criterion := range self.Criteria {
-		fields[i] = criterion.Field
-	}
-
-	return fields
+	return self.rootGroup().Fields()
 }
 
 func (self *Filter) IdOnly() bool {
@@ -458,7 +556,7 @@ func (self *Filter) GetIdentityValue() (interface{}, bool) {
 }
 
 func (self *Filter) IsMatchAll() bool {
-	if len(self.Criteria) == 0 {
+	if len(self.Criteria) == 0 && len(self.Groups) == 0 {
 		if self.MatchAll || self.Spec == AllValue {
 			self.MatchAll = true
 			return true
@@ -471,6 +569,8 @@ func (self *Filter) IsMatchAll() bool {
 func (self *Filter) String() string {
 	if self.IsMatchAll() {
 		return AllValue
+	} else if len(self.Groups) > 0 {
+		return self.rootGroup().expression()
 	} else {
 		criteria := make([]string, 0)
 
@@ -542,8 +642,10 @@ func (self *Filter) NewFromSpec(specs ...string) (*Filter, error) {
 }
 
 func (self *Filter) MatchesRecord(record *dal.Record) bool {
-	if self.Conjunction == OrConjunction {
-		panic("OR conjunction is not yet supported in filter.MatchesRecord()")
+	if self.After != nil {
+		if record == nil || !idIsAfter(record.ID, self.After) {
+			return false
+		}
 	}
 
 	if self.IsMatchAll() {
@@ -554,132 +656,209 @@ func (self *Filter) MatchesRecord(record *dal.Record) bool {
 		return false
 	}
 
-	for _, criterion := range self.Criteria {
-		var anyMatched bool
+	return self.matchesGroup(record, self.rootGroup())
+}
 
-	ValuesLoop:
-		for _, vI := range criterion.Values {
-			vStr := typeutil.String(vI)
+// whether the record satisfies all (or, for OR groups, any) of the group's criteria and nested
+// groups
+func (self *Filter) matchesGroup(record *dal.Record, group Group) bool {
+	var matchAny = (group.Conjunction == OrConjunction)
 
-			// if the operator isn't of the exact match sort, normalize the criterion value
-			if !IsExactMatchOperator(criterion.Operator) {
-				vStr = self.Normalizer(vStr)
-			}
+	for _, criterion := range group.Criteria {
+		if self.matchesCriterion(record, criterion) == matchAny {
+			return matchAny
+		}
+	}
 
-			// treat unset criterion values and the literal value "null" as nil
-			switch vStr {
-			case `null`, ``:
-				vI = nil
-			}
+	for _, subgroup := range group.Groups {
+		if subgroup.IsEmpty() {
+			continue
+		}
+
+		if self.matchesGroup(record, subgroup) == matchAny {
+			return matchAny
+		}
+	}
 
-			var invertQuery bool
-			var cmpValue interface{}
-			var cmpValueS string
+	return !matchAny || group.IsEmpty()
+}
 
-			if criterion.Field == self.IdentityField || criterion.Field == `id` {
-				cmpValue = record.ID
-			} else {
-				cmpValue = record.Get(criterion.Field)
+func (self *Filter) matchesCriterion(record *dal.Record, criterion Criterion) bool {
+	if IsContainmentOperator(criterion.Operator) {
+		return self.matchesContainment(record, criterion)
+	}
+
+	var anyMatched bool
+
+ValuesLoop:
+	for _, vI := range criterion.Values {
+		vStr := typeutil.String(vI)
+
+		// if the operator isn't of the exact match sort, normalize the criterion value
+		if !IsExactMatchOperator(criterion.Operator) {
+			vStr = self.Normalizer(vStr)
+		}
+
+		// treat unset criterion values and the literal value "null" as nil
+		switch vStr {
+		case `null`, ``:
+			vI = nil
+		}
+
+		var invertQuery bool
+		var cmpValue interface{}
+		var cmpValueS string
+
+		if criterion.Field == self.IdentityField || criterion.Field == `id` {
+			cmpValue = record.ID
+		} else {
+			cmpValue = record.Get(criterion.Field)
+		}
+
+		if cmpValue != nil {
+			cmpValueS = typeutil.String(cmpValue)
+
+			// if the operator isn't of the exact match sort, normalize the record field value
+			if !IsExactMatchOperator(criterion.Operator) {
+				cmpValueS = self.Normalizer(cmpValueS)
 			}
+		}
 
-			if cmpValue != nil {
-				cmpValueS = typeutil.String(cmpValue)
+		// fmt.Printf("term:%v value:%v\n", vStr, cmpValueS)
+
+		switch criterion.Operator {
+		case `is`, ``, `not`, `like`, `unlike`:
+			var isEqual bool
+
+			invertQuery = IsInvertingOperator(criterion.Operator)
 
-				// if the operator isn't of the exact match sort, normalize the record field value
-				if !IsExactMatchOperator(criterion.Operator) {
-					cmpValueS = self.Normalizer(cmpValueS)
+			switch criterion.Type {
+			case dal.AutoType, ``:
+				if e, err := stringutil.RelaxedEqual(vStr, cmpValueS); err == nil {
+					isEqual = e
+				} else {
+					return false
 				}
-			}
+			case dal.FloatType:
+				isEqual = (typeutil.Float(vI) == typeutil.Float(cmpValue))
 
-			// fmt.Printf("term:%v value:%v\n", vStr, cmpValueS)
+			case dal.IntType:
+				isEqual = (typeutil.Int(vI) == typeutil.Int(cmpValue))
 
-			switch criterion.Operator {
-			case `is`, ``, `not`, `like`, `unlike`:
-				var isEqual bool
+			case dal.BooleanType:
+				isEqual = (typeutil.Bool(vI) == typeutil.Bool(cmpValue))
 
-				invertQuery = IsInvertingOperator(criterion.Operator)
+			default:
+				isEqual = (vI == cmpValue)
+			}
 
-				switch criterion.Type {
-				case dal.AutoType:
-					if e, err := stringutil.RelaxedEqual(vStr, cmpValueS); err == nil {
-						isEqual = e
-					} else {
-						return false
-					}
-				case dal.FloatType:
-					isEqual = (typeutil.Float(vI) == typeutil.Float(cmpValue))
+			if !invertQuery && isEqual || invertQuery && !isEqual {
+				anyMatched = true
+				break ValuesLoop
+			}
 
-				case dal.IntType:
-					isEqual = (typeutil.Int(vI) == typeutil.Int(cmpValue))
+		case `prefix`:
+			if strings.HasPrefix(strings.ToLower(cmpValueS), strings.ToLower(vStr)) {
+				anyMatched = true
+				break ValuesLoop
+			}
 
-				case dal.BooleanType:
-					isEqual = (typeutil.Bool(vI) == typeutil.Bool(cmpValue))
+		case `suffix`:
+			if strings.HasSuffix(strings.ToLower(cmpValueS), strings.ToLower(vStr)) {
+				anyMatched = true
+				break ValuesLoop
+			}
 
-				default:
-					isEqual = (vI == cmpValue)
-				}
+		case `contains`:
+			if strings.Contains(strings.ToLower(cmpValueS), strings.ToLower(vStr)) {
+				anyMatched = true
+				break ValuesLoop
+			}
 
-				if !invertQuery && isEqual || invertQuery && !isEqual {
+		case `gt`, `lt`, `gte`, `lte`:
+			cmpValueF := typeutil.Float(cmpValue)
+			vF := typeutil.Float(vI)
+
+			switch criterion.Operator {
+			case `gt`:
+				if cmpValueF > vF {
 					anyMatched = true
 					break ValuesLoop
 				}
-
-			case `prefix`:
-				if strings.HasPrefix(strings.ToLower(cmpValueS), strings.ToLower(vStr)) {
+			case `gte`:
+				if cmpValueF >= vF {
 					anyMatched = true
 					break ValuesLoop
 				}
-
-			case `suffix`:
-				if strings.HasSuffix(strings.ToLower(cmpValueS), strings.ToLower(vStr)) {
+			case `lt`:
+				if cmpValueF < vF {
 					anyMatched = true
 					break ValuesLoop
 				}
-
-			case `contains`:
-				if strings.Contains(strings.ToLower(cmpValueS), strings.ToLower(vStr)) {
+			case `lte`:
+				if cmpValueF <= vF {
 					anyMatched = true
 					break ValuesLoop
 				}
+			}
 
-			case `gt`, `lt`, `gte`, `lte`:
-				cmpValueF := typeutil.Float(cmpValue)
-				vF := typeutil.Float(vI)
-
-				switch criterion.Operator {
-				case `gt`:
-					if cmpValueF > vF {
-						anyMatched = true
-						break ValuesLoop
-					}
-				case `gte`:
-					if cmpValueF >= vF {
-						anyMatched = true
-						break ValuesLoop
-					}
-				case `lt`:
-					if cmpValueF < vF {
-						anyMatched = true
-						break ValuesLoop
-					}
-				case `lte`:
-					if cmpValueF <= vF {
+		case `near`:
+			if cmpValue != nil {
+				if near, err := ParseGeoDistance(vI); err == nil {
+					if point, err := dal.ParseGeopoint(cmpValue); err == nil && near.Contains(point) {
 						anyMatched = true
 						break ValuesLoop
 					}
 				}
-			default:
-				return false
 			}
+		default:
+			return false
 		}
+	}
 
-		// if none of the values matched, the criterion is false
-		if !anyMatched {
-			return false
+	// if none of the values matched, the criterion is false
+	return anyMatched
+}
+
+// whether an array field contains (or, for not-in-array, doesn't contain) any of the criterion's
+// values, or whether an object field has any of the keys given as values
+func (self *Filter) matchesContainment(record *dal.Record, criterion Criterion) bool {
+	var value = record.Get(criterion.Field)
+	var members []string
+
+	if criterion.Operator == `contains-key` {
+		if typeutil.IsMap(value) {
+			members = maputil.StringKeys(value)
+		}
+	} else if typeutil.IsArray(value) {
+		members = sliceutil.Stringify(value)
+	}
+
+	for _, vI := range criterion.Values {
+		var want = typeutil.String(vI)
+
+		for _, member := range members {
+			if member == want {
+				return (criterion.Operator != `not-in-array`)
+			}
 		}
 	}
 
-	return true
+	return (criterion.Operator == `not-in-array`)
+}
+
+// compare IDs numerically if both are numbers, otherwise lexically
+func idIsAfter(id interface{}, after interface{}) bool {
+	var idS = typeutil.String(id)
+	var afterS = typeutil.String(after)
+
+	if idF, err := strconv.ParseFloat(idS, 64); err == nil {
+		if afterF, err := strconv.ParseFloat(afterS, 64); err == nil {
+			return idF > afterF
+		}
+	}
+
+	return idS > afterS
 }
 
 func IsExactMatchOperator(operator string) bool {
@@ -700,6 +879,17 @@ func IsInvertingOperator(operator string) bool {
 	return false
 }
 
+// Returns whether the given operator tests the contents of an array or object field, rather than
+// comparing the field's value as a whole.
+func IsContainmentOperator(operator string) bool {
+	switch operator {
+	case `in-array`, `not-in-array`, `contains-key`:
+		return true
+	}
+
+	return false
+}
+
 func SplitModifierToken(in string) (string, string) {
 	parts := strings.SplitN(in, ModifierDelimiter, 2)
 
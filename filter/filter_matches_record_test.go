@@ -57,3 +57,93 @@ func TestFilterMatchesRecord(t *testing.T) {
 	assert.True(MustParse(`name/Golden rod`).MatchesRecord(dal.NewRecord(1).Set(`name`, `Golden rod`)))
 	assert.True(MustParse(`name/like:golden rod`).MatchesRecord(dal.NewRecord(1).Set(`name`, `Golden rod`)))
 }
+
+func TestFilterMatchesRecordAfter(t *testing.T) {
+	assert := require.New(t)
+
+	f := All()
+	f.After = 9
+
+	assert.False(f.MatchesRecord(dal.NewRecord(1)))
+	assert.False(f.MatchesRecord(dal.NewRecord(9)))
+	assert.True(f.MatchesRecord(dal.NewRecord(10)))
+	assert.True(f.MatchesRecord(dal.NewRecord(`10`)))
+
+	f = MustParse(`name/Bob`)
+	f.After = `b`
+
+	assert.False(f.MatchesRecord(dal.NewRecord(`a`).Set(`name`, `Bob`)))
+	assert.True(f.MatchesRecord(dal.NewRecord(`c`).Set(`name`, `Bob`)))
+	assert.False(f.MatchesRecord(dal.NewRecord(`c`).Set(`name`, `Frank`)))
+}
+
+func TestFilterMatchesRecordGroups(t *testing.T) {
+	assert := require.New(t)
+
+	var record = dal.NewRecord(1).Set(`a`, 1).Set(`b`, 5).Set(`c`, 3)
+
+	assert.True(MustParse(`(a/1|b/2)/and/(c/3)`).MatchesRecord(record))
+	assert.True(MustParse(`(a/9|b/5)/and/(c/3)`).MatchesRecord(record))
+	assert.False(MustParse(`(a/9|b/2)/and/(c/3)`).MatchesRecord(record))
+	assert.False(MustParse(`(a/1|b/2)/and/(c/9)`).MatchesRecord(record))
+	assert.True(MustParse(`(a/9)/or/(c/3)`).MatchesRecord(record))
+	assert.False(MustParse(`(a/9)/or/(c/9)`).MatchesRecord(record))
+	assert.True(MustParse(`(a/9|(b/5/c/3))`).MatchesRecord(record))
+	assert.False(MustParse(`(a/9|(b/5/c/9))`).MatchesRecord(record))
+
+	// OR conjunctions without groups
+	f := MustParse(`a/9/c/3`)
+	assert.False(f.MatchesRecord(record))
+
+	f.Conjunction = OrConjunction
+	assert.True(f.MatchesRecord(record))
+}
+
+func TestFilterMatchesRecordContainment(t *testing.T) {
+	assert := require.New(t)
+
+	var record = dal.NewRecord(1).Set(`tags`, []interface{}{`red`, `blue`}).Set(`config`, map[string]interface{}{
+		`enabled`: true,
+	})
+
+	assert.True(MustParse(`tags/in-array:red`).MatchesRecord(record))
+	assert.True(MustParse(`tags/in-array:green|blue`).MatchesRecord(record))
+	assert.False(MustParse(`tags/in-array:green`).MatchesRecord(record))
+	assert.True(MustParse(`tags/not-in-array:green`).MatchesRecord(record))
+	assert.False(MustParse(`tags/not-in-array:green|red`).MatchesRecord(record))
+	assert.True(MustParse(`nothing/not-in-array:red`).MatchesRecord(record))
+	assert.True(MustParse(`config/contains-key:enabled`).MatchesRecord(record))
+	assert.False(MustParse(`config/contains-key:disabled`).MatchesRecord(record))
+	assert.False(MustParse(`tags/contains-key:red`).MatchesRecord(record))
+}
+
+func TestFilterMatchesRecordNear(t *testing.T) {
+	assert := require.New(t)
+
+	var record = dal.NewRecord(1).Set(`location`, dal.Geopoint{
+		Latitude:  40.758,
+		Longitude: -73.9855,
+	})
+
+	assert.True(MustParse(`location/near:40.7128,-74.006,6000`).MatchesRecord(record))
+	assert.True(MustParse(`location/near:40.7128,-74.006,6km`).MatchesRecord(record))
+	assert.False(MustParse(`location/near:40.7128,-74.006,5km`).MatchesRecord(record))
+	assert.False(MustParse(`location/near:51.5074,-0.1278,100mi`).MatchesRecord(record))
+	assert.True(MustParse(`location/near:51.5074,-0.1278,100mi|40.7128,-74.006,4mi`).MatchesRecord(record))
+	assert.False(MustParse(`nowhere/near:40.7128,-74.006,6000`).MatchesRecord(record))
+	assert.False(MustParse(`location/near:40.7128,-74.006`).MatchesRecord(record))
+
+	// points stored in other representations are matched too
+	record.Set(`location`, `40.758,-73.9855`)
+	assert.True(MustParse(`location/near:40.7128,-74.006,6000`).MatchesRecord(record))
+
+	near, err := ParseGeoDistance(`40.7128,-74.006,1.5km`)
+	assert.NoError(err)
+	assert.Equal(1500.0, near.Radius)
+
+	_, err = ParseGeoDistance(`40.7128,-74.006,5parsecs`)
+	assert.Error(err)
+
+	_, err = ParseGeoDistance(`40.7128,-74.006,-5`)
+	assert.Error(err)
+}
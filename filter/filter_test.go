@@ -266,3 +266,77 @@ func TestFilterParsePtrToStruct(t *testing.T) {
 	assert.False(ok)
 	assert.Nil(values)
 }
+
+func TestFilterParseGroups(t *testing.T) {
+	assert := require.New(t)
+
+	f, err := Parse(`(a/1|b/2)/and/(c/3)`)
+	assert.NoError(err)
+	assert.Equal(AndConjunction, f.Conjunction)
+	assert.Len(f.Criteria, 1)
+	assert.Equal(`c`, f.Criteria[0].Field)
+	assert.Len(f.Groups, 1)
+	assert.EqualValues(OrConjunction, f.Groups[0].Conjunction)
+	assert.Equal([]string{`a`, `b`}, f.Groups[0].Fields())
+	assert.Equal([]string{`c`, `a`, `b`}, f.CriteriaFields())
+	assert.Equal(`auto:c/3/and/(auto:a/1|auto:b/2)`, f.String())
+
+	// the string form parses back into the same structure
+	f2, err := Parse(f.String())
+	assert.NoError(err)
+	assert.Equal(f.Criteria, f2.Criteria)
+	assert.Equal(f.Groups, f2.Groups)
+
+	// bare values within a group are additional values of the preceding criterion
+	f, err = Parse(`(a/1|2|b/3)`)
+	assert.NoError(err)
+	assert.Len(f.Groups, 1)
+	assert.Len(f.Groups[0].Criteria, 2)
+	assert.Equal([]interface{}{`1`, `2`}, f.Groups[0].Criteria[0].Values)
+	assert.Equal([]interface{}{`3`}, f.Groups[0].Criteria[1].Values)
+
+	// groups nest, and several criteria in a group member are ANDed together
+	f, err = Parse(`(a/1)/or/((b/2/c/3)|d/4)`)
+	assert.NoError(err)
+	assert.EqualValues(OrConjunction, f.Conjunction)
+	assert.Len(f.Criteria, 1)
+	assert.Len(f.Groups, 1)
+	assert.Len(f.Groups[0].Criteria, 1)
+	assert.Len(f.Groups[0].Groups, 1)
+	assert.Equal(AndConjunction, f.Groups[0].Groups[0].Conjunction)
+	assert.Equal([]string{`b`, `c`}, f.Groups[0].Groups[0].Fields())
+
+	// values that look like groups are left alone
+	f, err = Parse(`phone/(555)`)
+	assert.NoError(err)
+	assert.Empty(f.Groups)
+	assert.Equal([]interface{}{`(555)`}, f.Criteria[0].Values)
+
+	_, err = Parse(`(a/1)/and/(b/2)/or/(c/3)`)
+	assert.Error(err)
+
+	_, err = Parse(`(a/1|b/2`)
+	assert.Error(err)
+
+	_, err = Parse(`(a/1)/and`)
+	assert.Error(err)
+}
+
+func TestFilterGroupsProgrammatic(t *testing.T) {
+	assert := require.New(t)
+
+	f := All().AddGroups(
+		Or(
+			Criterion{Field: `a`, Values: []interface{}{1}},
+			Criterion{Field: `b`, Values: []interface{}{2}},
+		).WithGroups(
+			And(
+				Criterion{Field: `c`, Values: []interface{}{3}},
+				Criterion{Field: `d`, Values: []interface{}{4}},
+			),
+		),
+	)
+
+	assert.False(f.IsMatchAll())
+	assert.Equal(`(a/1|b/2|(c/3/d/4))`, f.String())
+}
@@ -1,5 +1,10 @@
 package filter
 
+import (
+	"fmt"
+	"strings"
+)
+
 type IGenerator interface {
 	Initialize(string) error
 	Finalize(*Filter) error
@@ -16,12 +21,40 @@ type IGenerator interface {
 	Reset()
 }
 
+// Implemented by generators that have a native mechanism for resuming a query after a given
+// record (e.g.: Elasticsearch's search_after).  Generators that don't implement this have cursor
+// pagination expressed as a "greater than" criterion on the identity field.
+type CursorGenerator interface {
+	WithCursor(field string, after interface{}) error
+}
+
+// Implemented by generators that can natively join other collections to the one being queried.
+// Rendering a filter that declares joins with a generator that doesn't implement this is an error.
+type JoinGenerator interface {
+	WithJoin(join Join) error
+}
+
+// Implemented by generators that can render nested groups of criteria, each combined using its own
+// conjunction.  Rendering a filter that contains groups with a generator that doesn't implement
+// this is an error.
+type GroupGenerator interface {
+	WithGroup(group Group) error
+}
+
 type Generator struct {
 	IGenerator
 	payload []byte
 }
 
 func Render(generator IGenerator, collectionName string, filter *Filter) ([]byte, error) {
+	if filter.After != nil {
+		if cursored, err := cursorFilter(filter); err == nil {
+			filter = cursored
+		} else {
+			return nil, err
+		}
+	}
+
 	if err := generator.Initialize(collectionName); err != nil {
 		return nil, err
 	}
@@ -33,6 +66,19 @@ func Render(generator IGenerator, collectionName string, filter *Filter) ([]byte
 		}
 	}
 
+	//  add joins
+	if len(filter.Joins) > 0 {
+		if jg, ok := generator.(JoinGenerator); ok {
+			for _, join := range filter.Joins {
+				if err := jg.WithJoin(join); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			return nil, fmt.Errorf("%T does not support joins", generator)
+		}
+	}
+
 	//  add fields
 	for _, fieldName := range filter.Fields {
 		if filter.IdentityField != `` && fieldName == `id` {
@@ -55,6 +101,34 @@ func Render(generator IGenerator, collectionName string, filter *Filter) ([]byte
 		}
 	}
 
+	//  add groups
+	if len(filter.Groups) > 0 {
+		if gg, ok := generator.(GroupGenerator); ok {
+			for _, group := range filter.Groups {
+				if err := gg.WithGroup(withIdentityField(group, filter.IdentityField)); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			return nil, fmt.Errorf("%T does not support grouped criteria", generator)
+		}
+	}
+
+	//  add the cursor position
+	if filter.After != nil {
+		if cg, ok := generator.(CursorGenerator); ok {
+			if err := cg.WithCursor(filter.IdentityField, filter.After); err != nil {
+				return nil, err
+			}
+		} else if err := generator.WithCriterion(Criterion{
+			Field:    filter.IdentityField,
+			Operator: `gt`,
+			Values:   []interface{}{filter.After},
+		}); err != nil {
+			return nil, err
+		}
+	}
+
 	//  finalize the payload
 	if err := generator.Finalize(filter); err != nil {
 		return nil, err
@@ -87,3 +161,55 @@ func (self *Generator) Payload() []byte {
 func (self *Generator) Finalize(_ *Filter) error {
 	return nil
 }
+
+// returns a copy of the given filter suitable for keyset pagination: results are always ordered
+// by the identity field, and offsets are replaced by the cursor position.
+func cursorFilter(filter *Filter) (*Filter, error) {
+	var cursored = Copy(filter)
+
+	if cursored.IdentityField == `` {
+		cursored.IdentityField = DefaultIdentityField
+	}
+
+	if cursored.Conjunction == OrConjunction {
+		return nil, fmt.Errorf("cursor pagination cannot be used with an OR conjunction")
+	}
+
+	for _, sort := range cursored.Sort {
+		var field = strings.TrimPrefix(strings.TrimPrefix(sort, SortDescending), SortAscending)
+
+		if field != `id` && field != cursored.IdentityField {
+			return nil, fmt.Errorf("cursor pagination requires results to be sorted by %q, not %q", cursored.IdentityField, field)
+		} else if strings.HasPrefix(sort, SortDescending) {
+			return nil, fmt.Errorf("cursor pagination requires results to be sorted in ascending order")
+		}
+	}
+
+	cursored.Sort = []string{cursored.IdentityField}
+	cursored.Offset = 0
+
+	return &cursored, nil
+}
+
+// returns a copy of the given group with criteria on the "id" field applied to the identity field
+func withIdentityField(group Group, identityField string) Group {
+	var out = Group{
+		Conjunction: group.Conjunction,
+		Criteria:    make([]Criterion, len(group.Criteria)),
+		Groups:      make([]Group, len(group.Groups)),
+	}
+
+	for i, criterion := range group.Criteria {
+		if identityField != `` && criterion.Field == `id` {
+			criterion.Field = identityField
+		}
+
+		out.Criteria[i] = criterion
+	}
+
+	for i, subgroup := range group.Groups {
+		out.Groups[i] = withIdentityField(subgroup, identityField)
+	}
+
+	return out
+}
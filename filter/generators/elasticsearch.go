@@ -12,14 +12,16 @@ var DefaultMinVersionCompat = float64(6)
 
 type Elasticsearch struct {
 	filter.Generator
-	collection  string
-	fields      []string
-	criteria    []map[string]interface{}
-	options     map[string]interface{}
-	values      []interface{}
-	facetFields []string
-	aggregateBy []filter.Aggregate
-	compat      float64
+	collection   string
+	fields       []string
+	criteria     []map[string]interface{}
+	options      map[string]interface{}
+	values       []interface{}
+	facetFields  []string
+	aggregateBy  []filter.Aggregate
+	compat       float64
+	after        interface{}
+	searchFields []string
 }
 
 func NewElasticsearchGenerator() *Elasticsearch {
@@ -33,6 +35,12 @@ func (self *Elasticsearch) SetMinimumVersion(v float64) {
 	self.compat = v
 }
 
+// Set the fields that full-text criteria on filter.SearchAllField are matched against.  Fields may
+// carry a boost (e.g.: "title^2").  If no search fields are set, all fields are searched.
+func (self *Elasticsearch) SetSearchFields(fields ...string) {
+	self.searchFields = fields
+}
+
 func (self *Elasticsearch) Initialize(collectionName string) error {
 	self.Reset()
 	self.collection = collectionName
@@ -40,6 +48,7 @@ func (self *Elasticsearch) Initialize(collectionName string) error {
 	self.criteria = make([]map[string]interface{}, 0)
 	self.options = make(map[string]interface{})
 	self.values = make([]interface{}, 0)
+	self.after = nil
 
 	return nil
 }
@@ -81,7 +90,16 @@ func (self *Elasticsearch) Finalize(flt *filter.Filter) error {
 		}
 	}
 
-	if len(flt.Sort) > 0 {
+	if self.after != nil {
+		// documents are resumed by their ID, which is stored in _id rather than in the source
+		delete(payload, `from`)
+		payload[`sort`] = []interface{}{
+			map[string]interface{}{
+				`_id`: `asc`,
+			},
+		}
+		payload[`search_after`] = []interface{}{self.after}
+	} else if len(flt.Sort) > 0 {
 		var sorts = make([]interface{}, 0)
 
 		for _, sort := range flt.Sort {
@@ -114,6 +132,12 @@ func (self *Elasticsearch) Finalize(flt *filter.Filter) error {
 	return nil
 }
 
+// Resume results after the document with the given ID using search_after.
+func (self *Elasticsearch) WithCursor(_ string, after interface{}) error {
+	self.after = after
+	return nil
+}
+
 func (self *Elasticsearch) WithField(field string) error {
 	self.fields = append(self.fields, field)
 	return nil
@@ -143,6 +167,73 @@ func (self *Elasticsearch) GetValues() []interface{} {
 }
 
 func (self *Elasticsearch) WithCriterion(criterion filter.Criterion) error {
+	if c, err := self.criterionQuery(criterion); err == nil {
+		self.criteria = append(self.criteria, c)
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Adds a group of criteria, which is rendered as a nested bool query whose clauses must all
+// match (for AND groups) or of which at least one should match (for OR groups).
+func (self *Elasticsearch) WithGroup(group filter.Group) error {
+	if q, err := self.groupQuery(group); err == nil {
+		if q != nil {
+			self.criteria = append(self.criteria, q)
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *Elasticsearch) groupQuery(group filter.Group) (map[string]interface{}, error) {
+	var queries = make([]map[string]interface{}, 0)
+
+	for _, criterion := range group.Criteria {
+		if q, err := self.criterionQuery(criterion); err == nil {
+			queries = append(queries, q)
+		} else {
+			return nil, err
+		}
+	}
+
+	for _, subgroup := range group.Groups {
+		if q, err := self.groupQuery(subgroup); err == nil {
+			if q != nil {
+				queries = append(queries, q)
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	switch len(queries) {
+	case 0:
+		return nil, nil
+	case 1:
+		return queries[0], nil
+	}
+
+	if group.Conjunction == filter.OrConjunction {
+		return map[string]interface{}{
+			`bool`: map[string]interface{}{
+				`should`:               queries,
+				`minimum_should_match`: 1,
+			},
+		}, nil
+	} else {
+		return map[string]interface{}{
+			`bool`: map[string]interface{}{
+				`must`: queries,
+			},
+		}, nil
+	}
+}
+
+func (self *Elasticsearch) criterionQuery(criterion filter.Criterion) (map[string]interface{}, error) {
 	var c map[string]interface{}
 	var err error
 
@@ -161,15 +252,15 @@ func (self *Elasticsearch) WithCriterion(criterion filter.Criterion) error {
 		c, err = esCriterionOperatorRange(self, criterion, criterion.Operator)
 	case `fulltext`:
 		c, err = esCriterionOperatorFulltext(self, criterion)
+	case `in-array`, `not-in-array`:
+		c, err = esCriterionOperatorArray(self, criterion)
+	case `contains-key`:
+		c, err = esCriterionOperatorContainsKey(self, criterion)
+	case `near`:
+		c, err = esCriterionOperatorNear(self, criterion)
 	default:
-		return fmt.Errorf("Unimplemented operator '%s'", criterion.Operator)
+		return nil, fmt.Errorf("Unimplemented operator '%s'", criterion.Operator)
 	}
 
-	if err != nil {
-		return err
-	} else {
-		self.criteria = append(self.criteria, c)
-	}
-
-	return nil
+	return c, err
 }
@@ -0,0 +1,139 @@
+package generators
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElasticsearchSearchFields(t *testing.T) {
+	assert := require.New(t)
+
+	renderQueryString := func(gen *Elasticsearch, f *filter.Filter) map[string]interface{} {
+		data, err := filter.Render(gen, `posts`, f)
+		assert.NoError(err)
+
+		var payload map[string]interface{}
+		assert.NoError(json.Unmarshal(data, &payload))
+
+		must := payload[`query`].(map[string]interface{})[`bool`].(map[string]interface{})[`must`].([]interface{})
+		assert.Len(must, 1)
+
+		return must[0].(map[string]interface{})[`query_string`].(map[string]interface{})
+	}
+
+	// searching a specific field
+	qs := renderQueryString(NewElasticsearchGenerator(), filter.MustParse(`title/fulltext:hello world`))
+	assert.Equal(`hello world`, qs[`query`])
+	assert.Equal(`title`, qs[`default_field`])
+	assert.Nil(qs[`fields`])
+
+	// searching all fields when no search fields are declared
+	qs = renderQueryString(NewElasticsearchGenerator(), filter.All().Search(`hello world`))
+	assert.Equal(`*`, qs[`default_field`])
+
+	// searching the declared search fields
+	gen := NewElasticsearchGenerator()
+	gen.SetSearchFields(`title.search^2`, `body.search`)
+
+	qs = renderQueryString(gen, filter.All().Search(`hello world`))
+	assert.Equal(`hello world`, qs[`query`])
+	assert.Nil(qs[`default_field`])
+	assert.Equal([]interface{}{`title.search^2`, `body.search`}, qs[`fields`])
+}
+
+func TestElasticsearchGroups(t *testing.T) {
+	assert := require.New(t)
+
+	data, err := filter.Render(NewElasticsearchGenerator(), `posts`, filter.MustParse(`(a/1|b/2)/and/(c/3)`))
+	assert.NoError(err)
+
+	var payload map[string]interface{}
+	assert.NoError(json.Unmarshal(data, &payload))
+
+	must := payload[`query`].(map[string]interface{})[`bool`].(map[string]interface{})[`must`].([]interface{})
+	assert.Len(must, 2)
+
+	group := must[1].(map[string]interface{})[`bool`].(map[string]interface{})
+	assert.Len(group[`should`], 2)
+	assert.EqualValues(1, group[`minimum_should_match`])
+	assert.Nil(group[`must`])
+}
+
+func TestElasticsearchContainment(t *testing.T) {
+	assert := require.New(t)
+
+	data, err := filter.Render(NewElasticsearchGenerator(), `posts`, filter.MustParse(`tags/in-array:red|blue/tags/not-in-array:green/meta/contains-key:author`))
+	assert.NoError(err)
+
+	var payload map[string]interface{}
+	assert.NoError(json.Unmarshal(data, &payload))
+
+	must := payload[`query`].(map[string]interface{})[`bool`].(map[string]interface{})[`must`].([]interface{})
+	assert.Len(must, 3)
+
+	assert.Equal(map[string]interface{}{
+		`terms`: map[string]interface{}{
+			`tags`: []interface{}{`red`, `blue`},
+		},
+	}, must[0])
+
+	assert.Equal(map[string]interface{}{
+		`bool`: map[string]interface{}{
+			`must_not`: map[string]interface{}{
+				`terms`: map[string]interface{}{
+					`tags`: []interface{}{`green`},
+				},
+			},
+		},
+	}, must[1])
+
+	assert.Equal(map[string]interface{}{
+		`exists`: map[string]interface{}{
+			`field`: `meta.author`,
+		},
+	}, must[2])
+}
+
+func TestElasticsearchNear(t *testing.T) {
+	assert := require.New(t)
+
+	data, err := filter.Render(NewElasticsearchGenerator(), `places`, filter.MustParse(`location/near:40.7128,-74.006,1.5km|51.5074,-0.1278,500`))
+	assert.NoError(err)
+
+	var payload map[string]interface{}
+	assert.NoError(json.Unmarshal(data, &payload))
+
+	must := payload[`query`].(map[string]interface{})[`bool`].(map[string]interface{})[`must`].([]interface{})
+	assert.Len(must, 1)
+
+	assert.Equal(map[string]interface{}{
+		`bool`: map[string]interface{}{
+			`should`: []interface{}{
+				map[string]interface{}{
+					`geo_distance`: map[string]interface{}{
+						`distance`: `1500m`,
+						`location`: map[string]interface{}{
+							`lat`: 40.7128,
+							`lon`: -74.006,
+						},
+					},
+				},
+				map[string]interface{}{
+					`geo_distance`: map[string]interface{}{
+						`distance`: `500m`,
+						`location`: map[string]interface{}{
+							`lat`: 51.5074,
+							`lon`: -0.1278,
+						},
+					},
+				},
+			},
+		},
+	}, must[0])
+
+	_, err = filter.Render(NewElasticsearchGenerator(), `places`, filter.MustParse(`location/near:40.7128,-74.006`))
+	assert.Error(err)
+}
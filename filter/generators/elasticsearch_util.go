@@ -2,6 +2,7 @@ package generators
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/ghetzel/go-stockutil/typeutil"
 	"github.com/ghetzel/pivot/v3/filter"
@@ -267,13 +268,22 @@ func esCriterionOperatorFulltext(gen *Elasticsearch, criterion filter.Criterion)
 	for _, value := range criterion.Values {
 		gen.values = append(gen.values, value)
 
+		var qs = map[string]interface{}{
+			`query`:            typeutil.String(value),
+			`default_operator`: defop,
+			`lenient`:          true,
+		}
+
+		if criterion.Field != filter.SearchAllField {
+			qs[`default_field`] = criterion.Field
+		} else if len(gen.searchFields) > 0 {
+			qs[`fields`] = gen.searchFields
+		} else {
+			qs[`default_field`] = `*`
+		}
+
 		or_queries = append(or_queries, map[string]interface{}{
-			`query_string`: map[string]interface{}{
-				`query`:            typeutil.String(value),
-				`default_field`:    criterion.Field,
-				`default_operator`: defop,
-				`lenient`:          true,
-			},
+			`query_string`: qs,
 		})
 	}
 
@@ -292,3 +302,97 @@ func esCriterionOperatorFulltext(gen *Elasticsearch, criterion filter.Criterion)
 
 	return c, nil
 }
+
+func esCriterionOperatorArray(gen *Elasticsearch, criterion filter.Criterion) (map[string]interface{}, error) {
+	var c = make(map[string]interface{})
+
+	if len(criterion.Values) == 0 {
+		return c, fmt.Errorf("The %s criterion must have at least one value", criterion.Operator)
+	}
+
+	gen.values = append(gen.values, criterion.Values...)
+
+	// a terms query on an array field matches documents where any element is one of the values
+	var terms = map[string]interface{}{
+		`terms`: map[string]interface{}{
+			criterion.Field: criterion.Values,
+		},
+	}
+
+	if criterion.Operator == `not-in-array` {
+		c[`bool`] = map[string]interface{}{
+			`must_not`: terms,
+		}
+	} else {
+		c = terms
+	}
+
+	return c, nil
+}
+
+func esCriterionOperatorContainsKey(gen *Elasticsearch, criterion filter.Criterion) (map[string]interface{}, error) {
+	var c = make(map[string]interface{})
+	var exists = make([]map[string]interface{}, 0)
+
+	if len(criterion.Values) == 0 {
+		return c, fmt.Errorf("The contains-key criterion must have at least one value")
+	}
+
+	for _, key := range criterion.Values {
+		gen.values = append(gen.values, key)
+
+		exists = append(exists, map[string]interface{}{
+			`exists`: map[string]interface{}{
+				`field`: criterion.Field + `.` + typeutil.String(key),
+			},
+		})
+	}
+
+	if len(exists) == 1 {
+		c = exists[0]
+	} else {
+		c[`bool`] = map[string]interface{}{
+			`should`: exists,
+		}
+	}
+
+	return c, nil
+}
+
+func esCriterionOperatorNear(gen *Elasticsearch, criterion filter.Criterion) (map[string]interface{}, error) {
+	var c = make(map[string]interface{})
+	var distances = make([]map[string]interface{}, 0)
+
+	if len(criterion.Values) == 0 {
+		return c, fmt.Errorf("The near criterion must have at least one value")
+	}
+
+	for _, value := range criterion.Values {
+		if near, err := filter.ParseGeoDistance(value); err == nil {
+			gen.values = append(gen.values, value)
+
+			// the field must be mapped as a geo_point for this query to match anything
+			distances = append(distances, map[string]interface{}{
+				`geo_distance`: map[string]interface{}{
+					`distance`: strconv.FormatFloat(near.Radius, 'f', -1, 64) + `m`,
+					criterion.Field: map[string]interface{}{
+						`lat`: near.Point.Latitude,
+						`lon`: near.Point.Longitude,
+					},
+				},
+			})
+		} else {
+			return c, err
+		}
+	}
+
+	if len(distances) == 1 {
+		c = distances[0]
+	} else {
+		c[`bool`] = map[string]interface{}{
+			`should`: distances,
+		}
+	}
+
+	return c, nil
+}
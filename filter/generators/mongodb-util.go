@@ -5,6 +5,7 @@ import (
 	"regexp"
 
 	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/pivot/v3/dal"
 	"github.com/ghetzel/pivot/v3/filter"
 )
 
@@ -191,3 +192,91 @@ func mongoCriterionOperatorRange(gen *MongoDB, criterion filter.Criterion, opera
 
 	return c, nil
 }
+
+func mongoCriterionOperatorArray(gen *MongoDB, criterion filter.Criterion) (map[string]interface{}, error) {
+	c := make(map[string]interface{})
+
+	if len(criterion.Values) == 0 {
+		return nil, fmt.Errorf("The %s criterion must have at least one value", criterion.Operator)
+	}
+
+	for _, value := range criterion.Values {
+		gen.values = append(gen.values, value)
+	}
+
+	// $in and $nin match array fields against each of the array's elements
+	if criterion.Operator == `not-in-array` {
+		c[criterion.Field] = map[string]interface{}{
+			`$nin`: criterion.Values,
+		}
+	} else {
+		c[criterion.Field] = map[string]interface{}{
+			`$in`: criterion.Values,
+		}
+	}
+
+	return c, nil
+}
+
+func mongoCriterionOperatorContainsKey(gen *MongoDB, criterion filter.Criterion) (map[string]interface{}, error) {
+	exists := make([]map[string]interface{}, 0)
+
+	if len(criterion.Values) == 0 {
+		return nil, fmt.Errorf("The contains-key criterion must have at least one value")
+	}
+
+	for _, key := range criterion.Values {
+		gen.values = append(gen.values, key)
+
+		exists = append(exists, map[string]interface{}{
+			fmt.Sprintf("%v.%v", criterion.Field, key): map[string]interface{}{
+				`$exists`: true,
+			},
+		})
+	}
+
+	if len(exists) == 1 {
+		return exists[0], nil
+	}
+
+	return map[string]interface{}{
+		`$or`: exists,
+	}, nil
+}
+
+func mongoCriterionOperatorNear(gen *MongoDB, criterion filter.Criterion) (map[string]interface{}, error) {
+	within := make([]map[string]interface{}, 0)
+
+	if len(criterion.Values) == 0 {
+		return nil, fmt.Errorf("The near criterion must have at least one value")
+	}
+
+	for _, value := range criterion.Values {
+		if near, err := filter.ParseGeoDistance(value); err == nil {
+			gen.values = append(gen.values, value)
+
+			// $centerSphere takes its radius in radians.  Unlike $nearSphere, $geoWithin doesn't
+			// sort its results, so it can be combined with other criteria, sorting, and counts.
+			within = append(within, map[string]interface{}{
+				criterion.Field: map[string]interface{}{
+					`$geoWithin`: map[string]interface{}{
+						`$centerSphere`: []interface{}{
+							[]float64{near.Point.Longitude, near.Point.Latitude},
+							near.Radius / dal.EarthRadiusMeters,
+						},
+					},
+				},
+			})
+		} else {
+			return nil, err
+		}
+	}
+
+	if len(within) == 1 {
+		return within[0], nil
+	}
+
+	return map[string]interface{}{
+		`$or`: within,
+	}, nil
+}
@@ -107,6 +107,67 @@ func (self *MongoDB) GetValues() []interface{} {
 }
 
 func (self *MongoDB) WithCriterion(criterion filter.Criterion) error {
+	if c, err := self.criterionQuery(criterion); err == nil {
+		self.criteria = append(self.criteria, c)
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Adds a group of criteria, which is rendered as a nested $and or $or query.
+func (self *MongoDB) WithGroup(group filter.Group) error {
+	if q, err := self.groupQuery(group); err == nil {
+		if q != nil {
+			self.criteria = append(self.criteria, q)
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *MongoDB) groupQuery(group filter.Group) (map[string]interface{}, error) {
+	var queries = make([]map[string]interface{}, 0)
+
+	for _, criterion := range group.Criteria {
+		if q, err := self.criterionQuery(criterion); err == nil {
+			queries = append(queries, q)
+		} else {
+			return nil, err
+		}
+	}
+
+	for _, subgroup := range group.Groups {
+		if q, err := self.groupQuery(subgroup); err == nil {
+			if q != nil {
+				queries = append(queries, q)
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	switch len(queries) {
+	case 0:
+		return nil, nil
+	case 1:
+		return queries[0], nil
+	}
+
+	if group.Conjunction == filter.OrConjunction {
+		return map[string]interface{}{
+			`$or`: queries,
+		}, nil
+	} else {
+		return map[string]interface{}{
+			`$and`: queries,
+		}, nil
+	}
+}
+
+func (self *MongoDB) criterionQuery(criterion filter.Criterion) (map[string]interface{}, error) {
 	var c map[string]interface{}
 	var err error
 
@@ -130,15 +191,15 @@ func (self *MongoDB) WithCriterion(criterion filter.Criterion) error {
 		c, err = mongoCriterionOperatorPattern(self, criterion.Operator, criterion)
 	case `gt`, `gte`, `lt`, `lte`, `range`:
 		c, err = mongoCriterionOperatorRange(self, criterion, criterion.Operator)
+	case `in-array`, `not-in-array`:
+		c, err = mongoCriterionOperatorArray(self, criterion)
+	case `contains-key`:
+		c, err = mongoCriterionOperatorContainsKey(self, criterion)
+	case `near`:
+		c, err = mongoCriterionOperatorNear(self, criterion)
 	default:
-		return fmt.Errorf("Unimplemented operator '%s'", criterion.Operator)
-	}
-
-	if err != nil {
-		return err
-	} else {
-		self.criteria = append(self.criteria, c)
+		return nil, fmt.Errorf("Unimplemented operator '%s'", criterion.Operator)
 	}
 
-	return nil
+	return c, err
 }
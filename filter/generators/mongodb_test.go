@@ -5,6 +5,7 @@ import (
 
 	"encoding/json"
 
+	"github.com/ghetzel/pivot/v3/dal"
 	"github.com/ghetzel/pivot/v3/filter"
 	"github.com/stretchr/testify/require"
 )
@@ -197,6 +198,63 @@ func TestMongodb(t *testing.T) {
 			},
 			values: []interface{}{int64(7), `ted`},
 		},
+		`tags/in-array:red|blue`: {
+			query: map[string]interface{}{
+				`tags`: map[string]interface{}{
+					`$in`: []interface{}{`red`, `blue`},
+				},
+			},
+			values: []interface{}{`red`, `blue`},
+		},
+		`tags/not-in-array:red`: {
+			query: map[string]interface{}{
+				`tags`: map[string]interface{}{
+					`$nin`: []interface{}{`red`},
+				},
+			},
+			values: []interface{}{`red`},
+		},
+		`location/near:40.7128,-74.006,1km`: {
+			query: map[string]interface{}{
+				`location`: map[string]interface{}{
+					`$geoWithin`: map[string]interface{}{
+						`$centerSphere`: []interface{}{
+							[]interface{}{-74.006, 40.7128},
+							1000 / dal.EarthRadiusMeters,
+						},
+					},
+				},
+			},
+			values: []interface{}{`40.7128,-74.006,1km`},
+		},
+		`config/contains-key:enabled`: {
+			query: map[string]interface{}{
+				`config.enabled`: map[string]interface{}{
+					`$exists`: true,
+				},
+			},
+			values: []interface{}{`enabled`},
+		},
+		`(age/7|name/ted)/and/(enabled/true)`: {
+			query: map[string]interface{}{
+				`$and`: []interface{}{
+					map[string]interface{}{
+						`enabled`: true,
+					},
+					map[string]interface{}{
+						`$or`: []interface{}{
+							map[string]interface{}{
+								`age`: float64(7),
+							},
+							map[string]interface{}{
+								`name`: `ted`,
+							},
+						},
+					},
+				},
+			},
+			values: []interface{}{true, int64(7), `ted`},
+		},
 	}
 
 	for spec, expected := range tests {
@@ -10,7 +10,6 @@ import (
 	"time"
 
 	"github.com/ghetzel/go-stockutil/maputil"
-	"github.com/ghetzel/go-stockutil/rxutil"
 	"github.com/ghetzel/go-stockutil/sliceutil"
 	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/ghetzel/go-stockutil/typeutil"
@@ -18,8 +17,26 @@ import (
 	"github.com/ghetzel/pivot/v3/filter"
 )
 
+// Deprecated: the number of placeholders in a statement is no longer limited when rendering it, so
+// this is ignored.  Statements are split up by the backends according to the database's own limit
+// (see SqlTypeMapping.MaxPlaceholders).
 var SqlMaxPlaceholders = 16384
 
+// The most values rendered in a single IN() list.  Criteria with more values than this are split
+// into several IN() lists, OR'd together (or AND'd, for negated criteria).
+var SqlMaxInValues = 1000
+
+// the sequences surrounding the field name of each value's placeholder (see applyPlaceholders)
+const sqlPlaceholderOpen = "\u2983"
+const sqlPlaceholderClose = "\u2984"
+
+// The suffix appended to a table's name to form the name of the separate table that holds its
+// full-text index, for databases that keep one (see SqlTypeMapping.FulltextTable).
+var SqlFulltextTableSuffix = `_fts`
+
+// escapes the keys of nested fields for inclusion in a string literal
+var sqlNestedKeyEscaper = strings.NewReplacer(`'`, `''`)
+
 type sqlRangeValue struct {
 	lower interface{}
 	upper interface{}
@@ -78,10 +95,25 @@ type SqlTypeMapping struct {
 	NestedFieldNameFormat string                  // map of field name-format strings to wrap fields addressing nested map keys. supercedes FieldNameFormat
 	NestedFieldSeparator  string                  // the string used to denote nesting in a nested field name
 	NestedFieldJoiner     string                  // the string used to re-join all but the first value in a nested field when interpolating into NestedFieldNameFormat
+	NestedFieldCastFormat string                  // if set, format string used to cast nested field values (extracted as text) to the native type of the values they are compared to
+	JsonObjectTypes       bool                    // whether objects and arrays are stored in a native JSON type, which does not accept a length
 	ObjectTypeEncodeFunc  SqlObjectTypeEncodeFunc // function used for encoding objects to a native representation
 	ObjectTypeDecodeFunc  SqlObjectTypeDecodeFunc // function used for decoding objects from native into a destination map
 	ArrayTypeEncodeFunc   SqlArrayTypeEncodeFunc  // function used for encoding arrays to a native representation
 	ArrayTypeDecodeFunc   SqlArrayTypeDecodeFunc  // function used for decoding arrays from native into a destination map
+	OffsetFetchLimits     bool                    // whether limits are expressed as "OFFSET n ROWS FETCH NEXT n ROWS ONLY" instead of "LIMIT n OFFSET n"
+	OutputInserted        bool                    // whether INSERT statements return fields using an "OUTPUT INSERTED" clause instead of "RETURNING"
+	MaxTypeLength         int                     // if set, type lengths greater than this are rendered as "(MAX)"
+	ArrayContainsFormat   string                  // if set, format string used to test whether an array field contains a value; given the field name and a JSON array containing the value
+	ObjectHasKeyFormat    string                  // if set, format string used to test whether an object field has a key; given the field name and the key
+	FulltextFormat        string                  // if set, format string used to match a full-text query; given the field(s) being searched, the query, the full-text table name, and the table name
+	FulltextRankFormat    string                  // format string used to calculate the relevance of a full-text match; given the same arguments as FulltextFormat
+	FulltextFieldsFormat  string                  // if set, format string used to combine all of a collection's search fields into one searchable value; given the comma-separated field names
+	FulltextTable         bool                    // whether full-text queries are matched against a separate table (named with SqlFulltextTableSuffix) rather than the table's own columns
+	GeopointType          string                  // if set, the native type used to store geopoints; otherwise they are stored the same way as objects
+	GeoDistanceFormat     string                  // if set, format string used to test whether a geopoint field is within a distance of a point; given the field name, then placeholders for the longitude, latitude, and radius (in meters)
+	MaxPlaceholders       int                     // if set, the most placeholders the database accepts in a single statement
+	LockRowsClause        string                  // if set, the clause added to SELECT statements that lock the rows they read until the end of the transaction
 }
 
 func (self SqlTypeMapping) String() string {
@@ -151,6 +183,36 @@ var MysqlTypeMapping = SqlTypeMapping{
 	FieldNameFormat:      "`%s`",
 	NestedFieldSeparator: `.`,
 	NestedFieldJoiner:    `.`,
+	ArrayContainsFormat:  "JSON_CONTAINS(CONVERT(%s USING utf8mb4), %s)",
+	ObjectHasKeyFormat:   "JSON_CONTAINS_PATH(CONVERT(%s USING utf8mb4), 'one', CONCAT('$.\"', %s, '\"'))",
+	FulltextFormat:       "MATCH(%[1]s) AGAINST(%[2]s IN NATURAL LANGUAGE MODE)",
+	FulltextRankFormat:   "MATCH(%[1]s) AGAINST(%[2]s IN NATURAL LANGUAGE MODE)",
+	LockRowsClause:       `FOR UPDATE`,
+}
+
+// TiDB is compatible with MySQL, except that it does not support full-text search
+var TidbTypeMapping = SqlTypeMapping{
+	Name:                 `tidb`,
+	StringType:           `VARCHAR`,
+	StringTypeLength:     255,
+	IntegerType:          `BIGINT`,
+	FloatType:            `DECIMAL`,
+	FloatTypeLength:      10,
+	FloatTypePrecision:   8,
+	BooleanType:          `BOOL`,
+	DateTimeType:         `DATETIME`,
+	ObjectType:           `MEDIUMBLOB`,
+	ArrayType:            `MEDIUMBLOB`,
+	RawType:              `MEDIUMBLOB`,
+	PlaceholderFormat:    `?`,
+	PlaceholderArgument:  ``,
+	TableNameFormat:      "`%s`",
+	FieldNameFormat:      "`%s`",
+	NestedFieldSeparator: `.`,
+	NestedFieldJoiner:    `.`,
+	ArrayContainsFormat:  "JSON_CONTAINS(CONVERT(%s USING utf8mb4), %s)",
+	ObjectHasKeyFormat:   "JSON_CONTAINS_PATH(CONVERT(%s USING utf8mb4), 'one', CONCAT('$.\"', %s, '\"'))",
+	LockRowsClause:       `FOR UPDATE`,
 }
 
 var PostgresTypeMapping = SqlTypeMapping{
@@ -169,25 +231,94 @@ var PostgresTypeMapping = SqlTypeMapping{
 	FieldNameFormat:      "%q",
 	NestedFieldSeparator: `.`,
 	NestedFieldJoiner:    `.`,
+	ArrayContainsFormat:  "%s::jsonb @> %s::jsonb",
+	ObjectHasKeyFormat:   "%s::jsonb ? %s",
+	FulltextFormat:       "to_tsvector(%[1]s) @@ websearch_to_tsquery(%[2]s)",
+	FulltextRankFormat:   "ts_rank(to_tsvector(%[1]s), websearch_to_tsquery(%[2]s))",
+	FulltextFieldsFormat: "concat_ws(' ', %s)",
+	GeopointType:         `GEOGRAPHY(Point, 4326)`,
+	GeoDistanceFormat:    "ST_DWithin(%s, ST_SetSRID(ST_MakePoint(%s, %s), 4326)::geography, %s)",
+	LockRowsClause:       `FOR UPDATE`,
 }
 
+// Stores objects and arrays as JSONB (PostgreSQL 9.4+), which allows criteria on nested fields
+// (e.g.: "config.enabled/true") to be evaluated by the database.
 var PostgresJsonTypeMapping = SqlTypeMapping{
-	Name:         `postgres-json`,
-	StringType:   `TEXT`,
-	IntegerType:  `BIGINT`,
-	FloatType:    `NUMERIC`,
-	BooleanType:  `BOOLEAN`,
-	DateTimeType: `TIMESTAMP`,
-	// ObjectType:   `JSONB`, // TODO: implement the JSONB functionality in PostgreSQL 9.2+
-	ObjectType:           `VARCHAR`,
-	ArrayType:            `VARCHAR`,
-	RawType:              `BYTEA`,
+	Name:                  `postgres-json`,
+	StringType:            `TEXT`,
+	IntegerType:           `BIGINT`,
+	FloatType:             `NUMERIC`,
+	BooleanType:           `BOOLEAN`,
+	DateTimeType:          `TIMESTAMP`,
+	ObjectType:            `JSONB`,
+	ArrayType:             `JSONB`,
+	RawType:               `BYTEA`,
+	PlaceholderFormat:     `$%d`,
+	PlaceholderArgument:   `index1`,
+	TableNameFormat:       "%q",
+	FieldNameFormat:       "%q",
+	NestedFieldNameFormat: "%q#>>'{%s}'",
+	NestedFieldSeparator:  `.`,
+	NestedFieldJoiner:     `,`,
+	NestedFieldCastFormat: "(%s)::%s",
+	JsonObjectTypes:       true,
+	ArrayContainsFormat:   "%s @> %s::jsonb",
+	ObjectHasKeyFormat:    "%s ? %s",
+	FulltextFormat:        "to_tsvector(%[1]s) @@ websearch_to_tsquery(%[2]s)",
+	FulltextRankFormat:    "ts_rank(to_tsvector(%[1]s), websearch_to_tsquery(%[2]s))",
+	FulltextFieldsFormat:  "concat_ws(' ', %s)",
+	GeopointType:          `GEOGRAPHY(Point, 4326)`,
+	GeoDistanceFormat:     "ST_DWithin(%s, ST_SetSRID(ST_MakePoint(%s, %s), 4326)::geography, %s)",
+	LockRowsClause:        `FOR UPDATE`,
+}
+
+var CockroachTypeMapping = SqlTypeMapping{
+	Name:                 `cockroach`,
+	StringType:           `STRING`,
+	IntegerType:          `INT8`,
+	FloatType:            `DECIMAL`,
+	BooleanType:          `BOOL`,
+	DateTimeType:         `TIMESTAMP`,
+	ObjectType:           `STRING`,
+	ArrayType:            `STRING`,
+	RawType:              `BYTES`,
 	PlaceholderFormat:    `$%d`,
 	PlaceholderArgument:  `index1`,
 	TableNameFormat:      "%q",
 	FieldNameFormat:      "%q",
 	NestedFieldSeparator: `.`,
 	NestedFieldJoiner:    `.`,
+	ArrayContainsFormat:  "%s::JSONB @> %s::JSONB",
+	ObjectHasKeyFormat:   "%s::JSONB ? %s",
+	FulltextFormat:       "to_tsvector(%[1]s) @@ plainto_tsquery(%[2]s)",
+	FulltextRankFormat:   "ts_rank(to_tsvector(%[1]s), plainto_tsquery(%[2]s))",
+	FulltextFieldsFormat: "concat_ws(' ', %s)",
+	LockRowsClause:       `FOR UPDATE`,
+}
+
+var MssqlTypeMapping = SqlTypeMapping{
+	Name:                 `mssql`,
+	StringType:           `NVARCHAR`,
+	StringTypeLength:     255,
+	IntegerType:          `BIGINT`,
+	FloatType:            `DECIMAL`,
+	FloatTypeLength:      10,
+	FloatTypePrecision:   8,
+	BooleanType:          `BIT`,
+	DateTimeType:         `DATETIME2`,
+	ObjectType:           `NVARCHAR`,
+	ArrayType:            `VARCHAR`,
+	RawType:              `VARBINARY(MAX)`,
+	PlaceholderFormat:    `@p%d`,
+	PlaceholderArgument:  `index1`,
+	TableNameFormat:      "[%s]",
+	FieldNameFormat:      "[%s]",
+	NestedFieldSeparator: `.`,
+	NestedFieldJoiner:    `.`,
+	OffsetFetchLimits:    true,
+	OutputInserted:       true,
+	MaxTypeLength:        4000,
+	MaxPlaceholders:      2100,
 }
 
 var SqliteTypeMapping = SqlTypeMapping{
@@ -207,6 +338,12 @@ var SqliteTypeMapping = SqlTypeMapping{
 	FieldNameFormat:      "%q",
 	NestedFieldSeparator: `.`,
 	NestedFieldJoiner:    `.`,
+	ArrayContainsFormat:  "EXISTS (SELECT 1 FROM json_each(%s) WHERE json_array(json_each.value) = %s)",
+	ObjectHasKeyFormat:   "json_type(%s, '$.\"' || %s || '\"') IS NOT NULL",
+	FulltextFormat:       "%[4]s.rowid IN (SELECT rowid FROM %[3]s WHERE %[1]s MATCH %[2]s)",
+	FulltextRankFormat:   "(SELECT -rank FROM %[3]s WHERE %[3]s.rowid = %[4]s.rowid AND %[1]s MATCH %[2]s)",
+	FulltextTable:        true,
+	MaxPlaceholders:      999,
 }
 
 var DefaultSqlTypeMapping = GenericTypeMapping
@@ -217,10 +354,16 @@ func GetSqlTypeMapping(name string) (SqlTypeMapping, error) {
 		return PostgresTypeMapping, nil
 	case `postgresql-json`, `pgsql-json`:
 		return PostgresJsonTypeMapping, nil
+	case `cockroach`, `cockroachdb`:
+		return CockroachTypeMapping, nil
+	case `mssql`, `sqlserver`:
+		return MssqlTypeMapping, nil
 	case `sqlite`:
 		return SqliteTypeMapping, nil
 	case `mysql`:
 		return MysqlTypeMapping, nil
+	case `tidb`:
+		return TidbTypeMapping, nil
 	case `cassandra`:
 		return CassandraTypeMapping, nil
 	case ``:
@@ -232,17 +375,27 @@ func GetSqlTypeMapping(name string) (SqlTypeMapping, error) {
 
 type Sql struct {
 	filter.Generator
-	FieldWrappers    map[string]string      // map of field name-format strings to wrap specific fields in after FieldNameFormat is applied
-	NormalizeFields  []string               // a list of field names that should have the NormalizerFormat applied to them and their corresponding values
-	NormalizerFormat string                 // format string used to wrap fields and value clauses for the purpose of doing fuzzy searches
-	UseInStatement   bool                   // whether multiple values in a criterion should be tested using an IN() statement
-	Distinct         bool                   // whether a DISTINCT clause should be used in SELECT statements
-	Count            bool                   // whether this query is being used to count rows, which means that SELECT fields are discarded in favor of COUNT(1)
-	TypeMapping      SqlTypeMapping         // provides mapping information between DAL types and native SQL types
-	Type             SqlStatementType       // what type of SQL statement is being generated
-	InputData        map[string]interface{} // key-value data for statement types that require input data (e.g.: inserts, updates)
+	FieldWrappers    map[string]string        // map of field name-format strings to wrap specific fields in after FieldNameFormat is applied
+	NormalizeFields  []string                 // a list of field names that should have the NormalizerFormat applied to them and their corresponding values
+	NormalizerFormat string                   // format string used to wrap fields and value clauses for the purpose of doing fuzzy searches
+	UseInStatement   bool                     // whether multiple values in a criterion should be tested using an IN() statement
+	Distinct         bool                     // whether a DISTINCT clause should be used in SELECT statements
+	Count            bool                     // whether this query is being used to count rows, which means that SELECT fields are discarded in favor of COUNT(1)
+	CountLimit       int                      // if set along with Count, stop counting once this many rows have been counted
+	CountOverField   string                   // if set, SELECT statements also return the number of rows matching the query (regardless of limits) in a column with this name
+	TypeMapping      SqlTypeMapping           // provides mapping information between DAL types and native SQL types
+	Type             SqlStatementType         // what type of SQL statement is being generated
+	InputData        map[string]interface{}   // key-value data for statement types that require input data (e.g.: inserts, updates)
+	ReturningField   string                   // if set, INSERT statements return the value of this field (e.g.: a database-generated identity)
+	InputRows        []map[string]interface{} // additional rows inserted by INSERT statements; each must contain the same fields as InputData
+	SearchFields     []string                 // the fields that full-text criteria on filter.SearchAllField are matched against
+	ScoreField       string                   // if set, SELECT statements containing full-text criteria also return the relevance of each row in a column with this name, and are ordered by it unless otherwise sorted
+	AsOfSystemTime   string                   // if set, SELECT statements read data as of this time using an "AS OF SYSTEM TIME" clause (CockroachDB)
+	LockRows         bool                     // whether SELECT statements lock the rows they read until the end of the transaction (if the database supports it)
 	collection       string
+	collectionName   string
 	fields           []string
+	joins            []filter.Join
 	criteria         []string
 	inputValues      []interface{}
 	values           []interface{}
@@ -250,6 +403,9 @@ type Sql struct {
 	aggregateBy      []filter.Aggregate
 	conjunction      filter.ConjunctionType
 	placeholderIndex int
+	ranks            []string
+	rankValues       []interface{}
+	ranked           bool
 }
 
 func NewSqlGenerator() *Sql {
@@ -262,6 +418,7 @@ func NewSqlGenerator() *Sql {
 		TypeMapping:      DefaultSqlTypeMapping,
 		Type:             SqlSelectStatement,
 		InputData:        make(map[string]interface{}),
+		InputRows:        make([]map[string]interface{}, 0),
 	}
 }
 
@@ -269,11 +426,16 @@ func (self *Sql) Initialize(collectionName string) error {
 	self.Reset()
 	self.placeholderIndex = 0
 	self.collection = self.ToTableName(collectionName)
+	self.collectionName = collectionName
 	self.fields = make([]string, 0)
+	self.joins = make([]filter.Join, 0)
 	self.criteria = make([]string, 0)
 	self.inputValues = make([]interface{}, 0)
 	self.values = make([]interface{}, 0)
 	self.conjunction = filter.AndConjunction
+	self.ranks = nil
+	self.rankValues = nil
+	self.ranked = false
 
 	return nil
 }
@@ -292,28 +454,44 @@ func (self *Sql) Finalize(f *filter.Filter) error {
 
 	if err != nil {
 		return err
+	} else if len(self.joins) > 0 && self.Type != SqlSelectStatement {
+		return fmt.Errorf("joins are only supported in SELECT statements")
 	}
 
 	switch self.Type {
 	case SqlSelectStatement:
 		self.Push([]byte(`SELECT `))
 
-		if self.Count {
-			self.Push([]byte(`COUNT(1) `))
+		if self.Count && self.CountLimit > 0 {
+			// count the rows of a subquery that returns no more than the limit
+			self.Push([]byte(`COUNT(1) FROM (SELECT 1 AS matched`))
+		} else if self.Count {
+			self.Push([]byte(`COUNT(1)`))
 		} else {
 			if self.Distinct {
 				self.Push([]byte(`DISTINCT `))
 			}
 
 			if len(self.fields) == 0 && len(self.groupBy) == 0 && len(self.aggregateBy) == 0 {
-				self.Push([]byte(`*`))
+				// only return the queried table's columns unless fields from joined tables are asked for
+				if len(self.joins) > 0 {
+					self.Push([]byte(self.collection + `.*`))
+				} else {
+					self.Push([]byte(`*`))
+				}
 			} else {
 				fieldNames := make([]string, 0)
 
 				for _, f := range self.fields {
 					fName := self.ToFieldName(f)
 
-					if strings.Contains(f, self.TypeMapping.NestedFieldSeparator) {
+					if len(self.joins) > 0 {
+						// fields from joined tables are returned under their qualified names so that
+						// they don't collide with the queried table's fields
+						if table, _ := self.splitQualifiedField(f); table != self.collectionName {
+							fName = fmt.Sprintf("%v AS "+self.TypeMapping.FieldNameFormat, fName, f)
+						}
+					} else if strings.Contains(f, self.TypeMapping.NestedFieldSeparator) {
 						fName = fmt.Sprintf("%v AS "+self.TypeMapping.FieldNameFormat, fName, f)
 					}
 
@@ -336,17 +514,45 @@ func (self *Sql) Finalize(f *filter.Filter) error {
 
 				self.Push([]byte(strings.Join(fieldNames, `, `)))
 			}
+
+			if self.CountOverField != `` {
+				self.Push([]byte(`, COUNT(*) OVER () AS ` + fmt.Sprintf(self.TypeMapping.FieldNameFormat, self.CountOverField)))
+			}
+
+			// return the combined relevance of all full-text criteria
+			if self.ScoreField != `` && len(self.ranks) > 0 && !self.Distinct && len(self.groupBy) == 0 && len(self.aggregateBy) == 0 {
+				self.Push([]byte(`, ` + strings.Join(self.ranks, ` + `) + ` AS ` + fmt.Sprintf(self.TypeMapping.FieldNameFormat, self.ScoreField)))
+				self.ranked = true
+			}
 		}
 
 		self.Push([]byte(` FROM `))
 		self.Push([]byte(self.collection))
 
+		self.populateJoins()
+
+		// historical reads apply to the statement as a whole, so they can't be given in the subquery
+		if !(self.Count && self.CountLimit > 0) {
+			self.populateAsOfSystemTime()
+		}
+
 		self.populateWhereClause()
 		self.populateGroupBy()
 
-		if !self.Count {
+		if self.Count && self.CountLimit > 0 {
+			self.populateLimitOffset(&filter.Filter{
+				Limit: self.CountLimit,
+			})
+
+			self.Push([]byte(`) AS counted`))
+			self.populateAsOfSystemTime()
+		} else if !self.Count {
 			self.populateOrderBy(f)
 			self.populateLimitOffset(f)
+
+			if self.LockRows && self.TypeMapping.LockRowsClause != `` {
+				self.Push([]byte(` ` + self.TypeMapping.LockRowsClause))
+			}
 		}
 
 	case SqlInsertStatement:
@@ -368,10 +574,33 @@ func (self *Sql) Finalize(f *filter.Filter) error {
 		sort.Strings(fieldNames)
 
 		self.Push([]byte(strings.Join(fieldNames, `, `)))
-		self.Push([]byte(`) VALUES (`))
+		self.Push([]byte(`)`))
+
+		if self.ReturningField != `` && self.TypeMapping.OutputInserted {
+			self.Push([]byte(` OUTPUT INSERTED.`))
+			self.Push([]byte(self.ToFieldName(self.ReturningField)))
+		}
+
+		self.Push([]byte(` VALUES (`))
 
 		self.Push([]byte(strings.Join(inputValues, `, `)))
 		self.Push([]byte(`)`))
+
+		// multi-row inserts: each row gets its own parenthesized list of values
+		if rows, err := self.populateInputRows(); err == nil {
+			for _, rowValues := range rows {
+				self.Push([]byte(`, (`))
+				self.Push([]byte(strings.Join(rowValues, `, `)))
+				self.Push([]byte(`)`))
+			}
+		} else {
+			return err
+		}
+
+		if self.ReturningField != `` && !self.TypeMapping.OutputInserted {
+			self.Push([]byte(` RETURNING `))
+			self.Push([]byte(self.ToFieldName(self.ReturningField)))
+		}
 	case SqlUpdateStatement:
 		if len(self.InputData) == 0 {
 			return fmt.Errorf("UPDATE statements must specify input data")
@@ -426,11 +655,59 @@ func (self *Sql) populateInputValues() ([]string, error) {
 	return values, nil
 }
 
+// adds the values of each of the additional INSERT rows, in the same field order as InputData
+func (self *Sql) populateInputRows() ([][]string, error) {
+	rows := make([][]string, 0)
+	fields := maputil.StringKeys(self.InputData)
+
+	for i, row := range self.InputRows {
+		if len(row) != len(fields) {
+			return nil, fmt.Errorf("INSERT row %d has %d fields, expected %d", i+1, len(row), len(fields))
+		}
+
+		values := make([]string, 0)
+
+		for _, field := range fields {
+			if v, ok := row[field]; ok {
+				values = append(values, fmt.Sprintf("\u2983%s\u2984", field))
+
+				if vv, err := self.PrepareInputValue(field, v); err == nil {
+					self.inputValues = append(self.inputValues, vv)
+				} else {
+					return nil, err
+				}
+			} else {
+				return nil, fmt.Errorf("INSERT row %d is missing field %q", i+1, field)
+			}
+		}
+
+		rows = append(rows, values)
+	}
+
+	return rows, nil
+}
+
 func (self *Sql) WithField(field string) error {
 	self.fields = append(self.fields, field)
 	return nil
 }
 
+func (self *Sql) WithJoin(join filter.Join) error {
+	if join.Collection == `` {
+		return fmt.Errorf("joins must specify a collection")
+	} else if join.LocalField == `` {
+		return fmt.Errorf("join with %q must specify a local field", join.Collection)
+	}
+
+	switch join.Type {
+	case ``, filter.InnerJoin, filter.LeftJoin:
+		self.joins = append(self.joins, join)
+		return nil
+	default:
+		return fmt.Errorf("unsupported join type %q", join.Type)
+	}
+}
+
 func (self *Sql) SetOption(_ string, _ interface{}) error {
 	return nil
 }
@@ -450,7 +727,14 @@ func (self *Sql) AggregateByField(agg filter.Aggregation, field string) error {
 }
 
 func (self *Sql) GetValues() []interface{} {
-	return append(self.inputValues, self.values...)
+	var values = append([]interface{}{}, self.inputValues...)
+
+	// relevance calculations appear in the SELECT clause, ahead of the criteria they belong to
+	if self.ranked {
+		values = append(values, self.rankValues...)
+	}
+
+	return append(values, self.values...)
 }
 
 // Okay...so.
@@ -467,34 +751,106 @@ func (self *Sql) GetValues() []interface{} {
 // e.g. PostgreSQL).
 //
 func (self *Sql) applyPlaceholders() {
-	payload := string(self.Payload())
+	var payload = string(self.Payload())
+	var out strings.Builder
 
-	for i := 0; i < SqlMaxPlaceholders; i++ {
-		if match := rxutil.Match("(?P<field>\xe2\xa6\x83[^\xe2\xa6\x84]+\xe2\xa6\x84)", payload); match != nil {
-			field := match.Group(`field`)
-			field = strings.TrimPrefix(field, "\u2983")
-			field = strings.TrimSuffix(field, "\u2984")
+	out.Grow(len(payload))
 
-			payload = match.ReplaceGroup(`field`, self.GetPlaceholder(field))
-		} else {
+	for {
+		start := strings.Index(payload, sqlPlaceholderOpen)
+
+		if start < 0 {
+			break
+		}
+
+		length := strings.Index(payload[start+len(sqlPlaceholderOpen):], sqlPlaceholderClose)
+
+		if length < 0 {
 			break
+		} else if length == 0 {
+			// an empty sequence doesn't name a field, so it is left as-is
+			out.WriteString(payload[:start+len(sqlPlaceholderOpen)])
+			payload = payload[start+len(sqlPlaceholderOpen):]
+			continue
 		}
+
+		field := payload[start+len(sqlPlaceholderOpen) : start+len(sqlPlaceholderOpen)+length]
+
+		out.WriteString(payload[:start])
+		out.WriteString(self.GetPlaceholder(field))
+		payload = payload[start+len(sqlPlaceholderOpen)+length+len(sqlPlaceholderClose):]
 	}
 
-	self.Set([]byte(payload))
+	out.WriteString(payload)
+
+	self.Set([]byte(out.String()))
 }
 
 func (self *Sql) WithCriterion(criterion filter.Criterion) error {
-	criterionStr := ``
+	if criterionStr, err := self.criterionClause(criterion); err == nil {
+		self.criteria = append(self.criteria, criterionStr)
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Adds a group of criteria, which is rendered as a parenthesized boolean expression combining
+// its criteria and any nested groups using the group's conjunction.
+func (self *Sql) WithGroup(group filter.Group) error {
+	if groupStr, err := self.groupClause(group); err == nil {
+		if groupStr != `` {
+			self.criteria = append(self.criteria, groupStr)
+		}
 
-	if len(self.criteria) == 0 {
-		criterionStr = `WHERE (`
-	} else if self.conjunction == filter.OrConjunction {
-		criterionStr = `OR (`
+		return nil
 	} else {
-		criterionStr = `AND (`
+		return err
 	}
+}
+
+func (self *Sql) groupClause(group filter.Group) (string, error) {
+	var clauses = make([]string, 0)
 
+	for _, criterion := range group.Criteria {
+		if clause, err := self.criterionClause(criterion); err == nil {
+			clauses = append(clauses, clause)
+		} else {
+			return ``, err
+		}
+	}
+
+	for _, subgroup := range group.Groups {
+		if clause, err := self.groupClause(subgroup); err == nil {
+			if clause != `` {
+				clauses = append(clauses, clause)
+			}
+		} else {
+			return ``, err
+		}
+	}
+
+	switch len(clauses) {
+	case 0:
+		return ``, nil
+	case 1:
+		return clauses[0], nil
+	default:
+		return `(` + strings.Join(clauses, sqlConjunction(group.Conjunction)) + `)`, nil
+	}
+}
+
+// returns the parenthesized SQL expression for a single criterion
+func (self *Sql) criterionClause(criterion filter.Criterion) (string, error) {
+	if filter.IsContainmentOperator(criterion.Operator) {
+		return self.containmentClause(criterion)
+	} else if criterion.Operator == `fulltext` {
+		return self.fulltextClause(criterion)
+	} else if criterion.Operator == `near` {
+		return self.nearClause(criterion)
+	}
+
+	criterionStr := `(`
 	outValues := make([]string, 0)
 
 	// whether to wrap is: and not: queries containing multiple values in an IN() group
@@ -524,7 +880,7 @@ func (self *Sql) WithCriterion(criterion filter.Criterion) error {
 	// range queries are particular about the number of values
 	if criterion.Operator == `range` {
 		if len(criterion.Values) != 2 {
-			return fmt.Errorf("The 'range' operator must be given exactly two values")
+			return ``, fmt.Errorf("The 'range' operator must be given exactly two values")
 		}
 
 		if lowerValue, err := self.valueToNativeRepresentation(criterion.Type, criterion.Values[0]); err == nil {
@@ -536,10 +892,10 @@ func (self *Sql) WithCriterion(criterion filter.Criterion) error {
 					},
 				}
 			} else {
-				return fmt.Errorf("invalid range upper bound: %v", err)
+				return ``, fmt.Errorf("invalid range upper bound: %v", err)
 			}
 		} else {
-			return fmt.Errorf("invalid range lower bound: %v", err)
+			return ``, fmt.Errorf("invalid range lower bound: %v", err)
 		}
 
 	}
@@ -580,7 +936,7 @@ func (self *Sql) WithCriterion(criterion filter.Criterion) error {
 				outVal := ``
 
 				if !useInStatement {
-					outFieldName = self.ToFieldName(criterion.Field)
+					outFieldName = self.toCriterionFieldName(criterion)
 					outVal = outFieldName
 				}
 
@@ -652,36 +1008,244 @@ func (self *Sql) WithCriterion(criterion filter.Criterion) error {
 							self.ApplyNormalizer(criterion.Field, value),
 						)
 					} else {
-						return fmt.Errorf("Invalid value for 'range' operator")
+						return ``, fmt.Errorf("Invalid value for 'range' operator")
 					}
 				default:
-					return fmt.Errorf("Unimplemented operator '%s'", criterion.Operator)
+					return ``, fmt.Errorf("Unimplemented operator '%s'", criterion.Operator)
 				}
 
 				outValues = append(outValues, outVal)
 			} else {
-				return err
+				return ``, err
 			}
 		} else {
-			return err
+			return ``, err
 		}
 	}
 
 	if useInStatement {
-		criterionStr = criterionStr + self.ToFieldName(outFieldName) + ` `
+		var inField string
+		var inOperator = `IN(`
+		var inJoiner = ` OR `
+		var lists []string
+
+		if outFieldName == criterion.Field {
+			inField = self.toCriterionFieldName(criterion) + ` `
+		} else {
+			inField = self.ToFieldName(outFieldName) + ` `
+		}
 
 		if criterion.Operator == `not` || criterion.Operator == `unlike` {
-			criterionStr = criterionStr + `NOT `
+			inOperator = `NOT IN(`
+			inJoiner = ` AND `
+		}
+
+		// very long lists are split up, since some databases limit how many values a list can have
+		for len(outValues) > 0 {
+			var n = len(outValues)
+
+			if SqlMaxInValues > 0 && n > SqlMaxInValues {
+				n = SqlMaxInValues
+			}
+
+			lists = append(lists, inField+inOperator+strings.Join(outValues[:n], `, `)+`)`)
+			outValues = outValues[n:]
 		}
 
-		criterionStr = criterionStr + `IN(` + strings.Join(outValues, `, `) + `))`
+		criterionStr = criterionStr + strings.Join(lists, inJoiner) + `)`
 	} else {
 		criterionStr = criterionStr + strings.Join(outValues, ` OR `) + `)`
 	}
 
-	self.criteria = append(self.criteria, criterionStr)
+	return criterionStr, nil
+}
 
-	return nil
+// returns the expression testing whether an array field contains (or does not contain) any of the
+// criterion's values, or whether an object field has any of the keys given as values.  Arrays and
+// objects are stored as JSON, so array values are compared as single-element JSON arrays.
+func (self *Sql) containmentClause(criterion filter.Criterion) (string, error) {
+	var format = self.TypeMapping.ArrayContainsFormat
+	var joiner = ` OR `
+	var clauses = make([]string, 0)
+
+	if criterion.Operator == `contains-key` {
+		format = self.TypeMapping.ObjectHasKeyFormat
+	} else if criterion.Operator == `not-in-array` {
+		joiner = ` AND `
+	}
+
+	if format == `` {
+		return ``, fmt.Errorf("The '%s' operator is not supported by %v", criterion.Operator, self.TypeMapping)
+	} else if len(criterion.Values) == 0 {
+		return ``, fmt.Errorf("The '%s' operator requires at least one value", criterion.Operator)
+	}
+
+	for _, vI := range criterion.Values {
+		if criterion.Operator == `contains-key` {
+			self.values = append(self.values, typeutil.String(vI))
+		} else {
+			var element interface{}
+
+			switch criterion.Type {
+			case ``, dal.AutoType, dal.ArrayType, dal.ObjectType:
+				element = stringutil.Autotype(vI)
+			default:
+				if typedValue, err := self.valueToNativeRepresentation(criterion.Type, vI); err == nil {
+					element = typedValue
+				} else {
+					return ``, err
+				}
+			}
+
+			if data, err := json.Marshal([]interface{}{element}); err == nil {
+				self.values = append(self.values, string(data))
+			} else {
+				return ``, err
+			}
+		}
+
+		var clause = fmt.Sprintf(format, self.ToFieldName(criterion.Field), fmt.Sprintf("\u2983%s\u2984", criterion.Field))
+
+		if criterion.Operator == `not-in-array` {
+			clause = `NOT (` + clause + `)`
+		}
+
+		clauses = append(clauses, clause)
+	}
+
+	// records that don't have the array at all don't contain any of the values either
+	if criterion.Operator == `not-in-array` {
+		return `(` + self.ToFieldName(criterion.Field) + ` IS NULL OR (` + strings.Join(clauses, joiner) + `))`, nil
+	} else {
+		return `(` + strings.Join(clauses, joiner) + `)`, nil
+	}
+}
+
+// returns the expression matching any of the criterion's values as full-text queries against a
+// field, or against all of the collection's search fields if the field is filter.SearchAllField.
+// The relevance of each match is also recorded so that results can be ranked by it.
+func (self *Sql) fulltextClause(criterion filter.Criterion) (string, error) {
+	var format = self.TypeMapping.FulltextFormat
+	var ftsTable = self.ToTableName(self.collectionName + SqlFulltextTableSuffix)
+	var target string
+	var clauses = make([]string, 0)
+
+	if format == `` {
+		return ``, fmt.Errorf("The 'fulltext' operator is not supported by %v", self.TypeMapping)
+	} else if len(criterion.Values) == 0 {
+		return ``, fmt.Errorf("The 'fulltext' operator requires at least one value")
+	}
+
+	if criterion.Field == filter.SearchAllField {
+		if self.TypeMapping.FulltextTable {
+			// matching against the full-text table itself searches all of its columns
+			target = ftsTable
+		} else if len(self.SearchFields) > 0 {
+			var fields = make([]string, len(self.SearchFields))
+
+			for i, field := range self.SearchFields {
+				fields[i] = self.ToFieldName(field)
+			}
+
+			target = strings.Join(fields, `, `)
+
+			if fieldsFmt := self.TypeMapping.FulltextFieldsFormat; fieldsFmt != `` {
+				target = fmt.Sprintf(fieldsFmt, target)
+			}
+		} else {
+			return ``, fmt.Errorf("Cannot search all fields of %q: no search fields are declared", self.collectionName)
+		}
+	} else if self.TypeMapping.FulltextTable {
+		target = fmt.Sprintf(self.TypeMapping.FieldNameFormat, criterion.Field)
+	} else {
+		target = self.ToFieldName(criterion.Field)
+	}
+
+	for _, vI := range criterion.Values {
+		var query = typeutil.String(vI)
+		var placeholder = fmt.Sprintf("\u2983%s\u2984", criterion.Field)
+
+		self.values = append(self.values, query)
+		clauses = append(clauses, fmt.Sprintf(format, target, placeholder, ftsTable, self.collection))
+
+		if rankFmt := self.TypeMapping.FulltextRankFormat; rankFmt != `` {
+			self.rankValues = append(self.rankValues, query)
+			self.ranks = append(self.ranks, `COALESCE(`+fmt.Sprintf(rankFmt, target, placeholder, ftsTable, self.collection)+`, 0)`)
+		}
+	}
+
+	return `(` + strings.Join(clauses, ` OR `) + `)`, nil
+}
+
+// returns the expression matching a geopoint field that is within the given distance of any of
+// the criterion's points
+func (self *Sql) nearClause(criterion filter.Criterion) (string, error) {
+	var format = self.TypeMapping.GeoDistanceFormat
+	var placeholder = fmt.Sprintf("\u2983%s\u2984", criterion.Field)
+	var clauses = make([]string, 0)
+
+	if format == `` {
+		return ``, fmt.Errorf("The 'near' operator is not supported by %v", self.TypeMapping)
+	} else if len(criterion.Values) == 0 {
+		return ``, fmt.Errorf("The 'near' operator requires at least one value")
+	}
+
+	for _, vI := range criterion.Values {
+		if near, err := filter.ParseGeoDistance(vI); err == nil {
+			self.values = append(self.values, near.Point.Longitude, near.Point.Latitude, near.Radius)
+			clauses = append(clauses, fmt.Sprintf(format, self.ToFieldName(criterion.Field), placeholder, placeholder, placeholder))
+		} else {
+			return ``, err
+		}
+	}
+
+	return `(` + strings.Join(clauses, ` OR `) + `)`, nil
+}
+
+// returns the formatted name of the field a criterion applies to.  Nested fields are extracted
+// as text, so they are cast to the type of the values they are being compared against.
+func (self *Sql) toCriterionFieldName(criterion filter.Criterion) string {
+	var field = self.ToFieldName(criterion.Field)
+
+	if castFmt := self.TypeMapping.NestedFieldCastFormat; castFmt != `` && self.isNestedField(criterion.Field) {
+		switch criterion.Operator {
+		case `like`, `unlike`, `contains`, `prefix`, `suffix`:
+			return field
+		}
+
+		var ctype = criterion.Type
+
+		if (ctype == `` || ctype == dal.AutoType) && len(criterion.Values) > 0 {
+			switch stringutil.Autotype(criterion.Values[0]).(type) {
+			case int, int64:
+				ctype = dal.IntType
+			case float64:
+				ctype = dal.FloatType
+			case bool:
+				ctype = dal.BooleanType
+			case time.Time:
+				ctype = dal.TimeType
+			}
+		}
+
+		switch ctype {
+		case dal.IntType, dal.FloatType, dal.BooleanType, dal.TimeType:
+			if nativeType, err := self.ToNativeType(ctype, nil, 0); err == nil {
+				return fmt.Sprintf(castFmt, field, nativeType)
+			}
+		}
+	}
+
+	return field
+}
+
+// returns whether the given field name addresses a key within an object field
+func (self *Sql) isNestedField(field string) bool {
+	if self.TypeMapping.NestedFieldNameFormat == `` || len(self.joins) > 0 {
+		return false
+	}
+
+	return len(strings.Split(field, self.TypeMapping.NestedFieldSeparator)) > 1
 }
 
 func (self *Sql) ToTableName(table string) string {
@@ -692,9 +1256,19 @@ func (self *Sql) ToFieldName(field string) string {
 	var formattedField string
 
 	if field != `` {
-		if nestFmt := self.TypeMapping.NestedFieldNameFormat; nestFmt != `` {
+		// when joining other tables, fields are qualified with the name of the table they belong to
+		if len(self.joins) > 0 {
+			table, column := self.splitQualifiedField(field)
+			formattedField = self.ToTableName(table) + `.` + fmt.Sprintf(self.TypeMapping.FieldNameFormat, column)
+		} else if nestFmt := self.TypeMapping.NestedFieldNameFormat; nestFmt != `` {
 			if parts := strings.Split(field, self.TypeMapping.NestedFieldSeparator); len(parts) > 1 {
-				formattedField = fmt.Sprintf(nestFmt, parts[0], strings.Join(parts[1:], self.TypeMapping.NestedFieldJoiner))
+				var keys = make([]string, len(parts)-1)
+
+				for i, key := range parts[1:] {
+					keys[i] = sqlNestedKeyEscaper.Replace(key)
+				}
+
+				formattedField = fmt.Sprintf(nestFmt, parts[0], strings.Join(keys, self.TypeMapping.NestedFieldJoiner))
 			}
 		}
 
@@ -710,6 +1284,26 @@ func (self *Sql) ToFieldName(field string) string {
 	return formattedField
 }
 
+// Splits a field qualified with the name of the queried table or a joined table (e.g.: "orders.total")
+// into the table and field names.  Unqualified fields belong to the queried table.
+func (self *Sql) splitQualifiedField(field string) (string, string) {
+	if sep := self.TypeMapping.NestedFieldSeparator; sep != `` {
+		if parts := strings.SplitN(field, sep, 2); len(parts) == 2 {
+			if parts[0] == self.collectionName {
+				return parts[0], parts[1]
+			}
+
+			for _, join := range self.joins {
+				if parts[0] == join.Collection {
+					return parts[0], parts[1]
+				}
+			}
+		}
+	}
+
+	return self.collectionName, field
+}
+
 func (self *Sql) ToAggregatedFieldName(agg filter.Aggregation, field string) string {
 	field = self.ToFieldName(field)
 
@@ -801,6 +1395,10 @@ func (self *Sql) ToNativeType(in dal.Type, subtypes []dal.Type, length int) (str
 		out = self.TypeMapping.DateTimeType
 
 	case dal.ObjectType:
+		if self.TypeMapping.JsonObjectTypes {
+			length = 0
+		}
+
 		if f := self.TypeMapping.MultiSubtypeFormat; f == `` {
 			out = self.TypeMapping.ObjectType
 		} else if len(subtypes) == 2 {
@@ -821,6 +1419,10 @@ func (self *Sql) ToNativeType(in dal.Type, subtypes []dal.Type, length int) (str
 		}
 
 	case dal.ArrayType:
+		if self.TypeMapping.JsonObjectTypes {
+			length = 0
+		}
+
 		if f := self.TypeMapping.SubtypeFormat; f == `` {
 			out = self.TypeMapping.ArrayType
 		} else if len(subtypes) == 1 {
@@ -838,11 +1440,21 @@ func (self *Sql) ToNativeType(in dal.Type, subtypes []dal.Type, length int) (str
 	case dal.RawType:
 		out = self.TypeMapping.RawType
 
+	case dal.GeopointType:
+		if self.TypeMapping.GeopointType == `` {
+			return self.ToNativeType(dal.ObjectType, nil, length)
+		}
+
+		out = self.TypeMapping.GeopointType
+		length = 0
+
 	default:
 		out = strings.ToUpper(in.String())
 	}
 
-	if length > 0 {
+	if max := self.TypeMapping.MaxTypeLength; max > 0 && length > max {
+		out = out + `(MAX)`
+	} else if length > 0 {
 		if precision > 0 {
 			out = out + fmt.Sprintf("(%d,%d)", length, precision)
 		} else {
@@ -910,6 +1522,16 @@ func (self *Sql) PrepareInputValue(f string, value interface{}) (interface{}, er
 		return value, nil
 	}
 
+	// fields explicitly set to null are written as NULL
+	if dal.IsNull(value) {
+		return nil, nil
+	}
+
+	// geopoints are given to native geospatial types as EWKT, and are otherwise stored as JSON
+	if point, ok := value.(dal.Geopoint); ok && self.TypeMapping.GeopointType != `` {
+		return point.EWKT(), nil
+	}
+
 	switch reflect.ValueOf(value).Kind() {
 	case reflect.Struct, reflect.Map, reflect.Ptr, reflect.Array, reflect.Slice:
 		return SqlJsonTypeEncoder(value)
@@ -966,18 +1588,48 @@ func (self *Sql) ArrayTypeDecode(in []byte, out interface{}) error {
 	}
 }
 
-func (self *Sql) populateWhereClause() {
-	if len(self.criteria) > 0 {
-		self.Push([]byte(` `))
+func (self *Sql) populateJoins() {
+	for _, join := range self.joins {
+		var joinType = `INNER`
+		var remoteField = join.RemoteField
 
-		for i, criterionStr := range self.criteria {
-			self.Push([]byte(criterionStr))
+		if join.Type == filter.LeftJoin {
+			joinType = `LEFT`
+		}
 
-			// do this for all but the last criterion
-			if i+1 < len(self.criteria) {
-				self.Push([]byte(` `))
-			}
+		if remoteField == `` {
+			remoteField = filter.DefaultIdentityField
 		}
+
+		self.Push([]byte(fmt.Sprintf(
+			" %s JOIN %s ON (%s = %s)",
+			joinType,
+			self.ToTableName(join.Collection),
+			self.ToFieldName(join.LocalField),
+			self.ToFieldName(join.Collection+self.TypeMapping.NestedFieldSeparator+remoteField),
+		)))
+	}
+}
+
+func (self *Sql) populateAsOfSystemTime() {
+	if self.AsOfSystemTime != `` {
+		self.Push([]byte(` AS OF SYSTEM TIME ` + self.AsOfSystemTime))
+	}
+}
+
+func (self *Sql) populateWhereClause() {
+	if len(self.criteria) > 0 {
+		self.Push([]byte(` WHERE `))
+		self.Push([]byte(strings.Join(self.criteria, sqlConjunction(self.conjunction))))
+	}
+}
+
+// returns the operator used to join expressions with the given conjunction
+func sqlConjunction(conjunction filter.ConjunctionType) string {
+	if conjunction == filter.OrConjunction {
+		return ` OR `
+	} else {
+		return ` AND `
 	}
 }
 
@@ -994,7 +1646,10 @@ func (self *Sql) populateGroupBy() {
 }
 
 func (self *Sql) populateOrderBy(f *filter.Filter) {
-	if sortFields := sliceutil.CompactString(f.Sort); len(sortFields) > 0 {
+	if len(sliceutil.CompactString(f.Sort)) == 0 && self.ranked {
+		// the most relevant full-text matches come first
+		self.Push([]byte(` ORDER BY ` + fmt.Sprintf(self.TypeMapping.FieldNameFormat, self.ScoreField) + ` DESC`))
+	} else if sortFields := sliceutil.CompactString(f.Sort); len(sortFields) > 0 {
 		self.Push([]byte(` ORDER BY `))
 		orderByFields := make([]string, len(sortFields))
 
@@ -1015,7 +1670,20 @@ func (self *Sql) populateOrderBy(f *filter.Filter) {
 }
 
 func (self *Sql) populateLimitOffset(f *filter.Filter) {
-	if f.Limit > 0 {
+	if self.TypeMapping.OffsetFetchLimits {
+		if f.Limit > 0 || f.Offset > 0 {
+			// OFFSET...FETCH is only valid following an ORDER BY clause
+			if len(sliceutil.CompactString(f.Sort)) == 0 {
+				self.Push([]byte(` ORDER BY (SELECT NULL)`))
+			}
+
+			self.Push([]byte(fmt.Sprintf(" OFFSET %d ROWS", f.Offset)))
+
+			if f.Limit > 0 {
+				self.Push([]byte(fmt.Sprintf(" FETCH NEXT %d ROWS ONLY", f.Limit)))
+			}
+		}
+	} else if f.Limit > 0 {
 		self.Push([]byte(fmt.Sprintf(" LIMIT %d", f.Limit)))
 
 		if f.Offset > 0 {
@@ -301,6 +301,99 @@ func TestSqlInserts(t *testing.T) {
 	}
 }
 
+func TestSqlInsertReturning(t *testing.T) {
+	assert := require.New(t)
+
+	gen := NewSqlGenerator()
+	gen.TypeMapping = CockroachTypeMapping
+	gen.Type = SqlInsertStatement
+	gen.ReturningField = `id`
+	gen.InputData = map[string]interface{}{
+		`name`: `ted`,
+	}
+
+	actual, err := filter.Render(gen, `foo`, filter.New())
+	assert.NoError(err)
+	assert.Equal(`INSERT INTO "foo" ("name") VALUES ($1) RETURNING "id"`, string(actual[:]))
+	assert.Equal([]interface{}{`ted`}, gen.GetValues())
+}
+
+func TestSqlInsertMultipleRows(t *testing.T) {
+	assert := require.New(t)
+
+	gen := NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	gen.Type = SqlInsertStatement
+	gen.InputData = map[string]interface{}{
+		`id`:   1,
+		`name`: `ted`,
+	}
+
+	gen.InputRows = []map[string]interface{}{
+		{`id`: 2, `name`: `alice`},
+		{`id`: 3, `name`: `bob`},
+	}
+
+	actual, err := filter.Render(gen, `foo`, filter.New())
+	assert.NoError(err)
+	assert.Equal(`INSERT INTO "foo" ("id", "name") VALUES ($1, $2), ($3, $4), ($5, $6)`, string(actual[:]))
+	assert.Equal([]interface{}{1, `ted`, 2, `alice`, 3, `bob`}, gen.GetValues())
+
+	// all rows must have the same fields
+	gen = NewSqlGenerator()
+	gen.Type = SqlInsertStatement
+	gen.InputData = map[string]interface{}{
+		`id`:   1,
+		`name`: `ted`,
+	}
+
+	gen.InputRows = []map[string]interface{}{
+		{`id`: 2, `age`: 42},
+	}
+
+	_, err = filter.Render(gen, `foo`, filter.New())
+	assert.Error(err)
+}
+
+func TestSqlMssql(t *testing.T) {
+	assert := require.New(t)
+
+	gen := NewSqlGenerator()
+	gen.TypeMapping = MssqlTypeMapping
+
+	f := filter.MustParse(`name/ted`)
+	f.Limit = 10
+	f.Offset = 20
+
+	actual, err := filter.Render(gen, `foo`, f)
+	assert.NoError(err)
+	assert.Equal(`SELECT * FROM [foo] WHERE ([name] = @p1) ORDER BY (SELECT NULL) OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY`, string(actual[:]))
+
+	gen = NewSqlGenerator()
+	gen.TypeMapping = MssqlTypeMapping
+	f.Sort = []string{`-name`}
+
+	actual, err = filter.Render(gen, `foo`, f)
+	assert.NoError(err)
+	assert.Equal(`SELECT * FROM [foo] WHERE ([name] = @p1) ORDER BY [name] DESC OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY`, string(actual[:]))
+
+	gen = NewSqlGenerator()
+	gen.TypeMapping = MssqlTypeMapping
+	gen.Type = SqlInsertStatement
+	gen.ReturningField = `id`
+	gen.InputData = map[string]interface{}{
+		`name`: `ted`,
+	}
+
+	actual, err = filter.Render(gen, `foo`, filter.New())
+	assert.NoError(err)
+	assert.Equal(`INSERT INTO [foo] ([name]) OUTPUT INSERTED.[id] VALUES (@p1)`, string(actual[:]))
+
+	nativeType, err := gen.ToNativeType(dal.ObjectType, nil, 131071)
+	assert.NoError(err)
+	assert.Equal(`NVARCHAR(MAX)`, nativeType)
+}
+
 type updateTestData struct {
 	Input  map[string]interface{}
 	Filter string
@@ -369,6 +462,23 @@ func TestSqlUpdates(t *testing.T) {
 
 }
 
+func TestSqlUpdateExplicitNull(t *testing.T) {
+	assert := require.New(t)
+
+	gen := NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	gen.Type = SqlUpdateStatement
+	gen.InputData = map[string]interface{}{
+		`name`:    `ted`,
+		`address`: dal.Null,
+	}
+
+	actual, err := filter.Render(gen, `foo`, filter.MustParse(`id/42`))
+	assert.NoError(err)
+	assert.Equal(`UPDATE "foo" SET "address" = $1, "name" = $2 WHERE ("id" = $3)`, string(actual[:]))
+	assert.Equal([]interface{}{nil, `ted`, int64(42)}, gen.GetValues())
+}
+
 func TestSqlDeletes(t *testing.T) {
 	assert := require.New(t)
 
@@ -892,6 +1002,84 @@ func TestSqlLimitOffset(t *testing.T) {
 	assert.Equal(`SELECT * FROM foo LIMIT 4 OFFSET 12`, string(sql[:]))
 }
 
+func TestSqlSelectTotals(t *testing.T) {
+	assert := require.New(t)
+
+	f := filter.MustParse(`name/ted`)
+	f.Limit = 10
+	f.Offset = 20
+
+	gen := NewSqlGenerator()
+	gen.CountOverField = `_total`
+	sql, err := filter.Render(gen, `foo`, f)
+	assert.NoError(err)
+	assert.Equal(`SELECT *, COUNT(*) OVER () AS _total FROM foo WHERE (name = ?) LIMIT 10 OFFSET 20`, string(sql[:]))
+
+	gen = NewSqlGenerator()
+	gen.Count = true
+	gen.CountLimit = 100
+	sql, err = filter.Render(gen, `foo`, f)
+	assert.NoError(err)
+	assert.Equal(`SELECT COUNT(1) FROM (SELECT 1 AS matched FROM foo WHERE (name = ?) LIMIT 100) AS counted`, string(sql[:]))
+
+	gen = NewSqlGenerator()
+	gen.TypeMapping = MssqlTypeMapping
+	gen.Count = true
+	gen.CountLimit = 100
+	sql, err = filter.Render(gen, `foo`, f)
+	assert.NoError(err)
+	assert.Equal(
+		`SELECT COUNT(1) FROM (SELECT 1 AS matched FROM [foo] WHERE ([name] = @p1) `+
+			`ORDER BY (SELECT NULL) OFFSET 0 ROWS FETCH NEXT 100 ROWS ONLY) AS counted`,
+		string(sql[:]),
+	)
+}
+
+func TestSqlSelectAfter(t *testing.T) {
+	assert := require.New(t)
+
+	f := filter.MustParse(`age/gt:7`)
+	f.Limit = 4
+	f.Offset = 12
+	f.After = 42
+
+	gen := NewSqlGenerator()
+	sql, err := filter.Render(gen, `foo`, f)
+	assert.NoError(err)
+
+	assert.Equal(
+		`SELECT * FROM foo `+
+			`WHERE (age > ?) `+
+			`AND (id > ?) `+
+			`ORDER BY id ASC `+
+			`LIMIT 4`,
+		string(sql[:]),
+	)
+
+	values := gen.GetValues()
+	assert.Len(values, 2)
+	assert.EqualValues(7, values[0])
+	assert.EqualValues(42, values[1])
+
+	// the original filter is left untouched
+	assert.Equal(12, f.Offset)
+	assert.Empty(f.Sort)
+
+	// only ascending order by ID is supported
+	f.Sort = []string{`-id`}
+	_, err = filter.Render(NewSqlGenerator(), `foo`, f)
+	assert.Error(err)
+
+	f.Sort = []string{`name`}
+	_, err = filter.Render(NewSqlGenerator(), `foo`, f)
+	assert.Error(err)
+
+	f.Sort = nil
+	f.Conjunction = filter.OrConjunction
+	_, err = filter.Render(NewSqlGenerator(), `foo`, f)
+	assert.Error(err)
+}
+
 func TestSqlSelectFull(t *testing.T) {
 	assert := require.New(t)
 
@@ -1014,6 +1202,83 @@ func TestSqlSelectGroupBy(t *testing.T) {
 	)
 }
 
+func TestSqlSelectJoins(t *testing.T) {
+	assert := require.New(t)
+
+	f := filter.MustParse(`orders.total/gt:100/+name/prefix:b`)
+	f.WithJoins(filter.Join{
+		Collection:  `orders`,
+		LocalField:  `id`,
+		RemoteField: `user_id`,
+	})
+
+	gen := NewSqlGenerator()
+	sql, err := filter.Render(gen, `users`, f)
+	assert.NoError(err)
+
+	assert.Equal(
+		`SELECT users.* FROM users `+
+			`INNER JOIN orders ON (users.id = orders.user_id) `+
+			`WHERE (orders.total > ?) `+
+			`AND (users.name LIKE ?) `+
+			`ORDER BY users.name ASC`,
+		string(sql[:]),
+	)
+
+	assert.Equal([]interface{}{int64(100), `b%%`}, gen.GetValues())
+
+	// fields from joined tables are returned under their qualified names
+	f = filter.All()
+	f.Fields = []string{`name`, `users.email`, `accounts.plan`}
+	f.WithJoins(filter.Join{
+		Type:       filter.LeftJoin,
+		Collection: `accounts`,
+		LocalField: `account_id`,
+	})
+
+	gen = NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	sql, err = filter.Render(gen, `users`, f)
+	assert.NoError(err)
+
+	assert.Equal(
+		`SELECT "users"."name", "users"."email", "accounts"."plan" AS "accounts.plan" FROM "users" `+
+			`LEFT JOIN "accounts" ON ("users"."account_id" = "accounts"."id")`,
+		string(sql[:]),
+	)
+
+	// invalid joins
+	f = filter.All()
+	f.WithJoins(filter.Join{
+		Type:       `outer`,
+		Collection: `accounts`,
+		LocalField: `account_id`,
+	})
+
+	_, err = filter.Render(NewSqlGenerator(), `users`, f)
+	assert.Error(err)
+
+	f = filter.All()
+	f.WithJoins(filter.Join{
+		Collection: `accounts`,
+	})
+
+	_, err = filter.Render(NewSqlGenerator(), `users`, f)
+	assert.Error(err)
+
+	// joins only apply to queries
+	f = filter.All()
+	f.WithJoins(filter.Join{
+		Collection: `accounts`,
+		LocalField: `account_id`,
+	})
+
+	gen = NewSqlGenerator()
+	gen.Type = SqlDeleteStatement
+	_, err = filter.Render(gen, `users`, f)
+	assert.Error(err)
+}
+
 func TestSqlBulkDelete(t *testing.T) {
 	assert := require.New(t)
 
@@ -1119,6 +1384,9 @@ func TestSqlToNativeType(t *testing.T) {
 		{PostgresTypeMapping, dal.ArrayType, []dal.Type{dal.IntType}, 4321, `VARCHAR(4321)`},
 		{PostgresTypeMapping, dal.RawType, nil, 0, `BYTEA`},
 		{PostgresTypeMapping, dal.RawType, nil, 256, `BYTEA(256)`},
+		{PostgresJsonTypeMapping, dal.ObjectType, []dal.Type{dal.StringType, dal.AutoType}, 0, `JSONB`},
+		{PostgresJsonTypeMapping, dal.ObjectType, []dal.Type{dal.StringType, dal.AutoType}, 123456, `JSONB`},
+		{PostgresJsonTypeMapping, dal.ArrayType, []dal.Type{dal.IntType}, 4321, `JSONB`},
 		{CassandraTypeMapping, dal.StringType, nil, 0, `VARCHAR`},
 		{CassandraTypeMapping, dal.StringType, nil, 42, `VARCHAR(42)`},
 		{CassandraTypeMapping, dal.IntType, nil, 0, `INT`},
@@ -1142,3 +1410,366 @@ func TestSqlToNativeType(t *testing.T) {
 		assert.Equal(tcase.Expected, typ, help)
 	}
 }
+
+func TestSqlPostgresJsonNestedFields(t *testing.T) {
+	assert := require.New(t)
+
+	render := func(spec string) (string, []interface{}) {
+		gen := NewSqlGenerator()
+		gen.TypeMapping = PostgresJsonTypeMapping
+
+		actual, err := filter.Render(gen, `foo`, filter.MustParse(spec))
+		assert.NoError(err)
+
+		return string(actual), gen.GetValues()
+	}
+
+	sql, values := render(`config.enabled/true`)
+	assert.Equal(`SELECT * FROM "foo" WHERE (("config"#>>'{enabled}')::BOOLEAN = $1)`, sql)
+	assert.Equal([]interface{}{true}, values)
+
+	sql, values = render(`config.limits.max/gt:10`)
+	assert.Equal(`SELECT * FROM "foo" WHERE (("config"#>>'{limits,max}')::BIGINT > $1)`, sql)
+	assert.Equal([]interface{}{int64(10)}, values)
+
+	sql, _ = render(`config.name/prefix:test`)
+	assert.Equal(`SELECT * FROM "foo" WHERE ("config"#>>'{name}' LIKE $1)`, sql)
+
+	sql, _ = render(`config.name/alpha|beta`)
+	assert.Equal(`SELECT * FROM "foo" WHERE ("config"#>>'{name}' IN($1, $2))`, sql)
+
+	// keys are escaped
+	sql, _ = render(`config.it's/ok`)
+	assert.Equal(`SELECT * FROM "foo" WHERE ("config"#>>'{it''s}' = $1)`, sql)
+
+	// top-level fields are unaffected
+	sql, _ = render(`enabled/true`)
+	assert.Equal(`SELECT * FROM "foo" WHERE ("enabled" = $1)`, sql)
+}
+
+func TestSqlSelectGroups(t *testing.T) {
+	assert := require.New(t)
+
+	gen := NewSqlGenerator()
+	sql, err := filter.Render(gen, `foo`, filter.MustParse(`(a/1|b/2)/and/(c/3)`))
+	assert.NoError(err)
+	assert.Equal(`SELECT * FROM foo WHERE (c = ?) AND ((a = ?) OR (b = ?))`, string(sql[:]))
+
+	values := gen.GetValues()
+	assert.Len(values, 3)
+	assert.EqualValues(3, values[0])
+	assert.EqualValues(1, values[1])
+	assert.EqualValues(2, values[2])
+
+	gen = NewSqlGenerator()
+	sql, err = filter.Render(gen, `foo`, filter.MustParse(`(a/1)/or/((b/2/c/3)|d/4|5)`))
+	assert.NoError(err)
+	assert.Equal(`SELECT * FROM foo WHERE (a = ?) OR ((d IN(?, ?)) OR ((b = ?) AND (c = ?)))`, string(sql[:]))
+
+	// numbered placeholders follow the order values appear in
+	gen = NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	sql, err = filter.Render(gen, `foo`, filter.MustParse(`(a/1|b/2)/and/(c/3)`))
+	assert.NoError(err)
+	assert.Equal(`SELECT * FROM "foo" WHERE ("c" = $1) AND (("a" = $2) OR ("b" = $3))`, string(sql[:]))
+
+	// filter-level OR conjunctions apply to top-level criteria
+	f := filter.MustParse(`a/1/b/2`)
+	f.Conjunction = filter.OrConjunction
+
+	sql, err = filter.Render(NewSqlGenerator(), `foo`, f)
+	assert.NoError(err)
+	assert.Equal(`SELECT * FROM foo WHERE (a = ?) OR (b = ?)`, string(sql[:]))
+}
+
+func TestSqlSelectContainment(t *testing.T) {
+	assert := require.New(t)
+
+	gen := NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	sql, err := filter.Render(gen, `foo`, filter.MustParse(`tags/in-array:red|blue/int:sizes/in-array:4`))
+	assert.NoError(err)
+	assert.Equal(
+		`SELECT * FROM "foo" WHERE ("tags"::jsonb @> $1::jsonb OR "tags"::jsonb @> $2::jsonb) AND ("sizes"::jsonb @> $3::jsonb)`,
+		string(sql[:]),
+	)
+	assert.Equal([]interface{}{`["red"]`, `["blue"]`, `[4]`}, gen.GetValues())
+
+	gen = NewSqlGenerator()
+	gen.TypeMapping = PostgresJsonTypeMapping
+	sql, err = filter.Render(gen, `foo`, filter.MustParse(`tags/not-in-array:red/config/contains-key:enabled`))
+	assert.NoError(err)
+	assert.Equal(
+		`SELECT * FROM "foo" WHERE ("tags" IS NULL OR (NOT ("tags" @> $1::jsonb))) AND ("config" ? $2)`,
+		string(sql[:]),
+	)
+	assert.Equal([]interface{}{`["red"]`, `enabled`}, gen.GetValues())
+
+	gen = NewSqlGenerator()
+	gen.TypeMapping = MysqlTypeMapping
+	sql, err = filter.Render(gen, `foo`, filter.MustParse(`tags/in-array:red`))
+	assert.NoError(err)
+	assert.Equal("SELECT * FROM `foo` WHERE (JSON_CONTAINS(CONVERT(`tags` USING utf8mb4), ?))", string(sql[:]))
+
+	// mappings that don't declare how to test containment can't use these operators
+	_, err = filter.Render(NewSqlGenerator(), `foo`, filter.MustParse(`tags/in-array:red`))
+	assert.Error(err)
+}
+
+func TestSqlSelectFulltext(t *testing.T) {
+	assert := require.New(t)
+
+	// results are ranked by relevance, whose values precede those of the criteria
+	gen := NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	gen.ScoreField = `score`
+	sql, err := filter.Render(gen, `foo`, filter.MustParse(`enabled/true/title/fulltext:hello world`))
+	assert.NoError(err)
+	assert.Equal(
+		`SELECT *, COALESCE(ts_rank(to_tsvector("title"), websearch_to_tsquery($1)), 0) AS "score" FROM "foo" `+
+			`WHERE ("enabled" = $2) AND (to_tsvector("title") @@ websearch_to_tsquery($3)) ORDER BY "score" DESC`,
+		string(sql[:]),
+	)
+	assert.Equal([]interface{}{`hello world`, true, `hello world`}, gen.GetValues())
+
+	// searching all fields combines the declared search fields
+	gen = NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	gen.SearchFields = []string{`title`, `body`}
+	sql, err = filter.Render(gen, `foo`, filter.New().Search(`hello`).SortBy(`-id`))
+	assert.NoError(err)
+	assert.Equal(
+		`SELECT * FROM "foo" WHERE (to_tsvector(concat_ws(' ', "title", "body")) @@ websearch_to_tsquery($1)) ORDER BY "id" DESC`,
+		string(sql[:]),
+	)
+	assert.Equal([]interface{}{`hello`}, gen.GetValues())
+
+	gen = NewSqlGenerator()
+	gen.TypeMapping = MysqlTypeMapping
+	gen.SearchFields = []string{`title`, `body`}
+	gen.ScoreField = `score`
+	sql, err = filter.Render(gen, `foo`, filter.New().Search(`hello`).SortBy(`-id`))
+	assert.NoError(err)
+	assert.Equal(
+		"SELECT *, COALESCE(MATCH(`title`, `body`) AGAINST(? IN NATURAL LANGUAGE MODE), 0) AS `score` FROM `foo` "+
+			"WHERE (MATCH(`title`, `body`) AGAINST(? IN NATURAL LANGUAGE MODE)) ORDER BY `id` DESC",
+		string(sql[:]),
+	)
+
+	// SQLite searches a separate FTS5 table
+	gen = NewSqlGenerator()
+	gen.TypeMapping = SqliteTypeMapping
+	sql, err = filter.Render(gen, `foo`, filter.MustParse(`title/fulltext:hello|goodbye`))
+	assert.NoError(err)
+	assert.Equal(
+		`SELECT * FROM "foo" WHERE ("foo".rowid IN (SELECT rowid FROM "foo_fts" WHERE "title" MATCH ?) OR `+
+			`"foo".rowid IN (SELECT rowid FROM "foo_fts" WHERE "title" MATCH ?))`,
+		string(sql[:]),
+	)
+	assert.Equal([]interface{}{`hello`, `goodbye`}, gen.GetValues())
+
+	// counting doesn't rank
+	gen = NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	gen.ScoreField = `score`
+	gen.Count = true
+	sql, err = filter.Render(gen, `foo`, filter.MustParse(`title/fulltext:hello`))
+	assert.NoError(err)
+	assert.Equal(`SELECT COUNT(1) FROM "foo" WHERE (to_tsvector("title") @@ websearch_to_tsquery($1))`, string(sql[:]))
+	assert.Equal([]interface{}{`hello`}, gen.GetValues())
+
+	// searching all fields requires knowing what they are
+	_, err = filter.Render(NewSqlGenerator(), `foo`, filter.New().Search(`hello`))
+	assert.Error(err)
+
+	gen = NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	_, err = filter.Render(gen, `foo`, filter.New().Search(`hello`))
+	assert.Error(err)
+}
+
+func TestSqlSelectNear(t *testing.T) {
+	assert := require.New(t)
+
+	gen := NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	sql, err := filter.Render(gen, `places`, filter.MustParse(`location/near:40.7128,-74.006,5km|51.5074,-0.1278,100/name/London`))
+	assert.NoError(err)
+	assert.Equal(
+		`SELECT * FROM "places" WHERE (ST_DWithin("location", ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, $3) OR `+
+			`ST_DWithin("location", ST_SetSRID(ST_MakePoint($4, $5), 4326)::geography, $6)) AND ("name" = $7)`,
+		string(sql[:]),
+	)
+	assert.Equal([]interface{}{-74.006, 40.7128, 5000.0, -0.1278, 51.5074, 100.0, `London`}, gen.GetValues())
+
+	nativeType, err := gen.ToNativeType(dal.GeopointType, nil, 0)
+	assert.NoError(err)
+	assert.Equal(`GEOGRAPHY(POINT, 4326)`, nativeType)
+
+	value, err := gen.PrepareInputValue(`location`, dal.Geopoint{Latitude: 40.7128, Longitude: -74.006})
+	assert.NoError(err)
+	assert.Equal(`SRID=4326;POINT(-74.006 40.7128)`, value)
+
+	// mappings without native geospatial types store geopoints as JSON, and can't query them
+	gen = NewSqlGenerator()
+	gen.TypeMapping = SqliteTypeMapping
+
+	nativeType, err = gen.ToNativeType(dal.GeopointType, nil, 0)
+	assert.NoError(err)
+	assert.Equal(`BLOB`, nativeType)
+
+	value, err = gen.PrepareInputValue(`location`, dal.Geopoint{Latitude: 40.7128, Longitude: -74.006})
+	assert.NoError(err)
+	assert.JSONEq(`{"lat":40.7128,"lon":-74.006}`, string(value.([]byte)))
+
+	_, err = filter.Render(gen, `places`, filter.MustParse(`location/near:40.7128,-74.006,5km`))
+	assert.Error(err)
+}
+
+func TestSqlSelectAsOfSystemTime(t *testing.T) {
+	assert := require.New(t)
+
+	gen := NewSqlGenerator()
+	gen.TypeMapping = CockroachTypeMapping
+	gen.AsOfSystemTime = `follower_read_timestamp()`
+
+	sql, err := filter.Render(gen, `foo`, filter.MustParse(`name/Bob`))
+	assert.NoError(err)
+	assert.Equal(`SELECT * FROM "foo" AS OF SYSTEM TIME follower_read_timestamp() WHERE ("name" = $1)`, string(sql[:]))
+
+	// limited counts give the clause on the outer statement
+	gen = NewSqlGenerator()
+	gen.TypeMapping = CockroachTypeMapping
+	gen.AsOfSystemTime = `follower_read_timestamp()`
+	gen.Count = true
+	gen.CountLimit = 10
+
+	sql, err = filter.Render(gen, `foo`, filter.MustParse(`name/Bob`))
+	assert.NoError(err)
+	assert.Equal(
+		`SELECT COUNT(1) FROM (SELECT 1 AS matched FROM "foo" WHERE ("name" = $1) LIMIT 10) AS counted AS OF SYSTEM TIME follower_read_timestamp()`,
+		string(sql[:]),
+	)
+
+	// ...and it is never added to writes
+	gen = NewSqlGenerator()
+	gen.TypeMapping = CockroachTypeMapping
+	gen.AsOfSystemTime = `follower_read_timestamp()`
+	gen.Type = SqlDeleteStatement
+
+	sql, err = filter.Render(gen, `foo`, filter.MustParse(`name/Bob`))
+	assert.NoError(err)
+	assert.Equal(`DELETE FROM "foo" WHERE ("name" = $1)`, string(sql[:]))
+}
+
+func TestSqlSelectLockRows(t *testing.T) {
+	assert := require.New(t)
+
+	gen := NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	gen.LockRows = true
+
+	sql, err := filter.Render(gen, `foo`, filter.MustParse(`name/Bob`))
+	assert.NoError(err)
+	assert.Equal(`SELECT * FROM "foo" WHERE ("name" = $1) FOR UPDATE`, string(sql[:]))
+
+	// databases that can't lock rows read them as usual
+	gen = NewSqlGenerator()
+	gen.LockRows = true
+
+	sql, err = filter.Render(gen, `foo`, filter.MustParse(`name/Bob`))
+	assert.NoError(err)
+	assert.Equal(`SELECT * FROM foo WHERE (name = ?)`, string(sql[:]))
+}
+
+func TestSqlLargeInClause(t *testing.T) {
+	assert := require.New(t)
+
+	values := make([]string, 10000)
+
+	for i := range values {
+		values[i] = fmt.Sprintf("%d", i)
+	}
+
+	f, err := filter.Parse(`id/` + strings.Join(values, `|`))
+	assert.NoError(err)
+
+	gen := NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	actual, err := filter.Render(gen, `foo`, f)
+	assert.NoError(err)
+
+	stmt := string(actual[:])
+	assert.True(strings.HasPrefix(stmt, `SELECT * FROM "foo" WHERE ("id" IN($1, $2, $3, `))
+	assert.True(strings.HasSuffix(stmt, `, $9999, $10000))`))
+	assert.NotContains(stmt, "⦃")
+	assert.Len(gen.GetValues(), 10000)
+
+	// long lists are split into several IN() lists
+	assert.Equal(10, strings.Count(stmt, `"id" IN(`))
+	assert.Contains(stmt, `, $1000) OR "id" IN($1001, `)
+}
+
+func TestSqlManyPlaceholders(t *testing.T) {
+	assert := require.New(t)
+
+	values := make([]string, 20000)
+
+	for i := range values {
+		values[i] = fmt.Sprintf("%d", i)
+	}
+
+	gen := NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+	actual, err := filter.Render(gen, `foo`, filter.MustParse(`id/`+strings.Join(values, `|`)))
+	assert.NoError(err)
+
+	// every value gets a placeholder, however many there are
+	stmt := string(actual[:])
+	assert.NotContains(stmt, "⦃")
+	assert.True(strings.HasSuffix(stmt, `, $19999, $20000))`))
+	assert.Len(gen.GetValues(), 20000)
+}
+
+func TestSqlInClauseChunking(t *testing.T) {
+	assert := require.New(t)
+
+	defer func(max int) {
+		SqlMaxInValues = max
+	}(SqlMaxInValues)
+
+	SqlMaxInValues = 2
+
+	gen := NewSqlGenerator()
+	actual, err := filter.Render(gen, `foo`, filter.MustParse(`id/1|2|3|4|5`))
+	assert.NoError(err)
+	assert.Equal(`SELECT * FROM foo WHERE (id IN(?, ?) OR id IN(?, ?) OR id IN(?))`, string(actual[:]))
+	assert.Equal([]interface{}{int64(1), int64(2), int64(3), int64(4), int64(5)}, gen.GetValues())
+
+	gen = NewSqlGenerator()
+	actual, err = filter.Render(gen, `foo`, filter.MustParse(`id/not:1|2|3`))
+	assert.NoError(err)
+	assert.Equal(`SELECT * FROM foo WHERE (id NOT IN(?, ?) AND id NOT IN(?))`, string(actual[:]))
+}
+
+func BenchmarkSqlLargeInClause(b *testing.B) {
+	values := make([]string, 10000)
+
+	for i := range values {
+		values[i] = fmt.Sprintf("%d", i)
+	}
+
+	f := filter.MustParse(`id/` + strings.Join(values, `|`))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		gen := NewSqlGenerator()
+		gen.TypeMapping = PostgresTypeMapping
+
+		if _, err := filter.Render(gen, `foo`, f); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// The units that the radius of a "near" criterion may be given in, and their length in meters.
+// Radii without a unit are in meters.
+var DistanceUnits = map[string]float64{
+	`m`:  1,
+	`km`: 1000,
+	`mi`: 1609.344,
+	`ft`: 0.3048,
+}
+
+// A circular area on the surface of the Earth, as given by the values of "near" criteria
+// (e.g.: "location/near:40.7128,-74.0060,5km").
+type GeoDistance struct {
+	Point  dal.Geopoint `json:"point"`
+	Radius float64      `json:"radius"` // in meters
+}
+
+// Parses a "near" criterion value of the form "lat,lon,radius".
+func ParseGeoDistance(value interface{}) (GeoDistance, error) {
+	var distance GeoDistance
+	var parts = strings.Split(typeutil.String(value), `,`)
+
+	if len(parts) != 3 {
+		return distance, fmt.Errorf("near requires values of the form lat,lon,radius, got %q", typeutil.String(value))
+	}
+
+	if point, err := dal.ParseGeopoint(parts[0] + `,` + parts[1]); err == nil {
+		distance.Point = point
+	} else {
+		return distance, err
+	}
+
+	var radius = strings.ToLower(strings.TrimSpace(parts[2]))
+	var factor float64 = 1
+
+	for unit, meters := range DistanceUnits {
+		if strings.HasSuffix(radius, unit) {
+			if n := strings.TrimSuffix(radius, unit); n != `` && strings.IndexAny(n[len(n)-1:], `0123456789.`) == 0 {
+				radius = n
+				factor = meters
+				break
+			}
+		}
+	}
+
+	if r, err := strconv.ParseFloat(radius, 64); err == nil && r >= 0 {
+		distance.Radius = r * factor
+	} else {
+		return distance, fmt.Errorf("Invalid radius %q", parts[2])
+	}
+
+	return distance, nil
+}
+
+// Returns whether the given point is within the area.
+func (self GeoDistance) Contains(point dal.Geopoint) bool {
+	return self.Point.Distance(point) <= self.Radius
+}
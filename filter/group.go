@@ -0,0 +1,421 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Groups of criteria are enclosed in these delimiters in filter specs.  Criteria within a group
+// that are separated by ValueSeparator must match any one of them (e.g.: "(a/1|b/2)"), and groups
+// are combined with other groups and criteria using the "and" and "or" keywords
+// (e.g.: "(a/1|b/2)/and/(c/3)").
+var GroupOpen = `(`
+var GroupClose = `)`
+
+// A set of criteria and nested groups, all of which are combined using the group's conjunction.
+type Group struct {
+	Conjunction ConjunctionType `json:"conjunction,omitempty"`
+	Criteria    []Criterion     `json:"criteria,omitempty"`
+	Groups      []Group         `json:"groups,omitempty"`
+}
+
+// Return a group that matches if all of the given criteria match.
+func And(criteria ...Criterion) Group {
+	return Group{
+		Conjunction: AndConjunction,
+		Criteria:    criteria,
+	}
+}
+
+// Return a group that matches if any of the given criteria match.
+func Or(criteria ...Criterion) Group {
+	return Group{
+		Conjunction: OrConjunction,
+		Criteria:    criteria,
+	}
+}
+
+// Return a copy of this group with the given groups nested in it.
+func (self Group) WithGroups(groups ...Group) Group {
+	self.Groups = append(append([]Group{}, self.Groups...), groups...)
+	return self
+}
+
+// Return whether the group contains no criteria at any level of nesting.
+func (self Group) IsEmpty() bool {
+	if len(self.Criteria) > 0 {
+		return false
+	}
+
+	for _, group := range self.Groups {
+		if !group.IsEmpty() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Return the names of the fields used by all criteria in this group and its nested groups.
+func (self Group) Fields() []string {
+	var fields = make([]string, 0)
+
+	for _, criterion := range self.allCriteria() {
+		fields = append(fields, criterion.Field)
+	}
+
+	return fields
+}
+
+// return the criteria in this group and all of its nested groups
+func (self Group) allCriteria() []Criterion {
+	var criteria = append([]Criterion{}, self.Criteria...)
+
+	for _, group := range self.Groups {
+		criteria = append(criteria, group.allCriteria()...)
+	}
+
+	return criteria
+}
+
+func (self Group) String() string {
+	if self.Conjunction == OrConjunction {
+		var members = make([]string, 0)
+
+		for _, criterion := range self.Criteria {
+			members = append(members, criterion.String())
+		}
+
+		for _, group := range self.Groups {
+			members = append(members, group.String())
+		}
+
+		return GroupOpen + strings.Join(members, ValueSeparator) + GroupClose
+	} else {
+		return GroupOpen + self.expression() + GroupClose
+	}
+}
+
+// render the group as it appears at the top level of a filter spec; that is: without enclosing
+// delimiters
+func (self Group) expression() string {
+	var operands = make([]string, 0)
+	var keyword = `and`
+
+	if self.Conjunction == OrConjunction {
+		keyword = `or`
+
+		// the "or" keyword can only appear next to a group, so bare criteria are grouped together
+		if len(self.Criteria) > 0 {
+			operands = append(operands, Or(self.Criteria...).String())
+		}
+	} else if len(self.Criteria) > 0 {
+		var criteria = make([]string, 0)
+
+		for _, criterion := range self.Criteria {
+			criteria = append(criteria, criterion.String())
+		}
+
+		operands = append(operands, strings.Join(criteria, CriteriaSeparator))
+	}
+
+	for _, group := range self.Groups {
+		operands = append(operands, group.String())
+	}
+
+	return strings.Join(operands, CriteriaSeparator+keyword+CriteriaSeparator)
+}
+
+// Add groups of criteria to the filter.  Groups are combined with the filter's criteria using the
+// filter's conjunction.
+func (self *Filter) AddGroups(groups ...Group) *Filter {
+	if self.Spec == AllValue {
+		self.Spec = ``
+	}
+
+	self.MatchAll = false
+	self.Groups = append(self.Groups, groups...)
+	return self
+}
+
+// return a group containing all of the filter's criteria and groups
+func (self *Filter) rootGroup() Group {
+	return Group{
+		Conjunction: self.Conjunction,
+		Criteria:    self.Criteria,
+		Groups:      self.Groups,
+	}
+}
+
+// whether the given spec contains any groups, which is the case if a token that would otherwise be
+// a field name (or that follows a conjunction keyword) starts with GroupOpen
+func isGroupedSpec(spec string) bool {
+	var tokens = splitSpec(spec)
+
+	for i, token := range tokens {
+		if strings.HasPrefix(token, GroupOpen) {
+			if i%2 == 0 {
+				return true
+			}
+
+			switch strings.ToLower(tokens[i-1]) {
+			case `and`, `or`:
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// split the given string on a separator, ignoring any separators that appear within groups
+func splitOutsideGroups(in string, sep string) ([]string, error) {
+	var parts = make([]string, 0)
+	var depth int
+	var last int
+
+	for i := 0; i < len(in); i++ {
+		switch {
+		case strings.HasPrefix(in[i:], GroupOpen):
+			depth += 1
+		case strings.HasPrefix(in[i:], GroupClose):
+			depth -= 1
+
+			if depth < 0 {
+				return nil, fmt.Errorf("unexpected %q at position %d", GroupClose, i)
+			}
+		case depth == 0 && strings.HasPrefix(in[i:], sep):
+			parts = append(parts, in[last:i])
+			last = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+
+	if depth > 0 {
+		return nil, fmt.Errorf("unterminated group: missing %q", GroupClose)
+	}
+
+	return append(parts, in[last:]), nil
+}
+
+// whether the given token is a complete group
+func isGroupToken(token string) bool {
+	return strings.HasPrefix(token, GroupOpen) && strings.HasSuffix(token, GroupClose)
+}
+
+// parse a filter spec containing groups into a single group.  Criteria and groups are combined
+// using the "and" or "or" keyword that appears between them; those that appear next to each other
+// without a keyword are combined with AND.  Different conjunctions cannot be mixed at the same
+// level, so expressions like "(a/1)/and/(b/2)/or/(c/3)" must be explicitly grouped.
+func parseGroupExpression(spec string, into *Filter) (Group, error) {
+	var group Group
+	var tokens []string
+	var run []string
+	var lastWasGroup bool
+	var conjunction *ConjunctionType
+	var implicitAnd bool
+
+	if t, err := splitOutsideGroups(spec, CriteriaSeparator); err == nil {
+		tokens = t
+	} else {
+		return group, err
+	}
+
+	// whether tokens in a run alternate between field names and values
+	var pairedTokens = (CriteriaSeparator == FieldTermSeparator)
+
+	var flushRun = func() error {
+		if len(run) == 0 {
+			return nil
+		}
+
+		if f, err := ParseSpec(strings.Join(run, CriteriaSeparator)); err == nil {
+			if len(f.Criteria) == 1 {
+				group.Criteria = append(group.Criteria, f.Criteria...)
+			} else {
+				group.Groups = append(group.Groups, And(f.Criteria...))
+			}
+
+			into.Sort = append(into.Sort, f.Sort...)
+			run = nil
+			return nil
+		} else {
+			return err
+		}
+	}
+
+	var join = func(c *ConjunctionType) error {
+		if c == nil {
+			implicitAnd = true
+		} else if conjunction != nil && *conjunction != *c {
+			return fmt.Errorf("cannot mix 'and' and 'or' without grouping: %s", spec)
+		} else {
+			conjunction = c
+		}
+
+		if implicitAnd && conjunction != nil && *conjunction == OrConjunction {
+			return fmt.Errorf("cannot mix 'and' and 'or' without grouping: %s", spec)
+		}
+
+		return nil
+	}
+
+	var operands int
+	var pendingKeyword bool
+
+	for i, token := range tokens {
+		var fieldPosition = (!pairedTokens || len(run)%2 == 0)
+
+		if fieldPosition {
+			var keyword *ConjunctionType
+
+			switch strings.ToLower(token) {
+			case `and`:
+				var c = AndConjunction
+				keyword = &c
+			case `or`:
+				var c ConjunctionType = OrConjunction
+				keyword = &c
+			}
+
+			if keyword != nil && !pendingKeyword && operands > 0 {
+				var nextIsGroup = (i+1 < len(tokens) && isGroupToken(tokens[i+1]))
+
+				if !pairedTokens || lastWasGroup || nextIsGroup {
+					if err := flushRun(); err != nil {
+						return group, err
+					} else if err := join(keyword); err != nil {
+						return group, err
+					}
+
+					pendingKeyword = true
+					lastWasGroup = false
+					continue
+				}
+			}
+
+			if isGroupToken(token) {
+				if err := flushRun(); err != nil {
+					return group, err
+				}
+
+				if operands > 0 && !pendingKeyword {
+					if err := join(nil); err != nil {
+						return group, err
+					}
+				}
+
+				if subgroup, err := parseGroupBody(token[len(GroupOpen):len(token)-len(GroupClose)], into); err == nil {
+					if len(subgroup.Groups) == 0 && len(subgroup.Criteria) == 1 {
+						group.Criteria = append(group.Criteria, subgroup.Criteria...)
+					} else if !subgroup.IsEmpty() {
+						group.Groups = append(group.Groups, subgroup)
+					}
+				} else {
+					return group, err
+				}
+
+				operands += 1
+				pendingKeyword = false
+				lastWasGroup = true
+				continue
+			}
+
+			// a bare criterion following a group without a keyword is implicitly ANDed
+			if len(run) == 0 {
+				if operands > 0 && !pendingKeyword {
+					if err := join(nil); err != nil {
+						return group, err
+					}
+				}
+
+				operands += 1
+				pendingKeyword = false
+			}
+		}
+
+		lastWasGroup = false
+		run = append(run, token)
+	}
+
+	if pendingKeyword {
+		return group, fmt.Errorf("expected a criterion or group after the last keyword: %s", spec)
+	} else if err := flushRun(); err != nil {
+		return group, err
+	}
+
+	if conjunction != nil {
+		group.Conjunction = *conjunction
+	}
+
+	// AND groups nested in an AND expression can be flattened into it
+	if group.Conjunction != OrConjunction {
+		var nested = group.Groups
+
+		group.Groups = nil
+
+		for _, subgroup := range nested {
+			if subgroup.Conjunction == OrConjunction {
+				group.Groups = append(group.Groups, subgroup)
+			} else {
+				group.Criteria = append(group.Criteria, subgroup.Criteria...)
+				group.Groups = append(group.Groups, subgroup.Groups...)
+			}
+		}
+	}
+
+	return group, nil
+}
+
+// parse the contents of a group.  Members separated by ValueSeparator are ORed together, except
+// that a member that is just a value (e.g.: the "2" in "(a/1|2|b/3)") is another value of the
+// criterion before it.
+func parseGroupBody(body string, into *Filter) (Group, error) {
+	var members = make([]string, 0)
+
+	if segments, err := splitOutsideGroups(body, ValueSeparator); err == nil {
+		for _, segment := range segments {
+			if len(members) > 0 && !isGroupMember(segment) {
+				members[len(members)-1] += ValueSeparator + segment
+			} else {
+				members = append(members, segment)
+			}
+		}
+	} else {
+		return Group{}, err
+	}
+
+	if len(members) == 1 {
+		return parseGroupExpression(members[0], into)
+	}
+
+	var group = Or()
+
+	for _, member := range members {
+		if subgroup, err := parseGroupExpression(member, into); err == nil {
+			if len(subgroup.Groups) == 0 && len(subgroup.Criteria) == 1 {
+				group.Criteria = append(group.Criteria, subgroup.Criteria...)
+			} else if !subgroup.IsEmpty() {
+				group.Groups = append(group.Groups, subgroup)
+			}
+		} else {
+			return group, err
+		}
+	}
+
+	return group, nil
+}
+
+// whether the given segment of a group's contents is a criterion or group, rather than an
+// additional value of the preceding criterion
+func isGroupMember(segment string) bool {
+	if strings.HasPrefix(segment, GroupOpen) {
+		return true
+	} else if parts, err := splitOutsideGroups(segment, CriteriaSeparator); err == nil && len(parts) > 1 {
+		return true
+	} else if strings.Contains(segment, FieldTermSeparator) {
+		return true
+	}
+
+	return false
+}
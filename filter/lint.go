@@ -0,0 +1,198 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// The operators supported by the filter syntax.
+var Operators = []string{
+	`is`,
+	`not`,
+	`contains`,
+	`like`,
+	`unlike`,
+	`prefix`,
+	`suffix`,
+	`gt`,
+	`gte`,
+	`lt`,
+	`lte`,
+	`range`,
+	`fulltext`,
+	`in-array`,
+	`not-in-array`,
+	`contains-key`,
+	`near`,
+}
+
+// Describes a problem found in a filter spec.
+type LintIssue struct {
+	Criterion int    `json:"criterion"` // the zero-based position of the offending criterion
+	Field     string `json:"field,omitempty"`
+	Message   string `json:"message"`
+}
+
+func (self LintIssue) String() string {
+	if self.Field != `` {
+		return fmt.Sprintf("criterion %d (%s): %s", self.Criterion+1, self.Field, self.Message)
+	} else {
+		return fmt.Sprintf("criterion %d: %s", self.Criterion+1, self.Message)
+	}
+}
+
+// Parses the given filter spec and reports problems with it: unknown operators and types, fields
+// without values, range criteria that don't have exactly two values, and near criteria whose values
+// aren't of the form "lat,lon,radius".  If a collection is given, fields it does not define and
+// types that don't match its fields' types are also reported.  An error is only returned if the
+// spec cannot be parsed at all.
+func Lint(spec string, collection *dal.Collection) ([]LintIssue, error) {
+	var issues = make([]LintIssue, 0)
+
+	spec = strings.TrimPrefix(spec, `/`)
+
+	f, err := ParseSpec(spec)
+
+	if err != nil {
+		return nil, err
+	} else if spec == `` || f.IsMatchAll() {
+		return issues, nil
+	}
+
+	var criteria = f.rootGroup().allCriteria()
+
+	for i, criterion := range criteria {
+		var issue = func(format string, args ...interface{}) {
+			issues = append(issues, LintIssue{
+				Criterion: i,
+				Field:     criterion.Field,
+				Message:   fmt.Sprintf(format, args...),
+			})
+		}
+
+		if criterion.Field == `` {
+			issue("field name is empty")
+		}
+
+		if criterion.Operator != `` && !sliceutil.ContainsString(Operators, criterion.Operator) {
+			issue("unknown operator %q", criterion.Operator)
+		}
+
+		if criterion.Type != dal.AutoType && dal.ParseFieldType(string(criterion.Type)) == `` {
+			issue("unknown type %q", criterion.Type)
+		}
+
+		if criterion.Operator == `range` && len(criterion.Values) != 2 {
+			issue("range requires exactly 2 values, got %d", len(criterion.Values))
+		}
+
+		if criterion.Operator == `near` {
+			for _, value := range criterion.Values {
+				if _, err := ParseGeoDistance(value); err != nil {
+					issue("%v", err)
+					break
+				}
+			}
+		}
+
+		if collection != nil && criterion.Field != `` && criterion.Field != collection.GetIdentityFieldName() {
+			if field, ok := collection.GetField(criterion.Field); !ok {
+				issue("field is not defined in collection %q", collection.Name)
+			} else if (criterion.Operator == `in-array` || criterion.Operator == `not-in-array`) && field.Type != dal.ArrayType {
+				issue("%s can only be used on array fields", criterion.Operator)
+			} else if criterion.Operator == `contains-key` && field.Type != dal.ObjectType {
+				issue("contains-key can only be used on object fields")
+			} else if criterion.Operator == `near` && field.Type != dal.GeopointType {
+				issue("near can only be used on geopoint fields")
+			} else if criterion.Type != dal.AutoType && criterion.Type != field.Type && !IsContainmentOperator(criterion.Operator) {
+				issue("type %v does not match the field's type (%v)", criterion.Type, field.Type)
+			}
+		}
+	}
+
+	// the parser silently ignores a field that isn't followed by a value
+	if tokens := splitSpec(spec); len(tokens)%2 != 0 && !isGroupedSpec(spec) {
+		var last = tokens[len(tokens)-1]
+
+		if last == `` {
+			issues = append(issues, LintIssue{
+				Criterion: len(criteria),
+				Message:   `trailing separator`,
+			})
+		} else {
+			_, name := SplitModifierToken(strings.TrimLeft(last, SortAscending+SortDescending))
+
+			issues = append(issues, LintIssue{
+				Criterion: len(criteria),
+				Field:     name,
+				Message:   `no value given`,
+			})
+		}
+	}
+
+	return issues, nil
+}
+
+// Returns the canonical form of the given filter spec.  Leading and trailing separators are
+// removed, as are the implicit "auto" field type and "is" operator.  Criteria are not reordered,
+// since their order determines sort precedence, and the meaning of the spec is never changed.
+func Format(spec string) (string, error) {
+	spec = strings.TrimPrefix(spec, `/`)
+	spec = strings.TrimRight(spec, CriteriaSeparator)
+
+	if f, err := ParseSpec(spec); err != nil {
+		return ``, err
+	} else if spec == `` {
+		return ``, nil
+	} else if f.IsMatchAll() {
+		return AllValue, nil
+	} else if isGroupedSpec(spec) {
+		// specs containing groups are left as they are
+		return spec, nil
+	}
+
+	var tokens = splitSpec(spec)
+	var criteria = make([]string, 0)
+
+	for i := 0; i < len(tokens); i += 2 {
+		var criterion = formatFieldToken(tokens[i])
+
+		if i+1 < len(tokens) {
+			criterion += FieldTermSeparator + formatValueToken(tokens[i+1])
+		}
+
+		criteria = append(criteria, criterion)
+	}
+
+	return strings.Join(criteria, CriteriaSeparator), nil
+}
+
+func formatFieldToken(token string) string {
+	var prefix string
+
+	if strings.HasPrefix(token, SortAscending) {
+		prefix = SortAscending
+	} else if strings.HasPrefix(token, SortDescending) {
+		prefix = SortDescending
+	}
+
+	fType, fName := SplitModifierToken(strings.TrimPrefix(token, prefix))
+
+	if fType == `` || fType == string(dal.AutoType) {
+		return prefix + fName
+	} else {
+		return prefix + fType + ModifierDelimiter + fName
+	}
+}
+
+func formatValueToken(token string) string {
+	// only drop the "is" operator if doing so won't cause part of the value to be read as an operator
+	if operator, value := SplitModifierToken(token); operator == `is` && !strings.Contains(value, ModifierDelimiter) {
+		return value
+	}
+
+	return token
+}
@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	assert := require.New(t)
+
+	issues, err := Lint(`name/contains:bob/int:age/range:10|20`, nil)
+	assert.NoError(err)
+	assert.Empty(issues)
+
+	issues, err = Lint(`all`, nil)
+	assert.NoError(err)
+	assert.Empty(issues)
+
+	issues, err = Lint(`name/matches:bob/number:age/range:10/enabled`, nil)
+	assert.NoError(err)
+	assert.Len(issues, 4)
+	assert.Equal(`criterion 1 (name): unknown operator "matches"`, issues[0].String())
+	assert.Equal(`criterion 2 (age): unknown type "number"`, issues[1].String())
+	assert.Equal(`criterion 2 (age): range requires exactly 2 values, got 1`, issues[2].String())
+	assert.Equal(`criterion 3 (enabled): no value given`, issues[3].String())
+
+	issues, err = Lint(`name/bob/`, nil)
+	assert.NoError(err)
+	assert.Len(issues, 1)
+	assert.Equal(`trailing separator`, issues[0].Message)
+
+	collection := dal.NewCollection(`users`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `age`,
+		Type: dal.IntType,
+	})
+
+	issues, err = Lint(`id/1/name/bob/float:age/gt:21/nickname/bobby`, collection)
+	assert.NoError(err)
+	assert.Len(issues, 2)
+	assert.Equal(`age`, issues[0].Field)
+	assert.Equal(`type float does not match the field's type (int)`, issues[0].Message)
+	assert.Equal(`nickname`, issues[1].Field)
+	assert.Equal(`field is not defined in collection "users"`, issues[1].Message)
+
+	collection = dal.NewCollection(`posts`, dal.Field{
+		Name: `tags`,
+		Type: dal.ArrayType,
+	}, dal.Field{
+		Name: `title`,
+		Type: dal.StringType,
+	})
+
+	issues, err = Lint(`str:tags/in-array:red/title/contains-key:lead`, collection)
+	assert.NoError(err)
+	assert.Len(issues, 1)
+	assert.Equal(`title`, issues[0].Field)
+	assert.Equal(`contains-key can only be used on object fields`, issues[0].Message)
+
+	collection = dal.NewCollection(`places`, dal.Field{
+		Name: `location`,
+		Type: dal.GeopointType,
+	}, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})
+
+	issues, err = Lint(`location/near:40.7128,-74.006,5km/name/near:40.7128,-74.006,5km`, collection)
+	assert.NoError(err)
+	assert.Len(issues, 1)
+	assert.Equal(`name`, issues[0].Field)
+	assert.Equal(`near can only be used on geopoint fields`, issues[0].Message)
+
+	issues, err = Lint(`location/near:40.7128,-74.006`, collection)
+	assert.NoError(err)
+	assert.Len(issues, 1)
+	assert.Equal(`near requires values of the form lat,lon,radius, got "40.7128,-74.006"`, issues[0].Message)
+
+	_, err = Lint(`name`, nil)
+	assert.Error(err)
+}
+
+func TestFormat(t *testing.T) {
+	assert := require.New(t)
+
+	for in, out := range map[string]string{
+		``:                              ``,
+		`all`:                           `all`,
+		`/all`:                          `all`,
+		`/name/bob/`:                    `name/bob`,
+		`auto:name/is:bob`:              `name/bob`,
+		`-auto:name/is:bob/+int:age/21`: `-name/bob/+int:age/21`,
+		`name/is:a:b`:                   `name/is:a:b`,
+		`name/contains:bob|alice`:       `name/contains:bob|alice`,
+	} {
+		formatted, err := Format(in)
+		assert.NoError(err, in)
+		assert.Equal(out, formatted, in)
+
+		// formatting is idempotent
+		again, err := Format(formatted)
+		assert.NoError(err)
+		assert.Equal(formatted, again)
+	}
+
+	_, err := Format(`name`)
+	assert.Error(err)
+}
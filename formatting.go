@@ -0,0 +1,285 @@
+package pivot
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/httputil"
+	"github.com/ghetzel/pivot/v3/dal"
+)
+
+// The digit grouping and decimal separators numbers are formatted with in a given locale.
+type NumberLocale struct {
+	Grouping string
+	Decimal  string
+}
+
+// The locales numbers can be formatted for (with ?numfmt=locale&locale=...), keyed by lowercase
+// language tag.  Tags with a region (e.g.: "de-ch") are tried before the bare language ("de").
+// Locales that group digits with spaces use non-breaking ones.
+var NumberLocales = map[string]NumberLocale{
+	`en`:    {Grouping: `,`, Decimal: `.`},
+	`ja`:    {Grouping: `,`, Decimal: `.`},
+	`zh`:    {Grouping: `,`, Decimal: `.`},
+	`ko`:    {Grouping: `,`, Decimal: `.`},
+	`de`:    {Grouping: `.`, Decimal: `,`},
+	`de-ch`: {Grouping: `'`, Decimal: `.`},
+	`es`:    {Grouping: `.`, Decimal: `,`},
+	`it`:    {Grouping: `.`, Decimal: `,`},
+	`nl`:    {Grouping: `.`, Decimal: `,`},
+	`pt`:    {Grouping: `.`, Decimal: `,`},
+	`tr`:    {Grouping: `.`, Decimal: `,`},
+	`da`:    {Grouping: `.`, Decimal: `,`},
+	`fr`:    {Grouping: "\u00a0", Decimal: `,`},
+	`ru`:    {Grouping: "\u00a0", Decimal: `,`},
+	`pl`:    {Grouping: "\u00a0", Decimal: `,`},
+	`cs`:    {Grouping: "\u00a0", Decimal: `,`},
+	`sv`:    {Grouping: "\u00a0", Decimal: `,`},
+	`fi`:    {Grouping: "\u00a0", Decimal: `,`},
+	`nb`:    {Grouping: "\u00a0", Decimal: `,`},
+}
+
+// The locale numbers are formatted for when none (or an unknown one) is requested.
+var DefaultNumberLocale = `en`
+
+// How the dates and numbers in the records of an API response are encoded, as requested with the
+// datefmt, tz, numfmt, and locale query string parameters.
+type OutputFormat struct {
+	// One of "rfc3339", "rfc3339nano", "unix" (seconds), "epoch_ms", or a Go time layout.  Empty
+	// leaves dates as they are.
+	DateFormat string
+
+	// The time zone dates are converted to before being formatted.  Nil leaves them as they are.
+	Location *time.Location
+
+	// Encode numbers as strings.
+	NumbersAsStrings bool
+
+	// Round non-integer numbers to this many decimal places.  Negative leaves them as they are.
+	Precision int
+
+	// Format numbers as strings with the digit grouping and decimal separators of this locale.
+	Locale *NumberLocale
+}
+
+// Parses the output format requested by the given request, returning nil if none was requested.
+func OutputFormatFromRequest(req *http.Request) (*OutputFormat, error) {
+	var format = &OutputFormat{
+		Precision: -1,
+	}
+
+	var requested bool
+
+	if v := httputil.Q(req, `datefmt`); v != `` {
+		switch strings.ToLower(v) {
+		case `rfc3339`, `rfc3339nano`, `unix`, `epoch_ms`:
+			format.DateFormat = strings.ToLower(v)
+		default:
+			if !strings.Contains(v, `2006`) {
+				return nil, fmt.Errorf("invalid date format %q", v)
+			}
+
+			format.DateFormat = v
+		}
+
+		requested = true
+	}
+
+	if v := httputil.Q(req, `tz`); v != `` {
+		if loc, err := time.LoadLocation(v); err == nil {
+			format.Location = loc
+		} else {
+			return nil, fmt.Errorf("invalid time zone %q", v)
+		}
+
+		requested = true
+	}
+
+	for _, option := range httputil.QStrings(req, `numfmt`, `,`) {
+		option = strings.TrimSpace(option)
+
+		switch {
+		case option == ``:
+			continue
+		case option == `string`:
+			format.NumbersAsStrings = true
+		case option == `locale`:
+			var tag = strings.ToLower(strings.Replace(httputil.Q(req, `locale`, DefaultNumberLocale), `_`, `-`, -1))
+
+			if locale, ok := NumberLocales[tag]; ok {
+				format.Locale = &locale
+			} else if locale, ok := NumberLocales[strings.SplitN(tag, `-`, 2)[0]]; ok {
+				format.Locale = &locale
+			} else {
+				locale = NumberLocales[DefaultNumberLocale]
+				format.Locale = &locale
+			}
+		case strings.HasPrefix(option, `fixed:`):
+			if n, err := strconv.Atoi(strings.TrimPrefix(option, `fixed:`)); err == nil && n >= 0 {
+				format.Precision = n
+			} else {
+				return nil, fmt.Errorf("invalid number precision %q", option)
+			}
+		default:
+			return nil, fmt.Errorf("invalid number format %q", option)
+		}
+
+		requested = true
+	}
+
+	if requested {
+		return format, nil
+	} else {
+		return nil, nil
+	}
+}
+
+// Returns a copy of the given response data with the dates and numbers in its records' fields
+// formatted.  Records are copied rather than modified, since they may be shared (e.g.: by a
+// cache).  Data other than records and recordsets is returned as-is.
+func (self *OutputFormat) Apply(data interface{}) interface{} {
+	switch v := data.(type) {
+	case *dal.Record:
+		return self.formatRecord(v)
+	case *dal.RecordSet:
+		var recordset = *v
+		var records = make([]*dal.Record, len(recordset.Records))
+
+		for i, record := range recordset.Records {
+			records[i] = self.formatRecord(record)
+		}
+
+		recordset.Records = records
+		return &recordset
+	default:
+		return data
+	}
+}
+
+func (self *OutputFormat) formatRecord(record *dal.Record) *dal.Record {
+	if record == nil {
+		return nil
+	}
+
+	var out = *record
+
+	if record.Fields != nil {
+		out.Fields = self.formatValue(record.Fields).(map[string]interface{})
+	}
+
+	return &out
+}
+
+func (self *OutputFormat) formatValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return self.formatTime(v)
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+
+		return self.formatTime(*v)
+	case map[string]interface{}:
+		var out = make(map[string]interface{}, len(v))
+
+		for k, item := range v {
+			out[k] = self.formatValue(item)
+		}
+
+		return out
+	case []interface{}:
+		var out = make([]interface{}, len(v))
+
+		for i, item := range v {
+			out[i] = self.formatValue(item)
+		}
+
+		return out
+	}
+
+	switch rv := reflect.ValueOf(value); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return self.formatNumber(strconv.FormatInt(rv.Int(), 10), rv.Interface())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return self.formatNumber(strconv.FormatUint(rv.Uint(), 10), rv.Interface())
+	case reflect.Float32, reflect.Float64:
+		var f = rv.Float()
+
+		if self.Precision >= 0 {
+			var rounded, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'f', self.Precision, 64), 64)
+
+			if self.NumbersAsStrings || self.Locale != nil {
+				return self.formatNumber(strconv.FormatFloat(f, 'f', self.Precision, 64), rounded)
+			}
+
+			return rounded
+		}
+
+		return self.formatNumber(strconv.FormatFloat(f, 'f', -1, 64), value)
+	default:
+		return value
+	}
+}
+
+func (self *OutputFormat) formatTime(t time.Time) interface{} {
+	if self.Location != nil {
+		t = t.In(self.Location)
+	}
+
+	switch self.DateFormat {
+	case ``:
+		return t
+	case `rfc3339`:
+		return t.Format(time.RFC3339)
+	case `rfc3339nano`:
+		return t.Format(time.RFC3339Nano)
+	case `unix`:
+		return t.Unix()
+	case `epoch_ms`:
+		return t.UnixNano() / int64(time.Millisecond)
+	default:
+		return t.Format(self.DateFormat)
+	}
+}
+
+// returns the number (given as a plain decimal string) formatted as requested, or the original
+// value if numbers aren't being formatted as strings
+func (self *OutputFormat) formatNumber(number string, original interface{}) interface{} {
+	if self.Locale != nil {
+		var sign string
+		var integer = number
+		var fraction string
+
+		if strings.HasPrefix(integer, `-`) {
+			sign = `-`
+			integer = integer[1:]
+		}
+
+		if i := strings.Index(integer, `.`); i >= 0 {
+			fraction = self.Locale.Decimal + integer[i+1:]
+			integer = integer[:i]
+		}
+
+		var groups = make([]string, 0)
+
+		for len(integer) > 3 {
+			groups = append([]string{integer[len(integer)-3:]}, groups...)
+			integer = integer[:len(integer)-3]
+		}
+
+		groups = append([]string{integer}, groups...)
+
+		return sign + strings.Join(groups, self.Locale.Grouping) + fraction
+	} else if self.NumbersAsStrings {
+		return number
+	}
+
+	return original
+}
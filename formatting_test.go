@@ -0,0 +1,95 @@
+package pivot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputFormat(t *testing.T) {
+	assert := require.New(t)
+
+	created := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	record := dal.NewRecord(1).Set(`created_at`, created).Set(`price`, 1234567.891).Set(`count`, int64(-1234)).Set(`tags`, []interface{}{
+		map[string]interface{}{
+			`at`: created,
+		},
+	})
+
+	format := func(query string) *dal.Record {
+		f, err := OutputFormatFromRequest(httptest.NewRequest(`GET`, `/api/collections/things/records/1?`+query, nil))
+		assert.NoError(err)
+
+		if f == nil {
+			return nil
+		}
+
+		return f.Apply(record).(*dal.Record)
+	}
+
+	// nothing is formatted unless asked for
+	assert.Nil(format(``))
+
+	out := format(`datefmt=unix`)
+	assert.Equal(created.Unix(), out.Get(`created_at`))
+	assert.Equal(created.Unix(), out.Get(`tags`).([]interface{})[0].(map[string]interface{})[`at`])
+	assert.Equal(1234567.891, out.Get(`price`))
+
+	// the original record is left alone
+	assert.Equal(created, record.Get(`created_at`))
+
+	assert.Equal(created.UnixNano()/int64(time.Millisecond), format(`datefmt=epoch_ms`).Get(`created_at`))
+	assert.Equal(`2021-03-04T05:06:07Z`, format(`datefmt=rfc3339`).Get(`created_at`))
+	assert.Equal(`2021-03-04T00:06:07-05:00`, format(`datefmt=rfc3339&tz=America/New_York`).Get(`created_at`))
+	assert.Equal(`2021-03-04`, format(`datefmt=2006-01-02`).Get(`created_at`))
+
+	out = format(`numfmt=string`)
+	assert.Equal(`1234567.891`, out.Get(`price`))
+	assert.Equal(`-1234`, out.Get(`count`))
+	assert.Equal(created, out.Get(`created_at`))
+
+	assert.Equal(1234567.89, format(`numfmt=fixed:2`).Get(`price`))
+	assert.Equal(`1234567.9`, format(`numfmt=fixed:1,string`).Get(`price`))
+
+	out = format(`numfmt=locale,fixed:2&locale=de-DE`)
+	assert.Equal(`1.234.567,89`, out.Get(`price`))
+	assert.Equal(`-1.234`, out.Get(`count`))
+
+	assert.Equal(`1'234'567.89`, format(`numfmt=locale,fixed:2&locale=de_CH`).Get(`price`))
+	assert.Equal("1\u00a0234\u00a0567,891", format(`numfmt=locale&locale=fr`).Get(`price`))
+	assert.Equal(`1,234,567.891`, format(`numfmt=locale`).Get(`price`))
+
+	// recordsets are formatted record-by-record
+	recordset := (&OutputFormat{
+		DateFormat: `unix`,
+		Precision:  -1,
+	}).Apply(dal.NewRecordSet(record)).(*dal.RecordSet)
+
+	assert.Equal(created.Unix(), recordset.Records[0].Get(`created_at`))
+
+	// invalid formats are rejected
+	for _, query := range []string{`datefmt=whenever`, `tz=Nowhere/Special`, `numfmt=roman`, `numfmt=fixed:-1`} {
+		_, err := OutputFormatFromRequest(httptest.NewRequest(`GET`, `/api/status?`+query, nil))
+		assert.Error(err, query)
+	}
+
+	server := NewServer(`memory://`)
+	w := httptest.NewRecorder()
+
+	server.respond(w, httptest.NewRequest(`GET`, `/api/status?datefmt=whenever`, nil), record)
+	assert.Equal(http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+
+	server.respond(w, httptest.NewRequest(`GET`, `/api/status?datefmt=unix`, nil), record)
+	assert.Equal(http.StatusOK, w.Code)
+
+	body, err := ioutil.ReadAll(w.Body)
+	assert.NoError(err)
+	assert.Contains(string(body), `"created_at":1614834367`)
+}
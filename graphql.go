@@ -0,0 +1,579 @@
+package pivot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ghetzel/go-stockutil/httputil"
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// a GraphQL request, as POSTed to /api/graphql
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// the schema generated from the server's collections, rebuilt whenever they change
+type graphqlCache struct {
+	sync.Mutex
+	signature string
+	schema    *graphql.Schema
+}
+
+type graphqlBackendKey struct{}
+
+// Values of object, array, and other field types that GraphQL has no scalar for.
+var graphqlJSON = graphql.NewScalar(graphql.ScalarConfig{
+	Name:         `JSON`,
+	Description:  `Any JSON value.`,
+	Serialize:    func(value interface{}) interface{} { return value },
+	ParseValue:   func(value interface{}) interface{} { return value },
+	ParseLiteral: graphqlLiteralValue,
+})
+
+// Executes a GraphQL query or mutation against the collections the server's backend knows about.
+// Each collection is exposed as an object type (e.g.: "user_accounts" becomes UserAccounts), with
+// these queries and mutations:
+//
+//	userAccounts(filter: String, sort: [String], limit: Int, offset: Int): UserAccountsResults
+//	userAccountsRecord(id: ID!): UserAccounts
+//	createUserAccounts(input: UserAccountsInput!): UserAccounts
+//	updateUserAccounts(id: ID!, input: UserAccountsInput!): UserAccounts
+//	deleteUserAccounts(id: ID!): Boolean
+//
+// Filters use the same syntax as the query API (e.g.: "name/prefix:ca/age/gte:21").
+func (self *Server) graphqlHandler(w http.ResponseWriter, req *http.Request) {
+	var request graphqlRequest
+
+	if err := httputil.ParseRequest(req, &request); err != nil {
+		self.respond(w, req, err, requestBodyStatus(err))
+		return
+	} else if request.Query == `` {
+		self.respond(w, req, fmt.Errorf("Must specify a query"), http.StatusBadRequest)
+		return
+	}
+
+	if schema, err := self.graphqlSchema(); err == nil {
+		result := graphql.Do(graphql.Params{
+			Schema:         *schema,
+			RequestString:  request.Query,
+			VariableValues: request.Variables,
+			OperationName:  request.OperationName,
+			Context:        context.WithValue(req.Context(), graphqlBackendKey{}, backendForRequest(self, req, self.backend)),
+		})
+
+		self.respond(w, req, result)
+	} else {
+		self.respond(w, req, err, errorStatus(err))
+	}
+}
+
+// returns the GraphQL schema for the current set of collections, generating it if they've changed
+// since it was last generated
+func (self *Server) graphqlSchema() (*graphql.Schema, error) {
+	var collections = make([]*dal.Collection, 0)
+
+	if names, err := self.backend.ListCollections(); err == nil {
+		for _, name := range names {
+			// pivot's own bookkeeping isn't part of the API
+			if strings.HasPrefix(name, `__pivot_`) {
+				continue
+			}
+
+			if collection, err := self.backend.GetCollection(name); err == nil {
+				collections = append(collections, collection)
+			} else {
+				return nil, err
+			}
+		}
+	} else {
+		return nil, err
+	}
+
+	var signature string
+
+	if data, err := json.Marshal(collections); err == nil {
+		signature = string(data)
+	} else {
+		return nil, err
+	}
+
+	self.graphqlCache.Lock()
+	defer self.graphqlCache.Unlock()
+
+	if self.graphqlCache.schema != nil && self.graphqlCache.signature == signature {
+		return self.graphqlCache.schema, nil
+	}
+
+	if schema, err := graphqlSchemaFor(collections); err == nil {
+		self.graphqlCache.schema = schema
+		self.graphqlCache.signature = signature
+
+		return schema, nil
+	} else {
+		return nil, err
+	}
+}
+
+// generates a GraphQL schema exposing the given collections, whose resolvers read from and write
+// to the backend stored in the context of each request.  Collections whose names would collide
+// with another type in the schema are left out.
+func graphqlSchemaFor(collections []*dal.Collection) (*graphql.Schema, error) {
+	var names = make([]string, 0)
+	var queries = graphql.Fields{}
+	var mutations = graphql.Fields{}
+	var inputs = make([]graphql.Type, 0)
+	var types = map[string]bool{
+		`Query`:    true,
+		`Mutation`: true,
+		`String`:   true,
+		`Int`:      true,
+		`Float`:    true,
+		`Boolean`:  true,
+		`ID`:       true,
+		`DateTime`: true,
+		`JSON`:     true,
+	}
+
+	for _, collection := range collections {
+		var typeName = graphqlName(collection.Name, true)
+		var fieldName = graphqlName(collection.Name, false)
+
+		if types[typeName] || types[typeName+`Input`] || types[typeName+`Results`] {
+			log.Warningf("graphql: collection %q conflicts with another type named %q, skipping", collection.Name, typeName)
+			continue
+		}
+
+		types[typeName] = true
+		types[typeName+`Input`] = true
+		types[typeName+`Results`] = true
+		names = append(names, collection.Name)
+
+		var object, input, fieldMap = graphqlCollectionTypes(collection, typeName)
+		var results = graphql.NewObject(graphql.ObjectConfig{
+			Name:        typeName + `Results`,
+			Description: fmt.Sprintf("A page of records from the %q collection.", collection.Name),
+			Fields: graphql.Fields{
+				`records`: &graphql.Field{
+					Type: graphql.NewList(object),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(*dal.RecordSet).Records, nil
+					},
+				},
+				`resultCount`: &graphql.Field{
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(*dal.RecordSet).ResultCount, nil
+					},
+				},
+				`page`: &graphql.Field{
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(*dal.RecordSet).Page, nil
+					},
+				},
+				`totalPages`: &graphql.Field{
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(*dal.RecordSet).TotalPages, nil
+					},
+				},
+			},
+		})
+
+		queries[fieldName] = &graphql.Field{
+			Type:        results,
+			Description: fmt.Sprintf("Query records in the %q collection.", collection.Name),
+			Args: graphql.FieldConfigArgument{
+				`filter`: &graphql.ArgumentConfig{
+					Type:         graphql.String,
+					DefaultValue: `all`,
+				},
+				`sort`: &graphql.ArgumentConfig{
+					Type: graphql.NewList(graphql.String),
+				},
+				`limit`: &graphql.ArgumentConfig{
+					Type:         graphql.Int,
+					DefaultValue: DefaultResultLimit,
+				},
+				`offset`: &graphql.ArgumentConfig{
+					Type:         graphql.Int,
+					DefaultValue: 0,
+				},
+			},
+			Resolve: graphqlQueryResolver(collection.Name),
+		}
+
+		queries[fieldName+`Record`] = &graphql.Field{
+			Type:        object,
+			Description: fmt.Sprintf("Retrieve a record from the %q collection.", collection.Name),
+			Args: graphql.FieldConfigArgument{
+				`id`: &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.ID),
+				},
+			},
+			Resolve: func(name string) graphql.FieldResolveFn {
+				return func(p graphql.ResolveParams) (interface{}, error) {
+					return graphqlBackend(p).Retrieve(name, p.Args[`id`])
+				}
+			}(collection.Name),
+		}
+
+		// views can't be written to
+		if collection.View {
+			continue
+		}
+
+		inputs = append(inputs, input)
+
+		mutations[`create`+typeName] = &graphql.Field{
+			Type:        object,
+			Description: fmt.Sprintf("Create a record in the %q collection.", collection.Name),
+			Args: graphql.FieldConfigArgument{
+				`input`: &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(input),
+				},
+			},
+			Resolve: graphqlWriteResolver(collection.Name, fieldMap, false),
+		}
+
+		mutations[`update`+typeName] = &graphql.Field{
+			Type:        object,
+			Description: fmt.Sprintf("Update a record in the %q collection.", collection.Name),
+			Args: graphql.FieldConfigArgument{
+				`id`: &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.ID),
+				},
+				`input`: &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(input),
+				},
+			},
+			Resolve: graphqlWriteResolver(collection.Name, fieldMap, true),
+		}
+
+		mutations[`delete`+typeName] = &graphql.Field{
+			Type:        graphql.Boolean,
+			Description: fmt.Sprintf("Delete a record from the %q collection.", collection.Name),
+			Args: graphql.FieldConfigArgument{
+				`id`: &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.ID),
+				},
+			},
+			Resolve: func(name string) graphql.FieldResolveFn {
+				return func(p graphql.ResolveParams) (interface{}, error) {
+					if err := graphqlBackend(p).Delete(name, p.Args[`id`]); err == nil {
+						return true, nil
+					} else {
+						return false, err
+					}
+				}
+			}(collection.Name),
+		}
+	}
+
+	// a query with no fields isn't valid, so there is always at least this one
+	queries[`_collections`] = &graphql.Field{
+		Type:        graphql.NewList(graphql.String),
+		Description: `The names of the collections available in this schema.`,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return names, nil
+		},
+	}
+
+	// input types are declared explicitly so that they can be used as the types of query variables
+	var config = graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   `Query`,
+			Fields: queries,
+		}),
+		Types: inputs,
+	}
+
+	if len(mutations) > 0 {
+		config.Mutation = graphql.NewObject(graphql.ObjectConfig{
+			Name:   `Mutation`,
+			Fields: mutations,
+		})
+	}
+
+	if schema, err := graphql.NewSchema(config); err == nil {
+		return &schema, nil
+	} else {
+		return nil, fmt.Errorf("graphql: %v", err)
+	}
+}
+
+// returns the object and input types for records in the given collection, along with the names of
+// the collection fields that each GraphQL field corresponds to
+func graphqlCollectionTypes(collection *dal.Collection, typeName string) (*graphql.Object, *graphql.InputObject, map[string]string) {
+	var identity = graphqlFieldName(collection.GetIdentityFieldName())
+	var fieldMap = map[string]string{
+		identity: ``,
+	}
+
+	var outputs = graphql.Fields{
+		identity: &graphql.Field{
+			Type: graphql.NewNonNull(graphql.ID),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if record, ok := p.Source.(*dal.Record); ok {
+					return record.ID, nil
+				}
+
+				return nil, nil
+			},
+		},
+	}
+
+	var inputs = graphql.InputObjectConfigFieldMap{
+		identity: &graphql.InputObjectFieldConfig{
+			Type: graphql.ID,
+		},
+	}
+
+	for _, field := range collection.Fields {
+		var name = graphqlFieldName(field.Name)
+
+		if _, ok := fieldMap[name]; ok {
+			log.Warningf("graphql: %s: field %q conflicts with another field named %q, skipping", collection.Name, field.Name, name)
+			continue
+		}
+
+		fieldMap[name] = field.Name
+
+		outputs[name] = &graphql.Field{
+			Type:        graphqlFieldType(field.Type),
+			Description: field.Description,
+			Resolve:     graphqlFieldResolver(field),
+		}
+
+		inputs[name] = &graphql.InputObjectFieldConfig{
+			Type:        graphqlFieldType(field.Type),
+			Description: field.Description,
+		}
+	}
+
+	var object = graphql.NewObject(graphql.ObjectConfig{
+		Name:        typeName,
+		Description: fmt.Sprintf("A record in the %q collection.", collection.Name),
+		Fields:      outputs,
+	})
+
+	var input = graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        typeName + `Input`,
+		Description: fmt.Sprintf("The values to write to a record in the %q collection.", collection.Name),
+		Fields:      inputs,
+	})
+
+	return object, input, fieldMap
+}
+
+// returns the GraphQL scalar that values of the given field type are represented as
+func graphqlFieldType(fieldType dal.Type) *graphql.Scalar {
+	switch fieldType {
+	case dal.StringType:
+		return graphql.String
+	case dal.IntType:
+		return graphql.Int
+	case dal.FloatType:
+		return graphql.Float
+	case dal.BooleanType:
+		return graphql.Boolean
+	case dal.TimeType:
+		return graphql.DateTime
+	default:
+		return graphqlJSON
+	}
+}
+
+func graphqlFieldResolver(field dal.Field) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if record, ok := p.Source.(*dal.Record); ok {
+			var value = record.Get(field.Name)
+
+			// the DateTime scalar only serializes time values
+			if _, ok := value.(time.Time); !ok && value != nil && field.Type == dal.TimeType {
+				value = typeutil.V(value).Time()
+			}
+
+			return value, nil
+		}
+
+		return nil, nil
+	}
+}
+
+func graphqlQueryResolver(name string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		var backend = graphqlBackend(p)
+
+		if collection, err := backend.GetCollection(name); err == nil {
+			if f, err := filter.Parse(typeutil.String(p.Args[`filter`])); err == nil {
+				f.Limit = int(typeutil.Int(p.Args[`limit`]))
+				f.Offset = int(typeutil.Int(p.Args[`offset`]))
+
+				if sort, ok := p.Args[`sort`]; ok {
+					f.Sort = sliceutil.CompactString(sliceutil.Stringify(sort))
+				}
+
+				if search := backend.WithSearch(collection, f); search != nil {
+					return search.Query(collection, f)
+				} else {
+					return nil, fmt.Errorf("Backend %T does not support complex queries.", backend)
+				}
+			} else {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+}
+
+// returns a resolver that creates (or, if update is true, updates) a record from the "input"
+// argument, and returns the record as it was saved.  Updates only change the fields given.
+func graphqlWriteResolver(name string, fieldMap map[string]string, update bool) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		var backend = graphqlBackend(p)
+		var record = dal.NewRecord(nil)
+
+		if update {
+			if existing, err := backend.Retrieve(name, p.Args[`id`]); err == nil {
+				record = existing
+			} else {
+				return nil, err
+			}
+		}
+
+		if input, ok := p.Args[`input`].(map[string]interface{}); ok {
+			for key, value := range input {
+				if field, ok := fieldMap[key]; !ok {
+					continue
+				} else if field == `` {
+					// records are updated in place, not moved to another ID
+					if !update {
+						record.ID = value
+					}
+				} else {
+					record.Set(field, value)
+				}
+			}
+		}
+
+		var err error
+
+		if update {
+			err = backend.Update(name, dal.NewRecordSet(record))
+		} else {
+			err = backend.Insert(name, dal.NewRecordSet(record))
+		}
+
+		if err != nil {
+			return nil, err
+		} else if record.ID != nil {
+			return backend.Retrieve(name, record.ID)
+		} else {
+			return record, nil
+		}
+	}
+}
+
+// retrieves the backend that a request's resolvers should use
+func graphqlBackend(p graphql.ResolveParams) Backend {
+	if backend, ok := p.Context.Value(graphqlBackendKey{}).(Backend); ok {
+		return backend
+	}
+
+	panic("graphql: no backend in resolver context")
+}
+
+// converts a literal value in a query into the value it represents
+func graphqlLiteralValue(value ast.Value) interface{} {
+	switch v := value.(type) {
+	case *ast.ObjectValue:
+		var out = make(map[string]interface{})
+
+		for _, field := range v.Fields {
+			out[field.Name.Value] = graphqlLiteralValue(field.Value)
+		}
+
+		return out
+	case *ast.ListValue:
+		var out = make([]interface{}, 0)
+
+		for _, item := range v.Values {
+			out = append(out, graphqlLiteralValue(item))
+		}
+
+		return out
+	case *ast.IntValue:
+		return typeutil.Int(v.Value)
+	case *ast.FloatValue:
+		return typeutil.Float(v.Value)
+	case *ast.BooleanValue:
+		return v.Value
+	case *ast.StringValue:
+		return v.Value
+	case *ast.EnumValue:
+		return v.Value
+	default:
+		return nil
+	}
+}
+
+// converts a collection name into a GraphQL type name (e.g.: "user_accounts" becomes
+// "UserAccounts") or, if exported is false, a query name ("userAccounts")
+func graphqlName(name string, exported bool) string {
+	var out string
+
+	for i, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !graphqlNameRune(r)
+	}) {
+		if i == 0 && !exported {
+			out += strings.ToLower(word[:1]) + word[1:]
+		} else {
+			out += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+
+	if out == `` || unicode.IsDigit(rune(out[0])) {
+		out = `_` + out
+	}
+
+	return out
+}
+
+// converts a field name into a valid GraphQL name, replacing any characters that aren't allowed
+func graphqlFieldName(name string) string {
+	var out = strings.Map(func(r rune) rune {
+		if graphqlNameRune(r) || r == '_' {
+			return r
+		}
+
+		return '_'
+	}, name)
+
+	if out == `` || unicode.IsDigit(rune(out[0])) {
+		out = `_` + out
+	}
+
+	return out
+}
+
+// GraphQL names may only contain ASCII letters, digits, and underscores
+func graphqlNameRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
@@ -0,0 +1,140 @@
+package pivot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphQL(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-graphql-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	server := NewServer(`fs://` + root + `/`)
+	server.UiDirectory = ``
+	server.GraphQL = true
+
+	handler := server.Handler()
+
+	users := dal.NewCollection(`user_accounts`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `age`,
+		Type: dal.IntType,
+	})
+
+	users.IdentityFieldType = dal.StringType
+	assert.NoError(server.backend.CreateCollection(users))
+
+	query := func(q string, variables map[string]interface{}) map[string]interface{} {
+		body, err := json.Marshal(map[string]interface{}{
+			`query`:     q,
+			`variables`: variables,
+		})
+
+		assert.NoError(err)
+
+		req := httptest.NewRequest(`POST`, `/api/graphql`, strings.NewReader(string(body)))
+		req.Header.Set(`Content-Type`, `application/json`)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(http.StatusOK, w.Code, w.Body.String())
+
+		var result map[string]interface{}
+		assert.NoError(json.Unmarshal(w.Body.Bytes(), &result))
+
+		return result
+	}
+
+	result := query(`mutation($input: UserAccountsInput!) {
+		createUserAccounts(input: $input) { id name age }
+	}`, map[string]interface{}{
+		`input`: map[string]interface{}{
+			`id`:   `ada`,
+			`name`: `Ada`,
+			`age`:  36,
+		},
+	})
+
+	assert.Nil(result[`errors`])
+	assert.Equal(map[string]interface{}{
+		`createUserAccounts`: map[string]interface{}{
+			`id`:   `ada`,
+			`name`: `Ada`,
+			`age`:  float64(36),
+		},
+	}, result[`data`])
+
+	result = query(`mutation {
+		createUserAccounts(input: {id: "grace", name: "Grace", age: 85}) { id }
+	}`, nil)
+
+	assert.Nil(result[`errors`])
+
+	// records are queried with the same filters as the REST API
+	result = query(`{
+		userAccounts(filter: "age/gt:40") { resultCount records { id name } }
+	}`, nil)
+
+	assert.Nil(result[`errors`])
+	assert.Equal(map[string]interface{}{
+		`userAccounts`: map[string]interface{}{
+			`resultCount`: float64(1),
+			`records`: []interface{}{
+				map[string]interface{}{
+					`id`:   `grace`,
+					`name`: `Grace`,
+				},
+			},
+		},
+	}, result[`data`])
+
+	// (the filesystem backend doesn't sort, so only the limit is checked)
+	result = query(`{ userAccounts(sort: ["-age"], limit: 1) { records { id } } }`, nil)
+	assert.Nil(result[`errors`])
+	assert.Len(result[`data`].(map[string]interface{})[`userAccounts`].(map[string]interface{})[`records`], 1)
+
+	result = query(`mutation { updateUserAccounts(id: "ada", input: {age: 37}) { name age } }`, nil)
+	assert.Nil(result[`errors`])
+	assert.Equal(map[string]interface{}{
+		`name`: `Ada`,
+		`age`:  float64(37),
+	}, result[`data`].(map[string]interface{})[`updateUserAccounts`])
+
+	result = query(`mutation { deleteUserAccounts(id: "ada") }`, nil)
+	assert.Nil(result[`errors`])
+
+	result = query(`{ userAccountsRecord(id: "ada") { id } }`, nil)
+	assert.NotNil(result[`errors`])
+
+	result = query(`{ _collections }`, nil)
+	assert.Equal([]interface{}{`user_accounts`}, result[`data`].(map[string]interface{})[`_collections`])
+
+	// invalid queries are reported as GraphQL errors
+	result = query(`{ nonexistent { id } }`, nil)
+	assert.NotNil(result[`errors`])
+}
+
+func TestGraphQLNames(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal(`UserAccounts`, graphqlName(`user_accounts`, true))
+	assert.Equal(`userAccounts`, graphqlName(`user_accounts`, false))
+	assert.Equal(`WebHits2020`, graphqlName(`web-hits.2020`, true))
+	assert.Equal(`_2020Hits`, graphqlName(`2020_hits`, true))
+	assert.Equal(`first_name`, graphqlFieldName(`first_name`))
+	assert.Equal(`content_type`, graphqlFieldName(`content-type`))
+	assert.Equal(`_1st`, graphqlFieldName(`1st`))
+}
@@ -0,0 +1,305 @@
+package pivot
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/ghetzel/pivot/v3/rpc"
+	"github.com/ghetzel/pivot/v3/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// implements the gRPC service defined in rpc/pivot.proto against the server's backend
+type grpcService struct {
+	server *Server
+}
+
+// Returns a gRPC server that serves the Pivot service (see rpc/pivot.proto) from this server's
+// backend.  ListenAndServe starts one automatically when GRPCAddress is set; this is for
+// applications that want to serve it themselves.
+func (self *Server) GRPCServer(options ...grpc.ServerOption) (*grpc.Server, error) {
+	if err := self.Initialize(); err != nil {
+		return nil, err
+	}
+
+	if self.scheme() == `https` {
+		if creds, err := credentials.NewServerTLSFromFile(self.TLSCertFile, self.TLSKeyFile); err == nil {
+			options = append(options, grpc.Creds(creds))
+		} else {
+			return nil, err
+		}
+	}
+
+	var server = grpc.NewServer(options...)
+
+	rpc.RegisterPivotServer(server, &grpcService{
+		server: self,
+	})
+
+	return server, nil
+}
+
+func (self *Server) listenGRPC() error {
+	if listener, err := net.Listen(`tcp`, self.GRPCAddress); err == nil {
+		if server, err := self.GRPCServer(); err == nil {
+			log.Infof("gRPC service listening at %s", self.GRPCAddress)
+
+			go func() {
+				if err := server.Serve(listener); err != nil {
+					log.Errorf("gRPC service stopped: %v", err)
+				}
+			}()
+
+			return nil
+		} else {
+			listener.Close()
+			return err
+		}
+	} else {
+		return fmt.Errorf("gRPC: %v", err)
+	}
+}
+
+func (self *grpcService) Status(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	var status = util.Status{
+		OK:          true,
+		Application: ApplicationName,
+		Version:     ApplicationVersion,
+		Backend:     self.server.backend.GetConnectionString().String(),
+	}
+
+	if indexer := self.server.backend.WithSearch(nil, nil); indexer != nil {
+		status.Indexer = indexer.IndexConnectionString().String()
+	}
+
+	if db, ok := self.server.backend.(DB); ok {
+		if health := db.Health(); health != nil {
+			status.Health = health
+			status.OK = health.OK
+		}
+	}
+
+	return grpcEncode(&status)
+}
+
+func (self *grpcService) Collections(ctx context.Context, _ *emptypb.Empty) (*structpb.ListValue, error) {
+	if names, err := self.backend(ctx).ListCollections(); err == nil {
+		var values = make([]interface{}, len(names))
+
+		for i, name := range names {
+			values[i] = name
+		}
+
+		return structpb.NewList(values)
+	} else {
+		return nil, grpcError(err)
+	}
+}
+
+func (self *grpcService) GetCollection(ctx context.Context, name *wrapperspb.StringValue) (*structpb.Struct, error) {
+	if collection, err := self.backend(ctx).GetCollection(name.GetValue()); err == nil {
+		return grpcEncode(collection)
+	} else {
+		return nil, grpcError(err)
+	}
+}
+
+func (self *grpcService) Create(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	return self.write(ctx, in, false)
+}
+
+func (self *grpcService) Update(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	return self.write(ctx, in, true)
+}
+
+func (self *grpcService) Retrieve(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	var request rpc.RetrieveRequest
+
+	if err := rpc.Decode(in, &request); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if record, err := self.backend(ctx).Retrieve(request.Collection, grpcID(request.ID), request.Fields...); err == nil {
+		return grpcEncode(record)
+	} else {
+		return nil, grpcError(err)
+	}
+}
+
+func (self *grpcService) Delete(ctx context.Context, in *structpb.Struct) (*emptypb.Empty, error) {
+	var request rpc.DeleteRequest
+
+	if err := rpc.Decode(in, &request); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	for i, id := range request.IDs {
+		request.IDs[i] = grpcID(id)
+	}
+
+	if err := self.backend(ctx).Delete(request.Collection, request.IDs...); err == nil {
+		return new(emptypb.Empty), nil
+	} else {
+		return nil, grpcError(err)
+	}
+}
+
+// Streams each matching record to the client as it is read from the backend, rather than
+// gathering them into a recordset first.
+func (self *grpcService) Query(in *structpb.Struct, stream rpc.QueryServerStream) error {
+	var request rpc.QueryRequest
+	var backend = self.backend(stream.Context())
+
+	if err := rpc.Decode(in, &request); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	} else if request.Filter == `` {
+		request.Filter = filter.AllValue
+	}
+
+	f, err := filter.Parse(request.Filter)
+
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	f.Limit = request.Limit
+	f.Offset = request.Offset
+	f.Sort = request.Sort
+	f.Fields = request.Fields
+	f.After = request.After
+
+	switch request.Conjunction {
+	case ``, `and`:
+		f.Conjunction = filter.AndConjunction
+	case `or`:
+		f.Conjunction = filter.OrConjunction
+	default:
+		return status.Errorf(codes.InvalidArgument, "Unsupported conjunction operator '%s'", request.Conjunction)
+	}
+
+	if collection, err := backend.GetCollection(request.Collection); err == nil {
+		if search := backend.WithSearch(collection, f); search != nil {
+			err := search.QueryFunc(collection, f, func(record *dal.Record, err error, _ backends.IndexPage) error {
+				if err != nil {
+					return err
+				} else if err := stream.Context().Err(); err != nil {
+					return err
+				}
+
+				if out, err := grpcEncode(record); err == nil {
+					return stream.Send(out)
+				} else {
+					return err
+				}
+			})
+
+			return grpcError(err)
+		} else {
+			return status.Errorf(codes.Unimplemented, "Backend %T does not support complex queries.", backend)
+		}
+	} else {
+		return grpcError(err)
+	}
+}
+
+func (self *grpcService) write(ctx context.Context, in *structpb.Struct, update bool) (*structpb.Struct, error) {
+	var request rpc.RecordsRequest
+	var err error
+
+	if err := rpc.Decode(in, &request); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var recordset = dal.NewRecordSet(request.Records...)
+	var backend = self.backend(ctx)
+
+	for _, record := range recordset.Records {
+		record.ID = grpcID(record.ID)
+	}
+
+	if update {
+		err = backend.Update(request.Collection, recordset)
+	} else {
+		err = backend.Insert(request.Collection, recordset)
+	}
+
+	if err == nil {
+		return grpcEncode(recordset)
+	} else {
+		return nil, grpcError(err)
+	}
+}
+
+// wraps the server's backend the same way backendForRequest does for requests that don't ask for
+// anything different
+func (self *grpcService) backend(ctx context.Context) Backend {
+	var backend Backend = self.server.backend
+
+	if self.server.Tracing {
+		backend = backends.NewTracingBackend(backend, ctx)
+	}
+
+	if self.server.Autoexpand {
+		embedded := backends.NewEmbeddedRecordBackend(backend)
+
+		if self.server.MaxEmbeddedRecords > 0 {
+			embedded.MaxEmbeddedRecords = self.server.MaxEmbeddedRecords
+		}
+
+		if self.server.MaxEmbeddedBytes > 0 {
+			embedded.MaxEmbeddedBytes = self.server.MaxEmbeddedBytes
+		}
+
+		backend = embedded
+	}
+
+	return backend
+}
+
+func grpcEncode(in interface{}) (*structpb.Struct, error) {
+	if out, err := rpc.Encode(in); err == nil {
+		return out, nil
+	} else {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+}
+
+// Struct numbers are always floats; whole numbers are used as integer IDs
+func grpcID(id interface{}) interface{} {
+	if v, ok := id.(float64); ok && v == math.Trunc(v) && math.Abs(v) < (1<<53) {
+		return int64(v)
+	}
+
+	return id
+}
+
+// returns the gRPC status that best describes the given error, like errorStatus does for HTTP
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	} else if _, ok := status.FromError(err); ok {
+		return err
+	} else if err == context.Canceled {
+		return status.Error(codes.Canceled, err.Error())
+	} else if backends.IsTimeoutError(err) || err == context.DeadlineExceeded {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	} else if dal.IsCollectionNotFoundErr(err) || dal.IsNotExistError(err) {
+		return status.Error(codes.NotFound, err.Error())
+	} else if _, ok := err.(*dal.SchemaValidationError); ok || dal.IsValidationErr(err) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
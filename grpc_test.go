@@ -0,0 +1,123 @@
+package pivot
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/ghetzel/pivot/v3/client"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPC(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-grpc-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	server := NewServer(`fs://` + root + `/`)
+	server.UiDirectory = ``
+
+	grpcServer, err := server.GRPCServer()
+	assert.NoError(err)
+
+	listener := bufconn.Listen(1 << 20)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	rpcClient, err := client.NewGRPC(`bufnet`, grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+
+	assert.NoError(err)
+	defer rpcClient.Close()
+
+	assert.NoError(server.backend.CreateCollection(dal.NewCollection(`users`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `age`,
+		Type: dal.IntType,
+	})))
+
+	serverStatus, err := rpcClient.Status()
+	assert.NoError(err)
+	assert.True(serverStatus.OK)
+	assert.Equal(ApplicationName, serverStatus.Application)
+
+	names, err := rpcClient.Collections()
+	assert.NoError(err)
+	assert.Equal([]string{`users`}, names)
+
+	collection, err := rpcClient.Collection(`users`)
+	assert.NoError(err)
+	assert.Equal(`users`, collection.Name)
+	assert.Len(collection.Fields, 2)
+
+	recordset, err := rpcClient.CreateRecord(`users`,
+		dal.NewRecord(1).Set(`name`, `Alice`).Set(`age`, 30),
+		dal.NewRecord(2).Set(`name`, `Bob`).Set(`age`, 25),
+		dal.NewRecord(3).Set(`name`, `Carol`).Set(`age`, 41),
+	)
+
+	assert.NoError(err)
+	assert.Len(recordset.Records, 3)
+
+	record, err := rpcClient.GetRecord(`users`, 1)
+	assert.NoError(err)
+	assert.EqualValues(1, typeutil.Int(record.ID))
+	assert.Equal(`Alice`, record.Get(`name`))
+
+	_, err = rpcClient.UpdateRecord(`users`, dal.NewRecord(1).Set(`name`, `Alice`).Set(`age`, 31))
+	assert.NoError(err)
+
+	record, err = rpcClient.GetRecord(`users`, 1)
+	assert.NoError(err)
+	assert.EqualValues(31, typeutil.Int(record.Get(`age`)))
+
+	// query results are streamed back one record at a time
+	var streamed []string
+
+	assert.NoError(rpcClient.QueryFunc(`users`, `age/gt:26`, &client.QueryOptions{
+		Sort: []string{`-age`},
+	}, func(record *dal.Record) error {
+		streamed = append(streamed, typeutil.String(record.Get(`name`)))
+		return nil
+	}))
+
+	// (the filesystem backend doesn't sort, so only which records were streamed is checked)
+	assert.ElementsMatch([]string{`Carol`, `Alice`}, streamed)
+
+	recordset, err = rpcClient.Query(`users`, nil, &client.QueryOptions{
+		Limit: 2,
+	})
+
+	assert.NoError(err)
+	assert.Len(recordset.Records, 2)
+
+	assert.NoError(rpcClient.DeleteRecords(`users`, 1, 2))
+
+	// errors are reported with the matching gRPC status code
+	_, err = rpcClient.GetRecord(`users`, 1)
+	assert.Equal(codes.NotFound, status.Code(err))
+
+	_, err = rpcClient.Collection(`nonexistent`)
+	assert.Equal(codes.NotFound, status.Code(err))
+
+	err = rpcClient.QueryFunc(`users`, `age/gt:1`, &client.QueryOptions{
+		Conjunction: `xor`,
+	}, func(*dal.Record) error {
+		return nil
+	})
+
+	assert.Equal(codes.InvalidArgument, status.Code(err))
+}
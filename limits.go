@@ -0,0 +1,213 @@
+package pivot
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Idle clients are forgotten by the rate limiter after this long.
+var RateLimitIdleTimeout = 10 * time.Minute
+
+// Returned while reading a request body that is larger than the server allows.
+var ErrRequestTooLarge = fmt.Errorf("request body too large")
+
+// Returns the key that identifies the client making a request, for the purpose of rate limiting.
+type ClientKeyFunc func(req *http.Request) string
+
+// Limits placed on the requests clients make to the API, protecting a shared server from any one
+// client sending too many or too large requests.  The zero value imposes no limits.
+type RequestLimits struct {
+	// The sustained number of requests per second each client may make.  Clients exceeding this
+	// receive a 429 Too Many Requests response.  Zero is unlimited.
+	RequestsPerSecond float64
+
+	// The number of requests a client may make in a burst above the sustained rate.  Defaults to
+	// the number of requests per second (rounded up).
+	Burst int
+
+	// The maximum size (in bytes) of the body of requests that write records.  Larger requests
+	// receive a 413 Request Entity Too Large response.  Zero is unlimited.
+	MaxBodySize int64
+
+	// Identifies clients for rate limiting.  Defaults to the bearer token or API key the request was
+	// made with, falling back to the client's IP address.
+	ClientKey ClientKeyFunc
+}
+
+// Identifies clients by their bearer token or API key if one was given, otherwise by their IP
+// address.
+func DefaultClientKey(req *http.Request) string {
+	if auth := req.Header.Get(`Authorization`); auth != `` {
+		return `token:` + auth
+	} else if key := req.Header.Get(`X-Api-Key`); key != `` {
+		return `token:` + key
+	}
+
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return `ip:` + host
+	} else {
+		return `ip:` + req.RemoteAddr
+	}
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// A token bucket rate limiter for each client.
+type rateLimiter struct {
+	rate      float64
+	burst     float64
+	clients   map[string]*tokenBucket
+	lastSweep time.Time
+	lock      sync.Mutex
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		clients: make(map[string]*tokenBucket),
+	}
+}
+
+// Takes a token from the given client's bucket.  If the bucket is empty, returns false and how
+// long the client should wait before trying again.
+func (self *rateLimiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.sweep(now)
+
+	var bucket, ok = self.clients[key]
+
+	if ok {
+		bucket.tokens = math.Min(self.burst, bucket.tokens+(now.Sub(bucket.last).Seconds()*self.rate))
+		bucket.last = now
+	} else {
+		bucket = &tokenBucket{
+			tokens: self.burst,
+			last:   now,
+		}
+
+		self.clients[key] = bucket
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens -= 1
+		return true, 0
+	}
+
+	return false, time.Duration(((1 - bucket.tokens) / self.rate) * float64(time.Second))
+}
+
+// forget clients that haven't been seen in a while
+func (self *rateLimiter) sweep(now time.Time) {
+	if now.Sub(self.lastSweep) < RateLimitIdleTimeout {
+		return
+	}
+
+	for key, bucket := range self.clients {
+		if now.Sub(bucket.last) >= RateLimitIdleTimeout {
+			delete(self.clients, key)
+		}
+	}
+
+	self.lastSweep = now
+}
+
+// Rejects API requests from clients that are over their rate limit.
+func (self *Server) rateLimitMiddleware(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	if !strings.HasPrefix(req.URL.Path, `/api/`) {
+		next(w, req)
+		return
+	}
+
+	var keyFn = self.Limits.ClientKey
+
+	if keyFn == nil {
+		keyFn = DefaultClientKey
+	}
+
+	if ok, wait := self.rateLimiter.Allow(keyFn(req), time.Now()); !ok {
+		w.Header().Set(`Retry-After`, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		self.respond(w, req, fmt.Errorf("rate limit exceeded, try again in %v", wait.Round(time.Millisecond)), http.StatusTooManyRequests)
+		return
+	}
+
+	next(w, req)
+}
+
+// Rejects requests that write records whose bodies are larger than allowed.  Bodies that don't
+// declare their size up front are cut off once they exceed it, causing ErrRequestTooLarge to be
+// returned while they are being read.
+func (self *Server) requestSizeMiddleware(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	if isRecordWrite(req) {
+		if req.ContentLength > self.Limits.MaxBodySize {
+			self.respond(w, req, ErrRequestTooLarge, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		req.Body = &limitedBody{
+			ReadCloser: req.Body,
+			remaining:  self.Limits.MaxBodySize,
+		}
+	}
+
+	next(w, req)
+}
+
+// returns the status code to respond with when a request body couldn't be parsed
+func requestBodyStatus(err error) int {
+	if err == ErrRequestTooLarge || (err != nil && strings.Contains(err.Error(), ErrRequestTooLarge.Error())) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	return http.StatusBadRequest
+}
+
+func isRecordWrite(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return strings.HasPrefix(req.URL.Path, `/api/collections/`) && strings.Contains(req.URL.Path, `/records`)
+	}
+
+	return false
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (self *limitedBody) Read(p []byte) (int, error) {
+	if self.remaining < 0 {
+		return 0, ErrRequestTooLarge
+	}
+
+	// read one byte past the limit so that a body of exactly the maximum size is allowed
+	if int64(len(p)) > self.remaining+1 {
+		p = p[:self.remaining+1]
+	}
+
+	n, err := self.ReadCloser.Read(p)
+	self.remaining -= int64(n)
+
+	if self.remaining < 0 {
+		return 0, ErrRequestTooLarge
+	}
+
+	return n, err
+}
@@ -0,0 +1,104 @@
+package pivot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	assert := require.New(t)
+	limiter := newRateLimiter(2, 3)
+	now := time.Now()
+
+	// a full bucket allows a burst
+	for i := 0; i < 3; i++ {
+		ok, _ := limiter.Allow(`a`, now)
+		assert.True(ok)
+	}
+
+	ok, wait := limiter.Allow(`a`, now)
+	assert.False(ok)
+	assert.Equal(500*time.Millisecond, wait)
+
+	// other clients have their own buckets
+	ok, _ = limiter.Allow(`b`, now)
+	assert.True(ok)
+
+	// tokens are replenished at the given rate
+	ok, _ = limiter.Allow(`a`, now.Add(500*time.Millisecond))
+	assert.True(ok)
+
+	ok, _ = limiter.Allow(`a`, now.Add(500*time.Millisecond))
+	assert.False(ok)
+}
+
+func TestServerRequestLimits(t *testing.T) {
+	assert := require.New(t)
+
+	server := NewServer(`memory://`)
+	server.Limits = RequestLimits{
+		RequestsPerSecond: 1,
+		MaxBodySize:       16,
+	}
+
+	server.rateLimiter = newRateLimiter(server.Limits.RequestsPerSecond, server.Limits.Burst)
+
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		if _, err := ioutil.ReadAll(req.Body); err == nil {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(requestBodyStatus(err))
+		}
+	}
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+
+		server.rateLimitMiddleware(w, req, func(w http.ResponseWriter, req *http.Request) {
+			server.requestSizeMiddleware(w, req, handler)
+		})
+
+		return w
+	}
+
+	req := httptest.NewRequest(`GET`, `/api/status`, nil)
+	req.RemoteAddr = `10.0.0.1:1234`
+	assert.Equal(http.StatusNoContent, serve(req).Code)
+
+	req = httptest.NewRequest(`GET`, `/api/status`, nil)
+	req.RemoteAddr = `10.0.0.1:5678`
+	w := serve(req)
+	assert.Equal(http.StatusTooManyRequests, w.Code)
+	assert.Equal(`1`, w.Header().Get(`Retry-After`))
+
+	// requests with a token are limited separately from their IP address
+	req = httptest.NewRequest(`GET`, `/api/status`, nil)
+	req.RemoteAddr = `10.0.0.1:1234`
+	req.Header.Set(`Authorization`, `Bearer abc`)
+	assert.Equal(http.StatusNoContent, serve(req).Code)
+
+	// only the API is rate limited
+	req = httptest.NewRequest(`GET`, `/index.html`, nil)
+	req.RemoteAddr = `10.0.0.1:1234`
+	assert.Equal(http.StatusNoContent, serve(req).Code)
+
+	// record writes are limited in size, whether or not they declare it
+	req = httptest.NewRequest(`POST`, `/api/collections/things/records`, strings.NewReader(strings.Repeat(`x`, 17)))
+	req.RemoteAddr = `10.0.0.2:1234`
+	assert.Equal(http.StatusRequestEntityTooLarge, serve(req).Code)
+
+	req = httptest.NewRequest(`POST`, `/api/collections/things/records`, ioutil.NopCloser(strings.NewReader(strings.Repeat(`x`, 17))))
+	req.ContentLength = -1
+	req.RemoteAddr = `10.0.0.3:1234`
+	assert.Equal(http.StatusRequestEntityTooLarge, serve(req).Code)
+
+	req = httptest.NewRequest(`POST`, `/api/collections/things/records`, strings.NewReader(strings.Repeat(`x`, 16)))
+	req.RemoteAddr = `10.0.0.4:1234`
+	assert.Equal(http.StatusNoContent, serve(req).Code)
+}
@@ -73,7 +73,8 @@ func (self *Model) Migrate() error {
 
 	// create the collection if it doesn't exist
 	if c, err := self.db.GetCollection(self.collection.Name); dal.ShouldCreateCollection(self.collection, err) {
-		if err := self.db.CreateCollection(self.collection); err == nil {
+		// another process may have created the collection since we checked, which is fine
+		if err := self.db.CreateCollection(self.collection); err == nil || dal.IsCollectionExistsErr(err) {
 			if c, err := self.db.GetCollection(self.collection.Name); err == nil {
 				actualCollection = c
 			} else {
@@ -103,6 +104,42 @@ func (self *Model) Migrate() error {
 	// overlay the definition onto whatever the backend came back with
 	actualCollection.ApplyDefinition(self.collection)
 
+	// create any secondary indexes that don't exist yet
+	if err := backends.EnsureSecondaryIndexes(self.db, self.collection); err != nil {
+		return err
+	}
+
+	return self.migrateJoinCollections()
+}
+
+// create the join collections for any many-to-many relationships that don't have one yet
+func (self *Model) migrateJoinCollections() error {
+	for _, relationship := range self.collection.EmbeddedCollections {
+		if !relationship.IsManyToMany() {
+			continue
+		}
+
+		if _, err := self.db.GetCollection(relationship.Through); dal.IsCollectionNotFoundErr(err) {
+			if related, err := self.relatedCollection(&relationship); err == nil {
+				if join, err := relationship.JoinCollection(self.collection, related); err == nil {
+					if err := self.db.CreateCollection(join); err == nil || dal.IsCollectionExistsErr(err) {
+						if err := backends.EnsureSecondaryIndexes(self.db, join); err != nil {
+							return err
+						}
+					} else {
+						return err
+					}
+				} else {
+					return err
+				}
+			} else {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -168,11 +205,7 @@ func (self *Model) DeleteQuery(flt interface{}) error {
 	if f, err := filter.Parse(flt); err == nil {
 		f.IdentityField = self.collection.IdentityField
 
-		if search := self.db.WithSearch(self.collection, f); search != nil {
-			return search.DeleteQuery(self.collection, f)
-		} else {
-			return fmt.Errorf("backend %T does not support searching", self.db)
-		}
+		return backends.DeleteQuery(self.db, self.collection, f)
 	} else {
 		return err
 	}
@@ -371,6 +404,205 @@ func (self *Model) GroupBy(fields []string, aggregates []filter.Aggregate, flt i
 	}
 }
 
+// Retrieves the records related to the given instance (a struct, dal.Record, or ID) by the named
+// many-to-many relationship, and populates the slice or dal.RecordSet pointed to by the into
+// parameter with them.
+//
+func (self *Model) Related(instance interface{}, fieldName string, into interface{}) error {
+	if relationship, err := self.manyToMany(fieldName); err == nil {
+		if related, err := self.relatedCollection(relationship); err == nil {
+			var recordset = dal.NewRecordSet()
+
+			if ids, err := self.relatedIds(relationship, instance); err == nil && len(ids) > 0 {
+				var f = filter.New().AddCriteria(filter.Criterion{
+					Field:  related.GetIdentityFieldName(),
+					Values: ids,
+				})
+
+				f.IdentityField = related.GetIdentityFieldName()
+
+				if search := self.db.WithSearch(related, f); search != nil {
+					if rs, err := search.Query(related, f); err == nil {
+						recordset = rs
+					} else {
+						return err
+					}
+				} else {
+					return fmt.Errorf("backend %T does not support searching", self.db)
+				}
+			} else if err != nil {
+				return err
+			}
+
+			if rs, ok := into.(*dal.RecordSet); ok {
+				*rs = *recordset
+				return nil
+			} else {
+				return recordset.PopulateFromRecords(into, related)
+			}
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+// Relates the given instance to each of the given related instances (structs, dal.Records, or IDs)
+// by the named many-to-many relationship.  Instances that are already related are left as-is.
+//
+func (self *Model) Relate(instance interface{}, fieldName string, relatedInstances ...interface{}) error {
+	if relationship, err := self.manyToMany(fieldName); err == nil {
+		if related, err := self.relatedCollection(relationship); err == nil {
+			if existing, err := self.relatedIds(relationship, instance); err == nil {
+				var id = self.collection.IdentityValueOf(instance)
+				var joins = dal.NewRecordSet()
+
+				for _, relatedInstance := range relatedInstances {
+					if relatedId := related.IdentityValueOf(relatedInstance); typeutil.IsZero(relatedId) {
+						return fmt.Errorf("cannot relate a %s record without an ID", related.Name)
+					} else if !sliceutil.Contains(existing, relatedId) {
+						existing = append(existing, relatedId)
+
+						joins.Push(dal.NewRecord(nil).Set(
+							relationship.ThroughKey, id,
+						).Set(
+							relationship.ThroughRelatedKey, relatedId,
+						))
+					}
+				}
+
+				if len(joins.Records) == 0 {
+					return nil
+				} else if join, err := self.db.GetCollection(relationship.Through); err == nil {
+					for i, record := range joins.Records {
+						if r, err := join.StructToRecord(record); err == nil {
+							joins.Records[i] = r
+						} else {
+							return err
+						}
+					}
+
+					return self.db.Insert(join.Name, joins)
+				} else {
+					return err
+				}
+			} else {
+				return err
+			}
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+// Removes the relationship between the given instance and each of the given related instances
+// (structs, dal.Records, or IDs) by the named many-to-many relationship.  If no related instances
+// are given, the instance is unrelated from all records.  The related records themselves are
+// not deleted.
+//
+func (self *Model) Unrelate(instance interface{}, fieldName string, relatedInstances ...interface{}) error {
+	if relationship, err := self.manyToMany(fieldName); err == nil {
+		if related, err := self.relatedCollection(relationship); err == nil {
+			if join, err := self.db.GetCollection(relationship.Through); err == nil {
+				if id := self.collection.IdentityValueOf(instance); !typeutil.IsZero(id) {
+					var f = filter.New().AddCriteria(filter.Criterion{
+						Field:  relationship.ThroughKey,
+						Values: []interface{}{id},
+					})
+
+					if len(relatedInstances) > 0 {
+						var relatedIds = make([]interface{}, len(relatedInstances))
+
+						for i, relatedInstance := range relatedInstances {
+							relatedIds[i] = related.IdentityValueOf(relatedInstance)
+						}
+
+						f.AddCriteria(filter.Criterion{
+							Field:  relationship.ThroughRelatedKey,
+							Values: relatedIds,
+						})
+					}
+
+					f.IdentityField = join.GetIdentityFieldName()
+
+					if search := self.db.WithSearch(join, f); search != nil {
+						return search.DeleteQuery(join, f)
+					} else {
+						return fmt.Errorf("backend %T does not support searching", self.db)
+					}
+				} else {
+					return fmt.Errorf("cannot unrelate a %s record without an ID", self.collection.Name)
+				}
+			} else {
+				return err
+			}
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+// returns the many-to-many relationship with the given name
+func (self *Model) manyToMany(name string) (*dal.Relationship, error) {
+	for i, relationship := range self.collection.EmbeddedCollections {
+		if relationship.IsManyToMany() && relationship.Name() == name {
+			return &self.collection.EmbeddedCollections[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("collection %q has no many-to-many relationship %q", self.collection.Name, name)
+}
+
+func (self *Model) relatedCollection(relationship *dal.Relationship) (*dal.Collection, error) {
+	if relationship.Collection != nil {
+		return relationship.Collection, nil
+	} else {
+		return self.db.GetCollection(relationship.CollectionName)
+	}
+}
+
+// retrieves the IDs of the records related to the given instance, as recorded in the join collection
+func (self *Model) relatedIds(relationship *dal.Relationship, instance interface{}) ([]interface{}, error) {
+	var id = self.collection.IdentityValueOf(instance)
+	var ids = make([]interface{}, 0)
+
+	if typeutil.IsZero(id) {
+		return nil, fmt.Errorf("cannot retrieve records related to a %s record without an ID", self.collection.Name)
+	}
+
+	if join, err := self.db.GetCollection(relationship.Through); err == nil {
+		var f = filter.New().AddCriteria(filter.Criterion{
+			Field:  relationship.ThroughKey,
+			Values: []interface{}{id},
+		})
+
+		f.IdentityField = join.GetIdentityFieldName()
+
+		if search := self.db.WithSearch(join, f); search != nil {
+			if recordset, err := search.Query(join, f); err == nil {
+				for _, record := range recordset.Records {
+					if relatedId := record.Get(relationship.ThroughRelatedKey); relatedId != nil {
+						ids = append(ids, relatedId)
+					}
+				}
+
+				return sliceutil.Unique(ids), nil
+			} else {
+				return nil, err
+			}
+		} else {
+			return nil, fmt.Errorf("backend %T does not support searching", self.db)
+		}
+	} else {
+		return nil, err
+	}
+}
+
 func (self *Model) populateOutputParameter(f *filter.Filter, recordset *dal.RecordSet, into interface{}) error {
 	// for each resulting record...
 	for _, record := range recordset.Records {
@@ -0,0 +1,71 @@
+package pivot
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ghetzel/pivot/v3/backends"
+)
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Writes the given record size histograms in the Prometheus text exposition format.
+func writeRecordSizeMetrics(w io.Writer, stats []*backends.RecordSizeStat) {
+	fmt.Fprintln(w, `# HELP pivot_record_size_bytes The serialized size of records written to each collection.`)
+	fmt.Fprintln(w, `# TYPE pivot_record_size_bytes histogram`)
+
+	for _, stat := range stats {
+		var collection = metricLabelEscaper.Replace(stat.Collection)
+
+		for _, bucket := range stat.Buckets {
+			var le = `+Inf`
+
+			if !math.IsInf(bucket.UpperBound, 1) {
+				le = strconv.FormatFloat(bucket.UpperBound, 'f', -1, 64)
+			}
+
+			fmt.Fprintf(w, "pivot_record_size_bytes_bucket{collection=\"%s\",le=\"%s\"} %d\n", collection, le, bucket.Count)
+		}
+
+		fmt.Fprintf(w, "pivot_record_size_bytes_sum{collection=\"%s\"} %d\n", collection, stat.Sum)
+		fmt.Fprintf(w, "pivot_record_size_bytes_count{collection=\"%s\"} %d\n", collection, stat.Count)
+	}
+
+	fmt.Fprintln(w, `# HELP pivot_record_size_max_bytes The largest record written to each collection.`)
+	fmt.Fprintln(w, `# TYPE pivot_record_size_max_bytes gauge`)
+
+	for _, stat := range stats {
+		fmt.Fprintf(w, "pivot_record_size_max_bytes{collection=\"%s\"} %d\n", metricLabelEscaper.Replace(stat.Collection), stat.Max)
+	}
+}
+
+// Writes the number of times each deprecated field has been read, written, and queried in the
+// Prometheus text exposition format.
+func writeDeprecatedFieldMetrics(w io.Writer, usage []*backends.DeprecatedFieldUsage) {
+	for _, metric := range []struct {
+		name  string
+		help  string
+		value func(*backends.DeprecatedFieldUsage) int64
+	}{
+		{`reads`, `read`, func(u *backends.DeprecatedFieldUsage) int64 { return u.Reads }},
+		{`writes`, `written`, func(u *backends.DeprecatedFieldUsage) int64 { return u.Writes }},
+		{`queries`, `queried`, func(u *backends.DeprecatedFieldUsage) int64 { return u.Queries }},
+	} {
+		fmt.Fprintf(w, "# HELP pivot_deprecated_field_%s_total The number of times each deprecated field has been %s.\n", metric.name, metric.help)
+		fmt.Fprintf(w, "# TYPE pivot_deprecated_field_%s_total counter\n", metric.name)
+
+		for _, u := range usage {
+			fmt.Fprintf(
+				w,
+				"pivot_deprecated_field_%s_total{collection=\"%s\",field=\"%s\"} %d\n",
+				metric.name,
+				metricLabelEscaper.Replace(u.Collection),
+				metricLabelEscaper.Replace(u.Field),
+				metric.value(u),
+			)
+		}
+	}
+}
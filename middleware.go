@@ -0,0 +1,131 @@
+package pivot
+
+import (
+	"net/http"
+
+	"github.com/ghetzel/go-stockutil/httputil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+)
+
+// Middleware wraps the handler that serves every request made to the server.  It is called before
+// the request is routed, and may respond to the request itself (e.g.: to reject unauthenticated
+// requests) or pass it along to the next handler.
+type Middleware func(next http.Handler) http.Handler
+
+// A FilterHook is called with each filter parsed from an API request, before it is used to query
+// the backend.  Hooks may modify the filter (e.g.: to limit results to those the requester is
+// allowed to see).  Returning an error rejects the request with a 400 Bad Request.
+type FilterHook func(req *http.Request, f *filter.Filter) error
+
+// A ResponseHook is called with the data that is about to be encoded as the JSON response to an
+// API request, along with its HTTP status code.  The data and status it returns are encoded in
+// their place.  Errors being returned to the client are passed as an error value.
+type ResponseHook func(req *http.Request, data interface{}, status int) (interface{}, int)
+
+// Add middleware that will run for every request the server handles.  Middleware runs in the
+// order it was added, with the first middleware seeing the request first.
+func (self *Server) UseMiddleware(middleware ...Middleware) {
+	for _, mw := range middleware {
+		if mw != nil {
+			self.middleware = append(self.middleware, mw)
+		}
+	}
+}
+
+// Add hooks that are called with each filter parsed from an API request.  Hooks are called in the
+// order they were added, and the first one to return an error stops the request.
+func (self *Server) OnFilter(hooks ...FilterHook) {
+	for _, hook := range hooks {
+		if hook != nil {
+			self.filterHooks = append(self.filterHooks, hook)
+		}
+	}
+}
+
+// Add hooks that are called with each API response before it is encoded.  Hooks are called in
+// the order they were added, each receiving the output of the one before it.
+func (self *Server) OnResponse(hooks ...ResponseHook) {
+	for _, hook := range hooks {
+		if hook != nil {
+			self.responseHooks = append(self.responseHooks, hook)
+		}
+	}
+}
+
+// wraps the given handler in all registered middleware
+func (self *Server) applyMiddleware(handler http.Handler) http.Handler {
+	for i := len(self.middleware) - 1; i >= 0; i-- {
+		handler = self.middleware[i](handler)
+	}
+
+	return handler
+}
+
+// passes the given filter through all registered filter hooks
+func (self *Server) applyFilterHooks(req *http.Request, f *filter.Filter) error {
+	for _, hook := range self.filterHooks {
+		if err := hook(req, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parses a filter from the request, then passes it through all registered filter hooks
+func (self *Server) filterFromRequest(req *http.Request, filterIn interface{}, defaultLimit int64) (*filter.Filter, error) {
+	if f, err := filterFromRequest(req, filterIn, defaultLimit); err == nil {
+		if err := self.applyFilterHooks(req, f); err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	} else {
+		return nil, err
+	}
+}
+
+// passes the given data through all registered response hooks, then encodes it as JSON (formatting
+// the dates and numbers in records as the request asks)
+func (self *Server) respond(w http.ResponseWriter, req *http.Request, data interface{}, status ...int) {
+	if format, err := OutputFormatFromRequest(req); err != nil {
+		data = err
+		status = []int{http.StatusBadRequest}
+	} else if format != nil {
+		data = format.Apply(data)
+	}
+
+	if len(self.responseHooks) == 0 {
+		httputil.RespondJSON(w, responseBody(data), status...)
+		return
+	}
+
+	var code int
+
+	if len(status) > 0 {
+		code = status[0]
+	} else if _, ok := data.(error); ok {
+		code = http.StatusInternalServerError
+	} else {
+		code = http.StatusOK
+	}
+
+	for _, hook := range self.responseHooks {
+		data, code = hook(req, data, code)
+	}
+
+	httputil.RespondJSON(w, responseBody(data), code)
+}
+
+// validation errors are described field-by-field rather than with a single message
+func responseBody(data interface{}) interface{} {
+	if verrs, ok := data.(*dal.ValidationErrors); ok {
+		return map[string]interface{}{
+			`error`:  verrs.Error(),
+			`fields`: verrs.Fields,
+		}
+	}
+
+	return data
+}
@@ -0,0 +1,127 @@
+package pivot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerMiddleware(t *testing.T) {
+	assert := require.New(t)
+	server := NewServer(`memory://`)
+
+	var order []string
+
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+
+	server.UseMiddleware(tag(`first`), nil, tag(`second`))
+
+	handler := server.applyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		order = append(order, `handler`)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(`GET`, `/api/status`, nil))
+	assert.Equal([]string{`first`, `second`, `handler`}, order)
+}
+
+func TestServerFilterHooks(t *testing.T) {
+	assert := require.New(t)
+	server := NewServer(`memory://`)
+
+	server.OnFilter(func(req *http.Request, f *filter.Filter) error {
+		if tenant := req.Header.Get(`X-Tenant`); tenant != `` {
+			f.AddCriteria(filter.Criterion{
+				Field:  `tenant`,
+				Values: []interface{}{tenant},
+			})
+
+			return nil
+		}
+
+		return fmt.Errorf("missing tenant")
+	})
+
+	req := httptest.NewRequest(`GET`, `/api/collections/things/where/name/test`, nil)
+
+	_, err := server.filterFromRequest(req, `name/test`, 25)
+	assert.Error(err)
+
+	req.Header.Set(`X-Tenant`, `acme`)
+
+	f, err := server.filterFromRequest(req, `name/test`, 25)
+	assert.NoError(err)
+	assert.Len(f.Criteria, 2)
+	assert.Equal(`tenant`, f.Criteria[1].Field)
+	assert.Equal(25, f.Limit)
+}
+
+func TestServerResponseHooks(t *testing.T) {
+	assert := require.New(t)
+	server := NewServer(`memory://`)
+	req := httptest.NewRequest(`GET`, `/api/collections`, nil)
+
+	// without hooks, responses are encoded as-is
+	w := httptest.NewRecorder()
+	server.respond(w, req, []string{`a`})
+	assert.Equal(http.StatusOK, w.Code)
+
+	server.OnResponse(func(req *http.Request, data interface{}, status int) (interface{}, int) {
+		if _, ok := data.(error); ok {
+			return map[string]interface{}{
+				`message`: `something went wrong`,
+			}, http.StatusServiceUnavailable
+		}
+
+		return map[string]interface{}{
+			`data`: data,
+		}, status
+	})
+
+	w = httptest.NewRecorder()
+	server.respond(w, req, []string{`a`, `b`}, http.StatusCreated)
+	body, _ := ioutil.ReadAll(w.Body)
+
+	assert.Equal(http.StatusCreated, w.Code)
+	assert.JSONEq(`{"data":["a","b"]}`, string(body))
+
+	w = httptest.NewRecorder()
+	server.respond(w, req, fmt.Errorf("internal details"))
+	body, _ = ioutil.ReadAll(w.Body)
+
+	assert.Equal(http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(`{"message":"something went wrong"}`, string(body))
+}
+
+func TestServerValidationErrorResponse(t *testing.T) {
+	assert := require.New(t)
+	server := NewServer(`memory://`)
+	req := httptest.NewRequest(`POST`, `/api/collections/things/records`, nil)
+
+	verrs := dal.NewValidationErrors(`things`)
+	verrs.Add(`name`, dal.RequiredCode, fmt.Errorf("field \"name\" is required"))
+
+	w := httptest.NewRecorder()
+	server.respond(w, req, verrs, errorStatus(verrs))
+	body, _ := ioutil.ReadAll(w.Body)
+
+	assert.Equal(http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(`{
+		"error": "collection \"things\": 1 invalid field(s): name: field \"name\" is required",
+		"fields": {
+			"name": [{"code": "required", "message": "field \"name\" is required"}]
+		}
+	}`, string(body))
+}
@@ -30,6 +30,8 @@ type RecordSet = dal.RecordSet
 type Filter = filter.Filter
 type ConnectOptions = backends.ConnectOptions
 
+// A suggested interval between health checks of the backend.  Health checks are only run when an
+// interval is given (see ConnectOptions.HealthCheck).
 var MonitorCheckInterval = time.Duration(10) * time.Second
 var NetrcFile = ``
 
@@ -62,13 +64,113 @@ func NewDatabaseWithOptions(connection string, options ConnectOptions) (DB, erro
 
 			// TODO: add MultiIndexer if AdditionalIndexers is present
 
+			// serve collections matching any routes from their own backends
+			if len(options.Routes) > 0 {
+				multiplexer := backends.NewMultiplexBackend(backend)
+
+				for _, route := range options.Routes {
+					if rcs, err := dal.ParseConnectionString(route.Backend); err == nil {
+						if NetrcFile != `` {
+							if err := rcs.LoadCredentialsFromNetrc(NetrcFile); err != nil {
+								return nil, err
+							}
+						}
+
+						if routed, err := backends.MakeBackend(rcs); err == nil {
+							if err := multiplexer.Route(route.Collections, routed); err != nil {
+								return nil, err
+							}
+						} else {
+							return nil, fmt.Errorf("route %q: %v", route.Collections, err)
+						}
+					} else {
+						return nil, fmt.Errorf("route %q: %v", route.Collections, err)
+					}
+				}
+
+				backend = multiplexer
+			}
+
+			// wrap the backend so that collection definitions survive across processes
+			if options.PersistCollections {
+				backend = backends.NewCollectionRegistryBackend(backend)
+			}
+
+			// wrap the backend so that records are upgraded to their collection's latest schema as they're read
+			if len(options.Upgrades) > 0 {
+				upgrader := backends.NewUpgradingBackend(backend)
+
+				for collection, upgrades := range options.Upgrades {
+					upgrader.AddUpgrades(collection, upgrades...)
+				}
+
+				backend = upgrader
+			}
+
+			// wrap the backend so that rapid updates to the same records are merged
+			if len(options.Coalesce) > 0 {
+				coalescer := backends.NewCoalescingBackend(backend)
+
+				for collection, coalesceOptions := range options.Coalesce {
+					coalescer.Coalesce(collection, coalesceOptions)
+				}
+
+				backend = coalescer
+			}
+
+			// wrap the backend so that queries served by an external indexer can opt into
+			// seeing their own recent writes
+			if options.Indexer != `` {
+				backend = backends.NewConsistentReadBackend(backend)
+			}
+
+			// wrap the backend so we can track collection and field usage
+			if options.TrackUsage {
+				backend = backends.NewUsageTrackingBackend(backend)
+			}
+
+			// wrap the backend so that operations taking longer than the configured defaults fail
+			// with a TimeoutError
+			if options.DefaultQueryTimeout > 0 || options.DefaultWriteTimeout > 0 {
+				backend = backends.NewTimeoutBackend(backend, options.DefaultQueryTimeout, options.DefaultWriteTimeout)
+			}
+
 			if !options.SkipInitialize {
 				if err := backend.Initialize(); err != nil {
 					return nil, err
 				}
 			}
 
-			return newdb(backend), nil
+			// periodically remove index entries for records that were deleted without going through pivot
+			if options.Indexer != `` && options.IndexGC.Interval > 0 {
+				if err := backends.NewIndexGarbageCollector(backend, options.IndexGC).Start(); err != nil {
+					return nil, err
+				}
+			}
+
+			db := newdb(backend)
+
+			// periodically check that the backend and indexer are reachable, reconnecting if they aren't
+			if options.HealthCheck.Interval > 0 {
+				db.health = backends.NewHealthMonitor(backend, options.HealthCheck)
+
+				if err := db.health.Start(); err != nil {
+					return nil, err
+				}
+			}
+
+			// periodically count the records in each collection, alerting when the counts cross thresholds
+			if options.Stats.Interval > 0 {
+				db.stats = backends.NewStatsRefresher(backend, options.Stats)
+
+				if err := db.stats.Start(); err != nil {
+					return nil, err
+				}
+			} else if len(options.Stats.Alerts) > 0 {
+				return nil, fmt.Errorf("record count alerts require a stats refresh interval")
+			}
+
+			return db, nil
 		} else {
 			return nil, err
 		}
@@ -167,6 +269,8 @@ func ApplySchemata(fileOrDirPath string, db Backend) error {
 			} else if dal.IsCollectionNotFoundErr(err) {
 				if err := db.CreateCollection(schema); err == nil {
 					log.Noticef("[%v] Created collection %q", db, schema.Name)
+				} else if dal.IsCollectionExistsErr(err) {
+					log.Debugf("[%v] Collection %q was created elsewhere", db, schema.Name)
 				} else {
 					log.Errorf("Cannot create collection %q: %v", schema.Name, err)
 				}
@@ -326,3 +430,9 @@ func MustGetCollection(db Backend, name string) *dal.Collection {
 		panic(fmt.Sprintf("Cannot get collection %q: %v", name, err))
 	}
 }
+
+// Builds a collection definition from the given struct's fields and their `pivot` tags.  See
+// dal.CollectionFromStruct for the options tags may specify.
+func CollectionFromStruct(instance interface{}) (*dal.Collection, error) {
+	return dal.CollectionFromStruct(instance)
+}
@@ -0,0 +1,89 @@
+package pivot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/pivot/v3/backends"
+)
+
+// Describes a reindexing job started with POST /api/collections/:collection/reindex.
+type ReindexStatus struct {
+	Collection string                   `json:"collection"`
+	Running    bool                     `json:"running"`
+	StartedAt  time.Time                `json:"started_at"`
+	FinishedAt *time.Time               `json:"finished_at,omitempty"`
+	Progress   backends.ReindexProgress `json:"progress"`
+	Error      string                   `json:"error,omitempty"`
+}
+
+// tracks the most recent reindexing job for each collection, which run in the background
+type reindexJobs struct {
+	sync.Mutex
+	jobs map[string]*ReindexStatus
+}
+
+// start reindexing the named collection in the background, unless it is already being reindexed
+func (self *reindexJobs) start(backend Backend, name string, options backends.ReindexOptions) (ReindexStatus, error) {
+	self.Lock()
+	defer self.Unlock()
+
+	if self.jobs == nil {
+		self.jobs = make(map[string]*ReindexStatus)
+	}
+
+	if job, ok := self.jobs[name]; ok && job.Running {
+		return *job, fmt.Errorf("collection %q is already being reindexed", name)
+	}
+
+	var job = &ReindexStatus{
+		Collection: name,
+		Running:    true,
+		StartedAt:  time.Now(),
+	}
+
+	self.jobs[name] = job
+
+	options.Progress = func(progress *backends.ReindexProgress) {
+		self.Lock()
+		defer self.Unlock()
+
+		job.Progress = *progress
+	}
+
+	go func() {
+		progress, err := backends.Reindex(backend, name, options)
+
+		self.Lock()
+		defer self.Unlock()
+
+		var finished = time.Now()
+
+		job.Running = false
+		job.FinishedAt = &finished
+		job.Progress = *progress
+
+		if err == nil {
+			log.Infof("[%v] reindexed %d records in %v", name, progress.Indexed, progress.Elapsed)
+		} else {
+			job.Error = err.Error()
+			log.Warningf("[%v] reindexing failed after %d records: %v", name, progress.Indexed, err)
+		}
+	}()
+
+	return *job, nil
+}
+
+// returns the status of the most recent reindexing job for the named collection
+func (self *reindexJobs) status(name string) (ReindexStatus, bool) {
+	self.Lock()
+	defer self.Unlock()
+
+	if job, ok := self.jobs[name]; ok {
+		return *job, true
+	}
+
+	return ReindexStatus{}, false
+}
@@ -0,0 +1,94 @@
+package pivot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// a backend whose queries are served by a separate (and forgetful) index
+type testIndexedBackend struct {
+	*spi.Adapter
+	index *testIndex
+}
+
+func (self *testIndexedBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) backends.Indexer {
+	return self.index
+}
+
+type testIndex struct {
+	backends.Indexer
+}
+
+func (self *testIndex) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return nil
+}
+
+func (self *testIndex) FlushIndex() error {
+	return nil
+}
+
+func TestReindexJobs(t *testing.T) {
+	assert := require.New(t)
+
+	db := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	assert.NoError(db.CreateCollection(dal.NewCollection(`things`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	assert.NoError(db.Insert(`things`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `a`),
+		dal.NewRecord(2).Set(`name`, `b`),
+		dal.NewRecord(3).Set(`name`, `c`),
+	)))
+
+	backend := &testIndexedBackend{
+		Adapter: db,
+		index:   &testIndex{},
+	}
+
+	var jobs reindexJobs
+
+	_, ok := jobs.status(`things`)
+	assert.False(ok)
+
+	status, err := jobs.start(backend, `things`, backends.ReindexOptions{})
+	assert.NoError(err)
+	assert.Equal(`things`, status.Collection)
+	assert.True(status.Running)
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if status, _ = jobs.status(`things`); !status.Running {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.False(status.Running)
+	assert.NotNil(status.FinishedAt)
+	assert.Empty(status.Error)
+	assert.Equal(3, status.Progress.Indexed)
+
+	// failures are reported in the job's status
+	_, err = jobs.start(db, `things`, backends.ReindexOptions{})
+	assert.NoError(err)
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if status, _ = jobs.status(`things`); !status.Running {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Contains(status.Error, `does not have an external index`)
+}
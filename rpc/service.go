@@ -0,0 +1,216 @@
+// Package rpc defines the gRPC service (see pivot.proto) shared by the pivot server and its Go
+// client.  Messages are protobuf's well-known Struct types, which Encode and Decode convert to and
+// from the request types below (and any other JSON-serializable value, like dal.Record).
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const ServiceName = `pivot.Pivot`
+
+// The full names of the service's methods, as used by grpc.ClientConn.Invoke.
+const (
+	MethodStatus        = `/` + ServiceName + `/Status`
+	MethodCollections   = `/` + ServiceName + `/Collections`
+	MethodGetCollection = `/` + ServiceName + `/GetCollection`
+	MethodCreate        = `/` + ServiceName + `/Create`
+	MethodRetrieve      = `/` + ServiceName + `/Retrieve`
+	MethodUpdate        = `/` + ServiceName + `/Update`
+	MethodDelete        = `/` + ServiceName + `/Delete`
+	MethodQuery         = `/` + ServiceName + `/Query`
+)
+
+// The body of Create and Update requests.
+type RecordsRequest struct {
+	Collection string        `json:"collection"`
+	Records    []*dal.Record `json:"records"`
+}
+
+// The body of Retrieve requests.
+type RetrieveRequest struct {
+	Collection string      `json:"collection"`
+	ID         interface{} `json:"id"`
+	Fields     []string    `json:"fields,omitempty"`
+}
+
+// The body of Delete requests.
+type DeleteRequest struct {
+	Collection string        `json:"collection"`
+	IDs        []interface{} `json:"ids"`
+}
+
+// The body of Query requests.
+type QueryRequest struct {
+	Collection  string      `json:"collection"`
+	Filter      string      `json:"filter,omitempty"`
+	Sort        []string    `json:"sort,omitempty"`
+	Fields      []string    `json:"fields,omitempty"`
+	Limit       int         `json:"limit,omitempty"`
+	Offset      int         `json:"offset,omitempty"`
+	Conjunction string      `json:"conjunction,omitempty"`
+	After       interface{} `json:"after,omitempty"`
+}
+
+// Implemented by the server to handle the service's methods.
+type PivotServer interface {
+	Status(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+	Collections(context.Context, *emptypb.Empty) (*structpb.ListValue, error)
+	GetCollection(context.Context, *wrapperspb.StringValue) (*structpb.Struct, error)
+	Create(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	Retrieve(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	Update(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	Delete(context.Context, *structpb.Struct) (*emptypb.Empty, error)
+	Query(*structpb.Struct, QueryServerStream) error
+}
+
+// The stream that Query sends matching records to.
+type QueryServerStream interface {
+	Send(*structpb.Struct) error
+	grpc.ServerStream
+}
+
+type queryServerStream struct {
+	grpc.ServerStream
+}
+
+func (self *queryServerStream) Send(record *structpb.Struct) error {
+	return self.ServerStream.SendMsg(record)
+}
+
+// Describes the Pivot service to grpc.Server.RegisterService.
+var ServiceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*PivotServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unary(`Status`, func() proto.Message { return new(emptypb.Empty) }, func(srv PivotServer, ctx context.Context, in proto.Message) (interface{}, error) {
+			return srv.Status(ctx, in.(*emptypb.Empty))
+		}),
+		unary(`Collections`, func() proto.Message { return new(emptypb.Empty) }, func(srv PivotServer, ctx context.Context, in proto.Message) (interface{}, error) {
+			return srv.Collections(ctx, in.(*emptypb.Empty))
+		}),
+		unary(`GetCollection`, func() proto.Message { return new(wrapperspb.StringValue) }, func(srv PivotServer, ctx context.Context, in proto.Message) (interface{}, error) {
+			return srv.GetCollection(ctx, in.(*wrapperspb.StringValue))
+		}),
+		unary(`Create`, newStruct, func(srv PivotServer, ctx context.Context, in proto.Message) (interface{}, error) {
+			return srv.Create(ctx, in.(*structpb.Struct))
+		}),
+		unary(`Retrieve`, newStruct, func(srv PivotServer, ctx context.Context, in proto.Message) (interface{}, error) {
+			return srv.Retrieve(ctx, in.(*structpb.Struct))
+		}),
+		unary(`Update`, newStruct, func(srv PivotServer, ctx context.Context, in proto.Message) (interface{}, error) {
+			return srv.Update(ctx, in.(*structpb.Struct))
+		}),
+		unary(`Delete`, newStruct, func(srv PivotServer, ctx context.Context, in proto.Message) (interface{}, error) {
+			return srv.Delete(ctx, in.(*structpb.Struct))
+		}),
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    `Query`,
+			ServerStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				var in = new(structpb.Struct)
+
+				if err := stream.RecvMsg(in); err != nil {
+					return err
+				}
+
+				return srv.(PivotServer).Query(in, &queryServerStream{stream})
+			},
+		},
+	},
+	Metadata: `pivot.proto`,
+}
+
+// Register the given implementation of the Pivot service with a gRPC server.
+func RegisterPivotServer(server *grpc.Server, srv PivotServer) {
+	server.RegisterService(&ServiceDesc, srv)
+}
+
+// Open a stream of the records matching a query.
+func NewQueryStream(ctx context.Context, conn grpc.ClientConnInterface, request *structpb.Struct, options ...grpc.CallOption) (grpc.ClientStream, error) {
+	if stream, err := conn.NewStream(ctx, &ServiceDesc.Streams[0], MethodQuery, options...); err == nil {
+		if err := stream.SendMsg(request); err != nil {
+			return nil, err
+		}
+
+		if err := stream.CloseSend(); err != nil {
+			return nil, err
+		}
+
+		return stream, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Convert any value with a JSON object representation into a Struct.
+func Encode(in interface{}) (*structpb.Struct, error) {
+	var out = new(structpb.Struct)
+
+	if data, err := json.Marshal(in); err == nil {
+		if err := protojson.Unmarshal(data, out); err == nil {
+			return out, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// Populate the given value from a Struct's JSON representation.  As with JSON, numbers are decoded
+// as float64 unless the value being populated says otherwise.
+func Decode(in *structpb.Struct, into interface{}) error {
+	if data, err := protojson.Marshal(in); err == nil {
+		return json.Unmarshal(data, into)
+	} else {
+		return err
+	}
+}
+
+func newStruct() proto.Message {
+	return new(structpb.Struct)
+}
+
+// builds the descriptor of a unary method, decoding its request and passing it through any
+// interceptors the server was created with
+func unary(
+	name string,
+	newRequest func() proto.Message,
+	call func(srv PivotServer, ctx context.Context, in proto.Message) (interface{}, error),
+) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			var in = newRequest()
+
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+
+			var handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(PivotServer), ctx, req.(proto.Message))
+			}
+
+			if interceptor == nil {
+				return handler(ctx, in)
+			}
+
+			return interceptor(ctx, in, &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: `/` + ServiceName + `/` + name,
+			}, handler)
+		},
+	}
+}
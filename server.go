@@ -1,6 +1,7 @@
 package pivot
 
 //go:generate esc -o static.go -pkg pivot -modtime 1500000000 -prefix ui ui
+//go:generate sh -c "printf '//go:build !noui\n// +build !noui\n\n' | cat - static.go > static.go.tmp && mv static.go.tmp static.go"
 
 import (
 	"encoding/json"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ghetzel/diecast"
@@ -18,6 +20,7 @@ import (
 	"github.com/ghetzel/go-stockutil/log"
 	"github.com/ghetzel/go-stockutil/maputil"
 	"github.com/ghetzel/go-stockutil/pathutil"
+	"github.com/ghetzel/go-stockutil/sliceutil"
 	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/ghetzel/go-stockutil/typeutil"
 	"github.com/ghetzel/pivot/v3/backends"
@@ -33,17 +36,54 @@ var DefaultPort = 29029
 var DefaultResultLimit = 25
 var DefaultUiDirectory = `embedded`
 
+// The number of change events buffered for each client of the changes endpoint.  Clients that
+// fall this far behind are disconnected.
+var ChangeStreamBufferSize = 256
+
+// How often a comment is sent to idle change stream clients to keep the connection open.
+var ChangeStreamKeepaliveInterval = 15 * time.Second
+
+// Anything that HTTP handlers can be registered with, such as an *http.ServeMux.
+type ServeMux interface {
+	Handle(pattern string, handler http.Handler)
+}
+
 type Server struct {
-	Address          string
-	ConnectionString string
-	ConnectOptions   backends.ConnectOptions
-	UiDirectory      string
-	Autoexpand       bool
-	backend          Backend
-	endpoints        []util.Endpoint
-	routeMap         map[string]util.EndpointResponseFunc
-	schemaDefs       []string
-	fixturePaths     []string
+	Address            string
+	ConnectionString   string
+	ConnectOptions     backends.ConnectOptions
+	UiDirectory        string
+	Autoexpand         bool
+	MaxEmbeddedRecords int // the most related records embedded at each key of a record (0 is unlimited)
+	MaxEmbeddedBytes   int // the most bytes of related records embedded at each key of a record (0 is unlimited)
+	EmbedLinks         bool
+	DisableCompression bool
+	DisableCoalescing  bool
+	Tracing            bool   // trace API requests and the backend operations they perform with OpenTelemetry
+	GraphQL            bool   // serve a GraphQL API for the backend's collections at /api/graphql
+	GRPCAddress        string // also serve the gRPC service (see rpc/pivot.proto) at this address
+	Limits             RequestLimits
+	MirrorTo           string                 // the connection string of a backend to mirror requests to
+	Mirror             backends.MirrorOptions // how requests are mirrored to the MirrorTo backend
+	TLSCertFile        string
+	TLSKeyFile         string
+	backend            Backend
+	endpoints          []util.Endpoint
+	routeMap           map[string]util.EndpointResponseFunc
+	schemaDefs         []string
+	fixturePaths       []string
+	joinBackendDefs    map[string]string
+	joinBackends       map[string]Backend
+	api                http.Handler
+	ui                 http.Handler
+	initialized        bool
+	middleware         []Middleware
+	filterHooks        []FilterHook
+	responseHooks      []ResponseHook
+	queries            queryGroup
+	rateLimiter        *rateLimiter
+	graphqlCache       graphqlCache
+	reindexing         reindexJobs
 }
 
 func NewServer(connectionString ...string) *Server {
@@ -53,6 +93,8 @@ func NewServer(connectionString ...string) *Server {
 		UiDirectory:      DefaultUiDirectory,
 		endpoints:        make([]util.Endpoint, 0),
 		routeMap:         make(map[string]util.EndpointResponseFunc),
+		joinBackendDefs:  make(map[string]string),
+		joinBackends:     make(map[string]Backend),
 	}
 }
 
@@ -74,22 +116,51 @@ func (self *Server) AddFixturePath(fileOrDirPath string) {
 	self.fixturePaths = append(self.fixturePaths, fileOrDirPath)
 }
 
-func (self *Server) ListenAndServe() error {
-	uiDir := self.UiDirectory
-	loadedCollections := make([]*dal.Collection, 0)
+// Register an additional backend that can be used as the right-hand side of joined queries by
+// specifying ?join_backend=NAME.  This allows joining collections that live in different backends
+// (e.g.: PostgreSQL to Elasticsearch).
+func (self *Server) AddJoinBackend(name string, connectionString string) {
+	self.joinBackendDefs[name] = connectionString
+}
 
-	if d := os.Getenv(`UI`); fileutil.DirExists(d) {
-		uiDir = d
-	} else if self.UiDirectory == `embedded` {
-		uiDir = `/`
+// Connect to the backend, load schema definitions and fixtures, and build the server's routes.
+// This is called automatically by ListenAndServe, Handler, and RegisterRoutes, and only has an
+// effect the first time it is called.
+func (self *Server) Initialize() error {
+	if self.initialized {
+		return nil
 	}
 
+	loadedCollections := make([]*dal.Collection, 0)
+
 	if backend, err := NewDatabaseWithOptions(self.ConnectionString, self.ConnectOptions); err == nil {
+		// repeat a sample of requests against the mirror to compare it with the primary backend
+		if self.MirrorTo != `` {
+			if mirror, err := NewDatabase(self.MirrorTo); err == nil {
+				backend.SetBackend(backends.NewMirroringBackend(backend.GetBackend(), mirror.GetBackend(), self.Mirror))
+				log.Infof("Mirroring %v%% of reads to %v (writes: %v)", self.Mirror.Percent, self.MirrorTo, self.Mirror.Writes)
+			} else {
+				return fmt.Errorf("mirror backend: %v", err)
+			}
+		}
+
+		// watch for changes from the start so that change stream clients don't need to modify the
+		// backend while other requests are using it
+		backend.SetBackend(backends.NewChangeWatchingBackend(backend.GetBackend()))
 		self.backend = backend
 	} else {
 		return err
 	}
 
+	// connect to any additional backends used for joins
+	for name, connectionString := range self.joinBackendDefs {
+		if backend, err := NewDatabase(connectionString); err == nil {
+			self.joinBackends[name] = backend
+		} else {
+			return fmt.Errorf("join backend %q: %v", name, err)
+		}
+	}
+
 	// if specified, pre-load schema definitions
 	for _, filename := range self.schemaDefs {
 		if collections, err := LoadSchemataFromFile(filename); err == nil {
@@ -116,6 +187,8 @@ func (self *Server) ListenAndServe() error {
 			} else if dal.ShouldCreateCollection(schema, err) {
 				if err := self.backend.CreateCollection(schema); err == nil {
 					log.Noticef("[%v] Created collection %q", self.backend, schema.Name)
+				} else if dal.IsCollectionExistsErr(err) {
+					log.Debugf("[%v] Collection %q was created elsewhere", self.backend, schema.Name)
 				} else {
 					log.Errorf("[%v] Error creating collection %q: %v", self.backend, schema.Name, err)
 				}
@@ -132,37 +205,162 @@ func (self *Server) ListenAndServe() error {
 		}
 	}
 
+	router := vestigo.NewRouter()
+
+	if err := self.setupRoutes(router); err != nil {
+		return err
+	}
+
+	self.api = router
+
+	if self.UiDirectory != `` {
+		uiDir := self.UiDirectory
+		useEmbedded := false
+
+		if d := os.Getenv(`UI`); fileutil.DirExists(d) {
+			uiDir = d
+		} else if self.UiDirectory == `embedded` {
+			uiDir = `/`
+			useEmbedded = true
+		}
+
+		// headless builds only serve the API unless given a UI directory to use instead
+		if useEmbedded && !UiEmbedded {
+			log.Infof("web UI is not available in this build; only serving the API")
+			self.initialized = true
+			return nil
+		}
+
+		ui := diecast.NewServer(uiDir, `*.html`)
+
+		// tell diecast where loopback requests should go
+		if strings.HasPrefix(self.Address, `:`) {
+			ui.BindingPrefix = fmt.Sprintf("%s://localhost%s", self.scheme(), self.Address)
+		} else {
+			ui.BindingPrefix = fmt.Sprintf("%s://%s", self.scheme(), self.Address)
+		}
+
+		if useEmbedded {
+			ui.SetFileSystem(embeddedUiFileSystem())
+		}
+
+		if err := ui.Initialize(); err != nil {
+			return err
+		}
+
+		self.ui = ui
+	}
+
+	self.initialized = true
+	return nil
+}
+
+// Register the server's routes with an existing mux: the API under /api/, and (unless
+// UiDirectory is empty) the web UI under /.  Requests are passed through any middleware added
+// with UseMiddleware, but compression and request logging are left to the caller's own stack.
+// To mount the server under a subpath, use Handler with http.StripPrefix instead.
+func (self *Server) RegisterRoutes(mux ServeMux) error {
+	if err := self.Initialize(); err != nil {
+		return err
+	}
+
+	mux.Handle(`/api/`, self.applyMiddleware(self.api))
+
+	if self.ui != nil {
+		mux.Handle(`/`, self.applyMiddleware(self.ui))
+	}
+
+	return nil
+}
+
+// Returns an http.Handler that serves the complete server (API, UI, and middleware), suitable for
+// use with an application's own http.Server.  If the server fails to initialize, the error is
+// logged and the returned handler responds to all requests with it.
+func (self *Server) Handler() http.Handler {
+	if err := self.Initialize(); err != nil {
+		log.Errorf("pivot server failed to initialize: %v", err)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			httputil.RespondJSON(w, err, http.StatusServiceUnavailable)
+		})
+	}
+
 	server := negroni.New()
 	mux := http.NewServeMux()
-	router := vestigo.NewRouter()
-	ui := diecast.NewServer(uiDir, `*.html`)
 
-	// tell diecast where loopback requests should go
-	if strings.HasPrefix(self.Address, `:`) {
-		ui.BindingPrefix = fmt.Sprintf("http://localhost%s", self.Address)
+	mux.Handle(`/api/`, self.api)
+
+	if self.ui != nil {
+		mux.Handle(`/`, self.ui)
 	} else {
-		ui.BindingPrefix = fmt.Sprintf("http://%s", self.Address)
+		// without a UI, everything outside of the API is not found
+		mux.Handle(`/`, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			self.respond(w, req, fmt.Errorf("Not Found"), http.StatusNotFound)
+		}))
 	}
 
-	if self.UiDirectory == `embedded` {
-		ui.SetFileSystem(FS(false))
+	// tracing wraps everything else so that time spent in other middleware is included
+	if self.Tracing {
+		server.Use(negroni.HandlerFunc(self.tracingMiddleware))
 	}
 
-	if err := ui.Initialize(); err != nil {
-		return err
+	// rate limiting comes as early as possible so that rejecting requests is cheap
+	if self.Limits.RequestsPerSecond > 0 {
+		if self.rateLimiter == nil {
+			self.rateLimiter = newRateLimiter(self.Limits.RequestsPerSecond, self.Limits.Burst)
+		}
+
+		server.Use(negroni.HandlerFunc(self.rateLimitMiddleware))
 	}
 
-	if err := self.setupRoutes(router); err != nil {
-		return err
+	if !self.DisableCompression {
+		server.Use(negroni.HandlerFunc(compressionMiddleware))
 	}
 
-	mux.Handle(`/api/`, router)
-	mux.Handle(`/`, ui)
+	// size limits apply to request bodies after they've been decompressed
+	if self.Limits.MaxBodySize > 0 {
+		server.Use(negroni.HandlerFunc(self.requestSizeMiddleware))
+	}
 
-	server.UseHandler(mux)
+	// embedding applications' middleware runs before requests are routed
+	server.UseHandler(self.applyMiddleware(mux))
 	server.Use(httputil.NewRequestLogger())
-	server.Run(self.Address)
-	return nil
+
+	return server
+}
+
+func (self *Server) ListenAndServe() error {
+	if err := self.Initialize(); err != nil {
+		return err
+	}
+
+	if self.GRPCAddress != `` {
+		if err := self.listenGRPC(); err != nil {
+			return err
+		}
+	}
+
+	httpServer := &http.Server{
+		Addr:    self.Address,
+		Handler: self.Handler(),
+	}
+
+	log.Infof("listening at %s://%s", self.scheme(), self.Address)
+
+	// net/http negotiates HTTP/2 automatically for TLS connections
+	if self.scheme() == `https` {
+		return httpServer.ListenAndServeTLS(self.TLSCertFile, self.TLSKeyFile)
+	} else {
+		return httpServer.ListenAndServe()
+	}
+}
+
+func (self *Server) scheme() string {
+	if self.TLSCertFile != `` && self.TLSKeyFile != `` {
+		return `https`
+	}
+
+	return `http`
 }
 
 func (self *Server) setupRoutes(router *vestigo.Router) error {
@@ -184,11 +382,30 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 				Backend:     backend.GetConnectionString().String(),
 			}
 
-			if indexer := backend.WithSearch(nil, nil); indexer != nil {
+			// ask the server's own backend so that the indexer isn't hidden behind per-request wrappers
+			if indexer := self.backend.WithSearch(nil, nil); indexer != nil {
 				status.Indexer = indexer.IndexConnectionString().String()
+
+				if queued, ok := indexer.(*backends.QueuedIndexer); ok {
+					queueStatus := queued.QueueStatus()
+					status.IndexQueue = &queueStatus
+				}
+			}
+
+			// report whether the backend has been reachable, if it is being monitored
+			if db, ok := self.backend.(DB); ok {
+				if health := db.Health(); health != nil {
+					status.Health = health
+
+					if !health.OK {
+						status.OK = false
+						self.respond(w, req, &status, http.StatusServiceUnavailable)
+						return
+					}
+				}
 			}
 
-			httputil.RespondJSON(w, &status)
+			self.respond(w, req, &status)
 		})
 
 	router.Get(`/api/collections`,
@@ -196,9 +413,9 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			backend := backendForRequest(self, req, self.backend)
 
 			if names, err := backend.ListCollections(); err == nil {
-				httputil.RespondJSON(w, names)
+				self.respond(w, req, names)
 			} else {
-				httputil.RespondJSON(w, err)
+				self.respond(w, req, err, errorStatus(err))
 			}
 		})
 
@@ -220,7 +437,7 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			name, leftField = stringutil.SplitPair(collections[0], `.`)
 			rightName, rightField = stringutil.SplitPair(collections[1], `.`)
 		default:
-			httputil.RespondJSON(w, fmt.Errorf("Only two (2) joined collections are supported"), http.StatusBadRequest)
+			self.respond(w, req, fmt.Errorf("Only two (2) joined collections are supported"), http.StatusBadRequest)
 			return
 		}
 
@@ -236,14 +453,26 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			if err := httputil.ParseRequest(req, &fMap); err == nil {
 				query = fMap
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				self.respond(w, req, err, http.StatusBadRequest)
 				return
 			}
 		}
 
+		// free-text queries (?q=) are matched against the collection's search fields, and may be
+		// given on their own or to narrow down the results of a filter
+		var freetext = httputil.Q(req, `q`)
+
+		if freetext != `` && query == nil {
+			query = filter.All()
+		}
+
 		backend := backendForRequest(self, req, self.backend)
 
-		if f, err := filterFromRequest(req, query, int64(DefaultResultLimit)); err == nil {
+		if f, err := self.filterFromRequest(req, query, int64(DefaultResultLimit)); err == nil {
+			if freetext != `` {
+				f.Search(freetext)
+			}
+
 			if collection, err := backend.GetCollection(name); err == nil {
 				collection = injectRequestParamsIntoCollection(req, collection)
 
@@ -253,9 +482,20 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 					if rightName == `` {
 						queryInterface = search
 					} else {
-						if rightCollection, err := backend.GetCollection(rightName); err == nil {
-							// leaving this here, though a little redundant, for when we support heterogeneous backends
-							if rightSearch := backend.WithSearch(rightCollection, f); rightSearch != nil {
+						rightBackend := backend
+
+						// the right-hand side of the join may come from a different backend entirely
+						if joinName := httputil.Q(req, `join_backend`); joinName != `` {
+							if jb, ok := self.joinBackends[joinName]; ok {
+								rightBackend = backendForRequest(self, req, jb)
+							} else {
+								self.respond(w, req, fmt.Errorf("Unknown join backend %q", joinName), http.StatusBadRequest)
+								return
+							}
+						}
+
+						if rightCollection, err := rightBackend.GetCollection(rightName); err == nil {
+							if rightSearch := rightBackend.WithSearch(rightCollection, f); rightSearch != nil {
 								queryInterface = backends.NewMetaIndex(
 									search,
 									collection,
@@ -265,30 +505,46 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 									rightField,
 								)
 							} else {
-								httputil.RespondJSON(w, fmt.Errorf("Backend %T does not support complex queries.", self.backend), http.StatusBadRequest)
+								self.respond(w, req, fmt.Errorf("Backend %T does not support complex queries.", rightBackend), http.StatusBadRequest)
 								return
 							}
 						} else {
-							httputil.RespondJSON(w, fmt.Errorf("right-side: %v", err))
+							self.respond(w, req, fmt.Errorf("right-side: %v", err))
 							return
 						}
 					}
 
-					if recordset, err := queryInterface.Query(collection, f); err == nil {
-						httputil.RespondJSON(w, recordset)
+					if format := httputil.Q(req, `format`, `json`); format != `json` {
+						// exports are only limited if a limit was explicitly asked for
+						if httputil.Q(req, `limit`) == `` {
+							f.Limit = 0
+						}
+
+						self.streamRecords(w, req, format, queryInterface, collection, f)
+					} else if httputil.QBool(req, `stream`) && self.canStreamRecordSet(collection) {
+						self.streamRecordSet(w, req, queryInterface, collection, f)
+					} else if recordset, err := self.coalesceQuery(req, f, func() (*dal.RecordSet, error) {
+						return queryInterface.Query(collection, f)
+					}); err == nil {
+						if self.applyCachePolicy(w, req, collection, recordset.Records...) {
+							return
+						}
+
+						self.embedLinks(req, collection, recordset.Records...)
+						self.respond(w, req, recordset)
 					} else {
-						httputil.RespondJSON(w, err)
+						self.respond(w, req, err, errorStatus(err))
 					}
 				} else {
-					httputil.RespondJSON(w, fmt.Errorf("Backend %T does not support complex queries.", self.backend), http.StatusBadRequest)
+					self.respond(w, req, fmt.Errorf("Backend %T does not support complex queries.", self.backend), http.StatusBadRequest)
 				}
 			} else if dal.IsCollectionNotFoundErr(err) {
-				httputil.RespondJSON(w, err, http.StatusNotFound)
+				self.respond(w, req, err, http.StatusNotFound)
 			} else {
-				httputil.RespondJSON(w, err)
+				self.respond(w, req, err, errorStatus(err))
 			}
 		} else {
-			httputil.RespondJSON(w, err, http.StatusBadRequest)
+			self.respond(w, req, err, http.StatusBadRequest)
 		}
 	}
 
@@ -296,78 +552,177 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 	router.Get(`/api/collections/:collection/query/`, queryHandler)
 	router.Get(`/api/collections/:collection/where/*urlquery`, queryHandler)
 
-	router.Get(`/api/collections/:collection/aggregate/:fields`,
-		func(w http.ResponseWriter, req *http.Request) {
-			var name = vestigo.Param(req, `collection`)
-			var fields = strings.Split(vestigo.Param(req, `fields`), `,`)
-			var aggregations = strings.Split(httputil.Q(req, `fn`, `count`), `,`)
-			var backend = backendForRequest(self, req, self.backend)
-			var defaultField = httputil.Q(req, `field`)
+	// aggregates the values of fields across all records matching a query, or (if ?group= is given)
+	// returns one record per distinct combination of values of the grouped fields
+	aggregateHandler := func(w http.ResponseWriter, req *http.Request) {
+		var name = vestigo.Param(req, `collection`)
+		var fields = sliceutil.CompactString(strings.Split(vestigo.Param(req, `fields`), `,`))
+		var aggregations = strings.Split(httputil.Q(req, `fn`, `count`), `,`)
+		var backend = backendForRequest(self, req, self.backend)
+		var groups = httputil.QStrings(req, `group`, `,`)
+
+		if len(fields) == 0 && len(groups) == 0 {
+			self.respond(w, req, fmt.Errorf("Must specify the fields to aggregate or at least one field to group by (?group=field1,field2)"), http.StatusBadRequest)
+			return
+		}
 
-			if f, err := filterFromRequest(req, httputil.Q(req, `q`, `all`), 0); err == nil {
-				if collection, err := backend.GetCollection(name); err == nil {
-					collection = injectRequestParamsIntoCollection(req, collection)
+		if f, err := self.filterFromRequest(req, httputil.Q(req, `q`, `all`), 0); err == nil {
+			if collection, err := backend.GetCollection(name); err == nil {
+				collection = injectRequestParamsIntoCollection(req, collection)
 
-					if aggregator := backend.WithAggregator(collection); aggregator != nil {
-						var fns = fnFieldPairsToAggs(httputil.QStrings(req, `fn`, `,`, `count`), defaultField)
+				if aggregator := backend.WithAggregator(collection); aggregator != nil {
+					if len(groups) > 0 {
+						// functions that don't name a field apply to ?field, or else to the fields in the path
+						var defaultFields = fields
 
-						if groups := httputil.QStrings(req, `group`, `,`); len(groups) > 0 {
-							if rs, err := aggregator.GroupBy(collection, groups, fns, f); err == nil {
-								httputil.RespondJSON(w, rs)
-							} else {
-								httputil.RespondJSON(w, fmt.Errorf("group failed: %v", err), http.StatusBadRequest)
-							}
+						if field := httputil.Q(req, `field`); field != `` {
+							defaultFields = []string{field}
+						}
 
+						fns, err := fnFieldPairsToAggs(httputil.QStrings(req, `fn`, `,`, `count`), defaultFields...)
+
+						if err != nil {
+							self.respond(w, req, err, http.StatusBadRequest)
 							return
+						}
+
+						if rs, err := aggregator.GroupBy(collection, groups, fns, f); err == nil {
+							self.respond(w, req, rs)
 						} else {
-							var results = make(map[string]interface{})
-
-							for _, field := range fields {
-								var fieldResults = make(map[string]interface{})
-
-								for _, aggregation := range aggregations {
-									var value interface{}
-									var err error
-
-									switch aggregation {
-									case `count`:
-										value, err = aggregator.Count(collection, f)
-									case `sum`:
-										value, err = aggregator.Sum(collection, field, f)
-									case `min`:
-										value, err = aggregator.Minimum(collection, field, f)
-									case `max`:
-										value, err = aggregator.Maximum(collection, field, f)
-									case `avg`:
-										value, err = aggregator.Average(collection, field, f)
-									default:
-										httputil.RespondJSON(w, fmt.Errorf("Unsupported aggregator '%s'", aggregation), http.StatusBadRequest)
-										return
-									}
-
-									if err != nil {
-										httputil.RespondJSON(w, err)
-										return
-									}
-
-									fieldResults[aggregation] = value
+							self.respond(w, req, fmt.Errorf("group failed: %v", err), http.StatusBadRequest)
+						}
+
+						return
+					} else {
+						var results = make(map[string]interface{})
+
+						for _, field := range fields {
+							var fieldResults = make(map[string]interface{})
+
+							for _, aggregation := range aggregations {
+								var value interface{}
+								var err error
+
+								switch aggregation {
+								case `count`:
+									value, err = aggregator.Count(collection, f)
+								case `sum`:
+									value, err = aggregator.Sum(collection, field, f)
+								case `min`:
+									value, err = aggregator.Minimum(collection, field, f)
+								case `max`:
+									value, err = aggregator.Maximum(collection, field, f)
+								case `avg`:
+									value, err = aggregator.Average(collection, field, f)
+								default:
+									self.respond(w, req, fmt.Errorf("Unsupported aggregator '%s'", aggregation), http.StatusBadRequest)
+									return
 								}
 
-								results[field] = fieldResults
+								if err != nil {
+									self.respond(w, req, err, errorStatus(err))
+									return
+								}
+
+								fieldResults[aggregation] = value
 							}
 
-							httputil.RespondJSON(w, results)
+							results[field] = fieldResults
 						}
+
+						self.respond(w, req, results)
+					}
+				} else {
+					self.respond(w, req, fmt.Errorf("Backend %T does not support aggregations.", self.backend), http.StatusBadRequest)
+				}
+			} else if dal.IsCollectionNotFoundErr(err) {
+				self.respond(w, req, err, http.StatusNotFound)
+			} else {
+				self.respond(w, req, err, errorStatus(err))
+			}
+		} else {
+			self.respond(w, req, err, http.StatusBadRequest)
+		}
+	}
+
+	router.Get(`/api/collections/:collection/aggregate`, aggregateHandler)
+	router.Get(`/api/collections/:collection/aggregate/:fields`, aggregateHandler)
+
+	router.Get(`/api/collections/:collection/counts`,
+		func(w http.ResponseWriter, req *http.Request) {
+			var name = vestigo.Param(req, `collection`)
+			var backend = backendForRequest(self, req, self.backend)
+			var fields = httputil.QStrings(req, `by`, `,`)
+
+			if len(fields) == 0 {
+				self.respond(w, req, fmt.Errorf("Must specify at least one field to count by (?by=field1,field2)"), http.StatusBadRequest)
+				return
+			}
+
+			if f, err := self.filterFromRequest(req, httputil.Q(req, `q`, `all`), 0); err == nil {
+				if collection, err := backend.GetCollection(name); err == nil {
+					collection = injectRequestParamsIntoCollection(req, collection)
+
+					if counts, err := backends.CountBy(backend, collection, fields, f); err == nil {
+						self.respond(w, req, counts)
 					} else {
-						httputil.RespondJSON(w, fmt.Errorf("Backend %T does not support aggregations.", self.backend), http.StatusBadRequest)
+						self.respond(w, req, err, http.StatusBadRequest)
 					}
 				} else if dal.IsCollectionNotFoundErr(err) {
-					httputil.RespondJSON(w, err, http.StatusNotFound)
+					self.respond(w, req, err, http.StatusNotFound)
 				} else {
-					httputil.RespondJSON(w, err)
+					self.respond(w, req, err, errorStatus(err))
 				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				self.respond(w, req, err, http.StatusBadRequest)
+			}
+		})
+
+	// rebuilds a collection's external index from the records in the backend in the background
+	router.Post(`/api/collections/:collection/reindex`,
+		func(w http.ResponseWriter, req *http.Request) {
+			var name = vestigo.Param(req, `collection`)
+			var options = backends.ReindexOptions{
+				BatchSize: int(httputil.QInt(req, `batch`)),
+			}
+
+			if rate, err := backends.ParseBackfillRate(httputil.Q(req, `rate`)); err == nil {
+				options.Rate = rate
+			} else {
+				self.respond(w, req, err, http.StatusBadRequest)
+				return
+			}
+
+			if q := httputil.Q(req, `q`); q != `` {
+				if f, err := filter.Parse(q); err == nil {
+					options.Filter = f
+				} else {
+					self.respond(w, req, err, http.StatusBadRequest)
+					return
+				}
+			}
+
+			if _, err := self.backend.GetCollection(name); dal.IsCollectionNotFoundErr(err) {
+				self.respond(w, req, err, http.StatusNotFound)
+			} else if err != nil {
+				self.respond(w, req, err, errorStatus(err))
+			} else if err := backends.CanReindex(self.backend, name); err != nil {
+				self.respond(w, req, err, http.StatusBadRequest)
+			} else if status, err := self.reindexing.start(self.backend, name, options); err == nil {
+				self.respond(w, req, &status, http.StatusAccepted)
+			} else {
+				self.respond(w, req, err, http.StatusConflict)
+			}
+		})
+
+	router.Get(`/api/collections/:collection/reindex`,
+		func(w http.ResponseWriter, req *http.Request) {
+			var name = vestigo.Param(req, `collection`)
+
+			if status, ok := self.reindexing.status(name); ok {
+				self.respond(w, req, &status)
+			} else {
+				self.respond(w, req, fmt.Errorf("Collection %q has not been reindexed", name), http.StatusNotFound)
 			}
 		})
 
@@ -377,7 +732,7 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			fieldNames := vestigo.Param(req, `_name`)
 			backend := backendForRequest(self, req, self.backend)
 
-			if f, err := filterFromRequest(req, httputil.Q(req, `q`, `all`), 0); err == nil {
+			if f, err := self.filterFromRequest(req, httputil.Q(req, `q`, `all`), 0); err == nil {
 				if collection, err := backend.GetCollection(name); err == nil {
 					collection = injectRequestParamsIntoCollection(req, collection)
 
@@ -385,20 +740,20 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 						fields := strings.TrimPrefix(fieldNames, `/`)
 
 						if recordset, err := search.ListValues(collection, strings.Split(fields, `/`), f); err == nil {
-							httputil.RespondJSON(w, recordset)
+							self.respond(w, req, recordset)
 						} else {
-							httputil.RespondJSON(w, err)
+							self.respond(w, req, err, errorStatus(err))
 						}
 					} else {
-						httputil.RespondJSON(w, fmt.Errorf("Backend %T does not support complex queries.", self.backend), http.StatusBadRequest)
+						self.respond(w, req, fmt.Errorf("Backend %T does not support complex queries.", self.backend), http.StatusBadRequest)
 					}
 				} else if dal.IsCollectionNotFoundErr(err) {
-					httputil.RespondJSON(w, err, http.StatusNotFound)
+					self.respond(w, req, err, http.StatusNotFound)
 				} else {
-					httputil.RespondJSON(w, err)
+					self.respond(w, req, err, errorStatus(err))
 				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				self.respond(w, req, err, http.StatusBadRequest)
 			}
 		})
 
@@ -409,24 +764,89 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			backend := backendForRequest(self, req, self.backend)
 
 			if collection, err := backend.GetCollection(name); err == nil {
-				if search := backend.WithSearch(collection); search != nil {
-					if f, err := filter.Parse(query); err == nil {
-						if err := search.DeleteQuery(collection, f); err == nil {
-							httputil.RespondJSON(w, nil)
-						} else {
-							httputil.RespondJSON(w, fmt.Errorf("delete error: %v", err), http.StatusBadRequest)
-						}
+				if f, err := filter.Parse(query); err == nil {
+					if err := self.applyFilterHooks(req, f); err != nil {
+						self.respond(w, req, fmt.Errorf("filter error: %v", err), http.StatusBadRequest)
+					} else if err := backends.DeleteQuery(backend, collection, f); err == nil {
+						self.respond(w, req, nil)
+					} else {
+						self.respond(w, req, fmt.Errorf("delete error: %v", err), http.StatusBadRequest)
+					}
+				} else {
+					self.respond(w, req, fmt.Errorf("filter error: %v", err), http.StatusBadRequest)
+				}
+			} else {
+				self.respond(w, req, fmt.Errorf("collection error: %v", err), http.StatusBadRequest)
+			}
+		})
+
+	router.Put(`/api/collections/:collection/where/*urlquery`,
+		func(w http.ResponseWriter, req *http.Request) {
+			var record dal.Record
+
+			name := vestigo.Param(req, `collection`)
+			query := vestigo.Param(req, `_name`)
+			backend := backendForRequest(self, req, self.backend)
+
+			if err := httputil.ParseRequest(req, &record); err != nil {
+				self.respond(w, req, err, requestBodyStatus(err))
+			} else if collection, err := backend.GetCollection(name); err == nil {
+				if f, err := filter.Parse(query); err == nil {
+					if err := self.applyFilterHooks(req, f); err != nil {
+						self.respond(w, req, fmt.Errorf("filter error: %v", err), http.StatusBadRequest)
+					} else if updated, err := backends.UpdateQuery(backend, collection, f, &record); err == nil {
+						self.respond(w, req, map[string]interface{}{
+							`updated`: updated,
+						})
 					} else {
-						httputil.RespondJSON(w, fmt.Errorf("filter error: %v", err), http.StatusBadRequest)
+						self.respond(w, req, fmt.Errorf("update error: %v", err), errorStatus(err))
 					}
 				} else {
-					httputil.RespondJSON(w, fmt.Errorf("index error: backend does not support querying"), http.StatusBadRequest)
+					self.respond(w, req, fmt.Errorf("filter error: %v", err), http.StatusBadRequest)
 				}
+			} else if dal.IsCollectionNotFoundErr(err) {
+				self.respond(w, req, err, http.StatusNotFound)
 			} else {
-				httputil.RespondJSON(w, fmt.Errorf("collection error: %v", err), http.StatusBadRequest)
+				self.respond(w, req, fmt.Errorf("collection error: %v", err), http.StatusBadRequest)
 			}
 		})
 
+	router.Get(`/api/collections/:collection/changes`,
+		func(w http.ResponseWriter, req *http.Request) {
+			var name = vestigo.Param(req, `collection`)
+			var types = httputil.QStrings(req, `types`, `,`)
+			var f *filter.Filter
+
+			if _, err := self.backend.GetCollection(name); dal.IsCollectionNotFoundErr(err) {
+				self.respond(w, req, err, http.StatusNotFound)
+				return
+			} else if err != nil {
+				self.respond(w, req, err, errorStatus(err))
+				return
+			}
+
+			if v := httputil.Q(req, `q`); v != `` {
+				if flt, err := filter.Parse(v); err == nil {
+					f = flt
+				} else {
+					self.respond(w, req, fmt.Errorf("filter error: %v", err), http.StatusBadRequest)
+					return
+				}
+			} else if len(self.filterHooks) > 0 {
+				// give filter hooks the chance to restrict which changes are seen
+				f = filter.All()
+			}
+
+			if f != nil {
+				if err := self.applyFilterHooks(req, f); err != nil {
+					self.respond(w, req, fmt.Errorf("filter error: %v", err), http.StatusBadRequest)
+					return
+				}
+			}
+
+			self.streamChanges(w, req, name, types, f)
+		})
+
 	// Record CRUD
 	// ---------------------------------------------------------------------------------------------
 
@@ -441,7 +861,7 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 					if diffused, err := maputil.DiffuseMap(record.Fields, dchar); err == nil {
 						record.Fields = diffused
 					} else {
-						httputil.RespondJSON(w, err, http.StatusBadRequest)
+						self.respond(w, req, err, http.StatusBadRequest)
 						return
 					}
 				}
@@ -452,7 +872,13 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 
 			var err error
 
-			if req.Method == `PUT` || httputil.QBool(req, `update`) {
+			if keyFields := httputil.QStrings(req, `key`, `,`); len(keyFields) > 0 {
+				var result *backends.UpsertResult
+
+				if result, err = backends.UpsertByKey(backend, name, keyFields, &recordset); err == nil && result.Inserted > 0 {
+					status = http.StatusCreated
+				}
+			} else if req.Method == `PUT` || httputil.QBool(req, `update`) {
 				err = backend.Update(name, &recordset)
 			} else {
 				err = backend.Insert(name, &recordset)
@@ -463,13 +889,23 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 				if redirect := httputil.Q(req, `redirect`); strings.HasPrefix(redirect, `/`) {
 					http.Redirect(w, req, redirect, http.StatusTemporaryRedirect)
 				} else {
-					httputil.RespondJSON(w, recordset, status)
+					if collection, err := backend.GetCollection(name); err == nil {
+						self.embedLinks(req, collection, recordset.Records...)
+					}
+
+					self.respond(w, req, recordset, status)
 				}
+			} else if verr, ok := err.(*dal.SchemaValidationError); ok {
+				self.respond(w, req, map[string]interface{}{
+					`error`:      verr.Error(),
+					`field`:      verr.Field,
+					`violations`: verr.Violations,
+				}, http.StatusBadRequest)
 			} else {
-				httputil.RespondJSON(w, err)
+				self.respond(w, req, err, errorStatus(err))
 			}
 		} else {
-			httputil.RespondJSON(w, err, http.StatusBadRequest)
+			self.respond(w, req, err, requestBodyStatus(err))
 		}
 	}
 
@@ -495,11 +931,46 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			}
 
 			if record, err := backend.Retrieve(name, id, fields...); err == nil {
-				httputil.RespondJSON(w, record)
+				if collection, err := backend.GetCollection(name); err == nil {
+					if self.applyCachePolicy(w, req, collection, record) {
+						return
+					}
+
+					self.embedLinks(req, collection, record)
+				}
+
+				self.respond(w, req, record)
 			} else if strings.HasSuffix(err.Error(), `does not exist`) {
-				httputil.RespondJSON(w, err, http.StatusNotFound)
+				self.respond(w, req, err, http.StatusNotFound)
+			} else {
+				self.respond(w, req, err, errorStatus(err))
+			}
+		})
+
+	// records in another collection that belong to this one (e.g.: a customer's orders), queried by
+	// the constraint that relates them rather than a filter on the foreign key
+	router.Get(`/api/collections/:collection/records/:id/related/:related`,
+		func(w http.ResponseWriter, req *http.Request) {
+			name := vestigo.Param(req, `collection`)
+			related := vestigo.Param(req, `related`)
+			backend := backendForRequest(self, req, self.backend)
+
+			if f, err := self.filterFromRequest(req, httputil.Q(req, `q`, `all`), int64(DefaultResultLimit)); err == nil {
+				if recordset, err := backends.QueryRelated(backend, name, vestigo.Param(req, `id`), related, httputil.Q(req, `via`), f); err == nil {
+					if collection, err := backend.GetCollection(related); err == nil {
+						self.embedLinks(req, collection, recordset.Records...)
+					}
+
+					self.respond(w, req, recordset)
+				} else if _, ok := err.(backends.AmbiguousRelationshipError); ok {
+					self.respond(w, req, err, http.StatusBadRequest)
+				} else if dal.IsCollectionNotFoundErr(err) || dal.IsNotExistError(err) {
+					self.respond(w, req, err, http.StatusNotFound)
+				} else {
+					self.respond(w, req, err, errorStatus(err))
+				}
 			} else {
-				httputil.RespondJSON(w, err)
+				self.respond(w, req, err, http.StatusBadRequest)
 			}
 		})
 
@@ -521,12 +992,16 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 				}
 
 				if err == nil {
-					httputil.RespondJSON(w, &record)
+					if collection, err := backend.GetCollection(name); err == nil {
+						self.embedLinks(req, collection, &record)
+					}
+
+					self.respond(w, req, &record)
 				} else {
-					httputil.RespondJSON(w, err)
+					self.respond(w, req, err, errorStatus(err))
 				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				self.respond(w, req, err, requestBodyStatus(err))
 			}
 		})
 
@@ -544,9 +1019,9 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			}
 
 			if err := backend.Delete(name, id); err == nil {
-				httputil.RespondJSON(w, nil)
+				self.respond(w, req, nil)
 			} else {
-				httputil.RespondJSON(w, err)
+				self.respond(w, req, err, errorStatus(err))
 			}
 		})
 
@@ -560,9 +1035,9 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			if collection, err := backend.GetCollection(name); err == nil {
 				collection = injectRequestParamsIntoCollection(req, collection)
 
-				httputil.RespondJSON(w, collection)
+				self.respond(w, req, collection)
 			} else {
-				httputil.RespondJSON(w, err, http.StatusNotFound)
+				self.respond(w, req, err, http.StatusNotFound)
 			}
 		})
 
@@ -575,12 +1050,12 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 
 			if err := httputil.ParseRequest(req, &recordset); err == nil {
 				if err := backend.Insert(name, &recordset); err == nil {
-					httputil.RespondJSON(w, nil)
+					self.respond(w, req, nil)
 				} else {
-					httputil.RespondJSON(w, err)
+					self.respond(w, req, err, errorStatus(err))
 				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				self.respond(w, req, err, http.StatusBadRequest)
 			}
 		})
 
@@ -593,12 +1068,12 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 
 			if err := httputil.ParseRequest(req, &recordset); err == nil {
 				if err := backend.Update(name, &recordset); err == nil {
-					httputil.RespondJSON(w, nil)
+					self.respond(w, req, nil)
 				} else {
-					httputil.RespondJSON(w, err)
+					self.respond(w, req, err, errorStatus(err))
 				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				self.respond(w, req, err, http.StatusBadRequest)
 			}
 		})
 
@@ -607,9 +1082,9 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			backend := backendForRequest(self, req, self.backend)
 
 			if names, err := backend.ListCollections(); err == nil {
-				httputil.RespondJSON(w, names)
+				self.respond(w, req, names)
 			} else {
-				httputil.RespondJSON(w, err)
+				self.respond(w, req, err, errorStatus(err))
 			}
 		})
 
@@ -626,14 +1101,14 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 					collections = append(collections, collection)
 				} else if strings.Contains(err.Error(), `cannot unmarshal array `) {
 					if err := json.Unmarshal(body, &collections); err != nil {
-						httputil.RespondJSON(w, err, http.StatusBadRequest)
+						self.respond(w, req, err, http.StatusBadRequest)
 						return
 					}
 				} else {
-					httputil.RespondJSON(w, err, http.StatusBadRequest)
+					self.respond(w, req, err, http.StatusBadRequest)
 				}
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				self.respond(w, req, err, http.StatusBadRequest)
 				return
 			}
 
@@ -641,13 +1116,13 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 
 			for _, collection := range collections {
 				if err := backend.CreateCollection(&collection); err == nil {
-					httputil.RespondJSON(w, collection, http.StatusCreated)
+					self.respond(w, req, collection, http.StatusCreated)
 
 				} else if len(collections) == 1 {
 					if dal.IsExistError(err) {
-						httputil.RespondJSON(w, err, http.StatusConflict)
+						self.respond(w, req, err, http.StatusConflict)
 					} else {
-						httputil.RespondJSON(w, err)
+						self.respond(w, req, err, errorStatus(err))
 					}
 
 					return
@@ -657,7 +1132,7 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			}
 
 			if len(errors) > 0 {
-				httputil.RespondJSON(w, errors, http.StatusBadRequest)
+				self.respond(w, req, errors, http.StatusBadRequest)
 			}
 		})
 
@@ -669,9 +1144,44 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			if collection, err := backend.GetCollection(name); err == nil {
 				collection = injectRequestParamsIntoCollection(req, collection)
 
-				httputil.RespondJSON(w, collection)
+				self.respond(w, req, collection)
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				self.respond(w, req, err, http.StatusBadRequest)
+			}
+		})
+
+	router.Get(`/api/schema/:collection/fields/:field/schema`,
+		func(w http.ResponseWriter, req *http.Request) {
+			name := vestigo.Param(req, `collection`)
+			backend := backendForRequest(self, req, self.backend)
+
+			if collection, err := backend.GetCollection(name); err == nil {
+				if field, ok := collection.GetField(vestigo.Param(req, `field`)); ok {
+					if len(field.Schema) > 0 {
+						var schema = field.Schema
+
+						// flag deprecated fields using the JSON Schema keyword for it
+						if field.Deprecated {
+							schema = make(map[string]interface{})
+
+							for k, v := range field.Schema {
+								schema[k] = v
+							}
+
+							schema[`deprecated`] = true
+						}
+
+						self.respond(w, req, schema)
+					} else {
+						self.respond(w, req, fmt.Errorf("field %q does not have a schema", field.Name), http.StatusNotFound)
+					}
+				} else {
+					self.respond(w, req, dal.FieldNotFound, http.StatusNotFound)
+				}
+			} else if dal.IsCollectionNotFoundErr(err) {
+				self.respond(w, req, err, http.StatusNotFound)
+			} else {
+				self.respond(w, req, err, http.StatusBadRequest)
 			}
 		})
 
@@ -681,15 +1191,348 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			backend := backendForRequest(self, req, self.backend)
 
 			if err := backend.DeleteCollection(name); err == nil {
-				httputil.RespondJSON(w, nil)
+				self.respond(w, req, nil)
 			} else {
-				httputil.RespondJSON(w, err, http.StatusBadRequest)
+				self.respond(w, req, err, http.StatusBadRequest)
 			}
 		})
 
+	// GraphQL
+	// ---------------------------------------------------------------------------------------------
+	if self.GraphQL {
+		router.Post(`/api/graphql`, self.graphqlHandler)
+	}
+
+	// Administrative Operations
+	// ---------------------------------------------------------------------------------------------
+	integrityHandler := func(w http.ResponseWriter, req *http.Request) {
+		var mode backends.IntegrityRepairMode
+
+		// repairs modify data, so they are only performed on POST
+		if req.Method == `POST` {
+			if m, err := backends.ParseIntegrityRepairMode(httputil.Q(req, `repair`)); err == nil {
+				mode = m
+			} else {
+				self.respond(w, req, err, http.StatusBadRequest)
+				return
+			}
+		}
+
+		if report, err := backends.CheckIntegrity(
+			self.backend,
+			mode,
+			httputil.QStrings(req, `collections`, `,`)...,
+		); err == nil {
+			self.respond(w, req, report)
+		} else {
+			self.respond(w, req, err, errorStatus(err))
+		}
+	}
+
+	router.Get(`/api/admin/integrity`, integrityHandler)
+	router.Post(`/api/admin/integrity`, integrityHandler)
+
+	router.Get(`/api/admin/usage`,
+		func(w http.ResponseWriter, req *http.Request) {
+			if tracker := self.usageTracker(); tracker != nil {
+				self.respond(w, req, tracker.Usage())
+			} else {
+				self.respond(w, req, fmt.Errorf("Usage tracking is not enabled"), http.StatusNotFound)
+			}
+		})
+
+	router.Get(`/api/admin/usage/sizes`,
+		func(w http.ResponseWriter, req *http.Request) {
+			if tracker := self.usageTracker(); tracker != nil {
+				self.respond(w, req, tracker.RecordSizes())
+			} else {
+				self.respond(w, req, fmt.Errorf("Usage tracking is not enabled"), http.StatusNotFound)
+			}
+		})
+
+	router.Get(`/api/admin/mirror`,
+		func(w http.ResponseWriter, req *http.Request) {
+			if mirror := self.mirroringBackend(); mirror != nil {
+				self.respond(w, req, mirror.Stats())
+			} else {
+				self.respond(w, req, fmt.Errorf("Request mirroring is not enabled"), http.StatusNotFound)
+			}
+		})
+
+	router.Get(`/api/admin/stats`,
+		func(w http.ResponseWriter, req *http.Request) {
+			if db, ok := self.backend.(DB); ok && db.Stats() != nil {
+				self.respond(w, req, db.Stats())
+			} else {
+				self.respond(w, req, fmt.Errorf("Stats refreshing is not enabled"), http.StatusNotFound)
+			}
+		})
+
+	router.Get(`/api/admin/deprecations`,
+		func(w http.ResponseWriter, req *http.Request) {
+			if tracker := self.usageTracker(); tracker != nil {
+				if usage, err := tracker.DeprecatedFieldUsage(); err == nil {
+					self.respond(w, req, usage)
+				} else {
+					self.respond(w, req, err, errorStatus(err))
+				}
+			} else {
+				self.respond(w, req, fmt.Errorf("Usage tracking is not enabled"), http.StatusNotFound)
+			}
+		})
+
+	// Prometheus-style metrics
+	router.Get(`/api/metrics`,
+		func(w http.ResponseWriter, req *http.Request) {
+			if tracker := self.usageTracker(); tracker != nil {
+				w.Header().Set(`Content-Type`, `text/plain; version=0.0.4`)
+				writeRecordSizeMetrics(w, tracker.RecordSizes())
+
+				if usage, err := tracker.DeprecatedFieldUsage(); err == nil {
+					writeDeprecatedFieldMetrics(w, usage)
+				} else {
+					log.Warningf("failed to report deprecated field usage: %v", err)
+				}
+			} else {
+				self.respond(w, req, fmt.Errorf("Usage tracking is not enabled"), http.StatusNotFound)
+			}
+		})
+
+	router.Post(`/api/admin/usage`,
+		func(w http.ResponseWriter, req *http.Request) {
+			if tracker := self.usageTracker(); tracker != nil {
+				if err := tracker.PersistUsage(); err == nil {
+					self.respond(w, req, tracker.Usage())
+				} else {
+					self.respond(w, req, err, errorStatus(err))
+				}
+			} else {
+				self.respond(w, req, fmt.Errorf("Usage tracking is not enabled"), http.StatusNotFound)
+			}
+		})
+
+	return nil
+}
+
+// Writes the results of a query to the response in the given format (e.g.: "csv", "parquet") as
+// they are retrieved from the backend, rather than accumulating them into a RecordSet first.
+func (self *Server) streamRecords(w http.ResponseWriter, req *http.Request, format string, search backends.Indexer, collection *dal.Collection, f *filter.Filter) {
+	var contentType string
+
+	switch format {
+	case `csv`:
+		contentType = `text/csv`
+	case `parquet`:
+		contentType = `application/vnd.apache.parquet`
+	default:
+		self.respond(w, req, fmt.Errorf("Unsupported format %q", format), http.StatusBadRequest)
+		return
+	}
+
+	writer, err := dal.NewRecordWriter(format, w, collection, f.Fields...)
+
+	if err != nil {
+		self.respond(w, req, err, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set(`Content-Type`, contentType)
+	w.Header().Set(`Content-Disposition`, fmt.Sprintf("attachment; filename=%q", collection.Name+`.`+format))
+	w.WriteHeader(http.StatusOK)
+
+	// the response has already started, so errors past this point can only be logged
+	err = search.QueryFunc(collection, f, func(record *dal.Record, err error, _ backends.IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		return writer.Write(record)
+	})
+
+	if err == nil {
+		err = writer.Close()
+	}
+
+	if err != nil {
+		log.Warningf("[%v] %s export failed: %v", collection.Name, format, err)
+	}
+}
+
+// Writes the results of a query to the response as a JSON RecordSet as they are retrieved from the
+// backend, so that large responses don't need to be held in memory.  The records array is written
+// first, followed by the result count and page details.
+func (self *Server) streamRecordSet(w http.ResponseWriter, req *http.Request, search backends.Indexer, collection *dal.Collection, f *filter.Filter) {
+	format, err := OutputFormatFromRequest(req)
+
+	if err != nil {
+		self.respond(w, req, err, http.StatusBadRequest)
+		return
+	}
+
+	var encoder = dal.NewRecordSetEncoder(w)
+	var envelope = dal.NewRecordSet()
+	var written int64
+
+	w.Header().Set(`Content-Type`, `application/json`)
+	w.WriteHeader(http.StatusOK)
+
+	// the response has already started, so errors past this point are reported at the end of it
+	err = search.QueryFunc(collection, f, func(record *dal.Record, err error, page backends.IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		backends.PopulateRecordSetPageDetails(envelope, f, page)
+		self.embedLinks(req, collection, record)
+
+		if format != nil {
+			record = format.formatRecord(record)
+		}
+
+		written += 1
+		return encoder.Encode(record)
+	})
+
+	if !envelope.KnownSize {
+		envelope.ResultCount = written
+	}
+
+	if err != nil {
+		log.Warningf("[%v] streamed query failed: %v", collection.Name, err)
+	}
+
+	if err := encoder.Finish(envelope, err); err != nil {
+		log.Warningf("[%v] failed to write response: %v", collection.Name, err)
+	}
+}
+
+// Streamed responses can't be passed through response hooks or checked against a cache policy,
+// since both need the whole RecordSet; queries that need them are answered normally instead.
+func (self *Server) canStreamRecordSet(collection *dal.Collection) bool {
+	return len(self.responseHooks) == 0 && collection.Cache == nil
+}
+
+// Streams change events for the named collection to the client as Server-Sent Events until the
+// client disconnects.  If given, only events of the given types and events whose records match
+// the filter are sent.
+func (self *Server) streamChanges(w http.ResponseWriter, req *http.Request, name string, types []string, f *filter.Filter) {
+	var db, ok = self.backend.(DB)
+
+	if !ok {
+		self.respond(w, req, fmt.Errorf("Backend %T does not support watching for changes", self.backend), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+
+	if !ok {
+		self.respond(w, req, fmt.Errorf("Streaming responses are not supported"), http.StatusInternalServerError)
+		return
+	}
+
+	var events = make(chan dal.ChangeEvent, ChangeStreamBufferSize)
+	var lagging = make(chan bool)
+	var lagOnce sync.Once
+
+	unwatch := db.Watch(name, func(event dal.ChangeEvent) {
+		if len(types) > 0 && !sliceutil.ContainsString(types, string(event.Type)) {
+			return
+		}
+
+		if f != nil && (event.Record == nil || !f.MatchesRecord(event.Record)) {
+			return
+		}
+
+		// never block writers on slow clients
+		select {
+		case events <- event:
+		default:
+			lagOnce.Do(func() {
+				close(lagging)
+			})
+		}
+	})
+
+	defer unwatch()
+
+	w.Header().Set(`Content-Type`, `text/event-stream`)
+	w.Header().Set(`Cache-Control`, `no-cache`)
+	w.Header().Set(`Connection`, `keep-alive`)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var keepalive = time.NewTicker(ChangeStreamKeepaliveInterval)
+	var seq int64
+
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+
+		case <-lagging:
+			fmt.Fprintf(w, "event: overflow\ndata: {\"error\":\"client fell too far behind\"}\n\n")
+			flusher.Flush()
+			return
+
+		case <-keepalive.C:
+			fmt.Fprintf(w, ": keepalive\n\n")
+			flusher.Flush()
+
+		case event := <-events:
+			if data, err := json.Marshal(event); err == nil {
+				seq += 1
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", seq, event.Type, data)
+				flusher.Flush()
+			} else {
+				log.Warningf("failed to encode change event: %v", err)
+			}
+		}
+	}
+}
+
+// Returns the usage tracker wrapping the server's backend, or nil if usage tracking is not enabled.
+func (self *Server) usageTracker() *backends.UsageTrackingBackend {
+	// the tracker may be wrapped by other backends (e.g.: the change watcher the server adds)
+	for _, backend := range backends.BackendChain(self.backend) {
+		if tracker, ok := backend.(*backends.UsageTrackingBackend); ok {
+			return tracker
+		}
+	}
+
 	return nil
 }
 
+// Returns the backend mirroring the server's requests, or nil if mirroring is not enabled.
+func (self *Server) mirroringBackend() *backends.MirroringBackend {
+	for _, backend := range backends.BackendChain(self.backend) {
+		if mirror, ok := backend.(*backends.MirroringBackend); ok {
+			return mirror
+		}
+	}
+
+	return nil
+}
+
+// Adds hypermedia links (self, collection, and related records) to the given records if the server
+// is configured to do so or the request asks for them with ?links=true.
+func (self *Server) embedLinks(req *http.Request, collection *dal.Collection, records ...*dal.Record) {
+	enabled := self.EmbedLinks
+
+	if v := httputil.Q(req, `links`); v != `` {
+		enabled = typeutil.Bool(v)
+	}
+
+	if enabled {
+		for _, record := range records {
+			if record != nil {
+				record.Links = dal.RecordLinks(collection, record)
+			}
+		}
+	}
+}
+
 func injectRequestParamsIntoCollection(req *http.Request, collection *dal.Collection) *dal.Collection {
 	// shallow copy the collection so we can screw with it
 	c := *collection
@@ -744,6 +1587,27 @@ func filterFromRequest(req *http.Request, filterIn interface{}, defaultLimit int
 	f.Limit = limit
 	f.Offset = offset
 
+	// keyset pagination: return records whose ID comes after the given one
+	if v := httputil.Q(req, `after`); v != `` {
+		f.After = stringutil.Autotype(v)
+	}
+
+	if v := httputil.Q(req, `consistency`); v != `` {
+		if level, err := filter.ParseConsistencyLevel(v); err == nil {
+			f.Consistency = level
+		} else {
+			return nil, err
+		}
+	}
+
+	if v := httputil.Q(req, `totals`); v != `` {
+		if mode, err := filter.ParseTotalsMode(v); err == nil {
+			f.Totals = mode
+		} else {
+			return nil, err
+		}
+	}
+
 	if v := httputil.Q(req, `sort`); v != `` {
 		f.Sort = strings.Split(v, `,`)
 	}
@@ -766,10 +1630,22 @@ func filterFromRequest(req *http.Request, filterIn interface{}, defaultLimit int
 	return f, nil
 }
 
+// returns the HTTP status code that best describes the given error
+func errorStatus(err error) int {
+	if backends.IsTimeoutError(err) {
+		return http.StatusGatewayTimeout
+	} else if dal.IsValidationErr(err) {
+		return http.StatusUnprocessableEntity
+	}
+
+	return http.StatusInternalServerError
+}
+
 func backendForRequest(server *Server, req *http.Request, backend Backend) Backend {
 	nx := httputil.Q(req, `noexpand`)
 	skipKeys := make([]string, 0)
-	useEmbeddedBackend := server.Autoexpand
+	expand := sliceutil.CompactString(strings.Split(httputil.Q(req, `expand`), `,`))
+	useEmbeddedBackend := server.Autoexpand || len(expand) > 0
 
 	// if the ?noexpand querystring was provided in some form...
 	if nx != `` {
@@ -786,24 +1662,37 @@ func backendForRequest(server *Server, req *http.Request, backend Backend) Backe
 		}
 	}
 
+	if server.Tracing {
+		backend = backends.NewTracingBackend(backend, req.Context())
+	}
+
 	if useEmbeddedBackend {
-		backend = backends.NewEmbeddedRecordBackend(backend, skipKeys...)
+		embedded := backends.NewEmbeddedRecordBackend(backend, skipKeys...)
+
+		// ?expand=a,b.c means "only expand these relationships (and these nested within them)"
+		embedded.Expand = expand
+
+		if server.MaxEmbeddedRecords > 0 {
+			embedded.MaxEmbeddedRecords = server.MaxEmbeddedRecords
+		}
+
+		if server.MaxEmbeddedBytes > 0 {
+			embedded.MaxEmbeddedBytes = server.MaxEmbeddedBytes
+		}
+
+		backend = embedded
 	}
 
 	return backend
 }
 
-func fnFieldPairsToAggs(pairs []string, defaultField string) (aggs []filter.Aggregate) {
+// parses "fn:field" pairs into aggregates; functions that don't name a field are performed on each
+// of the default fields instead
+func fnFieldPairsToAggs(pairs []string, defaultFields ...string) (aggs []filter.Aggregate, err error) {
 	for _, pair := range pairs {
 		fn, field := stringutil.SplitPair(pair, `:`)
 
-		agg := filter.Aggregate{
-			Field: field,
-		}
-
-		if agg.Field == `` {
-			agg.Field = defaultField
-		}
+		var agg filter.Aggregate
 
 		switch fn {
 		case `sum`:
@@ -820,9 +1709,19 @@ func fnFieldPairsToAggs(pairs []string, defaultField string) (aggs []filter.Aggr
 			agg.Aggregation = filter.Maximum
 		case `count`, ``:
 			agg.Aggregation = filter.Count
+		default:
+			return nil, fmt.Errorf("Unsupported aggregator '%s'", fn)
 		}
 
-		aggs = append(aggs, agg)
+		if field != `` || len(defaultFields) == 0 {
+			agg.Field = field
+			aggs = append(aggs, agg)
+		} else {
+			for _, defaultField := range defaultFields {
+				agg.Field = defaultField
+				aggs = append(aggs, agg)
+			}
+		}
 	}
 
 	return
@@ -0,0 +1,256 @@
+package pivot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerRegisterRoutes(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-server-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	server := NewServer(`fs://` + root + `/`)
+	server.UiDirectory = ``
+
+	var seen int
+
+	server.UseMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			seen += 1
+			next.ServeHTTP(w, req)
+		})
+	})
+
+	// mount the API on an application's own mux
+	mux := http.NewServeMux()
+	mux.HandleFunc(`/hello`, func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`hi`))
+	})
+
+	assert.NoError(server.RegisterRoutes(mux))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(`GET`, `/api/status`, nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(1, seen)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(`GET`, `/hello`, nil))
+	assert.Equal(`hi`, w.Body.String())
+	assert.Equal(1, seen)
+
+	// mount the whole server under a subpath
+	mux = http.NewServeMux()
+	mux.Handle(`/pivot/`, http.StripPrefix(`/pivot`, server.Handler()))
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(`GET`, `/pivot/api/collections`, nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(2, seen)
+
+	// requests outside the API aren't served when the UI is disabled
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(`GET`, `/pivot/index.html`, nil))
+	assert.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestServerIntegrity(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-server-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	server := NewServer(`fs://` + root + `/`)
+	server.UiDirectory = ``
+
+	handler := server.Handler()
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+	server.backend = backend
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`authors`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`books`, dal.Field{
+		Name:      `author_id`,
+		Type:      dal.IntType,
+		BelongsTo: `authors`,
+	})))
+
+	assert.NoError(backend.Insert(`authors`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `Alice`),
+	)))
+
+	assert.NoError(backend.Insert(`books`, dal.NewRecordSet(
+		dal.NewRecord(10).Set(`author_id`, 1),
+		dal.NewRecord(11).Set(`author_id`, 2),
+	)))
+
+	check := func(method string, url string, code int) *backends.IntegrityReport {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, url, nil))
+		assert.Equal(code, w.Code, w.Body.String())
+
+		var report backends.IntegrityReport
+		assert.NoError(json.Unmarshal(w.Body.Bytes(), &report))
+
+		return &report
+	}
+
+	// GET only ever reports, even when asked to repair
+	report := check(`GET`, `/api/admin/integrity?collections=books&repair=delete`, http.StatusOK)
+	assert.Equal([]string{`books`}, report.Collections)
+	assert.Len(report.Orphans, 1)
+	assert.False(report.Orphans[0].Repaired)
+	assert.True(backend.Exists(`books`, 11))
+
+	check(`POST`, `/api/admin/integrity?repair=explode`, http.StatusBadRequest)
+	assert.True(backend.Exists(`books`, 11))
+
+	report = check(`POST`, `/api/admin/integrity?repair=delete`, http.StatusOK)
+	assert.Equal(backends.IntegrityDelete, report.Mode)
+	assert.Len(report.Orphans, 1)
+	assert.True(report.Orphans[0].Repaired)
+	assert.False(backend.Exists(`books`, 11))
+	assert.True(backend.Exists(`books`, 10))
+}
+
+// an aggregator that remembers which groupings it was asked for
+type testAggregator struct {
+	backends.Aggregator
+	groups     []string
+	aggregates []filter.Aggregate
+}
+
+func (self *testAggregator) Sum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return 42, nil
+}
+
+func (self *testAggregator) GroupBy(collection *dal.Collection, fields []string, aggregates []filter.Aggregate, f ...*filter.Filter) (*dal.RecordSet, error) {
+	self.groups = fields
+	self.aggregates = aggregates
+
+	return dal.NewRecordSet(), nil
+}
+
+type testAggregatingBackend struct {
+	*spi.Adapter
+	aggregator *testAggregator
+}
+
+func (self *testAggregatingBackend) WithAggregator(collection *dal.Collection) backends.Aggregator {
+	return self.aggregator
+}
+
+func TestServerAggregate(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-server-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	server := NewServer(`fs://` + root + `/`)
+	server.UiDirectory = ``
+
+	handler := server.Handler()
+	aggregator := &testAggregator{}
+	backend := &testAggregatingBackend{
+		Adapter:    spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver()),
+		aggregator: aggregator,
+	}
+
+	server.backend = backend
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`orders`, dal.Field{
+		Name: `region`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `total`,
+		Type: dal.FloatType,
+	}, dal.Field{
+		Name: `tax`,
+		Type: dal.FloatType,
+	})))
+
+	get := func(url string, code int) []byte {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(`GET`, url, nil))
+		assert.Equal(code, w.Code, w.Body.String())
+
+		return w.Body.Bytes()
+	}
+
+	get(`/api/collections/orders/aggregate`, http.StatusBadRequest)
+
+	var results map[string]map[string]float64
+
+	assert.NoError(json.Unmarshal(get(`/api/collections/orders/aggregate/total?fn=sum`, http.StatusOK), &results))
+	assert.Equal(map[string]map[string]float64{
+		`total`: {`sum`: 42},
+	}, results)
+
+	// functions that don't name a field are performed on each of the fields in the path
+	get(`/api/collections/orders/aggregate/total,tax?group=region&fn=sum,count:id`, http.StatusOK)
+	assert.Equal([]string{`region`}, aggregator.groups)
+	assert.Equal([]filter.Aggregate{
+		{Aggregation: filter.Sum, Field: `total`},
+		{Aggregation: filter.Sum, Field: `tax`},
+		{Aggregation: filter.Count, Field: `id`},
+	}, aggregator.aggregates)
+
+	// ...unless a field is given explicitly
+	get(`/api/collections/orders/aggregate/total?group=region&fn=sum&field=tax`, http.StatusOK)
+	assert.Equal([]filter.Aggregate{
+		{Aggregation: filter.Sum, Field: `tax`},
+	}, aggregator.aggregates)
+
+	get(`/api/collections/orders/aggregate?group=region`, http.StatusOK)
+	assert.Equal([]filter.Aggregate{
+		{Aggregation: filter.Count},
+	}, aggregator.aggregates)
+
+	get(`/api/collections/orders/aggregate?group=region&fn=explode`, http.StatusBadRequest)
+}
+
+func TestServerWithoutUi(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-server-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	server := NewServer(`fs://` + root + `/`)
+	server.UiDirectory = ``
+
+	handler := server.Handler()
+
+	// only the API is served...
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(`GET`, `/api/status`, nil))
+	assert.Equal(http.StatusOK, w.Code)
+
+	// ...and everything else is not found
+	for _, path := range []string{`/`, `/index.html`, `/collections/things`} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(`GET`, path, nil))
+		assert.Equal(http.StatusNotFound, w.Code, path)
+		assert.Contains(w.Header().Get(`Content-Type`), `application/json`)
+	}
+}
@@ -0,0 +1,146 @@
+package pivot
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/ghetzel/go-stockutil/httputil"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/husobee/vestigo"
+)
+
+// An in-progress (or just completed) query whose result is shared by every request that asked
+// for the same thing while it was running.
+type queryFlight struct {
+	done      sync.WaitGroup
+	recordset *dal.RecordSet
+	err       error
+	waiters   int
+}
+
+// Coalesces identical concurrent queries so that only one of them is executed against the
+// backend, with the others waiting for and receiving a copy of its result.  Nothing is cached
+// once a query completes; a request that arrives afterward executes the query again.
+type queryGroup struct {
+	sync.Mutex
+	flights map[string]*queryFlight
+}
+
+// Executes fn unless an identical query (identified by key) is already running, in which case
+// this waits for that query and returns its result instead.  The second return value is true
+// if the result came from another request's execution.
+func (self *queryGroup) Do(key string, fn func() (*dal.RecordSet, error)) (*dal.RecordSet, bool, error) {
+	self.Lock()
+
+	if self.flights == nil {
+		self.flights = make(map[string]*queryFlight)
+	}
+
+	if flight, ok := self.flights[key]; ok {
+		flight.waiters += 1
+		self.Unlock()
+		flight.done.Wait()
+
+		return copyRecordSet(flight.recordset), true, flight.err
+	}
+
+	var flight = new(queryFlight)
+
+	flight.done.Add(1)
+	self.flights[key] = flight
+	self.Unlock()
+
+	var recordset *dal.RecordSet
+
+	func() {
+		defer func() {
+			self.Lock()
+			delete(self.flights, key)
+			self.Unlock()
+
+			// once removed from the map nobody else can join this flight, so if anyone already
+			// has, take a private copy before letting them read the original
+			if flight.waiters > 0 {
+				recordset = copyRecordSet(flight.recordset)
+			} else {
+				recordset = flight.recordset
+			}
+
+			flight.done.Done()
+		}()
+
+		flight.recordset, flight.err = fn()
+	}()
+
+	return recordset, false, flight.err
+}
+
+// Returns the number of distinct queries currently executing.
+func (self *queryGroup) Len() int {
+	self.Lock()
+	defer self.Unlock()
+
+	return len(self.flights)
+}
+
+// Builds the key that identifies a query request: two requests with the same key are
+// guaranteed to produce the same results, ignoring anything that is applied to the records
+// after the query runs.
+func queryFlightKey(req *http.Request, f *filter.Filter) (string, error) {
+	if data, err := json.Marshal(map[string]interface{}{
+		`collection`:   vestigo.Param(req, `collection`),
+		`filter`:       f,
+		`noexpand`:     httputil.Q(req, `noexpand`),
+		`join_backend`: httputil.Q(req, `join_backend`),
+		`index`:        httputil.Q(req, `index`),
+		`keys`:         httputil.Q(req, `keys`),
+		`joiner`:       httputil.Q(req, `joiner`),
+	}); err == nil {
+		return string(data), nil
+	} else {
+		return ``, err
+	}
+}
+
+// Shared results are modified by each request as they are being responded to (e.g.: embedding
+// links), so every waiter receives its own shallow copy of the recordset and its records.
+func copyRecordSet(recordset *dal.RecordSet) *dal.RecordSet {
+	if recordset == nil {
+		return nil
+	}
+
+	var rs = *recordset
+
+	rs.Records = make([]*dal.Record, len(recordset.Records))
+	rs.Options = make(map[string]interface{})
+
+	for k, v := range recordset.Options {
+		rs.Options[k] = v
+	}
+
+	for i, record := range recordset.Records {
+		if record != nil {
+			var r = *record
+			rs.Records[i] = &r
+		}
+	}
+
+	return &rs
+}
+
+// Runs the given query, or waits on an identical one that another request is already running.
+func (self *Server) coalesceQuery(req *http.Request, f *filter.Filter, fn func() (*dal.RecordSet, error)) (*dal.RecordSet, error) {
+	if self.DisableCoalescing {
+		return fn()
+	}
+
+	if key, err := queryFlightKey(req, f); err == nil {
+		recordset, _, err := self.queries.Do(key, fn)
+		return recordset, err
+	} else {
+		// if the query can't be identified, it can't be shared
+		return fn()
+	}
+}
@@ -0,0 +1,87 @@
+package pivot
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryGroupCoalescesConcurrentQueries(t *testing.T) {
+	assert := require.New(t)
+
+	var group queryGroup
+	var executions int32
+	var release = make(chan struct{})
+	var wg sync.WaitGroup
+	var results = make([]*dal.RecordSet, 8)
+	var shared int32
+
+	query := func() (*dal.RecordSet, error) {
+		atomic.AddInt32(&executions, 1)
+		<-release
+		return dal.NewRecordSet(dal.NewRecord(`a`), dal.NewRecord(`b`)), nil
+	}
+
+	for i := 0; i < len(results); i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			rs, wasShared, err := group.Do(`same`, query)
+			assert.NoError(err)
+
+			if wasShared {
+				atomic.AddInt32(&shared, 1)
+			}
+
+			results[i] = rs
+		}(i)
+	}
+
+	// wait for everyone to join the flight before letting the query finish
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		group.Lock()
+		flight, ok := group.flights[`same`]
+		joined := ok && flight.waiters == len(results)-1
+		group.Unlock()
+
+		if joined {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(1, executions)
+	assert.EqualValues(len(results)-1, shared)
+	assert.Zero(group.Len())
+
+	for i, rs := range results {
+		assert.NotNil(rs)
+		assert.Len(rs.Records, 2)
+
+		// every request gets its own records so that they can be modified independently
+		for j := i + 1; j < len(results); j++ {
+			assert.False(rs.Records[0] == results[j].Records[0])
+		}
+	}
+
+	// queries that have completed are not cached
+	rs, wasShared, err := group.Do(`same`, func() (*dal.RecordSet, error) {
+		atomic.AddInt32(&executions, 1)
+		return dal.NewRecordSet(), nil
+	})
+
+	assert.NoError(err)
+	assert.False(wasShared)
+	assert.Len(rs.Records, 0)
+	assert.EqualValues(2, executions)
+}
@@ -1,3 +1,6 @@
+//go:build !noui
+// +build !noui
+
 // Code generated by "esc -o static.go -pkg pivot -modtime 1500000000 -prefix ui ui"; DO NOT EDIT.
 
 package pivot
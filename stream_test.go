@@ -0,0 +1,60 @@
+package pivot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ghetzel/pivot/v3/backends/spi"
+	"github.com/ghetzel/pivot/v3/backends/spi/spitest"
+	"github.com/ghetzel/pivot/v3/dal"
+	"github.com/ghetzel/pivot/v3/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStreamRecordSet(t *testing.T) {
+	assert := require.New(t)
+	server := NewServer(`memory://`)
+
+	backend := spi.NewAdapter(dal.MustParseConnectionString(`memory://`), spitest.NewMemoryDriver())
+
+	assert.NoError(backend.CreateCollection(dal.NewCollection(`things`, dal.Field{
+		Name: `name`,
+		Type: dal.StringType,
+	})))
+
+	assert.NoError(backend.Insert(`things`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `a`),
+		dal.NewRecord(2).Set(`name`, `b`),
+		dal.NewRecord(3).Set(`name`, `c`),
+	)))
+
+	collection, err := backend.GetCollection(`things`)
+	assert.NoError(err)
+	assert.True(server.canStreamRecordSet(collection))
+
+	w := httptest.NewRecorder()
+	server.streamRecordSet(
+		w,
+		httptest.NewRequest(`GET`, `/api/collections/things/query/?stream=true`, nil),
+		backend.WithSearch(collection),
+		collection,
+		filter.All(),
+	)
+
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(`application/json`, w.Header().Get(`Content-Type`))
+
+	var recordset dal.RecordSet
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &recordset))
+	assert.EqualValues(3, recordset.ResultCount)
+	assert.Len(recordset.Records, 3)
+
+	// responses that need the whole RecordSet aren't streamed
+	collection.Cache = &dal.CachePolicy{
+		MaxAge: 60,
+	}
+
+	assert.False(server.canStreamRecordSet(collection))
+}
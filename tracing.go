@@ -0,0 +1,52 @@
+package pivot
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ghetzel/pivot/v3/backends"
+	"github.com/urfave/negroni"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Starts a span for each API request, continuing any trace propagated by the client (as
+// configured with otel.SetTextMapPropagator).  Backend operations performed while handling the
+// request are traced as children of this span (see backends.TracingBackend).
+func (self *Server) tracingMiddleware(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	if !strings.HasPrefix(req.URL.Path, `/api/`) {
+		next(w, req)
+		return
+	}
+
+	var ctx = otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+	ctx, span := otel.Tracer(backends.TracerName).Start(
+		ctx,
+		req.Method+` `+req.URL.Path,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String(`http.method`, req.Method),
+			attribute.String(`http.target`, req.URL.RequestURI()),
+		),
+	)
+
+	defer span.End()
+
+	rw, ok := w.(negroni.ResponseWriter)
+
+	if !ok {
+		rw = negroni.NewResponseWriter(w)
+	}
+
+	next(rw, req.WithContext(ctx))
+
+	span.SetAttributes(attribute.Int(`http.status_code`, rw.Status()))
+
+	if rw.Status() >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(rw.Status()))
+	}
+}
@@ -0,0 +1,37 @@
+//go:build noui
+// +build noui
+
+package pivot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerEmbeddedUiNotBuilt(t *testing.T) {
+	assert := require.New(t)
+	assert.False(UiEmbedded)
+
+	root, err := ioutil.TempDir(``, `pivot-server-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	// asking for the embedded UI in a headless build only serves the API
+	server := NewServer(`fs://` + root + `/`)
+	server.UiDirectory = `embedded`
+
+	handler := server.Handler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(`GET`, `/api/status`, nil))
+	assert.Equal(http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(`GET`, `/index.html`, nil))
+	assert.Equal(http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,16 @@
+//go:build !noui
+// +build !noui
+
+package pivot
+
+import (
+	"net/http"
+)
+
+// Whether the web UI was compiled into this binary.  Build with the "noui" tag to leave it out.
+const UiEmbedded = true
+
+// returns the filesystem containing the embedded web UI
+func embeddedUiFileSystem() http.FileSystem {
+	return FS(false)
+}
@@ -1,11 +1,41 @@
 package util
 
+import (
+	"time"
+)
+
 var RecordStructTag = `pivot`
 
 type Status struct {
-	OK          bool   `json:"ok"`
-	Application string `json:"application"`
-	Version     string `json:"version"`
-	Backend     string `json:"backend,omitempty"`
-	Indexer     string `json:"indexer,omitempty"`
+	OK          bool              `json:"ok"`
+	Application string            `json:"application"`
+	Version     string            `json:"version"`
+	Backend     string            `json:"backend,omitempty"`
+	Indexer     string            `json:"indexer,omitempty"`
+	IndexQueue  *IndexQueueStatus `json:"index_queue,omitempty"`
+	Health      *HealthStatus     `json:"health,omitempty"`
+}
+
+type IndexQueueStatus struct {
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+	Overflow string `json:"overflow"`
+	Spilled  bool   `json:"spilled,omitempty"`
+	Dropped  int64  `json:"dropped,omitempty"`
+}
+
+type HealthStatus struct {
+	OK      bool             `json:"ok"`
+	Backend ComponentHealth  `json:"backend"`
+	Indexer *ComponentHealth `json:"indexer,omitempty"`
+}
+
+type ComponentHealth struct {
+	OK                  bool       `json:"ok"`
+	Latency             float64    `json:"latency_ms"`
+	CheckedAt           time.Time  `json:"checked_at"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures,omitempty"`
+	Reconnects          int        `json:"reconnects,omitempty"`
 }